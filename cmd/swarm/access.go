@@ -0,0 +1,256 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command access publishes access manifests granting access to content.
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func accessNewPass(ctx *cli.Context) {
+	ref := accessRef(ctx)
+	password := getPassPhrase("Password granting access", 0, accessPasswords(ctx))
+	ae, accessKey, err := api.NewAccessEntryPassword(password, api.DefaultKdfParams)
+	if err != nil {
+		utils.Fatalf("Error deriving the access key: %s", err)
+	}
+	publishAccessManifest(ctx, ref, accessKey, ae)
+}
+
+func accessNewPK(ctx *cli.Context) {
+	ref := accessRef(ctx)
+	grantee := ctx.String(SwarmAccessGrantKeyFlag.Name)
+	if grantee == "" {
+		utils.Fatalf("--%s is required", SwarmAccessGrantKeyFlag.Name)
+	}
+	granteeKey, err := parsePubkey(grantee)
+	if err != nil {
+		utils.Fatalf("Invalid grantee key %s: %s", grantee, err)
+	}
+	ae, accessKey, err := api.NewAccessEntryPK(getPublisherKey(ctx), granteeKey)
+	if err != nil {
+		utils.Fatalf("Error deriving the access key: %s", err)
+	}
+	publishAccessManifest(ctx, ref, accessKey, ae)
+}
+
+func accessNewACT(ctx *cli.Context) {
+	ref := accessRef(ctx)
+	act, err := api.NewACT(accessGrantees(ctx))
+	if err != nil {
+		utils.Fatalf("Error creating the ACT: %s", err)
+	}
+	publishACT(ctx, ref, getPublisherKey(ctx), act)
+}
+
+func accessGrant(ctx *cli.Context) {
+	publisher, ref, act := openACT(ctx)
+	if added := act.Grant(accessGrantees(ctx)...); added == 0 {
+		utils.Fatalf("All keys are granted access already")
+	}
+	publishACT(ctx, ref, publisher, act)
+}
+
+func accessRevoke(ctx *cli.Context) {
+	publisher, ref, act := openACT(ctx)
+	removed, err := act.Revoke(accessGrantees(ctx)...)
+	if err != nil {
+		utils.Fatalf("Error revoking access: %s", err)
+	}
+	if removed == 0 {
+		utils.Fatalf("None of the keys is granted access")
+	}
+	publishACT(ctx, ref, publisher, act)
+}
+
+// openACT downloads the ACT access manifest given as argument and returns
+// the trie and the address it grants access to decrypted with the key of
+// the publisher
+func openACT(ctx *cli.Context) (*ecdsa.PrivateKey, storage.Address, *api.ACT) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm access %s <access manifest>", ctx.Command.Name)
+	}
+	client := accessClient(ctx)
+	m, _, err := client.DownloadManifest(args[0])
+	if err != nil {
+		utils.Fatalf("Error downloading access manifest %s: %s", args[0], err)
+	}
+	entry := api.AccessManifestEntry(m)
+	if entry == nil || entry.Access.Type != api.AccessTypeACT {
+		utils.Fatalf("%s is not an ACT access manifest", args[0])
+	}
+	actManifest, _, err := client.DownloadManifest(entry.Access.Act)
+	if err != nil {
+		utils.Fatalf("Error downloading ACT %s: %s", entry.Access.Act, err)
+	}
+	publisher := getPublisherKey(ctx)
+	act, err := api.OpenACT(actManifest, publisher, entry.Access.Salt)
+	if err != nil {
+		utils.Fatalf("Error opening ACT %s: %s", entry.Access.Act, err)
+	}
+	ref, err := api.DecryptAccessEntry(entry, act.AccessKey)
+	if err != nil {
+		utils.Fatalf("Error decrypting access manifest %s: %s", args[0], err)
+	}
+	return publisher, ref, act
+}
+
+// publishACT uploads the ACT manifest of the trie and the access manifest
+// granting its grantees access
+func publishACT(ctx *cli.Context, ref storage.Address, publisher *ecdsa.PrivateKey, act *api.ACT) {
+	m, err := act.Manifest(publisher)
+	if err != nil {
+		utils.Fatalf("Error creating the ACT manifest: %s", err)
+	}
+	var actAddr storage.Address
+	if ctx.Bool(SwarmDryRunFlag.Name) {
+		fmt.Println("ACT manifest:")
+		printJSON(m)
+		// the ACT is not uploaded, so its address is not known
+		actAddr = make(storage.Address, storage.KeyLength)
+	} else {
+		hash, err := accessClient(ctx).UploadManifest(m, false)
+		if err != nil {
+			utils.Fatalf("Error uploading the ACT manifest: %s", err)
+		}
+		actAddr = common.Hex2Bytes(hash)
+	}
+	publishAccessManifest(ctx, ref, act.AccessKey, api.NewAccessEntryACT(publisher, act, actAddr))
+}
+
+// publishAccessManifest uploads the access manifest granting access to the
+// address to the holders of the access key and prints its address, or only
+// prints the manifest with --dry-run
+func publishAccessManifest(ctx *cli.Context, ref storage.Address, accessKey []byte, ae *api.AccessEntry) {
+	m, err := api.NewAccessManifest(ref, accessKey, ae)
+	if err != nil {
+		utils.Fatalf("Error creating the access manifest: %s", err)
+	}
+	if ctx.Bool(SwarmDryRunFlag.Name) {
+		fmt.Println("access manifest:")
+		printJSON(m)
+		return
+	}
+	hash, err := accessClient(ctx).UploadManifest(m, false)
+	if err != nil {
+		utils.Fatalf("Error uploading the access manifest: %s", err)
+	}
+	fmt.Println(hash)
+}
+
+func accessClient(ctx *cli.Context) *swarm.Client {
+	return writeClient(ctx, strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+}
+
+// accessRef returns the address of the manifest given as argument
+func accessRef(ctx *cli.Context) storage.Address {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm access new %s <manifest hash>", ctx.Command.Name)
+	}
+	ref, err := hex.DecodeString(args[0])
+	if err != nil || (len(ref) != storage.KeyLength && len(ref) != 2*storage.KeyLength) {
+		utils.Fatalf("Invalid manifest hash %s", args[0])
+	}
+	return ref
+}
+
+// accessPasswords returns the password in the file given with
+// --access-password, or none to prompt for it
+func accessPasswords(ctx *cli.Context) []string {
+	path := ctx.String(SwarmAccessPasswordFlag.Name)
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		utils.Fatalf("Error reading password file %s: %s", path, err)
+	}
+	return []string{strings.TrimRight(string(data), "\r\n")}
+}
+
+// accessGrantees returns the public keys in the file given with --grant-keys,
+// one hex encoded key per line
+func accessGrantees(ctx *cli.Context) []*ecdsa.PublicKey {
+	path := ctx.String(SwarmAccessGrantKeysFlag.Name)
+	if path == "" {
+		utils.Fatalf("--%s is required", SwarmAccessGrantKeysFlag.Name)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		utils.Fatalf("Error reading grantee keys %s: %s", path, err)
+	}
+	var keys []*ecdsa.PublicKey
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, err := parsePubkey(line)
+		if err != nil {
+			utils.Fatalf("Invalid grantee key %s: %s", line, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// parsePubkey parses a hex encoded compressed or uncompressed public key
+func parsePubkey(s string) (*ecdsa.PublicKey, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 33 {
+		return crypto.DecompressPubkey(data)
+	}
+	return crypto.UnmarshalPubkey(data)
+}
+
+// getPublisherKey returns the private key of the swarm account, which
+// publishes the access manifest
+func getPublisherKey(ctx *cli.Context) *ecdsa.PrivateKey {
+	bzzconfig, err := buildConfig(ctx)
+	if err != nil {
+		utils.Fatalf("unable to configure swarm: %v", err)
+	}
+	cfg := defaultNodeConfig
+	if _, err := os.Stat(bzzconfig.Path); err == nil {
+		cfg.DataDir = bzzconfig.Path
+	}
+	utils.SetNodeConfig(ctx, &cfg)
+	stack, err := node.New(&cfg)
+	if err != nil {
+		utils.Fatalf("can't create node: %v", err)
+	}
+	return getAccount(bzzconfig.BzzAccount, ctx, stack)
+}
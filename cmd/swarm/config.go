@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"os"
 	"reflect"
 	"strconv"
@@ -36,6 +37,7 @@ import (
 	"github.com/naoina/toml"
 
 	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/network"
 )
 
 const SWARM_VERSION = "0.3"
@@ -65,20 +67,80 @@ const (
 	SWARM_ENV_ACCOUNT              = "SWARM_ACCOUNT"
 	SWARM_ENV_LISTEN_ADDR          = "SWARM_LISTEN_ADDR"
 	SWARM_ENV_PORT                 = "SWARM_PORT"
+	SWARM_ENV_S3_PORT              = "SWARM_S3_PORT"
+	SWARM_ENV_RESTIC_PORT          = "SWARM_RESTIC_PORT"
 	SWARM_ENV_NETWORK_ID           = "SWARM_NETWORK_ID"
+	SWARM_ENV_PRIVATE              = "SWARM_PRIVATE"
 	SWARM_ENV_SWAP_ENABLE          = "SWARM_SWAP_ENABLE"
 	SWARM_ENV_SWAP_API             = "SWARM_SWAP_API"
 	SWARM_ENV_SYNC_DISABLE         = "SWARM_SYNC_DISABLE"
 	SWARM_ENV_SYNC_UPDATE_DELAY    = "SWARM_ENV_SYNC_UPDATE_DELAY"
+	SWARM_ENV_SYNC_BATCH_SIZE      = "SWARM_SYNC_BATCH_SIZE"
+	SWARM_ENV_SYNC_OFFER_WINDOW    = "SWARM_SYNC_OFFER_WINDOW"
+	SWARM_ENV_SYNC_BIN_CONCURRENCY = "SWARM_SYNC_BIN_CONCURRENCY"
+	SWARM_ENV_RETRIEVE_FANOUT      = "SWARM_RETRIEVE_FANOUT"
+	SWARM_ENV_LIGHT_NODE           = "SWARM_LIGHT_NODE"
+	SWARM_ENV_REQUIRED_CAPS        = "SWARM_REQUIRED_CAPABILITIES"
+	SWARM_ENV_BIN_MIN_PEERS        = "SWARM_BIN_MIN_PEERS"
+	SWARM_ENV_BIN_MAX_PEERS        = "SWARM_BIN_MAX_PEERS"
+	SWARM_ENV_MAX_BZZ_PEERS        = "SWARM_MAX_BZZ_PEERS"
+	SWARM_ENV_INLINE_THRESHOLD     = "SWARM_INLINE_THRESHOLD"
+	SWARM_ENV_MANIFEST_VERSION     = "SWARM_MANIFEST_VERSION"
+	SWARM_ENV_MANIFEST_FANOUT      = "SWARM_MANIFEST_FANOUT"
+	SWARM_ENV_PREFETCH_CONCURRENCY = "SWARM_PREFETCH_CONCURRENCY"
+	SWARM_ENV_SHUTDOWN_GRACE       = "SWARM_SHUTDOWN_GRACE"
 	SWARM_ENV_DELIVERY_SKIP_CHECK  = "SWARM_DELIVERY_SKIP_CHECK"
+	SWARM_ENV_DELIVERY_RECEIPTS    = "SWARM_DELIVERY_RECEIPTS"
+	SWARM_ENV_DELIVERY_NO_COMPRESS = "SWARM_DELIVERY_NO_COMPRESSION"
 	SWARM_ENV_ENS_API              = "SWARM_ENS_API"
+	SWARM_ENV_NAME_REGISTRY        = "SWARM_NAME_REGISTRY"
+	SWARM_ENV_FALLBACK_GATEWAYS    = "SWARM_FALLBACK_GATEWAYS"
+	SWARM_ENV_FALLBACK_TIMEOUT     = "SWARM_FALLBACK_TIMEOUT"
 	SWARM_ENV_ENS_ADDR             = "SWARM_ENS_ADDR"
 	SWARM_ENV_CORS                 = "SWARM_CORS"
+	SWARM_ENV_ACCESS_LOG           = "SWARM_ACCESS_LOG"
+	SWARM_ENV_ANONYMIZE_IPS        = "SWARM_ANONYMIZE_IPS"
+	SWARM_ENV_TLS_CERT             = "SWARM_TLS_CERT"
+	SWARM_ENV_TLS_KEY              = "SWARM_TLS_KEY"
+	SWARM_ENV_HTTP_SOCKET          = "SWARM_HTTP_SOCKET"
+	SWARM_ENV_GATEWAY_READONLY     = "SWARM_GATEWAY_READONLY"
+	SWARM_ENV_GATEWAY_ALLOW        = "SWARM_GATEWAY_ALLOW"
+	SWARM_ENV_GATEWAY_DENY         = "SWARM_GATEWAY_DENY"
+	SWARM_ENV_GATEWAY_DENY_LIST    = "SWARM_GATEWAY_DENY_LIST"
+	SWARM_ENV_GATEWAY_DENY_SIGNER  = "SWARM_GATEWAY_DENY_SIGNER"
+	SWARM_ENV_TAKEDOWN_TOKEN       = "SWARM_TAKEDOWN_TOKEN"
+	SWARM_ENV_MIME_TYPES           = "SWARM_MIME_TYPES"
+	SWARM_ENV_MIME_NOSNIFF         = "SWARM_MIME_NOSNIFF"
+	SWARM_ENV_MIME_DEFAULT         = "SWARM_MIME_DEFAULT"
+	SWARM_ENV_GATEWAY_DOMAINS      = "SWARM_GATEWAY_DOMAINS"
+	SWARM_ENV_TLS_WILDCARD_CERT    = "SWARM_TLS_WILDCARD_CERT"
+	SWARM_ENV_TLS_WILDCARD_KEY     = "SWARM_TLS_WILDCARD_KEY"
+	SWARM_ENV_HTTP_SIGNED_WRITES   = "SWARM_HTTP_SIGNED_WRITES"
+	SWARM_ENV_SIGN_KEY             = "SWARM_SIGN_KEY"
+	SWARM_ENV_SIGN_SECRET          = "SWARM_SIGN_SECRET"
+	SWARM_ENV_SIGN_KEYFILE         = "SWARM_SIGN_KEYFILE"
 	SWARM_ENV_BOOTNODES            = "SWARM_BOOTNODES"
+	SWARM_ENV_BOOTNODES_FILE       = "SWARM_BOOTNODES_FILE"
 	SWARM_ENV_PSS_ENABLE           = "SWARM_PSS_ENABLE"
+	SWARM_ENV_PSS_RELAY_TTL        = "SWARM_PSS_RELAY_TTL"
+	SWARM_ENV_PSS_POW              = "SWARM_PSS_POW"
+	SWARM_ENV_PSS_TOPIC_RATE       = "SWARM_PSS_TOPIC_RATE"
+	SWARM_ENV_PSS_TOPIC_BURST      = "SWARM_PSS_TOPIC_BURST"
 	SWARM_ENV_STORE_PATH           = "SWARM_STORE_PATH"
 	SWARM_ENV_STORE_CAPACITY       = "SWARM_STORE_CAPACITY"
+	SWARM_ENV_STORE_SIZE_BYTES     = "SWARM_STORE_SIZE_BYTES"
+	SWARM_ENV_STORE_SIZE_RATIO     = "SWARM_STORE_SIZE_RATIO"
+	SWARM_ENV_STORE_SIZE_LOWWATER  = "SWARM_STORE_SIZE_LOWWATER"
 	SWARM_ENV_STORE_CACHE_CAPACITY = "SWARM_STORE_CACHE_CAPACITY"
+	SWARM_ENV_STORE_CACHE_RATIO    = "SWARM_STORE_CACHE_RATIO"
+	SWARM_ENV_STORE_FORWARD_CACHE  = "SWARM_STORE_FORWARD_CACHE"
+	SWARM_ENV_STORE_DISK_HIGHWATER = "SWARM_STORE_DISK_HIGHWATER"
+	SWARM_ENV_STORE_RESYNC_LOST    = "SWARM_STORE_RESYNC_LOST"
+	SWARM_ENV_STORE_COMPRESS       = "SWARM_STORE_COMPRESS"
+	SWARM_ENV_STORE_ENCRYPT        = "SWARM_STORE_ENCRYPT"
+	SWARM_ENV_STORE_KEY_CMD        = "SWARM_STORE_KEY_CMD"
+	SWARM_ENV_STORE_SHARDS         = "SWARM_STORE_SHARDS"
+	SWARM_ENV_PCAP                 = "SWARM_PCAP"
 	GETH_ENV_DATADIR               = "GETH_DATADIR"
 )
 
@@ -179,6 +241,10 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		}
 	}
 
+	if ctx.GlobalIsSet(SwarmPrivateFlag.Name) {
+		currentConfig.PrivateSwarm = true
+	}
+
 	if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
 		if datadir := ctx.GlobalString(utils.DataDirFlag.Name); datadir != "" {
 			currentConfig.Path = datadir
@@ -190,6 +256,14 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.Port = bzzport
 	}
 
+	if s3port := ctx.GlobalString(SwarmS3PortFlag.Name); s3port != "" {
+		currentConfig.S3Port = s3port
+	}
+
+	if resticport := ctx.GlobalString(SwarmResticPortFlag.Name); resticport != "" {
+		currentConfig.ResticPort = resticport
+	}
+
 	if bzzaddr := ctx.GlobalString(SwarmListenAddrFlag.Name); bzzaddr != "" {
 		currentConfig.ListenAddr = bzzaddr
 	}
@@ -206,10 +280,82 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.SyncUpdateDelay = d
 	}
 
+	if n := ctx.GlobalInt(SwarmSyncBatchSizeFlag.Name); n > 0 {
+		currentConfig.SyncBatchSize = n
+	}
+
+	if n := ctx.GlobalInt(SwarmSyncOfferWindowFlag.Name); n > 0 {
+		currentConfig.SyncOfferWindow = n
+	}
+
+	if n := ctx.GlobalInt(SwarmSyncBinConcurrencyFlag.Name); n > 0 {
+		currentConfig.SyncConcurrency = n
+	}
+
+	if n := ctx.GlobalInt(SwarmRetrieveFanoutFlag.Name); n > 0 {
+		currentConfig.RetrieveFanout = n
+	}
+
+	if ctx.GlobalIsSet(SwarmLightNodeFlag.Name) {
+		currentConfig.LightNode = true
+	}
+
+	if ctx.GlobalIsSet(SwarmRequiredCapabilitiesFlag.Name) {
+		caps, err := network.ParseCapabilities(ctx.GlobalString(SwarmRequiredCapabilitiesFlag.Name))
+		if err != nil {
+			utils.Fatalf("Invalid required capabilities: %v", err)
+		}
+		currentConfig.RequiredCapabilities = caps
+	}
+
+	if ctx.GlobalIsSet(SwarmPssDisabledFlag.Name) {
+		currentConfig.PssEnabled = false
+	}
+
+	if n := ctx.GlobalInt(SwarmBinMinPeersFlag.Name); n > 0 {
+		currentConfig.MinBinSize = n
+	}
+
+	if ctx.GlobalIsSet(SwarmBinMaxPeersFlag.Name) {
+		currentConfig.MaxBinSize = ctx.GlobalInt(SwarmBinMaxPeersFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmMaxBzzPeersFlag.Name) {
+		currentConfig.MaxBzzPeers = ctx.GlobalInt(SwarmMaxBzzPeersFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmInlineThresholdFlag.Name) {
+		currentConfig.InlineThreshold = ctx.GlobalInt64(SwarmInlineThresholdFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmManifestVersionFlag.Name) {
+		currentConfig.ManifestVersion = ctx.GlobalInt(SwarmManifestVersionFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmManifestFanoutFlag.Name) {
+		currentConfig.ManifestFanout = ctx.GlobalInt(SwarmManifestFanoutFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmPrefetchConcurrencyFlag.Name) {
+		currentConfig.PrefetchConcurrency = ctx.GlobalInt(SwarmPrefetchConcurrencyFlag.Name)
+	}
+
+	if d := ctx.GlobalDuration(SwarmShutdownGraceFlag.Name); d > 0 {
+		currentConfig.ShutdownGracePeriod = d
+	}
+
 	if ctx.GlobalIsSet(SwarmDeliverySkipCheckFlag.Name) {
 		currentConfig.DeliverySkipCheck = true
 	}
 
+	if ctx.GlobalIsSet(SwarmDeliveryReceiptsFlag.Name) {
+		currentConfig.DeliveryReceipts = true
+	}
+
+	if ctx.GlobalIsSet(SwarmDeliveryNoCompressionFlag.Name) {
+		currentConfig.DeliveryCompression = false
+	}
+
 	currentConfig.SwapAPI = ctx.GlobalString(SwarmSwapAPIFlag.Name)
 	if currentConfig.SwapEnabled && currentConfig.SwapAPI == "" {
 		utils.Fatalf(SWARM_ERR_SWAP_SET_NO_API)
@@ -224,14 +370,125 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.EnsAPIs = ensAPIs
 	}
 
+	if names := ctx.GlobalString(SwarmNameRegistryFlag.Name); names != "" {
+		currentConfig.NameRegistry = names
+	}
+
+	if ctx.GlobalIsSet(SwarmFallbackGatewayFlag.Name) {
+		currentConfig.FallbackGateways = ctx.GlobalStringSlice(SwarmFallbackGatewayFlag.Name)
+	}
+
+	if d := ctx.GlobalDuration(SwarmFallbackTimeoutFlag.Name); d > 0 {
+		currentConfig.FallbackTimeout = d
+	}
+
+	if d := ctx.GlobalDuration(SwarmPssRelayTTLFlag.Name); d > 0 {
+		currentConfig.Pss.RelayTTL = d
+	}
+
+	if ctx.GlobalIsSet(SwarmPssPoWFlag.Name) {
+		currentConfig.Pss.MinPoW = ctx.GlobalFloat64(SwarmPssPoWFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmPssTopicRateFlag.Name) {
+		currentConfig.Pss.TopicRate = ctx.GlobalFloat64(SwarmPssTopicRateFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmPssTopicBurstFlag.Name) {
+		currentConfig.Pss.TopicBurst = ctx.GlobalInt(SwarmPssTopicBurstFlag.Name)
+	}
+
 	if cors := ctx.GlobalString(CorsStringFlag.Name); cors != "" {
 		currentConfig.Cors = cors
 	}
 
+	if accessLog := ctx.GlobalString(SwarmAccessLogFlag.Name); accessLog != "" {
+		currentConfig.AccessLog = accessLog
+	}
+
+	if ctx.GlobalIsSet(SwarmAnonymizeIPsFlag.Name) {
+		currentConfig.AnonymizeIPs = true
+	}
+
+	if cert := ctx.GlobalString(SwarmTLSCertFlag.Name); cert != "" {
+		currentConfig.TLSCert = cert
+	}
+
+	if key := ctx.GlobalString(SwarmTLSKeyFlag.Name); key != "" {
+		currentConfig.TLSKey = key
+	}
+
+	if socket := ctx.GlobalString(SwarmHTTPSocketFlag.Name); socket != "" {
+		currentConfig.HTTPSocket = socket
+	}
+
+	if ctx.GlobalIsSet(SwarmGatewayReadOnlyFlag.Name) {
+		currentConfig.GatewayReadOnly = true
+	}
+
+	if ctx.GlobalIsSet(SwarmGatewayAllowFlag.Name) {
+		currentConfig.GatewayAllow = ctx.GlobalStringSlice(SwarmGatewayAllowFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmGatewayDenyFlag.Name) {
+		currentConfig.GatewayDeny = ctx.GlobalStringSlice(SwarmGatewayDenyFlag.Name)
+	}
+
+	if url := ctx.GlobalString(SwarmGatewayDenyListFlag.Name); url != "" {
+		currentConfig.GatewayDenyList = url
+	}
+
+	if signer := ctx.GlobalString(SwarmGatewayDenySignerFlag.Name); signer != "" {
+		currentConfig.GatewayDenySigner = signer
+	}
+
+	if token := ctx.GlobalString(SwarmTakedownTokenFlag.Name); token != "" {
+		currentConfig.TakedownToken = token
+	}
+
+	if ctx.GlobalIsSet(SwarmMimeTypesFlag.Name) {
+		currentConfig.MimeTypes = ctx.GlobalStringSlice(SwarmMimeTypesFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmMimeNoSniffFlag.Name) {
+		currentConfig.MimeSniff = false
+	}
+
+	if typ := ctx.GlobalString(SwarmMimeDefaultFlag.Name); typ != "" {
+		currentConfig.MimeDefault = typ
+	}
+
+	if ctx.GlobalIsSet(SwarmGatewayDomainFlag.Name) {
+		currentConfig.GatewayDomains = ctx.GlobalStringSlice(SwarmGatewayDomainFlag.Name)
+	}
+
+	if cert := ctx.GlobalString(SwarmTLSWildcardCertFlag.Name); cert != "" {
+		currentConfig.TLSWildcardCert = cert
+	}
+
+	if key := ctx.GlobalString(SwarmTLSWildcardKeyFlag.Name); key != "" {
+		currentConfig.TLSWildcardKey = key
+	}
+
+	if ctx.GlobalIsSet(SwarmHTTPSignedWritesFlag.Name) {
+		currentConfig.RequireSignedWrites = true
+	}
+
 	if ctx.GlobalIsSet(utils.BootnodesFlag.Name) {
 		currentConfig.BootNodes = ctx.GlobalString(utils.BootnodesFlag.Name)
 	}
 
+	if path := ctx.GlobalString(SwarmBootnodesFileFlag.Name); path != "" {
+		bootnodes, err := loadBootnodes(path)
+		if err != nil {
+			utils.Fatalf("Failed to load bootnodes file: %v", err)
+		}
+		if currentConfig.BootNodes != "" {
+			bootnodes = append([]string{currentConfig.BootNodes}, bootnodes...)
+		}
+		currentConfig.BootNodes = strings.Join(bootnodes, ",")
+	}
+
 	if storePath := ctx.GlobalString(SwarmStorePath.Name); storePath != "" {
 		currentConfig.LocalStoreParams.ChunkDbPath = storePath
 	}
@@ -240,10 +497,54 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.LocalStoreParams.DbCapacity = storeCapacity
 	}
 
+	if storeBytes := ctx.GlobalUint64(SwarmStoreByteCapacity.Name); storeBytes != 0 {
+		currentConfig.LocalStoreParams.DbByteCapacity = storeBytes
+	}
+
+	if ctx.GlobalIsSet(SwarmStoreByteCapacityRatio.Name) {
+		currentConfig.LocalStoreParams.DbByteCapacityRatio = ctx.GlobalFloat64(SwarmStoreByteCapacityRatio.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmStoreLowWater.Name) {
+		currentConfig.LocalStoreParams.DbLowWater = ctx.GlobalFloat64(SwarmStoreLowWater.Name)
+	}
+
 	if storeCacheCapacity := ctx.GlobalUint(SwarmStoreCacheCapacity.Name); storeCacheCapacity != 0 {
 		currentConfig.LocalStoreParams.CacheCapacity = storeCacheCapacity
 	}
 
+	if ctx.GlobalIsSet(SwarmStoreCacheRatio.Name) {
+		currentConfig.LocalStoreParams.CacheCapacityRatio = ctx.GlobalFloat64(SwarmStoreCacheRatio.Name)
+	}
+
+	if forwardCache := ctx.GlobalUint64(SwarmStoreForwardCache.Name); forwardCache != 0 {
+		currentConfig.LocalStoreParams.ForwardCacheCapacity = forwardCache
+	}
+
+	if ctx.GlobalIsSet(SwarmStoreDiskHighWater.Name) {
+		currentConfig.LocalStoreParams.DiskHighWater = ctx.GlobalFloat64(SwarmStoreDiskHighWater.Name)
+	}
+
+	if ctx.GlobalBool(SwarmStoreResyncLost.Name) {
+		currentConfig.ResyncLostBins = true
+	}
+
+	if ctx.GlobalBool(SwarmStoreCompress.Name) {
+		currentConfig.LocalStoreParams.Compress = true
+	}
+
+	if ctx.GlobalBool(SwarmStoreEncrypt.Name) {
+		currentConfig.EncryptStore = true
+	}
+
+	if keyCmd := ctx.GlobalString(SwarmStoreKeyCommand.Name); keyCmd != "" {
+		currentConfig.StoreKeyCommand = keyCmd
+	}
+
+	if ctx.GlobalIsSet(SwarmStoreShards.Name) {
+		currentConfig.LocalStoreParams.ChunkDbShards = ctx.GlobalStringSlice(SwarmStoreShards.Name)
+	}
+
 	return currentConfig
 
 }
@@ -266,6 +567,12 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		}
 	}
 
+	if v := os.Getenv(SWARM_ENV_PRIVATE); v != "" {
+		if private, err := strconv.ParseBool(v); err == nil {
+			currentConfig.PrivateSwarm = private
+		}
+	}
+
 	if datadir := os.Getenv(GETH_ENV_DATADIR); datadir != "" {
 		currentConfig.Path = datadir
 	}
@@ -275,6 +582,14 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		currentConfig.Port = bzzport
 	}
 
+	if s3port := os.Getenv(SWARM_ENV_S3_PORT); s3port != "" {
+		currentConfig.S3Port = s3port
+	}
+
+	if resticport := os.Getenv(SWARM_ENV_RESTIC_PORT); resticport != "" {
+		currentConfig.ResticPort = resticport
+	}
+
 	if bzzaddr := os.Getenv(SWARM_ENV_LISTEN_ADDR); bzzaddr != "" {
 		currentConfig.ListenAddr = bzzaddr
 	}
@@ -297,12 +612,116 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		}
 	}
 
+	if v := os.Getenv(SWARM_ENV_DELIVERY_RECEIPTS); v != "" {
+		if receipts, err := strconv.ParseBool(v); err == nil {
+			currentConfig.DeliveryReceipts = receipts
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_DELIVERY_NO_COMPRESS); v != "" {
+		if noCompression, err := strconv.ParseBool(v); err == nil {
+			currentConfig.DeliveryCompression = !noCompression
+		}
+	}
+
 	if v := os.Getenv(SWARM_ENV_SYNC_UPDATE_DELAY); v != "" {
 		if d, err := time.ParseDuration(v); err != nil {
 			currentConfig.SyncUpdateDelay = d
 		}
 	}
 
+	if v := os.Getenv(SWARM_ENV_SYNC_BATCH_SIZE); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			currentConfig.SyncBatchSize = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_SYNC_OFFER_WINDOW); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			currentConfig.SyncOfferWindow = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_SYNC_BIN_CONCURRENCY); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			currentConfig.SyncConcurrency = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_RETRIEVE_FANOUT); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			currentConfig.RetrieveFanout = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_LIGHT_NODE); v != "" {
+		if light, err := strconv.ParseBool(v); err == nil {
+			currentConfig.LightNode = light
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_REQUIRED_CAPS); v != "" {
+		caps, err := network.ParseCapabilities(v)
+		if err != nil {
+			utils.Fatalf("Invalid required capabilities: %v", err)
+		}
+		currentConfig.RequiredCapabilities = caps
+	}
+
+	if v := os.Getenv(SWARM_ENV_PSS_ENABLE); v != "" {
+		if enable, err := strconv.ParseBool(v); err == nil {
+			currentConfig.PssEnabled = enable
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_BIN_MIN_PEERS); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			currentConfig.MinBinSize = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_BIN_MAX_PEERS); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			currentConfig.MaxBinSize = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_MAX_BZZ_PEERS); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			currentConfig.MaxBzzPeers = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_INLINE_THRESHOLD); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			currentConfig.InlineThreshold = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_MANIFEST_VERSION); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			currentConfig.ManifestVersion = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_MANIFEST_FANOUT); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			currentConfig.ManifestFanout = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_PREFETCH_CONCURRENCY); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			currentConfig.PrefetchConcurrency = n
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_SHUTDOWN_GRACE); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			currentConfig.ShutdownGracePeriod = d
+		}
+	}
+
 	if swapapi := os.Getenv(SWARM_ENV_SWAP_API); swapapi != "" {
 		currentConfig.SwapAPI = swapapi
 	}
@@ -315,6 +734,30 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		currentConfig.EnsAPIs = strings.Split(ensapi, ",")
 	}
 
+	if names := os.Getenv(SWARM_ENV_NAME_REGISTRY); names != "" {
+		currentConfig.NameRegistry = names
+	}
+
+	if gateways := os.Getenv(SWARM_ENV_FALLBACK_GATEWAYS); gateways != "" {
+		currentConfig.FallbackGateways = strings.Split(gateways, ",")
+	}
+
+	if shards := os.Getenv(SWARM_ENV_STORE_SHARDS); shards != "" {
+		currentConfig.LocalStoreParams.ChunkDbShards = strings.Split(shards, ",")
+	}
+
+	if v := os.Getenv(SWARM_ENV_FALLBACK_TIMEOUT); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			currentConfig.FallbackTimeout = d
+		}
+	}
+
+	if v := os.Getenv(SWARM_ENV_PSS_RELAY_TTL); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			currentConfig.Pss.RelayTTL = d
+		}
+	}
+
 	if ensaddr := os.Getenv(SWARM_ENV_ENS_ADDR); ensaddr != "" {
 		currentConfig.EnsRoot = common.HexToAddress(ensaddr)
 	}
@@ -323,6 +766,86 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		currentConfig.Cors = cors
 	}
 
+	if accessLog := os.Getenv(SWARM_ENV_ACCESS_LOG); accessLog != "" {
+		currentConfig.AccessLog = accessLog
+	}
+
+	if v := os.Getenv(SWARM_ENV_ANONYMIZE_IPS); v != "" {
+		if anonymize, err := strconv.ParseBool(v); err == nil {
+			currentConfig.AnonymizeIPs = anonymize
+		}
+	}
+
+	if cert := os.Getenv(SWARM_ENV_TLS_CERT); cert != "" {
+		currentConfig.TLSCert = cert
+	}
+
+	if key := os.Getenv(SWARM_ENV_TLS_KEY); key != "" {
+		currentConfig.TLSKey = key
+	}
+
+	if socket := os.Getenv(SWARM_ENV_HTTP_SOCKET); socket != "" {
+		currentConfig.HTTPSocket = socket
+	}
+
+	if v := os.Getenv(SWARM_ENV_GATEWAY_READONLY); v != "" {
+		if readOnly, err := strconv.ParseBool(v); err == nil {
+			currentConfig.GatewayReadOnly = readOnly
+		}
+	}
+
+	if allow := os.Getenv(SWARM_ENV_GATEWAY_ALLOW); allow != "" {
+		currentConfig.GatewayAllow = strings.Split(allow, ",")
+	}
+
+	if deny := os.Getenv(SWARM_ENV_GATEWAY_DENY); deny != "" {
+		currentConfig.GatewayDeny = strings.Split(deny, ",")
+	}
+
+	if url := os.Getenv(SWARM_ENV_GATEWAY_DENY_LIST); url != "" {
+		currentConfig.GatewayDenyList = url
+	}
+
+	if signer := os.Getenv(SWARM_ENV_GATEWAY_DENY_SIGNER); signer != "" {
+		currentConfig.GatewayDenySigner = signer
+	}
+
+	if token := os.Getenv(SWARM_ENV_TAKEDOWN_TOKEN); token != "" {
+		currentConfig.TakedownToken = token
+	}
+
+	if types := os.Getenv(SWARM_ENV_MIME_TYPES); types != "" {
+		currentConfig.MimeTypes = strings.Split(types, ",")
+	}
+
+	if v := os.Getenv(SWARM_ENV_MIME_NOSNIFF); v != "" {
+		if noSniff, err := strconv.ParseBool(v); err == nil {
+			currentConfig.MimeSniff = !noSniff
+		}
+	}
+
+	if typ := os.Getenv(SWARM_ENV_MIME_DEFAULT); typ != "" {
+		currentConfig.MimeDefault = typ
+	}
+
+	if domains := os.Getenv(SWARM_ENV_GATEWAY_DOMAINS); domains != "" {
+		currentConfig.GatewayDomains = strings.Split(domains, ",")
+	}
+
+	if cert := os.Getenv(SWARM_ENV_TLS_WILDCARD_CERT); cert != "" {
+		currentConfig.TLSWildcardCert = cert
+	}
+
+	if key := os.Getenv(SWARM_ENV_TLS_WILDCARD_KEY); key != "" {
+		currentConfig.TLSWildcardKey = key
+	}
+
+	if v := os.Getenv(SWARM_ENV_HTTP_SIGNED_WRITES); v != "" {
+		if signedWrites, err := strconv.ParseBool(v); err == nil {
+			currentConfig.RequireSignedWrites = signedWrites
+		}
+	}
+
 	if bootnodes := os.Getenv(SWARM_ENV_BOOTNODES); bootnodes != "" {
 		currentConfig.BootNodes = bootnodes
 	}
@@ -356,6 +879,32 @@ func validateConfig(cfg *bzzapi.Config) (err error) {
 			}
 		}
 	}
+	if cfg.PrivateSwarm && cfg.NetworkID == network.DefaultNetworkID {
+		return fmt.Errorf("a private swarm cannot use the public network id %d", network.DefaultNetworkID)
+	}
+	if cfg.NameRegistry != "" && len(common.FromHex(cfg.NameRegistry)) != common.HashLength {
+		return fmt.Errorf("invalid name registry address %q", cfg.NameRegistry)
+	}
+	if cfg.GatewayDenyList != "" && !common.IsHexAddress(cfg.GatewayDenySigner) {
+		return fmt.Errorf("invalid deny list signer address %q", cfg.GatewayDenySigner)
+	}
+	if _, err := bzzapi.ParseMimeTypes(cfg.MimeTypes); err != nil {
+		return err
+	}
+	if _, _, err := mime.ParseMediaType(cfg.MimeDefault); err != nil {
+		return fmt.Errorf("invalid default content type %q: %v", cfg.MimeDefault, err)
+	}
+	for _, domain := range cfg.GatewayDomains {
+		if domain = strings.Trim(domain, "."); domain == "" || strings.ContainsAny(domain, ":/ ") {
+			return fmt.Errorf("invalid gateway domain %q, expected a domain name such as gateway.example.com", domain)
+		}
+	}
+	if (cfg.TLSWildcardCert == "") != (cfg.TLSWildcardKey == "") {
+		return errors.New("the wildcard TLS certificate and its key have to be set together")
+	}
+	if cfg.TLSWildcardCert != "" && (cfg.TLSCert == "" || len(cfg.GatewayDomains) == 0) {
+		return errors.New("the wildcard TLS certificate requires a TLS certificate and gateway domains")
+	}
 	return nil
 }
 
@@ -549,6 +549,17 @@ func TestValidateConfig(t *testing.T) {
 			}},
 			err: "invalid format [tld:][contract-addr@]url for ENS API endpoint configuration \"@/data/testnet/geth.ipc\": missing contract address",
 		},
+		{
+			cfg: &api.Config{NameRegistry: "0x0cfd2a3f2a87e3a1b2a9a9e5a6b5f1a3b8c4d9e0f1a2b3c4d5e6f708192a3b4c"},
+		},
+		{
+			cfg: &api.Config{NameRegistry: "0x0cfd"},
+			err: "invalid name registry address \"0x0cfd\"",
+		},
+		{
+			cfg: &api.Config{GatewayDenyList: "https://example.com/deny.json", GatewayDenySigner: "0x0cfd"},
+			err: "invalid deny list signer address \"0x0cfd\"",
+		},
 	} {
 		err := validateConfig(c.cfg)
 		if c.err != "" && err.Error() != c.err {
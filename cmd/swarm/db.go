@@ -17,12 +17,15 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/swarm/storage"
@@ -32,12 +35,12 @@ import (
 func dbExport(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) != 3 {
-		utils.Fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database), <file> (path to write the tar archive to, - for stdout) and the base key")
+		fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database), <file> (path to write the tar archive to, - for stdout) and the base key")
 	}
 
 	store, err := openLDBStore(args[0], common.Hex2Bytes(args[2]))
 	if err != nil {
-		utils.Fatalf("error opening local chunk database: %s", err)
+		fatalf("error opening local chunk database: %s", err)
 	}
 	defer store.Close()
 
@@ -47,29 +50,46 @@ func dbExport(ctx *cli.Context) {
 	} else {
 		f, err := os.Create(args[1])
 		if err != nil {
-			utils.Fatalf("error opening output file: %s", err)
+			fatalf("error opening output file: %s", err)
 		}
 		defer f.Close()
 		out = f
+		if strings.HasSuffix(args[1], ".gz") {
+			gz := gzip.NewWriter(f)
+			defer gz.Close()
+			out = gz
+		}
 	}
 
-	count, err := store.Export(out)
+	var since time.Time
+	if ts := ctx.Int64(SwarmExportSinceFlag.Name); ts > 0 {
+		since = time.Unix(ts, 0)
+	}
+	count, err := store.ExportSince(out, since)
 	if err != nil {
-		utils.Fatalf("error exporting local chunk database: %s", err)
+		fatalf("error exporting local chunk database: %s", err)
 	}
 
+	// the result is not mixed into an archive written to stdout
+	if jsonOutput && args[1] != "-" {
+		printJSON(map[string]int64{"exported": count, "since": ctx.Int64(SwarmExportSinceFlag.Name)})
+	}
+	if !since.IsZero() {
+		log.Info(fmt.Sprintf("successfully exported %d chunks stored since %s", count, since))
+		return
+	}
 	log.Info(fmt.Sprintf("successfully exported %d chunks", count))
 }
 
 func dbImport(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) != 3 {
-		utils.Fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database), <file> (path to read the tar archive from, - for stdin) and the base key")
+		fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database), <file> (path to read the tar archive from, - for stdin) and the base key")
 	}
 
 	store, err := openLDBStore(args[0], common.Hex2Bytes(args[2]))
 	if err != nil {
-		utils.Fatalf("error opening local chunk database: %s", err)
+		fatalf("error opening local chunk database: %s", err)
 	}
 	defer store.Close()
 
@@ -79,36 +99,237 @@ func dbImport(ctx *cli.Context) {
 	} else {
 		f, err := os.Open(args[1])
 		if err != nil {
-			utils.Fatalf("error opening input file: %s", err)
+			fatalf("error opening input file: %s", err)
 		}
 		defer f.Close()
 		in = f
 	}
+	in, err = decompressReader(in)
+	if err != nil {
+		fatalf("error reading compressed input: %s", err)
+	}
 
+	if ctx.Bool(SwarmImportResumeFlag.Name) {
+		count, skipped, err := store.ResumeImport(in)
+		if err != nil {
+			fatalf("error importing local chunk database: %s", err)
+		}
+		if jsonOutput {
+			printJSON(map[string]int64{"imported": count, "skipped": skipped})
+		}
+		log.Info(fmt.Sprintf("successfully imported %d chunks, %d already imported", count, skipped))
+		return
+	}
 	count, err := store.Import(in)
 	if err != nil {
-		utils.Fatalf("error importing local chunk database: %s", err)
+		fatalf("error importing local chunk database: %s", err)
 	}
 
+	if jsonOutput {
+		printJSON(map[string]int64{"imported": count})
+	}
 	log.Info(fmt.Sprintf("successfully imported %d chunks", count))
 }
 
+// decompressReader returns a reader decompressing the input if it is
+// compressed with gzip
+func decompressReader(in io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(in)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// a short input is left to the tar reader to reject
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
 func dbClean(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) != 2 {
-		utils.Fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database) and the base key")
+		fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database) and the base key")
 	}
 
 	store, err := openLDBStore(args[0], common.Hex2Bytes(args[1]))
 	if err != nil {
-		utils.Fatalf("error opening local chunk database: %s", err)
+		fatalf("error opening local chunk database: %s", err)
 	}
 	defer store.Close()
 
 	store.Cleanup()
+	if jsonOutput {
+		printJSON(map[string]string{"cleaned": args[0]})
+	}
+}
+
+func dbVerify(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database) and the base key")
+	}
+
+	store, err := openLDBStore(args[0], common.Hex2Bytes(args[1]))
+	if err != nil {
+		fatalf("error opening local chunk database: %s", err)
+	}
+	defer store.Close()
+
+	report, err := store.Verify(true)
+	if err != nil {
+		fatalf("error verifying local chunk database: %s", err)
+	}
+	if jsonOutput {
+		printJSON(map[string]interface{}{
+			"chunks":   report.Counted.Entries,
+			"stored":   report.Stored.Entries,
+			"orphaned": report.Orphaned,
+			"repaired": report.Repaired,
+		})
+	}
+	if !report.Repaired {
+		log.Info(fmt.Sprintf("chunk database counters are consistent (%d chunks)", report.Counted.Entries))
+		return
+	}
+	log.Warn(fmt.Sprintf("repaired chunk database counters: %d chunks counted, %d stored, %d orphaned data entries removed", report.Counted.Entries, report.Stored.Entries, report.Orphaned))
+}
+
+func dbInspect(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 3 {
+		fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database), <key> (the chunk to inspect) and the base key")
+	}
+
+	store, err := openReadOnlyLDBStore(args[0], common.Hex2Bytes(args[2]))
+	if err != nil {
+		fatalf("error opening local chunk database: %s", err)
+	}
+	defer store.Close()
+
+	addr := storage.Address(common.Hex2Bytes(args[1]))
+	info, err := store.Inspect(addr)
+	if err != nil {
+		fatalf("error inspecting chunk %s: %s", args[1], err)
+	}
+	parents, err := store.Parents(addr)
+	if err != nil {
+		fatalf("error looking up the parents of chunk %s: %s", args[1], err)
+	}
+
+	if jsonOutput {
+		var storedAt *time.Time
+		if !info.StoredAt.IsZero() {
+			storedAt = &info.StoredAt
+		}
+		printJSON(struct {
+			Chunk    storage.Address   `json:"chunk"`
+			Bin      uint8             `json:"bin"`
+			Index    uint64            `json:"index"`
+			Access   uint64            `json:"access"`
+			StoredAt *time.Time        `json:"stored,omitempty"`
+			Span     int64             `json:"span"`
+			Size     int               `json:"size"`
+			Children []storage.Address `json:"children"`
+			Parents  []storage.Address `json:"parents"`
+		}{info.Addr, info.Po, info.Idx, info.Access, storedAt, info.Span, info.Size, info.Children, parents})
+		return
+	}
+
+	fmt.Printf("chunk:     %x\n", info.Addr)
+	fmt.Printf("bin:       %d\n", info.Po)
+	fmt.Printf("index:     %d\n", info.Idx)
+	fmt.Printf("access:    %d\n", info.Access)
+	if !info.StoredAt.IsZero() {
+		fmt.Printf("stored:    %s\n", info.StoredAt)
+	}
+	fmt.Printf("span:      %d\n", info.Span)
+	fmt.Printf("size:      %d\n", info.Size)
+	fmt.Printf("children:  %d\n", len(info.Children))
+	for _, child := range info.Children {
+		fmt.Printf("  %x\n", child)
+	}
+	fmt.Printf("parents:   %d\n", len(parents))
+	for _, parent := range parents {
+		fmt.Printf("  %x\n", parent)
+	}
+}
+
+func dbMigrate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 && len(args) != 3 {
+		fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database), the base key and optionally <newchunkdb> (path to migrate the chunks to)")
+	}
+	dryRun := ctx.Bool(SwarmDryRunFlag.Name)
+
+	var (
+		store *storage.LDBStore
+		dst   *storage.LDBStore
+		err   error
+	)
+	// the legacy database is only written to if it is migrated in place
+	if dryRun || len(args) == 3 {
+		store, err = openReadOnlyLDBStore(args[0], common.Hex2Bytes(args[1]))
+	} else {
+		store, err = openLDBStore(args[0], common.Hex2Bytes(args[1]))
+	}
+	if err != nil {
+		fatalf("error opening local chunk database: %s", err)
+	}
+	defer store.Close()
+	if len(args) == 3 && !dryRun {
+		ldbparams := storage.NewLDBStoreParams(storage.NewDefaultStoreParams(), args[2])
+		ldbparams.BaseKey = common.Hex2Bytes(args[1])
+		if dst, err = storage.NewLDBStore(ldbparams); err != nil {
+			fatalf("error opening new chunk database: %s", err)
+		}
+		defer dst.Close()
+	}
+
+	start := time.Now()
+	report, err := store.MigrateLegacy(dst, dryRun, func(done, total int) {
+		if done%10000 == 0 {
+			log.Info(fmt.Sprintf("migrated %d of %d legacy chunks (%d%%)", done, total, done*100/total), "elapsed", time.Since(start))
+		}
+	})
+	if err != nil {
+		fatalf("error migrating local chunk database: %s", err)
+	}
+	if jsonOutput {
+		printJSON(map[string]interface{}{
+			"legacy":   report.Legacy,
+			"migrated": report.Migrated,
+			"invalid":  report.Invalid,
+			"dryRun":   dryRun,
+		})
+	}
+	if report.Legacy == 0 {
+		log.Info("chunk database has no legacy entries")
+		return
+	}
+	if dryRun {
+		log.Info(fmt.Sprintf("dry run: %d of %d legacy chunks would be migrated, %d invalid entries", report.Migrated, report.Legacy, report.Invalid))
+		return
+	}
+	log.Info(fmt.Sprintf("successfully migrated %d of %d legacy chunks, %d invalid entries", report.Migrated, report.Legacy, report.Invalid), "elapsed", time.Since(start))
 }
 
 func openLDBStore(path string, basekey []byte) (*storage.LDBStore, error) {
+	ldbparams, err := newLDBStoreParams(path, basekey)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewLDBStore(ldbparams)
+}
+
+// openReadOnlyLDBStore opens a chunk database without ever writing to it
+func openReadOnlyLDBStore(path string, basekey []byte) (*storage.LDBStore, error) {
+	ldbparams, err := newLDBStoreParams(path, basekey)
+	if err != nil {
+		return nil, err
+	}
+	ldbparams.ReadOnly = true
+	return storage.NewLDBStore(ldbparams)
+}
+
+func newLDBStoreParams(path string, basekey []byte) (*storage.LDBStoreParams, error) {
 	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
 		return nil, fmt.Errorf("invalid chunkdb path: %s", err)
 	}
@@ -116,5 +337,7 @@ func openLDBStore(path string, basekey []byte) (*storage.LDBStore, error) {
 	storeparams := storage.NewDefaultStoreParams()
 	ldbparams := storage.NewLDBStoreParams(storeparams, path)
 	ldbparams.BaseKey = basekey
-	return storage.NewLDBStore(ldbparams)
+	// the chunk data stays in the shards the node has configured
+	ldbparams.KeepShards = true
+	return ldbparams, nil
 }
@@ -0,0 +1,67 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command deploy publishes static site versions to a mutable resource.
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/node"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func deploy(ctx *cli.Context) {
+	args := ctx.Args()
+	rollback := ctx.Bool(SwarmDeployRollbackFlag.Name)
+	if (rollback && len(args) != 1) || (!rollback && len(args) != 2) {
+		fatalf("Usage: swarm deploy <dir> <resource> or swarm deploy --rollback <resource>")
+	}
+	var (
+		bzzapi      = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+		defaultPath = ctx.GlobalString(SwarmUploadDefaultPath.Name)
+		resource    = args[len(args)-1]
+		history     = ctx.String(SwarmDeployHistoryFlag.Name)
+	)
+	if history == "" {
+		history = filepath.Join(node.DefaultDataDir(), "swarm", "deploy", resource+".json")
+	}
+	deployer := &swarm.Deployer{
+		Client:   writeClient(ctx, bzzapi),
+		Resource: resource,
+		History:  expandPath(history),
+		Keep:     ctx.Int(SwarmDeployKeepFlag.Name),
+	}
+	if gateway := ctx.String(SwarmDeployVerifyFlag.Name); gateway != "" {
+		deployer.Verify = swarm.NewClient(strings.TrimRight(gateway, "/"))
+	}
+
+	if rollback {
+		hash, err := deployer.Rollback()
+		if err != nil {
+			fatalf("Rollback failed: %s", err)
+		}
+		printResult(map[string]string{"hash": hash}, hash)
+		return
+	}
+	hash, err := deployer.Deploy(expandPath(args[0]), defaultPath)
+	if err != nil {
+		fatalf("Deploy failed: %s", err)
+	}
+	printResult(map[string]string{"hash": hash}, hash)
+}
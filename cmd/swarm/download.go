@@ -21,7 +21,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/swarm/api"
 	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
@@ -35,7 +34,7 @@ func download(ctx *cli.Context) {
 
 	switch len(args) {
 	case 0:
-		utils.Fatalf("Usage: swarm down [options] <bzz locator> [<destination path>]")
+		fatalf("Usage: swarm down [options] <bzz locator> [<destination path>]")
 	case 1:
 		log.Trace(fmt.Sprintf("swarm down: no destination path - assuming working dir"))
 	default:
@@ -43,7 +42,7 @@ func download(ctx *cli.Context) {
 		if absDest, err := filepath.Abs(args[1]); err == nil {
 			dest = absDest
 		} else {
-			utils.Fatalf("could not get download path: %v", err)
+			fatalf("could not get download path: %v", err)
 		}
 	}
 
@@ -52,26 +51,52 @@ func download(ctx *cli.Context) {
 		isRecursive = ctx.Bool(SwarmRecursiveFlag.Name)
 		client      = swarm.NewClient(bzzapi)
 	)
+	client.Preserve = ctx.Bool(SwarmPreserveFlag.Name)
 
 	if fi, err := os.Stat(dest); err == nil {
 		if isRecursive && !fi.Mode().IsDir() {
-			utils.Fatalf("destination path is not a directory!")
+			fatalf("destination path is not a directory!")
 		}
 	} else {
 		if !os.IsNotExist(err) {
-			utils.Fatalf("could not stat path: %v", err)
+			fatalf("could not stat path: %v", err)
 		}
 	}
 
 	uri, err := api.Parse(args[0])
 	if err != nil {
-		utils.Fatalf("could not parse uri argument: %v", err)
+		fatalf("could not parse uri argument: %v", err)
+	}
+
+	if ctx.Bool(SwarmCheckFlag.Name) {
+		missing, err := client.MissingChunks(uri.Addr)
+		if err != nil {
+			fatalf("could not check %s: %v", uri.Addr, err)
+		}
+		if jsonOutput {
+			result := map[string]interface{}{"missing": missing}
+			if len(missing) > 0 {
+				result["error"] = fmt.Sprintf("%d chunks of %s are missing", len(missing), uri.Addr)
+				printJSON(result)
+				os.Exit(1)
+			}
+			result["missing"] = []string{}
+			printJSON(result)
+			return
+		}
+		for _, addr := range missing {
+			fmt.Println(addr)
+		}
+		if len(missing) > 0 {
+			fatalf("%d chunks of %s are missing", len(missing), uri.Addr)
+		}
+		return
 	}
 
 	// assume behaviour according to --recursive switch
 	if isRecursive {
 		if err := client.DownloadDirectory(uri.Addr, uri.Path, dest); err != nil {
-			utils.Fatalf("encoutered an error while downloading directory: %v", err)
+			fatalf("encoutered an error while downloading directory: %v", err)
 		}
 	} else {
 		// we are downloading a file
@@ -79,7 +104,10 @@ func download(ctx *cli.Context) {
 
 		err := client.DownloadFile(uri.Addr, uri.Path, dest)
 		if err != nil {
-			utils.Fatalf("could not download %s from given address: %s. error: %v", uri.Path, uri.Addr, err)
+			fatalf("could not download %s from given address: %s. error: %v", uri.Path, uri.Addr, err)
 		}
 	}
+	if jsonOutput {
+		printJSON(map[string]string{"destination": dest})
+	}
 }
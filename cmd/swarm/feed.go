@@ -0,0 +1,172 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command feed manages mutable resources.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func feedCreate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 || len(args) > 2 {
+		fatalf("Usage: swarm feed create <name> [<data>]")
+	}
+	if ctx.Uint64(SwarmFeedFrequencyFlag.Name) == 0 {
+		fatalf("--frequency is required")
+	}
+	var (
+		client      = feedClient(ctx)
+		isMultihash = ctx.Bool(SwarmFeedMultihashFlag.Name)
+		data        []byte
+	)
+	// a resource can be created without a first update
+	if len(args) == 2 {
+		data = feedData(args[1:], isMultihash)
+	}
+	manifest, err := client.CreateResource(args[0], ctx.Uint64(SwarmFeedFrequencyFlag.Name), data, isMultihash)
+	if err != nil {
+		fatalf("Error creating resource %s: %s", args[0], err)
+	}
+	printResult(map[string]string{"manifest": manifest}, manifest)
+}
+
+func feedUpdate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 || len(args) > 2 {
+		fatalf("Usage: swarm feed update <resource> [<data>]")
+	}
+	var (
+		client      = feedClient(ctx)
+		resource    = args[0]
+		isMultihash = ctx.Bool(SwarmFeedMultihashFlag.Name)
+		data        = feedData(args[1:], isMultihash)
+		err         error
+	)
+	if keyfile := ctx.String(SwarmFeedKeyFileFlag.Name); keyfile != "" {
+		key, kerr := crypto.LoadECDSA(expandPath(keyfile))
+		if kerr != nil {
+			fatalf("Error loading key file %s: %s", keyfile, kerr)
+		}
+		err = client.UpdateResourceSigned(resource, data, isMultihash, &mru.GenericSigner{PrivKey: key})
+	} else {
+		err = client.UpdateResource(resource, data, isMultihash)
+	}
+	if err != nil {
+		fatalf("Error updating resource %s: %s", resource, err)
+	}
+	if jsonOutput {
+		info, err := client.ResourceInfo(resource)
+		if err != nil {
+			fatalf("Error getting resource %s: %s", resource, err)
+		}
+		printJSON(info)
+	}
+}
+
+func feedLookup(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		fatalf("Usage: swarm feed lookup <resource>")
+	}
+	var (
+		client  = feedClient(ctx)
+		period  = uint32(ctx.Uint(SwarmFeedPeriodFlag.Name))
+		version = uint32(ctx.Uint(SwarmFeedVersionFlag.Name))
+		hint    = uint32(ctx.Uint(SwarmFeedHintFlag.Name))
+	)
+	if version > 0 && period == 0 {
+		fatalf("--version needs --period")
+	}
+	data, err := client.LookupResource(args[0], period, version, hint)
+	if err != nil {
+		fatalf("Error looking up resource %s: %s", args[0], err)
+	}
+	if jsonOutput {
+		printJSON(map[string]hexutil.Bytes{"data": data})
+		return
+	}
+	os.Stdout.Write(data)
+}
+
+func feedInfo(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		fatalf("Usage: swarm feed info <resource>")
+	}
+	info, err := feedClient(ctx).ResourceInfo(args[0])
+	if err != nil {
+		fatalf("Error getting resource %s: %s", args[0], err)
+	}
+	if jsonOutput {
+		printJSON(info)
+		return
+	}
+	fmt.Printf("name:        %s\n", info.Name)
+	fmt.Printf("start block: %d\n", info.StartBlock)
+	fmt.Printf("frequency:   %d\n", info.Frequency)
+	fmt.Printf("multihash:   %t\n", info.Multihash)
+	if info.LastPeriod > 0 {
+		fmt.Printf("latest:      period %d version %d (%s)\n", info.LastPeriod, info.Version, info.LastAddr)
+	}
+	for _, author := range info.Authors {
+		fmt.Printf("author:      %s\n", author.Hex())
+	}
+	if info.Merge != "" {
+		fmt.Printf("merge:       %s\n", info.Merge)
+	}
+	if info.Next != nil {
+		fmt.Printf("next:        period %d version %d (%s)\n", info.Next.Period, info.Next.Version, info.Next.Addr)
+	}
+}
+
+func feedClient(ctx *cli.Context) *swarm.Client {
+	return writeClient(ctx, strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+}
+
+// feedData returns the update data given as argument or read from stdin if
+// there is no argument or it is -, multihash updates are given as the hex
+// encoded swarm hash they point to
+func feedData(args []string, isMultihash bool) []byte {
+	var data []byte
+	if len(args) == 0 || args[0] == "-" {
+		var err error
+		if data, err = ioutil.ReadAll(os.Stdin); err != nil {
+			fatalf("Error reading update data: %s", err)
+		}
+	} else {
+		data = []byte(args[0])
+	}
+	if isMultihash {
+		hash := strings.TrimSpace(string(data))
+		if len(common.FromHex(hash)) == 0 {
+			fatalf("Invalid swarm hash %q", hash)
+		}
+		return common.FromHex(hash)
+	}
+	return data
+}
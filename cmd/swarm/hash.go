@@ -19,10 +19,8 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -30,11 +28,11 @@ import (
 func hash(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) < 1 {
-		utils.Fatalf("Usage: swarm hash <file name>")
+		fatalf("Usage: swarm hash <file name>")
 	}
 	f, err := os.Open(args[0])
 	if err != nil {
-		utils.Fatalf("Error opening file " + args[1])
+		fatalf("Error opening file %s: %s", args[0], err)
 	}
 	defer f.Close()
 
@@ -42,8 +40,7 @@ func hash(ctx *cli.Context) {
 	fileStore := storage.NewFileStore(storage.NewMapChunkStore(), storage.NewFileStoreParams())
 	addr, _, err := fileStore.Store(context.TODO(), f, stat.Size(), false)
 	if err != nil {
-		utils.Fatalf("%v\n", err)
-	} else {
-		fmt.Printf("%v\n", addr)
+		fatalf("%v", err)
 	}
+	printResult(map[string]string{"hash": addr.Hex()}, addr.Hex())
 }
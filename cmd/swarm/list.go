@@ -22,7 +22,6 @@ import (
 	"strings"
 	"text/tabwriter"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -31,9 +30,9 @@ func list(ctx *cli.Context) {
 	args := ctx.Args()
 
 	if len(args) < 1 {
-		utils.Fatalf("Please supply a manifest reference as the first argument")
+		fatalf("Please supply a manifest reference as the first argument")
 	} else if len(args) > 2 {
-		utils.Fatalf("Too many arguments - usage 'swarm ls manifest [prefix]'")
+		fatalf("Too many arguments - usage 'swarm ls manifest [prefix]'")
 	}
 	manifest := args[0]
 
@@ -46,7 +45,12 @@ func list(ctx *cli.Context) {
 	client := swarm.NewClient(bzzapi)
 	list, err := client.List(manifest, prefix)
 	if err != nil {
-		utils.Fatalf("Failed to generate file and directory list: %s", err)
+		fatalf("Failed to generate file and directory list: %s", err)
+	}
+
+	if jsonOutput {
+		printJSON(list)
+		return
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
@@ -42,7 +42,10 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/swarm"
 	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
+	bzzclient "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/capture"
 	swarmmetrics "github.com/ethereum/go-ethereum/swarm/metrics"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 
 	"gopkg.in/urfave/cli.v1"
 )
@@ -97,11 +100,26 @@ var (
 		Usage:  "Swarm local http api port",
 		EnvVar: SWARM_ENV_PORT,
 	}
+	SwarmS3PortFlag = cli.StringFlag{
+		Name:   "s3port",
+		Usage:  "Swarm local S3 compatible API port (disabled if not set)",
+		EnvVar: SWARM_ENV_S3_PORT,
+	}
+	SwarmResticPortFlag = cli.StringFlag{
+		Name:   "resticport",
+		Usage:  "Swarm local restic REST backend port (disabled if not set)",
+		EnvVar: SWARM_ENV_RESTIC_PORT,
+	}
 	SwarmNetworkIdFlag = cli.IntFlag{
 		Name:   "bzznetworkid",
 		Usage:  "Network identifier (integer, default 3=swarm testnet)",
 		EnvVar: SWARM_ENV_NETWORK_ID,
 	}
+	SwarmPrivateFlag = cli.BoolFlag{
+		Name:   "private",
+		Usage:  "Run in a private swarm with its own network id, public devp2p discovery and the default bootnodes are disabled",
+		EnvVar: SWARM_ENV_PRIVATE,
+	}
 	SwarmSwapEnabledFlag = cli.BoolFlag{
 		Name:   "swap",
 		Usage:  "Swarm SWAP enabled (default false)",
@@ -122,16 +140,135 @@ var (
 		Usage:  "Duration for sync subscriptions update after no new peers are added (default 15s)",
 		EnvVar: SWARM_ENV_SYNC_UPDATE_DELAY,
 	}
+	SwarmSyncBatchSizeFlag = cli.IntFlag{
+		Name:   "sync-batch-size",
+		Usage:  "Maximum number of chunk hashes offered in a sync batch (default 128)",
+		EnvVar: SWARM_ENV_SYNC_BATCH_SIZE,
+	}
+	SwarmSyncOfferWindowFlag = cli.IntFlag{
+		Name:   "sync-offer-window",
+		Usage:  "Number of offered hashes batches per sync stream in flight (default 1)",
+		EnvVar: SWARM_ENV_SYNC_OFFER_WINDOW,
+	}
+	SwarmSyncBinConcurrencyFlag = cli.IntFlag{
+		Name:   "sync-bin-concurrency",
+		Usage:  "Number of sync streams per proximity bin served concurrently (default 4)",
+		EnvVar: SWARM_ENV_SYNC_BIN_CONCURRENCY,
+	}
+	SwarmRetrieveFanoutFlag = cli.IntFlag{
+		Name:   "retrieve-fanout",
+		Usage:  "Number of closest peers a chunk is requested from in parallel (default 1)",
+		EnvVar: SWARM_ENV_RETRIEVE_FANOUT,
+	}
+	SwarmLightNodeFlag = cli.BoolFlag{
+		Name:   "light",
+		Usage:  "Advertise the node as light, peers do not request chunks from it (default false)",
+		EnvVar: SWARM_ENV_LIGHT_NODE,
+	}
+	SwarmRequiredCapabilitiesFlag = cli.StringFlag{
+		Name:   "required-capabilities",
+		Usage:  "Comma separated capabilities peers must advertise to be connected to (storer,light,pushsync,pss,encryption)",
+		EnvVar: SWARM_ENV_REQUIRED_CAPS,
+	}
+	SwarmBinMinPeersFlag = cli.IntFlag{
+		Name:   "bin-min-peers",
+		Usage:  "Number of peers connected in each proximity bin (default 2)",
+		EnvVar: SWARM_ENV_BIN_MIN_PEERS,
+	}
+	SwarmBinMaxPeersFlag = cli.IntFlag{
+		Name:   "bin-max-peers",
+		Usage:  "Maximum number of peers in a proximity bin outside the neighbourhood before the least useful are dropped",
+		EnvVar: SWARM_ENV_BIN_MAX_PEERS,
+	}
+	SwarmMaxBzzPeersFlag = cli.IntFlag{
+		Name:   "max-bzz-peers",
+		Usage:  "Maximum number of bzz peers before the least useful peers outside the neighbourhood are dropped",
+		EnvVar: SWARM_ENV_MAX_BZZ_PEERS,
+	}
+	SwarmInlineThresholdFlag = cli.Int64Flag{
+		Name:   "inline-threshold",
+		Usage:  "Store uploaded files up to this size in bytes inline in their manifest entries (default 0, disabled)",
+		EnvVar: SWARM_ENV_INLINE_THRESHOLD,
+	}
+	SwarmManifestVersionFlag = cli.IntFlag{
+		Name:   "manifest-version",
+		Usage:  "Encoding version of new manifests, 1 for JSON or 2 for binary (default 1)",
+		EnvVar: SWARM_ENV_MANIFEST_VERSION,
+	}
+	SwarmManifestFanoutFlag = cli.IntFlag{
+		Name:   "manifest-fanout",
+		Usage:  "Maximum number of entries of a manifest including embedded submanifests, larger submanifests are stored separately (default 0, disabled)",
+		EnvVar: SWARM_ENV_MANIFEST_FANOUT,
+	}
+	SwarmPrefetchConcurrencyFlag = cli.IntFlag{
+		Name:   "prefetch-concurrency",
+		Usage:  "Number of chunks retrieved concurrently when content is prefetched (default 16)",
+		EnvVar: SWARM_ENV_PREFETCH_CONCURRENCY,
+	}
+	SwarmShutdownGraceFlag = cli.DurationFlag{
+		Name:   "shutdown-grace",
+		Usage:  "Maximum time in-flight requests and uploads are waited for on shutdown (default 30s)",
+		EnvVar: SWARM_ENV_SHUTDOWN_GRACE,
+	}
 	SwarmDeliverySkipCheckFlag = cli.BoolFlag{
 		Name:   "delivery-skip-check",
 		Usage:  "Skip chunk delivery check (default false)",
 		EnvVar: SWARM_ENV_DELIVERY_SKIP_CHECK,
 	}
+	SwarmDeliveryReceiptsFlag = cli.BoolFlag{
+		Name:   "delivery-receipts",
+		Usage:  "Sign receipts for delivered chunks (default false)",
+		EnvVar: SWARM_ENV_DELIVERY_RECEIPTS,
+	}
+	SwarmDeliveryNoCompressionFlag = cli.BoolFlag{
+		Name:   "delivery-no-compression",
+		Usage:  "Deliver chunks uncompressed to all peers (default false)",
+		EnvVar: SWARM_ENV_DELIVERY_NO_COMPRESS,
+	}
 	EnsAPIFlag = cli.StringSliceFlag{
 		Name:   "ens-api",
 		Usage:  "ENS API endpoint for a TLD and with contract address, can be repeated, format [tld:][contract-addr@]url",
 		EnvVar: SWARM_ENV_ENS_API,
 	}
+	SwarmNameRegistryFlag = cli.StringFlag{
+		Name:   "name-registry",
+		Usage:  "Address of the name registry names are resolved with ahead of ENS, see swarm names create",
+		EnvVar: SWARM_ENV_NAME_REGISTRY,
+	}
+	SwarmFallbackGatewayFlag = cli.StringSliceFlag{
+		Name:   "fallback-gateway",
+		Usage:  "URL of a trusted HTTP gateway chunks which cannot be retrieved from the network are retrieved from, can be repeated",
+		EnvVar: SWARM_ENV_FALLBACK_GATEWAYS,
+	}
+	SwarmFallbackTimeoutFlag = cli.DurationFlag{
+		Name:   "fallback-timeout",
+		Usage:  "Time a chunk is retrieved from the network for before it is retrieved from the fallback gateways (default 10s)",
+		EnvVar: SWARM_ENV_FALLBACK_TIMEOUT,
+	}
+	SwarmPssDisabledFlag = cli.BoolFlag{
+		Name:  "nopss",
+		Usage: "Disable pss, the node neither runs nor forwards pss messages",
+	}
+	SwarmPssRelayTTLFlag = cli.DurationFlag{
+		Name:   "pss.relay.ttl",
+		Usage:  "Time pss messages which cannot be forwarded to any peer are queued and retried for, the queue is disabled if not set",
+		EnvVar: SWARM_ENV_PSS_RELAY_TTL,
+	}
+	SwarmPssPoWFlag = cli.Float64Flag{
+		Name:   "pss.pow",
+		Usage:  "Proof of work required of incoming encrypted pss messages and target of sent messages, 0 disables the requirement",
+		EnvVar: SWARM_ENV_PSS_POW,
+	}
+	SwarmPssTopicRateFlag = cli.Float64Flag{
+		Name:   "pss.topic.rate",
+		Usage:  "Number of incoming pss messages per second accepted on each topic, 0 disables the limit",
+		EnvVar: SWARM_ENV_PSS_TOPIC_RATE,
+	}
+	SwarmPssTopicBurstFlag = cli.IntFlag{
+		Name:   "pss.topic.burst",
+		Usage:  "Number of incoming pss messages accepted on a topic in a burst above --pss.topic.rate",
+		EnvVar: SWARM_ENV_PSS_TOPIC_BURST,
+	}
 	SwarmApiFlag = cli.StringFlag{
 		Name:  "bzzapi",
 		Usage: "Swarm HTTP endpoint",
@@ -141,6 +278,10 @@ var (
 		Name:  "recursive",
 		Usage: "Upload directories recursively",
 	}
+	SwarmCheckFlag = cli.BoolFlag{
+		Name:  "check",
+		Usage: "List the chunks of the content missing from the local store of the node instead of downloading it",
+	}
 	SwarmWantManifestFlag = cli.BoolTFlag{
 		Name:  "manifest",
 		Usage: "Automatic manifest upload (default true)",
@@ -157,15 +298,271 @@ var (
 		Name:  "mime",
 		Usage: "Manually specify MIME type",
 	}
+	SwarmUploadMediaFlag = cli.BoolFlag{
+		Name:  "media",
+		Usage: "upload a HLS/DASH stream directory (or an mp4 file segmented with ffmpeg) for streaming",
+	}
 	SwarmEncryptedFlag = cli.BoolFlag{
 		Name:  "encrypt",
 		Usage: "use encrypted upload",
 	}
+	SwarmPreserveFlag = cli.BoolFlag{
+		Name:  "preserve",
+		Usage: "Upload symbolic links as links, restore the mode, modification time and symbolic links of downloaded files",
+	}
+	SwarmUploadHashFlag = cli.StringFlag{
+		Name:  "hash",
+		Usage: fmt.Sprintf("chunk hash function of the upload (%s), the gateway default if not set", strings.Join(storage.SupportedHashes, ", ")),
+	}
+	SwarmUploadSparseFlag = cli.BoolFlag{
+		Name:  "sparse",
+		Usage: "Do not store the chunks of zeros of the upload, for sparse files and disk images. The upload can only be retrieved from nodes which support sparse content",
+	}
+	SwarmUploadErrorDocumentFlag = cli.StringSliceFlag{
+		Name:  "errordocument",
+		Usage: "Document served for the unresolved paths of the upload, as status[:lang]=path e.g. 404=404.html or 404:de=de/404.html, can be repeated",
+	}
+	SwarmUploadSPAFlag = cli.BoolFlag{
+		Name:  "spa",
+		Usage: "Serve index.html for the unresolved paths of the upload, for single-page applications routing their paths in the browser",
+	}
+	SwarmSyncResourceFlag = cli.BoolFlag{
+		Name:  "resource",
+		Usage: "sync to the manifest referenced by a mutable resource and update the resource",
+	}
+	SwarmDeployVerifyFlag = cli.StringFlag{
+		Name:  "verify-gateway",
+		Usage: "Swarm HTTP endpoint used to verify the retrievability of a deploy (default --bzzapi)",
+	}
+	SwarmDeployKeepFlag = cli.IntFlag{
+		Name:  "keep",
+		Usage: "Number of previous deploys kept for rollback",
+		Value: bzzclient.DefaultDeployKeep,
+	}
+	SwarmDeployHistoryFlag = cli.StringFlag{
+		Name:  "history",
+		Usage: "Path of the deploy history file (default <datadir>/swarm/deploy/<resource>.json)",
+	}
+	SwarmDeployRollbackFlag = cli.BoolFlag{
+		Name:  "rollback",
+		Usage: "Switch the resource back to the previous deploy",
+	}
+	SwarmDryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Only report what would be done",
+	}
+	SwarmFeedFrequencyFlag = cli.Uint64Flag{
+		Name:  "frequency",
+		Usage: "Number of blocks between the update periods of a new mutable resource",
+	}
+	SwarmFeedMultihashFlag = cli.BoolFlag{
+		Name:  "multihash",
+		Usage: "Update data is the hex encoded swarm hash the resource points to",
+	}
+	SwarmFeedKeyFileFlag = cli.StringFlag{
+		Name:  "keyfile",
+		Usage: "File with the hex encoded private key which signs the update (default: signed by the node)",
+	}
+	SwarmFeedPeriodFlag = cli.UintFlag{
+		Name:  "period",
+		Usage: "Period of the looked up update (default: latest)",
+	}
+	SwarmFeedVersionFlag = cli.UintFlag{
+		Name:  "version",
+		Usage: "Version of the looked up update within --period (default: latest)",
+	}
+	SwarmFeedHintFlag = cli.UintFlag{
+		Name:  "hint",
+		Usage: "Period of a known update, the lookup of the latest update does not search before it",
+	}
+	SwarmNamesMergeFlag = cli.StringFlag{
+		Name:  "merge",
+		Usage: "Rule choosing the current name table among the updates of the owners, last-writer or sequence",
+		Value: "sequence",
+	}
+	SwarmJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the results and errors of the commands as JSON to stdout",
+	}
+	SwarmAccessGrantKeyFlag = cli.StringFlag{
+		Name:  "grant-key",
+		Usage: "Hex encoded public key of the grantee",
+	}
+	SwarmAccessGrantKeysFlag = cli.StringFlag{
+		Name:  "grant-keys",
+		Usage: "File with the hex encoded public keys of the grantees, one per line",
+	}
+	SwarmAccessPasswordFlag = cli.StringFlag{
+		Name:  "access-password",
+		Usage: "File with the password granting access (prompted for if not set)",
+	}
+	SwarmProxyAddrFlag = cli.StringFlag{
+		Name:  "proxyaddr",
+		Usage: "Address the proxy listens on",
+		Value: "127.0.0.1:8501",
+	}
+	SwarmProxyTLDFlag = cli.StringSliceFlag{
+		Name:  "proxy-tld",
+		Usage: "Top level domain of the ENS names served by the proxy (default: eth)",
+	}
+	SwarmExportSinceFlag = cli.Int64Flag{
+		Name:  "since",
+		Usage: "Only export the chunks stored at or after this Unix time",
+	}
+	SwarmImportResumeFlag = cli.BoolFlag{
+		Name:  "resume",
+		Usage: "Skip the chunks already in the chunk database to continue an interrupted import",
+	}
+	SwarmBootnodesFileFlag = cli.StringFlag{
+		Name:   "bootnodes-file",
+		Usage:  "File with the enode URLs of swarm bootnodes, one per line",
+		EnvVar: SWARM_ENV_BOOTNODES_FILE,
+	}
+	SwarmGenConfigNodesFlag = cli.IntFlag{
+		Name:  "nodes",
+		Usage: "Number of nodes in the private swarm",
+		Value: 3,
+	}
+	SwarmGenConfigBootnodesFlag = cli.IntFlag{
+		Name:  "bootnode-count",
+		Usage: "Number of nodes acting as bootnodes for the others",
+		Value: 1,
+	}
+	SwarmGenConfigHostFlag = cli.StringFlag{
+		Name:  "host",
+		Usage: "IP address the nodes are reachable at",
+		Value: "127.0.0.1",
+	}
+	SwarmGenConfigP2PPortFlag = cli.IntFlag{
+		Name:  "p2p-port",
+		Usage: "Network listening port of the first node, incremented for every node",
+		Value: 30399,
+	}
+	SwarmGenConfigBzzPortFlag = cli.IntFlag{
+		Name:  "bzz-port",
+		Usage: "HTTP API port of the first node, incremented for every node",
+		Value: 8500,
+	}
+	SwarmPcapFlag = cli.StringFlag{
+		Name:   "pcap",
+		Usage:  "Record the bzz, stream and pss protocol messages to this rotating capture file",
+		EnvVar: SWARM_ENV_PCAP,
+	}
+	SwarmPcapPayloadFlag = cli.IntFlag{
+		Name:  "pcap-payload",
+		Usage: "Number of payload bytes of each message recorded to the capture file",
+		Value: capture.DefaultPayloadSize,
+	}
 	CorsStringFlag = cli.StringFlag{
 		Name:   "corsdomain",
 		Usage:  "Domain on which to send Access-Control-Allow-Origin header (multiple domains can be supplied separated by a ',')",
 		EnvVar: SWARM_ENV_CORS,
 	}
+	SwarmAccessLogFlag = cli.StringFlag{
+		Name:   "access-log",
+		Usage:  "File the JSON access log of the HTTP API is written to, its rollup is served at /access-stats",
+		EnvVar: SWARM_ENV_ACCESS_LOG,
+	}
+	SwarmAnonymizeIPsFlag = cli.BoolFlag{
+		Name:   "access-log-anonymize",
+		Usage:  "Remove the host part of client IP addresses from the access log",
+		EnvVar: SWARM_ENV_ANONYMIZE_IPS,
+	}
+	SwarmTLSCertFlag = cli.StringFlag{
+		Name:   "tls-cert",
+		Usage:  "Certificate file the HTTP API is served with over TLS and HTTP/2, reloaded when it changes so that it can be renewed by an ACME client",
+		EnvVar: SWARM_ENV_TLS_CERT,
+	}
+	SwarmTLSKeyFlag = cli.StringFlag{
+		Name:   "tls-key",
+		Usage:  "Private key file of the TLS certificate",
+		EnvVar: SWARM_ENV_TLS_KEY,
+	}
+	SwarmHTTPSocketFlag = cli.StringFlag{
+		Name:   "http-socket",
+		Usage:  "Path of a unix socket the HTTP API is also served on, only accessible by the user running the node",
+		EnvVar: SWARM_ENV_HTTP_SOCKET,
+	}
+	SwarmGatewayReadOnlyFlag = cli.BoolFlag{
+		Name:   "gateway-readonly",
+		Usage:  "Refuse the requests of the HTTP API which store or modify content",
+		EnvVar: SWARM_ENV_GATEWAY_READONLY,
+	}
+	SwarmGatewayAllowFlag = cli.StringSliceFlag{
+		Name:   "gateway-allow",
+		Usage:  "Root hash or name the HTTP API only serves, can be repeated, any content is served if not set",
+		EnvVar: SWARM_ENV_GATEWAY_ALLOW,
+	}
+	SwarmGatewayDenyFlag = cli.StringSliceFlag{
+		Name:   "gateway-deny",
+		Usage:  "Root hash or name the HTTP API refuses to serve, can be repeated",
+		EnvVar: SWARM_ENV_GATEWAY_DENY,
+	}
+	SwarmGatewayDenyListFlag = cli.StringFlag{
+		Name:   "gateway-deny-list",
+		Usage:  "URL of a signed list of roots the HTTP API refuses to serve, fetched again every 10 minutes",
+		EnvVar: SWARM_ENV_GATEWAY_DENY_LIST,
+	}
+	SwarmGatewayDenySignerFlag = cli.StringFlag{
+		Name:   "gateway-deny-signer",
+		Usage:  "Address of the key the deny list has to be signed with",
+		EnvVar: SWARM_ENV_GATEWAY_DENY_SIGNER,
+	}
+	SwarmTakedownTokenFlag = cli.StringFlag{
+		Name:   "takedown-token",
+		Usage:  "Bearer token of the takedown webhook of the HTTP API operators block roots with, the webhook is disabled if not set",
+		EnvVar: SWARM_ENV_TAKEDOWN_TOKEN,
+	}
+	SwarmMimeTypesFlag = cli.StringSliceFlag{
+		Name:   "mime-type",
+		Usage:  "Content type of the files with an extension as ext=type, overriding the built-in types, can be repeated",
+		EnvVar: SWARM_ENV_MIME_TYPES,
+	}
+	SwarmMimeNoSniffFlag = cli.BoolFlag{
+		Name:   "mime-nosniff",
+		Usage:  "Do not detect the content types of files with unknown extensions from their content",
+		EnvVar: SWARM_ENV_MIME_NOSNIFF,
+	}
+	SwarmMimeDefaultFlag = cli.StringFlag{
+		Name:   "mime-default",
+		Usage:  "Content type of the files whose type is not detected (default application/octet-stream)",
+		EnvVar: SWARM_ENV_MIME_DEFAULT,
+	}
+	SwarmGatewayDomainFlag = cli.StringSliceFlag{
+		Name:   "gateway-domain",
+		Usage:  "Domain whose subdomains serve the roots of the HTTP API as <root>.<domain>, giving each root its own browser origin, can be repeated",
+		EnvVar: SWARM_ENV_GATEWAY_DOMAINS,
+	}
+	SwarmTLSWildcardCertFlag = cli.StringFlag{
+		Name:   "tls-wildcard-cert",
+		Usage:  "Wildcard certificate file the subdomains of the gateway domains are served with, the TLS certificate if not set",
+		EnvVar: SWARM_ENV_TLS_WILDCARD_CERT,
+	}
+	SwarmTLSWildcardKeyFlag = cli.StringFlag{
+		Name:   "tls-wildcard-key",
+		Usage:  "Private key file of the wildcard TLS certificate",
+		EnvVar: SWARM_ENV_TLS_WILDCARD_KEY,
+	}
+	SwarmHTTPSignedWritesFlag = cli.BoolFlag{
+		Name:   "http-signed-writes",
+		Usage:  "Refuse the write requests of the HTTP API which are not signed with a signing key added with bzzadmin_addHMACKey or bzzadmin_addECDSAKey",
+		EnvVar: SWARM_ENV_HTTP_SIGNED_WRITES,
+	}
+	SwarmSignKeyFlag = cli.StringFlag{
+		Name:   "sign-key",
+		Usage:  "Id of the signing key the write requests to the HTTP API are signed with",
+		EnvVar: SWARM_ENV_SIGN_KEY,
+	}
+	SwarmSignSecretFlag = cli.StringFlag{
+		Name:   "sign-secret",
+		Usage:  "Hex encoded secret of the HMAC signing key",
+		EnvVar: SWARM_ENV_SIGN_SECRET,
+	}
+	SwarmSignKeyFileFlag = cli.StringFlag{
+		Name:   "sign-keyfile",
+		Usage:  "File of the hex encoded private key of the ECDSA signing key",
+		EnvVar: SWARM_ENV_SIGN_KEYFILE,
+	}
 	SwarmStorePath = cli.StringFlag{
 		Name:   "store.path",
 		Usage:  "Path to leveldb chunk DB (default <$GETH_ENV_DIR>/swarm/bzz-<$BZZ_KEY>/chunks)",
@@ -176,11 +573,66 @@ var (
 		Usage:  "Number of chunks (5M is roughly 20-25GB) (default 5000000)",
 		EnvVar: SWARM_ENV_STORE_CAPACITY,
 	}
+	SwarmStoreByteCapacity = cli.Uint64Flag{
+		Name:   "store.size.bytes",
+		Usage:  "Size of the chunk DB on disk in bytes including the LevelDB overhead above which garbage is collected, 0 disables the limit",
+		EnvVar: SWARM_ENV_STORE_SIZE_BYTES,
+	}
+	SwarmStoreByteCapacityRatio = cli.Float64Flag{
+		Name:   "store.size.ratio",
+		Usage:  "Ratio of the disk space available to the chunk DB the --store.size.bytes limit is periodically set to, 0 keeps --store.size.bytes",
+		EnvVar: SWARM_ENV_STORE_SIZE_RATIO,
+	}
+	SwarmStoreLowWater = cli.Float64Flag{
+		Name:   "store.size.lowwater",
+		Usage:  "Ratio of --store.size.bytes garbage is collected down to when it is exceeded (default 0.9)",
+		EnvVar: SWARM_ENV_STORE_SIZE_LOWWATER,
+	}
 	SwarmStoreCacheCapacity = cli.UintFlag{
 		Name:   "store.cache.size",
 		Usage:  "Number of recent chunks cached in memory (default 5000)",
 		EnvVar: SWARM_ENV_STORE_CACHE_CAPACITY,
 	}
+	SwarmStoreCacheRatio = cli.Float64Flag{
+		Name:   "store.cache.ratio",
+		Usage:  "Ratio of the memory of the system the --store.cache.size is periodically set to, 0 keeps --store.cache.size",
+		EnvVar: SWARM_ENV_STORE_CACHE_RATIO,
+	}
+	SwarmStoreForwardCache = cli.Uint64Flag{
+		Name:   "store.forward-cache.size",
+		Usage:  "Number of chunks retrieved for peers that are cached in a store separate from the synced chunks, 0 stores them with the synced chunks",
+		EnvVar: SWARM_ENV_STORE_FORWARD_CACHE,
+	}
+	SwarmStoreDiskHighWater = cli.Float64Flag{
+		Name:   "store.disk-highwater",
+		Usage:  "Ratio of the filesystem of the chunk DB used above which new chunks are rejected, 0 disables the check (default 0.95)",
+		EnvVar: SWARM_ENV_STORE_DISK_HIGHWATER,
+	}
+	SwarmStoreResyncLost = cli.BoolFlag{
+		Name:   "store.resync-lost",
+		Usage:  "Resync the chunks lost in the recovery of a corrupted chunk DB from the neighbourhood",
+		EnvVar: SWARM_ENV_STORE_RESYNC_LOST,
+	}
+	SwarmStoreCompress = cli.BoolFlag{
+		Name:   "store.compress",
+		Usage:  "Store the chunk data compressed in the chunk DB unless it is incompressible",
+		EnvVar: SWARM_ENV_STORE_COMPRESS,
+	}
+	SwarmStoreEncrypt = cli.BoolFlag{
+		Name:   "store.encrypt",
+		Usage:  "Encrypt the chunk data in the chunk DB with a key derived from the node key",
+		EnvVar: SWARM_ENV_STORE_ENCRYPT,
+	}
+	SwarmStoreKeyCommand = cli.StringFlag{
+		Name:   "store.key-cmd",
+		Usage:  "Command printing the hex encoded key the chunk data in the chunk DB is encrypted with, implies --store.encrypt",
+		EnvVar: SWARM_ENV_STORE_KEY_CMD,
+	}
+	SwarmStoreShards = cli.StringSliceFlag{
+		Name:   "store.shard",
+		Usage:  "Directory of an additional chunk DB the chunk data is sharded to by address, can be repeated, the data is moved when directories are added or removed",
+		EnvVar: SWARM_ENV_STORE_SHARDS,
+	}
 )
 
 //declare a few constant error messages, useful for later error check comparisons in test
@@ -222,9 +674,73 @@ func init() {
 			Name:               "up",
 			Usage:              "uploads a file or directory to swarm using the HTTP API",
 			ArgsUsage:          "<file>",
-			Flags:              []cli.Flag{SwarmEncryptedFlag},
+			Flags:              []cli.Flag{SwarmEncryptedFlag, SwarmUploadMediaFlag, SwarmUploadHashFlag, SwarmUploadSparseFlag, SwarmPreserveFlag, SwarmUploadErrorDocumentFlag, SwarmUploadSPAFlag},
 			Description:        "uploads a file or directory to swarm using the HTTP API and prints the root hash",
 		},
+		{
+			Action:             syncDir,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "sync",
+			Usage:              "incrementally syncs a directory to a swarm manifest",
+			ArgsUsage:          "<dir> <manifest>",
+			Flags:              []cli.Flag{SwarmSyncResourceFlag},
+			Description: `
+Syncs a local directory to a previously published swarm manifest, uploading only
+new or changed files and removing files which no longer exist locally. Prints
+the hash of the resulting manifest.
+
+    swarm sync ./site <manifest hash>
+
+With --resource, the manifest is the one the latest multihash update of the
+given mutable resource points to, and the resource is updated to point to the
+new manifest once it has been fully uploaded:
+
+    swarm sync --resource ./site <resource manifest hash or ENS name>
+`,
+		},
+		{
+			Action:             deploy,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "deploy",
+			Usage:              "deploys a static site to a mutable resource",
+			ArgsUsage:          "<dir> <resource>",
+			Flags:              []cli.Flag{SwarmDeployVerifyFlag, SwarmDeployKeepFlag, SwarmDeployHistoryFlag, SwarmDeployRollbackFlag},
+			Description: `
+Uploads a directory, verifies that all of its content can be retrieved from
+the --verify-gateway and then updates the mutable resource to point to the new
+version. The resource must have been created with a multihash update.
+
+    swarm deploy --verify-gateway https://swarm-gateways.net ./site <resource>
+
+Previously deployed versions are recorded in a local history file, so the
+resource can be switched back to the previous version without uploading:
+
+    swarm deploy --rollback <resource>
+`,
+		},
+		{
+			Action:             proxy,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "proxy",
+			Usage:              "serve swarm URLs to browsers as a local HTTP proxy",
+			ArgsUsage:          "",
+			Flags:              []cli.Flag{SwarmProxyAddrFlag, SwarmProxyTLDFlag},
+			Description: `
+Run a local HTTP proxy which translates swarm URLs to requests to the bzz:/
+scheme of the --bzzapi gateway, so browsers can navigate to them:
+
+    http://<name>.eth/<path>       for the ENS names of the --proxy-tld domains
+    http://bzz/<manifest>/<path>   for manifest hashes and names
+    bzz://<manifest>/<path>        sent by clients handling the bzz scheme
+
+Requests to other URLs are refused. Configure the browser with the proxy
+auto-config file served by the proxy, which only sends the swarm URLs through
+the proxy:
+
+    swarm proxy --proxyaddr 127.0.0.1:8501
+    # proxy auto-config URL: http://127.0.0.1:8501/proxy.pac
+`,
+		},
 		{
 			Action:             list,
 			CustomHelpTemplate: helpTemplate,
@@ -241,14 +757,30 @@ func init() {
 			ArgsUsage:          "<file>",
 			Description:        "Prints the swarm hash of file or directory",
 		},
+		{
+			Action:             verify,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "verify",
+			Usage:              "verify that the files of a directory match a manifest",
+			ArgsUsage:          "<dir> <manifest>",
+			Description: `
+Hashes the files of the directory offline the way 'swarm --recursive up' uploads them and compares the hashes with the entries of the manifest. The paths whose content differs from the manifest, the files missing from the manifest and the entries missing from the directory are listed and the command fails if there are any.
+
+Encrypted and composite entries are listed as unverified, their hashes cannot be computed offline.
+`,
+		},
 		{
 			Action:    download,
 			Name:      "down",
-			Flags:     []cli.Flag{SwarmRecursiveFlag},
+			Flags:     []cli.Flag{SwarmRecursiveFlag, SwarmCheckFlag, SwarmPreserveFlag},
 			Usage:     "downloads a swarm manifest or a file inside a manifest",
 			ArgsUsage: " <uri> [<dir>]",
 			Description: `
 Downloads a swarm bzz uri to the given dir. When no dir is provided, working directory is assumed. --recursive flag is expected when downloading a manifest with multiple entries.
+
+With --check nothing is downloaded, the chunks of the content which are missing from the local store of the node are listed instead and the command fails if there are any.
+
+With --preserve the downloaded files get the mode and the modification time they were uploaded with, and the symbolic links uploaded with 'swarm --recursive up --preserve' are restored as links instead of files containing their targets.
 `,
 		},
 
@@ -257,7 +789,7 @@ Downloads a swarm bzz uri to the given dir. When no dir is provided, working dir
 			CustomHelpTemplate: helpTemplate,
 			Usage:              "perform operations on swarm manifests",
 			ArgsUsage:          "COMMAND",
-			Description:        "Updates a MANIFEST by adding/removing/updating the hash of a path.\nCOMMAND could be: add, update, remove",
+			Description:        "Updates a MANIFEST by adding/removing/updating the hash of a path.\nCOMMAND could be: add, update, remove, convert",
 			Subcommands: []cli.Command{
 				{
 					Action:             add,
@@ -283,6 +815,14 @@ Downloads a swarm bzz uri to the given dir. When no dir is provided, working dir
 					ArgsUsage:          "<MANIFEST> <path>",
 					Description:        "Removes a path from the manifest",
 				},
+				{
+					Action:             convert,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "convert",
+					Usage:              "converts the manifest and its submanifests to another encoding",
+					ArgsUsage:          "<MANIFEST>",
+					Description:        "Converts the manifest and its submanifests to the encoding version given by --manifest-version (default 2, binary) and prints the hash of the converted manifest",
+				},
 			},
 		},
 		{
@@ -321,6 +861,267 @@ Downloads a swarm bzz uri to the given dir. When no dir is provided, working dir
 				},
 			},
 		},
+		{
+			Name:               "feed",
+			CustomHelpTemplate: helpTemplate,
+			Usage:              "create, update and look up mutable resources",
+			ArgsUsage:          "feed COMMAND",
+			Description:        "Create, update and look up mutable resources (feeds) through the --bzzapi gateway",
+			Subcommands: []cli.Command{
+				{
+					Action:             feedCreate,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "create",
+					Usage:              "create a mutable resource",
+					ArgsUsage:          "<name> [<data>]",
+					Flags:              []cli.Flag{SwarmFeedFrequencyFlag, SwarmFeedMultihashFlag},
+					Description: `
+Create a mutable resource for an ENS name owned by the key of the node, with an
+update period of --frequency blocks, and print the hash of its manifest.
+
+    swarm feed create --frequency 60 mysite.eth "first update"
+
+The data of the first update is optional, use - to read it from stdin. With
+--multihash the updates point to the hex encoded swarm hash given as data.
+`,
+				},
+				{
+					Action:             feedUpdate,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "update",
+					Usage:              "update a mutable resource",
+					ArgsUsage:          "<resource> [<data>]",
+					Flags:              []cli.Flag{SwarmFeedMultihashFlag, SwarmFeedKeyFileFlag},
+					Description: `
+Publish an update of a mutable resource, given by the hash of its manifest or
+its ENS name. The data is read from stdin if it is not given or -.
+
+    date | swarm feed update mysite.eth
+
+Updates are signed by the node with the key from its keystore. With --keyfile
+the update is signed locally with the private key of the resource owner stored
+in the file, so the key of the node does not need to own the ENS name:
+
+    swarm feed update --keyfile owner.key mysite.eth "new data"
+
+With the global --json flag the resource after the update is printed as in
+swarm feed info.
+`,
+				},
+				{
+					Action:             feedLookup,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "lookup",
+					Usage:              "print the data of an update of a mutable resource",
+					ArgsUsage:          "<resource>",
+					Flags:              []cli.Flag{SwarmFeedPeriodFlag, SwarmFeedVersionFlag, SwarmFeedHintFlag},
+					Description: `
+Print the data of the latest update of a mutable resource, or of the given
+--period and --version.
+
+    swarm feed lookup mysite.eth
+
+The lookup of the latest update walks back through the periods until an update
+is found. Scripts polling a resource can pass the period of the last update
+they have seen with --hint, so the lookup never searches further back.
+`,
+				},
+				{
+					Action:             feedInfo,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "info",
+					Usage:              "describe a mutable resource and its latest update",
+					ArgsUsage:          "<resource>",
+					Description: `
+Print the metadata of a mutable resource, its latest update and the period,
+version and address of its next update.
+
+    swarm --json feed info mysite.eth
+`,
+				},
+			},
+		},
+		{
+			Name:               "names",
+			CustomHelpTemplate: helpTemplate,
+			Usage:              "manage the name registry",
+			ArgsUsage:          "names COMMAND",
+			Description:        "Create name registries and register names in the name registry of a running node through its IPC endpoint",
+			Subcommands: []cli.Command{
+				{
+					Action:             namesCreate,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "create",
+					Usage:              "create a name registry",
+					ArgsUsage:          "<name> <owner>...",
+					Flags:              []cli.Flag{utils.IPCPathFlag, SwarmFeedFrequencyFlag, SwarmNamesMergeFlag},
+					Description: `
+Create a name registry, a mutable resource mapping names to hashes which can be
+resolved without ENS, owned by the given addresses, and print its address. The
+key of the node has to be one of the owners.
+
+    swarm names create --ipcpath bzzd.ipc --frequency 60 names.local 0x8d2a... 0x41f0...
+
+Start the nodes with --name-registry <address> to resolve the names in bzz URLs
+ahead of ENS. A name is held by the owner which registered it, the other owners
+cannot change or remove it. With --merge sequence, the default, every update of
+the registry follows the current one, and the update with the highest sequence
+number is current.
+`,
+				},
+				{
+					Action:             namesRegister,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "register",
+					Usage:              "register a name",
+					ArgsUsage:          "<name> <hash>",
+					Flags:              []cli.Flag{utils.IPCPathFlag},
+					Description: `
+Register the name with the hash in the name registry of the node, or change the
+hash of a name registered by the node.
+
+    swarm names register --ipcpath bzzd.ipc mysite.local 2477cc8584cc61091b5cc084cdcdb45bf3c6210c263b0143f030cf7d750e894d
+`,
+				},
+				{
+					Action:             namesRemove,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "remove",
+					Usage:              "remove a name",
+					ArgsUsage:          "<name>",
+					Flags:              []cli.Flag{utils.IPCPathFlag},
+					Description:        "Remove a name registered by the node from the name registry of the node",
+				},
+				{
+					Action:             namesResolve,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "resolve",
+					Usage:              "print the hash of a name",
+					ArgsUsage:          "<name>",
+					Flags:              []cli.Flag{utils.IPCPathFlag},
+					Description:        "Print the hash a name is registered with in the name registry of the node",
+				},
+				{
+					Action:             namesList,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "list",
+					Usage:              "list the registered names",
+					Flags:              []cli.Flag{utils.IPCPathFlag},
+					Description:        "List the names in the name registry of the node with their hashes and owners",
+				},
+			},
+		},
+		{
+			Name:               "access",
+			CustomHelpTemplate: helpTemplate,
+			Usage:              "grant access to content with access manifests",
+			ArgsUsage:          "access COMMAND",
+			Description: `
+Publish access manifests granting access to the content of a manifest. The
+address of the content manifest is encrypted in the access manifest, which is
+served by the bzz:/ scheme of the HTTP API to the requests it grants access to.
+Upload the content with --encrypt, so the content itself cannot be read by
+anybody knowing its address.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:               "new",
+					CustomHelpTemplate: helpTemplate,
+					Usage:              "publish a new access manifest",
+					ArgsUsage:          "new COMMAND",
+					Description:        "Publish a new access manifest granting access with a password, a public key or an access control trie",
+					Subcommands: []cli.Command{
+						{
+							Action:             accessNewPass,
+							CustomHelpTemplate: helpTemplate,
+							Name:               "pass",
+							Usage:              "grant access with a password",
+							ArgsUsage:          "<manifest hash>",
+							Flags:              []cli.Flag{SwarmAccessPasswordFlag, SwarmDryRunFlag},
+							Description: `
+Publish an access manifest granting access to the manifest with a password,
+and print its address.
+
+    swarm access new pass --access-password password.txt <manifest hash>
+
+The HTTP API asks for the password with basic authentication, the user name
+is ignored.
+`,
+						},
+						{
+							Action:             accessNewPK,
+							CustomHelpTemplate: helpTemplate,
+							Name:               "pk",
+							Usage:              "grant access to a public key",
+							ArgsUsage:          "<manifest hash>",
+							Flags:              []cli.Flag{SwarmAccessGrantKeyFlag, SwarmDryRunFlag},
+							Description: `
+Publish an access manifest granting access to the manifest to the node with
+the given public key, and print its address. The access key is derived from
+the keys of the grantee and of the publisher, the swarm account given with
+--bzzaccount.
+
+    swarm access new pk --bzzaccount <address> --grant-key <public key> <manifest hash>
+`,
+						},
+						{
+							Action:             accessNewACT,
+							CustomHelpTemplate: helpTemplate,
+							Name:               "act",
+							Usage:              "grant access to a list of public keys",
+							ArgsUsage:          "<manifest hash>",
+							Flags:              []cli.Flag{SwarmAccessGrantKeysFlag, SwarmDryRunFlag},
+							Description: `
+Publish an access control trie (ACT) granting the nodes with the public keys
+in the --grant-keys file access, and an access manifest granting access to the
+manifest to the grantees of the trie, and print its address.
+
+    swarm access new act --bzzaccount <address> --grant-keys keys.txt <manifest hash>
+
+Only the publisher, the swarm account given with --bzzaccount, can change the
+grantees of the trie later.
+`,
+						},
+					},
+				},
+				{
+					Action:             accessGrant,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "grant",
+					Usage:              "grant public keys access to an ACT access manifest",
+					ArgsUsage:          "<access manifest>",
+					Flags:              []cli.Flag{SwarmAccessGrantKeysFlag, SwarmDryRunFlag},
+					Description: `
+Publish a new ACT access manifest granting the public keys in the --grant-keys
+file access in addition to the grantees of the given one, and print its
+address. Only the publisher of the access manifest can grant access.
+
+    swarm access grant --bzzaccount <address> --grant-keys keys.txt <access manifest>
+`,
+				},
+				{
+					Action:             accessRevoke,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "revoke",
+					Usage:              "revoke the access of public keys to an ACT access manifest",
+					ArgsUsage:          "<access manifest>",
+					Flags:              []cli.Flag{SwarmAccessGrantKeysFlag, SwarmDryRunFlag},
+					Description: `
+Publish a new ACT access manifest with a new access key, granting access to
+the grantees of the given one except the public keys in the --grant-keys
+file, and print its address. Only the publisher of the access manifest can
+revoke access.
+
+    swarm access revoke --bzzaccount <address> --grant-keys keys.txt <access manifest>
+
+The previous access manifest still grants the revoked keys access, publish the
+new one instead, e.g. by updating the ENS name or mutable resource pointing to
+it. Revoked grantees which have seen the address of the content can still read
+it, publish the content again to hide new versions from them.
+`,
+				},
+			},
+		},
 		{
 			Name:               "db",
 			CustomHelpTemplate: helpTemplate,
@@ -334,6 +1135,9 @@ Downloads a swarm bzz uri to the given dir. When no dir is provided, working dir
 					Name:               "export",
 					Usage:              "export a local chunk database as a tar archive (use - to send to stdout)",
 					ArgsUsage:          "<chunkdb> <file>",
+					Flags: []cli.Flag{
+						SwarmExportSinceFlag,
+					},
 					Description: `
 Export a local chunk database as a tar archive (use - to send to stdout).
 
@@ -343,6 +1147,15 @@ The export may be quite large, consider piping the output through the Unix
 pv(1) tool to get a progress bar:
 
     swarm db export ~/.ethereum/swarm/bzz-KEY/chunks - | pv > chunks.tar
+
+The archive is compressed with gzip if the file name ends with .gz. With
+--since only the chunks stored at or after the given Unix time are exported,
+so scheduled backups can be incremental:
+
+    swarm db export --since 1530000000 ~/.ethereum/swarm/bzz-KEY/chunks - KEY | gzip > chunks.tar.gz
+
+Chunks stored by swarm versions without store times are only exported without
+--since.
 `,
 				},
 				{
@@ -351,6 +1164,9 @@ pv(1) tool to get a progress bar:
 					Name:               "import",
 					Usage:              "import chunks from a tar archive into a local chunk database (use - to read from stdin)",
 					ArgsUsage:          "<chunkdb> <file>",
+					Flags: []cli.Flag{
+						SwarmImportResumeFlag,
+					},
 					Description: `
 Import chunks from a tar archive into a local chunk database (use - to read from stdin).
 
@@ -360,6 +1176,11 @@ The import may be quite large, consider piping the input through the Unix
 pv(1) tool to get a progress bar:
 
     pv chunks.tar | swarm db import ~/.ethereum/swarm/bzz-KEY/chunks -
+
+Archives compressed with gzip are decompressed. An interrupted import is
+continued with --resume, the chunks already in the database are skipped:
+
+    swarm db import --resume ~/.ethereum/swarm/bzz-KEY/chunks chunks.tar.gz KEY
 `,
 				},
 				{
@@ -370,6 +1191,124 @@ pv(1) tool to get a progress bar:
 					ArgsUsage:          "<chunkdb>",
 					Description:        "Remove corrupt entries from a local chunk database",
 				},
+				{
+					Action:             dbInspect,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "inspect",
+					Usage:              "print the metadata and references of a chunk in a local chunk database",
+					ArgsUsage:          "<chunkdb> <key>",
+					Description: `
+Print the metadata of a chunk in a local chunk database together with the
+chunks it references and the chunks referencing it.
+
+    swarm db inspect ~/.ethereum/swarm/bzz-KEY/chunks CHUNK KEY
+
+The database is opened read-only, so a copy of the database of a running node
+can be inspected without changing it. Looking up the referencing chunks scans
+the whole database.
+`,
+				},
+				{
+					Action:             dbMigrate,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "migrate",
+					Usage:              "migrate a local chunk database from the legacy layout",
+					ArgsUsage:          "<chunkdb> [<newchunkdb>]",
+					Flags: []cli.Flag{
+						SwarmDryRunFlag,
+					},
+					Description: `
+Re-index the chunks of a local chunk database created by an older swarm
+version into the current layout.
+
+    swarm db migrate ~/.ethereum/swarm/bzz-KEY/chunks KEY
+
+The chunks are migrated in place unless a new database directory is given,
+in which case the legacy database is not changed:
+
+    swarm db migrate ~/.ethereum/swarm/bzz-KEY/chunks KEY ./chunks.new
+
+With --dry-run the legacy chunks are only counted. The node using the
+database must not be running.
+`,
+				},
+				{
+					Action:             dbVerify,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "verify",
+					Usage:              "recompute and repair the counters of a local chunk database",
+					ArgsUsage:          "<chunkdb>",
+					Description: `
+Recompute the chunk count, access counter and storage indexes of a local chunk
+database from its entries and repair the persisted ones if they differ.
+
+    swarm db verify ~/.ethereum/swarm/bzz-KEY/chunks KEY
+
+Data entries left without an index are removed. The node using the database
+must not be running.
+`,
+				},
+			},
+		},
+
+		{
+			Name:               "genconfig",
+			CustomHelpTemplate: helpTemplate,
+			Usage:              "generate swarm node configurations",
+			ArgsUsage:          "genconfig COMMAND",
+			Description:        "Generate swarm node configurations",
+			Subcommands: []cli.Command{
+				{
+					Action:             genConfigPrivate,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "private",
+					Usage:              "generate matching configs for the nodes of a private swarm",
+					ArgsUsage:          "<dir>",
+					Flags: []cli.Flag{
+						SwarmNetworkIdFlag,
+						SwarmGenConfigNodesFlag,
+						SwarmGenConfigBootnodesFlag,
+						SwarmGenConfigHostFlag,
+						SwarmGenConfigP2PPortFlag,
+						SwarmGenConfigBzzPortFlag,
+					},
+					Description: `
+Generate the keys and configs for a fleet of nodes forming a private swarm.
+
+    swarm genconfig private --nodes 5 --bzznetworkid 4242 ./fleet
+
+Every node gets a data directory ./fleet/nodeNN holding its node key, swarm
+account key and a config.toml with the shared network id and the first
+--bootnode-count nodes as bootnodes. The bootnodes are also written to
+./fleet/bootnodes.txt for use with --bootnodes-file. If no network id is
+given a random one is picked.
+`,
+				},
+			},
+		},
+
+		{
+			Name:               "pcap",
+			CustomHelpTemplate: helpTemplate,
+			Usage:              "inspect protocol message captures",
+			ArgsUsage:          "pcap COMMAND",
+			Description:        "Inspect protocol message captures recorded with --pcap",
+			Subcommands: []cli.Command{
+				{
+					Action:             pcapDecode,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "decode",
+					Usage:              "print the messages of capture files",
+					ArgsUsage:          "<file>...",
+					Description: `
+Print the protocol messages recorded to capture files, one per line with
+the time, direction, protocol, peer, message type, size and payload.
+
+    swarm pcap decode bzz.pcap.1 bzz.pcap
+
+Pass rotated capture files oldest first to print the messages in order.
+`,
+				},
 			},
 		},
 
@@ -382,6 +1321,7 @@ pv(1) tool to get a progress bar:
 		utils.IdentityFlag,
 		utils.DataDirFlag,
 		utils.BootnodesFlag,
+		SwarmBootnodesFileFlag,
 		utils.KeyStoreDirFlag,
 		utils.ListenPortFlag,
 		utils.NoDiscoverFlag,
@@ -396,29 +1336,93 @@ pv(1) tool to get a progress bar:
 		utils.PasswordFileFlag,
 		// bzzd-specific flags
 		CorsStringFlag,
+		SwarmAccessLogFlag,
+		SwarmAnonymizeIPsFlag,
+		SwarmTLSCertFlag,
+		SwarmTLSKeyFlag,
+		SwarmHTTPSocketFlag,
+		SwarmGatewayReadOnlyFlag,
+		SwarmGatewayAllowFlag,
+		SwarmGatewayDenyFlag,
+		SwarmGatewayDenyListFlag,
+		SwarmGatewayDenySignerFlag,
+		SwarmTakedownTokenFlag,
+		SwarmMimeTypesFlag,
+		SwarmMimeNoSniffFlag,
+		SwarmMimeDefaultFlag,
+		SwarmGatewayDomainFlag,
+		SwarmTLSWildcardCertFlag,
+		SwarmTLSWildcardKeyFlag,
+		SwarmHTTPSignedWritesFlag,
 		EnsAPIFlag,
+		SwarmNameRegistryFlag,
+		SwarmFallbackGatewayFlag,
+		SwarmFallbackTimeoutFlag,
+		SwarmPssDisabledFlag,
+		SwarmPssRelayTTLFlag,
+		SwarmPssPoWFlag,
+		SwarmPssTopicRateFlag,
+		SwarmPssTopicBurstFlag,
 		SwarmTomlConfigPathFlag,
 		SwarmSwapEnabledFlag,
 		SwarmSwapAPIFlag,
 		SwarmSyncDisabledFlag,
 		SwarmSyncUpdateDelay,
+		SwarmSyncBatchSizeFlag,
+		SwarmSyncOfferWindowFlag,
+		SwarmSyncBinConcurrencyFlag,
+		SwarmRetrieveFanoutFlag,
+		SwarmLightNodeFlag,
+		SwarmRequiredCapabilitiesFlag,
+		SwarmBinMinPeersFlag,
+		SwarmBinMaxPeersFlag,
+		SwarmMaxBzzPeersFlag,
+		SwarmInlineThresholdFlag,
+		SwarmManifestVersionFlag,
+		SwarmManifestFanoutFlag,
+		SwarmPrefetchConcurrencyFlag,
+		SwarmShutdownGraceFlag,
 		SwarmDeliverySkipCheckFlag,
+		SwarmDeliveryReceiptsFlag,
+		SwarmDeliveryNoCompressionFlag,
 		SwarmListenAddrFlag,
 		SwarmPortFlag,
+		SwarmS3PortFlag,
+		SwarmResticPortFlag,
 		SwarmAccountFlag,
 		SwarmNetworkIdFlag,
+		SwarmPrivateFlag,
 		ChequebookAddrFlag,
 		// upload flags
 		SwarmApiFlag,
+		SwarmSignKeyFlag,
+		SwarmSignSecretFlag,
+		SwarmSignKeyFileFlag,
 		SwarmRecursiveFlag,
 		SwarmWantManifestFlag,
 		SwarmUploadDefaultPath,
 		SwarmUpFromStdinFlag,
 		SwarmUploadMimeType,
+		// output flags
+		SwarmJSONFlag,
 		// storage flags
 		SwarmStorePath,
 		SwarmStoreCapacity,
+		SwarmStoreByteCapacity,
+		SwarmStoreByteCapacityRatio,
+		SwarmStoreLowWater,
 		SwarmStoreCacheCapacity,
+		SwarmStoreCacheRatio,
+		SwarmStoreForwardCache,
+		SwarmStoreDiskHighWater,
+		SwarmStoreResyncLost,
+		SwarmStoreCompress,
+		SwarmStoreEncrypt,
+		SwarmStoreKeyCommand,
+		SwarmStoreShards,
+		// debug flags
+		SwarmPcapFlag,
+		SwarmPcapPayloadFlag,
 	}
 	rpcFlags := []cli.Flag{
 		utils.WSEnabledFlag,
@@ -436,6 +1440,7 @@ pv(1) tool to get a progress bar:
 			return err
 		}
 		swarmmetrics.Setup(ctx)
+		jsonOutput = ctx.GlobalBool(SwarmJSONFlag.Name)
 		return nil
 	}
 	app.After = func(ctx *cli.Context) error {
@@ -482,6 +1487,8 @@ func bzzd(ctx *cli.Context) error {
 	}
 	//setup the ethereum node
 	utils.SetNodeConfig(ctx, &cfg)
+	//keep private swarms out of public discovery
+	scopeDiscovery(bzzconfig, &cfg)
 	stack, err := node.New(&cfg)
 	if err != nil {
 		utils.Fatalf("can't create node: %v", err)
@@ -491,6 +1498,9 @@ func bzzd(ctx *cli.Context) error {
 	initSwarmNode(bzzconfig, stack, ctx)
 	//register BZZ as node.Service in the ethereum node
 	registerBzzService(bzzconfig, stack)
+	//record protocol messages if requested
+	stopCapture := startCapture(ctx)
+	defer stopCapture()
 	//start the node
 	utils.StartNode(stack)
 
@@ -20,13 +20,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"mime"
-	"path/filepath"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/swarm/api"
-	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -35,7 +31,7 @@ const bzzManifestJSON = "application/bzz-manifest+json"
 func add(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) < 3 {
-		utils.Fatalf("Need at least three arguments <MHASH> <path> <HASH> [<content-type>]")
+		fatalf("Need at least three arguments <MHASH> <path> <HASH> [<content-type>]")
 	}
 
 	var (
@@ -51,13 +47,13 @@ func add(ctx *cli.Context) {
 	if len(args) > 3 {
 		ctype = args[3]
 	} else {
-		ctype = mime.TypeByExtension(filepath.Ext(path))
+		ctype = api.NewMimeDetector().ByName(path)
 	}
 
 	newManifest := addEntryToManifest(ctx, mhash, path, hash, ctype)
-	fmt.Println(newManifest)
+	printResult(map[string]string{"hash": newManifest}, newManifest)
 
-	if !wantManifest {
+	if !wantManifest && !jsonOutput {
 		// Print the manifest. This is the only output to stdout.
 		mrootJSON, _ := json.MarshalIndent(mroot, "", "  ")
 		fmt.Println(string(mrootJSON))
@@ -69,7 +65,7 @@ func update(ctx *cli.Context) {
 
 	args := ctx.Args()
 	if len(args) < 3 {
-		utils.Fatalf("Need at least three arguments <MHASH> <path> <HASH>")
+		fatalf("Need at least three arguments <MHASH> <path> <HASH>")
 	}
 
 	var (
@@ -84,13 +80,13 @@ func update(ctx *cli.Context) {
 	if len(args) > 3 {
 		ctype = args[3]
 	} else {
-		ctype = mime.TypeByExtension(filepath.Ext(path))
+		ctype = api.NewMimeDetector().ByName(path)
 	}
 
 	newManifest := updateEntryInManifest(ctx, mhash, path, hash, ctype)
-	fmt.Println(newManifest)
+	printResult(map[string]string{"hash": newManifest}, newManifest)
 
-	if !wantManifest {
+	if !wantManifest && !jsonOutput {
 		// Print the manifest. This is the only output to stdout.
 		mrootJSON, _ := json.MarshalIndent(mroot, "", "  ")
 		fmt.Println(string(mrootJSON))
@@ -101,7 +97,7 @@ func update(ctx *cli.Context) {
 func remove(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) < 2 {
-		utils.Fatalf("Need at least two arguments <MHASH> <path>")
+		fatalf("Need at least two arguments <MHASH> <path>")
 	}
 
 	var (
@@ -113,9 +109,9 @@ func remove(ctx *cli.Context) {
 	)
 
 	newManifest := removeEntryFromManifest(ctx, mhash, path)
-	fmt.Println(newManifest)
+	printResult(map[string]string{"hash": newManifest}, newManifest)
 
-	if !wantManifest {
+	if !wantManifest && !jsonOutput {
 		// Print the manifest. This is the only output to stdout.
 		mrootJSON, _ := json.MarshalIndent(mroot, "", "  ")
 		fmt.Println(string(mrootJSON))
@@ -123,29 +119,52 @@ func remove(ctx *cli.Context) {
 	}
 }
 
+func convert(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fatalf("Need at least one argument <MHASH>")
+	}
+
+	var (
+		mhash   = args[0]
+		bzzapi  = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+		client  = writeClient(ctx, bzzapi)
+		version = api.ManifestVersion2
+	)
+	if ctx.GlobalIsSet(SwarmManifestVersionFlag.Name) {
+		version = ctx.GlobalInt(SwarmManifestVersionFlag.Name)
+	}
+
+	newManifest, err := client.ConvertManifest(mhash, version)
+	if err != nil {
+		fatalf("Error converting manifest: %v", err)
+	}
+	printResult(map[string]string{"hash": newManifest}, newManifest)
+}
+
 func addEntryToManifest(ctx *cli.Context, mhash, path, hash, ctype string) string {
 
 	var (
 		bzzapi           = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
-		client           = swarm.NewClient(bzzapi)
+		client           = writeClient(ctx, bzzapi)
 		longestPathEntry = api.ManifestEntry{}
 	)
 
 	mroot, isEncrypted, err := client.DownloadManifest(mhash)
 	if err != nil {
-		utils.Fatalf("Manifest download failed: %v", err)
+		fatalf("Manifest download failed: %v", err)
 	}
 
 	//TODO: check if the "hash" to add is valid and present in swarm
 	_, _, err = client.DownloadManifest(hash)
 	if err != nil {
-		utils.Fatalf("Hash to add is not present: %v", err)
+		fatalf("Hash to add is not present: %v", err)
 	}
 
 	// See if we path is in this Manifest or do we have to dig deeper
 	for _, entry := range mroot.Entries {
 		if path == entry.Path {
-			utils.Fatalf("Path %s already present, not adding anything", path)
+			fatalf("Path %s already present, not adding anything", path)
 		} else {
 			if entry.ContentType == bzzManifestJSON {
 				prfxlen := strings.HasPrefix(path, entry.Path)
@@ -182,7 +201,7 @@ func addEntryToManifest(ctx *cli.Context, mhash, path, hash, ctype string) strin
 
 	newManifestHash, err := client.UploadManifest(mroot, isEncrypted)
 	if err != nil {
-		utils.Fatalf("Manifest upload failed: %v", err)
+		fatalf("Manifest upload failed: %v", err)
 	}
 	return newManifestHash
 
@@ -192,14 +211,14 @@ func updateEntryInManifest(ctx *cli.Context, mhash, path, hash, ctype string) st
 
 	var (
 		bzzapi           = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
-		client           = swarm.NewClient(bzzapi)
+		client           = writeClient(ctx, bzzapi)
 		newEntry         = api.ManifestEntry{}
 		longestPathEntry = api.ManifestEntry{}
 	)
 
 	mroot, isEncrypted, err := client.DownloadManifest(mhash)
 	if err != nil {
-		utils.Fatalf("Manifest download failed: %v", err)
+		fatalf("Manifest download failed: %v", err)
 	}
 
 	//TODO: check if the "hash" with which to update is valid and present in swarm
@@ -219,7 +238,7 @@ func updateEntryInManifest(ctx *cli.Context, mhash, path, hash, ctype string) st
 	}
 
 	if longestPathEntry.Path == "" && newEntry.Path == "" {
-		utils.Fatalf("Path %s not present in the Manifest, not setting anything", path)
+		fatalf("Path %s not present in the Manifest, not setting anything", path)
 	}
 
 	if longestPathEntry.Path != "" {
@@ -259,7 +278,7 @@ func updateEntryInManifest(ctx *cli.Context, mhash, path, hash, ctype string) st
 
 	newManifestHash, err := client.UploadManifest(mroot, isEncrypted)
 	if err != nil {
-		utils.Fatalf("Manifest upload failed: %v", err)
+		fatalf("Manifest upload failed: %v", err)
 	}
 	return newManifestHash
 }
@@ -268,14 +287,14 @@ func removeEntryFromManifest(ctx *cli.Context, mhash, path string) string {
 
 	var (
 		bzzapi           = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
-		client           = swarm.NewClient(bzzapi)
+		client           = writeClient(ctx, bzzapi)
 		entryToRemove    = api.ManifestEntry{}
 		longestPathEntry = api.ManifestEntry{}
 	)
 
 	mroot, isEncrypted, err := client.DownloadManifest(mhash)
 	if err != nil {
-		utils.Fatalf("Manifest download failed: %v", err)
+		fatalf("Manifest download failed: %v", err)
 	}
 
 	// See if we path is in this Manifest or do we have to dig deeper
@@ -293,7 +312,7 @@ func removeEntryFromManifest(ctx *cli.Context, mhash, path string) string {
 	}
 
 	if longestPathEntry.Path == "" && entryToRemove.Path == "" {
-		utils.Fatalf("Path %s not present in the Manifest, not removing anything", path)
+		fatalf("Path %s not present in the Manifest, not removing anything", path)
 	}
 
 	if longestPathEntry.Path != "" {
@@ -325,7 +344,7 @@ func removeEntryFromManifest(ctx *cli.Context, mhash, path string) string {
 
 	newManifestHash, err := client.UploadManifest(mroot, isEncrypted)
 	if err != nil {
-		utils.Fatalf("Manifest upload failed: %v", err)
+		fatalf("Manifest upload failed: %v", err)
 	}
 	return newManifestHash
 }
@@ -0,0 +1,112 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command names manages the name registry of a node.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// namesTimeout is the timeout of the name registry RPC calls, which look up
+// the name table in the network
+const namesTimeout = 60 * time.Second
+
+func namesCreate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fatalf("Usage: swarm names create --ipcpath <path to bzzd.ipc> <name> <owner>...")
+	}
+	frequency := ctx.Uint64(SwarmFeedFrequencyFlag.Name)
+	if frequency == 0 {
+		fatalf("--frequency is required")
+	}
+	var owners []common.Address
+	for _, owner := range args[1:] {
+		if !common.IsHexAddress(owner) {
+			fatalf("Invalid owner address %q", owner)
+		}
+		owners = append(owners, common.HexToAddress(owner))
+	}
+	var addr string
+	namesCall(ctx, &addr, "bzzadmin_createNameRegistry", args[0], frequency, owners, ctx.String(SwarmNamesMergeFlag.Name))
+	printResult(map[string]string{"registry": addr}, addr)
+}
+
+func namesRegister(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		fatalf("Usage: swarm names register --ipcpath <path to bzzd.ipc> <name> <hash>")
+	}
+	var record api.NameRecord
+	namesCall(ctx, &record, "bzzadmin_registerName", args[0], args[1])
+	printResult(&record, record.Hash)
+}
+
+func namesRemove(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		fatalf("Usage: swarm names remove --ipcpath <path to bzzd.ipc> <name>")
+	}
+	namesCall(ctx, nil, "bzzadmin_removeName", args[0])
+}
+
+func namesResolve(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		fatalf("Usage: swarm names resolve --ipcpath <path to bzzd.ipc> <name>")
+	}
+	var record api.NameRecord
+	namesCall(ctx, &record, "bzzadmin_resolveName", args[0])
+	printResult(&record, record.Hash)
+}
+
+func namesList(ctx *cli.Context) {
+	var records []*api.NameRecord
+	namesCall(ctx, &records, "bzzadmin_names")
+	if jsonOutput {
+		printJSON(records)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "NAME\tHASH\tOWNER\tUPDATED")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Hash, r.Owner.Hex(), r.Updated.Format(time.RFC3339))
+	}
+}
+
+// namesCall calls the name registry RPC method of the node
+func namesCall(ctx *cli.Context, result interface{}, method string, args ...interface{}) {
+	client, err := dialRPC(ctx)
+	if err != nil {
+		fatalf("Error dialing the RPC endpoint: %v", err)
+	}
+	defer client.Close()
+	callCtx, cancel := context.WithTimeout(context.Background(), namesTimeout)
+	defer cancel()
+	if err := client.CallContext(callCtx, result, method, args...); err != nil {
+		fatalf("Error calling %s: %v", method, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+)
+
+// jsonOutput is set by the global --json flag, the commands then print their
+// results and errors as JSON objects to stdout
+var jsonOutput bool
+
+// fatalf exits with the error like utils.Fatalf, with --json the error is
+// printed as {"error": "<message>"} to stdout instead
+func fatalf(format string, args ...interface{}) {
+	if !jsonOutput {
+		utils.Fatalf(format, args...)
+	}
+	printJSON(map[string]string{"error": fmt.Sprintf(format, args...)})
+	os.Exit(1)
+}
+
+// printResult prints the result as JSON with --json, and the text otherwise
+func printResult(v interface{}, text string) {
+	if jsonOutput {
+		printJSON(v)
+		return
+	}
+	fmt.Println(text)
+}
+
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		utils.Fatalf("Error encoding output: %s", err)
+	}
+	fmt.Println(string(out))
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command pcap records and decodes protocol message captures.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+	"github.com/ethereum/go-ethereum/swarm/capture"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// startCapture records the protocol messages to the capture file given
+// by --pcap, the returned function stops recording
+func startCapture(ctx *cli.Context) func() {
+	path := ctx.GlobalString(SwarmPcapFlag.Name)
+	if path == "" {
+		return func() {}
+	}
+	config := capture.NewConfig(expandPath(path))
+	if n := ctx.GlobalInt(SwarmPcapPayloadFlag.Name); n > 0 {
+		config.PayloadSize = n
+	}
+	w, err := capture.NewWriter(config)
+	if err != nil {
+		utils.Fatalf("Failed to create capture file: %v", err)
+	}
+	protocols.SetTracer(w)
+	log.Warn("Recording protocol messages", "file", config.Path)
+	return func() {
+		protocols.SetTracer(nil)
+		if err := w.Close(); err != nil {
+			log.Error("Failed to close capture file", "err", err)
+		}
+	}
+}
+
+func pcapDecode(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) == 0 {
+		utils.Fatalf("Usage: swarm pcap decode <file>...")
+	}
+	for _, path := range args {
+		if err := decodeCapture(path); err != nil {
+			utils.Fatalf("Failed to decode %s: %v", path, err)
+		}
+	}
+}
+
+func decodeCapture(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := capture.NewReader(f)
+	if err != nil {
+		return err
+	}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t := time.Unix(0, int64(rec.Time)).UTC()
+		fmt.Println(t.Format("2006-01-02T15:04:05.000000"), rec)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Private swarm helpers: bootnode list files and fleet config generation.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/network"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	// bootnodesFileName is the name of the bootnode list written by genconfig
+	bootnodesFileName = "bootnodes.txt"
+	// privateNetworkIDMin is the lowest network id picked at random for a
+	// private swarm, lower ids are left to public networks
+	privateNetworkIDMin = 1000
+)
+
+// loadBootnodes reads a static bootnode list, one enode URL per line,
+// blank lines and lines starting with # are ignored
+func loadBootnodes(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nodes []string
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := discover.ParseNode(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid bootnode: %v", path, lineno, err)
+		}
+		nodes = append(nodes, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// scopeDiscovery keeps the nodes configured as a private swarm out of the
+// public discovery network: devp2p discovery and the default bootstrap nodes
+// are disabled, peers are only found through the bootnodes and the hive of
+// nodes sharing the network id
+func scopeDiscovery(config *bzzapi.Config, cfg *node.Config) {
+	if !config.PrivateSwarm {
+		return
+	}
+	cfg.P2P.NoDiscovery = true
+	cfg.P2P.DiscoveryV5 = false
+	cfg.P2P.BootstrapNodes = nil
+	cfg.P2P.BootstrapNodesV5 = nil
+	log.Info("Private swarm, public discovery disabled", "networkid", config.NetworkID)
+}
+
+// privateFleet describes the nodes of a private swarm generated by genconfig
+type privateFleet struct {
+	Dir       string
+	NetworkID uint64
+	Nodes     int
+	Bootnodes int
+	Host      string
+	P2PPort   int
+	BzzPort   int
+}
+
+// privateNode is a node of a generated private swarm
+type privateNode struct {
+	Dir     string
+	Config  string
+	Enode   string
+	P2PPort int
+}
+
+// generate writes a data directory for every node of the fleet holding its
+// p2p node key, swarm account key and TOML config, the configs share the
+// network id and list the first nodes of the fleet as bootnodes
+func (f *privateFleet) generate() ([]*privateNode, error) {
+	if f.Nodes < 1 {
+		return nil, fmt.Errorf("number of nodes must be positive")
+	}
+	if f.Bootnodes < 1 || f.Bootnodes > f.Nodes {
+		return nil, fmt.Errorf("number of bootnodes must be between 1 and %d", f.Nodes)
+	}
+	ip := net.ParseIP(f.Host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid host IP %q", f.Host)
+	}
+	dir, err := filepath.Abs(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*privateNode, f.Nodes)
+	for i := range nodes {
+		n := &privateNode{
+			Dir:     filepath.Join(dir, fmt.Sprintf("node%02d", i)),
+			P2PPort: f.P2PPort + i,
+		}
+		n.Config = filepath.Join(n.Dir, "config.toml")
+		// the node key is where geth looks for it in the data directory
+		nodeKey, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		keyDir := filepath.Join(n.Dir, clientIdentifier)
+		if err := os.MkdirAll(keyDir, 0700); err != nil {
+			return nil, err
+		}
+		if err := crypto.SaveECDSA(filepath.Join(keyDir, "nodekey"), nodeKey); err != nil {
+			return nil, err
+		}
+		id := discover.PubkeyID(&nodeKey.PublicKey)
+		n.Enode = discover.NewNode(id, ip, uint16(n.P2PPort), uint16(n.P2PPort)).String()
+		nodes[i] = n
+	}
+
+	bootnodes := make([]string, f.Bootnodes)
+	for i := range bootnodes {
+		bootnodes[i] = nodes[i].Enode
+	}
+	list := fmt.Sprintf("# bootnodes of private swarm %d\n%s\n", f.NetworkID, strings.Join(bootnodes, "\n"))
+	if err := ioutil.WriteFile(filepath.Join(dir, bootnodesFileName), []byte(list), 0644); err != nil {
+		return nil, err
+	}
+
+	for i, n := range nodes {
+		bzzKey, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		keyFile := filepath.Join(n.Dir, "bzzkey")
+		if err := crypto.SaveECDSA(keyFile, bzzKey); err != nil {
+			return nil, err
+		}
+
+		var peers []string
+		for _, b := range bootnodes {
+			if b != n.Enode {
+				peers = append(peers, b)
+			}
+		}
+		config := bzzapi.NewConfig()
+		config.Path = n.Dir
+		config.BzzAccount = keyFile
+		config.NetworkID = f.NetworkID
+		config.PrivateSwarm = true
+		config.Port = strconv.Itoa(f.BzzPort + i)
+		config.BootNodes = strings.Join(peers, ",")
+		out, err := tomlSettings.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(n.Config, out, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+func genConfigPrivate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm genconfig private [options] <dir>")
+	}
+	networkID := uint64(ctx.Int(SwarmNetworkIdFlag.Name))
+	if networkID == 0 {
+		networkID = uint64(privateNetworkIDMin + rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(1<<31))
+	}
+	if networkID == network.DefaultNetworkID {
+		utils.Fatalf("Network id %d is the public swarm network id", networkID)
+	}
+	fleet := &privateFleet{
+		Dir:       args[0],
+		NetworkID: networkID,
+		Nodes:     ctx.Int(SwarmGenConfigNodesFlag.Name),
+		Bootnodes: ctx.Int(SwarmGenConfigBootnodesFlag.Name),
+		Host:      ctx.String(SwarmGenConfigHostFlag.Name),
+		P2PPort:   ctx.Int(SwarmGenConfigP2PPortFlag.Name),
+		BzzPort:   ctx.Int(SwarmGenConfigBzzPortFlag.Name),
+	}
+	nodes, err := fleet.generate()
+	if err != nil {
+		utils.Fatalf("Failed to generate private swarm configs: %v", err)
+	}
+	fmt.Printf("Generated configs for private swarm %d, start the nodes with:\n\n", networkID)
+	for _, n := range nodes {
+		fmt.Printf("    swarm --config %s --port %d\n", n.Config, n.P2PPort)
+	}
+	fmt.Printf("\nOther nodes can join with --bzznetworkid %d --private --bootnodes-file %s\n", networkID, filepath.Join(filepath.Dir(nodes[0].Dir), bootnodesFileName))
+}
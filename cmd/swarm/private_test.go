@@ -0,0 +1,165 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	bzzapi "github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/network"
+)
+
+func TestLoadBootnodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-bootnodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enode := "enode://" + strings.Repeat("ab", 64) + "@127.0.0.1:30399"
+	path := filepath.Join(dir, "bootnodes.txt")
+	content := "# comment\n\n  " + enode + "  \n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := loadBootnodes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0] != enode {
+		t.Fatalf("expected [%s], got %v", enode, nodes)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not an enode\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBootnodes(path); err == nil {
+		t.Fatal("expected error loading invalid bootnode")
+	}
+}
+
+func TestGenConfigPrivate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fleet := &privateFleet{
+		Dir:       dir,
+		NetworkID: 4242,
+		Nodes:     3,
+		Bootnodes: 2,
+		Host:      "127.0.0.1",
+		P2PPort:   30400,
+		BzzPort:   8600,
+	}
+	nodes, err := fleet.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nodes))
+	}
+
+	bootnodes, err := loadBootnodes(filepath.Join(dir, bootnodesFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bootnodes) != 2 || bootnodes[0] != nodes[0].Enode || bootnodes[1] != nodes[1].Enode {
+		t.Fatalf("unexpected bootnodes %v", bootnodes)
+	}
+
+	for i, n := range nodes {
+		// the enode has to match the generated node key
+		key, err := crypto.LoadECDSA(filepath.Join(n.Dir, clientIdentifier, "nodekey"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enode, err := discover.ParseNode(n.Enode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enode.ID != discover.PubkeyID(&key.PublicKey) {
+			t.Fatalf("node %d: enode does not match node key", i)
+		}
+
+		f, err := os.Open(n.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := bzzapi.NewConfig()
+		err = tomlSettings.NewDecoder(f).Decode(config)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.NetworkID != 4242 {
+			t.Fatalf("node %d: expected network id 4242, got %d", i, config.NetworkID)
+		}
+		if !config.PrivateSwarm {
+			t.Fatalf("node %d: expected a private swarm config", i)
+		}
+		if _, err := crypto.LoadECDSA(config.BzzAccount); err != nil {
+			t.Fatalf("node %d: loading account key: %v", i, err)
+		}
+		// bootnodes do not list themselves
+		peers := strings.Split(config.BootNodes, ",")
+		expected := 2
+		if i < 2 {
+			expected = 1
+		}
+		if len(peers) != expected {
+			t.Fatalf("node %d: expected %d bootnodes, got %v", i, expected, peers)
+		}
+		for _, p := range peers {
+			if p == n.Enode {
+				t.Fatalf("node %d: lists itself as bootnode", i)
+			}
+		}
+	}
+}
+
+// TestScopeDiscovery tests that only the nodes configured as a private swarm
+// are kept out of public discovery, whatever their network id
+func TestScopeDiscovery(t *testing.T) {
+	for _, test := range []struct {
+		networkID uint64
+		private   bool
+	}{
+		{network.DefaultNetworkID, false},
+		{4242, false},
+		{4242, true},
+	} {
+		config := bzzapi.NewConfig()
+		config.NetworkID = test.networkID
+		config.PrivateSwarm = test.private
+		cfg := node.Config{P2P: p2p.Config{BootstrapNodes: []*discover.Node{{}}}}
+		scopeDiscovery(config, &cfg)
+		if cfg.P2P.NoDiscovery != test.private || (len(cfg.P2P.BootstrapNodes) == 0) != test.private {
+			t.Fatalf("network %d private %v: unexpected discovery config, no discovery %v, %d bootnodes", test.networkID, test.private, cfg.P2P.NoDiscovery, len(cfg.P2P.BootstrapNodes))
+		}
+	}
+}
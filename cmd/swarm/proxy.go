@@ -0,0 +1,50 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command proxy serves swarm URLs to browsers as a local HTTP proxy.
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	swarmhttp "github.com/ethereum/go-ethereum/swarm/api/http"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func proxy(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		utils.Fatalf("Usage: swarm proxy")
+	}
+	var (
+		bzzapi = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+		addr   = ctx.String(SwarmProxyAddrFlag.Name)
+		tlds   = ctx.StringSlice(SwarmProxyTLDFlag.Name)
+	)
+	if len(tlds) == 0 {
+		tlds = []string{"eth"}
+	}
+	p, err := swarmhttp.NewProxy(bzzapi, tlds)
+	if err != nil {
+		utils.Fatalf("Error creating proxy: %s", err)
+	}
+	log.Info("Serving swarm URLs", "proxy", addr, "gateway", bzzapi, "tlds", strings.Join(tlds, ","), "pac", "http://"+addr+swarmhttp.ProxyPACPath)
+	if err := http.ListenAndServe(addr, p); err != nil {
+		utils.Fatalf("Proxy stopped: %s", err)
+	}
+}
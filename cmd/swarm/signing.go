@@ -0,0 +1,54 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/hex"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// writeClient returns a client of the gateway signing its write requests
+// with the signing key given by the global flags, unsigned if no key is given
+func writeClient(ctx *cli.Context, bzzapi string) *swarm.Client {
+	client := swarm.NewClient(bzzapi)
+	id := ctx.GlobalString(SwarmSignKeyFlag.Name)
+	secret := ctx.GlobalString(SwarmSignSecretFlag.Name)
+	keyfile := ctx.GlobalString(SwarmSignKeyFileFlag.Name)
+	switch {
+	case id == "" && secret == "" && keyfile == "":
+	case id == "":
+		fatalf("--%s is required to sign requests", SwarmSignKeyFlag.Name)
+	case (secret == "") == (keyfile == ""):
+		fatalf("Either --%s or --%s is required to sign requests", SwarmSignSecretFlag.Name, SwarmSignKeyFileFlag.Name)
+	case secret != "":
+		key, err := hex.DecodeString(secret)
+		if err != nil {
+			fatalf("Invalid signing secret: %s", err)
+		}
+		client.Signer = swarm.NewHMACSigner(id, key)
+	default:
+		key, err := crypto.LoadECDSA(expandPath(keyfile))
+		if err != nil {
+			fatalf("Error loading signing key file %s: %s", keyfile, err)
+		}
+		client.Signer = swarm.NewECDSASigner(id, key)
+	}
+	return client
+}
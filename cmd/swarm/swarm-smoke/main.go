@@ -19,8 +19,10 @@ package main
 import (
 	"os"
 	"sort"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	swarmmetrics "github.com/ethereum/go-ethereum/swarm/metrics"
 	colorable "github.com/mattn/go-colorable"
 
 	cli "gopkg.in/urfave/cli.v1"
@@ -34,6 +36,8 @@ var (
 	filesize         int
 	from             int
 	to               int
+	timeout          time.Duration
+	uploads          int
 )
 
 func main() {
@@ -80,15 +84,38 @@ func main() {
 			Usage:       "file size for generated random file in MB",
 			Destination: &filesize,
 		},
+		cli.DurationFlag{
+			Name:        "timeout",
+			Value:       120 * time.Second,
+			Usage:       "time an uploaded file is retried to be retrieved from an endpoint",
+			Destination: &timeout,
+		},
+		cli.IntFlag{
+			Name:        "uploads",
+			Value:       10,
+			Usage:       "maximum number of files uploaded by the sliding window test",
+			Destination: &uploads,
+		},
 	}
+	app.Flags = append(app.Flags, swarmmetrics.Flags...)
 
 	app.Commands = []cli.Command{
 		{
 			Name:    "upload_and_sync",
-			Aliases: []string{"c"},
+			Aliases: []string{"c", "upload-and-sync"},
 			Usage:   "upload and sync",
 			Action:  cliUploadAndSync,
 		},
+		{
+			Name:    "sliding_window",
+			Aliases: []string{"s", "sliding-window"},
+			Usage:   "upload files until the oldest one is not retrievable anymore",
+			Action:  cliSlidingWindow,
+		},
+	}
+	// the metrics of the test are reported once it is done
+	app.After = func(ctx *cli.Context) error {
+		return swarmmetrics.Report(ctx, "smoke.")
 	}
 
 	sort.Sort(cli.FlagsByName(app.Flags))
@@ -97,5 +124,6 @@ func main() {
 	err := app.Run(os.Args)
 	if err != nil {
 		log.Error(err.Error())
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,99 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/pborman/uuid"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	windowFilesGauge = metrics.NewRegisteredGauge("sliding-window.files", nil)
+	windowBytesGauge = metrics.NewRegisteredGauge("sliding-window.bytes", nil)
+)
+
+// uploadedFile is a file uploaded by the sliding window test
+type uploadedFile struct {
+	hash   string
+	digest []byte
+}
+
+// cliSlidingWindow uploads random files one after the other to the first
+// endpoint, after each upload all the files uploaded so far are retrieved
+// from random endpoints. The test stops as soon as the oldest file is not
+// retrievable anymore, the number of files still retrievable is the window
+// of content the cluster keeps. It fails if a file just uploaded cannot be
+// retrieved.
+func cliSlidingWindow(c *cli.Context) error {
+	defer func(now time.Time) { log.Info("total time", "time", time.Since(now), "size", filesize) }(time.Now())
+
+	generateEndpoints(scheme, cluster, from, to)
+
+	var files []uploadedFile
+	for i := 0; i < uploads; i++ {
+		f, cleanup := generateRandomFile(filesize * 1000000)
+		hash, err := upload(f, endpoints[0])
+		if err != nil {
+			cleanup()
+			return err
+		}
+		fhash, err := digest(f)
+		cleanup()
+		if err != nil {
+			return err
+		}
+		log.Info("uploaded successfully", "upload", i, "hash", hash, "digest", fmt.Sprintf("%x", fhash))
+		files = append(files, uploadedFile{hash: hash, digest: fhash})
+
+		// the file just uploaded must be retrievable, the older ones are
+		// retrieved only once from the oldest to the newest
+		ruid := uuid.New()[:8]
+		if err := fetchUntil(hash, randomEndpoint(), fhash, ruid, timeout); err != nil {
+			return fmt.Errorf("uploaded file %s is not retrievable: %v", hash, err)
+		}
+		for j, file := range files[:len(files)-1] {
+			ruid := uuid.New()[:8]
+			if err := fetch(file.hash, randomEndpoint(), file.digest, ruid); err != nil {
+				log.Info("sliding window found", "lost", file.hash, "files", len(files)-j-1, "size", (len(files)-j-1)*filesize)
+				reportWindow(len(files) - j - 1)
+				return nil
+			}
+		}
+	}
+	log.Info("no uploaded file lost", "files", len(files), "size", len(files)*filesize)
+	reportWindow(len(files))
+
+	return nil
+}
+
+// randomEndpoint returns one of the endpoints the files are retrieved from
+func randomEndpoint() string {
+	return endpoints[rand.Intn(len(endpoints))]
+}
+
+// reportWindow records the number of files and bytes retrievable
+func reportWindow(files int) {
+	windowFilesGauge.Update(int64(files))
+	windowBytesGauge.Update(int64(files * filesize * 1000000))
+}
@@ -27,15 +27,23 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/pborman/uuid"
 
 	cli "gopkg.in/urfave/cli.v1"
 )
 
+var (
+	uploadTimer         = metrics.NewRegisteredResettingTimer("upload-and-sync.upload-time", nil)
+	syncTimer           = metrics.NewRegisteredResettingTimer("upload-and-sync.sync-time", nil)
+	successCounter      = metrics.NewRegisteredCounter("upload-and-sync.success", nil)
+	failCounter         = metrics.NewRegisteredCounter("upload-and-sync.fail", nil)
+	endpointFailCounter = metrics.NewRegisteredCounter("upload-and-sync.endpoint-fail", nil)
+)
+
 func generateEndpoints(scheme string, cluster string, from int, to int) {
 	if cluster == "prod" {
 		cluster = ""
@@ -62,11 +70,14 @@ func cliUploadAndSync(c *cli.Context) error {
 	f, cleanup := generateRandomFile(filesize * 1000000)
 	defer cleanup()
 
+	start := time.Now()
 	hash, err := upload(f, endpoints[0])
 	if err != nil {
 		log.Error(err.Error())
+		failCounter.Inc(1)
 		return err
 	}
+	uploadTimer.UpdateSince(start)
 
 	fhash, err := digest(f)
 	if err != nil {
@@ -82,29 +93,48 @@ func cliUploadAndSync(c *cli.Context) error {
 		time.Sleep(2 * time.Duration(filesize) * time.Second)
 	}
 
-	wg := sync.WaitGroup{}
+	errC := make(chan error, len(endpoints))
 	for _, endpoint := range endpoints {
-		endpoint := endpoint
 		ruid := uuid.New()[:8]
-		wg.Add(1)
 		go func(endpoint string, ruid string) {
-			for {
-				err := fetch(hash, endpoint, fhash, ruid)
-				if err != nil {
-					continue
-				}
-
-				wg.Done()
-				return
+			start := time.Now()
+			err := fetchUntil(hash, endpoint, fhash, ruid, timeout)
+			if err != nil {
+				log.Error("endpoint did not sync random file", "ruid", ruid, "api", endpoint, "err", err)
+				endpointFailCounter.Inc(1)
+			} else {
+				syncTimer.UpdateSince(start)
 			}
+			errC <- err
 		}(endpoint, ruid)
 	}
-	wg.Wait()
+	var failed int
+	for range endpoints {
+		if err := <-errC; err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		failCounter.Inc(1)
+		return fmt.Errorf("%d of %d endpoints did not sync random file within %v", failed, len(endpoints), timeout)
+	}
+	successCounter.Inc(1)
 	log.Info("all endpoints synced random file successfully")
 
 	return nil
 }
 
+// fetchUntil is retrying to fetch the requested `hash` from the `endpoint` until it matches the `original` file or the timeout expires
+func fetchUntil(hash string, endpoint string, original []byte, ruid string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fetch(hash, endpoint, original, ruid)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+	}
+}
+
 // fetch is getting the requested `hash` from the `endpoint` and compares it with the `original` file
 func fetch(hash string, endpoint string, original []byte, ruid string) error {
 	log.Trace("sleeping", "ruid", ruid)
@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command sync incrementally publishes a directory to swarm.
+package main
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func syncDir(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		fatalf("Usage: swarm sync <dir> <manifest|resource>")
+	}
+	var (
+		bzzapi     = strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+		dir        = expandPath(args[0])
+		target     = args[1]
+		isResource = ctx.Bool(SwarmSyncResourceFlag.Name)
+		client     = writeClient(ctx, bzzapi)
+		manifest   = target
+		err        error
+	)
+
+	// the previously published manifest of a resource is the one its
+	// latest update points to
+	if isResource {
+		manifest, err = client.ResourceManifest(target)
+		if err != nil {
+			fatalf("Error getting manifest of resource %s: %s", target, err)
+		}
+	}
+
+	hash, result, err := client.SyncDirectory(dir, manifest)
+	if err != nil {
+		fatalf("Sync failed: %s", err)
+	}
+	for _, path := range result.Added {
+		log.Info("Added", "path", path)
+	}
+	for _, path := range result.Updated {
+		log.Info("Updated", "path", path)
+	}
+	for _, path := range result.Removed {
+		log.Info("Removed", "path", path)
+	}
+
+	// the resource only gets updated once the new manifest is complete, so
+	// readers either see the previous or the new version of the directory
+	if isResource && hash != manifest {
+		if err := client.UpdateResourceManifest(target, hash); err != nil {
+			fatalf("Error updating resource %s: %s", target, err)
+		}
+	}
+	printResult(&syncOutput{Hash: hash, Added: result.Added, Updated: result.Updated, Removed: result.Removed}, hash)
+}
+
+// syncOutput is the result of sync printed with --json
+type syncOutput struct {
+	Hash    string   `json:"hash"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
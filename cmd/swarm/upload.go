@@ -22,15 +22,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime"
-	"net/http"
 	"os"
+	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/swarm/api"
 	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -45,49 +44,72 @@ func upload(ctx *cli.Context) {
 		defaultPath  = ctx.GlobalString(SwarmUploadDefaultPath.Name)
 		fromStdin    = ctx.GlobalBool(SwarmUpFromStdinFlag.Name)
 		mimeType     = ctx.GlobalString(SwarmUploadMimeType.Name)
-		client       = swarm.NewClient(bzzapi)
+		client       = writeClient(ctx, bzzapi)
 		toEncrypt    = ctx.Bool(SwarmEncryptedFlag.Name)
+		media        = ctx.Bool(SwarmUploadMediaFlag.Name)
 		file         string
 	)
+	client.Hash = ctx.String(SwarmUploadHashFlag.Name)
+	client.Sparse = ctx.Bool(SwarmUploadSparseFlag.Name)
+	client.Preserve = ctx.Bool(SwarmPreserveFlag.Name)
+	for _, value := range ctx.StringSlice(SwarmUploadErrorDocumentFlag.Name) {
+		doc, err := api.ParseErrorDocument(value)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		client.ErrorDocuments = append(client.ErrorDocuments, doc)
+	}
+	if ctx.Bool(SwarmUploadSPAFlag.Name) {
+		client.Fallback = api.DefaultFallback
+	}
 
 	if len(args) != 1 {
 		if fromStdin {
 			tmp, err := ioutil.TempFile("", "swarm-stdin")
 			if err != nil {
-				utils.Fatalf("error create tempfile: %s", err)
+				fatalf("error create tempfile: %s", err)
 			}
 			defer os.Remove(tmp.Name())
 			n, err := io.Copy(tmp, os.Stdin)
 			if err != nil {
-				utils.Fatalf("error copying stdin to tempfile: %s", err)
+				fatalf("error copying stdin to tempfile: %s", err)
 			} else if n == 0 {
-				utils.Fatalf("error reading from stdin: zero length")
+				fatalf("error reading from stdin: zero length")
 			}
 			file = tmp.Name()
 		} else {
-			utils.Fatalf("Need filename as the first and only argument")
+			fatalf("Need filename as the first and only argument")
 		}
 	} else {
 		file = expandPath(args[0])
 	}
 
+	if media {
+		hash, err := uploadMedia(client, file, toEncrypt)
+		if err != nil {
+			fatalf("Upload failed: %s", err)
+		}
+		printResult(map[string]string{"hash": hash}, hash)
+		return
+	}
+
 	if !wantManifest {
 		f, err := swarm.Open(file)
 		if err != nil {
-			utils.Fatalf("Error opening file: %s", err)
+			fatalf("Error opening file: %s", err)
 		}
 		defer f.Close()
 		hash, err := client.UploadRaw(f, f.Size, toEncrypt)
 		if err != nil {
-			utils.Fatalf("Upload failed: %s", err)
+			fatalf("Upload failed: %s", err)
 		}
-		fmt.Println(hash)
+		printResult(map[string]string{"hash": hash}, hash)
 		return
 	}
 
 	stat, err := os.Stat(file)
 	if err != nil {
-		utils.Fatalf("Error opening file: %s", err)
+		fatalf("Error opening file: %s", err)
 	}
 
 	// define a function which either uploads a directory or single file
@@ -116,9 +138,37 @@ func upload(ctx *cli.Context) {
 	}
 	hash, err := doUpload()
 	if err != nil {
-		utils.Fatalf("Upload failed: %s", err)
+		fatalf("Upload failed: %s", err)
+	}
+	printResult(map[string]string{"hash": hash}, hash)
+}
+
+// uploadMedia uploads a HLS/DASH stream directory, segmenting the file first
+// if it is not a directory
+func uploadMedia(client *swarm.Client, file string, toEncrypt bool) (string, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if stat.IsDir() {
+		return client.UploadMedia(file, toEncrypt)
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg is required to segment %s: %s", file, err)
+	}
+	tmp, err := ioutil.TempDir("", "swarm-media")
+	if err != nil {
+		return "", err
 	}
-	fmt.Println(hash)
+	defer os.RemoveAll(tmp)
+	cmd := exec.Command(ffmpeg, "-loglevel", "error", "-i", file, "-codec", "copy",
+		"-hls_time", "10", "-hls_playlist_type", "vod", "-f", "hls", filepath.Join(tmp, "index.m3u8"))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error segmenting %s: %s", file, err)
+	}
+	return client.UploadMedia(tmp, toEncrypt)
 }
 
 // Expands a file path
@@ -145,18 +195,9 @@ func homeDir() string {
 	return ""
 }
 
+// detectMimeType returns the content type of the file by its extension, the
+// node detects the types of the files with unknown extensions with its own
+// rules
 func detectMimeType(file string) string {
-	if ext := filepath.Ext(file); ext != "" {
-		return mime.TypeByExtension(ext)
-	}
-	f, err := os.Open(file)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-	buf := make([]byte, 512)
-	if n, _ := f.Read(buf); n > 0 {
-		return http.DetectContentType(buf)
-	}
-	return ""
+	return api.NewMimeDetector().ByName(file)
 }
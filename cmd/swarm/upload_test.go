@@ -60,6 +60,32 @@ func TestCLISwarmUpEncryptedRecursive(t *testing.T) {
 	testCLISwarmUpRecursive(true, t)
 }
 
+// TestCLISwarmUpJSON tests that 'swarm --json up' prints the hash and the
+// errors as JSON objects to stdout
+func TestCLISwarmUpJSON(t *testing.T) {
+	cluster := newTestCluster(t, 1)
+	defer cluster.Shutdown()
+
+	tmp, err := ioutil.TempFile("", "swarm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, "notsorandomdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	up := runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "--json", "up", tmp.Name())
+	_, matches := up.ExpectRegexp(`^\{\s*"hash": "([a-f\d]{64})"\s*\}\n`)
+	up.ExpectExit()
+	log.Info("file uploaded", "hash", matches[1])
+
+	up = runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "--json", "up", tmp.Name()+".missing")
+	up.ExpectRegexp(`^\{\s*"error": "Error opening file: [^"]+"\s*\}\n`)
+	up.ExpectExit()
+}
+
 func testCLISwarmUp(toEncrypt bool, t *testing.T) {
 	log.Info("starting 3 node cluster")
 	cluster := newTestCluster(t, 3)
@@ -0,0 +1,209 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// verification statuses of a path
+const (
+	verifyOK         = "ok"         // the local content has the hash of the entry
+	verifyModified   = "modified"   // the local content differs from the entry
+	verifyMissing    = "missing"    // the entry has no local file
+	verifyExtra      = "extra"      // the local file has no entry
+	verifyUnverified = "unverified" // the entry is encrypted or composite, its hash cannot be computed offline
+)
+
+// verifyResult is the result of the verification of a path
+type verifyResult struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`
+	Hash      string `json:"hash,omitempty"`      // hash of the entry in the manifest
+	LocalHash string `json:"localHash,omitempty"` // hash of the local file
+}
+
+func verify(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		fatalf("Usage: swarm verify <dir> <manifest>")
+	}
+	bzzapi := strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+	results, err := verifyDir(swarm.NewClient(bzzapi), args[0], args[1])
+	if err != nil {
+		fatalf("Failed to verify %s: %v", args[0], err)
+	}
+
+	var diverged, verified int
+	for _, r := range results {
+		switch r.Status {
+		case verifyOK:
+			verified++
+		case verifyUnverified:
+		default:
+			diverged++
+		}
+	}
+	if jsonOutput {
+		printJSON(results)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+		for _, r := range results {
+			if r.Status != verifyOK {
+				fmt.Fprintf(w, "%s\t%s\n", r.Status, r.Path)
+			}
+		}
+		w.Flush()
+	}
+	if diverged > 0 {
+		fatalf("%d paths diverge from manifest %s", diverged, args[1])
+	}
+	if !jsonOutput {
+		fmt.Printf("%d files match manifest %s\n", verified, args[1])
+	}
+}
+
+// verifyDir hashes the files of the directory the way they are uploaded with
+// 'swarm --recursive up' and compares the hashes with the entries of the
+// manifest, the results are sorted by path
+func verifyDir(client *swarm.Client, dir, manifest string) ([]*verifyResult, error) {
+	// the content is hashed with the hash function of the manifest, sparse
+	// if the content of the manifest is
+	root, _, err := client.DownloadManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*api.ManifestEntry)
+	if err := listManifest(client, manifest, "", entries); err != nil {
+		return nil, err
+	}
+	var results []*verifyResult
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		entry, ok := entries[relPath]
+		if !ok {
+			results = append(results, &verifyResult{Path: relPath, Status: verifyExtra})
+			return nil
+		}
+		delete(entries, relPath)
+		result, err := verifyEntry(path, f, entry, root)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for path, entry := range entries {
+		// the entry of the default path duplicates one of the files
+		if path == "" {
+			continue
+		}
+		results = append(results, &verifyResult{Path: path, Status: verifyMissing, Hash: entry.Hash})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results, nil
+}
+
+// listManifest adds the entries of the manifest under the prefix, and of its
+// directories, to the entries keyed by path
+func listManifest(client *swarm.Client, manifest, prefix string, entries map[string]*api.ManifestEntry) error {
+	list, err := client.List(manifest, prefix)
+	if err != nil {
+		return err
+	}
+	for _, p := range list.CommonPrefixes {
+		if p == prefix {
+			continue
+		}
+		if err := listManifest(client, manifest, p, entries); err != nil {
+			return err
+		}
+	}
+	for _, entry := range list.Entries {
+		entries[entry.Path] = entry
+	}
+	return nil
+}
+
+// verifyEntry compares the content of the local file with the entry, the
+// content of a link uploaded as a link is its target, hashed like the content
+// of the manifest of the entry
+func verifyEntry(path string, f os.FileInfo, entry *api.ManifestEntry, root *api.Manifest) (*verifyResult, error) {
+	result := &verifyResult{Path: entry.Path, Hash: entry.Hash}
+	var data []byte
+	var err error
+	if entry.LinkTarget != "" && f.Mode()&os.ModeSymlink != 0 {
+		var target string
+		target, err = os.Readlink(path)
+		data = []byte(target)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(entry.Data) > 0:
+		result.Status = verifyModified
+		if bytes.Equal(data, entry.Data) {
+			result.Status = verifyOK
+		}
+		return result, nil
+	case len(entry.Parts) > 0 || len(entry.Hash) != 2*storage.KeyLength:
+		result.Status = verifyUnverified
+		return result, nil
+	}
+
+	fileStore := storage.NewFileStore(storage.NewMapChunkStore(), storage.NewFileStoreParams())
+	ctx := storage.WithSparse(context.TODO(), root.Sparse)
+	addr, _, err := fileStore.StoreWithHash(ctx, bytes.NewReader(data), int64(len(data)), false, root.HashFunc)
+	if err != nil {
+		return nil, err
+	}
+	result.LocalHash = addr.Hex()
+	result.Status = verifyModified
+	if result.LocalHash == entry.Hash {
+		result.Status = verifyOK
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCLISwarmVerify tests that 'swarm verify' accepts the directory a
+// manifest was uploaded from and lists the paths which diverge from it
+func TestCLISwarmVerify(t *testing.T) {
+	cluster := newTestCluster(t, 1)
+	defer cluster.Shutdown()
+
+	dir, err := ioutil.TempDir("", "swarm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 10000)
+	rand.Read(data)
+	for _, path := range []string{"a", "b", "sub/c"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up := runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "--recursive", "up", dir)
+	_, matches := up.ExpectRegexp(`[a-f\d]{64}`)
+	up.ExpectExit()
+	hash := matches[0]
+
+	verify := runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "verify", dir, hash)
+	verify.ExpectRegexp(`3 files match manifest ` + hash)
+	verify.ExpectExit()
+
+	// the files of a manifest are hashed with its hash function
+	up = runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "--recursive", "up", "--hash", "SHA256", dir)
+	_, matches = up.ExpectRegexp(`[a-f\d]{64}`)
+	up.ExpectExit()
+	sha256Hash := matches[0]
+	verify = runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "verify", dir, sha256Hash)
+	verify.ExpectRegexp(`3 files match manifest ` + sha256Hash)
+	verify.ExpectExit()
+
+	data[0]++
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub/c"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "d"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	verify = runSwarm(t, "--bzzapi", cluster.Nodes[0].URL, "verify", dir, hash)
+	verify.ExpectRegexp(`missing\s+b\nextra\s+d\nmodified\s+sub/c\nFatal: 3 paths diverge from manifest ` + hash + `\n`)
+	verify.ExpectExit()
+}
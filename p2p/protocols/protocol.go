@@ -29,16 +29,21 @@ devp2p subprotocols by abstracting away code standardly shared by protocols.
 package protocols
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // error codes used by this  protocol scheme
@@ -176,6 +181,61 @@ func (s *Spec) NewMsg(code uint64) (interface{}, bool) {
 	return reflect.New(typ).Interface(), true
 }
 
+// TraceEvent describes a message sent to or received from a peer
+type TraceEvent struct {
+	Time     time.Time
+	Protocol string
+	Version  uint
+	Peer     discover.NodeID
+	Outbound bool   // true if the message was sent to the peer
+	Code     uint64 // message code
+	Type     string // name of the message type
+	Size     uint32 // size of the RLP encoded payload
+	Payload  []byte // RLP encoded payload
+}
+
+// Tracer is notified of every message sent or received by protocol peers,
+// Trace must not retain the payload
+type Tracer interface {
+	Trace(*TraceEvent)
+}
+
+type tracerBox struct {
+	Tracer
+}
+
+var tracer atomic.Value
+
+// SetTracer installs a tracer receiving the messages of all protocol peers,
+// nil disables tracing
+func SetTracer(t Tracer) {
+	tracer.Store(tracerBox{t})
+}
+
+func getTracer() Tracer {
+	box, _ := tracer.Load().(tracerBox)
+	return box.Tracer
+}
+
+// trace notifies the tracer of a message
+func (p *Peer) trace(t Tracer, outbound bool, code uint64, size uint32, payload []byte) {
+	var name string
+	if typ, ok := p.spec.types[code]; ok {
+		name = typ.Name()
+	}
+	t.Trace(&TraceEvent{
+		Time:     time.Now(),
+		Protocol: p.spec.Name,
+		Version:  p.spec.Version,
+		Peer:     p.ID(),
+		Outbound: outbound,
+		Code:     code,
+		Type:     name,
+		Size:     size,
+		Payload:  payload,
+	})
+}
+
 // Peer represents a remote peer or protocol instance that is running on a peer connection with
 // a remote peer
 type Peer struct {
@@ -232,6 +292,11 @@ func (p *Peer) Send(msg interface{}) error {
 	if !found {
 		return errorf(ErrInvalidMsgType, "%v", code)
 	}
+	if t := getTracer(); t != nil {
+		if payload, err := rlp.EncodeToBytes(msg); err == nil {
+			p.trace(t, true, code, uint32(len(payload)), payload)
+		}
+	}
 	return p2p.Send(p.rw, code, msg)
 }
 
@@ -259,6 +324,14 @@ func (p *Peer) handleIncoming(handle func(msg interface{}) error) error {
 	if !ok {
 		return errorf(ErrInvalidMsgCode, "%v", msg.Code)
 	}
+	if t := getTracer(); t != nil {
+		payload, err := ioutil.ReadAll(msg.Payload)
+		if err != nil {
+			return errorf(ErrDecode, "<= %v: %v", msg, err)
+		}
+		p.trace(t, false, msg.Code, msg.Size, payload)
+		msg.Payload = bytes.NewReader(payload)
+	}
 	if err := msg.Decode(val); err != nil {
 		return errorf(ErrDecode, "<= %v: %v", msg, err)
 	}
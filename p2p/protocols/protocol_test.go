@@ -386,3 +386,63 @@ func XTestMultiplePeersDropOther(t *testing.T) {
 		fmt.Errorf("subprotocol error"),
 	)
 }
+
+type testTracer struct {
+	peer   discover.NodeID
+	events chan *TraceEvent
+}
+
+func (t *testTracer) Trace(ev *TraceEvent) {
+	// peers of other tests may still be running
+	if ev.Peer == t.peer {
+		t.events <- ev
+	}
+}
+
+func TestTracer(t *testing.T) {
+	id := adapters.RandomNodeConfig().ID
+	tr := &testTracer{peer: id, events: make(chan *TraceEvent, 10)}
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	spec := &Spec{
+		Name:       "test",
+		Version:    42,
+		MaxMsgSize: 10 * 1024,
+		Messages:   []interface{}{hs0{}},
+	}
+	rw1, rw2 := p2p.MsgPipe()
+	defer rw1.Close()
+	p1 := NewPeer(p2p.NewPeer(id, "p1", nil), rw1, spec)
+	p2 := NewPeer(p2p.NewPeer(id, "p2", nil), rw2, spec)
+
+	received := make(chan interface{}, 1)
+	go p2.handleIncoming(func(msg interface{}) error {
+		received <- msg
+		return nil
+	})
+	if err := p1.Send(&hs0{C: 42}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg.(*hs0).C != 42 {
+			t.Fatalf("expected 42, got %v", msg.(*hs0).C)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	for _, outbound := range []bool{true, false} {
+		ev := <-tr.events
+		if ev.Outbound != outbound {
+			t.Fatalf("expected outbound %v, got %v", outbound, ev.Outbound)
+		}
+		if ev.Protocol != "test" || ev.Version != 42 || ev.Type != "hs0" || ev.Code != 0 || ev.Peer != id {
+			t.Fatalf("unexpected trace event %+v", ev)
+		}
+		if int(ev.Size) != len(ev.Payload) || len(ev.Payload) == 0 {
+			t.Fatalf("unexpected payload size %d (%d)", ev.Size, len(ev.Payload))
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"net"
 	"sync"
 
@@ -39,6 +40,7 @@ type SimAdapter struct {
 	mtx      sync.RWMutex
 	nodes    map[discover.NodeID]*SimNode
 	services map[string]ServiceFunc
+	links    *links
 }
 
 // NewSimAdapter creates a SimAdapter which is capable of running in-memory
@@ -50,6 +52,7 @@ func NewSimAdapter(services map[string]ServiceFunc) *SimAdapter {
 		pipe:     pipes.NetPipe,
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    newLinks(),
 	}
 }
 
@@ -58,6 +61,7 @@ func NewTCPAdapter(services map[string]ServiceFunc) *SimAdapter {
 		pipe:     pipes.TCPPipe,
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    newLinks(),
 	}
 }
 
@@ -92,7 +96,7 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			PrivateKey:      config.PrivateKey,
 			MaxPeers:        math.MaxInt32,
 			NoDiscovery:     true,
-			Dialer:          s,
+			Dialer:          &simDialer{s, id},
 			EnableMsgEvents: config.EnableMsgEvents,
 		},
 		NoUSB:  true,
@@ -116,6 +120,27 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
 // an in-memory net.Pipe
 func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
+	return s.dial(discover.NodeID{}, dest)
+}
+
+// SetLink implements LinkConditioner
+func (s *SimAdapter) SetLink(one, other discover.NodeID, link *Link) {
+	s.links.set(newLinkKey(one, other), link)
+}
+
+// SetRand implements Randomizer, the loss of writes on the simulated links
+// is drawn from rnd, which has to be safe for concurrent use
+func (s *SimAdapter) SetRand(rnd *rand.Rand) {
+	s.links.setRand(rnd)
+}
+
+// dial connects the src node to the dest node over a pipe subject to the
+// conditions of the link between them
+func (s *SimAdapter) dial(src discover.NodeID, dest *discover.Node) (conn net.Conn, err error) {
+	key := newLinkKey(src, dest.ID)
+	if link := s.links.get(key); link != nil && link.Down {
+		return nil, errLinkDown
+	}
 	node, ok := s.GetNode(dest.ID)
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID)
@@ -132,8 +157,8 @@ func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
 	// this is simulated 'listening'
 	// asynchronously call the dialed destintion node's p2p server
 	// to set up connection on the 'listening' side
-	go srv.SetupConn(pipe1, 0, nil)
-	return pipe2, nil
+	go srv.SetupConn(s.links.wrap(key, pipe1), 0, nil)
+	return s.links.wrap(key, pipe2), nil
 }
 
 // DialRPC implements the RPCDialer interface by creating an in-memory RPC
@@ -0,0 +1,196 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// LossRetransmitDelay is the delay a lost write adds, simulating the
+// retransmission of the lost packet on a reliable connection
+var LossRetransmitDelay = 200 * time.Millisecond
+
+// maxLoss caps the loss rate so that every write eventually succeeds
+const maxLoss = 0.99
+
+var errLinkDown = errors.New("simulated link down")
+
+// Link describes the simulated conditions of the link between two nodes
+type Link struct {
+	Latency time.Duration // delay of every write
+	Loss    float64       // probability of a write being lost and retransmitted
+	Down    bool          // the nodes are partitioned
+}
+
+// LinkConditioner is implemented by adapters which can simulate the
+// conditions of the links between nodes
+type LinkConditioner interface {
+	// SetLink sets the conditions of the link between two nodes, a nil
+	// link restores a perfect link, existing connections are closed if
+	// the link goes down
+	SetLink(one, other discover.NodeID, link *Link)
+}
+
+// Randomizer is implemented by adapters which can draw the randomness of the
+// simulation, e.g. the loss of writes on a link, from a seeded source
+type Randomizer interface {
+	SetRand(rnd *rand.Rand)
+}
+
+// linkKey identifies the undirected link between two nodes
+type linkKey struct {
+	one, other discover.NodeID
+}
+
+func newLinkKey(one, other discover.NodeID) linkKey {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return linkKey{one, other}
+}
+
+// links keeps the conditions of the links and the live connections
+type links struct {
+	mtx   sync.RWMutex
+	links map[linkKey]*Link
+	conns map[linkKey]map[*linkConn]struct{}
+	rnd   *rand.Rand // source of the write loss, the global source if nil
+}
+
+func newLinks() *links {
+	return &links{
+		links: make(map[linkKey]*Link),
+		conns: make(map[linkKey]map[*linkConn]struct{}),
+	}
+}
+
+func (l *links) get(key linkKey) *Link {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return l.links[key]
+}
+
+func (l *links) setRand(rnd *rand.Rand) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.rnd = rnd
+}
+
+// lost reports whether a write is lost with the given probability
+func (l *links) lost(loss float64) bool {
+	l.mtx.RLock()
+	rnd := l.rnd
+	l.mtx.RUnlock()
+	if rnd == nil {
+		return rand.Float64() < loss
+	}
+	return rnd.Float64() < loss
+}
+
+func (l *links) set(key linkKey, link *Link) {
+	l.mtx.Lock()
+	if link == nil {
+		delete(l.links, key)
+	} else {
+		l.links[key] = link
+	}
+	var down []*linkConn
+	if link != nil && link.Down {
+		for c := range l.conns[key] {
+			down = append(down, c)
+		}
+	}
+	l.mtx.Unlock()
+	for _, c := range down {
+		c.Close()
+	}
+}
+
+// wrap returns a connection subject to the conditions of the link
+func (l *links) wrap(key linkKey, conn net.Conn) *linkConn {
+	c := &linkConn{Conn: conn, links: l, key: key}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	conns, ok := l.conns[key]
+	if !ok {
+		conns = make(map[*linkConn]struct{})
+		l.conns[key] = conns
+	}
+	conns[c] = struct{}{}
+	return c
+}
+
+func (l *links) remove(c *linkConn) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	delete(l.conns[c.key], c)
+	if len(l.conns[c.key]) == 0 {
+		delete(l.conns, c.key)
+	}
+}
+
+// linkConn is a connection delaying writes according to its link
+type linkConn struct {
+	net.Conn
+	links *links
+	key   linkKey
+	once  sync.Once
+}
+
+func (c *linkConn) Write(b []byte) (int, error) {
+	if link := c.links.get(c.key); link != nil {
+		if link.Down {
+			c.Close()
+			return 0, errLinkDown
+		}
+		delay := link.Latency
+		loss := link.Loss
+		if loss > maxLoss {
+			loss = maxLoss
+		}
+		for loss > 0 && c.links.lost(loss) {
+			delay += LossRetransmitDelay
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *linkConn) Close() error {
+	c.once.Do(func() { c.links.remove(c) })
+	return c.Conn.Close()
+}
+
+// simDialer dials other simulation nodes on behalf of a node so that the
+// link between them is known
+type simDialer struct {
+	adapter *SimAdapter
+	id      discover.NodeID
+}
+
+func (d *simDialer) Dial(dest *discover.Node) (net.Conn, error) {
+	return d.adapter.dial(d.id, dest)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+func TestLinkConditions(t *testing.T) {
+	l := newLinks()
+	one, other := discover.NodeID{1}, discover.NodeID{2}
+	key := newLinkKey(one, other)
+	if key != newLinkKey(other, one) {
+		t.Fatal("expected links to be undirected")
+	}
+
+	p1, p2 := net.Pipe()
+	c1, c2 := l.wrap(key, p1), l.wrap(key, p2)
+	go io.Copy(ioutil.Discard, c2)
+
+	latency := 50 * time.Millisecond
+	l.set(key, &Link{Latency: latency})
+	start := time.Now()
+	if _, err := c1.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Fatalf("expected write to be delayed by %v, took %v", latency, elapsed)
+	}
+
+	// lost writes are retransmitted
+	defer func(d time.Duration) { LossRetransmitDelay = d }(LossRetransmitDelay)
+	LossRetransmitDelay = time.Millisecond
+	l.set(key, &Link{Loss: 1})
+	start = time.Now()
+	if _, err := c1.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < LossRetransmitDelay {
+		t.Fatal("expected lost write to be retransmitted")
+	}
+
+	// taking the link down closes the connections
+	l.set(key, &Link{Down: true})
+	if _, err := c1.Write([]byte("hello")); err == nil {
+		t.Fatal("expected write to fail on a link which is down")
+	}
+	if n := len(l.conns[key]); n != 0 {
+		t.Fatalf("expected connections to be closed, %d left", n)
+	}
+}
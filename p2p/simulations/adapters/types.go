@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strconv"
@@ -187,6 +188,33 @@ func RandomNodeConfig() *NodeConfig {
 	}
 }
 
+// SeededNodeConfig returns a node configuration with a private key read from
+// rnd, a seeded source of randomness yields the same node IDs on every run
+func SeededNodeConfig(rnd io.Reader) *NodeConfig {
+	var key *ecdsa.PrivateKey
+	for key == nil {
+		seed := make([]byte, 32)
+		if _, err := io.ReadFull(rnd, seed); err != nil {
+			panic("unable to read key seed")
+		}
+		// the seed is rejected if it is not a valid scalar, read another one
+		key, _ = crypto.ToECDSA(seed)
+	}
+
+	id := discover.PubkeyID(&key.PublicKey)
+	port, err := assignTCPPort()
+	if err != nil {
+		panic("unable to assign tcp port")
+	}
+	return &NodeConfig{
+		ID:              id,
+		Name:            fmt.Sprintf("node_%s", id.String()),
+		PrivateKey:      key,
+		Port:            port,
+		EnableMsgEvents: true,
+	}
+}
+
 func assignTCPPort() (uint16, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -0,0 +1,182 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// SeedEnvVar is the environment variable holding the seed of deterministic
+// simulations, set it to the seed logged by a failed run to reproduce it
+const SeedEnvVar = "SIMULATION_SEED"
+
+// virtualEpoch is the start time of the virtual clock of deterministic
+// networks
+var virtualEpoch = time.Unix(0, 0).UTC()
+
+// SeedFromEnv returns the seed set in the SIMULATION_SEED environment
+// variable, or a seed derived from the current time if it is not set
+func SeedFromEnv() int64 {
+	if s := os.Getenv(SeedEnvVar); s != "" {
+		if seed, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return seed
+		}
+	}
+	return time.Now().UnixNano()
+}
+
+// Clock is the source of time of a simulation
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the wall clock used by non-deterministic networks
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// VirtualClock is a clock which only moves when it is advanced, timers fire
+// in the order of their deadlines and of their creation if the deadlines
+// are equal
+type VirtualClock struct {
+	mtx    sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*virtualTimer
+}
+
+type virtualTimer struct {
+	at  time.Time
+	seq uint64
+	c   chan time.Time
+}
+
+// NewVirtualClock returns a virtual clock starting at the given time
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now returns the current virtual time
+func (c *VirtualClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the virtual time once the clock is
+// advanced by d
+func (c *VirtualClock) After(d time.Duration) <-chan time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.seq++
+	c.timers = append(c.timers, &virtualTimer{at: c.now.Add(d), seq: c.seq, c: ch})
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].at.Equal(c.timers[j].at) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the timers due
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if d <= 0 {
+		return
+	}
+	c.now = c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].at.After(c.now) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		t.c <- t.at
+	}
+}
+
+// lockedSource is a rand.Source safe for concurrent use
+type lockedSource struct {
+	mtx sync.Mutex
+	src rand.Source
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{src: rand.NewSource(seed)}
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.src.Seed(seed)
+}
+
+// Rand returns the source of randomness of the network, it is seeded with
+// the network seed and safe for concurrent use
+func (net *Network) Rand() *rand.Rand {
+	return net.rand
+}
+
+// Clock returns the clock of the network, a virtual clock for deterministic
+// networks and the wall clock otherwise
+func (net *Network) Clock() Clock {
+	return net.clock
+}
+
+// RandomNodeConfig returns the configuration of a new node, the node keys
+// of deterministic networks are drawn from the seeded source so that the
+// node IDs are the same on every run
+func (net *Network) RandomNodeConfig() *adapters.NodeConfig {
+	if !net.Deterministic {
+		return adapters.RandomNodeConfig()
+	}
+	return adapters.SeededNodeConfig(net.rand)
+}
+
+// SeedLogger is the part of testing.TB used to report the seed of a failed
+// simulation
+type SeedLogger interface {
+	Failed() bool
+	Logf(format string, args ...interface{})
+}
+
+// LogSeed logs the seed of the network if the test failed, tests defer it
+// so that a failed run can be reproduced by setting SIMULATION_SEED
+func (net *Network) LogSeed(t SeedLogger) {
+	if t.Failed() {
+		t.Logf("simulation seed %d, rerun with %s=%d to reproduce", net.Seed, SeedEnvVar, net.Seed)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewVirtualClock(start)
+	late := clock.After(3 * time.Second)
+	first := clock.After(time.Second)
+	middle := clock.After(2 * time.Second)
+	second := clock.After(time.Second)
+
+	clock.Advance(2 * time.Second)
+	if now := clock.Now(); !now.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("expected clock at %v, got %v", start.Add(2*time.Second), now)
+	}
+	for i, c := range []<-chan time.Time{first, second, middle} {
+		select {
+		case <-c:
+		default:
+			t.Fatalf("timer %d did not fire", i)
+		}
+	}
+	select {
+	case <-late:
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+	clock.Advance(time.Second)
+	if at := <-late; !at.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("expected timer to fire at %v, got %v", start.Add(3*time.Second), at)
+	}
+}
+
+// TestDeterministicNetwork tests that networks with the same seed create the
+// same nodes and churn schedules, and run scenarios on the virtual clock
+func TestDeterministicNetwork(t *testing.T) {
+	run := func(seed int64) ([]discover.NodeID, []string) {
+		network := NewNetwork(adapters.NewSimAdapter(testServices), &NetworkConfig{
+			DefaultService: "test",
+			Deterministic:  true,
+			Seed:           seed,
+		})
+		defer network.Shutdown()
+		defer network.LogSeed(t)
+
+		ids := make([]discover.NodeID, 4)
+		for i := range ids {
+			node, err := network.NewNodeWithConfig(network.RandomNodeConfig())
+			if err != nil {
+				t.Fatalf("error creating node: %s", err)
+			}
+			if err := network.Start(node.ID()); err != nil {
+				t.Fatalf("error starting node: %s", err)
+			}
+			ids[i] = node.ID()
+		}
+
+		// an hour of churn runs in virtual time
+		events := RandomChurn(network.Rand(), ids, time.Hour, 10*time.Minute, 5*time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		start := network.Clock().Now()
+		if err := network.RunScenario(ctx, events); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := network.Clock().Now().Sub(start); elapsed != events[len(events)-1].At {
+			t.Fatalf("expected virtual clock to advance by %v, got %v", events[len(events)-1].At, elapsed)
+		}
+		labels := make([]string, len(events))
+		for i, ev := range events {
+			labels[i] = ev.Label
+		}
+		return ids, labels
+	}
+
+	ids, labels := run(42)
+	otherIDs, otherLabels := run(42)
+	if fmt.Sprint(ids) != fmt.Sprint(otherIDs) {
+		t.Fatalf("expected the same node IDs for the same seed, got %v and %v", ids, otherIDs)
+	}
+	if strings.Join(labels, ",") != strings.Join(otherLabels, ",") {
+		t.Fatalf("expected the same churn for the same seed, got %v and %v", labels, otherLabels)
+	}
+	if differentIDs, _ := run(43); fmt.Sprint(ids) == fmt.Sprint(differentIDs) {
+		t.Fatal("expected different node IDs for a different seed")
+	}
+}
+
+type seedRecorder struct {
+	failed bool
+	logs   []string
+}
+
+func (r *seedRecorder) Failed() bool { return r.failed }
+
+func (r *seedRecorder) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestLogSeed(t *testing.T) {
+	network := NewNetwork(adapters.NewSimAdapter(testServices), &NetworkConfig{
+		Deterministic: true,
+		Seed:          7,
+	})
+	defer network.Shutdown()
+
+	r := &seedRecorder{}
+	network.LogSeed(r)
+	if len(r.logs) != 0 {
+		t.Fatalf("expected no seed logged for a passing test, got %v", r.logs)
+	}
+	r.failed = true
+	network.LogSeed(r)
+	if len(r.logs) != 1 || !strings.Contains(r.logs[0], SeedEnvVar+"=7") {
+		t.Fatalf("expected seed to be logged, got %v", r.logs)
+	}
+
+	// a failing scenario event reports the seed
+	err := network.RunScenario(context.Background(), []*ScenarioEvent{
+		NodeDown(time.Minute, discover.NodeID{}),
+	})
+	if err == nil || !strings.Contains(err.Error(), "seed 7") {
+		t.Fatalf("expected scenario error with seed, got %v", err)
+	}
+}
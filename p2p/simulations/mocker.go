@@ -20,13 +20,11 @@ package simulations
 
 import (
 	"fmt"
-	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
-	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 )
 
 //a map of mocker names to its function
@@ -73,7 +71,7 @@ func startStop(net *Network, quit chan struct{}, nodeCount int) {
 			log.Info("Terminating simulation loop")
 			return
 		case <-tick.C:
-			id := nodes[rand.Intn(len(nodes))]
+			id := nodes[net.Rand().Intn(len(nodes))]
 			log.Info("stopping node", "id", id)
 			if err := net.Stop(id); err != nil {
 				log.Error("error stopping node", "id", id, "err", err)
@@ -120,9 +118,9 @@ func probabilistic(net *Network, quit chan struct{}, nodeCount int) {
 		}
 		var lowid, highid int
 		var wg sync.WaitGroup
-		randWait := time.Duration(rand.Intn(5000)+1000) * time.Millisecond
-		rand1 := rand.Intn(nodeCount - 1)
-		rand2 := rand.Intn(nodeCount - 1)
+		randWait := time.Duration(net.Rand().Intn(5000)+1000) * time.Millisecond
+		rand1 := net.Rand().Intn(nodeCount - 1)
+		rand2 := net.Rand().Intn(nodeCount - 1)
 		if rand1 < rand2 {
 			lowid = rand1
 			highid = rand2
@@ -172,7 +170,7 @@ func probabilistic(net *Network, quit chan struct{}, nodeCount int) {
 func connectNodesInRing(net *Network, nodeCount int) ([]discover.NodeID, error) {
 	ids := make([]discover.NodeID, nodeCount)
 	for i := 0; i < nodeCount; i++ {
-		conf := adapters.RandomNodeConfig()
+		conf := net.RandomNodeConfig()
 		node, err := net.NewNodeWithConfig(conf)
 		if err != nil {
 			log.Error("Error creating a node!", "err", err)
@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -37,6 +38,11 @@ var DialBanTimeout = 200 * time.Millisecond
 type NetworkConfig struct {
 	ID             string `json:"id"`
 	DefaultService string `json:"default_service,omitempty"`
+
+	// Deterministic networks draw all simulation randomness from Seed and
+	// schedule scenarios on a virtual clock so that runs can be reproduced
+	Deterministic bool  `json:"deterministic,omitempty"`
+	Seed          int64 `json:"seed,omitempty"`
 }
 
 // Network models a p2p simulation network which consists of a collection of
@@ -60,17 +66,33 @@ type Network struct {
 	events      event.Feed
 	lock        sync.RWMutex
 	quitc       chan struct{}
+	partition   [][2]discover.NodeID // links taken down by Partition
+	rand        *rand.Rand
+	clock       Clock
 }
 
 // NewNetwork returns a Network which uses the given NodeAdapter and NetworkConfig
 func NewNetwork(nodeAdapter adapters.NodeAdapter, conf *NetworkConfig) *Network {
-	return &Network{
+	net := &Network{
 		NetworkConfig: *conf,
 		nodeAdapter:   nodeAdapter,
 		nodeMap:       make(map[discover.NodeID]int),
 		connMap:       make(map[string]int),
 		quitc:         make(chan struct{}),
+		clock:         systemClock{},
+	}
+	if !net.Deterministic && net.Seed == 0 {
+		net.Seed = time.Now().UnixNano()
+	}
+	net.rand = rand.New(newLockedSource(net.Seed))
+	if net.Deterministic {
+		net.clock = NewVirtualClock(virtualEpoch)
+		if r, ok := nodeAdapter.(adapters.Randomizer); ok {
+			r.SetRand(net.rand)
+		}
+		log.Info("deterministic simulation network", "seed", net.Seed)
 	}
+	return net
 }
 
 // Events returns the output event feed of the Network.
@@ -180,6 +202,7 @@ func (net *Network) startWithSnapshots(id discover.NodeID, snapshots map[string]
 		return err
 	}
 	node.Up = true
+	node.starts++
 	log.Info(fmt.Sprintf("started node %v: %v", id, node.Up))
 
 	net.events.Send(NewEvent(node))
@@ -194,13 +217,13 @@ func (net *Network) startWithSnapshots(id discover.NodeID, snapshots map[string]
 	if err != nil {
 		return fmt.Errorf("error getting peer events for node %v: %s", id, err)
 	}
-	go net.watchPeerEvents(id, events, sub)
+	go net.watchPeerEvents(id, node.starts, events, sub)
 	return nil
 }
 
 // watchPeerEvents reads peer events from the given channel and emits
 // corresponding network events
-func (net *Network) watchPeerEvents(id discover.NodeID, events chan *p2p.PeerEvent, sub event.Subscription) {
+func (net *Network) watchPeerEvents(id discover.NodeID, run uint64, events chan *p2p.PeerEvent, sub event.Subscription) {
 	defer func() {
 		sub.Unsubscribe()
 
@@ -212,6 +235,10 @@ func (net *Network) watchPeerEvents(id discover.NodeID, events chan *p2p.PeerEve
 			log.Error("Can not find node for id", "id", id)
 			return
 		}
+		if node.starts != run {
+			// the node was restarted, the new run is watched already
+			return
+		}
 		node.Up = false
 		net.events.Send(NewEvent(node))
 	}()
@@ -524,6 +551,10 @@ type Node struct {
 
 	// Up tracks whether or not the node is running
 	Up bool `json:"up"`
+
+	// starts counts the starts of the node so that the peer event watcher
+	// of an earlier run does not mark the restarted node down
+	starts uint64
 }
 
 // ID returns the ID of the node
@@ -0,0 +1,200 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+var errNoLinkConditioner = errors.New("node adapter cannot simulate link conditions")
+
+// SetLink sets the conditions of the link between two nodes, a nil link
+// restores a perfect link
+func (net *Network) SetLink(one, other discover.NodeID, link *adapters.Link) error {
+	lc, ok := net.nodeAdapter.(adapters.LinkConditioner)
+	if !ok {
+		return errNoLinkConditioner
+	}
+	lc.SetLink(one, other, link)
+	return nil
+}
+
+// Partition takes down the links between nodes of different groups, nodes
+// not in any group keep their links, connections across groups are closed
+// and can not be established until Heal is called
+func (net *Network) Partition(groups ...[]discover.NodeID) error {
+	lc, ok := net.nodeAdapter.(adapters.LinkConditioner)
+	if !ok {
+		return errNoLinkConditioner
+	}
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	for i, group := range groups {
+		for _, other := range groups[i+1:] {
+			for _, one := range group {
+				for _, two := range other {
+					lc.SetLink(one, two, &adapters.Link{Down: true})
+					net.partition = append(net.partition, [2]discover.NodeID{one, two})
+				}
+			}
+		}
+	}
+	log.Info("partitioned network", "groups", len(groups))
+	return nil
+}
+
+// Heal restores the links taken down by Partition
+func (net *Network) Heal() error {
+	lc, ok := net.nodeAdapter.(adapters.LinkConditioner)
+	if !ok {
+		return errNoLinkConditioner
+	}
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	for _, link := range net.partition {
+		lc.SetLink(link[0], link[1], nil)
+	}
+	net.partition = nil
+	log.Info("healed network partition")
+	return nil
+}
+
+// ScenarioEvent is an action on the network executed at an offset from the
+// start of a scenario
+type ScenarioEvent struct {
+	At     time.Duration
+	Label  string
+	Action func(*Network) error
+}
+
+// NodeDown returns a scenario event stopping a node
+func NodeDown(at time.Duration, id discover.NodeID) *ScenarioEvent {
+	return &ScenarioEvent{
+		At:    at,
+		Label: fmt.Sprintf("node %s down", id.TerminalString()),
+		Action: func(net *Network) error {
+			return net.Stop(id)
+		},
+	}
+}
+
+// NodeUp returns a scenario event starting a node
+func NodeUp(at time.Duration, id discover.NodeID) *ScenarioEvent {
+	return &ScenarioEvent{
+		At:    at,
+		Label: fmt.Sprintf("node %s up", id.TerminalString()),
+		Action: func(net *Network) error {
+			return net.Start(id)
+		},
+	}
+}
+
+// LinkChange returns a scenario event setting the conditions of a link
+func LinkChange(at time.Duration, one, other discover.NodeID, link *adapters.Link) *ScenarioEvent {
+	return &ScenarioEvent{
+		At:    at,
+		Label: fmt.Sprintf("link %s-%s %+v", one.TerminalString(), other.TerminalString(), link),
+		Action: func(net *Network) error {
+			return net.SetLink(one, other, link)
+		},
+	}
+}
+
+// PartitionEvent returns a scenario event partitioning the network
+func PartitionEvent(at time.Duration, groups ...[]discover.NodeID) *ScenarioEvent {
+	return &ScenarioEvent{
+		At:    at,
+		Label: fmt.Sprintf("partition into %d groups", len(groups)),
+		Action: func(net *Network) error {
+			return net.Partition(groups...)
+		},
+	}
+}
+
+// HealEvent returns a scenario event healing the network partition
+func HealEvent(at time.Duration) *ScenarioEvent {
+	return &ScenarioEvent{
+		At:    at,
+		Label: "heal partition",
+		Action: func(net *Network) error {
+			return net.Heal()
+		},
+	}
+}
+
+// RandomChurn returns a churn schedule over the given duration in which
+// every interval a random node which is up goes down for the downtime
+func RandomChurn(rnd *rand.Rand, ids []discover.NodeID, duration, interval, downtime time.Duration) []*ScenarioEvent {
+	var events []*ScenarioEvent
+	upAt := make(map[discover.NodeID]time.Duration)
+	for at := interval; at+downtime <= duration; at += interval {
+		var up []discover.NodeID
+		for _, id := range ids {
+			if upAt[id] <= at {
+				up = append(up, id)
+			}
+		}
+		if len(up) == 0 {
+			continue
+		}
+		id := up[rnd.Intn(len(up))]
+		upAt[id] = at + downtime
+		events = append(events, NodeDown(at, id), NodeUp(at+downtime, id))
+	}
+	return events
+}
+
+// RunScenario executes the events in the order of their offsets, it returns
+// when all events are executed, an event fails or the context is done
+func (net *Network) RunScenario(ctx context.Context, events []*ScenarioEvent) error {
+	events = append([]*ScenarioEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At < events[j].At
+	})
+	clock := net.Clock()
+	start := clock.Now()
+	for _, ev := range events {
+		wait := start.Add(ev.At).Sub(clock.Now())
+		timer := clock.After(wait)
+		if vc, ok := clock.(*VirtualClock); ok {
+			// virtual time jumps to the event instead of waiting for it
+			vc.Advance(wait)
+		}
+		select {
+		case <-timer:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		log.Debug("scenario event", "at", ev.At, "event", ev.Label)
+		if err := ev.Action(net); err != nil {
+			if net.Deterministic {
+				return fmt.Errorf("scenario event %q at %v (seed %d): %v", ev.Label, ev.At, net.Seed, err)
+			}
+			return fmt.Errorf("scenario event %q at %v: %v", ev.Label, ev.At, err)
+		}
+	}
+	return nil
+}
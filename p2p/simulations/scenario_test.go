@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+func newScenarioNetwork(t *testing.T, n int) (*Network, []discover.NodeID) {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"test": newTestService,
+	})
+	network := NewNetwork(adapter, &NetworkConfig{
+		DefaultService: "test",
+	})
+	ids := make([]discover.NodeID, n)
+	for i := range ids {
+		node, err := network.NewNodeWithConfig(adapters.RandomNodeConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = node.ID()
+	}
+	return network, ids
+}
+
+// waitConn waits until the connection between two nodes is up or down
+func waitConn(t *testing.T, network *Network, one, other discover.NodeID, up bool) {
+	for i := 0; i < 100; i++ {
+		if conn := network.GetConn(one, other); conn != nil && conn.Up == up {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for connection %s-%s up: %v", one.TerminalString(), other.TerminalString(), up)
+}
+
+func TestNetworkPartition(t *testing.T) {
+	network, ids := newScenarioNetwork(t, 3)
+	defer network.Shutdown()
+	a, b, c := ids[0], ids[1], ids[2]
+
+	if err := network.Connect(a, b); err != nil {
+		t.Fatal(err)
+	}
+	waitConn(t, network, a, b, true)
+
+	// the connection across the partition is closed
+	if err := network.Partition([]discover.NodeID{a}, []discover.NodeID{b, c}); err != nil {
+		t.Fatal(err)
+	}
+	waitConn(t, network, a, b, false)
+
+	// nodes within a group can connect
+	if err := network.Connect(b, c); err != nil {
+		t.Fatal(err)
+	}
+	waitConn(t, network, b, c, true)
+
+	if err := network.Heal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.Connect(a, c); err != nil {
+		t.Fatal(err)
+	}
+	waitConn(t, network, a, c, true)
+}
+
+func TestRunScenario(t *testing.T) {
+	network, ids := newScenarioNetwork(t, 4)
+	defer network.Shutdown()
+
+	events := RandomChurn(rand.New(rand.NewSource(1)), ids, time.Second, 100*time.Millisecond, 200*time.Millisecond)
+	if len(events) == 0 {
+		t.Fatal("expected churn events")
+	}
+	// stopping a node which is down fails the scenario, so running it
+	// checks the churn schedule too
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := network.RunScenario(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if !network.GetNode(id).Up {
+			t.Fatalf("expected node %v to be up after the churn", id)
+		}
+	}
+
+	// failing events stop the scenario
+	err := network.RunScenario(ctx, []*ScenarioEvent{NodeUp(0, ids[0])})
+	if err == nil {
+		t.Fatal("expected error starting a running node")
+	}
+}
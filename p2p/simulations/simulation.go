@@ -41,8 +41,9 @@ func NewSimulation(network *Network) *Simulation {
 func (s *Simulation) Run(ctx context.Context, step *Step) (result *StepResult) {
 	result = newStepResult()
 
-	result.StartedAt = time.Now()
-	defer func() { result.FinishedAt = time.Now() }()
+	clock := s.network.Clock()
+	result.StartedAt = clock.Now()
+	defer func() { result.FinishedAt = clock.Now() }()
 
 	// watch network events for the duration of the step
 	stop := s.watchNetwork(result)
@@ -79,7 +80,7 @@ func (s *Simulation) Run(ctx context.Context, step *Step) (result *StepResult) {
 				return
 			}
 			if pass {
-				result.Passes[id] = time.Now()
+				result.Passes[id] = clock.Now()
 			}
 		case <-ctx.Done():
 			result.Error = ctx.Err()
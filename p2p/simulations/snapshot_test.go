@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// TestSnapshotFile tests that a snapshot saved to disk restores both the
+// connections and the service state of the network
+func TestSnapshotFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	network := NewNetwork(adapters.NewSimAdapter(testServices), &NetworkConfig{
+		DefaultService: "test",
+	})
+	defer network.Shutdown()
+
+	nodeCount := 3
+	ids := make([]discover.NodeID, nodeCount)
+	for i := range ids {
+		node, err := network.NewNodeWithConfig(adapters.RandomNodeConfig())
+		if err != nil {
+			t.Fatalf("error creating node: %s", err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatalf("error starting node: %s", err)
+		}
+		ids[i] = node.ID()
+	}
+	for i := 1; i < nodeCount; i++ {
+		if err := network.Connect(ids[0], ids[i]); err != nil {
+			t.Fatalf("error connecting nodes: %s", err)
+		}
+	}
+	if err := waitPeerCounts(network, ids); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		client, err := network.GetNode(id).Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Call(nil, "test_setState", []byte(fmt.Sprintf("state%d", i))); err != nil {
+			t.Fatalf("error setting service state: %s", err)
+		}
+	}
+
+	snap, err := network.Snapshot()
+	if err != nil {
+		t.Fatalf("error creating snapshot: %s", err)
+	}
+
+	for _, name := range []string{"snapshot.json", "snapshot.json.gz"} {
+		path := filepath.Join(dir, name)
+		if err := snap.Save(path); err != nil {
+			t.Fatalf("%s: error saving snapshot: %s", name, err)
+		}
+		loaded, err := ReadSnapshot(path)
+		if err != nil {
+			t.Fatalf("%s: error reading snapshot: %s", name, err)
+		}
+
+		restored := NewNetwork(adapters.NewSimAdapter(testServices), &NetworkConfig{
+			DefaultService: "test",
+		})
+		if err := restored.Load(loaded); err != nil {
+			restored.Shutdown()
+			t.Fatalf("%s: error loading snapshot: %s", name, err)
+		}
+		for i := 1; i < nodeCount; i++ {
+			if restored.GetConn(ids[0], ids[i]) == nil {
+				restored.Shutdown()
+				t.Fatalf("%s: connection %d missing after restore", name, i)
+			}
+		}
+		if err := waitPeerCounts(restored, ids); err != nil {
+			restored.Shutdown()
+			t.Fatalf("%s: %s", name, err)
+		}
+		for i, id := range ids {
+			client, err := restored.GetNode(id).Client()
+			if err != nil {
+				restored.Shutdown()
+				t.Fatal(err)
+			}
+			var state []byte
+			if err := client.Call(&state, "test_getState"); err != nil {
+				restored.Shutdown()
+				t.Fatalf("%s: error getting service state: %s", name, err)
+			}
+			if expected := fmt.Sprintf("state%d", i); string(state) != expected {
+				restored.Shutdown()
+				t.Fatalf("%s: expected state %q, got %q", name, expected, state)
+			}
+		}
+		restored.Shutdown()
+	}
+}
+
+// waitPeerCounts waits until the test services of a star network with the
+// first node at the center completed their handshakes
+func waitPeerCounts(network *Network, ids []discover.NodeID) error {
+	deadline := time.Now().Add(10 * time.Second)
+	for i, id := range ids {
+		expected := int64(1)
+		if i == 0 {
+			expected = int64(len(ids) - 1)
+		}
+		client, err := network.GetNode(id).Client()
+		if err != nil {
+			return err
+		}
+		for {
+			var count int64
+			if err := client.Call(&count, "test_peerCount"); err != nil {
+				return err
+			}
+			if count == expected {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("node %d: expected %d peers, got %d", i, expected, count)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,498 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/sctx"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/encryption"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Access manifests grant access to content to the holders of a password or
+// of private keys. An access manifest has a single entry with the encrypted
+// address of the root manifest of the content, the access entry of the
+// entry tells how the key the address is encrypted with is derived:
+//
+//   - pass: from a password with scrypt
+//   - pk: from the shared secret of the publisher and a single grantee
+//   - act: the key is random and stored in an access control trie (ACT)
+//     manifest, encrypted for each grantee with the shared secret of the
+//     grantee and the publisher, at a lookup key derived from the secret
+//
+// The encrypted address is followed by a checksum, so wrong credentials are
+// told apart from content which cannot be retrieved.
+
+// AccessType is the way the access key of an access manifest is derived
+type AccessType string
+
+const (
+	AccessTypePass AccessType = "pass"
+	AccessTypePK   AccessType = "pk"
+	AccessTypeACT  AccessType = "act"
+)
+
+const (
+	// AccessSaltLength is the length of the salts of access entries
+	AccessSaltLength = 32
+	// accessChecksumLength is the length of the checksum following the
+	// encrypted address of an access manifest
+	accessChecksumLength = 4
+)
+
+// ErrAccessDenied is returned when the credentials of a request do not grant
+// access to an access manifest
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessEntry tells how the key the address in an access manifest is
+// encrypted with is derived
+type AccessEntry struct {
+	Type      AccessType `json:"type"`
+	Publisher string     `json:"publisher,omitempty"` // hex encoded compressed public key
+	Salt      []byte     `json:"salt"`
+	Act       string     `json:"act,omitempty"` // address of the ACT manifest
+	KdfParams *KdfParams `json:"kdf_params,omitempty"`
+}
+
+// KdfParams are the scrypt parameters of password access entries
+type KdfParams struct {
+	N int `json:"n"`
+	P int `json:"p"`
+	R int `json:"r"`
+}
+
+// DefaultKdfParams are the scrypt parameters of the keystore, deriving a key
+// takes about a second
+var DefaultKdfParams = &KdfParams{N: 262144, P: 1, R: 8}
+
+// NewSessionKeyPK returns the session key of a private key and the public
+// key of the other party, the publisher and a grantee derive the same key
+func NewSessionKeyPK(private *ecdsa.PrivateKey, public *ecdsa.PublicKey, salt []byte) ([]byte, error) {
+	shared, err := ecies.ImportECDSA(private).GenerateShared(ecies.ImportECDSAPublic(public), 16, 16)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(shared, salt), nil
+}
+
+// NewSessionKeyPassword returns the session key derived from the password
+// with the salt and scrypt parameters of the access entry
+func NewSessionKeyPassword(password string, ae *AccessEntry) ([]byte, error) {
+	if ae.KdfParams == nil {
+		return nil, errors.New("access entry has no scrypt parameters")
+	}
+	return scrypt.Key([]byte(password), ae.Salt, ae.KdfParams.N, ae.KdfParams.R, ae.KdfParams.P, encryption.KeyLength)
+}
+
+// NewAccessEntryPassword returns a password access entry and the access key
+// derived from the password
+func NewAccessEntryPassword(password string, kdf *KdfParams) (*AccessEntry, []byte, error) {
+	salt, err := newAccessSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+	ae := &AccessEntry{
+		Type:      AccessTypePass,
+		Salt:      salt,
+		KdfParams: kdf,
+	}
+	key, err := NewSessionKeyPassword(password, ae)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ae, key, nil
+}
+
+// NewAccessEntryPK returns an access entry granting the holder of the private
+// key of the grantee access and the access key
+func NewAccessEntryPK(publisher *ecdsa.PrivateKey, grantee *ecdsa.PublicKey) (*AccessEntry, []byte, error) {
+	salt, err := newAccessSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := NewSessionKeyPK(publisher, grantee, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &AccessEntry{
+		Type:      AccessTypePK,
+		Publisher: hex.EncodeToString(crypto.CompressPubkey(&publisher.PublicKey)),
+		Salt:      salt,
+	}, key, nil
+}
+
+// NewAccessEntryACT returns an access entry granting the grantees of the ACT
+// stored at the given address access
+func NewAccessEntryACT(publisher *ecdsa.PrivateKey, act *ACT, actAddr storage.Address) *AccessEntry {
+	return &AccessEntry{
+		Type:      AccessTypeACT,
+		Publisher: hex.EncodeToString(crypto.CompressPubkey(&publisher.PublicKey)),
+		Salt:      act.Salt,
+		Act:       actAddr.Hex(),
+	}
+}
+
+// NewAccessManifest returns an access manifest granting access to the
+// manifest with the given address to the holders of the access key
+func NewAccessManifest(ref storage.Address, accessKey []byte, ae *AccessEntry) (*Manifest, error) {
+	data := append(append([]byte{}, ref...), crypto.Keccak256(ref)[:accessChecksumLength]...)
+	encrypted, err := accessEncrypt(data, accessKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Entries: []ManifestEntry{{
+			Hash:        hex.EncodeToString(encrypted),
+			ContentType: ManifestType,
+			ModTime:     time.Now(),
+			Access:      ae,
+		}},
+	}, nil
+}
+
+// AccessManifestEntry returns the entry of an access manifest, or nil if
+// the manifest is not an access manifest
+func AccessManifestEntry(m *Manifest) *ManifestEntry {
+	if len(m.Entries) != 1 || m.Entries[0].Path != "" || m.Entries[0].Access == nil {
+		return nil
+	}
+	return &m.Entries[0]
+}
+
+// DecryptAccessEntry returns the address the entry of an access manifest
+// points to decrypted with the access key, ErrAccessDenied if the key is
+// wrong
+func DecryptAccessEntry(entry *ManifestEntry, accessKey []byte) (storage.Address, error) {
+	encrypted, err := hex.DecodeString(entry.Hash)
+	if err != nil || len(encrypted) <= accessChecksumLength {
+		return nil, fmt.Errorf("invalid access manifest reference %q", entry.Hash)
+	}
+	data, err := accessEncrypt(encrypted, accessKey)
+	if err != nil {
+		return nil, err
+	}
+	ref := data[:len(data)-accessChecksumLength]
+	if !bytes.Equal(crypto.Keccak256(ref)[:accessChecksumLength], data[len(ref):]) {
+		return nil, ErrAccessDenied
+	}
+	return storage.Address(ref), nil
+}
+
+// ACT is an access control trie, the grantees of the content encrypted with
+// its access key
+type ACT struct {
+	Salt      []byte
+	AccessKey []byte
+	Grantees  []*ecdsa.PublicKey
+}
+
+// NewACT returns an access control trie granting the given keys access with a
+// new access key
+func NewACT(grantees []*ecdsa.PublicKey) (*ACT, error) {
+	act := &ACT{}
+	if err := act.rekey(); err != nil {
+		return nil, err
+	}
+	act.Grant(grantees...)
+	return act, nil
+}
+
+// Grant adds the given keys to the grantees and returns the number of keys
+// which were not granted access before
+func (act *ACT) Grant(keys ...*ecdsa.PublicKey) int {
+	var added int
+	for _, key := range keys {
+		if act.index(key) < 0 {
+			act.Grantees = append(act.Grantees, key)
+			added++
+		}
+	}
+	return added
+}
+
+// Revoke removes the given keys from the grantees and returns the number of
+// keys removed. The access key is replaced if a key was removed, so the
+// content has to be encrypted again.
+func (act *ACT) Revoke(keys ...*ecdsa.PublicKey) (int, error) {
+	var removed int
+	for _, key := range keys {
+		if i := act.index(key); i >= 0 {
+			act.Grantees = append(act.Grantees[:i], act.Grantees[i+1:]...)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, act.rekey()
+}
+
+func (act *ACT) index(key *ecdsa.PublicKey) int {
+	compressed := crypto.CompressPubkey(key)
+	for i, grantee := range act.Grantees {
+		if bytes.Equal(compressed, crypto.CompressPubkey(grantee)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (act *ACT) rekey() (err error) {
+	if act.Salt, err = newAccessSalt(); err != nil {
+		return err
+	}
+	act.AccessKey, err = encryption.GenerateRandomKey()
+	return err
+}
+
+// Manifest returns the ACT manifest of the trie, which has an entry for each
+// grantee with the access key encrypted for the grantee. The entry of the
+// publisher also holds the list of grantees, so the publisher can change the
+// trie later (see OpenACT).
+func (act *ACT) Manifest(publisher *ecdsa.PrivateKey) (*Manifest, error) {
+	publisherKey := crypto.CompressPubkey(&publisher.PublicKey)
+	grantees := make([]byte, 0, len(act.Grantees)*len(publisherKey))
+	m := &Manifest{}
+	for _, grantee := range act.Grantees {
+		compressed := crypto.CompressPubkey(grantee)
+		grantees = append(grantees, compressed...)
+		if bytes.Equal(compressed, publisherKey) {
+			continue
+		}
+		entry, _, err := act.entry(publisher, grantee)
+		if err != nil {
+			return nil, err
+		}
+		m.Entries = append(m.Entries, *entry)
+	}
+	entry, sessionKey, err := act.entry(publisher, &publisher.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// the list is prefixed with a version byte, so it is not empty if
+	// there are no grantees
+	if entry.Data, err = accessEncrypt(append([]byte{0}, grantees...), actListKey(sessionKey)); err != nil {
+		return nil, err
+	}
+	m.Entries = append(m.Entries, *entry)
+	return m, nil
+}
+
+// entry returns the ACT manifest entry of the grantee and its session key
+func (act *ACT) entry(publisher *ecdsa.PrivateKey, grantee *ecdsa.PublicKey) (*ManifestEntry, []byte, error) {
+	sessionKey, err := NewSessionKeyPK(publisher, grantee, act.Salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	encrypted, err := accessEncrypt(act.AccessKey, actAccessKeyKey(sessionKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ManifestEntry{
+		Path: hex.EncodeToString(actLookupKey(sessionKey)),
+		Hash: hex.EncodeToString(encrypted),
+	}, sessionKey, nil
+}
+
+// OpenACT returns the trie of an ACT manifest created by the publisher with
+// the given salt
+func OpenACT(m *Manifest, publisher *ecdsa.PrivateKey, salt []byte) (*ACT, error) {
+	sessionKey, err := NewSessionKeyPK(publisher, &publisher.PublicKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	entry := actEntry(m, sessionKey)
+	if entry == nil || entry.Data == nil {
+		return nil, fmt.Errorf("the ACT was not published with the key %x", crypto.CompressPubkey(&publisher.PublicKey))
+	}
+	accessKey, err := actDecryptAccessKey(entry, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	list, err := accessEncrypt(entry.Data, actListKey(sessionKey))
+	if err != nil {
+		return nil, err
+	}
+	act := &ACT{
+		Salt:      salt,
+		AccessKey: accessKey,
+	}
+	n := len(crypto.CompressPubkey(&publisher.PublicKey))
+	for list = list[1:]; len(list) > 0; {
+		if len(list) < n {
+			return nil, errors.New("invalid ACT grantee list")
+		}
+		grantee, err := crypto.DecompressPubkey(list[:n])
+		if err != nil {
+			return nil, err
+		}
+		act.Grantees = append(act.Grantees, grantee)
+		list = list[n:]
+	}
+	return act, nil
+}
+
+// actEntry returns the entry of the ACT manifest of the holder of the
+// session key, or nil if the holder is not a grantee
+func actEntry(m *Manifest, sessionKey []byte) *ManifestEntry {
+	path := hex.EncodeToString(actLookupKey(sessionKey))
+	for i := range m.Entries {
+		if m.Entries[i].Path == path {
+			return &m.Entries[i]
+		}
+	}
+	return nil
+}
+
+func actDecryptAccessKey(entry *ManifestEntry, sessionKey []byte) ([]byte, error) {
+	encrypted, err := hex.DecodeString(entry.Hash)
+	if err != nil || len(encrypted) != encryption.KeyLength {
+		return nil, fmt.Errorf("invalid ACT entry %q", entry.Path)
+	}
+	return accessEncrypt(encrypted, actAccessKeyKey(sessionKey))
+}
+
+func actLookupKey(sessionKey []byte) []byte {
+	return crypto.Keccak256(sessionKey, []byte{0})
+}
+
+func actAccessKeyKey(sessionKey []byte) []byte {
+	return crypto.Keccak256(sessionKey, []byte{1})
+}
+
+func actListKey(sessionKey []byte) []byte {
+	return crypto.Keccak256(sessionKey, []byte{2})
+}
+
+// accessEncrypt encrypts or decrypts the data with the key
+func accessEncrypt(data []byte, key []byte) ([]byte, error) {
+	return encryption.New(len(data), 0, sha3.NewKeccak256).Encrypt(data, key)
+}
+
+func newAccessSalt() ([]byte, error) {
+	salt := make([]byte, AccessSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// SetAccessKey sets the private key the node decrypts the access manifests
+// granting it access with
+func (a *API) SetAccessKey(key *ecdsa.PrivateKey) {
+	a.accessKey = key
+}
+
+// ResolveAccess returns the address of the manifest the access manifest with
+// the given address grants access to with the password or the access key of
+// the node. The addresses of other manifests are returned unchanged.
+func (a *API) ResolveAccess(ctx context.Context, addr storage.Address, password string) (storage.Address, error) {
+	m, err := a.fetchManifest(ctx, addr)
+	if err != nil {
+		// the lookup of the content reports missing or invalid manifests
+		return addr, nil
+	}
+	entry := AccessManifestEntry(m)
+	if entry == nil {
+		return addr, nil
+	}
+	log.Debug("resolving access manifest", "ruid", sctx.GetRequestID(ctx), "addr", addr, "type", entry.Access.Type)
+
+	accessKey, err := a.accessManifestKey(ctx, entry.Access, password)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptAccessEntry(entry, accessKey)
+}
+
+// accessManifestKey returns the access key of an access entry derived from the
+// password or the access key of the node
+func (a *API) accessManifestKey(ctx context.Context, ae *AccessEntry, password string) ([]byte, error) {
+	if ae.Type == AccessTypePass {
+		if password == "" {
+			return nil, ErrAccessDenied
+		}
+		return NewSessionKeyPassword(password, ae)
+	}
+	if a.accessKey == nil {
+		return nil, ErrAccessDenied
+	}
+	publisherKey, err := hex.DecodeString(ae.Publisher)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher key %q", ae.Publisher)
+	}
+	publisher, err := crypto.DecompressPubkey(publisherKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher key %q: %v", ae.Publisher, err)
+	}
+	sessionKey, err := NewSessionKeyPK(a.accessKey, publisher, ae.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ae.Type {
+	case AccessTypePK:
+		return sessionKey, nil
+	case AccessTypeACT:
+		act, err := a.fetchManifest(ctx, storage.Address(common.Hex2Bytes(ae.Act)))
+		if err != nil {
+			return nil, err
+		}
+		entry := actEntry(act, sessionKey)
+		if entry == nil {
+			return nil, ErrAccessDenied
+		}
+		return actDecryptAccessKey(entry, sessionKey)
+	default:
+		return nil, fmt.Errorf("unknown access type %q", ae.Type)
+	}
+}
+
+// fetchManifest retrieves and decodes the manifest with the given address
+func (a *API) fetchManifest(ctx context.Context, addr storage.Address) (*Manifest, error) {
+	reader, _ := a.fileStore.Retrieve(ctx, addr)
+	size, err := reader.Size(nil)
+	if err != nil {
+		return nil, &ManifestError{Addr: addr, Err: ErrManifestNotFound, Reason: err.Error()}
+	}
+	if size > manifestSizeLimit {
+		return nil, &ManifestError{Addr: addr, Err: ErrInvalidManifest, Reason: fmt.Sprintf("size of %v bytes exceeds the %v byte limit", size, manifestSizeLimit)}
+	}
+	data := make([]byte, size)
+	if n, err := reader.ReadAt(data, 0); int64(n) < size {
+		return nil, &ManifestError{Addr: addr, Err: ErrManifestNotFound, Reason: fmt.Sprintf("retrieval cut short: %v", err)}
+	}
+	m, _, err := DecodeManifest(data)
+	if err != nil {
+		return nil, &ManifestError{Addr: addr, Err: ErrInvalidManifest, Reason: err.Error()}
+	}
+	return m, nil
+}
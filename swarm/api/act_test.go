@@ -0,0 +1,218 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// testKdfParams keep the tests fast
+var testKdfParams = &KdfParams{N: 16, P: 1, R: 8}
+
+func storeTestManifest(t *testing.T, api *API, m *Manifest) storage.Address {
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.TODO()
+	addr, wait, err := api.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func newTestKeys(t *testing.T, n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func checkAccess(t *testing.T, api *API, addr, expected storage.Address, key *ecdsa.PrivateKey, password string) {
+	t.Helper()
+	api.SetAccessKey(key)
+	resolved, err := api.ResolveAccess(context.TODO(), addr, password)
+	if expected == nil {
+		if err != ErrAccessDenied {
+			t.Fatalf("expected access to be denied, got %v, %v", resolved, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resolved, expected) {
+		t.Fatalf("expected access manifest to resolve to %v, got %v", expected, resolved)
+	}
+}
+
+// TestAccessPassword tests that a password access manifest is only resolved
+// with the password
+func TestAccessPassword(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		ref, _, err := api.Put(context.TODO(), "hello", "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ae, key, err := NewAccessEntryPassword("secret", testKdfParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := NewAccessManifest(ref, key, ae)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := storeTestManifest(t, api, m)
+
+		checkAccess(t, api, addr, ref, nil, "secret")
+		checkAccess(t, api, addr, nil, nil, "wrong")
+		checkAccess(t, api, addr, nil, nil, "")
+
+		// other manifests are not changed
+		checkAccess(t, api, ref, ref, nil, "")
+	})
+}
+
+// TestAccessPK tests that a pk access manifest is only resolved with the key
+// of the grantee
+func TestAccessPK(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		keys := newTestKeys(t, 3)
+		publisher, grantee, other := keys[0], keys[1], keys[2]
+		ref, _, err := api.Put(context.TODO(), "hello", "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ae, key, err := NewAccessEntryPK(publisher, &grantee.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := NewAccessManifest(ref, key, ae)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := storeTestManifest(t, api, m)
+
+		checkAccess(t, api, addr, ref, grantee, "")
+		checkAccess(t, api, addr, nil, other, "")
+		checkAccess(t, api, addr, nil, nil, "")
+	})
+}
+
+// TestAccessACT tests that an ACT access manifest is resolved with the keys
+// of the grantees, and that grants and revocations by the publisher change
+// the grantees
+func TestAccessACT(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		keys := newTestKeys(t, 4)
+		publisher, alice, bob, carol := keys[0], keys[1], keys[2], keys[3]
+		ref, _, err := api.Put(context.TODO(), "hello", "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publish := func(act *ACT) storage.Address {
+			m, err := act.Manifest(publisher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actAddr := storeTestManifest(t, api, m)
+			m, err = NewAccessManifest(ref, act.AccessKey, NewAccessEntryACT(publisher, act, actAddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return storeTestManifest(t, api, m)
+		}
+
+		act, err := NewACT([]*ecdsa.PublicKey{&alice.PublicKey, &bob.PublicKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := publish(act)
+		checkAccess(t, api, addr, ref, alice, "")
+		checkAccess(t, api, addr, ref, bob, "")
+		checkAccess(t, api, addr, ref, publisher, "")
+		checkAccess(t, api, addr, nil, carol, "")
+
+		// the publisher recovers the grantees from the published trie
+		m, err := api.fetchManifest(context.TODO(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ae := AccessManifestEntry(m).Access
+		actManifest, err := api.fetchManifest(context.TODO(), storage.Address(hexBytes(t, ae.Act)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := OpenACT(actManifest, publisher, ae.Salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(opened.AccessKey, act.AccessKey) || len(opened.Grantees) != 2 {
+			t.Fatalf("expected the trie to be opened with its access key and 2 grantees, got %d grantees", len(opened.Grantees))
+		}
+		if _, err := OpenACT(actManifest, alice, ae.Salt); err == nil {
+			t.Fatal("expected a grantee not to open the trie")
+		}
+
+		if added := opened.Grant(&carol.PublicKey, &alice.PublicKey); added != 1 {
+			t.Fatalf("expected 1 key to be granted, got %d", added)
+		}
+		addr = publish(opened)
+		checkAccess(t, api, addr, ref, carol, "")
+		checkAccess(t, api, addr, ref, alice, "")
+
+		removed, err := opened.Revoke(&alice.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if removed != 1 {
+			t.Fatalf("expected 1 key to be revoked, got %d", removed)
+		}
+		if bytes.Equal(opened.AccessKey, act.AccessKey) {
+			t.Fatal("expected the access key to be replaced")
+		}
+		addr = publish(opened)
+		checkAccess(t, api, addr, nil, alice, "")
+		checkAccess(t, api, addr, ref, bob, "")
+		checkAccess(t, api, addr, ref, carol, "")
+	})
+}
+
+func hexBytes(t *testing.T, s string) []byte {
+	addr, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
@@ -18,15 +18,17 @@ package api
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 
 	"bytes"
-	"mime"
 	"path/filepath"
 	"time"
 
@@ -36,6 +38,8 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/multihash"
+	"github.com/ethereum/go-ethereum/swarm/sctx"
+	"github.com/ethereum/go-ethereum/swarm/singleflight"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 	"github.com/ethereum/go-ethereum/swarm/storage/mru"
 )
@@ -48,6 +52,7 @@ var (
 	apiGetCount        = metrics.NewRegisteredCounter("api.get.count", nil)
 	apiGetNotFound     = metrics.NewRegisteredCounter("api.get.notfound", nil)
 	apiGetHTTP300      = metrics.NewRegisteredCounter("api.get.http.300", nil)
+	apiGetInline       = metrics.NewRegisteredCounter("api.get.inline", nil)
 	apiModifyCount     = metrics.NewRegisteredCounter("api.modify.count", nil)
 	apiModifyFail      = metrics.NewRegisteredCounter("api.modify.fail", nil)
 	apiAddFileCount    = metrics.NewRegisteredCounter("api.addfile.count", nil)
@@ -56,9 +61,26 @@ var (
 	apiRmFileFail      = metrics.NewRegisteredCounter("api.removefile.fail", nil)
 	apiAppendFileCount = metrics.NewRegisteredCounter("api.appendfile.count", nil)
 	apiAppendFileFail  = metrics.NewRegisteredCounter("api.appendfile.fail", nil)
+	apiPrefetchCount   = metrics.NewRegisteredCounter("api.prefetch.count", nil)
+	apiPrefetchFail    = metrics.NewRegisteredCounter("api.prefetch.fail", nil)
+	apiGetShared       = metrics.NewRegisteredCounter("api.get.shared", nil)
 	apiGetInvalid      = metrics.NewRegisteredCounter("api.get.invalid", nil)
+	apiSelectCount     = metrics.NewRegisteredCounter("api.select.count", nil)
+	apiSelectFallback  = metrics.NewRegisteredCounter("api.select.fallback", nil)
 )
 
+// SwarmHashHeader is the HTTP header selecting the chunk hash function of an
+// upload, one of storage.SupportedHashes
+const SwarmHashHeader = "X-Swarm-Hash"
+
+// SwarmSparseHeader is the HTTP header selecting whether an upload is stored
+// sparse, see storage.WithSparse
+const SwarmSparseHeader = "X-Swarm-Sparse"
+
+// lookupTimeout is the time a manifest lookup shared by concurrent requests
+// is given, independently of the requests waiting for it
+const lookupTimeout = time.Minute
+
 // Resolver interface resolve a domain name to a hash using ENS
 type Resolver interface {
 	Resolve(string) (common.Hash, error)
@@ -214,6 +236,29 @@ type API struct {
 	resource  *mru.Handler
 	fileStore *storage.FileStore
 	dns       Resolver
+	names     *NameRegistry // resolves names ahead of dns, nil if not set
+
+	// files up to this size are stored inline in the entries of uploaded
+	// manifests, 0 disables inlining
+	inlineThreshold int64
+	// encoding version of new manifests
+	manifestVersion int
+	// maximum number of entries of a manifest including the entries of the
+	// submanifests it embeds, 0 stores every submanifest separately
+	manifestFanout int
+
+	// number of chunks retrieved concurrently by a prefetch
+	prefetchConcurrency int
+	tags                *storage.Tags
+	prefetchMu          sync.Mutex
+	prefetches          map[string]*storage.Tag // last prefetch by root address
+	lookups             singleflight.Group      // manifest lookups in flight
+
+	// key the access manifests granting the node access are decrypted with
+	accessKey *ecdsa.PrivateKey
+
+	// detector of the content types of uploaded and served files
+	mime *MimeDetector
 }
 
 // NewAPI the api constructor initialises a new API instance.
@@ -222,10 +267,48 @@ func NewAPI(fileStore *storage.FileStore, dns Resolver, resourceHandler *mru.Han
 		fileStore: fileStore,
 		dns:       dns,
 		resource:  resourceHandler,
+
+		tags:       storage.NewTags(),
+		prefetches: make(map[string]*storage.Tag),
+		mime:       NewMimeDetector(),
 	}
 	return
 }
 
+// SetInlineThreshold sets the size up to which the content of files uploaded
+// to manifests is stored inline in their manifest entries
+func (a *API) SetInlineThreshold(size int64) {
+	a.inlineThreshold = size
+}
+
+// SetManifestVersion sets the encoding version of new manifests, existing
+// manifests keep the encoding they were read in when they are modified
+func (a *API) SetManifestVersion(version int) error {
+	if version != ManifestVersion1 && version != ManifestVersion2 {
+		return fmt.Errorf("unsupported manifest version %d", version)
+	}
+	a.manifestVersion = version
+	return nil
+}
+
+// SetManifestFanout sets the maximum number of entries of written manifests
+// including the entries of embedded submanifests, larger submanifests are
+// stored separately and loaded on demand
+func (a *API) SetManifestFanout(fanout int) {
+	a.manifestFanout = fanout
+}
+
+// SetPrefetchConcurrency sets the number of chunks retrieved concurrently
+// when content is prefetched, 0 for the default
+func (a *API) SetPrefetchConcurrency(n int) {
+	a.prefetchConcurrency = n
+}
+
+// Tags returns the registry of the tags of the operations of the API
+func (a *API) Tags() *storage.Tags {
+	return a.tags
+}
+
 // Upload to be used only in TEST
 func (a *API) Upload(ctx context.Context, uploadDir, index string, toEncrypt bool) (hash string, err error) {
 	fs := NewFileSystem(a)
@@ -238,19 +321,161 @@ func (a *API) Retrieve(ctx context.Context, addr storage.Address) (reader storag
 	return a.fileStore.Retrieve(ctx, addr)
 }
 
+// GetChunk returns the data of the chunk with the given address
+func (a *API) GetChunk(ctx context.Context, addr storage.Address) ([]byte, error) {
+	chunk, err := a.fileStore.Get(addr)
+	if err != nil {
+		return nil, &storage.ChunkError{Op: "get", Addr: addr, Err: err}
+	}
+	return chunk.SData, nil
+}
+
+// MissingChunks returns the addresses of the chunks of the content with the
+// given root which are not available locally, chunks are only resolved from
+// the local store
+//
+// If the root is a manifest which is available locally, the content of its
+// entries and submanifests is checked as well.
+func (a *API) MissingChunks(ctx context.Context, root storage.Address) ([]storage.Address, error) {
+	missing, err := a.fileStore.MissingChunks(ctx, root)
+	if err != nil || len(missing) > 0 {
+		return missing, err
+	}
+	local := a.fileStore.Local()
+	trie, err := loadManifest(ctx, local, root, nil)
+	if err != nil {
+		// not a manifest
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	walker := &ManifestWalker{a, trie, nil}
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		if entry.Hash == "" || seen[entry.Hash] {
+			return nil
+		}
+		seen[entry.Hash] = true
+		addr := storage.Address(common.Hex2Bytes(entry.Hash))
+		if entry.ContentType == ResourceContentType {
+			// only the root chunk of a resource is part of the content
+			if _, err := local.Get(addr); err != nil {
+				missing = append(missing, addr)
+			}
+			return nil
+		}
+		m, err := a.fileStore.MissingChunks(ctx, addr)
+		if err != nil {
+			return err
+		}
+		missing = append(missing, m...)
+		if len(m) > 0 && entry.ContentType == ManifestType {
+			return ErrSkipManifest
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// Prefetch starts retrieving all the chunks of the content with the given
+// root into the local store and returns the tag following its progress.
+// If the root is a manifest, the content of its entries and submanifests is
+// retrieved as well. A prefetch of a root which is in progress is not
+// restarted, its tag is returned instead.
+func (a *API) Prefetch(ctx context.Context, root storage.Address) (*storage.Tag, error) {
+	apiPrefetchCount.Inc(1)
+	a.prefetchMu.Lock()
+	defer a.prefetchMu.Unlock()
+	if tag, ok := a.prefetches[root.Hex()]; ok && !tag.IsDone() {
+		return tag, nil
+	}
+	tag := a.tags.New("prefetch", root)
+	a.prefetches[root.Hex()] = tag
+
+	// the prefetch outlives the request which started it
+	go func() {
+		err := a.prefetch(context.Background(), root, tag)
+		if err != nil {
+			apiPrefetchFail.Inc(1)
+			log.Warn("prefetch failed", "root", root, "err", err)
+		}
+		tag.Done(err)
+	}()
+	return tag, nil
+}
+
+// PrefetchTag returns the tag of the last prefetch of the given root
+func (a *API) PrefetchTag(root storage.Address) (*storage.Tag, bool) {
+	a.prefetchMu.Lock()
+	defer a.prefetchMu.Unlock()
+	tag, ok := a.prefetches[root.Hex()]
+	return tag, ok
+}
+
+func (a *API) prefetch(ctx context.Context, root storage.Address, tag *storage.Tag) error {
+	if err := a.fileStore.Prefetch(ctx, root, a.prefetchConcurrency, tag); err != nil {
+		return err
+	}
+	walker, err := a.NewManifestWalker(ctx, root, nil)
+	if err != nil {
+		// not a manifest
+		return nil
+	}
+
+	seen := map[string]bool{root.Hex(): true}
+	return walker.Walk(func(entry *ManifestEntry) error {
+		if entry.Hash == "" || seen[entry.Hash] {
+			return nil
+		}
+		seen[entry.Hash] = true
+		addr := storage.Address(common.Hex2Bytes(entry.Hash))
+		if entry.ContentType == ResourceContentType {
+			// only the root chunk of a resource is part of the content
+			tag.Inc(storage.StateTotal)
+			if _, err := a.fileStore.Get(addr); err != nil {
+				tag.Inc(storage.StateFailed)
+				return err
+			}
+			tag.Inc(storage.StateStored)
+			return nil
+		}
+		return a.fileStore.Prefetch(ctx, addr, a.prefetchConcurrency, tag)
+	})
+}
+
 // Store wraps the Store API call of the embedded FileStore
 func (a *API) Store(ctx context.Context, data io.Reader, size int64, toEncrypt bool) (addr storage.Address, wait func(ctx context.Context) error, err error) {
-	log.Debug("api.store", "size", size)
+	log.Debug("api.store", "ruid", sctx.GetRequestID(ctx), "size", size)
 	return a.fileStore.Store(ctx, data, size, toEncrypt)
 }
 
+// StoreWithHash wraps the StoreWithHash API call of the embedded FileStore
+func (a *API) StoreWithHash(ctx context.Context, data io.Reader, size int64, toEncrypt bool, hash string) (addr storage.Address, wait func(ctx context.Context) error, err error) {
+	log.Debug("api.store", "ruid", sctx.GetRequestID(ctx), "size", size, "hash", hash)
+	return a.fileStore.StoreWithHash(ctx, data, size, toEncrypt, hash)
+}
+
+// Append wraps the Append API call of the embedded FileStore
+func (a *API) Append(ctx context.Context, addr storage.Address, data io.Reader) (newAddr storage.Address, wait func(ctx context.Context) error, err error) {
+	log.Debug("api.append", "ruid", sctx.GetRequestID(ctx), "addr", addr)
+	return a.fileStore.Append(ctx, addr, data)
+}
+
+// Patch wraps the Patch API call of the embedded FileStore
+func (a *API) Patch(ctx context.Context, addr storage.Address, data []byte, off int64) (newAddr storage.Address, wait func(ctx context.Context) error, err error) {
+	log.Debug("api.patch", "ruid", sctx.GetRequestID(ctx), "addr", addr, "offset", off, "size", len(data))
+	return a.fileStore.Patch(ctx, addr, data, off)
+}
+
 // ErrResolve is returned when an URI cannot be resolved from ENS.
 type ErrResolve error
 
 // Resolve resolves a URI to an Address using the MultiResolver.
 func (a *API) Resolve(ctx context.Context, uri *URI) (storage.Address, error) {
 	apiResolveCount.Inc(1)
-	log.Trace("resolving", "uri", uri.Addr)
+	log.Trace("resolving", "ruid", sctx.GetRequestID(ctx), "uri", uri.Addr)
 
 	// if the URI is immutable, check if the address looks like a hash
 	if uri.Immutable() {
@@ -261,6 +486,16 @@ func (a *API) Resolve(ctx context.Context, uri *URI) (storage.Address, error) {
 		return key, nil
 	}
 
+	// names in the name registry take precedence over ENS, hashes are not
+	// looked up as names
+	if a.names != nil && uri.Address() == nil {
+		record, err := a.names.Lookup(ctx, uri.Addr)
+		if err == nil {
+			return storage.Address(common.FromHex(record.Hash)), nil
+		}
+		log.Trace("name registry lookup failed", "ruid", sctx.GetRequestID(ctx), "uri", uri.Addr, "err", err)
+	}
+
 	// if DNS is not configured, check if the address is a hash
 	if a.dns == nil {
 		key := uri.Address()
@@ -312,36 +547,35 @@ func (a *API) Put(ctx context.Context, content string, contentType string, toEnc
 
 // Get uses iterative manifest retrieval and prefix matching
 // to resolve basePath to content using FileStore retrieve
-// it returns a section reader, mimeType, status, the key of the actual content and an error
-func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, mimeType string, status int, contentAddr storage.Address, err error) {
-	log.Debug("api.get", "key", manifestAddr, "path", path)
+// it returns a section reader, mimeType, status, the key of the actual content,
+// the manifest entry the content is served from and an error
+func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, mimeType string, status int, contentAddr storage.Address, served *ManifestEntry, err error) {
+	ruid := sctx.GetRequestID(ctx)
+	log.Debug("api.get", "ruid", ruid, "key", manifestAddr, "path", path)
 	apiGetCount.Inc(1)
-	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil)
+	entry, hashFunc, err := a.lookup(ctx, manifestAddr, path)
 	if err != nil {
 		apiGetNotFound.Inc(1)
 		status = http.StatusNotFound
-		log.Warn(fmt.Sprintf("loadManifestTrie error: %v", err))
+		log.Warn(fmt.Sprintf("loadManifestTrie error: %v", err), "ruid", ruid)
 		return
 	}
 
-	log.Debug("trie getting entry", "key", manifestAddr, "path", path)
-	entry, _ := trie.getEntry(path)
-
 	if entry != nil {
-		log.Debug("trie got entry", "key", manifestAddr, "path", path, "entry.Hash", entry.Hash)
+		log.Debug("trie got entry", "ruid", ruid, "key", manifestAddr, "path", path, "entry.Hash", entry.Hash)
 		// we need to do some extra work if this is a mutable resource manifest
 		if entry.ContentType == ResourceContentType {
 
 			// get the resource root chunk key
-			log.Trace("resource type", "key", manifestAddr, "hash", entry.Hash)
+			log.Trace("resource type", "ruid", ruid, "key", manifestAddr, "hash", entry.Hash)
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 			rsrc, err := a.resource.Load(storage.Address(common.FromHex(entry.Hash)))
 			if err != nil {
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
-				log.Debug(fmt.Sprintf("get resource content error: %v", err))
-				return reader, mimeType, status, nil, err
+				log.Debug(fmt.Sprintf("get resource content error: %v", err), "ruid", ruid)
+				return reader, mimeType, status, nil, nil, err
 			}
 
 			// use this key to retrieve the latest update
@@ -349,8 +583,8 @@ func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string
 			if err != nil {
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
-				log.Debug(fmt.Sprintf("get resource content error: %v", err))
-				return reader, mimeType, status, nil, err
+				log.Debug(fmt.Sprintf("get resource content error: %v", err), "ruid", ruid)
+				return reader, mimeType, status, nil, nil, err
 			}
 
 			// if it's multihash, we will transparently serve the content this multihash points to
@@ -362,8 +596,8 @@ func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string
 				if err != nil {
 					apiGetNotFound.Inc(1)
 					status = http.StatusNotFound
-					log.Warn(fmt.Sprintf("get resource content error: %v", err))
-					return reader, mimeType, status, nil, err
+					log.Warn(fmt.Sprintf("get resource content error: %v", err), "ruid", ruid)
+					return reader, mimeType, status, nil, nil, err
 				}
 
 				// validate that data as multihash
@@ -371,19 +605,19 @@ func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string
 				if err != nil {
 					apiGetInvalid.Inc(1)
 					status = http.StatusUnprocessableEntity
-					log.Warn("invalid resource multihash", "err", err)
-					return reader, mimeType, status, nil, err
+					log.Warn("invalid resource multihash", "ruid", ruid, "err", err)
+					return reader, mimeType, status, nil, nil, err
 				}
 				manifestAddr = storage.Address(decodedMultihash)
-				log.Trace("resource is multihash", "key", manifestAddr)
+				log.Trace("resource is multihash", "ruid", ruid, "key", manifestAddr)
 
 				// get the manifest the multihash digest points to
 				trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil)
 				if err != nil {
 					apiGetNotFound.Inc(1)
 					status = http.StatusNotFound
-					log.Warn(fmt.Sprintf("loadManifestTrie (resource multihash) error: %v", err))
-					return reader, mimeType, status, nil, err
+					log.Warn(fmt.Sprintf("loadManifestTrie (resource multihash) error: %v", err), "ruid", ruid)
+					return reader, mimeType, status, nil, nil, err
 				}
 
 				// finally, get the manifest entry
@@ -392,14 +626,14 @@ func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string
 				if entry == nil {
 					status = http.StatusNotFound
 					apiGetNotFound.Inc(1)
-					err = fmt.Errorf("manifest (resource multihash) entry for '%s' not found", path)
-					log.Trace("manifest (resource multihash) entry not found", "key", manifestAddr, "path", path)
-					return reader, mimeType, status, nil, err
+					err = &ManifestError{Addr: manifestAddr, Path: path, Err: ErrEntryNotFound}
+					log.Trace("manifest (resource multihash) entry not found", "ruid", ruid, "key", manifestAddr, "path", path)
+					return reader, mimeType, status, nil, nil, err
 				}
 
 			} else {
 				// data is returned verbatim since it's not a multihash
-				return rsrc, "application/octet-stream", http.StatusOK, nil, nil
+				return rsrc, "application/octet-stream", http.StatusOK, nil, nil, nil
 			}
 		}
 
@@ -409,21 +643,219 @@ func (a *API) Get(ctx context.Context, manifestAddr storage.Address, path string
 		status = entry.Status
 		if status == http.StatusMultipleChoices {
 			apiGetHTTP300.Inc(1)
-			return nil, entry.ContentType, status, contentAddr, err
+			return nil, entry.ContentType, status, contentAddr, nil, err
 		}
 		mimeType = entry.ContentType
-		log.Debug("content lookup key", "key", contentAddr, "mimetype", mimeType)
-		reader, _ = a.fileStore.Retrieve(ctx, contentAddr)
+		if entry.Data != nil {
+			// inline content is served from the entry, its address is
+			// calculated for use as an identifier without storing it
+			apiGetInline.Inc(1)
+			contentAddr, err = InlineAddress(ctx, entry.Data, hashFunc)
+			if err != nil {
+				status = http.StatusInternalServerError
+				return nil, mimeType, status, nil, nil, err
+			}
+		} else if len(entry.Parts) > 0 {
+			contentAddr = CompositeAddress(entry.Parts)
+		}
+		// the chunks of the content are validated with the hash function
+		// of the manifest when they are delivered
+		reader, _ = a.RetrieveEntry(storage.WithHash(ctx, hashFunc), &entry.ManifestEntry)
+		e := entry.ManifestEntry
+		served = &e
+		if mimeType == "" {
+			mimeType = a.mime.DetectReaderAt(path, reader)
+		}
+		log.Debug("content lookup key", "ruid", ruid, "key", contentAddr, "mimetype", mimeType)
 	} else {
 		// no entry found
 		status = http.StatusNotFound
 		apiGetNotFound.Inc(1)
-		err = fmt.Errorf("manifest entry for '%s' not found", path)
-		log.Trace("manifest entry not found", "key", contentAddr, "path", path)
+		err = &ManifestError{Addr: manifestAddr, Path: path, Err: ErrEntryNotFound}
+		log.Trace("manifest entry not found", "ruid", ruid, "key", contentAddr, "path", path)
 	}
 	return
 }
 
+// manifestLookup is the result of the lookup of a path in a manifest
+type manifestLookup struct {
+	entry    *manifestTrieEntry
+	hashFunc string
+}
+
+// lookup returns the entry of the manifest with the given address on the
+// given path and the hash function of the manifest. Concurrent lookups of
+// the same path, such as the ones of the clients requesting newly published
+// content from a gateway, walk the manifest once and share the entry. The
+// shared walk is not cancelled with the context of any of the callers, each
+// of them stops waiting for it when its own context is done.
+func (a *API) lookup(ctx context.Context, manifestAddr storage.Address, path string) (*manifestTrieEntry, string, error) {
+	ruid := sctx.GetRequestID(ctx)
+	ch := a.lookups.DoChan(manifestAddr.Hex()+"/"+path, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(sctx.SetRequestID(context.Background(), ruid), lookupTimeout)
+		defer cancel()
+		trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("trie getting entry", "ruid", ruid, "key", manifestAddr, "path", path)
+		entry, _ := trie.getEntry(path)
+		return &manifestLookup{entry, trie.hashFunc}, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Shared {
+			apiGetShared.Inc(1)
+		}
+		if res.Err != nil {
+			return nil, "", res.Err
+		}
+		l := res.Val.(*manifestLookup)
+		return l.entry, l.hashFunc, nil
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+}
+
+// SelectRoot returns the address of the first of the given manifests the path
+// can be retrieved from, so that content mirrored under several roots can be
+// served as long as one of them is retrievable
+func (a *API) SelectRoot(ctx context.Context, uris []*URI, path string) (storage.Address, error) {
+	apiSelectCount.Inc(1)
+	var lastErr error
+	for i, uri := range uris {
+		addr := uri.Address()
+		if addr == nil {
+			var err error
+			addr, err = a.Resolve(ctx, uri)
+			if err != nil {
+				lastErr = fmt.Errorf("cannot resolve %s: %v", uri.Addr, err)
+				continue
+			}
+		}
+		// the errors are kept as they are so that the error of the last
+		// root tells why the path cannot be retrieved
+		reader, _, status, _, _, err := a.Get(ctx, addr, path)
+		if err != nil {
+			log.Debug("root not retrievable", "ruid", sctx.GetRequestID(ctx), "root", uri.Addr, "path", path, "err", err)
+			lastErr = err
+			continue
+		}
+		// ambiguous paths are served as a listing, there is no content
+		// to check
+		if status != http.StatusMultipleChoices {
+			if err := probeContent(reader); err != nil {
+				log.Debug("root content not retrievable", "ruid", sctx.GetRequestID(ctx), "root", uri.Addr, "path", path, "err", err)
+				lastErr = err
+				continue
+			}
+		}
+		if i > 0 {
+			apiSelectFallback.Inc(1)
+			log.Debug("falling back to mirror root", "ruid", sctx.GetRequestID(ctx), "root", uri.Addr, "addr", addr, "path", path)
+		}
+		return addr, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no roots given")
+	}
+	return nil, lastErr
+}
+
+// probeContent checks that the content can be retrieved by reading its first
+// and last byte, so that the chunks on both edges of the chunk tree are
+// retrieved and not only its root chunk which holds the size of the content
+func probeContent(reader storage.LazySectionReader) error {
+	size, err := reader.Size(nil)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+	b := make([]byte, 1)
+	for _, off := range []int64{0, size - 1} {
+		if _, err := reader.ReadAt(b, off); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEntry returns the entry of the manifest which Get would serve for the
+// given path
+func (a *API) GetEntry(ctx context.Context, manifestAddr storage.Address, path string) (*ManifestEntry, error) {
+	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil)
+	if err != nil {
+		return nil, err
+	}
+	entry, _ := trie.getEntry(path)
+	if entry == nil {
+		return nil, &ManifestError{Addr: manifestAddr, Path: path, Err: ErrEntryNotFound}
+	}
+	e := entry.ManifestEntry
+	return &e, nil
+}
+
+// GetManifestList returns the entries of the manifest with the given prefix,
+// the paths continuing with a slash after the prefix are listed as common
+// prefixes instead of their entries
+func (a *API) GetManifestList(ctx context.Context, addr storage.Address, prefix string) (list ManifestList, err error) {
+	walker, err := a.NewManifestWalker(ctx, addr, nil)
+	if err != nil {
+		return
+	}
+
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		// handle non-manifest files
+		if entry.ContentType != ManifestType {
+			// ignore the file if it doesn't have the specified prefix
+			if !strings.HasPrefix(entry.Path, prefix) {
+				return nil
+			}
+
+			// if the path after the prefix contains a slash, add a
+			// common prefix to the list, otherwise add the entry
+			suffix := strings.TrimPrefix(entry.Path, prefix)
+			if index := strings.Index(suffix, "/"); index > -1 {
+				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
+				return nil
+			}
+			if entry.Path == "" {
+				entry.Path = "/"
+			}
+			list.Entries = append(list.Entries, entry)
+			return nil
+		}
+
+		// if the manifest's path is a prefix of the specified prefix
+		// then just recurse into the manifest by returning nil and
+		// continuing the walk
+		if strings.HasPrefix(prefix, entry.Path) {
+			return nil
+		}
+
+		// if the manifest's path has the specified prefix, then if the
+		// path after the prefix contains a slash, add a common prefix
+		// to the list and skip the manifest, otherwise recurse into
+		// the manifest by returning nil and continuing the walk
+		if strings.HasPrefix(entry.Path, prefix) {
+			suffix := strings.TrimPrefix(entry.Path, prefix)
+			if index := strings.Index(suffix, "/"); index > -1 {
+				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
+				return ErrSkipManifest
+			}
+			return nil
+		}
+
+		// the manifest neither has the prefix or needs recursing in to
+		// so just skip it
+		return ErrSkipManifest
+	})
+
+	return list, nil
+}
+
 // Modify loads manifest and checks the content hash before recalculating and storing the manifest.
 func (a *API) Modify(ctx context.Context, addr storage.Address, path, contentHash, contentType string) (storage.Address, error) {
 	apiModifyCount.Inc(1)
@@ -433,6 +865,7 @@ func (a *API) Modify(ctx context.Context, addr storage.Address, path, contentHas
 		apiModifyFail.Inc(1)
 		return nil, err
 	}
+	trie.fanout = a.manifestFanout
 	if contentHash != "" {
 		entry := newManifestTrieEntry(&ManifestEntry{
 			Path:        path,
@@ -473,7 +906,7 @@ func (a *API) AddFile(ctx context.Context, mhash, path, fname string, content []
 
 	entry := &ManifestEntry{
 		Path:        filepath.Join(path, fname),
-		ContentType: mime.TypeByExtension(filepath.Ext(fname)),
+		ContentType: a.mime.Detect(fname, content),
 		Mode:        0700,
 		Size:        int64(len(content)),
 		ModTime:     time.Now(),
@@ -602,7 +1035,7 @@ func (a *API) AppendFile(ctx context.Context, mhash, path, fname string, existin
 
 	entry := &ManifestEntry{
 		Path:        filepath.Join(path, fname),
-		ContentType: mime.TypeByExtension(filepath.Ext(fname)),
+		ContentType: a.mime.Detect(fname, nil),
 		Mode:        0700,
 		Size:        totalSize,
 		ModTime:     time.Now(),
@@ -639,7 +1072,7 @@ func (a *API) BuildDirectoryTree(ctx context.Context, mhash string, nameresolver
 	quitC := make(chan bool)
 	rootTrie, err := loadManifest(ctx, a.fileStore, addr, quitC)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can't load manifest %v: %v", addr.String(), err)
+		return nil, nil, err
 	}
 
 	manifestEntryMap = map[string]*manifestTrieEntry{}
@@ -654,15 +1087,16 @@ func (a *API) BuildDirectoryTree(ctx context.Context, mhash string, nameresolver
 }
 
 // ResourceLookup Looks up mutable resource updates at specific periods and versions
-func (a *API) ResourceLookup(ctx context.Context, addr storage.Address, period uint32, version uint32, maxLookup *mru.LookupParams) (string, []byte, error) {
+// and returns the name of the resource with the address and the data of the update
+func (a *API) ResourceLookup(ctx context.Context, addr storage.Address, period uint32, version uint32, maxLookup *mru.LookupParams) (string, storage.Address, []byte, error) {
 	var err error
 	rsrc, err := a.resource.Load(addr)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 	if version != 0 {
 		if period == 0 {
-			return "", nil, mru.NewError(mru.ErrInvalidValue, "Period can't be 0")
+			return "", nil, nil, mru.NewError(mru.ErrInvalidValue, "Period can't be 0")
 		}
 		_, err = a.resource.LookupVersion(ctx, rsrc.NameHash(), period, version, true, maxLookup)
 	} else if period != 0 {
@@ -671,14 +1105,13 @@ func (a *API) ResourceLookup(ctx context.Context, addr storage.Address, period u
 		_, err = a.resource.LookupLatest(ctx, rsrc.NameHash(), true, maxLookup)
 	}
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
-	var data []byte
-	_, data, err = a.resource.GetContent(rsrc.NameHash().Hex())
+	updateAddr, data, err := a.resource.GetContent(rsrc.NameHash().Hex())
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
-	return rsrc.Name(), data, nil
+	return rsrc.Name(), updateAddr, data, nil
 }
 
 // ResourceCreate creates Resource and returns its key
@@ -690,6 +1123,15 @@ func (a *API) ResourceCreate(ctx context.Context, name string, frequency uint64)
 	return key, nil
 }
 
+// ResourceCreateMultiAuthor creates a Resource updatable by any of the given authors and returns its key
+func (a *API) ResourceCreateMultiAuthor(ctx context.Context, name string, frequency uint64, authors []common.Address, merge mru.MergeRule) (storage.Address, error) {
+	key, _, err := a.resource.NewMultiAuthor(ctx, name, frequency, authors, merge)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // ResourceUpdateMultihash updates a Mutable Resource and marks the update's content to be of multihash type, which will be recognized upon retrieval.
 // It will fail if the data is not a valid multihash.
 func (a *API) ResourceUpdateMultihash(ctx context.Context, name string, data []byte) (storage.Address, uint32, uint32, error) {
@@ -710,11 +1152,26 @@ func (a *API) resourceUpdate(ctx context.Context, name string, data []byte, mult
 	} else {
 		addr, err = a.resource.Update(ctx, name, data)
 	}
-	period, _ := a.resource.GetLastPeriod(name)
-	version, _ := a.resource.GetVersion(name)
+	// the handler indexes its resources by the hash of their name
+	index := ens.EnsNode(name).Hex()
+	period, _ := a.resource.GetLastPeriod(index)
+	version, _ := a.resource.GetVersion(index)
 	return addr, period, version, err
 }
 
+// ResourceUpdateSigned updates a Mutable Resource with an update signed by
+// the client, the period and version are the ones of the next update
+// returned by ResourceInfo
+func (a *API) ResourceUpdateSigned(ctx context.Context, name string, period uint32, version uint32, data []byte, multihash bool, signature mru.Signature) (storage.Address, error) {
+	return a.resource.UpdateSigned(ctx, name, period, version, data, multihash, signature)
+}
+
+// ResourceInfo describes the Mutable Resource with the given metadata chunk
+// address and its latest update
+func (a *API) ResourceInfo(ctx context.Context, addr storage.Address) (*mru.Info, error) {
+	return a.resource.Info(ctx, addr)
+}
+
 // ResourceHashSize returned the size of the digest produced by the Mutable Resource hashing function
 func (a *API) ResourceHashSize() int {
 	return a.resource.HashSize
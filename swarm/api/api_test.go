@@ -17,6 +17,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -24,7 +25,10 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -85,7 +89,7 @@ func expResponse(content string, mimeType string, status int) *Response {
 
 func testGet(t *testing.T, api *API, bzzhash, path string) *testResponse {
 	addr := storage.Address(common.Hex2Bytes(bzzhash))
-	reader, mimeType, status, _, err := api.Get(context.TODO(), addr, path)
+	reader, mimeType, status, _, _, err := api.Get(context.TODO(), addr, path)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -123,6 +127,161 @@ func TestApiPut(t *testing.T) {
 	})
 }
 
+// TestApiStoreWithReceipt tests that the receipt of an upload describes the
+// stored content
+func TestApiStoreWithReceipt(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		data := make([]byte, 5*4096)
+		for i := range data {
+			data[i] = byte(i/4096 + 1)
+		}
+		receipt, err := api.StoreWithReceipt(context.TODO(), bytes.NewReader(data), int64(len(data)), toEncrypt, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the root chunk and 5 data chunks
+		if receipt.Encrypted != toEncrypt || receipt.Manifest || receipt.Chunks != 6 || receipt.Bytes != int64(len(data)) {
+			t.Fatalf("unexpected receipt %+v", receipt)
+		}
+		if receipt.RootHash != receipt.Address[:2*storage.KeyLength] {
+			t.Fatalf("expected root hash %s, got %s", receipt.Address[:2*storage.KeyLength], receipt.RootHash)
+		}
+		tag, ok := api.Tags().Get(receipt.Tag)
+		if !ok {
+			t.Fatalf("tag %d not found", receipt.Tag)
+		}
+		if tag.Get(storage.StateStored) != 6 {
+			t.Fatalf("expected 6 stored chunks, got %d", tag.Get(storage.StateStored))
+		}
+	})
+}
+
+// TestApiCompositeManifest tests that the root entry of a composite manifest
+// is read as the concatenation of its parts
+func TestApiCompositeManifest(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		ctx := context.TODO()
+		var parts []storage.Address
+		var expected []byte
+		for _, content := range []string{strings.Repeat("a", 5000), "b", strings.Repeat("c", 4096)} {
+			addr, wait, err := api.Store(ctx, strings.NewReader(content), int64(len(content)), toEncrypt)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			parts = append(parts, addr)
+			expected = append(expected, content...)
+		}
+		addr, err := api.NewCompositeManifest(ctx, parts, "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		reader, mimeType, status, contentAddr, _, err := api.Get(ctx, addr, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mimeType != "text/plain" || status != 0 {
+			t.Fatalf("unexpected mime type %q and status %d", mimeType, status)
+		}
+		var hexParts []string
+		for _, part := range parts {
+			hexParts = append(hexParts, part.Hex())
+		}
+		if !bytes.Equal(contentAddr, CompositeAddress(hexParts)) {
+			t.Fatalf("expected composite address %s, got %s", CompositeAddress(hexParts), contentAddr)
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(content, expected) {
+			t.Fatalf("expected %d bytes of concatenated content, got %d", len(expected), len(content))
+		}
+
+		missing := storage.Address(bytes.Repeat([]byte{1}, 32))
+		if _, err := api.NewCompositeManifest(ctx, append(parts, missing), "", toEncrypt); err == nil {
+			t.Fatal("expected error creating a composite manifest with a missing part")
+		}
+	})
+}
+
+// TestApiPin tests that pinning a manifest pins the content of its entries
+// and the parts of its composite entries
+func TestApiPin(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		ctx := context.TODO()
+		ldb := api.fileStore.ChunkStore.(*storage.LocalStore).DbStore
+		var parts []storage.Address
+		for _, content := range []string{strings.Repeat("a", 5000), "b"} {
+			addr, wait, err := api.Store(ctx, strings.NewReader(content), int64(len(content)), toEncrypt)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			parts = append(parts, addr)
+		}
+		addr, err := api.NewCompositeManifest(ctx, parts, "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		check := func(pins uint64) {
+			for _, ref := range append([]storage.Address{addr}, parts...) {
+				if count := ldb.PinCount(ref[:32]); count != pins {
+					t.Fatalf("encrypt %v: expected %d pins of %v, got %d", toEncrypt, pins, ref[:32], count)
+				}
+			}
+		}
+		for pins := uint64(1); pins <= 2; pins++ {
+			if err := api.Pin(ctx, addr); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			check(pins)
+		}
+		for pins := uint64(1); pins <= 2; pins++ {
+			if err := api.Unpin(ctx, addr); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			check(2 - pins)
+		}
+		if err := api.Unpin(ctx, addr); err == nil {
+			t.Fatal("expected error unpinning content which is not pinned")
+		}
+	})
+}
+
+// TestApiGetErrors tests that the errors of Get can be told apart by their
+// cause
+func TestApiGetErrors(t *testing.T) {
+	testAPI(t, func(api *API, toEncrypt bool) {
+		ctx := context.TODO()
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, _, _, _, err = api.Get(ctx, addr, "missing")
+		if storage.Cause(err) != ErrEntryNotFound {
+			t.Fatalf("expected %v, got %v", ErrEntryNotFound, err)
+		}
+		if merr, ok := err.(*ManifestError); !ok || merr.Path != "missing" || !bytes.Equal(merr.Addr, addr) {
+			t.Fatalf("expected manifest error for path missing of %v, got %v", addr, err)
+		}
+
+		missing := storage.Address(make([]byte, 32))
+		missing[0] = 1
+		_, _, _, _, _, err = api.Get(ctx, missing, "")
+		if storage.Cause(err) != ErrManifestNotFound {
+			t.Fatalf("expected %v, got %v", ErrManifestNotFound, err)
+		}
+	})
+}
+
 // testResolver implements the Resolver interface and either returns the given
 // hash if it is set, or returns a "name not found" error
 type testResolveValidator struct {
@@ -373,3 +532,136 @@ func TestMultiResolver(t *testing.T) {
 		})
 	}
 }
+
+// hidingChunkStore is a ChunkStore which does not return the hidden chunks
+type hidingChunkStore struct {
+	storage.ChunkStore
+	hidden map[string]bool
+}
+
+func (s *hidingChunkStore) Get(addr storage.Address) (*storage.Chunk, error) {
+	if s.hidden[addr.Hex()] {
+		return nil, storage.ErrChunkNotFound
+	}
+	return s.ChunkStore.Get(addr)
+}
+
+// blockingChunkStore is a ChunkStore which counts the retrievals of chunks
+// and blocks them until it is released
+type blockingChunkStore struct {
+	storage.ChunkStore
+	mu      sync.Mutex
+	gets    map[string]int
+	release chan struct{}
+}
+
+func (s *blockingChunkStore) Get(addr storage.Address) (*storage.Chunk, error) {
+	s.mu.Lock()
+	s.gets[addr.Hex()]++
+	s.mu.Unlock()
+	<-s.release
+	return s.ChunkStore.Get(addr)
+}
+
+// TestGetShared tests that concurrent requests of the same path retrieve
+// the manifest and the content once
+func TestGetShared(t *testing.T) {
+	store := &blockingChunkStore{
+		ChunkStore: storage.NewMapChunkStore(),
+		gets:       make(map[string]int),
+		release:    make(chan struct{}),
+	}
+	close(store.release)
+	api := NewAPI(storage.NewFileStore(store, storage.NewFileStoreParams()), nil, nil)
+	ctx := context.TODO()
+	content := strings.Repeat("0123456789abcdef", 100)
+	addr, wait, err := api.Put(ctx, content, "text/plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	store.release = make(chan struct{})
+
+	n := 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader, _, _, _, _, err := api.Get(ctx, addr, "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, err := ioutil.ReadAll(io.NewSectionReader(reader, 0, int64(len(content))))
+			if err != nil || string(data) != content {
+				t.Errorf("unexpected content %q (%v)", data, err)
+			}
+		}()
+	}
+	// let the requests join the lookup in flight
+	time.Sleep(100 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	if gets := store.gets[addr.Hex()]; gets != 1 {
+		t.Fatalf("expected the manifest to be retrieved once, got %d", gets)
+	}
+}
+
+// TestMissingChunks tests that the chunks of a manifest and its content
+// missing from the local store are listed
+func TestMissingChunks(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		store := &hidingChunkStore{
+			ChunkStore: storage.NewMapChunkStore(),
+			hidden:     make(map[string]bool),
+		}
+		api := NewAPI(storage.NewFileStore(store, storage.NewFileStoreParams()), nil, nil)
+		ctx := context.TODO()
+
+		content := strings.Repeat("0123456789abcdef", 1000)
+		addr, wait, err := api.Put(ctx, content, "text/plain", toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		_, _, _, contentAddr, _, err := api.Get(ctx, addr, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		check := func(hidden storage.Address, expected ...storage.Address) {
+			store.hidden = map[string]bool{hidden.Hex(): true}
+			missing, err := api.MissingChunks(ctx, addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(missing) != len(expected) {
+				t.Fatalf("encrypted %v, hidden %v: expected %d missing chunks, got %v", toEncrypt, hidden, len(expected), missing)
+			}
+			for i := range expected {
+				if !bytes.Equal(missing[i], expected[i]) {
+					t.Fatalf("encrypted %v: expected missing chunk %v, got %v", toEncrypt, expected[i], missing[i])
+				}
+			}
+		}
+		check(nil)
+		check(addr[:32], addr[:32])
+		check(contentAddr[:32], contentAddr[:32])
+
+		if toEncrypt {
+			continue
+		}
+		root, err := store.ChunkStore.Get(contentAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf := storage.Address(root.SData[8+32 : 8+64])
+		check(leaf, leaf)
+	}
+}
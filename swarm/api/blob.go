@@ -0,0 +1,461 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var (
+	blobPutCount    = metrics.NewRegisteredCounter("api.blob.put.count", nil)
+	blobPutFail     = metrics.NewRegisteredCounter("api.blob.put.fail", nil)
+	blobGetCount    = metrics.NewRegisteredCounter("api.blob.get.count", nil)
+	blobGetFail     = metrics.NewRegisteredCounter("api.blob.get.fail", nil)
+	blobDeleteCount = metrics.NewRegisteredCounter("api.blob.delete.count", nil)
+	blobListCount   = metrics.NewRegisteredCounter("api.blob.list.count", nil)
+)
+
+var (
+	// ErrRepoNotFound is returned when a blob repository does not exist
+	ErrRepoNotFound = errors.New("blob repository not found")
+	// ErrRepoExists is returned when a blob repository is created twice
+	ErrRepoExists = errors.New("blob repository already exists")
+	// ErrBlobNotFound is returned when a blob does not exist
+	ErrBlobNotFound = errors.New("blob not found")
+	// ErrBlobExists is returned when a blob is stored under a name which is
+	// already taken, blobs are never overwritten
+	ErrBlobExists = errors.New("blob already exists")
+	// ErrRepoLocked is returned when the lock of a blob repository is held
+	// by another owner
+	ErrRepoLocked = errors.New("blob repository locked by another owner")
+)
+
+// blobReposKey is the state store key under which the repository to
+// manifest mapping is persisted
+const blobReposKey = "blob_repos"
+
+// blobRepo records the manifest which currently holds the blobs of a
+// repository
+type blobRepo struct {
+	Manifest storage.Address `json:"manifest"`
+	Created  time.Time       `json:"created"`
+}
+
+// BlobInfo describes a blob stored in a blob repository
+type BlobInfo struct {
+	Name    string          `json:"name"`
+	Size    int64           `json:"size"`
+	Hash    storage.Address `json:"hash"`
+	ModTime time.Time       `json:"modTime"`
+}
+
+// BlobLock is the lock of a blob repository
+type BlobLock struct {
+	Repo    string    `json:"repo"`
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// BlobStore is a low-level storage backend for backup tools such as restic
+// or borg, which keep their repositories as flat sets of named, immutable
+// blobs. As blobs are stored as swarm content, identical chunks of blobs
+// are stored once no matter how many blobs or repositories contain them.
+//
+// A repository is a named, mutable pointer to a manifest kept in the state
+// store of the node, the manifest can be used to pin or export the
+// repository. The contract backup tools can rely on is:
+//
+//   - Put stores a blob under a name which is not taken and returns once
+//     the blob is stored, a name which is taken is refused with
+//     ErrBlobExists, blobs are never overwritten
+//   - Get and Stat return the blob stored under the exact name, names are
+//     not matched by prefix
+//   - List returns the blobs whose names start with a prefix sorted by name,
+//     names are opaque and may contain slashes
+//   - Delete removes a blob, removing a blob which does not exist returns
+//     ErrBlobNotFound
+//   - Lock grants an exclusive, expiring lock of a repository to an owner,
+//     locking again extends the lock of the owner. Locks are advisory, they
+//     do not prevent the other calls, and they are kept in memory only.
+//
+// Writes of the same repository are serialised, the content of blobs is
+// stored before the pointer of the repository is updated so that uploads
+// are concurrent.
+type BlobStore struct {
+	api   *API
+	store state.Store
+	mu    sync.Mutex // serialises repository pointer updates
+	locks map[string]*BlobLock
+	lmu   sync.Mutex
+}
+
+// NewBlobStore creates a BlobStore persisting repository pointers in store
+func NewBlobStore(api *API, store state.Store) *BlobStore {
+	return &BlobStore{
+		api:   api,
+		store: store,
+		locks: make(map[string]*BlobLock),
+	}
+}
+
+func (b *BlobStore) repos() (map[string]*blobRepo, error) {
+	repos := make(map[string]*blobRepo)
+	err := b.store.Get(blobReposKey, &repos)
+	if err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	return repos, nil
+}
+
+func validateRepoName(repo string) error {
+	if repo == "" || strings.Contains(repo, "/") {
+		return fmt.Errorf("invalid blob repository name %q", repo)
+	}
+	return nil
+}
+
+func validateBlobName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return fmt.Errorf("invalid blob name %q", name)
+	}
+	return nil
+}
+
+// CreateRepo creates an empty repository
+func (b *BlobStore) CreateRepo(ctx context.Context, repo string) error {
+	if err := validateRepoName(repo); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	repos, err := b.repos()
+	if err != nil {
+		return err
+	}
+	if _, ok := repos[repo]; ok {
+		return ErrRepoExists
+	}
+	addr, err := b.api.NewManifest(ctx, false)
+	if err != nil {
+		return err
+	}
+	repos[repo] = &blobRepo{Manifest: addr, Created: time.Now()}
+	if err := b.store.Put(blobReposKey, repos); err != nil {
+		return err
+	}
+	log.Info("blob repository created", "repo", repo, "manifest", addr)
+	return nil
+}
+
+// Repos returns the names of the repositories sorted by name
+func (b *BlobStore) Repos() ([]string, error) {
+	repos, err := b.repos()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Root returns the address of the manifest currently holding the blobs of
+// the repository
+func (b *BlobStore) Root(repo string) (storage.Address, error) {
+	repos, err := b.repos()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := repos[repo]
+	if !ok {
+		return nil, ErrRepoNotFound
+	}
+	return r.Manifest, nil
+}
+
+// update applies update to the manifest of the repository and moves the
+// repository pointer to the resulting manifest, update is passed the
+// current blob with the name, nil if there is none
+func (b *BlobStore) update(ctx context.Context, repo, name string, update func(current *ManifestEntry, trie *manifestTrie) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	repos, err := b.repos()
+	if err != nil {
+		return err
+	}
+	r, ok := repos[repo]
+	if !ok {
+		return ErrRepoNotFound
+	}
+	// walks rewrite the paths of the entries, the current blob is looked up
+	// in a manifest loaded apart from the one which is updated
+	walker, err := b.api.NewManifestWalker(ctx, r.Manifest, nil)
+	if err != nil {
+		return err
+	}
+	current, err := blobEntry(walker, name)
+	if err != nil && err != ErrBlobNotFound {
+		return err
+	}
+	trie, err := loadManifest(ctx, b.api.fileStore, r.Manifest, nil)
+	if err != nil {
+		return err
+	}
+	trie.fanout = b.api.manifestFanout
+	if err := update(current, trie); err != nil {
+		return err
+	}
+	if err := trie.recalcAndStore(); err != nil {
+		return err
+	}
+	r.Manifest = trie.ref
+	return b.store.Put(blobReposKey, repos)
+}
+
+// blobEntries returns the blob entries of the manifest whose names start
+// with prefix sorted by name
+func blobEntries(walker *ManifestWalker, prefix string) ([]*ManifestEntry, error) {
+	var entries []*ManifestEntry
+	err := walker.Walk(func(entry *ManifestEntry) error {
+		if entry.ContentType == ManifestType {
+			// only recurse into manifests which can contain the prefix
+			if strings.HasPrefix(prefix, entry.Path) || strings.HasPrefix(entry.Path, prefix) {
+				return nil
+			}
+			return ErrSkipManifest
+		}
+		if entry.Path == "" || !strings.HasPrefix(entry.Path, prefix) {
+			return nil
+		}
+		e := *entry
+		entries = append(entries, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// blobEntry returns the entry of the blob with the exact name
+func blobEntry(walker *ManifestWalker, name string) (*ManifestEntry, error) {
+	entries, err := blobEntries(walker, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Path == name {
+			return e, nil
+		}
+	}
+	return nil, ErrBlobNotFound
+}
+
+// entry returns the entry of the blob with the exact name in the current
+// manifest of the repository
+func (b *BlobStore) entry(ctx context.Context, repo, name string) (*ManifestEntry, error) {
+	if err := validateBlobName(name); err != nil {
+		return nil, err
+	}
+	addr, err := b.Root(repo)
+	if err != nil {
+		return nil, err
+	}
+	walker, err := b.api.NewManifestWalker(ctx, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return blobEntry(walker, name)
+}
+
+func blobInfo(e *ManifestEntry) *BlobInfo {
+	return &BlobInfo{
+		Name:    e.Path,
+		Size:    e.Size,
+		Hash:    storage.Address(common.Hex2Bytes(e.Hash)),
+		ModTime: e.ModTime,
+	}
+}
+
+// Put stores the blob of the given size under name
+func (b *BlobStore) Put(ctx context.Context, repo, name string, data io.Reader, size int64) (*BlobInfo, error) {
+	blobPutCount.Inc(1)
+	if err := validateBlobName(name); err != nil {
+		blobPutFail.Inc(1)
+		return nil, err
+	}
+	if _, err := b.Root(repo); err != nil {
+		blobPutFail.Inc(1)
+		return nil, err
+	}
+	if _, err := b.entry(ctx, repo, name); err != ErrBlobNotFound {
+		blobPutFail.Inc(1)
+		if err == nil {
+			err = ErrBlobExists
+		}
+		return nil, err
+	}
+
+	addr, wait, err := b.api.Store(ctx, data, size, false)
+	if err != nil {
+		blobPutFail.Inc(1)
+		return nil, err
+	}
+	if err := wait(ctx); err != nil {
+		blobPutFail.Inc(1)
+		return nil, err
+	}
+
+	info := &BlobInfo{Name: name, Size: size, Hash: addr, ModTime: time.Now()}
+	err = b.update(ctx, repo, name, func(current *ManifestEntry, trie *manifestTrie) error {
+		// the name may have been taken while the content was stored
+		if current != nil {
+			return ErrBlobExists
+		}
+		entry := newManifestTrieEntry(&ManifestEntry{
+			Path:        name,
+			ContentType: "application/octet-stream",
+			Mode:        0644,
+			Size:        size,
+			ModTime:     info.ModTime,
+		}, nil)
+		entry.Hash = addr.Hex()
+		trie.addEntry(entry, nil)
+		return nil
+	})
+	if err != nil {
+		blobPutFail.Inc(1)
+		return nil, err
+	}
+	log.Debug("blob stored", "repo", repo, "name", name, "size", size, "hash", addr)
+	return info, nil
+}
+
+// Stat returns the description of the blob stored under name
+func (b *BlobStore) Stat(ctx context.Context, repo, name string) (*BlobInfo, error) {
+	e, err := b.entry(ctx, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	return blobInfo(e), nil
+}
+
+// Get returns the description and a reader of the content of the blob
+// stored under name
+func (b *BlobStore) Get(ctx context.Context, repo, name string) (*BlobInfo, storage.LazySectionReader, error) {
+	blobGetCount.Inc(1)
+	e, err := b.entry(ctx, repo, name)
+	if err != nil {
+		blobGetFail.Inc(1)
+		return nil, nil, err
+	}
+	reader, _ := b.api.RetrieveEntry(ctx, e)
+	return blobInfo(e), reader, nil
+}
+
+// List returns the blobs whose names start with prefix sorted by name
+func (b *BlobStore) List(ctx context.Context, repo, prefix string) ([]*BlobInfo, error) {
+	blobListCount.Inc(1)
+	addr, err := b.Root(repo)
+	if err != nil {
+		return nil, err
+	}
+	walker, err := b.api.NewManifestWalker(ctx, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := blobEntries(walker, prefix)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*BlobInfo, len(entries))
+	for i, e := range entries {
+		infos[i] = blobInfo(e)
+	}
+	return infos, nil
+}
+
+// Delete removes the blob stored under name
+func (b *BlobStore) Delete(ctx context.Context, repo, name string) error {
+	blobDeleteCount.Inc(1)
+	if err := validateBlobName(name); err != nil {
+		return err
+	}
+	return b.update(ctx, repo, name, func(current *ManifestEntry, trie *manifestTrie) error {
+		if current == nil {
+			return ErrBlobNotFound
+		}
+		trie.deleteEntry(name, nil)
+		return nil
+	})
+}
+
+// Lock locks the repository for owner until ttl passes, it fails with
+// ErrRepoLocked if the repository is locked by another owner
+func (b *BlobStore) Lock(repo, owner string, ttl time.Duration) (*BlobLock, error) {
+	if _, err := b.Root(repo); err != nil {
+		return nil, err
+	}
+	b.lmu.Lock()
+	defer b.lmu.Unlock()
+
+	now := time.Now()
+	if l, ok := b.locks[repo]; ok && l.Owner != owner && now.Before(l.Expires) {
+		return nil, ErrRepoLocked
+	}
+	l := &BlobLock{Repo: repo, Owner: owner, Expires: now.Add(ttl)}
+	b.locks[repo] = l
+	lock := *l
+	return &lock, nil
+}
+
+// Unlock releases the lock of the repository held by owner
+func (b *BlobStore) Unlock(repo, owner string) error {
+	b.lmu.Lock()
+	defer b.lmu.Unlock()
+
+	l, ok := b.locks[repo]
+	if !ok || time.Now().After(l.Expires) {
+		delete(b.locks, repo)
+		return nil
+	}
+	if l.Owner != owner {
+		return ErrRepoLocked
+	}
+	delete(b.locks, repo)
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+// TestBlobStore tests the storage backend contract of BlobStore
+func TestBlobStore(t *testing.T) {
+	testAPI(t, func(a *API, _ bool) {
+		ctx := context.Background()
+		blobs := NewBlobStore(a, state.NewInmemoryStore())
+
+		if _, err := blobs.Put(ctx, "repo", "config", strings.NewReader("x"), 1); err != ErrRepoNotFound {
+			t.Fatalf("expected %v putting to a missing repository, got %v", ErrRepoNotFound, err)
+		}
+		if err := blobs.CreateRepo(ctx, "repo"); err != nil {
+			t.Fatal(err)
+		}
+		if err := blobs.CreateRepo(ctx, "repo"); err != ErrRepoExists {
+			t.Fatalf("expected %v, got %v", ErrRepoExists, err)
+		}
+
+		content := map[string]string{
+			"config":       "config",
+			"data/00/0001": "first data blob",
+			"data/00/0002": "second data blob",
+			"data/01/0101": "third data blob",
+			"keys/abcd":    "key",
+		}
+		for name, data := range content {
+			info, err := blobs.Put(ctx, "repo", name, strings.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("put %s: %v", name, err)
+			}
+			if info.Size != int64(len(data)) {
+				t.Fatalf("put %s: expected size %d, got %d", name, len(data), info.Size)
+			}
+		}
+
+		// blobs are never overwritten
+		if _, err := blobs.Put(ctx, "repo", "config", strings.NewReader("other"), 5); err != ErrBlobExists {
+			t.Fatalf("expected %v, got %v", ErrBlobExists, err)
+		}
+
+		for name, data := range content {
+			_, reader, err := blobs.Get(ctx, "repo", name)
+			if err != nil {
+				t.Fatalf("get %s: %v", name, err)
+			}
+			size, err := reader.Size(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(reader)
+			if err != nil && int64(len(got)) != size {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, []byte(data)) {
+				t.Fatalf("get %s: expected %q, got %q", name, data, got)
+			}
+		}
+
+		// names are not matched by prefix
+		if _, err := blobs.Stat(ctx, "repo", "data/00"); err != ErrBlobNotFound {
+			t.Fatalf("expected %v, got %v", ErrBlobNotFound, err)
+		}
+
+		list, err := blobs.List(ctx, "repo", "data/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, info := range list {
+			names = append(names, info.Name)
+		}
+		if exp := "data/00/0001,data/00/0002,data/01/0101"; strings.Join(names, ",") != exp {
+			t.Fatalf("expected blobs %s, got %s", exp, strings.Join(names, ","))
+		}
+
+		if err := blobs.Delete(ctx, "repo", "data/00/0001"); err != nil {
+			t.Fatal(err)
+		}
+		if err := blobs.Delete(ctx, "repo", "data/00/0001"); err != ErrBlobNotFound {
+			t.Fatalf("expected %v, got %v", ErrBlobNotFound, err)
+		}
+		if _, err := blobs.Stat(ctx, "repo", "data/00/0002"); err != nil {
+			t.Fatalf("expected the other blobs to be kept, got %v", err)
+		}
+	})
+}
+
+// TestBlobStoreLock tests that the lock of a repository is exclusive until
+// it expires or is released
+func TestBlobStoreLock(t *testing.T) {
+	testAPI(t, func(a *API, _ bool) {
+		blobs := NewBlobStore(a, state.NewInmemoryStore())
+		if err := blobs.CreateRepo(context.Background(), "repo"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := blobs.Lock("repo", "alice", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blobs.Lock("repo", "bob", time.Hour); err != ErrRepoLocked {
+			t.Fatalf("expected %v, got %v", ErrRepoLocked, err)
+		}
+		if err := blobs.Unlock("repo", "bob"); err != ErrRepoLocked {
+			t.Fatalf("expected %v, got %v", ErrRepoLocked, err)
+		}
+		if err := blobs.Unlock("repo", "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		// expired locks are taken over
+		if _, err := blobs.Lock("repo", "bob", -time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blobs.Lock("repo", "alice", time.Hour); err != nil {
+			t.Fatalf("expected the expired lock to be taken over, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var (
+	blocklistBlockCount   = metrics.NewRegisteredCounter("api.blocklist.block.count", nil)
+	blocklistUnblockCount = metrics.NewRegisteredCounter("api.blocklist.unblock.count", nil)
+)
+
+// ErrNotBlocked is returned when a root which is not blocked is unblocked
+var ErrNotBlocked = errors.New("root is not blocked")
+
+const (
+	// blocklistKey is the state store key under which the block records are
+	// persisted
+	blocklistKey = "blocklist"
+	// blocklistChunksKeyPrefix prefixes the state store keys under which the
+	// chunks excluded from the forwarding cache are persisted per root
+	blocklistChunksKeyPrefix = "blocklist_chunks_"
+)
+
+// BlockListVersion is the version of the export format of block lists
+const BlockListVersion = 1
+
+// BlockRecord records the decision of the operator to block a root
+type BlockRecord struct {
+	Root    string    `json:"root"`           // hex encoded root hash
+	Name    string    `json:"name,omitempty"` // name the root was blocked by, if any
+	Reason  string    `json:"reason"`
+	Source  string    `json:"source"` // who reported or imported the root
+	Blocked time.Time `json:"blocked"`
+}
+
+// BlockListExport is the format block lists are shared in between operators
+type BlockListExport struct {
+	Version int            `json:"version"`
+	Records []*BlockRecord `json:"records"`
+}
+
+// Blocklist is the list of roots a gateway operator took down, for abuse
+// reports and takedown requests. Blocking a root:
+//
+//   - refuses the requests of the root at the HTTP layer
+//   - removes all the pins of the content of the root
+//   - evicts the chunks of the content available locally from the forwarding
+//     cache and keeps them out of it when they are retrieved for peers
+//   - logs the decision with its reason and source
+//
+// The chunks synced to the node are kept, they are stored on behalf of the
+// network. Records are persisted in the state store, lists can be exported
+// and imported to share them between operators.
+type Blocklist struct {
+	api     *API
+	store   state.Store
+	mu      sync.RWMutex
+	records map[string]*BlockRecord
+}
+
+// NewBlocklist loads the block records from store and keeps the chunks of
+// the blocked roots out of the forwarding cache
+func NewBlocklist(api *API, store state.Store) (*Blocklist, error) {
+	b := &Blocklist{
+		api:     api,
+		store:   store,
+		records: make(map[string]*BlockRecord),
+	}
+	err := store.Get(blocklistKey, &b.records)
+	if err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	for root := range b.records {
+		var chunks []storage.Address
+		err := store.Get(blocklistChunksKeyPrefix+root, &chunks)
+		if err == state.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := api.fileStore.ExcludeChunksFromCache(chunks); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Blocked reports whether the root hash is blocked
+func (b *Blocklist) Blocked(root string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.records[blockedRoot(root)]
+	return ok
+}
+
+// Records returns the block records sorted by root
+func (b *Blocklist) Records() []*BlockRecord {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	records := make([]*BlockRecord, 0, len(b.records))
+	for _, record := range b.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Root < records[j].Root })
+	return records
+}
+
+// Block blocks the root hash or name with the given reason reported by
+// source, a root which is already blocked keeps its record
+func (b *Blocklist) Block(ctx context.Context, root, reason, source string) (*BlockRecord, error) {
+	addr, err := b.resolve(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	record := &BlockRecord{
+		Root:    addr.Hex(),
+		Reason:  reason,
+		Source:  source,
+		Blocked: time.Now(),
+	}
+	if (&URI{Addr: blockedRoot(root)}).Address() == nil {
+		record.Name = root
+	}
+	return b.block(ctx, addr, record)
+}
+
+// blockedRoot returns hashes in lower case hex without prefix
+func blockedRoot(root string) string {
+	if hash := strings.TrimPrefix(strings.ToLower(root), "0x"); hashMatcher.MatchString(hash) {
+		return hash
+	}
+	return root
+}
+
+// resolve resolves the root hash or name to the address of the root
+func (b *Blocklist) resolve(ctx context.Context, root string) (storage.Address, error) {
+	return b.api.Resolve(ctx, &URI{Scheme: "bzz", Addr: blockedRoot(root)})
+}
+
+func (b *Blocklist) block(ctx context.Context, addr storage.Address, record *BlockRecord) (*BlockRecord, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if existing, ok := b.records[record.Root]; ok {
+		return existing, nil
+	}
+	b.records[record.Root] = record
+	if err := b.store.Put(blocklistKey, b.records); err != nil {
+		delete(b.records, record.Root)
+		return nil, err
+	}
+	blocklistBlockCount.Inc(1)
+
+	// the content is only found if it is available locally
+	contents, err := b.api.pinContents(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	unpinned := 0
+	for b.api.Unpin(ctx, addr) == nil {
+		unpinned++
+	}
+	var chunks []storage.Address
+	for _, content := range contents {
+		excluded, err := b.api.fileStore.ExcludeFromCache(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, excluded...)
+	}
+	if err := b.store.Put(blocklistChunksKeyPrefix+record.Root, chunks); err != nil {
+		return nil, err
+	}
+	log.Info("content blocked", "root", record.Root, "name", record.Name, "reason", record.Reason, "source", record.Source, "unpinned", unpinned, "chunks", len(chunks))
+	return record, nil
+}
+
+// Unblock removes the block of the root hash or name, the chunks of the root
+// can be cached again
+func (b *Blocklist) Unblock(ctx context.Context, root string) error {
+	addr, err := b.resolve(ctx, root)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	record, ok := b.records[addr.Hex()]
+	if !ok {
+		return ErrNotBlocked
+	}
+	delete(b.records, record.Root)
+	if err := b.store.Put(blocklistKey, b.records); err != nil {
+		b.records[record.Root] = record
+		return err
+	}
+	blocklistUnblockCount.Inc(1)
+
+	var chunks []storage.Address
+	err = b.store.Get(blocklistChunksKeyPrefix+record.Root, &chunks)
+	if err != nil && err != state.ErrNotFound {
+		return err
+	}
+	if err := b.api.fileStore.IncludeChunksInCache(chunks); err != nil {
+		return err
+	}
+	if err := b.store.Delete(blocklistChunksKeyPrefix + record.Root); err != nil {
+		return err
+	}
+	log.Info("content unblocked", "root", record.Root, "name", record.Name, "reason", record.Reason, "source", record.Source)
+	return nil
+}
+
+// Export writes the block records to w in the export format
+func (b *Blocklist) Export(w io.Writer) error {
+	return json.NewEncoder(w).Encode(&BlockListExport{
+		Version: BlockListVersion,
+		Records: b.Records(),
+	})
+}
+
+// Import blocks the roots of the block list exported by another operator
+// read from r and returns the number of roots which were not blocked yet,
+// the records keep their reason and source
+func (b *Blocklist) Import(ctx context.Context, r io.Reader) (int, error) {
+	var list BlockListExport
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return 0, err
+	}
+	if list.Version != BlockListVersion {
+		return 0, fmt.Errorf("unsupported block list version %d", list.Version)
+	}
+	count := 0
+	for _, record := range list.Records {
+		addr := (&URI{Addr: blockedRoot(record.Root)}).Address()
+		if addr == nil {
+			return count, fmt.Errorf("invalid root hash %q", record.Root)
+		}
+		if b.Blocked(addr.Hex()) {
+			continue
+		}
+		count++
+		imported := *record
+		imported.Root = addr.Hex()
+		if imported.Blocked.IsZero() {
+			imported.Blocked = time.Now()
+		}
+		if _, err := b.block(ctx, addr, &imported); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// BlocklistAPI manages the block list of the node in the bzz namespace
+type BlocklistAPI struct {
+	blocklist *Blocklist
+}
+
+// NewBlocklistAPI creates a new BlocklistAPI
+func NewBlocklistAPI(blocklist *Blocklist) *BlocklistAPI {
+	return &BlocklistAPI{blocklist: blocklist}
+}
+
+// Block blocks the root hash or name with the given reason
+func (b *BlocklistAPI) Block(ctx context.Context, root, reason string) (*BlockRecord, error) {
+	return b.blocklist.Block(ctx, root, reason, "rpc")
+}
+
+// Unblock removes the block of the root hash or name
+func (b *BlocklistAPI) Unblock(ctx context.Context, root string) error {
+	return b.blocklist.Unblock(ctx, root)
+}
+
+// Blocklist returns the block records of the node
+func (b *BlocklistAPI) Blocklist() []*BlockRecord {
+	return b.blocklist.Records()
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+// TestBlocklist tests that blocking a root unpins its content and that
+// block lists are persisted, exported and imported
+func TestBlocklist(t *testing.T) {
+	testAPI(t, func(a *API, toEncrypt bool) {
+		ctx := context.Background()
+		data := "abusive content"
+		addr, wait, err := a.Store(ctx, strings.NewReader(data), int64(len(data)), toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := a.Pin(ctx, addr); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		store := state.NewInmemoryStore()
+		blocklist, err := NewBlocklist(a, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, err := blocklist.Block(ctx, "0x"+strings.ToUpper(addr.Hex()), "abuse report", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Root != addr.Hex() || record.Name != "" {
+			t.Fatalf("unexpected record %+v", record)
+		}
+		if !blocklist.Blocked(addr.Hex()) {
+			t.Fatal("expected root to be blocked")
+		}
+		if err := a.Unpin(ctx, addr); err == nil {
+			t.Fatal("expected blocked content to be unpinned")
+		}
+
+		// the records are persisted
+		blocklist, err = NewBlocklist(a, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !blocklist.Blocked(addr.Hex()) {
+			t.Fatal("expected root to be blocked after reloading")
+		}
+
+		var export bytes.Buffer
+		if err := blocklist.Export(&export); err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewBlocklist(a, state.NewInmemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err := other.Import(ctx, bytes.NewReader(export.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("expected 1 imported root, got %d", count)
+		}
+		records := other.Records()
+		if len(records) != 1 || records[0].Reason != "abuse report" || records[0].Source != "test" {
+			t.Fatalf("unexpected imported records %+v", records)
+		}
+		if count, err := other.Import(ctx, bytes.NewReader(export.Bytes())); err != nil || count != 0 {
+			t.Fatalf("expected no root imported twice, got %d %v", count, err)
+		}
+
+		if err := blocklist.Unblock(ctx, addr.Hex()); err != nil {
+			t.Fatal(err)
+		}
+		if blocklist.Blocked(addr.Hex()) {
+			t.Fatal("expected root to be unblocked")
+		}
+		if err := blocklist.Unblock(ctx, addr.Hex()); err != ErrNotBlocked {
+			t.Fatalf("expected %v, got %v", ErrNotBlocked, err)
+		}
+	})
+}
@@ -19,22 +19,30 @@ package client
 import (
 	"archive/tar"
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/multihash"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
 )
 
 var (
@@ -51,13 +59,42 @@ func NewClient(gateway string) *Client {
 // Client wraps interaction with a swarm HTTP gateway.
 type Client struct {
 	Gateway string
+	// Hash is the chunk hash function of uploads, the default hash function
+	// of the gateway if empty
+	Hash string
+	// Sparse stores the content of uploads sparse, with its chunks of zeros
+	// represented by the zero reference, see storage.WithSparse
+	Sparse bool
+	// Preserve uploads symbolic links of directories as links instead of
+	// the files they point to, and restores the mode, the modification time
+	// and the symbolic links of downloaded files
+	Preserve bool
+	// ErrorDocuments are the error documents set in the manifests of
+	// directory and file uploads
+	ErrorDocuments []*api.ErrorDocument
+	// Fallback is the path of the entry served instead of the unresolved
+	// paths of the manifests of directory and file uploads
+	Fallback string
+	// Signer signs the write requests of the client, they are sent
+	// unsigned if nil
+	Signer RequestSigner
 }
 
 // UploadRaw uploads raw data to swarm and returns the resulting hash. If toEncrypt is true it
 // uploads encrypted data
 func (c *Client) UploadRaw(r io.Reader, size int64, toEncrypt bool) (string, error) {
+	receipt, err := c.UploadRawReceipt(r, size, toEncrypt)
+	if err != nil {
+		return "", err
+	}
+	return receipt.Address, nil
+}
+
+// UploadRawReceipt uploads raw data to swarm like UploadRaw and returns the
+// receipt of the upload
+func (c *Client) UploadRawReceipt(r io.Reader, size int64, toEncrypt bool) (*api.UploadReceipt, error) {
 	if size <= 0 {
-		return "", errors.New("data size must be greater than zero")
+		return nil, errors.New("data size must be greater than zero")
 	}
 	addr := ""
 	if toEncrypt {
@@ -65,22 +102,28 @@ func (c *Client) UploadRaw(r io.Reader, size int64, toEncrypt bool) (string, err
 	}
 	req, err := http.NewRequest("POST", c.Gateway+"/bzz-raw:/"+addr, r)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.ContentLength = size
-	res, err := http.DefaultClient.Do(req)
+	req.Header.Set("Accept", "application/json")
+	c.setStoreHeaders(req)
+	res, err := c.do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return decodeReceipt(res)
+}
+
+func decodeReceipt(res *http.Response) (*api.UploadReceipt, error) {
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
 	}
-	data, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return "", err
+	receipt := &api.UploadReceipt{}
+	if err := json.NewDecoder(res.Body).Decode(receipt); err != nil {
+		return nil, err
 	}
-	return string(data), nil
+	return receipt, nil
 }
 
 // DownloadRaw downloads raw data from swarm and it returns a ReadCloser and a bool whether the
@@ -121,7 +164,7 @@ func Open(path string) (*File, error) {
 	return &File{
 		ReadCloser: f,
 		ManifestEntry: api.ManifestEntry{
-			ContentType: mime.TypeByExtension(filepath.Ext(path)),
+			ContentType: api.NewMimeDetector().ByName(path),
 			Mode:        int64(stat.Mode()),
 			Size:        stat.Size(),
 			ModTime:     stat.ModTime(),
@@ -161,6 +204,109 @@ func (c *Client) Download(hash, path string) (*File, error) {
 	}, nil
 }
 
+// MissingChunks returns the hex encoded addresses of the chunks of the content
+// with the given hash which are not available in the local store of the node
+func (c *Client) MissingChunks(hash string) ([]string, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz:/" + hash + "/?missing=true")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var missing []string
+	if err := json.NewDecoder(res.Body).Decode(&missing); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// Tag is the progress of an operation of the node on content as reported
+// by the HTTP API
+type Tag struct {
+	Uid       uint32    `json:"uid"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	StartedAt time.Time `json:"startedAt"`
+	Total     int64     `json:"total"`
+	Stored    int64     `json:"stored"`
+	Sent      int64     `json:"sent"`
+	Synced    int64     `json:"synced"`
+	Failed    int64     `json:"failed"`
+	Done      bool      `json:"done"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Prefetch starts retrieving the content with the given hash into the local
+// store of the node and returns the tag following its progress
+func (c *Client) Prefetch(hash string) (*Tag, error) {
+	res, err := c.post(c.Gateway+"/bzz-prefetch:/"+hash, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTag(res, http.StatusAccepted)
+}
+
+// Pin pins the content with the given hash stored on the node, so that it is
+// kept by garbage collection until it is unpinned
+func (c *Client) Pin(hash string) error {
+	return c.pin("POST", hash)
+}
+
+// Unpin removes a pin of the content with the given hash made by Pin
+func (c *Client) Unpin(hash string) error {
+	return c.pin("DELETE", hash)
+}
+
+func (c *Client) pin(method, hash string) error {
+	req, err := http.NewRequest(method, c.Gateway+"/bzz-pin:/"+hash, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// PrefetchTag returns the tag of the last prefetch of the content with the
+// given hash
+func (c *Client) PrefetchTag(hash string) (*Tag, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-prefetch:/" + hash)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTag(res, http.StatusOK)
+}
+
+// Tag returns the state of the tag with the given uid, the uid of the tag of
+// an upload or download is returned in the X-Swarm-Tag response header
+func (c *Client) Tag(uid uint32) (*Tag, error) {
+	res, err := http.DefaultClient.Get(fmt.Sprintf("%s/bzz-tag:/%d", c.Gateway, uid))
+	if err != nil {
+		return nil, err
+	}
+	return decodeTag(res, http.StatusOK)
+}
+
+func decodeTag(res *http.Response, status int) (*Tag, error) {
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	tag := &Tag{}
+	if err := json.NewDecoder(res.Body).Decode(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
 // UploadDirectory uploads a directory tree to swarm and either adds the files
 // to an existing manifest (if the manifest argument is non-empty) or creates a
 // new manifest, returning the resulting manifest hash (files from the
@@ -174,7 +320,7 @@ func (c *Client) UploadDirectory(dir, defaultPath, manifest string, toEncrypt bo
 	} else if !stat.IsDir() {
 		return "", fmt.Errorf("not a directory: %s", dir)
 	}
-	return c.TarUpload(manifest, &DirectoryUploader{dir, defaultPath}, toEncrypt)
+	return c.TarUpload(manifest, &DirectoryUploader{Dir: dir, DefaultPath: defaultPath, Links: c.Preserve}, toEncrypt)
 }
 
 // DownloadDirectory downloads the files contained in a swarm manifest under
@@ -193,7 +339,7 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 		return err
 	}
 	req.Header.Set("Accept", "application/x-tar")
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -202,10 +348,13 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
 	}
 	tr := tar.NewReader(res.Body)
+	// the links are created once the files are written so that no file is
+	// written through them
+	links := make(map[string]string)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
-			return nil
+			break
 		} else if err != nil {
 			return err
 		}
@@ -218,6 +367,17 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
 			return err
 		}
+		var content io.Reader = tr
+		size := hdr.Size
+		if hdr.Typeflag == tar.TypeSymlink {
+			if c.Preserve {
+				links[dstPath] = hdr.Linkname
+				continue
+			}
+			// without links, the file has the content of the entry
+			content = strings.NewReader(hdr.Linkname)
+			size = int64(len(hdr.Linkname))
+		}
 		var mode os.FileMode = 0644
 		if hdr.Mode > 0 {
 			mode = os.FileMode(hdr.Mode)
@@ -226,14 +386,50 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 		if err != nil {
 			return err
 		}
-		n, err := io.Copy(dst, tr)
+		n, err := io.Copy(dst, content)
 		dst.Close()
 		if err != nil {
 			return err
-		} else if n != hdr.Size {
-			return fmt.Errorf("expected %s to be %d bytes but got %d", hdr.Name, hdr.Size, n)
+		} else if n != size {
+			return fmt.Errorf("expected %s to be %d bytes but got %d", hdr.Name, size, n)
+		}
+		if c.Preserve {
+			if err := restoreFileInfo(dstPath, hdr.Mode, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+	for dstPath, target := range links {
+		if err := createLink(dstPath, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFileInfo sets the permissions and the modification time of the
+// downloaded file to the ones of its manifest entry, if they are set
+func restoreFileInfo(path string, mode int64, modTime time.Time) error {
+	if mode > 0 {
+		if err := os.Chmod(path, os.FileMode(mode).Perm()); err != nil {
+			return err
+		}
+	}
+	if modTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
+
+// createLink creates the symbolic link of a downloaded entry, replacing an
+// existing file
+func createLink(path, target string) error {
+	if _, err := os.Lstat(path); err == nil {
+		if err := os.Remove(path); err != nil {
+			return err
 		}
 	}
+	return os.Symlink(target, path)
 }
 
 // DownloadFile downloads a single file into the destination directory
@@ -271,7 +467,7 @@ func (c *Client) DownloadFile(hash, path, dest string) error {
 	if err != nil {
 		return err
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -308,14 +504,22 @@ func (c *Client) DownloadFile(hash, path, dest string) error {
 		return err
 	}
 
+	entry := manifestList.Entries[0]
+	if c.Preserve && entry.LinkTarget != "" {
+		return createLink(filename, entry.LinkTarget)
+	}
 	dst, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
-
 	_, err = io.Copy(dst, res.Body)
-	return err
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || !c.Preserve {
+		return err
+	}
+	return restoreFileInfo(filename, entry.Mode, entry.ModTime)
 }
 
 // UploadManifest uploads the given manifest to swarm
@@ -334,11 +538,58 @@ func (c *Client) DownloadManifest(hash string) (*api.Manifest, bool, error) {
 		return nil, isEncrypted, err
 	}
 	defer res.Close()
-	var manifest api.Manifest
-	if err := json.NewDecoder(res).Decode(&manifest); err != nil {
+	data, err := ioutil.ReadAll(res)
+	if err != nil {
 		return nil, isEncrypted, err
 	}
-	return &manifest, isEncrypted, nil
+	manifest, _, err := api.DecodeManifest(data)
+	if err != nil {
+		return nil, isEncrypted, err
+	}
+	return manifest, isEncrypted, nil
+}
+
+// ConvertManifest converts the manifest with the given hash and all of its
+// submanifests to the given encoding version and returns the hash of the
+// converted manifest
+func (c *Client) ConvertManifest(hash string, version int) (string, error) {
+	manifest, isEncrypted, err := c.DownloadManifest(hash)
+	if err != nil {
+		return "", err
+	}
+	if err := c.convertEntries(manifest.Entries, version); err != nil {
+		return "", err
+	}
+	data, err := api.EncodeManifest(manifest, version)
+	if err != nil {
+		return "", err
+	}
+	// the converted manifest keeps the hash function of the original
+	uploader := *c
+	uploader.Hash = manifest.HashFunc
+	return uploader.UploadRaw(bytes.NewReader(data), int64(len(data)), isEncrypted)
+}
+
+// convertEntries converts the submanifests referenced by the entries and by
+// the entries of embedded submanifests
+func (c *Client) convertEntries(entries []api.ManifestEntry, version int) error {
+	for i, entry := range entries {
+		if entry.ContentType != api.ManifestType {
+			continue
+		}
+		if entry.Entries != nil {
+			if err := c.convertEntries(entry.Entries, version); err != nil {
+				return err
+			}
+			continue
+		}
+		converted, err := c.ConvertManifest(entry.Hash, version)
+		if err != nil {
+			return err
+		}
+		entries[i].Hash = converted
+	}
+	return nil
 }
 
 // List list files in a swarm manifest which have the given prefix, grouping
@@ -390,6 +641,9 @@ func (u UploaderFunc) Upload(upload UploadFn) error {
 type DirectoryUploader struct {
 	Dir         string
 	DefaultPath string
+	// Links uploads the symbolic links as links instead of the files they
+	// point to
+	Links bool
 }
 
 // Upload performs the upload of the directory and default path
@@ -410,7 +664,12 @@ func (d *DirectoryUploader) Upload(upload UploadFn) error {
 		if f.IsDir() {
 			return nil
 		}
-		file, err := Open(path)
+		var file *File
+		if d.Links && f.Mode()&os.ModeSymlink != 0 {
+			file, err = openLink(path, f)
+		} else {
+			file, err = Open(path)
+		}
 		if err != nil {
 			return err
 		}
@@ -423,6 +682,24 @@ func (d *DirectoryUploader) Upload(upload UploadFn) error {
 	})
 }
 
+// openLink opens a symbolic link to upload it as a link, its content is the
+// target of the link
+func openLink(path string, f os.FileInfo) (*File, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		ReadCloser: ioutil.NopCloser(strings.NewReader(target)),
+		ManifestEntry: api.ManifestEntry{
+			Mode:       int64(f.Mode().Perm()),
+			Size:       int64(len(target)),
+			ModTime:    f.ModTime(),
+			LinkTarget: target,
+		},
+	}, nil
+}
+
 // FileUploader uploads a single file
 type FileUploader struct {
 	File *File
@@ -441,6 +718,16 @@ type UploadFn func(file *File) error
 // TarUpload uses the given Uploader to upload files to swarm as a tar stream,
 // returning the resulting manifest hash
 func (c *Client) TarUpload(hash string, uploader Uploader, toEncrypt bool) (string, error) {
+	receipt, err := c.TarUploadReceipt(hash, uploader, toEncrypt)
+	if err != nil {
+		return "", err
+	}
+	return receipt.Address, nil
+}
+
+// TarUploadReceipt uploads files to swarm as a tar stream like TarUpload and
+// returns the receipt of the upload
+func (c *Client) TarUploadReceipt(hash string, uploader Uploader, toEncrypt bool) (*api.UploadReceipt, error) {
 	reqR, reqW := io.Pipe()
 	defer reqR.Close()
 	addr := hash
@@ -454,9 +741,12 @@ func (c *Client) TarUpload(hash string, uploader Uploader, toEncrypt bool) (stri
 	}
 	req, err := http.NewRequest("POST", c.Gateway+"/bzz:/"+addr, reqR)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set("Accept", "application/json")
+	c.setStoreHeaders(req)
+	c.setManifestHeaders(req)
 
 	// use 'Expect: 100-continue' so we don't send the request body if
 	// the server refuses the request
@@ -475,6 +765,18 @@ func (c *Client) TarUpload(hash string, uploader Uploader, toEncrypt bool) (stri
 				"user.swarm.content-type": file.ContentType,
 			},
 		}
+		if file.ContentDisposition != "" {
+			hdr.Xattrs["user.swarm.content-disposition"] = file.ContentDisposition
+		}
+		if len(file.Preload) > 0 {
+			hdr.Xattrs["user.swarm.preload"] = strings.Join(file.Preload, ",")
+		}
+		if file.LinkTarget != "" {
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = file.LinkTarget
+			hdr.Size = 0
+			return tw.WriteHeader(hdr)
+		}
 		if err := tw.WriteHeader(hdr); err != nil {
 			return err
 		}
@@ -492,19 +794,11 @@ func (c *Client) TarUpload(hash string, uploader Uploader, toEncrypt bool) (stri
 		reqW.CloseWithError(err)
 	}()
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
-	}
-	data, err := ioutil.ReadAll(res.Body)
+	res, err := c.do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(data), nil
+	return decodeReceipt(res)
 }
 
 // MultipartUpload uses the given Uploader to upload files to swarm as a
@@ -523,6 +817,8 @@ func (c *Client) MultipartUpload(hash string, uploader Uploader) (string, error)
 
 	mw := multipart.NewWriter(reqW)
 	req.Header.Set("Content-Type", fmt.Sprintf("multipart/form-data; boundary=%q", mw.Boundary()))
+	c.setStoreHeaders(req)
+	c.setManifestHeaders(req)
 
 	// define an UploadFn which adds files to the multipart form
 	uploadFn := func(file *File) error {
@@ -548,7 +844,52 @@ func (c *Client) MultipartUpload(hash string, uploader Uploader) (string, error)
 		reqW.CloseWithError(err)
 	}()
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Delete removes the given path from the swarm manifest with the given hash,
+// returning the resulting manifest hash
+func (c *Client) Delete(hash, path string) (string, error) {
+	req, err := http.NewRequest("DELETE", c.Gateway+"/bzz:/"+hash+"/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Move moves the given path, or directory, to the new path in the swarm
+// manifest with the given hash, returning the resulting manifest hash
+func (c *Client) Move(hash, from, to string) (string, error) {
+	req, err := http.NewRequest("MOVE", c.Gateway+"/bzz:/"+hash+"/"+from, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Destination", "/bzz:/"+hash+"/"+to)
+	res, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -562,3 +903,367 @@ func (c *Client) MultipartUpload(hash string, uploader Uploader) (string, error)
 	}
 	return string(data), nil
 }
+
+// ResourceManifest returns the hash of the manifest the latest multihash
+// update of the given mutable resource points to
+func (c *Client) ResourceManifest(resource string) (string, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-resource:/" + resource)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	addr, err := multihash.FromMultihash(data)
+	if err != nil {
+		return "", fmt.Errorf("resource update is not a multihash: %s", err)
+	}
+	return hex.EncodeToString(addr), nil
+}
+
+// UpdateResourceManifest publishes a multihash update of the given mutable
+// resource which points to the manifest with the given hash
+func (c *Client) UpdateResourceManifest(resource, hash string) error {
+	addr, err := hex.DecodeString(hash)
+	if err != nil {
+		return err
+	}
+	data := hexutil.Encode(multihash.ToMultihash(addr))
+	res, err := c.post(c.Gateway+"/bzz-resource:/"+resource, "text/plain", strings.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// CreateResource creates a mutable resource with the given name which is
+// updated every frequency blocks and returns the hash of its manifest. The
+// resource gets a first update with the data signed by the node unless the
+// data is empty, multihash updates point to the swarm hash in the data.
+func (c *Client) CreateResource(name string, frequency uint64, data []byte, isMultihash bool) (string, error) {
+	path := fmt.Sprintf("%s/%d", name, frequency)
+	if !isMultihash {
+		path = fmt.Sprintf("%s/raw/%d", name, frequency)
+	}
+	res, err := c.post(c.Gateway+"/bzz-resource:/"+path, "text/plain", resourceBody(data, isMultihash))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var manifest storage.Address
+	if err := json.NewDecoder(res.Body).Decode(&manifest); err != nil {
+		return "", err
+	}
+	return manifest.Hex(), nil
+}
+
+// UpdateResource publishes an update of the given mutable resource signed
+// by the node
+func (c *Client) UpdateResource(resource string, data []byte, isMultihash bool) error {
+	return c.postResourceUpdate(resource, data, isMultihash, nil)
+}
+
+// UpdateResourceSigned publishes an update of the given single-author
+// mutable resource signed by the client with the given signer
+func (c *Client) UpdateResourceSigned(resource string, data []byte, isMultihash bool, signer mru.Signer) error {
+	info, err := c.ResourceInfo(resource)
+	if err != nil {
+		return err
+	}
+	if info.Next == nil {
+		return fmt.Errorf("the next update of resource %s is unknown", resource)
+	}
+	// the signed digest covers the decoded multihash
+	digestData := data
+	if isMultihash {
+		digestData = multihash.ToMultihash(data)
+	}
+	signature, err := signer.Sign(mru.UpdateDigest(info.Next.Addr, digestData))
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("signature", hexutil.Encode(signature[:]))
+	query.Set("period", strconv.FormatUint(uint64(info.Next.Period), 10))
+	query.Set("version", strconv.FormatUint(uint64(info.Next.Version), 10))
+	return c.postResourceUpdate(resource, data, isMultihash, query)
+}
+
+func (c *Client) postResourceUpdate(resource string, data []byte, isMultihash bool, query url.Values) error {
+	uri := c.Gateway + "/bzz-resource:/" + resource
+	if !isMultihash {
+		uri += "/raw"
+	}
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	res, err := c.post(uri, "text/plain", resourceBody(data, isMultihash))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// resourceBody returns the body of a resource update request, multihash
+// updates are sent hex encoded
+func resourceBody(data []byte, isMultihash bool) io.Reader {
+	if isMultihash && len(data) > 0 {
+		return strings.NewReader(hexutil.Encode(multihash.ToMultihash(data)))
+	}
+	return bytes.NewReader(data)
+}
+
+// LookupResource returns the data of an update of the given mutable
+// resource, the latest one if period is 0 and the latest one of the period
+// if version is 0. The lookup of the latest update walks back no further
+// than the period hint if it is not 0.
+func (c *Client) LookupResource(resource string, period, version, hint uint32) ([]byte, error) {
+	uri := c.Gateway + "/bzz-resource:/" + resource
+	switch {
+	case version > 0:
+		uri += fmt.Sprintf("/%d/%d", period, version)
+	case period > 0:
+		uri += fmt.Sprintf("/%d", period)
+	case hint > 0:
+		uri += fmt.Sprintf("?hint=%d", hint)
+	}
+	res, err := http.DefaultClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// ResourceInfo describes the given mutable resource and its latest update
+func (c *Client) ResourceInfo(resource string) (*mru.Info, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-resource:/" + resource + "?meta=1")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	info := &mru.Info{}
+	if err := json.NewDecoder(res.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// SyncResult lists the paths which were changed by a directory sync
+type SyncResult struct {
+	Added   []string
+	Updated []string
+	Removed []string
+}
+
+// SyncDirectory makes the swarm manifest with the given hash mirror the
+// contents of a local directory, returning the resulting manifest hash.
+//
+// Only files which are missing from the manifest or whose content hash
+// differs are uploaded and paths which no longer exist locally are removed,
+// so that unchanged parts of the manifest are reused as is. If manifest is
+// empty, the whole directory is uploaded to a new manifest.
+//
+// Content hashes are calculated locally with the hash function of the
+// manifest, sparse if its content is, but without encryption, so manifests
+// containing encrypted content will always be uploaded in full.
+func (c *Client) SyncDirectory(dir, manifest string) (string, *SyncResult, error) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return "", nil, err
+	} else if !stat.IsDir() {
+		return "", nil, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	remote := make(map[string]*api.ManifestEntry)
+	var hashFunc string
+	ctx := context.TODO()
+	if manifest != "" {
+		root, _, err := c.DownloadManifest(manifest)
+		if err != nil {
+			return "", nil, err
+		}
+		hashFunc = root.HashFunc
+		ctx = storage.WithSparse(ctx, root.Sparse)
+		if err := c.collectEntries(root.Entries, "", remote); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// hash local files and compare them to the manifest entries
+	result := &SyncResult{}
+	fileStore := storage.NewFileStore(storage.NewMapChunkStore(), storage.NewFileStoreParams())
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		addr, _, err := fileStore.StoreWithHash(ctx, file, f.Size(), false, hashFunc)
+		if err != nil {
+			return err
+		}
+		entry, ok := remote[relPath]
+		delete(remote, relPath)
+		if !ok {
+			result.Added = append(result.Added, relPath)
+			return nil
+		}
+		hash, err := contentHash(ctx, entry, hashFunc)
+		if err != nil {
+			return err
+		}
+		if hash != addr.Hex() {
+			result.Updated = append(result.Updated, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	for path := range remote {
+		result.Removed = append(result.Removed, path)
+	}
+	sort.Strings(result.Removed)
+
+	// upload changed files in a single tar stream
+	changed := append(append([]string{}, result.Added...), result.Updated...)
+	if len(changed) > 0 || manifest == "" {
+		uploader := UploaderFunc(func(upload UploadFn) error {
+			for _, path := range changed {
+				file, err := Open(filepath.Join(dir, filepath.FromSlash(path)))
+				if err != nil {
+					return err
+				}
+				file.Path = path
+				err = upload(file)
+				file.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		manifest, err = c.TarUpload(manifest, uploader, false)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	for _, path := range result.Removed {
+		manifest, err = c.Delete(manifest, path)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return manifest, result, nil
+}
+
+// manifestEntries recursively collects all the entries in a swarm manifest,
+// keyed by their full path
+func (c *Client) manifestEntries(hash, prefix string, entries map[string]*api.ManifestEntry) error {
+	manifest, _, err := c.DownloadManifest(hash)
+	if err != nil {
+		return err
+	}
+	return c.collectEntries(manifest.Entries, prefix, entries)
+}
+
+// collectEntries collects the entries and the entries of their submanifests,
+// either embedded or referenced
+func (c *Client) collectEntries(entries []api.ManifestEntry, prefix string, collected map[string]*api.ManifestEntry) error {
+	for _, entry := range entries {
+		path := prefix + entry.Path
+		if entry.ContentType == api.ManifestType {
+			var err error
+			if entry.Entries != nil {
+				err = c.collectEntries(entry.Entries, path, collected)
+			} else {
+				err = c.manifestEntries(entry.Hash, path, collected)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// skip the default entry, it is not part of the directory tree
+		if path == "" || strings.HasSuffix(path, "/") {
+			continue
+		}
+		e := entry
+		e.Path = path
+		collected[path] = &e
+	}
+	return nil
+}
+
+// contentHash returns the hash the content of the entry has when it is
+// stored with the given hash function, which is calculated for inline
+// content as it is not stored. Composite content has no such hash.
+func contentHash(ctx context.Context, entry *api.ManifestEntry, hash string) (string, error) {
+	switch {
+	case entry.Data != nil:
+		addr, err := api.InlineAddress(ctx, entry.Data, hash)
+		if err != nil {
+			return "", err
+		}
+		return addr.Hex(), nil
+	case len(entry.Parts) > 0:
+		return "", nil
+	}
+	return entry.Hash, nil
+}
+
+// setStoreHeaders selects the chunk hash function of an upload request and
+// whether its content is stored sparse
+func (c *Client) setStoreHeaders(req *http.Request) {
+	if c.Hash != "" {
+		req.Header.Set(api.SwarmHashHeader, c.Hash)
+	}
+	if c.Sparse {
+		req.Header.Set(api.SwarmSparseHeader, "true")
+	}
+}
+
+// setManifestHeaders sets the error documents and the fallback path of the
+// manifest of an upload request
+func (c *Client) setManifestHeaders(req *http.Request) {
+	for _, doc := range c.ErrorDocuments {
+		req.Header.Add(api.SwarmErrorDocumentHeader, doc.String())
+	}
+	if c.Fallback != "" {
+		req.Header.Set(api.SwarmFallbackHeader, c.Fallback)
+	}
+}
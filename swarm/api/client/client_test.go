@@ -19,14 +19,18 @@ package client
 import (
 	"bytes"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/swarm/api"
 	swarmhttp "github.com/ethereum/go-ethereum/swarm/api/http"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 	"github.com/ethereum/go-ethereum/swarm/testutil"
 )
 
@@ -73,6 +77,49 @@ func testClientUploadDownloadRaw(toEncrypt bool, t *testing.T) {
 	}
 }
 
+// TestClientUploadReceipt tests that the receipts of raw and tar uploads
+// describe the uploaded content
+func TestClientUploadReceipt(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+
+	data := []byte("foo123")
+	receipt, err := client.UploadRawReceipt(bytes.NewReader(data), int64(len(data)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !receipt.Encrypted || receipt.Manifest || len(receipt.Address) != 128 || receipt.RootHash != receipt.Address[:64] || receipt.Chunks != 1 || receipt.Bytes != int64(len(data)) || receipt.Tag == 0 {
+		t.Fatalf("unexpected raw upload receipt %+v", receipt)
+	}
+	res, _, err := client.DownloadRaw(receipt.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Close()
+
+	file := &File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "foo.txt",
+			ContentType: "text/plain",
+			Mode:        0644,
+			Size:        int64(len(data)),
+		},
+	}
+	receipt, err = client.TarUploadReceipt("", &FileUploader{file}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Encrypted || !receipt.Manifest || len(receipt.Address) != 64 || receipt.Chunks == 0 || receipt.Bytes == 0 {
+		t.Fatalf("unexpected tar upload receipt %+v", receipt)
+	}
+	if _, err := client.Download(receipt.Address, "foo.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestClientUploadDownloadFiles test uploading and downloading files to swarm
 // manifests
 func TestClientUploadDownloadFiles(t *testing.T) {
@@ -179,6 +226,83 @@ func newTestDirectory(t *testing.T) string {
 	return dir
 }
 
+// TestClientPreserve tests that the mode, the modification time and the
+// symbolic links of a directory are restored when downloading it with
+// Preserve, and that links are files containing their target otherwise
+func TestClientPreserve(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+	modTime := time.Unix(1530000000, 0)
+	if err := os.Chmod(filepath.Join(dir, "file1.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "file1.txt"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir1/file3.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(srv.URL)
+	client.Preserve = true
+	hash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	download := func(preserve bool) string {
+		tmp, err := ioutil.TempDir("", "swarm-client-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Preserve = preserve
+		if err := client.DownloadDirectory(hash, "", tmp); err != nil {
+			os.RemoveAll(tmp)
+			t.Fatalf("error downloading directory: %s", err)
+		}
+		return tmp
+	}
+
+	tmp := download(true)
+	defer os.RemoveAll(tmp)
+	stat, err := os.Stat(filepath.Join(tmp, "file1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 || !stat.ModTime().Equal(modTime) {
+		t.Fatalf("expected mode 0600 and mod time %v, got %v and %v", modTime, stat.Mode(), stat.ModTime())
+	}
+	if target, err := os.Readlink(filepath.Join(tmp, "link.txt")); err != nil || target != "dir1/file3.txt" {
+		t.Fatalf("expected link to dir1/file3.txt, got %q (%v)", target, err)
+	}
+
+	tmp = download(false)
+	defer os.RemoveAll(tmp)
+	stat, err = os.Lstat(filepath.Join(tmp, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.Mode().IsRegular() {
+		t.Fatalf("expected a regular file, got %v", stat.Mode())
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(tmp, "link.txt")); err != nil || string(data) != "dir1/file3.txt" {
+		t.Fatalf("expected the link target as content, got %q (%v)", data, err)
+	}
+
+	// the link is downloaded as a single file too
+	link := filepath.Join(tmp, "single.txt")
+	client.Preserve = true
+	if err := client.DownloadFile(hash, "link.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "dir1/file3.txt" {
+		t.Fatalf("expected link to dir1/file3.txt, got %q (%v)", target, err)
+	}
+}
+
 // TestClientUploadDownloadDirectory tests uploading and downloading a
 // directory of files to a swarm manifest
 func TestClientUploadDownloadDirectory(t *testing.T) {
@@ -238,6 +362,295 @@ func TestClientUploadDownloadDirectory(t *testing.T) {
 	}
 }
 
+// TestClientConvertManifest tests converting the manifests of an uploaded
+// directory to the binary encoding
+func TestClientConvertManifest(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	converted, err := client.ConvertManifest(hash, api.ManifestVersion2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted == hash {
+		t.Fatal("expected converted manifest to have a different hash")
+	}
+
+	// check the root manifest and its submanifests are binary encoded
+	var checkManifest func(hash string)
+	checkManifest = func(hash string) {
+		res, err := http.Get(srv.URL + "/bzz-raw:/" + hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest, version, err := api.DecodeManifest(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != api.ManifestVersion2 {
+			t.Fatalf("expected manifest %s to have version %d, got %d", hash, api.ManifestVersion2, version)
+		}
+		for _, entry := range manifest.Entries {
+			if entry.ContentType == api.ManifestType {
+				checkManifest(entry.Hash)
+			}
+		}
+	}
+	checkManifest(converted)
+
+	// check the files are still available
+	for _, file := range testDirFiles {
+		res, err := client.Download(converted, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(res)
+		res.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte(file)) {
+			t.Fatalf("expected data to be %q, got %q", file, data)
+		}
+	}
+}
+
+// TestClientSyncDirectory tests that syncing a directory only uploads
+// changed files and removes deleted ones from the manifest
+func TestClientSyncDirectory(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	// syncing an unchanged directory is a no-op
+	newHash, result, err := client.SyncDirectory(dir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash != hash {
+		t.Fatalf("expected unchanged manifest %s, got %s", hash, newHash)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Removed) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+
+	// change, add and remove some files
+	if err := ioutil.WriteFile(filepath.Join(dir, "dir1/file3.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "dir2/new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "dir2/dir4/file7.txt")); err != nil {
+		t.Fatal(err)
+	}
+	newHash, result, err = client.SyncDirectory(dir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &SyncResult{
+		Added:   []string{"dir2/new.txt"},
+		Updated: []string{"dir1/file3.txt"},
+		Removed: []string{"dir2/dir4/file7.txt"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected sync result %+v, got %+v", expected, result)
+	}
+
+	// check the new manifest mirrors the directory
+	list, err := client.List(newHash, "dir2/dir4/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].Path != "dir2/dir4/file8.txt" {
+		t.Fatalf("unexpected entries %+v", list.Entries)
+	}
+	for path, content := range map[string]string{
+		"dir1/file3.txt": "changed",
+		"dir2/new.txt":   "new",
+		"file1.txt":      "file1.txt",
+	} {
+		file, err := client.Download(newHash, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expected %s to contain %q, got %q", path, content, data)
+		}
+	}
+}
+
+// TestClientSyncDirectoryHash tests that the files of a manifest with a
+// non-default hash function are hashed with it when syncing a directory
+func TestClientSyncDirectoryHash(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	uploader := NewClient(srv.URL)
+	uploader.Hash = storage.SHA256Hash
+	hash, err := uploader.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	client := NewClient(srv.URL)
+	newHash, result, err := client.SyncDirectory(dir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash != hash {
+		t.Fatalf("expected unchanged manifest %s, got %s", hash, newHash)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Removed) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+}
+
+// TestClientSyncDirectoryInline tests that inline entries are compared by
+// the hash of their content when syncing a directory and are not retrieved
+// separately when verifying a deploy
+func TestClientSyncDirectoryInline(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		a.SetInlineThreshold(1024)
+		return serverFunc(a)
+	})
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	entries := make(map[string]*api.ManifestEntry)
+	if err := client.manifestEntries(hash, "", entries); err != nil {
+		t.Fatal(err)
+	}
+	for path, entry := range entries {
+		if entry.Data == nil {
+			t.Fatalf("expected %s to be inline", path)
+		}
+	}
+
+	newHash, result, err := client.SyncDirectory(dir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash != hash {
+		t.Fatalf("expected unchanged manifest %s, got %s", hash, newHash)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Removed) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+
+	deployer := &Deployer{Client: client}
+	if err := deployer.verify(hash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestClientSyncDirectorySparse tests that the sparse storage of an upload
+// is recorded in its manifest, that its content is retrieved in full and that
+// syncing the directory compares the files by their sparse hashes
+func TestClientSyncDirectorySparse(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "swarm-client-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := make([]byte, 10*4096)
+	copy(data[5*4096:], "sparse")
+	if err := ioutil.WriteFile(filepath.Join(dir, "disk.img"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(srv.URL)
+	client.Sparse = true
+	hash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	root, _, err := client.DownloadManifest(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.Sparse {
+		t.Fatal("expected the manifest to be sparse")
+	}
+	file, err := client.Download(hash, "disk.img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gotData, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotData, data) {
+		t.Fatal("expected the sparse content to be retrieved in full")
+	}
+
+	// the content is stored sparse only on request
+	client.Sparse = false
+	denseHash, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	if denseHash == hash {
+		t.Fatal("expected the sparse and the dense upload to differ")
+	}
+
+	newHash, result, err := client.SyncDirectory(dir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash != hash {
+		t.Fatalf("expected unchanged manifest %s, got %s", hash, newHash)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Removed) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+
+	deployer := &Deployer{Client: client}
+	if err := deployer.verify(hash); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestClientFileList tests listing files in a swarm manifest
 func TestClientFileList(t *testing.T) {
 	testClientFileList(false, t)
@@ -354,3 +767,84 @@ func TestClientMultipartUpload(t *testing.T) {
 		checkDownloadFile(file)
 	}
 }
+
+// TestClientMissingChunks tests listing the chunks of uploaded content missing
+// from the local store of the node
+func TestClientMissingChunks(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	data := bytes.Repeat([]byte("foo123"), 2000)
+	hash, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := client.MissingChunks(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("expected no missing chunks, got %v", missing)
+	}
+
+	missing, err = client.MissingChunks(strings.Repeat("1", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0] != strings.Repeat("1", 64) {
+		t.Fatalf("expected the root chunk to be missing, got %v", missing)
+	}
+}
+
+// TestClientResource tests creating, updating, looking up and describing a
+// mutable resource
+func TestClientResource(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	resource, err := client.CreateResource("foo.eth", 13, []byte("first"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateResource(resource, []byte("second"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := client.LookupResource(resource, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Fatalf("expected latest update %q, got %q", "second", data)
+	}
+	data, err = client.LookupResource(resource, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first" {
+		t.Fatalf("expected first update %q, got %q", "first", data)
+	}
+	data, err = client.LookupResource(resource, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Fatalf("expected latest update %q with hint, got %q", "second", data)
+	}
+
+	info, err := client.ResourceInfo(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "foo.eth" || info.Frequency != 13 {
+		t.Fatalf("unexpected resource %+v", info)
+	}
+	if info.LastPeriod != 1 || info.Version != 2 {
+		t.Fatalf("expected version 2 of period 1, got %d of period %d", info.Version, info.LastPeriod)
+	}
+	if info.Next == nil || info.Next.Period != 1 || info.Next.Version != 3 {
+		t.Fatalf("expected next update version 3 of period 1, got %+v", info.Next)
+	}
+}
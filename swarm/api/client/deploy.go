@@ -0,0 +1,205 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+)
+
+// DefaultDeployKeep is the default number of previously deployed roots kept
+// in the deploy history
+const DefaultDeployKeep = 5
+
+// Deployer publishes versions of a static site behind a mutable resource.
+//
+// Each deploy uploads the site, checks that the new root is retrievable from
+// the verify gateway and only then switches the resource to the new root, so
+// the site is never pointed at content which can not be served. Deployed
+// roots are recorded in a local history file which allows rolling back to a
+// previous version without uploading anything, and are pinned on the node
+// of Client while they are in the history so that they are not garbage
+// collected.
+type Deployer struct {
+	// Client is used to upload content and to update the resource
+	Client *Client
+
+	// Verify is used to check the retrievability of uploaded content,
+	// typically a public gateway other than the one uploaded to. If nil,
+	// Client is used
+	Verify *Client
+
+	// Resource is the manifest hash or ENS name of the mutable resource
+	Resource string
+
+	// History is the path of the file the deployed roots are recorded in
+	History string
+
+	// Keep is the number of previously deployed roots kept in the history
+	Keep int
+}
+
+// Deploy uploads the given directory, verifies it and switches the resource
+// to it, returning the new root manifest hash
+func (d *Deployer) Deploy(dir, defaultPath string) (string, error) {
+	hash, err := d.Client.UploadDirectory(dir, defaultPath, "", false)
+	if err != nil {
+		return "", fmt.Errorf("error uploading %s: %s", dir, err)
+	}
+	if err := d.verify(hash); err != nil {
+		return "", fmt.Errorf("error verifying %s: %s", hash, err)
+	}
+	if err := d.Client.Pin(hash); err != nil {
+		return "", fmt.Errorf("error pinning %s: %s", hash, err)
+	}
+	if err := d.Client.UpdateResourceManifest(d.Resource, hash); err != nil {
+		d.Client.Unpin(hash)
+		return "", fmt.Errorf("error updating resource %s: %s", d.Resource, err)
+	}
+
+	history, err := d.loadHistory()
+	if err != nil {
+		return "", err
+	}
+	history = append(history, hash)
+	keep := d.Keep
+	if keep <= 0 {
+		keep = DefaultDeployKeep
+	}
+	var dropped []string
+	if len(history) > keep+1 {
+		dropped = history[:len(history)-keep-1]
+		history = history[len(history)-keep-1:]
+	}
+	if err := d.saveHistory(history); err != nil {
+		return "", err
+	}
+	return hash, d.unpin(dropped)
+}
+
+// Rollback switches the resource back to the previously deployed root and
+// returns its hash
+func (d *Deployer) Rollback() (string, error) {
+	history, err := d.loadHistory()
+	if err != nil {
+		return "", err
+	}
+	if len(history) < 2 {
+		return "", errors.New("no previous deploy to roll back to")
+	}
+	hash := history[len(history)-2]
+	if err := d.verify(hash); err != nil {
+		return "", fmt.Errorf("error verifying %s: %s", hash, err)
+	}
+	if err := d.Client.UpdateResourceManifest(d.Resource, hash); err != nil {
+		return "", fmt.Errorf("error updating resource %s: %s", d.Resource, err)
+	}
+	if err := d.saveHistory(history[:len(history)-1]); err != nil {
+		return "", err
+	}
+	return hash, d.unpin(history[len(history)-1:])
+}
+
+// unpin removes the pins of the roots which are no longer in the history
+func (d *Deployer) unpin(roots []string) error {
+	for _, hash := range roots {
+		if err := d.Client.Unpin(hash); err != nil {
+			return fmt.Errorf("error unpinning %s: %s", hash, err)
+		}
+	}
+	return nil
+}
+
+// verify checks that the manifest with the given hash and all the content it
+// references can be retrieved
+func (d *Deployer) verify(hash string) error {
+	client := d.Verify
+	if client == nil {
+		client = d.Client
+	}
+	entries := make(map[string]*api.ManifestEntry)
+	if err := client.manifestEntries(hash, "", entries); err != nil {
+		return err
+	}
+	for path, entry := range entries {
+		// inline content is retrieved with the manifest, the content of
+		// composite entries is stored in its parts
+		var hashes []string
+		switch {
+		case entry.Data != nil:
+		case len(entry.Parts) > 0:
+			hashes = entry.Parts
+		default:
+			hashes = []string{entry.Hash}
+		}
+		for _, contentHash := range hashes {
+			if err := client.retrieveRaw(contentHash); err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// retrieveRaw retrieves and discards the raw content with the given hash
+func (c *Client) retrieveRaw(hash string) error {
+	r, _, err := c.DownloadRaw(hash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+func (d *Deployer) loadHistory() ([]string, error) {
+	data, err := ioutil.ReadFile(d.History)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var history []string
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("invalid deploy history %s: %s", d.History, err)
+	}
+	return history, nil
+}
+
+func (d *Deployer) saveHistory(history []string) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.History), 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so an interrupted write can not
+	// corrupt the history
+	tmp := d.History + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.History)
+}
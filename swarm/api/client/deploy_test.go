@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/swarm/multihash"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestDeployer tests deploying site versions to a mutable resource and
+// rolling back to a previous version
+func TestDeployer(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	initial, err := client.UploadDirectory(dir, "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create the resource pointing to the initial upload
+	data := hexutil.Encode(multihash.ToMultihash(common.FromHex(initial)))
+	res, err := http.Post(srv.URL+"/bzz-resource:/foo.eth/13", "application/octet-stream", bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected HTTP status: %s", res.Status)
+	}
+	var resource storage.Address
+	if err := json.Unmarshal(body, &resource); err != nil {
+		t.Fatal(err)
+	}
+
+	historyDir, err := ioutil.TempDir("", "swarm-deploy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(historyDir)
+	deployer := &Deployer{
+		Client:   client,
+		Resource: resource.Hex(),
+		History:  filepath.Join(historyDir, "history.json"),
+		Keep:     1,
+	}
+
+	checkResource := func(expected string) {
+		hash, err := client.ResourceManifest(deployer.Resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != expected {
+			t.Fatalf("expected resource to point to %s, got %s", expected, hash)
+		}
+	}
+	checkResource(initial)
+
+	var deployed []string
+	for _, content := range []string{"v1", "v2", "v3"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "file1.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := deployer.Deploy(dir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkResource(hash)
+		deployed = append(deployed, hash)
+	}
+
+	// the roots in the history are pinned, only one previous root is kept
+	ldb := srv.FileStore.ChunkStore.(*storage.LocalStore).DbStore
+	checkPins := func(hash string, expected uint64) {
+		if count := ldb.PinCount(common.FromHex(hash)); count != expected {
+			t.Fatalf("expected %d pins of %s, got %d", expected, hash, count)
+		}
+	}
+	checkPins(deployed[0], 0)
+	checkPins(deployed[1], 1)
+	checkPins(deployed[2], 1)
+
+	hash, err := deployer.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != deployed[1] {
+		t.Fatalf("expected rollback to %s, got %s", deployed[1], hash)
+	}
+	checkResource(deployed[1])
+	checkPins(deployed[1], 1)
+	checkPins(deployed[2], 0)
+	if _, err := deployer.Rollback(); err == nil {
+		t.Fatal("expected rollback without history to fail")
+	}
+}
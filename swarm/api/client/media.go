@@ -0,0 +1,153 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+)
+
+// UploadMedia uploads a directory containing a HLS or DASH stream so that it
+// can be played directly from a swarm gateway, returning the resulting
+// manifest hash.
+//
+// Streaming files are uploaded with the content types players expect, all
+// segments referenced by HLS playlists are checked to be present and the
+// top level playlist is uploaded to the root of the manifest, so the stream
+// can be played from bzz:/<hash>/.
+func (c *Client) UploadMedia(dir string, toEncrypt bool) (string, error) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	} else if !stat.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", dir)
+	}
+	defaultPath, err := mediaDefaultPath(dir)
+	if err != nil {
+		return "", err
+	}
+	uploader := &DirectoryUploader{Dir: dir, DefaultPath: defaultPath}
+	return c.TarUpload("", UploaderFunc(func(upload UploadFn) error {
+		return uploader.Upload(func(file *File) error {
+			name := file.Path
+			if name == "" {
+				name = defaultPath
+			}
+			if contentType := api.MediaContentType(name); contentType != "" {
+				file.ContentType = contentType
+			}
+			return upload(file)
+		})
+	}), toEncrypt)
+}
+
+// hlsURIAttr matches the URI attribute of HLS tags such as EXT-X-MAP and
+// EXT-X-MEDIA
+var hlsURIAttr = regexp.MustCompile(`URI="([^"]+)"`)
+
+// mediaDefaultPath checks the playlists in dir and returns the path of the
+// top level one, which is the only playlist not referenced by another
+func mediaDefaultPath(dir string) (string, error) {
+	var playlists []string
+	referenced := make(map[string]bool)
+	err := filepath.Walk(dir, func(file string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() || !api.IsPlaylistType(api.MediaContentType(file)) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		playlists = append(playlists, rel)
+		if api.MediaContentType(file) != api.HLSPlaylistType {
+			return nil
+		}
+		uris, err := hlsPlaylistURIs(file)
+		if err != nil {
+			return err
+		}
+		for _, uri := range uris {
+			// remote segments do not have to be uploaded
+			if strings.Contains(uri, "://") {
+				continue
+			}
+			if i := strings.IndexAny(uri, "?#"); i >= 0 {
+				uri = uri[:i]
+			}
+			ref := path.Join(path.Dir(rel), uri)
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(ref))); err != nil {
+				return fmt.Errorf("%s references missing file %s", rel, uri)
+			}
+			referenced[ref] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var roots []string
+	for _, playlist := range playlists {
+		if !referenced[playlist] {
+			roots = append(roots, playlist)
+		}
+	}
+	switch len(roots) {
+	case 0:
+		return "", fmt.Errorf("no HLS playlist or DASH manifest found in %s", dir)
+	case 1:
+		return filepath.Join(dir, filepath.FromSlash(roots[0])), nil
+	default:
+		return "", fmt.Errorf("multiple top level playlists found in %s: %s", dir, strings.Join(roots, ", "))
+	}
+}
+
+// hlsPlaylistURIs returns the URIs of the segments, variant playlists and
+// other media referenced by a HLS playlist
+func hlsPlaylistURIs(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var uris []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			for _, m := range hlsURIAttr.FindAllStringSubmatch(line, -1) {
+				uris = append(uris, m[1])
+			}
+		default:
+			uris = append(uris, line)
+		}
+	}
+	return uris, scanner.Err()
+}
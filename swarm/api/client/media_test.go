@@ -0,0 +1,95 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+var testHLSFiles = map[string]string{
+	"master.m3u8":     "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh/index.m3u8\n",
+	"low/index.m3u8":  "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nseg0.ts\n#EXTINF:10,\nseg1.ts\n#EXT-X-ENDLIST\n",
+	"low/seg0.ts":     "low0",
+	"low/seg1.ts":     "low1",
+	"high/index.m3u8": "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:10,\nseg0.m4s\n#EXT-X-ENDLIST\n",
+	"high/init.mp4":   "init",
+	"high/seg0.m4s":   "high0",
+}
+
+// TestClientUploadMedia tests uploading a HLS stream
+func TestClientUploadMedia(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "swarm-media-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for file, content := range testHLSFiles {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadMedia(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkFile := func(path, content, contentType string) {
+		file, err := client.Download(hash, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expected %q to contain %q, got %q", path, content, data)
+		}
+		if file.ContentType != contentType {
+			t.Fatalf("expected %q to have content type %q, got %q", path, contentType, file.ContentType)
+		}
+	}
+	// the master playlist is served from the root of the manifest
+	checkFile("", testHLSFiles["master.m3u8"], api.HLSPlaylistType)
+	checkFile("low/index.m3u8", testHLSFiles["low/index.m3u8"], api.HLSPlaylistType)
+	checkFile("low/seg0.ts", "low0", "video/mp2t")
+	checkFile("high/seg0.m4s", "high0", "video/iso.segment")
+
+	// streams with missing segments are rejected
+	if err := os.Remove(filepath.Join(dir, "high", "init.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UploadMedia(dir, false); err == nil {
+		t.Fatal("expected upload of stream with missing segment to fail")
+	}
+}
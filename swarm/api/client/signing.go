@@ -0,0 +1,152 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+)
+
+// RequestSigner signs the write requests of a client with a signing key
+// known to the gateway
+type RequestSigner interface {
+	// KeyID returns the id the key is known by to the gateway
+	KeyID() string
+	// Sign returns the signature of the data of a request
+	Sign(data []byte) ([]byte, error)
+}
+
+type hmacSigner struct {
+	id     string
+	secret []byte
+}
+
+// NewHMACSigner returns a signer signing HMAC-SHA256 signatures with the
+// secret of the key with the given id
+func NewHMACSigner(id string, secret []byte) RequestSigner {
+	return &hmacSigner{id: id, secret: secret}
+}
+
+func (s *hmacSigner) KeyID() string { return s.id }
+
+func (s *hmacSigner) Sign(data []byte) ([]byte, error) {
+	return api.SignRequestHMAC(s.secret, data), nil
+}
+
+type ecdsaSigner struct {
+	id  string
+	key *ecdsa.PrivateKey
+}
+
+// NewECDSASigner returns a signer signing ECDSA signatures with the private
+// key of the key with the given id
+func NewECDSASigner(id string, key *ecdsa.PrivateKey) RequestSigner {
+	return &ecdsaSigner{id: id, key: key}
+}
+
+func (s *ecdsaSigner) KeyID() string { return s.id }
+
+func (s *ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	return api.SignRequestECDSA(s.key, data)
+}
+
+// do sends the request, signing it with the signer of the client if it is
+// a write request
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Signer == nil || req.Method == "GET" || req.Method == "HEAD" {
+		return http.DefaultClient.Do(req)
+	}
+	cleanup, err := c.sign(req)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return http.DefaultClient.Do(req)
+}
+
+// post sends a POST request with the given content type and body
+func (c *Client) post(uri, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
+}
+
+// sign sets the signature headers of the request. The body is spooled to a
+// temporary file to be hashed unless it can be read again, the returned
+// function removes the file.
+func (c *Client) sign(req *http.Request) (func(), error) {
+	cleanup := func() {}
+	h := sha256.New()
+	switch {
+	case req.Body == nil:
+	case req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		f, err := ioutil.TempFile("", "swarm-signed-request")
+		if err != nil {
+			return nil, err
+		}
+		cleanup = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		size, err := io.Copy(io.MultiWriter(f, h), req.Body)
+		req.Body.Close()
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(f)
+		req.ContentLength = size
+	}
+	id := c.Signer.KeyID()
+	timestamp := time.Now().Unix()
+	contentHash := h.Sum(nil)
+	sig, err := c.Signer.Sign(api.SignedRequestData(req.Method, req.URL.RequestURI(), id, timestamp, contentHash))
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	req.Header.Set(api.SwarmKeyHeader, id)
+	req.Header.Set(api.SwarmTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(api.SwarmContentHashHeader, hex.EncodeToString(contentHash))
+	req.Header.Set(api.SwarmSignatureHeader, hex.EncodeToString(sig))
+	return cleanup, nil
+}
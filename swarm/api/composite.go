@@ -0,0 +1,89 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/sctx"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// NewCompositeManifest creates and stores a manifest whose root entry is the
+// concatenation of the contents stored at the given addresses, without
+// copying them. The parts are read one after the other when the entry is
+// retrieved, see RetrieveEntry.
+func (a *API) NewCompositeManifest(ctx context.Context, parts []storage.Address, contentType string, toEncrypt bool) (storage.Address, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("composite content without parts")
+	}
+	entry := ManifestEntry{
+		ContentType: contentType,
+		Parts:       make([]string, len(parts)),
+	}
+	// the parts must exist, their sizes add up to the size of the entry
+	for i, addr := range parts {
+		reader, _ := a.Retrieve(ctx, addr)
+		size, err := reader.Size(nil)
+		if err != nil {
+			return nil, fmt.Errorf("part %d (%s): %v", i, addr, err)
+		}
+		entry.Parts[i] = addr.Hex()
+		entry.Size += size
+	}
+	log.Debug("api.composite", "ruid", sctx.GetRequestID(ctx), "parts", len(parts), "size", entry.Size)
+
+	manifest := Manifest{Entries: []ManifestEntry{entry}}
+	data, err := EncodeManifest(&manifest, a.manifestVersion)
+	if err != nil {
+		return nil, err
+	}
+	addr, wait, err := a.Store(ctx, bytes.NewReader(data), int64(len(data)), toEncrypt)
+	if err != nil {
+		return nil, err
+	}
+	return addr, wait(ctx)
+}
+
+// CompositeAddress returns the address identifying the concatenation of the
+// parts of a composite entry, the hash of the addresses of the parts
+func CompositeAddress(parts []string) storage.Address {
+	var addrs [][]byte
+	for _, part := range parts {
+		addrs = append(addrs, common.Hex2Bytes(part))
+	}
+	return storage.Address(crypto.Keccak256(addrs...))
+}
+
+// retrieveComposite returns a reader of the concatenation of the parts, the
+// content is encrypted if any of the parts is
+func (a *API) retrieveComposite(ctx context.Context, parts []string) (storage.LazySectionReader, bool) {
+	readers := make([]storage.LazySectionReader, len(parts))
+	var isEncrypted bool
+	for i, part := range parts {
+		reader, encrypted := a.Retrieve(ctx, storage.Address(common.Hex2Bytes(part)))
+		readers[i] = reader
+		isEncrypted = isEncrypted || encrypted
+	}
+	return storage.NewConcatReader(readers...), isEncrypted
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/network/stream"
 	"github.com/ethereum/go-ethereum/swarm/pss"
 	"github.com/ethereum/go-ethereum/swarm/services/swap"
 	"github.com/ethereum/go-ethereum/swarm/storage"
@@ -38,6 +39,10 @@ import (
 const (
 	DefaultHTTPListenAddr = "127.0.0.1"
 	DefaultHTTPPort       = "8500"
+
+	// DefaultShutdownGracePeriod is the default time in-flight requests
+	// and uploads are waited for on shutdown
+	DefaultShutdownGracePeriod = 30 * time.Second
 )
 
 // separate bzz directories
@@ -50,25 +55,68 @@ type Config struct {
 	Swap *swap.LocalProfile
 	Pss  *pss.PssParams
 	//*network.SyncParams
-	Contract          common.Address
-	EnsRoot           common.Address
-	EnsAPIs           []string
-	Path              string
-	ListenAddr        string
-	Port              string
-	PublicKey         string
-	BzzKey            string
-	NodeID            string
-	NetworkID         uint64
-	SwapEnabled       bool
-	SyncEnabled       bool
-	DeliverySkipCheck bool
-	SyncUpdateDelay   time.Duration
-	SwapAPI           string
-	Cors              string
-	BzzAccount        string
-	BootNodes         string
-	privateKey        *ecdsa.PrivateKey
+	Contract            common.Address
+	EnsRoot             common.Address
+	EnsAPIs             []string
+	NameRegistry        string // hex address of the metadata chunk of the name registry names are resolved with ahead of ENS, empty to disable
+	FallbackGateways    []string
+	FallbackTimeout     time.Duration
+	Path                string
+	ListenAddr          string
+	Port                string
+	S3Port              string
+	ResticPort          string
+	PublicKey           string
+	BzzKey              string
+	NodeID              string
+	NetworkID           uint64
+	PrivateSwarm        bool // keep the node out of public discovery, peers are only found through the bootnodes and the hive
+	SwapEnabled         bool
+	SyncEnabled         bool
+	PssEnabled          bool // run pss and advertise forwarding pss messages in the handshake
+	LightNode           bool // advertise the node as light, peers do not request chunks from it
+	DeliverySkipCheck   bool
+	DeliveryReceipts    bool
+	DeliveryCompression bool // deliver chunks compressed to the peers supporting it
+	SyncUpdateDelay     time.Duration
+	ShutdownGracePeriod time.Duration
+	ResyncLostBins      bool  // resync the bins lost in the recovery of a corrupted chunk database
+	SyncBatchSize       int   // maximum number of hashes offered in a sync batch
+	SyncOfferWindow     int   // number of offered hashes batches per stream in flight
+	SyncConcurrency     int   // number of sync streams per bin served concurrently
+	RetrieveFanout      int   // number of closest peers a chunk is requested from in parallel
+	MinBinSize          int   // connection target of each kademlia bin
+	MaxBinSize          int   // kademlia bins are pruned above this many peers, 0 for no limit
+	MaxBzzPeers         int   // peers are pruned above this many bzz peers, 0 for no limit
+	InlineThreshold     int64 // uploaded files up to this size are stored inline in manifest entries, 0 to disable
+	ManifestVersion     int   // encoding version of new manifests
+	ManifestFanout      int   // maximum number of entries of a manifest including embedded submanifests, 0 to store submanifests separately
+	PrefetchConcurrency int   // number of chunks retrieved concurrently when content is prefetched
+	SwapAPI             string
+	Cors                string
+	AccessLog           string   // file the access log of the HTTP API is written to, empty to disable
+	AnonymizeIPs        bool     // remove the host part of client addresses from the access log
+	TLSCert             string   // certificate file the HTTP API is served with over TLS and HTTP/2, empty to serve plain HTTP
+	TLSKey              string   // private key file of the TLS certificate
+	HTTPSocket          string   // unix socket the HTTP API is also served on, empty to disable
+	GatewayReadOnly     bool     // refuse the requests of the HTTP API which store or modify content
+	GatewayAllow        []string // root hashes and names the HTTP API only serves, empty to serve any
+	GatewayDeny         []string // root hashes and names the HTTP API refuses to serve
+	GatewayDenyList     string   // URL of a signed list of roots the HTTP API refuses to serve, empty to disable
+	GatewayDenySigner   string   // hex address of the key the deny list has to be signed with
+	TakedownToken       string   // bearer token of the takedown webhook of the HTTP API, empty to disable
+	MimeTypes           []string // extension to content type overrides as ext=type
+	MimeSniff           bool     // detect the content types of files with unknown extensions from their content
+	MimeDefault         string   // content type of files whose type is not detected
+	GatewayDomains      []string // domains whose subdomains serve the roots of the HTTP API, <root>.<domain>
+	TLSWildcardCert     string   // wildcard certificate file the subdomains of the gateway domains are served with, the TLS certificate if empty
+	TLSWildcardKey      string   // private key file of the wildcard TLS certificate
+	RequireSignedWrites bool     // refuse the write requests of the HTTP API which are not signed with a signing key
+	EncryptStore        bool     // encrypt the chunk data at rest with a key derived from the node key
+	StoreKeyCommand     string   // command printing the hex encoded key the chunk data is encrypted with at rest, instead of deriving it
+	BzzAccount          string
+	BootNodes           string
+	privateKey          *ecdsa.PrivateKey
 }
 
 //create a default config with all parameters to set to defaults
@@ -79,27 +127,41 @@ func NewConfig() (c *Config) {
 		FileStoreParams:  storage.NewFileStoreParams(),
 		HiveParams:       network.NewHiveParams(),
 		//SyncParams:    network.NewDefaultSyncParams(),
-		Swap:              swap.NewDefaultSwapParams(),
-		Pss:               pss.NewPssParams(),
-		ListenAddr:        DefaultHTTPListenAddr,
-		Port:              DefaultHTTPPort,
-		Path:              node.DefaultDataDir(),
-		EnsAPIs:           nil,
-		EnsRoot:           ens.TestNetAddress,
-		NetworkID:         network.DefaultNetworkID,
-		SwapEnabled:       false,
-		SyncEnabled:       true,
-		DeliverySkipCheck: false,
-		SyncUpdateDelay:   15 * time.Second,
-		SwapAPI:           "",
-		BootNodes:         "",
+		Swap:                swap.NewDefaultSwapParams(),
+		Pss:                 pss.NewPssParams(),
+		ListenAddr:          DefaultHTTPListenAddr,
+		Port:                DefaultHTTPPort,
+		Path:                node.DefaultDataDir(),
+		EnsAPIs:             nil,
+		EnsRoot:             ens.TestNetAddress,
+		NetworkID:           network.DefaultNetworkID,
+		SwapEnabled:         false,
+		SyncEnabled:         true,
+		PssEnabled:          true,
+		DeliverySkipCheck:   false,
+		DeliveryReceipts:    false,
+		DeliveryCompression: true,
+		SyncUpdateDelay:     15 * time.Second,
+		SyncBatchSize:       stream.BatchSize,
+		SyncOfferWindow:     stream.DefaultOfferWindow,
+		SyncConcurrency:     stream.DefaultBinConcurrency,
+		RetrieveFanout:      stream.DefaultRetrieveFanout,
+		MinBinSize:          network.NewKadParams().MinBinSize,
+		ManifestVersion:     DefaultManifestVersion,
+		MimeSniff:           true,
+		MimeDefault:         DefaultContentType,
+		SwapAPI:             "",
+		BootNodes:           "",
 	}
+	c.LocalStoreParams.DiskHighWater = storage.DefaultDiskHighWater
+	c.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	c.FallbackTimeout = storage.DefaultFallbackTimeout
 
 	return
 }
 
-//some config params need to be initialized after the complete
-//config building phase is completed (e.g. due to overriding flags)
+// some config params need to be initialized after the complete
+// config building phase is completed (e.g. due to overriding flags)
 func (c *Config) Init(prvKey *ecdsa.PrivateKey) {
 
 	address := crypto.PubkeyToAddress(prvKey.PublicKey)
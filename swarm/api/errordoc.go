@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"golang.org/x/text/language"
+)
+
+// SwarmErrorDocumentHeader is the HTTP header setting an error document of
+// the manifest of an upload, see ParseErrorDocument, it can be repeated
+const SwarmErrorDocumentHeader = "X-Swarm-Error-Document"
+
+// ErrorDocument is a document of a manifest the HTTP server serves instead
+// of an error for the paths of the manifest, like the error documents of
+// websites hosted on S3
+type ErrorDocument struct {
+	Status int    `json:"status,omitempty"` // status of the error, any error if 0
+	Lang   string `json:"lang,omitempty"`   // language tag of the document, any language if empty
+	Path   string `json:"path"`             // path of the document in the manifest
+}
+
+// ParseErrorDocument parses an error document given as status[:lang]=path,
+// e.g. 404=404.html or 404:de=de/404.html, the status is * for any error
+func ParseErrorDocument(s string) (*ErrorDocument, error) {
+	i := strings.Index(s, "=")
+	if i < 1 || i == len(s)-1 {
+		return nil, fmt.Errorf("invalid error document %q, expected status[:lang]=path", s)
+	}
+	doc := &ErrorDocument{Path: strings.TrimPrefix(s[i+1:], "/")}
+	status := s[:i]
+	if j := strings.Index(status, ":"); j != -1 {
+		tag, err := language.Parse(status[j+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid language of error document %q: %v", s, err)
+		}
+		doc.Lang, status = tag.String(), status[:j]
+	}
+	if status != "*" {
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid status of error document %q", s)
+		}
+		doc.Status = code
+	}
+	if doc.Path == "" {
+		return nil, fmt.Errorf("missing path of error document %q", s)
+	}
+	return doc, nil
+}
+
+// String returns the document in the format parsed by ParseErrorDocument
+func (d *ErrorDocument) String() string {
+	status := "*"
+	if d.Status != 0 {
+		status = strconv.Itoa(d.Status)
+	}
+	if d.Lang != "" {
+		status += ":" + d.Lang
+	}
+	return status + "=" + d.Path
+}
+
+// SelectErrorDocument returns the path of the document of docs served for an
+// error with the given status to a client accepting the languages of the
+// Accept-Language header value, or false if there is none
+//
+// The documents of the status are preferred to those of any error. Among
+// them the document in the most preferred language accepted by the client,
+// or in its base language, is selected, otherwise the document of any
+// language, otherwise the first one.
+func SelectErrorDocument(docs []ErrorDocument, status int, acceptLanguage string) (string, bool) {
+	var candidates []ErrorDocument
+	for _, code := range []int{status, 0} {
+		for _, doc := range docs {
+			if doc.Status == code {
+				candidates = append(candidates, doc)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// the tags are sorted by preference, the invalid header of a client
+	// only results in the default document
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	for _, tag := range tags {
+		lang := strings.ToLower(tag.String())
+		for _, doc := range candidates {
+			if strings.ToLower(doc.Lang) == lang {
+				return doc.Path, true
+			}
+		}
+		for _, doc := range candidates {
+			if doc.Lang != "" && baseLanguage(doc.Lang) == baseLanguage(lang) {
+				return doc.Path, true
+			}
+		}
+	}
+	for _, doc := range candidates {
+		if doc.Lang == "" {
+			return doc.Path, true
+		}
+	}
+	return candidates[0].Path, true
+}
+
+// baseLanguage returns the lowercase primary subtag of the language tag
+func baseLanguage(tag string) string {
+	if i := strings.Index(tag, "-"); i != -1 {
+		tag = tag[:i]
+	}
+	return strings.ToLower(tag)
+}
+
+// ErrorDocuments returns the error documents of the manifest
+func (a *API) ErrorDocuments(ctx context.Context, addr storage.Address) ([]ErrorDocument, error) {
+	trie, err := loadManifest(ctx, a.fileStore, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return trie.errorDocs, nil
+}
+
+// SetErrorDocument adds the error document to the manifest, replacing the
+// document of the same status and language
+func (m *ManifestWriter) SetErrorDocument(doc *ErrorDocument) {
+	docs := make([]ErrorDocument, 0, len(m.trie.errorDocs)+1)
+	for _, d := range m.trie.errorDocs {
+		if d.Status != doc.Status || !strings.EqualFold(d.Lang, doc.Lang) {
+			docs = append(docs, d)
+		}
+	}
+	m.trie.errorDocs = append(docs, *doc)
+	m.trie.ref = nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorDocument(t *testing.T) {
+	for value, expected := range map[string]ErrorDocument{
+		"404=404.html":          {Status: 404, Path: "404.html"},
+		"404:de=/de/404.html":   {Status: 404, Lang: "de", Path: "de/404.html"},
+		"500:en-us=errors.html": {Status: 500, Lang: "en-US", Path: "errors.html"},
+		"*=error.html":          {Path: "error.html"},
+	} {
+		doc, err := ParseErrorDocument(value)
+		if err != nil {
+			t.Fatalf("%s: %v", value, err)
+		}
+		if *doc != expected {
+			t.Fatalf("%s: expected %+v, got %+v", value, expected, *doc)
+		}
+		if parsed, _ := ParseErrorDocument(doc.String()); *parsed != *doc {
+			t.Fatalf("%s: %q does not parse to the document", value, doc.String())
+		}
+	}
+	for _, value := range []string{"404", "=404.html", "404=", "200=ok.html", "abc=404.html", "404:=404.html", "404:x-invalid-=404.html", "404=/"} {
+		if _, err := ParseErrorDocument(value); err == nil {
+			t.Fatalf("expected an error parsing %q", value)
+		}
+	}
+}
+
+// TestSelectErrorDocument tests the selection of error documents by status
+// and by the languages accepted by clients
+func TestSelectErrorDocument(t *testing.T) {
+	docs := []ErrorDocument{
+		{Status: 404, Path: "404.html"},
+		{Status: 404, Lang: "de", Path: "de/404.html"},
+		{Status: 404, Lang: "pt-BR", Path: "pt-br/404.html"},
+		{Path: "error.html"},
+		{Lang: "de", Path: "de/error.html"},
+	}
+	for _, test := range []struct {
+		status         int
+		acceptLanguage string
+		path           string
+	}{
+		{404, "", "404.html"},
+		{404, "fr", "404.html"},
+		{404, "de", "de/404.html"},
+		{404, "de-AT", "de/404.html"},
+		{404, "fr, de;q=0.5", "de/404.html"},
+		{404, "pt, pt-BR;q=0.9", "pt-br/404.html"},
+		{404, "en;q=0.3, pt-br", "pt-br/404.html"},
+		{404, "invalid;;", "404.html"},
+		{500, "", "error.html"},
+		{500, "de-CH", "de/error.html"},
+	} {
+		path, ok := SelectErrorDocument(docs, test.status, test.acceptLanguage)
+		if !ok || path != test.path {
+			t.Fatalf("%d %q: expected %s, got %s", test.status, test.acceptLanguage, test.path, path)
+		}
+	}
+
+	if path, _ := SelectErrorDocument(docs[1:3], 404, "en"); path != "de/404.html" {
+		t.Fatalf("expected the first document without one of any language, got %s", path)
+	}
+	if _, ok := SelectErrorDocument(docs[:3], 500, ""); ok {
+		t.Fatal("expected no document of another status")
+	}
+}
+
+// TestManifestErrorDocuments tests that the error documents of a manifest
+// are kept when its entries are modified
+func TestManifestErrorDocuments(t *testing.T) {
+	testAPI(t, func(a *API, toEncrypt bool) {
+		ctx := context.Background()
+		addr, err := a.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		update := func(fn func(mw *ManifestWriter)) {
+			mw, err := a.NewManifestWriter(ctx, addr, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fn(mw)
+			if addr, err = mw.Store(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		update(func(mw *ManifestWriter) {
+			mw.SetErrorDocument(&ErrorDocument{Status: 404, Path: "404.html"})
+			mw.SetErrorDocument(&ErrorDocument{Status: 404, Lang: "de", Path: "404.html"})
+			mw.SetErrorDocument(&ErrorDocument{Status: 404, Lang: "DE", Path: "de/404.html"})
+		})
+		update(func(mw *ManifestWriter) {
+			for _, path := range []string{"404.html", "de/404.html", "dir/index.html"} {
+				if _, err := mw.AddEntry(ctx, strings.NewReader(path), &ManifestEntry{Path: path, Size: int64(len(path))}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+
+		docs, err := a.ErrorDocuments(ctx, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []ErrorDocument{
+			{Status: 404, Path: "404.html"},
+			{Status: 404, Lang: "DE", Path: "de/404.html"},
+		}
+		if !reflect.DeepEqual(docs, expected) {
+			t.Fatalf("expected error documents %+v, got %+v", expected, docs)
+		}
+	})
+}
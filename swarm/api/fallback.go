@@ -0,0 +1,48 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// SwarmFallbackHeader is the HTTP header setting the fallback path of the
+// manifest of an upload
+const SwarmFallbackHeader = "X-Swarm-Fallback"
+
+// DefaultFallback is the fallback path of single-page applications
+const DefaultFallback = "index.html"
+
+// Fallback returns the path of the entry of the manifest served instead of
+// its unresolved paths, an empty string if the manifest has none
+func (a *API) Fallback(ctx context.Context, addr storage.Address) (string, error) {
+	trie, err := loadManifest(ctx, a.fileStore, addr, nil)
+	if err != nil {
+		return "", err
+	}
+	return trie.fallback, nil
+}
+
+// SetFallback sets the path of the entry of the manifest served instead of
+// its unresolved paths, an empty path unsets it
+func (m *ManifestWriter) SetFallback(path string) {
+	m.trie.fallback = strings.TrimPrefix(path, "/")
+	m.trie.ref = nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+// FeedPollInterval is the interval at which subscribed feeds are looked up
+// in the neighbourhood of their update chunks
+var FeedPollInterval = 5 * time.Second
+
+// FeedUpdate is the notification sent to the subscribers of a feed
+type FeedUpdate struct {
+	Name    string        `json:"name"`
+	Key     hexutil.Bytes `json:"key"`
+	Period  uint32        `json:"period"`
+	Version uint32        `json:"version"`
+	Data    hexutil.Bytes `json:"data"`
+}
+
+// ResourceLatest looks up the latest update of the Mutable Resource with the
+// given metadata chunk address
+func (a *API) ResourceLatest(ctx context.Context, addr storage.Address) (*FeedUpdate, error) {
+	rsrc, err := a.resource.Load(addr)
+	if err != nil {
+		return nil, err
+	}
+	return a.resourceLatest(ctx, rsrc.NameHash())
+}
+
+// resourceLatest looks up the latest update of a loaded Mutable Resource
+func (a *API) resourceLatest(ctx context.Context, nameHash common.Hash) (*FeedUpdate, error) {
+	rsrc, err := a.resource.LookupLatest(ctx, nameHash, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	index := nameHash.Hex()
+	key, data, err := a.resource.GetContent(index)
+	if err != nil {
+		return nil, err
+	}
+	period, err := a.resource.GetLastPeriod(index)
+	if err != nil {
+		return nil, err
+	}
+	version, err := a.resource.GetVersion(index)
+	if err != nil {
+		return nil, err
+	}
+	return &FeedUpdate{
+		Name:    rsrc.Name(),
+		Key:     hexutil.Bytes(key),
+		Period:  period,
+		Version: version,
+		Data:    hexutil.Bytes(data),
+	}, nil
+}
+
+// FeedAPI pushes Mutable Resource updates to subscribers in the bzz namespace
+type FeedAPI struct {
+	api *API
+}
+
+// NewFeedAPI creates a new FeedAPI
+func NewFeedAPI(api *API) *FeedAPI {
+	return &FeedAPI{api: api}
+}
+
+// SubscribeFeed creates a subscription which gets a notification for every
+// new update of the Mutable Resource with the given metadata chunk address
+//
+// The feed is looked up every FeedPollInterval, so that clients do not have
+// to poll the lookup themselves
+func (f *FeedAPI) SubscribeFeed(ctx context.Context, addr string) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rsrc, err := f.api.resource.Load(storage.Address(common.FromHex(addr)))
+	if err != nil {
+		return nil, err
+	}
+	nameHash := rsrc.NameHash()
+
+	// the update current at the time of subscribing is not notified
+	var last []byte
+	if update, err := f.api.resourceLatest(ctx, nameHash); err == nil {
+		last = update.Key
+	}
+
+	sub := notifier.CreateSubscription()
+	go func() {
+		ticker := time.NewTicker(FeedPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+			update, err := f.api.resourceLatest(context.Background(), nameHash)
+			if err != nil {
+				if rerr, ok := err.(*mru.Error); !ok || rerr.Code() != mru.ErrNotFound {
+					log.Debug("feed subscription lookup", "addr", addr, "err", err)
+				}
+				continue
+			}
+			if bytes.Equal(update.Key, last) {
+				continue
+			}
+			last = update.Key
+			if err := notifier.Notify(sub.ID, update); err != nil {
+				log.Warn("rpc sub notifier notify feed update", "err", err)
+			}
+		}
+	}()
+	return sub, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+type fakeHeaderGetter struct {
+	blocknumber int64
+}
+
+func (f *fakeHeaderGetter) HeaderByNumber(context.Context, string, *big.Int) (*types.Header, error) {
+	f.blocknumber++
+	return &types.Header{Number: big.NewInt(f.blocknumber)}, nil
+}
+
+// TestSubscribeFeed tests that subscribers are notified of new resource
+// updates but not of the update current when subscribing
+func TestSubscribeFeed(t *testing.T) {
+	defer func(interval time.Duration) { FeedPollInterval = interval }(FeedPollInterval)
+	FeedPollInterval = 10 * time.Millisecond
+
+	datadir, err := ioutil.TempDir("", "bzz-feed-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	rh, err := mru.NewTestHandler(datadir, &mru.HandlerParams{
+		QueryMaxPeriods: &mru.LookupParams{},
+		HeaderGetter:    &fakeHeaderGetter{blocknumber: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rh.Close()
+	a := NewAPI(nil, nil, rh)
+
+	ctx := context.Background()
+	addr, err := a.ResourceCreate(ctx, "feed.eth", 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.ResourceUpdate(ctx, "feed.eth", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("bzz", NewFeedAPI(a)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	updates := make(chan FeedUpdate, 10)
+	sub, err := client.Subscribe(ctx, "bzz", updates, "subscribeFeed", addr.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// let a few polls pass, the current update must not be notified
+	time.Sleep(10 * FeedPollInterval)
+	if _, _, _, err := a.ResourceUpdate(ctx, "feed.eth", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case update := <-updates:
+		if update.Name != "feed.eth" || string(update.Data) != "second" {
+			t.Fatalf("expected update 'second' of feed.eth, got '%s' of %s", update.Data, update.Name)
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for feed update")
+	}
+}
@@ -21,13 +21,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"sync"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 )
@@ -123,15 +121,7 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 				err = wait(ctx)
 				awg.Done()
 				if err == nil {
-					first512 := make([]byte, 512)
-					fread, _ := f.ReadAt(first512, 0)
-					if fread > 0 {
-						mimeType := http.DetectContentType(first512[:fread])
-						if filepath.Ext(entry.Path) == ".css" {
-							mimeType = "text/css"
-						}
-						list[i].ContentType = mimeType
-					}
+					list[i].ContentType = fs.api.mime.DetectReaderAt(entry.Path, f)
 				}
 				f.Close()
 			}
@@ -146,6 +136,8 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 
 	trie := &manifestTrie{
 		fileStore: fs.api.fileStore,
+		version:   fs.api.manifestVersion,
+		fanout:    fs.api.manifestFanout,
 	}
 	quitC := make(chan bool)
 	for i, entry := range list {
@@ -209,8 +201,8 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 	}
 
 	type downloadListEntry struct {
-		addr storage.Address
-		path string
+		entry ManifestEntry
+		path  string
 	}
 
 	var list []*downloadListEntry
@@ -220,7 +212,6 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 	err = trie.listWithPrefix(path, quitC, func(entry *manifestTrieEntry, suffix string) {
 		log.Trace(fmt.Sprintf("fs.Download: %#v", entry))
 
-		addr = common.Hex2Bytes(entry.Hash)
 		path := lpath + "/" + suffix
 		dir := filepath.Dir(path)
 		if dir != prevPath {
@@ -228,7 +219,7 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 			prevPath = dir
 		}
 		if (mde == nil) && (path != dir+"/") {
-			list = append(list, &downloadListEntry{addr: addr, path: path})
+			list = append(list, &downloadListEntry{entry: entry.ManifestEntry, path: path})
 		}
 	})
 	if err != nil {
@@ -247,7 +238,8 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 		}
 		go func(i int, entry *downloadListEntry) {
 			defer wg.Done()
-			err := retrieveToFile(quitC, fs.api.fileStore, entry.addr, entry.path)
+			reader, _ := fs.api.RetrieveEntry(context.TODO(), &entry.entry)
+			err := retrieveToFile(quitC, reader, entry.path)
 			if err != nil {
 				select {
 				case errC <- err:
@@ -270,12 +262,11 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 	}
 }
 
-func retrieveToFile(quitC chan bool, fileStore *storage.FileStore, addr storage.Address, path string) error {
+func retrieveToFile(quitC chan bool, reader storage.LazySectionReader, path string) error {
 	f, err := os.Create(path) // TODO: basePath separators
 	if err != nil {
 		return err
 	}
-	reader, _ := fileStore.Retrieve(context.TODO(), addr)
 	writer := bufio.NewWriter(f)
 	size, err := reader.Size(quitC)
 	if err != nil {
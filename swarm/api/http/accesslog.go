@@ -0,0 +1,219 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// AccessStatsPath is the path of the rollup of the access log
+	AccessStatsPath = "/access-stats"
+
+	// maxAccessStatsContent is the number of content roots the rollup
+	// keeps statistics of, the least requested one is dropped for a new one
+	maxAccessStatsContent = 10000
+
+	// accessStatsTop is the number of content roots in the rollup
+	accessStatsTop = 100
+)
+
+// AccessStats is the rollup of the requests served by the HTTP API since
+// the access log was enabled
+type AccessStats struct {
+	Since        time.Time       `json:"since"`
+	Requests     uint64          `json:"requests"`
+	Bytes        uint64          `json:"bytes"`
+	Statuses     map[int]uint64  `json:"statuses"`
+	ErrorRate    float64         `json:"errorRate"`    // ratio of responses with a 5xx status
+	CacheHitRate float64         `json:"cacheHitRate"` // ratio of responses served from a cache
+	TopContent   []*ContentStats `json:"topContent"`
+}
+
+// ContentStats are the statistics of the GET requests of content with a
+// root, a swarm hash or a name resolved through ENS
+type ContentStats struct {
+	Content  string `json:"content"`
+	Requests uint64 `json:"requests"`
+	Bytes    uint64 `json:"bytes"`
+	Errors   uint64 `json:"errors"` // responses with a 4xx or 5xx status
+}
+
+// accessLog writes a structured log record for every request served by the
+// HTTP API and keeps the rollup of the records
+//
+// Records contain the hash of the request path rather than the path, the
+// client address is anonymized if configured.
+type accessLog struct {
+	logger    log.Logger
+	anonymize bool
+
+	mu        sync.Mutex
+	since     time.Time
+	requests  uint64
+	bytes     uint64
+	statuses  map[int]uint64
+	cacheHits uint64
+	errors    uint64
+	content   map[string]*ContentStats
+}
+
+// EnableAccessLog writes the access log of the server with the given handler
+// and enables the rollup of the log at AccessStatsPath
+//
+// If anonymize is true the host part of client addresses is removed from
+// the records.
+func (s *Server) EnableAccessLog(h log.Handler, anonymize bool) {
+	logger := log.New()
+	logger.SetHandler(h)
+	s.accessLog = &accessLog{
+		logger:    logger,
+		anonymize: anonymize,
+		since:     time.Now(),
+		statuses:  make(map[int]uint64),
+		content:   make(map[string]*ContentStats),
+	}
+}
+
+// record logs a served request and adds it to the rollup
+func (a *accessLog) record(r *Request, w *loggingResponseWriter, latency time.Duration) {
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	if a.anonymize {
+		client = anonymizeIP(client)
+	}
+	var content string
+	if r.uri != nil && r.uri.Addr != "" {
+		content = r.uri.Scheme + ":/" + r.uri.Addr
+	}
+	a.logger.Info("access",
+		"ruid", r.ruid,
+		"client", client,
+		"method", r.Method,
+		"content", content,
+		"path", common.Bytes2Hex(crypto.Keccak256([]byte(r.URL.Path))),
+		"status", w.statusCode,
+		"bytes", w.bytes,
+		"latency", latency,
+		"cache", w.cacheHit,
+	)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests++
+	a.bytes += uint64(w.bytes)
+	a.statuses[w.statusCode]++
+	if w.cacheHit {
+		a.cacheHits++
+	}
+	if w.statusCode >= 500 {
+		a.errors++
+	}
+	if content == "" || r.Method != http.MethodGet {
+		return
+	}
+	c, ok := a.content[content]
+	if !ok {
+		if len(a.content) >= maxAccessStatsContent {
+			var least *ContentStats
+			for _, c := range a.content {
+				if least == nil || c.Requests < least.Requests {
+					least = c
+				}
+			}
+			delete(a.content, least.Content)
+		}
+		c = &ContentStats{Content: content}
+		a.content[content] = c
+	}
+	c.Requests++
+	c.Bytes += uint64(w.bytes)
+	if w.statusCode >= 400 {
+		c.Errors++
+	}
+}
+
+// rollup returns a copy of the rollup with the most requested content
+func (a *accessLog) rollup() *AccessStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := &AccessStats{
+		Since:    a.since,
+		Requests: a.requests,
+		Bytes:    a.bytes,
+		Statuses: make(map[int]uint64, len(a.statuses)),
+	}
+	for status, n := range a.statuses {
+		stats.Statuses[status] = n
+	}
+	if a.requests > 0 {
+		stats.ErrorRate = float64(a.errors) / float64(a.requests)
+		stats.CacheHitRate = float64(a.cacheHits) / float64(a.requests)
+	}
+	for _, c := range a.content {
+		cc := *c
+		stats.TopContent = append(stats.TopContent, &cc)
+	}
+	sort.Slice(stats.TopContent, func(i, j int) bool {
+		if stats.TopContent[i].Requests != stats.TopContent[j].Requests {
+			return stats.TopContent[i].Requests > stats.TopContent[j].Requests
+		}
+		return stats.TopContent[i].Content < stats.TopContent[j].Content
+	})
+	if len(stats.TopContent) > accessStatsTop {
+		stats.TopContent = stats.TopContent[:accessStatsTop]
+	}
+	return stats
+}
+
+// HandleAccessStats responds with the rollup of the access log as JSON
+func (s *Server) HandleAccessStats(w http.ResponseWriter, r *Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.accessLog.rollup())
+}
+
+// anonymizeIP removes the host part of an IP address, keeping the /24
+// network of IPv4 and the /48 network of IPv6 addresses
+func anonymizeIP(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// markCacheHit marks the response as served from a cache in the access log
+func markCacheHit(w http.ResponseWriter) {
+	if lrw, ok := w.(*loggingResponseWriter); ok {
+		lrw.cacheHit = true
+	}
+}
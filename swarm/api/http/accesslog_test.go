@@ -0,0 +1,149 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	for addr, expect := range map[string]string{
+		"192.168.1.42":                 "192.168.1.0",
+		"::ffff:10.0.0.1":              "10.0.0.0",
+		"2001:db8:85a3:1:2:8a2e:370:1": "2001:db8:85a3::",
+		"fe80::1ff:fe23:4567:890a":     "fe80::",
+		"not an address":               "",
+	} {
+		if got := anonymizeIP(addr); got != expect {
+			t.Fatalf("%s: expected %q, got %q", addr, expect, got)
+		}
+	}
+}
+
+// TestAccessLog tests the records of the access log and its rollup
+func TestAccessLog(t *testing.T) {
+	var mu sync.Mutex
+	var records []map[string]interface{}
+	handler := log.FuncHandler(func(r *log.Record) error {
+		record := make(map[string]interface{})
+		for i := 0; i < len(r.Ctx); i += 2 {
+			record[r.Ctx[i].(string)] = r.Ctx[i+1]
+		}
+		mu.Lock()
+		records = append(records, record)
+		mu.Unlock()
+		return nil
+	})
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server := NewServer(a)
+		server.EnableAccessLog(handler, true)
+		return server
+	})
+	defer srv.Close()
+
+	data := "access log"
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := string(body)
+	get := func(path, etag string) *http.Response {
+		req, err := http.NewRequest("GET", srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	res = get("/bzz-raw:/"+hash, "")
+	res.Body.Close()
+	get("/bzz-raw:/"+hash, res.Header.Get("ETag")).Body.Close()
+	get("/bzz-raw:/"+strings.Repeat("1", 64), "").Body.Close()
+
+	mu.Lock()
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	for i, expect := range []struct {
+		method string
+		status int
+		bytes  int64
+		cache  bool
+	}{
+		{"POST", http.StatusOK, 64, false},
+		{"GET", http.StatusOK, int64(len(data)), false},
+		{"GET", http.StatusNotModified, 0, true},
+		{"GET", http.StatusNotFound, -1, false},
+	} {
+		record := records[i]
+		if record["method"] != expect.method || record["status"] != expect.status || record["cache"] != expect.cache {
+			t.Fatalf("record %d: expected %s with status %d, cache %v, got %v", i, expect.method, expect.status, expect.cache, record)
+		}
+		if expect.bytes >= 0 && record["bytes"] != expect.bytes {
+			t.Fatalf("record %d: expected %d bytes, got %v", i, expect.bytes, record["bytes"])
+		}
+		if record["client"] != "127.0.0.0" {
+			t.Fatalf("record %d: expected anonymized client address, got %v", i, record["client"])
+		}
+		if path, _ := record["path"].(string); len(path) != 64 || strings.Contains(path, hash) {
+			t.Fatalf("record %d: expected hashed path, got %v", i, record["path"])
+		}
+	}
+	mu.Unlock()
+
+	res = get(AccessStatsPath, "")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for the rollup, got %s", res.Status)
+	}
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats AccessStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Requests != 4 || stats.Statuses[http.StatusNotModified] != 1 || stats.CacheHitRate != 0.25 {
+		t.Fatalf("unexpected rollup %s", body)
+	}
+	top := fmt.Sprintf("bzz-raw:/%s", hash)
+	if len(stats.TopContent) != 2 || stats.TopContent[0].Content != top || stats.TopContent[0].Requests != 2 {
+		t.Fatalf("expected %s to be the top content, got %s", top, body)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+)
+
+var getNotModified = metrics.NewRegisteredCounter("api.http.get.notmodified", nil)
+
+// setValidators sets the ETag of a response to the immutable reference the
+// request was resolved to and its Last-Modified time if it is known
+//
+// Responses to mutable URLs, resolved through ENS or a mutable resource,
+// must be revalidated by caches, which are then able to keep serving them
+// with a conditional request for as long as the name resolves to the same
+// reference.
+func setValidators(w http.ResponseWriter, r *Request, etag string, modTime time.Time) {
+	w.Header().Set("ETag", fmt.Sprintf("%q", etag))
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if r.uri.Address() == nil {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// mediaMaxAge is the max-age of the playlists and segments of immutable
+// roots, a year as recommended for immutable responses
+const mediaMaxAge = 365 * 24 * time.Hour
+
+// setMediaCacheControl lets shared caches and players keep the playlists and
+// segments of adaptive streaming content served from an immutable root
+//
+// The playlists behind mutable names are revalidated as set by
+// setValidators, and the responses which depend on the credentials of the
+// request are kept private.
+func setMediaCacheControl(w http.ResponseWriter, r *Request, contentType string) {
+	if r.uri.Address() == nil || w.Header().Get("Cache-Control") == "private" || !api.IsMediaType(contentType) {
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(mediaMaxAge.Seconds())))
+}
+
+// checkNotModified responds with 304 Not Modified and returns true if the
+// conditional headers of the request match the validators of the response
+//
+// As in RFC 7232, If-Modified-Since is only evaluated if the request has no
+// If-None-Match header.
+func checkNotModified(w http.ResponseWriter, r *Request, etag string, modTime time.Time) bool {
+	if !notModified(&r.Request, etag, modTime) {
+		return false
+	}
+	getNotModified.Inc(1)
+	markCacheHit(w)
+	Respond(w, r, "Not Modified", http.StatusNotModified)
+	return true
+}
+
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modTime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// the Last-Modified header has a resolution of seconds
+	return !modTime.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether the list of entity tags of an If-None-Match
+// header contains the etag, using the weak comparison
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if strings.Trim(tag, `"`) == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+func TestNotModified(t *testing.T) {
+	etag := "8b634aea26eec353ac0ecbec20c94f44d6f8d11f38d4578a4c207a84c74ef731"
+	modTime := time.Date(2018, 6, 1, 12, 0, 0, 500, time.UTC)
+	for _, x := range []struct {
+		method  string
+		header  map[string]string
+		modTime time.Time
+		expect  bool
+	}{
+		{"GET", nil, modTime, false},
+		{"GET", map[string]string{"If-None-Match": `"` + etag + `"`}, modTime, true},
+		{"HEAD", map[string]string{"If-None-Match": `"` + etag + `"`}, modTime, true},
+		{"POST", map[string]string{"If-None-Match": `"` + etag + `"`}, modTime, false},
+		{"GET", map[string]string{"If-None-Match": `W/"` + etag + `"`}, modTime, true},
+		{"GET", map[string]string{"If-None-Match": `"abcd", "` + etag + `"`}, modTime, true},
+		{"GET", map[string]string{"If-None-Match": "*"}, modTime, true},
+		{"GET", map[string]string{"If-None-Match": `"abcd"`}, modTime, false},
+		{"GET", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, modTime, true},
+		{"GET", map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, modTime, false},
+		{"GET", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, time.Time{}, false},
+		// If-None-Match takes precedence over If-Modified-Since
+		{"GET", map[string]string{"If-None-Match": `"abcd"`, "If-Modified-Since": modTime.Format(http.TimeFormat)}, modTime, false},
+	} {
+		r, err := http.NewRequest(x.method, "http://localhost/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range x.header {
+			r.Header.Set(k, v)
+		}
+		if got := notModified(r, etag, x.modTime); got != x.expect {
+			t.Fatalf("%s %v: expected %v, got %v", x.method, x.header, x.expect, got)
+		}
+	}
+}
+
+// TestBzzConditionalGet tests that responses carry the validators of the
+// immutable content they were resolved to and that matching conditional
+// requests are answered with 304 Not Modified
+func TestBzzConditionalGet(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	modTime := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := []byte("conditional")
+	client := swarm.NewClient(srv.URL)
+	hash, err := client.Upload(&swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "file.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+			ModTime:     modTime,
+		},
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string, header map[string]string) *http.Response {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for _, url := range []string{
+		fmt.Sprintf("%s/bzz:/%s/file.txt", srv.URL, hash),
+		fmt.Sprintf("%s/bzz-raw:/%s/file.txt", srv.URL, hash),
+	} {
+		res := get(url, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %s", url, res.Status)
+		}
+		etag := res.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: missing ETag", url)
+		}
+		if lastModified := res.Header.Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+			t.Fatalf("%s: expected Last-Modified %q, got %q", url, modTime.Format(http.TimeFormat), lastModified)
+		}
+
+		if res := get(url, map[string]string{"If-None-Match": etag}); res.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: expected status 304 for matching etag, got %s", url, res.Status)
+		}
+		if res := get(url, map[string]string{"If-None-Match": `"abcd"`}); res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status 200 for other etag, got %s", url, res.Status)
+		}
+		if res := get(url, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}); res.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: expected status 304 for unmodified content, got %s", url, res.Status)
+		}
+		if res := get(url, map[string]string{"If-Modified-Since": modTime.Add(-time.Second).Format(http.TimeFormat)}); res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status 200 for modified content, got %s", url, res.Status)
+		}
+	}
+
+	// the etag of the latest update of a resource changes with updates
+	url := fmt.Sprintf("%s/bzz-resource:/conditional.eth/raw/13", srv.URL)
+	res, err := http.Post(url, "application/octet-stream", bytes.NewReader([]byte("first")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 creating resource, got %s", res.Status)
+	}
+	var manifestAddr storage.Address
+	if err := json.NewDecoder(res.Body).Decode(&manifestAddr); err != nil {
+		t.Fatal(err)
+	}
+	url = fmt.Sprintf("%s/bzz-resource:/%s", srv.URL, manifestAddr)
+	res = get(url, nil)
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" || res.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected revalidated response with etag, got %s with etag %q and cache control %q", res.Status, etag, res.Header.Get("Cache-Control"))
+	}
+	if res := get(url, map[string]string{"If-None-Match": etag}); res.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected status 304 for matching etag, got %s", res.Status)
+	}
+	res, err = http.Post(url+"/raw", "application/octet-stream", bytes.NewReader([]byte("second")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 updating resource, got %s", res.Status)
+	}
+	if res := get(url, map[string]string{"If-None-Match": etag}); res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Fatalf("expected status 200 with new etag after update, got %s with etag %q", res.Status, res.Header.Get("ETag"))
+	}
+}
+
+// TestMediaCacheControl tests that the playlists and segments of immutable
+// roots are cached as immutable by shared caches
+func TestMediaCacheControl(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	files := map[string]string{
+		"stream.m3u8":  api.HLSPlaylistType,
+		"segment0.ts":  "video/mp2t",
+		"index.html":   "text/html",
+		"manifest.mpd": api.DASHManifestType,
+	}
+	uploader := swarm.UploaderFunc(func(upload swarm.UploadFn) error {
+		for path, contentType := range files {
+			err := upload(&swarm.File{
+				ReadCloser: ioutil.NopCloser(bytes.NewReader([]byte(path))),
+				ManifestEntry: api.ManifestEntry{
+					Path:        path,
+					ContentType: contentType,
+					Size:        int64(len(path)),
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	hash, err := swarm.NewClient(srv.URL).TarUpload("", uploader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{
+		"stream.m3u8":  "public, max-age=31536000, immutable",
+		"segment0.ts":  "public, max-age=31536000, immutable",
+		"manifest.mpd": "public, max-age=31536000, immutable",
+		"index.html":   "max-age=2147483648, immutable",
+	} {
+		res, err := http.Get(fmt.Sprintf("%s/bzz:/%s/%s", srv.URL, hash, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected status %s", path, res.Status)
+		}
+		if cc := res.Header.Get("Cache-Control"); cc != expected {
+			t.Fatalf("%s: expected cache control %q, got %q", path, expected, cc)
+		}
+	}
+}
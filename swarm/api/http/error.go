@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/api"
 	l "github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
 //templateMap holds a mapping of an HTTP error code to a template
@@ -49,8 +50,36 @@ type ResponseParams struct {
 	Msg       string
 	Code      int
 	Timestamp string
-	template  *template.Template
-	Details   template.HTML
+	RequestID string
+	// Error is the machine readable name of the cause of a typed error,
+	// Address and Peer are the chunk or manifest and the peer it refers to
+	Error    string `json:",omitempty"`
+	Address  string `json:",omitempty"`
+	Peer     string `json:",omitempty"`
+	template *template.Template
+	Details  template.HTML
+}
+
+// errorStatus is the HTTP status code and the machine readable name of the
+// cause of typed errors of the storage and api layers
+type errorStatus struct {
+	code int
+	name string
+}
+
+var errorStatuses = map[error]errorStatus{
+	storage.ErrChunkNotFound:     {http.StatusNotFound, "chunk_not_found"},
+	storage.ErrChunkUnavailable:  {http.StatusNotFound, "chunk_unavailable"},
+	storage.ErrChunkTimeout:      {http.StatusGatewayTimeout, "chunk_timeout"},
+	storage.ErrChunkInvalid:      {http.StatusBadGateway, "chunk_invalid"},
+	storage.ErrCapacityExhausted: {http.StatusInsufficientStorage, "capacity_exhausted"},
+	storage.ErrReadOnly:          {http.StatusForbidden, "read_only"},
+	storage.ErrShuttingDown:      {http.StatusServiceUnavailable, "shutting_down"},
+	storage.ErrStoreClosed:       {http.StatusServiceUnavailable, "store_closed"},
+	api.ErrManifestNotFound:      {http.StatusNotFound, "manifest_not_found"},
+	api.ErrInvalidManifest:       {http.StatusUnprocessableEntity, "manifest_invalid"},
+	api.ErrEntryNotFound:         {http.StatusNotFound, "entry_not_found"},
+	api.ErrEntryExists:           {http.StatusConflict, "entry_exists"},
 }
 
 //a custom error case struct that would be used to store validators and
@@ -143,12 +172,36 @@ func ShowMultipleChoices(w http.ResponseWriter, req *Request, list api.ManifestL
 //The code is used to evaluate which template will be displayed
 //(and return the correct HTTP status code)
 func Respond(w http.ResponseWriter, req *Request, msg string, code int) {
+	respondError(w, req, &ResponseParams{Code: code, Msg: msg})
+}
+
+//RespondError responds with the message like Respond, the status code and the
+//machine readable fields are the ones of the typed storage or api error err
+//is caused by, code is used if err is not typed
+func RespondError(w http.ResponseWriter, req *Request, msg string, err error, code int) {
+	params := &ResponseParams{Code: code, Msg: msg}
+	if s, ok := errorStatuses[storage.Cause(err)]; ok {
+		params.Code = s.code
+		params.Error = s.name
+	}
+	switch e := err.(type) {
+	case *storage.ChunkError:
+		params.Address = e.Addr.Hex()
+		params.Peer = e.Peer
+	case *api.ManifestError:
+		params.Address = e.Addr.Hex()
+	}
+	respondError(w, req, params)
+}
+
+func respondError(w http.ResponseWriter, req *Request, params *ResponseParams) {
+	msg, code := params.Msg, params.Code
 	additionalMessage := ValidateCaseErrors(req)
 	switch code {
 	case http.StatusInternalServerError:
-		log.Output(msg, log.LvlError, l.CallDepth, "ruid", req.ruid, "code", code)
+		log.Output(msg, log.LvlError, l.CallDepth+1, "ruid", req.ruid, "code", code)
 	case http.StatusMultipleChoices:
-		log.Output(msg, log.LvlDebug, l.CallDepth, "ruid", req.ruid, "code", code)
+		log.Output(msg, log.LvlDebug, l.CallDepth+1, "ruid", req.ruid, "code", code)
 		listURI := api.URI{
 			Scheme: "bzz-list",
 			Addr:   req.uri.Addr,
@@ -156,7 +209,7 @@ func Respond(w http.ResponseWriter, req *Request, msg string, code int) {
 		}
 		additionalMessage = fmt.Sprintf(`<a href="/%s">multiple choices</a>`, listURI.String())
 	default:
-		log.Output(msg, log.LvlDebug, l.CallDepth, "ruid", req.ruid, "code", code)
+		log.Output(msg, log.LvlDebug, l.CallDepth+1, "ruid", req.ruid, "code", code)
 	}
 
 	if code >= 400 {
@@ -164,13 +217,11 @@ func Respond(w http.ResponseWriter, req *Request, msg string, code int) {
 		w.Header().Del("ETag")
 	}
 
-	respond(w, &req.Request, &ResponseParams{
-		Code:      code,
-		Msg:       msg,
-		Details:   template.HTML(additionalMessage),
-		Timestamp: time.Now().Format(time.RFC1123),
-		template:  getTemplate(code),
-	})
+	params.Details = template.HTML(additionalMessage)
+	params.Timestamp = time.Now().Format(time.RFC1123)
+	params.RequestID = req.ruid
+	params.template = getTemplate(code)
+	respond(w, &req.Request, params)
 }
 
 //evaluate if client accepts html or json response
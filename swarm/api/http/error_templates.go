@@ -165,6 +165,7 @@ func GetGenericErrorPage() string {
         </div>
         <div class="header-right">
           <div id="timestamp">{{.Timestamp}}</div>
+          {{if .RequestID}}<div id="request-id">Request ID: {{.RequestID}}</div>{{end}}
         </div>
       </header>
 
@@ -343,6 +344,7 @@ func GetNotFoundErrorPage() string {
         </div>
         <div class="header-right">
           <div id="timestamp">{{.Timestamp}}</div>
+          {{if .RequestID}}<div id="request-id">Request ID: {{.RequestID}}</div>{{end}}
         </div>
       </header>
 
@@ -516,6 +518,7 @@ func GetMultipleChoicesErrorPage() string {
         </div>
         <div class="header-right">
           <div id="timestamp">{{.Timestamp}}</div>
+          {{if .RequestID}}<div id="request-id">Request ID: {{.RequestID}}</div>{{end}}
         </div>
       </header>
 
@@ -18,13 +18,17 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"golang.org/x/net/html"
 
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 	"github.com/ethereum/go-ethereum/swarm/testutil"
 )
 
@@ -167,6 +171,42 @@ func TestJsonResponse(t *testing.T) {
 
 }
 
+// TestRespondError tests that typed errors are responded with the status
+// code and the machine readable name of their cause
+func TestRespondError(t *testing.T) {
+	addr := storage.Address(make([]byte, 32))
+	for _, tc := range []struct {
+		err  error
+		code int
+		name string
+	}{
+		{&storage.ChunkError{Op: "get", Addr: addr, Err: storage.ErrChunkNotFound}, http.StatusNotFound, "chunk_not_found"},
+		{&storage.ChunkError{Op: "get", Addr: addr, Peer: "peer", Err: storage.ErrChunkTimeout}, http.StatusGatewayTimeout, "chunk_timeout"},
+		{&storage.ChunkError{Op: "store", Addr: addr, Err: storage.ErrCapacityExhausted}, http.StatusInsufficientStorage, "capacity_exhausted"},
+		{storage.ErrShuttingDown, http.StatusServiceUnavailable, "shutting_down"},
+		{&api.ManifestError{Addr: addr, Path: "a", Err: api.ErrEntryNotFound}, http.StatusNotFound, "entry_not_found"},
+		{errors.New("untyped"), http.StatusInternalServerError, ""},
+	} {
+		r, _ := http.NewRequest("GET", "/bzz:/a", nil)
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		RespondError(w, &Request{Request: *r, uri: &api.URI{Scheme: "bzz"}}, tc.err.Error(), tc.err, http.StatusInternalServerError)
+		if w.Code != tc.code {
+			t.Fatalf("%v: expected status %d, got %d", tc.err, tc.code, w.Code)
+		}
+		var params ResponseParams
+		if err := json.Unmarshal(w.Body.Bytes(), &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.Error != tc.name || params.Code != tc.code {
+			t.Fatalf("%v: expected error %q, code %d, got %q, %d", tc.err, tc.name, tc.code, params.Error, params.Code)
+		}
+		if cerr, ok := tc.err.(*storage.ChunkError); ok && (params.Address != addr.Hex() || params.Peer != cerr.Peer) {
+			t.Fatalf("%v: expected address %s and peer %q, got %s and %q", tc.err, addr.Hex(), cerr.Peer, params.Address, params.Peer)
+		}
+	}
+}
+
 func isJSON(s string) bool {
 	var js map[string]interface{}
 	return json.Unmarshal([]byte(s), &js) == nil
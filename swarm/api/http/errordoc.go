@@ -0,0 +1,80 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var getFileErrorDocument = metrics.NewRegisteredCounter("api.http.get.file.errordocument", nil)
+
+// uploadErrorDocuments returns the error documents set with the
+// X-Swarm-Error-Document headers of an upload request
+func uploadErrorDocuments(r *Request) ([]*api.ErrorDocument, error) {
+	var docs []*api.ErrorDocument
+	for _, value := range r.Header[api.SwarmErrorDocumentHeader] {
+		doc, err := api.ParseErrorDocument(value)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// serveErrorDocument responds to a request of a path of the manifest which
+// failed with the status with the error document of the manifest in the
+// language preferred by the client, it returns false if the manifest has no
+// error document for the status which can be served
+func (s *Server) serveErrorDocument(ctx context.Context, w http.ResponseWriter, r *Request, manifestAddr storage.Address, status int) bool {
+	docs, err := s.api.ErrorDocuments(ctx, manifestAddr)
+	if err != nil || len(docs) == 0 {
+		return false
+	}
+	path, ok := api.SelectErrorDocument(docs, status, r.Header.Get("Accept-Language"))
+	if !ok {
+		return false
+	}
+	reader, contentType, docStatus, _, _, err := s.api.Get(ctx, manifestAddr, path)
+	if err != nil || docStatus == http.StatusMultipleChoices {
+		log.Warn("cannot retrieve error document", "ruid", r.ruid, "key", manifestAddr, "path", path, "err", err)
+		return false
+	}
+	size, err := reader.Size(nil)
+	if err != nil {
+		log.Warn("cannot retrieve error document", "ruid", r.ruid, "key", manifestAddr, "path", path, "err", err)
+		return false
+	}
+	getFileErrorDocument.Inc(1)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		io.Copy(w, io.NewSectionReader(reader, 0, size))
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestErrorDocument tests that the error documents set at upload are served
+// in the language preferred by the client for the unresolved paths
+func TestErrorDocument(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	files := map[string]string{
+		"index.html":  "<h1>home</h1>",
+		"404.html":    "<h1>not found</h1>",
+		"de/404.html": "<h1>nicht gefunden</h1>",
+	}
+	uploader := swarm.UploaderFunc(func(upload swarm.UploadFn) error {
+		for path, content := range files {
+			file := &swarm.File{
+				ReadCloser: ioutil.NopCloser(strings.NewReader(content)),
+				ManifestEntry: api.ManifestEntry{
+					Path:        path,
+					ContentType: "text/html",
+					Size:        int64(len(content)),
+				},
+			}
+			if err := upload(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	client := swarm.NewClient(srv.URL)
+	plain, err := client.TarUpload("", uploader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"404=404.html", "404:de=de/404.html"} {
+		doc, err := api.ParseErrorDocument(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.ErrorDocuments = append(client.ErrorDocuments, doc)
+	}
+	site, err := client.TarUpload("", uploader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path           string
+		acceptLanguage string
+		status         int
+		body           string
+	}{
+		{site + "/index.html", "de", http.StatusOK, files["index.html"]},
+		{site + "/missing", "", http.StatusNotFound, files["404.html"]},
+		{site + "/app/route", "fr, de-DE;q=0.8", http.StatusNotFound, files["de/404.html"]},
+		{plain + "/missing", "", http.StatusNotFound, ""},
+	} {
+		req, err := http.NewRequest("GET", srv.URL+"/bzz:/"+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.status {
+			t.Fatalf("%s: expected status %d, got %d", test.path, test.status, res.StatusCode)
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Fatalf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if test.body == "" && strings.Contains(string(body), files["404.html"]) {
+			t.Fatalf("%s: unexpected error document", test.path)
+		}
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/bzz:/", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set(api.SwarmErrorDocumentHeader, "200=index.html")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d uploading an invalid error document, got %d", http.StatusBadRequest, res.StatusCode)
+	}
+}
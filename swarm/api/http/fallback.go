@@ -0,0 +1,56 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var getFileFallback = metrics.NewRegisteredCounter("api.http.get.file.fallback", nil)
+
+// fallbackPath returns the path of the entry of the manifest served instead
+// of the unresolved path of the request, an empty string if there is none
+//
+// It is the fallback path of the manifest, unless the request toggles the
+// single-page application mode with the spa query parameter: spa=false
+// disables the fallback and spa=true falls back to index.html if the
+// manifest has no fallback path.
+func (s *Server) fallbackPath(ctx context.Context, r *Request, manifestAddr storage.Address) string {
+	query := r.URL.Query().Get("spa")
+	spa, err := strconv.ParseBool(query)
+	if query != "" && (err != nil || !spa) {
+		return ""
+	}
+	fallback, err := s.api.Fallback(ctx, manifestAddr)
+	if err != nil {
+		log.Debug("cannot retrieve fallback path", "ruid", r.ruid, "key", manifestAddr, "err", err)
+		return ""
+	}
+	if fallback == "" && spa {
+		fallback = api.DefaultFallback
+	}
+	if fallback != "" {
+		getFileFallback.Inc(1)
+	}
+	return fallback
+}
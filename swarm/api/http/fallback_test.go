@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestFallback tests that the unresolved paths of single-page applications
+// are served the fallback entry of their manifest, or index.html if the
+// request enables the fallback
+func TestFallback(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	files := map[string]string{
+		"index.html": "<div id=app></div>",
+		"app.js":     "route(location.pathname)",
+	}
+	uploader := swarm.UploaderFunc(func(upload swarm.UploadFn) error {
+		for path, content := range files {
+			file := &swarm.File{
+				ReadCloser: ioutil.NopCloser(strings.NewReader(content)),
+				ManifestEntry: api.ManifestEntry{
+					Path: path,
+					Size: int64(len(content)),
+				},
+			}
+			if err := upload(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	client := swarm.NewClient(srv.URL)
+	plain, err := client.TarUpload("", uploader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Fallback = api.DefaultFallback
+	spa, err := client.TarUpload("", uploader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{spa + "/app.js", http.StatusOK, files["app.js"]},
+		{spa + "/users/42/profile", http.StatusOK, files["index.html"]},
+		{spa + "/users/42/profile?spa=false", http.StatusNotFound, ""},
+		{plain + "/users/42/profile", http.StatusNotFound, ""},
+		{plain + "/users/42/profile?spa=true", http.StatusOK, files["index.html"]},
+		{plain + "/users/42/profile?spa=invalid", http.StatusNotFound, ""},
+	} {
+		res, err := http.Get(srv.URL + "/bzz:/" + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.status {
+			t.Fatalf("%s: expected status %d, got %d", test.path, test.status, res.StatusCode)
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Fatalf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if test.status == http.StatusOK && !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") && test.body == files["index.html"] {
+			t.Fatalf("%s: expected the content type of index.html, got %q", test.path, res.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+var (
+	policyDeniedCount     = metrics.NewRegisteredCounter("api.http.policy.denied", nil)
+	policyNotAllowedCount = metrics.NewRegisteredCounter("api.http.policy.notallowed", nil)
+	policyReadOnlyCount   = metrics.NewRegisteredCounter("api.http.policy.readonly", nil)
+)
+
+// denyListCheckInterval is the minimum time between two fetches of the
+// remote deny list
+var denyListCheckInterval = 10 * time.Minute
+
+var errInvalidDenyList = errors.New("invalid deny list signature")
+
+// DenyList is a list of roots a gateway refuses to serve, signed by its
+// publisher so that it can be distributed over untrusted channels
+type DenyList struct {
+	Roots     []string      `json:"roots"`     // root hashes and names
+	Timestamp uint64        `json:"timestamp"` // unix time in seconds the list was signed at
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// NewDenyList creates a deny list of the given roots signed with key
+func NewDenyList(roots []string, key *ecdsa.PrivateKey) (*DenyList, error) {
+	l := &DenyList{
+		Roots:     roots,
+		Timestamp: uint64(time.Now().Unix()),
+	}
+	sig, err := crypto.Sign(l.digest(), key)
+	if err != nil {
+		return nil, err
+	}
+	l.Signature = sig
+	return l, nil
+}
+
+// digest returns the hash of the signed fields of the list
+func (l *DenyList) digest() []byte {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, l.Timestamp)
+	data := [][]byte{ts}
+	for _, root := range l.Roots {
+		data = append(data, crypto.Keccak256([]byte(root)))
+	}
+	return crypto.Keccak256(data...)
+}
+
+// Verify checks that the list was signed by the key with the given address
+func (l *DenyList) Verify(signer common.Address) error {
+	pub, err := crypto.SigToPub(l.digest(), l.Signature)
+	if err != nil {
+		return errInvalidDenyList
+	}
+	if crypto.PubkeyToAddress(*pub) != signer {
+		return errInvalidDenyList
+	}
+	return nil
+}
+
+// GatewayPolicy decides which requests a public gateway serves, for
+// operators which are legally constrained in the content they serve:
+//
+//   - a read-only gateway refuses the requests which store or modify content
+//   - if the allow list is not empty only the listed roots are served
+//   - the roots of the deny list are refused, the deny list is the union of
+//     a local list and a list fetched from a URL and signed by a trusted key
+//
+// Roots are root hashes or names, requests of names are refused if either
+// the name or the hash it resolves to is denied. The policy is evaluated
+// before any content is retrieved.
+type GatewayPolicy struct {
+	readOnly bool
+	allow    map[string]bool
+	deny     map[string]bool
+
+	remoteURL    string
+	remoteSigner common.Address
+	client       *http.Client
+
+	mu         sync.RWMutex
+	remote     map[string]bool
+	remoteTime uint64 // timestamp of the current remote list
+	checkedAt  time.Time
+	fetching   bool
+
+	blocklist *api.Blocklist
+}
+
+// NewGatewayPolicy creates a policy with the given allow and deny lists
+func NewGatewayPolicy(readOnly bool, allow, deny []string) *GatewayPolicy {
+	return &GatewayPolicy{
+		readOnly: readOnly,
+		allow:    rootSet(allow),
+		deny:     rootSet(deny),
+		remote:   make(map[string]bool),
+		client:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+// rootSet returns the set of the normalised roots
+func rootSet(roots []string) map[string]bool {
+	set := make(map[string]bool, len(roots))
+	for _, root := range roots {
+		if root = normalizeRoot(root); root != "" {
+			set[root] = true
+		}
+	}
+	return set
+}
+
+// normalizeRoot returns hashes in lower case hex without prefix and names
+// in lower case
+func normalizeRoot(root string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(root)), "0x")
+}
+
+// SetDenyListURL loads the deny list signed by signer from url and fetches
+// it again every denyListCheckInterval, lists which are not newer than the
+// current one are ignored
+func (p *GatewayPolicy) SetDenyListURL(url string, signer common.Address) error {
+	p.remoteURL, p.remoteSigner = url, signer
+	return p.fetchDenyList()
+}
+
+// fetchDenyList fetches, verifies and applies the remote deny list
+func (p *GatewayPolicy) fetchDenyList() error {
+	res, err := p.client.Get(p.remoteURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status fetching deny list: %s", res.Status)
+	}
+	var list DenyList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return err
+	}
+	if err := list.Verify(p.remoteSigner); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checkedAt = time.Now()
+	if list.Timestamp <= p.remoteTime {
+		return nil
+	}
+	p.remote, p.remoteTime = rootSet(list.Roots), list.Timestamp
+	log.Info("loaded deny list", "url", p.remoteURL, "roots", len(p.remote), "timestamp", list.Timestamp)
+	return nil
+}
+
+// refreshDenyList fetches the remote deny list in the background if it was
+// last fetched more than denyListCheckInterval ago
+func (p *GatewayPolicy) refreshDenyList() {
+	if p.remoteURL == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fetching || time.Since(p.checkedAt) < denyListCheckInterval {
+		return
+	}
+	p.fetching = true
+	go func() {
+		if err := p.fetchDenyList(); err != nil {
+			log.Error("cannot fetch deny list", "url", p.remoteURL, "err", err)
+		}
+		p.mu.Lock()
+		p.fetching = false
+		p.checkedAt = time.Now()
+		p.mu.Unlock()
+	}()
+}
+
+// SetBlocklist denies the roots blocked by the operator in b as well
+func (p *GatewayPolicy) SetBlocklist(b *api.Blocklist) {
+	p.blocklist = b
+}
+
+// denied reports whether the root is on the deny list or blocked
+func (p *GatewayPolicy) denied(root string) bool {
+	if p.blocklist != nil && p.blocklist.Blocked(root) {
+		return true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.deny[root] || p.remote[root]
+}
+
+// check returns the status and message the request is refused with, or
+// zero if the policy lets it be served
+func (p *GatewayPolicy) check(ctx context.Context, a *api.API, r *Request) (int, string) {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		if p.readOnly {
+			policyReadOnlyCount.Inc(1)
+			return http.StatusMethodNotAllowed, fmt.Sprintf("%s method not allowed on a read-only gateway", r.Method)
+		}
+	}
+
+	p.refreshDenyList()
+	uris := []*api.URI{r.uri}
+	fallbacks, err := fallbackURIs(r)
+	if err == nil {
+		uris = append(uris, fallbacks...)
+	}
+	for _, uri := range uris {
+		if status, msg := p.checkRoot(ctx, a, uri); status != 0 {
+			log.Debug("request refused by gateway policy", "ruid", r.ruid, "root", uri.Addr, "status", status)
+			return status, msg
+		}
+	}
+	return 0, ""
+}
+
+// checkRoot checks the root of the uri against the allow and deny lists
+func (p *GatewayPolicy) checkRoot(ctx context.Context, a *api.API, uri *api.URI) (int, string) {
+	if uri.Addr == "" || uri.Addr == "encrypt" {
+		// uploads of new content
+		return 0, ""
+	}
+	roots := []string{normalizeRoot(uri.Addr)}
+	if uri.Address() == nil {
+		// the hash a name resolves to is checked as well, names which
+		// cannot be resolved are only checked by name
+		if addr, err := a.Resolve(ctx, &api.URI{Scheme: "bzz", Addr: uri.Addr}); err == nil {
+			roots = append(roots, addr.Hex())
+		}
+	}
+	for _, root := range roots {
+		if p.denied(root) {
+			policyDeniedCount.Inc(1)
+			return http.StatusUnavailableForLegalReasons, fmt.Sprintf("%s is not available on this gateway", uri.Addr)
+		}
+	}
+	if len(p.allow) == 0 {
+		return 0, ""
+	}
+	for _, root := range roots {
+		if p.allow[root] {
+			return 0, ""
+		}
+	}
+	policyNotAllowedCount.Inc(1)
+	return http.StatusForbidden, fmt.Sprintf("%s is not served by this gateway", uri.Addr)
+}
+
+// SetPolicy sets the policy the requests of the server are checked with
+func (s *Server) SetPolicy(p *GatewayPolicy) {
+	s.policy = p
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestGatewayPolicy tests that the requests refused by the policy of a
+// gateway are refused with the right status
+func TestGatewayPolicy(t *testing.T) {
+	var server *Server
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server = NewServer(a)
+		return server
+	})
+	defer srv.Close()
+
+	upload := func(data string) string {
+		res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	allowed, denied, remote := upload("allowed"), upload("denied"), upload("remote")
+
+	// the remote deny list is only applied with the signature of the
+	// trusted key
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewDenyList([]string{remote}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer listSrv.Close()
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewGatewayPolicy(true, nil, []string{"0x" + strings.ToUpper(denied)})
+	if err := policy.SetDenyListURL(listSrv.URL, crypto.PubkeyToAddress(other.PublicKey)); err != errInvalidDenyList {
+		t.Fatalf("expected %v, got %v", errInvalidDenyList, err)
+	}
+	if err := policy.SetDenyListURL(listSrv.URL, crypto.PubkeyToAddress(key.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetPolicy(policy)
+
+	expect := func(method, path string, status int) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: expected status %d, got %d", method, path, status, res.StatusCode)
+		}
+	}
+	expect("GET", "/bzz-raw:/"+allowed, http.StatusOK)
+	expect("GET", "/bzz-raw:/"+denied, http.StatusUnavailableForLegalReasons)
+	expect("GET", "/bzz-raw:/"+remote, http.StatusUnavailableForLegalReasons)
+	expect("GET", "/bzz:/"+allowed+"/?fallback="+denied, http.StatusUnavailableForLegalReasons)
+	expect("POST", "/bzz-raw:/", http.StatusMethodNotAllowed)
+
+	// with an allow list only the listed roots are served
+	server.SetPolicy(NewGatewayPolicy(false, []string{allowed}, nil))
+	expect("GET", "/bzz-raw:/"+allowed, http.StatusOK)
+	expect("GET", "/bzz-raw:/"+remote, http.StatusForbidden)
+	expect("GET", "/bzz-chunk:/"+remote, http.StatusForbidden)
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var preloadPushCount = metrics.NewRegisteredCounter("api.http.preload.push", nil)
+
+// preload starts retrieving the dependencies the entry declares into the
+// local store and announces them to the client with Link preload headers,
+// clients connected over HTTP/2 have them pushed. The dependencies are
+// linked under the given root, it must be called before the response is
+// written.
+func (s *Server) preload(ctx context.Context, w http.ResponseWriter, r *Request, root string, manifestAddr storage.Address, entry *api.ManifestEntry) {
+	deps, err := s.api.Preload(ctx, manifestAddr, entry)
+	if err != nil {
+		log.Debug("preload failed", "ruid", r.ruid, "key", manifestAddr, "err", err)
+		return
+	}
+	pusher, _ := w.(http.Pusher)
+	for _, dep := range deps {
+		target := fmt.Sprintf("/%s:/%s/%s", r.uri.Scheme, root, dep.Path)
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=preload; as=%s", target, preloadDestination(dep.ContentType)))
+		if pusher == nil {
+			continue
+		}
+		if err := pusher.Push(target, nil); err != nil {
+			// the client disabled push or the connection is not HTTP/2
+			pusher = nil
+			continue
+		}
+		preloadPushCount.Inc(1)
+	}
+}
+
+// preloadPaths returns the paths of the dependencies given as a comma
+// separated list relative to the path of an upload
+func preloadPaths(base, list string) []string {
+	var paths []string
+	for _, dep := range strings.Split(list, ",") {
+		if dep = strings.TrimSpace(dep); dep != "" {
+			paths = append(paths, path.Join(base, dep))
+		}
+	}
+	return paths
+}
+
+// preloadDestination returns the destination of a preload link of content
+// with the given type
+func preloadDestination(contentType string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "text/css":
+		return "style"
+	case strings.HasSuffix(mediaType, "javascript"):
+		return "script"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "font/"), strings.Contains(mediaType, "font-woff"):
+		return "font"
+	default:
+		return "fetch"
+	}
+}
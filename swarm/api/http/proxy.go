@@ -0,0 +1,212 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// ProxyHost is the host of the URLs which are served by the proxy with the
+// manifest in the first path segment, http://bzz/<manifest>/<path>, so
+// browsers can navigate to content hashes which are too long to be hosts
+const ProxyHost = "bzz"
+
+// ProxyPACPath is the path the proxy serves its proxy auto-config file at
+const ProxyPACPath = "/proxy.pac"
+
+// Proxy is an HTTP proxy serving swarm URLs from a gateway, so browsers
+// configured to use it can navigate to them:
+//
+//   - bzz://<manifest>/<path> as sent by clients handling the bzz scheme
+//   - http://<name>.<tld>/<path> for the ENS top level domains of the proxy
+//   - http://bzz/<manifest>/<path>
+//
+// The URLs are translated to bzz:/<manifest>/<path> requests to the gateway,
+// requests to other hosts are refused. Browsers can be configured with the
+// proxy auto-config file served at ProxyPACPath, which only sends the swarm
+// URLs through the proxy.
+type Proxy struct {
+	gateway *url.URL
+	tlds    []string
+	proxy   *httputil.ReverseProxy
+}
+
+// NewProxy returns a proxy for the gateway serving the names with the given
+// top level domains
+func NewProxy(gateway string, tlds []string) (*Proxy, error) {
+	u, err := url.Parse(strings.TrimRight(gateway, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid gateway URL %q", gateway)
+	}
+	p := &Proxy{
+		gateway: u,
+		tlds:    tlds,
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Director:       func(*http.Request) {}, // requests are rewritten by ServeHTTP
+		ModifyResponse: p.modifyResponse,
+	}
+	return p, nil
+}
+
+// ServeHTTP serves the proxy auto-config file and the requests for swarm
+// URLs
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() && r.URL.Path == ProxyPACPath {
+		p.servePAC(w, r)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		http.Error(w, "tunnels are not supported", http.StatusMethodNotAllowed)
+		return
+	}
+	manifest, path, ok := p.translate(r)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s is not a swarm URL", r.URL), http.StatusForbidden)
+		return
+	}
+	if manifest == "" {
+		http.Error(w, "missing manifest", http.StatusNotFound)
+		return
+	}
+	// relative URLs of pages need a trailing slash after the manifest
+	if path == "" {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	log.Debug("proxy request", "url", r.URL, "manifest", manifest, "path", path)
+
+	// the manifest is kept in the paths of the redirects of http://bzz URLs
+	inPath := r.URL.Scheme != "bzz" && requestHost(r) == ProxyHost
+	out := r.WithContext(context.WithValue(r.Context(), proxyPathKey{}, inPath))
+	out.URL = &url.URL{
+		Scheme:   p.gateway.Scheme,
+		Host:     p.gateway.Host,
+		Path:     p.gateway.Path + "/bzz:/" + manifest + path,
+		RawQuery: r.URL.RawQuery,
+	}
+	out.Host = p.gateway.Host
+	out.RequestURI = ""
+	// the gateway is not a proxy
+	out.Header = cloneHeader(r.Header)
+	out.Header.Del("Proxy-Authorization")
+	out.Header.Del("Proxy-Connection")
+	p.proxy.ServeHTTP(w, out)
+}
+
+// translate returns the manifest and path the URL of the request refers to,
+// false if it is not a swarm URL
+func (p *Proxy) translate(r *http.Request) (manifest, path string, ok bool) {
+	host := requestHost(r)
+
+	switch {
+	case r.URL.Scheme == "bzz":
+		return host, r.URL.Path, true
+	case host == ProxyHost:
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if i := strings.Index(path, "/"); i >= 0 {
+			return path[:i], path[i:], true
+		}
+		return path, "", true
+	case p.isSwarmHost(host):
+		return host, r.URL.Path, true
+	}
+	return "", "", false
+}
+
+func (p *Proxy) isSwarmHost(host string) bool {
+	for _, tld := range p.tlds {
+		if strings.HasSuffix(host, "."+tld) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyPathKey is the context key of the proxied requests telling whether
+// the manifest is in the path of the URL
+type proxyPathKey struct{}
+
+// requestHost returns the lower case host of the URL of the request without
+// the port
+func requestHost(r *http.Request) string {
+	host := r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// modifyResponse makes the redirects of the gateway within the manifest
+// relative to the URL of the proxied request
+func (p *Proxy) modifyResponse(res *http.Response) error {
+	location := res.Header.Get("Location")
+	prefix := p.gateway.Path + "/bzz:/"
+	if !strings.HasPrefix(location, prefix) {
+		return nil
+	}
+	// the location is /bzz:/<manifest>/<path>
+	rest := strings.TrimPrefix(location, prefix)
+	if inPath, _ := res.Request.Context().Value(proxyPathKey{}).(bool); inPath {
+		res.Header.Set("Location", "/"+rest)
+		return nil
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		res.Header.Set("Location", rest[i:])
+	}
+	return nil
+}
+
+// servePAC serves the proxy auto-config file which sends the requests for
+// swarm URLs to the proxy and the others directly
+func (p *Proxy) servePAC(w http.ResponseWriter, r *http.Request) {
+	var conditions []string
+	conditions = append(conditions, fmt.Sprintf("host == %q", ProxyHost))
+	for _, tld := range p.tlds {
+		conditions = append(conditions, fmt.Sprintf("dnsDomainIs(host, %q)", "."+tld))
+	}
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	fmt.Fprintf(w, `function FindProxyForURL(url, host) {
+	if (%s) {
+		return "PROXY %s";
+	}
+	return "DIRECT";
+}
+`, strings.Join(conditions, " || "), r.Host)
+}
+
+func cloneHeader(h http.Header) http.Header {
+	res := make(http.Header, len(h))
+	for k, v := range h {
+		res[k] = append([]string(nil), v...)
+	}
+	return res
+}
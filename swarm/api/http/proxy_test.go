@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestProxy tests that the proxy translates the swarm URLs to requests to
+// the gateway and refuses other URLs
+func TestProxy(t *testing.T) {
+	// the gateway responds with the requested path and redirects requests of
+	// directories
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/dir") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer gateway.Close()
+
+	proxy, err := NewProxy(gateway.URL, []string{"eth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+	proxyURL, _ := url.Parse(srv.URL)
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, x := range []struct {
+		url      string
+		status   int
+		body     string
+		location string
+	}{
+		{url: "http://theswarm.eth/index.html?a=1", status: http.StatusOK, body: "/bzz:/theswarm.eth/index.html?a=1"},
+		{url: "http://bzz/1234/img/logo.png", status: http.StatusOK, body: "/bzz:/1234/img/logo.png?"},
+		{url: "http://bzz/1234", status: http.StatusMovedPermanently, location: "/1234/"},
+		{url: "http://theswarm.eth/dir", status: http.StatusMovedPermanently, location: "/dir/"},
+		{url: "http://bzz/1234/dir", status: http.StatusMovedPermanently, location: "/1234/dir/"},
+		{url: "http://example.com/", status: http.StatusForbidden},
+	} {
+		res, err := client.Get(x.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != x.status {
+			t.Fatalf("%s: expected status %d, got %s", x.url, x.status, res.Status)
+		}
+		if x.body != "" && string(body) != x.body {
+			t.Fatalf("%s: expected gateway request %q, got %q", x.url, x.body, body)
+		}
+		if location := res.Header.Get("Location"); location != x.location {
+			t.Fatalf("%s: expected location %q, got %q", x.url, x.location, location)
+		}
+	}
+
+	// bzz URLs are sent by clients handling the scheme
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET bzz://theswarm.eth/a/b.txt HTTP/1.1\r\nHost: theswarm.eth\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/bzz:/theswarm.eth/a/b.txt?" {
+		t.Fatalf("expected gateway request %q, got %q", "/bzz:/theswarm.eth/a/b.txt?", body)
+	}
+
+	// the proxy auto-config file only proxies the swarm hosts
+	res, err = http.Get(srv.URL + ProxyPACPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pac, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`dnsDomainIs(host, ".eth")`, `host == "bzz"`, "PROXY " + proxyURL.Host, "DIRECT"} {
+		if !strings.Contains(string(pac), expected) {
+			t.Fatalf("expected proxy auto-config file to contain %q, got:\n%s", expected, pac)
+		}
+	}
+}
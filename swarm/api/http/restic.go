@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/pborman/uuid"
+)
+
+// resticV2MediaType is the media type of version 2 of the restic REST
+// backend protocol, which lists blobs with their sizes
+const resticV2MediaType = "application/vnd.x.restic.rest.v2"
+
+// resticTypes are the directories of a restic repository
+var resticTypes = map[string]bool{
+	"data":      true,
+	"keys":      true,
+	"locks":     true,
+	"snapshots": true,
+	"index":     true,
+}
+
+// ResticServer serves blob repositories with the REST backend protocol of
+// restic, so that swarm can be used as a deduplicating backup target:
+//
+//	restic -r rest:http://localhost:<port>/<repository>/ init
+//
+// The first path segment is the name of the repository, the repository
+// file at <type>/<name> is stored as the blob with the same name in the
+// repository, the config file as the blob "config".
+type ResticServer struct {
+	blobs *api.BlobStore
+}
+
+// NewResticServer creates a ResticServer serving the repositories of blobs
+func NewResticServer(blobs *api.BlobStore) *ResticServer {
+	return &ResticServer{blobs: blobs}
+}
+
+// StartResticServer starts the restic REST backend listening on addr
+func StartResticServer(a *api.API, store state.Store, addr string) {
+	go http.ListenAndServe(addr, NewResticServer(api.NewBlobStore(a, store)))
+}
+
+func (s *ResticServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.TODO()
+	ruid := uuid.New()[:8]
+	log.Debug("serving restic request", "ruid", ruid, "method", r.Method, "url", r.RequestURI)
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	repo := parts[0]
+	if repo == "" {
+		http.Error(w, "missing repository", http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 1 || parts[1] == "":
+		if r.Method == "POST" && r.URL.Query().Get("create") == "true" {
+			s.handleCreate(ctx, w, repo)
+			return
+		}
+		http.Error(w, fmt.Sprintf("%s method not allowed on repository", r.Method), http.StatusMethodNotAllowed)
+
+	case len(parts) == 2 && parts[1] == "config":
+		s.handleBlob(ctx, w, r, repo, "config")
+
+	case resticTypes[parts[1]] && (len(parts) == 2 || parts[2] == ""):
+		if r.Method != "GET" {
+			http.Error(w, fmt.Sprintf("%s method not allowed on %s", r.Method, parts[1]), http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleList(ctx, w, r, repo, parts[1])
+
+	case resticTypes[parts[1]] && !strings.Contains(parts[2], "/"):
+		s.handleBlob(ctx, w, r, repo, parts[1]+"/"+parts[2])
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *ResticServer) handleCreate(ctx context.Context, w http.ResponseWriter, repo string) {
+	err := s.blobs.CreateRepo(ctx, repo)
+	if err != nil && err != api.ErrRepoExists {
+		resticError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type resticBlob struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (s *ResticServer) handleList(ctx context.Context, w http.ResponseWriter, r *http.Request, repo, typ string) {
+	infos, err := s.blobs.List(ctx, repo, typ+"/")
+	if err != nil {
+		resticError(w, err)
+		return
+	}
+	if r.Header.Get("Accept") == resticV2MediaType {
+		blobs := make([]resticBlob, len(infos))
+		for i, info := range infos {
+			blobs[i] = resticBlob{Name: strings.TrimPrefix(info.Name, typ+"/"), Size: info.Size}
+		}
+		w.Header().Set("Content-Type", resticV2MediaType)
+		json.NewEncoder(w).Encode(blobs)
+		return
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = strings.TrimPrefix(info.Name, typ+"/")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(names)
+}
+
+func (s *ResticServer) handleBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, repo, name string) {
+	switch r.Method {
+	case "GET", "HEAD":
+		info, reader, err := s.blobs.Get(ctx, repo, name)
+		if err != nil {
+			resticError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", time.Time{}, newBufferedReadSeeker(reader, getFileBufferSize))
+		log.Debug("restic blob served", "repo", repo, "name", name, "size", info.Size)
+
+	case "POST":
+		if r.ContentLength < 0 {
+			http.Error(w, "missing Content-Length", http.StatusLengthRequired)
+			return
+		}
+		if _, err := s.blobs.Put(ctx, repo, name, r.Body, r.ContentLength); err != nil {
+			resticError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case "DELETE":
+		if err := s.blobs.Delete(ctx, repo, name); err != nil {
+			resticError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, fmt.Sprintf("%s method not allowed on blob", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// resticError responds with the status restic expects for the error of the
+// blob store
+func resticError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case api.ErrRepoNotFound, api.ErrBlobNotFound:
+		status = http.StatusNotFound
+	case api.ErrBlobExists:
+		status = http.StatusForbidden
+	}
+	log.Debug("restic error", "err", err, "status", status)
+	http.Error(w, err.Error(), status)
+}
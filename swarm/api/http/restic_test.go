@@ -0,0 +1,152 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestResticServer tests the requests restic makes to a REST backend
+func TestResticServer(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		return NewResticServer(api.NewBlobStore(a, state.NewInmemoryStore()))
+	})
+	defer srv.Close()
+
+	// restic checks for the config to tell whether the repository exists
+	res := s3Request(t, "HEAD", srv.URL+"/repo/config", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+
+	res = s3Request(t, "POST", srv.URL+"/repo/?create=true", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("create repository: unexpected status %s", res.Status)
+	}
+
+	files := map[string]string{
+		"config":         "config",
+		"keys/1a2b":      "key",
+		"data/3c4d":      "data blob",
+		"snapshots/5e6f": "snapshot",
+	}
+	for name, content := range files {
+		res = s3Request(t, "POST", srv.URL+"/repo/"+name, []byte(content))
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("save %s: unexpected status %s", name, res.Status)
+		}
+	}
+
+	// files are never overwritten
+	res = s3Request(t, "POST", srv.URL+"/repo/config", []byte("other"))
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, res.StatusCode)
+	}
+
+	for name, content := range files {
+		res = s3Request(t, "GET", srv.URL+"/repo/"+name, nil)
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("load %s: unexpected status %s", name, res.Status)
+		}
+		if string(data) != content {
+			t.Fatalf("load %s: expected %q, got %q", name, content, data)
+		}
+	}
+
+	// restic loads parts of pack files with range requests
+	req, err := http.NewRequest("GET", srv.URL+"/repo/data/3c4d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes=5-8")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusPartialContent || string(data) != "blob" {
+		t.Fatalf("expected partial content %q, got %s %q", "blob", res.Status, data)
+	}
+
+	// version 2 listings include the sizes of the files
+	req, err = http.NewRequest("GET", srv.URL+"/repo/data/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", resticV2MediaType)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blobs []resticBlob
+	if err := json.NewDecoder(res.Body).Decode(&blobs); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(blobs) != 1 || blobs[0].Name != "3c4d" || blobs[0].Size != int64(len(files["data/3c4d"])) {
+		t.Fatalf("unexpected listing %+v", blobs)
+	}
+
+	res = s3Request(t, "GET", srv.URL+"/repo/locks/", nil)
+	var names []string
+	if err := json.NewDecoder(res.Body).Decode(&names); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(names) != 0 {
+		t.Fatalf("expected no locks, got %v", names)
+	}
+
+	res = s3Request(t, "DELETE", srv.URL+"/repo/keys/1a2b", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("delete: unexpected status %s", res.Status)
+	}
+	res = s3Request(t, "GET", srv.URL+"/repo/keys/1a2b", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+
+	// unknown file types are refused
+	res = s3Request(t, "POST", srv.URL+"/repo/other/1a2b", bytes.Repeat([]byte("x"), 10))
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+}
@@ -0,0 +1,772 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/pborman/uuid"
+)
+
+var (
+	s3PutObjectCount    = metrics.NewRegisteredCounter("api.s3.putobject.count", nil)
+	s3PutObjectFail     = metrics.NewRegisteredCounter("api.s3.putobject.fail", nil)
+	s3GetObjectCount    = metrics.NewRegisteredCounter("api.s3.getobject.count", nil)
+	s3GetObjectFail     = metrics.NewRegisteredCounter("api.s3.getobject.fail", nil)
+	s3DeleteObjectCount = metrics.NewRegisteredCounter("api.s3.deleteobject.count", nil)
+	s3DeleteObjectFail  = metrics.NewRegisteredCounter("api.s3.deleteobject.fail", nil)
+	s3ListObjectsCount  = metrics.NewRegisteredCounter("api.s3.listobjects.count", nil)
+	s3ListObjectsFail   = metrics.NewRegisteredCounter("api.s3.listobjects.fail", nil)
+)
+
+const (
+	// s3BucketsKey is the state store key under which the bucket to
+	// manifest mapping is persisted
+	s3BucketsKey = "s3_buckets"
+
+	s3DefaultMaxKeys = 1000
+	s3XMLNamespace   = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
+
+// s3Bucket records the manifest which currently holds the content of a bucket
+type s3Bucket struct {
+	Manifest storage.Address `json:"manifest"`
+	Created  time.Time       `json:"created"`
+}
+
+// S3Server is a minimal Amazon S3 compatible facade on top of swarm
+// manifests, so that existing S3 tooling (rclone, backup software, the aws
+// cli) can store and retrieve content on a swarm node without modification.
+//
+// A bucket is a named, mutable pointer to a manifest which is kept in the
+// node's state store; every object write or delete produces a new manifest
+// and moves the pointer. Buckets which are not known to the server are
+// resolved as swarm addresses (content hashes or ENS names) and are served
+// read-only.
+//
+// Request signatures are not verified, the server is meant to listen on a
+// local interface only.
+type S3Server struct {
+	api   *api.API
+	store state.Store
+	mu    sync.Mutex // serialises bucket pointer updates
+}
+
+// NewS3Server creates an S3Server persisting bucket pointers in store
+func NewS3Server(api *api.API, store state.Store) *S3Server {
+	return &S3Server{
+		api:   api,
+		store: store,
+	}
+}
+
+// StartS3Server starts the S3 facade listening on addr, the returned server
+// is shut down with the node
+func StartS3Server(api *api.API, store state.Store, addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: NewS3Server(api, store),
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Error("s3 server stopped", "addr", addr, "err", err)
+		}
+	}()
+	return srv, nil
+}
+
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ruid := uuid.New()[:8]
+	log.Debug("serving s3 request", "ruid", ruid, "method", r.Method, "url", r.RequestURI)
+
+	w.Header().Set("x-amz-request-id", ruid)
+
+	bucket, key := splitS3Path(r.URL.Path)
+	switch {
+	case bucket == "":
+		if r.Method != "GET" {
+			s3Error(w, r, "MethodNotAllowed", "method not allowed on service", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleListBuckets(w, r)
+
+	case key == "":
+		switch r.Method {
+		case "GET":
+			s.handleListObjects(ctx, w, r, bucket)
+		case "HEAD":
+			if _, _, err := s.resolveBucket(ctx, bucket); err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "PUT":
+			s.handleCreateBucket(ctx, w, r, bucket)
+		case "DELETE":
+			s.handleDeleteBucket(ctx, w, r, bucket)
+		default:
+			s3Error(w, r, "MethodNotAllowed", fmt.Sprintf("%s method not allowed on bucket", r.Method), http.StatusMethodNotAllowed)
+		}
+
+	default:
+		switch r.Method {
+		case "GET", "HEAD":
+			s.handleGetObject(ctx, w, r, bucket, key)
+		case "PUT":
+			s.handlePutObject(ctx, w, r, bucket, key)
+		case "DELETE":
+			s.handleDeleteObject(ctx, w, r, bucket, key)
+		default:
+			s3Error(w, r, "MethodNotAllowed", fmt.Sprintf("%s method not allowed on object", r.Method), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// splitS3Path splits a path style S3 request path into bucket and key
+func splitS3Path(p string) (bucket, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	bucket = parts[0]
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	return bucket, key
+}
+
+func (s *S3Server) buckets() (map[string]*s3Bucket, error) {
+	buckets := make(map[string]*s3Bucket)
+	err := s.store.Get(s3BucketsKey, &buckets)
+	if err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// resolveBucket returns the manifest address of the bucket and whether it
+// can be written to
+func (s *S3Server) resolveBucket(ctx context.Context, bucket string) (storage.Address, bool, error) {
+	buckets, err := s.buckets()
+	if err != nil {
+		return nil, false, err
+	}
+	if b, ok := buckets[bucket]; ok {
+		return b.Manifest, true, nil
+	}
+	uri, err := api.Parse("bzz:/" + bucket)
+	if err != nil {
+		return nil, false, err
+	}
+	addr, err := s.api.Resolve(ctx, uri)
+	if err != nil {
+		return nil, false, err
+	}
+	return addr, false, nil
+}
+
+// updateBucket applies update to the manifest of a writable bucket and moves
+// the bucket pointer to the resulting manifest. The bucket pointers are
+// locked during the update, so the content of objects has to be stored
+// beforehand.
+func (s *S3Server) updateBucket(ctx context.Context, bucket string, update func(mw *api.ManifestWriter) error) (storage.Address, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.buckets()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := buckets[bucket]
+	if !ok {
+		return nil, errNoSuchBucket
+	}
+	mw, err := s.api.NewManifestWriter(ctx, b.Manifest, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(mw); err != nil {
+		return nil, err
+	}
+	addr, err := mw.Store()
+	if err != nil {
+		return nil, err
+	}
+	b.Manifest = addr
+	if err := s.store.Put(s3BucketsKey, buckets); err != nil {
+		return nil, err
+	}
+	log.Debug("s3 bucket updated", "bucket", bucket, "manifest", addr)
+	return addr, nil
+}
+
+var errNoSuchBucket = fmt.Errorf("no such writable bucket")
+
+// validAddress reports whether the hash of a manifest entry is a hex encoded
+// swarm address
+func validAddress(hash string) bool {
+	addr, err := hex.DecodeString(hash)
+	return err == nil && len(addr) > 0
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   struct {
+		ID          string
+		DisplayName string
+	}
+	Buckets []s3BucketInfo `xml:"Buckets>Bucket"`
+}
+
+type s3BucketInfo struct {
+	Name         string
+	CreationDate string
+}
+
+func (s *S3Server) handleListBuckets(w http.ResponseWriter, r *http.Request) {
+	buckets, err := s.buckets()
+	if err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res := &s3ListAllMyBucketsResult{Xmlns: s3XMLNamespace}
+	res.Owner.ID = "swarm"
+	res.Owner.DisplayName = "swarm"
+	for name, b := range buckets {
+		res.Buckets = append(res.Buckets, s3BucketInfo{
+			Name:         name,
+			CreationDate: b.Created.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(res.Buckets, func(i, j int) bool { return res.Buckets[i].Name < res.Buckets[j].Name })
+	s3Respond(w, res)
+}
+
+func (s *S3Server) handleCreateBucket(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.buckets()
+	if err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := buckets[bucket]; ok {
+		s3Error(w, r, "BucketAlreadyOwnedByYou", fmt.Sprintf("bucket %s already exists", bucket), http.StatusConflict)
+		return
+	}
+	addr, err := s.api.NewManifest(ctx, false)
+	if err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buckets[bucket] = &s3Bucket{
+		Manifest: addr,
+		Created:  time.Now(),
+	}
+	if err := s.store.Put(s3BucketsKey, buckets); err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debug("s3 bucket created", "bucket", bucket, "manifest", addr)
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *S3Server) handleDeleteBucket(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.buckets()
+	if err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, ok := buckets[bucket]
+	if !ok {
+		s3Error(w, r, "NoSuchBucket", fmt.Sprintf("bucket %s does not exist", bucket), http.StatusNotFound)
+		return
+	}
+	keys, _, _, err := s.listKeys(ctx, b.Manifest, "")
+	if err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(keys) > 0 {
+		s3Error(w, r, "BucketNotEmpty", fmt.Sprintf("bucket %s is not empty", bucket), http.StatusConflict)
+		return
+	}
+	delete(buckets, bucket)
+	if err := s.store.Put(s3BucketsKey, buckets); err != nil {
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listKeys returns the sorted paths of all non-manifest entries with the
+// given prefix and the hash function of the manifest
+func (s *S3Server) listKeys(ctx context.Context, addr storage.Address, prefix string) ([]string, map[string]*api.ManifestEntry, string, error) {
+	walker, err := s.api.NewManifestWalker(ctx, addr, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	entries := make(map[string]*api.ManifestEntry)
+	var keys []string
+	err = walker.Walk(func(entry *api.ManifestEntry) error {
+		if entry.ContentType == api.ManifestType {
+			// only recurse into manifests which can contain the prefix
+			if strings.HasPrefix(prefix, entry.Path) || strings.HasPrefix(entry.Path, prefix) {
+				return nil
+			}
+			return api.ErrSkipManifest
+		}
+		if entry.Path == "" || !strings.HasPrefix(entry.Path, prefix) {
+			return nil
+		}
+		e := *entry
+		entries[e.Path] = &e
+		keys = append(keys, e.Path)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	sort.Strings(keys)
+	return keys, entries, walker.HashFunc(), nil
+}
+
+// s3ETag returns the ETag of the object of the entry, the address of its
+// content, which is calculated for inline and composite content as it is
+// not referenced by the hash of the entry
+func s3ETag(ctx context.Context, entry *api.ManifestEntry, hash string) (string, error) {
+	switch {
+	case entry.Data != nil:
+		addr, err := api.InlineAddress(ctx, entry.Data, hash)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%q", addr.Hex()), nil
+	case len(entry.Parts) > 0:
+		return fmt.Sprintf("%q", api.CompositeAddress(entry.Parts).Hex()), nil
+	}
+	return fmt.Sprintf("%q", entry.Hash), nil
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name   `xml:"ListBucketResult"`
+	Xmlns                 string     `xml:"xmlns,attr"`
+	Name                  string     `xml:"Name"`
+	Prefix                string     `xml:"Prefix"`
+	Marker                string     `xml:"Marker,omitempty"`
+	NextMarker            string     `xml:"NextMarker,omitempty"`
+	StartAfter            string     `xml:"StartAfter,omitempty"`
+	ContinuationToken     string     `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string     `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int        `xml:"KeyCount,omitempty"`
+	MaxKeys               int        `xml:"MaxKeys"`
+	Delimiter             string     `xml:"Delimiter,omitempty"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	Contents              []s3Object `xml:"Contents"`
+	CommonPrefixes        []s3Prefix `xml:"CommonPrefixes"`
+}
+
+type s3Object struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type s3Prefix struct {
+	Prefix string
+}
+
+// handleListObjects handles both the ListObjects and ListObjectsV2 requests
+func (s *S3Server) handleListObjects(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket string) {
+	s3ListObjectsCount.Inc(1)
+	addr, _, err := s.resolveBucket(ctx, bucket)
+	if err != nil {
+		s3ListObjectsFail.Inc(1)
+		s3Error(w, r, "NoSuchBucket", err.Error(), http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	v2 := q.Get("list-type") == "2"
+	res := &s3ListBucketResult{
+		Xmlns:     s3XMLNamespace,
+		Name:      bucket,
+		Prefix:    q.Get("prefix"),
+		Delimiter: q.Get("delimiter"),
+		MaxKeys:   s3DefaultMaxKeys,
+	}
+	if mk := q.Get("max-keys"); mk != "" {
+		n, err := strconv.Atoi(mk)
+		if err != nil || n < 0 {
+			s3ListObjectsFail.Inc(1)
+			s3Error(w, r, "InvalidArgument", "invalid max-keys", http.StatusBadRequest)
+			return
+		}
+		if n < res.MaxKeys {
+			res.MaxKeys = n
+		}
+	}
+	var after string
+	if v2 {
+		res.StartAfter = q.Get("start-after")
+		res.ContinuationToken = q.Get("continuation-token")
+		after = res.StartAfter
+		if res.ContinuationToken != "" {
+			after = res.ContinuationToken
+		}
+	} else {
+		res.Marker = q.Get("marker")
+		after = res.Marker
+	}
+
+	keys, entries, hash, err := s.listKeys(ctx, addr, res.Prefix)
+	if err != nil {
+		s3ListObjectsFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var last string
+	seenPrefixes := make(map[string]bool)
+	count := 0
+	for _, key := range keys {
+		if key <= after {
+			continue
+		}
+		if res.Delimiter != "" {
+			suffix := strings.TrimPrefix(key, res.Prefix)
+			if i := strings.Index(suffix, res.Delimiter); i > -1 {
+				cp := res.Prefix + suffix[:i+len(res.Delimiter)]
+				if seenPrefixes[cp] || cp <= after {
+					continue
+				}
+				if count == res.MaxKeys {
+					res.IsTruncated = true
+					break
+				}
+				seenPrefixes[cp] = true
+				res.CommonPrefixes = append(res.CommonPrefixes, s3Prefix{Prefix: cp})
+				last = cp
+				count++
+				continue
+			}
+		}
+		if count == res.MaxKeys {
+			res.IsTruncated = true
+			break
+		}
+		entry := entries[key]
+		etag, err := s3ETag(ctx, entry, hash)
+		if err != nil {
+			s3ListObjectsFail.Inc(1)
+			s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Contents = append(res.Contents, s3Object{
+			Key:          key,
+			LastModified: entry.ModTime.UTC().Format(time.RFC3339),
+			ETag:         etag,
+			Size:         entry.Size,
+			StorageClass: "STANDARD",
+		})
+		last = key
+		count++
+	}
+	if res.IsTruncated {
+		if v2 {
+			res.NextContinuationToken = last
+		} else {
+			res.NextMarker = last
+		}
+	}
+	if v2 {
+		res.KeyCount = count
+	}
+	s3Respond(w, res)
+}
+
+func (s *S3Server) handleGetObject(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s3GetObjectCount.Inc(1)
+	addr, _, err := s.resolveBucket(ctx, bucket)
+	if err != nil {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "NoSuchBucket", err.Error(), http.StatusNotFound)
+		return
+	}
+	// manifest lookups match path prefixes, S3 keys have to match exactly
+	_, entries, hash, err := s.listKeys(ctx, addr, key)
+	if err != nil {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry, ok := entries[key]
+	if !ok {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "NoSuchKey", fmt.Sprintf("key %s does not exist", key), http.StatusNotFound)
+		return
+	}
+	if entry.Data == nil && len(entry.Parts) == 0 && !validAddress(entry.Hash) {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", fmt.Sprintf("invalid content hash %q of key %s", entry.Hash, key), http.StatusInternalServerError)
+		return
+	}
+	etag, err := s3ETag(ctx, entry, hash)
+	if err != nil {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	contentType := entry.ContentType
+	// the retrieval of the content fails when its size is read
+	reader, _ := s.api.RetrieveEntry(ctx, entry)
+	if _, err := reader.Size(nil); err != nil {
+		s3GetObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", fmt.Sprintf("cannot retrieve the content of key %s: %v", key, err), http.StatusInternalServerError)
+		return
+	}
+	if contentType == "" {
+		contentType = s.api.MimeDetector().DetectReaderAt(key, reader)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, newBufferedReadSeeker(reader, getFileBufferSize))
+}
+
+func (s *S3Server) handlePutObject(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s3PutObjectCount.Inc(1)
+	if r.Header.Get("x-amz-copy-source") != "" {
+		s3PutObjectFail.Inc(1)
+		s3Error(w, r, "NotImplemented", "object copy is not supported", http.StatusNotImplemented)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	size := r.ContentLength
+	if isAWSChunked(r) {
+		decoded, err := strconv.ParseInt(r.Header.Get("x-amz-decoded-content-length"), 10, 64)
+		if err != nil {
+			s3PutObjectFail.Inc(1)
+			s3Error(w, r, "MissingContentLength", "missing or invalid x-amz-decoded-content-length", http.StatusLengthRequired)
+			return
+		}
+		body = newAWSChunkedReader(r.Body)
+		size = decoded
+	}
+	if size < 0 {
+		// copy the body to a tmp file to get its size
+		tmp, err := ioutil.TempFile("", "swarm-s3")
+		if err != nil {
+			s3PutObjectFail.Inc(1)
+			s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, body); err != nil {
+			s3PutObjectFail.Inc(1)
+			s3Error(w, r, "IncompleteBody", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			s3PutObjectFail.Inc(1)
+			s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = tmp
+	}
+
+	if _, writable, err := s.resolveBucket(ctx, bucket); err != nil || !writable {
+		s3PutObjectFail.Inc(1)
+		s3Error(w, r, "NoSuchBucket", fmt.Sprintf("bucket %s does not exist or is read-only", bucket), http.StatusNotFound)
+		return
+	}
+	// the content is stored before the bucket is locked, so that uploads
+	// to the same bucket do not wait for each other
+	contentAddr, wait, err := s.api.Store(ctx, body, size, false)
+	if err == nil {
+		err = wait(ctx)
+	}
+	if err != nil {
+		s3PutObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = s.updateBucket(ctx, bucket, func(mw *api.ManifestWriter) error {
+		// replace any existing entry at the same key
+		if err := mw.RemoveEntry(key); err != nil {
+			return err
+		}
+		return mw.AddEntryHash(&api.ManifestEntry{
+			Hash:        contentAddr.Hex(),
+			Path:        key,
+			ContentType: r.Header.Get("Content-Type"),
+			Mode:        0644,
+			Size:        size,
+			ModTime:     time.Now(),
+		})
+	})
+	if err == errNoSuchBucket {
+		s3PutObjectFail.Inc(1)
+		s3Error(w, r, "NoSuchBucket", fmt.Sprintf("bucket %s does not exist or is read-only", bucket), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s3PutObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("%q", contentAddr.Hex()))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *S3Server) handleDeleteObject(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s3DeleteObjectCount.Inc(1)
+	_, err := s.updateBucket(ctx, bucket, func(mw *api.ManifestWriter) error {
+		return mw.RemoveEntry(key)
+	})
+	if err == errNoSuchBucket {
+		s3DeleteObjectFail.Inc(1)
+		s3Error(w, r, "NoSuchBucket", fmt.Sprintf("bucket %s does not exist or is read-only", bucket), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s3DeleteObjectFail.Inc(1)
+		s3Error(w, r, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isAWSChunked reports whether the request body uses the aws-chunked
+// encoding of signature v4 streaming uploads
+func isAWSChunked(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("x-amz-content-sha256"), "STREAMING-") ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked")
+}
+
+// awsChunkedReader strips the chunk framing of an aws-chunked body:
+//
+//	<hex size>;chunk-signature=<sig>\r\n<data>\r\n ... 0;chunk-signature=<sig>\r\n\r\n
+//
+// The chunk signatures are not verified.
+type awsChunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func newAWSChunkedReader(r io.Reader) *awsChunkedReader {
+	return &awsChunkedReader{r: bufio.NewReader(r)}
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, ";"); i > -1 {
+			line = line[:i]
+		}
+		size, err := strconv.ParseInt(line, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid aws-chunked chunk size %q", line)
+		}
+		if size == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		c.remaining = size
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		// consume the CRLF which terminates the chunk data
+		if _, err := c.r.Discard(2); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+type s3ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string
+	RequestID string `xml:"RequestId"`
+}
+
+func s3Error(w http.ResponseWriter, r *http.Request, code, msg string, status int) {
+	log.Debug("s3 error", "code", code, "msg", msg, "status", status)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == "HEAD" {
+		return
+	}
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(&s3ErrorResponse{
+		Code:      code,
+		Message:   msg,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
+
+func s3Respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+func s3Request(t *testing.T, method, url string, body []byte) *http.Response {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestS3Facade(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		return NewS3Server(a, state.NewInmemoryStore())
+	})
+	defer srv.Close()
+
+	// objects can not be stored before the bucket is created
+	res := s3Request(t, "PUT", srv.URL+"/backup/a.txt", []byte("a"))
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+
+	res = s3Request(t, "PUT", srv.URL+"/backup", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("create bucket: unexpected status %s", res.Status)
+	}
+
+	objects := map[string]string{
+		"a.txt":       "aaa",
+		"dir/b.txt":   "bbbb",
+		"dir/c/d.txt": "ddddd",
+	}
+	for key, content := range objects {
+		res = s3Request(t, "PUT", srv.URL+"/backup/"+key, []byte(content))
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("put %s: unexpected status %s", key, res.Status)
+		}
+		if res.Header.Get("ETag") == "" {
+			t.Fatalf("put %s: missing ETag", key)
+		}
+	}
+
+	for key, content := range objects {
+		res = s3Request(t, "GET", srv.URL+"/backup/"+key, nil)
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("get %s: unexpected status %s", key, res.Status)
+		}
+		if string(data) != content {
+			t.Fatalf("get %s: expected %q, got %q", key, content, data)
+		}
+	}
+
+	// keys have to match exactly
+	res = s3Request(t, "GET", srv.URL+"/backup/dir/b", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+
+	// list with a delimiter groups keys into common prefixes
+	res = s3Request(t, "GET", srv.URL+"/backup?list-type=2&delimiter=/", nil)
+	var list s3ListBucketResult
+	if err := xml.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(list.Contents) != 1 || list.Contents[0].Key != "a.txt" {
+		t.Fatalf("unexpected list contents %+v", list.Contents)
+	}
+	if len(list.CommonPrefixes) != 1 || list.CommonPrefixes[0].Prefix != "dir/" {
+		t.Fatalf("unexpected list common prefixes %+v", list.CommonPrefixes)
+	}
+
+	// paginated list
+	res = s3Request(t, "GET", srv.URL+"/backup?list-type=2&max-keys=2", nil)
+	list = s3ListBucketResult{}
+	if err := xml.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if !list.IsTruncated || len(list.Contents) != 2 || list.NextContinuationToken != "dir/b.txt" {
+		t.Fatalf("unexpected first page %+v", list)
+	}
+	res = s3Request(t, "GET", srv.URL+"/backup?list-type=2&max-keys=2&continuation-token="+list.NextContinuationToken, nil)
+	list = s3ListBucketResult{}
+	if err := xml.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if list.IsTruncated || len(list.Contents) != 1 || list.Contents[0].Key != "dir/c/d.txt" {
+		t.Fatalf("unexpected second page %+v", list)
+	}
+
+	// a non empty bucket can not be deleted
+	res = s3Request(t, "DELETE", srv.URL+"/backup", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, res.StatusCode)
+	}
+
+	for key := range objects {
+		res = s3Request(t, "DELETE", srv.URL+"/backup/"+key, nil)
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("delete %s: unexpected status %s", key, res.Status)
+		}
+	}
+	res = s3Request(t, "GET", srv.URL+"/backup/a.txt", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+
+	res = s3Request(t, "DELETE", srv.URL+"/backup", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete bucket: unexpected status %s", res.Status)
+	}
+}
+
+// TestS3InlineETag tests that the objects of inline entries have the address
+// of their content as ETag
+func TestS3InlineETag(t *testing.T) {
+	var a *api.API
+	srv := testutil.NewTestSwarmServer(t, func(swarmAPI *api.API) testutil.TestServer {
+		a = swarmAPI
+		return NewS3Server(a, state.NewInmemoryStore())
+	})
+	defer srv.Close()
+
+	ctx := context.TODO()
+	a.SetInlineThreshold(1024)
+	data := []byte("inline")
+	addr, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw, err := a.NewManifestWriter(ctx, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mw.AddEntryInline(ctx, bytes.NewReader(data), &api.ManifestEntry{Path: "a.txt", Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	addr, err = mw.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentAddr, err := api.InlineAddress(ctx, data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := fmt.Sprintf("%q", contentAddr.Hex())
+
+	res := s3Request(t, "GET", srv.URL+"/"+addr.Hex()+"/a.txt", nil)
+	got, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || !bytes.Equal(got, data) {
+		t.Fatalf("expected inline content, got %s: %q", res.Status, got)
+	}
+	if res.Header.Get("ETag") != etag {
+		t.Fatalf("expected ETag %s, got %s", etag, res.Header.Get("ETag"))
+	}
+
+	res = s3Request(t, "GET", srv.URL+"/"+addr.Hex()+"?list-type=2", nil)
+	var list s3ListBucketResult
+	if err := xml.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(list.Contents) != 1 || list.Contents[0].ETag != etag {
+		t.Fatalf("expected a.txt with ETag %s, got %+v", etag, list.Contents)
+	}
+}
+
+func TestS3AWSChunkedReader(t *testing.T) {
+	body := "5;chunk-signature=abc\r\nhello\r\n6;chunk-signature=def\r\n world\r\n0;chunk-signature=ghi\r\n\r\n"
+	data, err := ioutil.ReadAll(newAWSChunkedReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("expected %q, got %q", "hello world", data)
+	}
+}
+
+// TestS3ConcurrentPuts tests that the objects stored concurrently in a bucket
+// are all kept in its manifest
+func TestS3ConcurrentPuts(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		return NewS3Server(a, state.NewInmemoryStore())
+	})
+	defer srv.Close()
+
+	res := s3Request(t, "PUT", srv.URL+"/backup", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("create bucket: unexpected status %s", res.Status)
+	}
+
+	const n = 10
+	errC := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			key := fmt.Sprintf("file%d.txt", i)
+			req, err := http.NewRequest("PUT", srv.URL+"/backup/"+key, strings.NewReader(key))
+			if err != nil {
+				errC <- err
+				return
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				errC <- err
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				err = fmt.Errorf("put %s: unexpected status %s", key, res.Status)
+			}
+			errC <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errC; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("file%d.txt", i)
+		res := s3Request(t, "GET", srv.URL+"/backup/"+key, nil)
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK || string(data) != key {
+			t.Fatalf("get %s: unexpected response %s %q", key, res.Status, data)
+		}
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
@@ -41,9 +42,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	l "github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/api"
 	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/sctx"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 	"github.com/ethereum/go-ethereum/swarm/storage/mru"
 	"github.com/pborman/uuid"
@@ -63,6 +66,8 @@ var (
 	postFilesFail   = metrics.NewRegisteredCounter("api.http.post.files.fail", nil)
 	deleteCount     = metrics.NewRegisteredCounter("api.http.delete.count", nil)
 	deleteFail      = metrics.NewRegisteredCounter("api.http.delete.fail", nil)
+	moveCount       = metrics.NewRegisteredCounter("api.http.move.count", nil)
+	moveFail        = metrics.NewRegisteredCounter("api.http.move.fail", nil)
 	getCount        = metrics.NewRegisteredCounter("api.http.get.count", nil)
 	getFail         = metrics.NewRegisteredCounter("api.http.get.fail", nil)
 	getFileCount    = metrics.NewRegisteredCounter("api.http.get.file.count", nil)
@@ -72,6 +77,14 @@ var (
 	getFilesFail    = metrics.NewRegisteredCounter("api.http.get.files.fail", nil)
 	getListCount    = metrics.NewRegisteredCounter("api.http.get.list.count", nil)
 	getListFail     = metrics.NewRegisteredCounter("api.http.get.list.fail", nil)
+	getMissingCount = metrics.NewRegisteredCounter("api.http.get.missing.count", nil)
+	getMissingFail  = metrics.NewRegisteredCounter("api.http.get.missing.fail", nil)
+	prefetchCount   = metrics.NewRegisteredCounter("api.http.prefetch.count", nil)
+	prefetchFail    = metrics.NewRegisteredCounter("api.http.prefetch.fail", nil)
+	pinCount        = metrics.NewRegisteredCounter("api.http.pin.count", nil)
+	pinFail         = metrics.NewRegisteredCounter("api.http.pin.fail", nil)
+	getChunkCount   = metrics.NewRegisteredCounter("api.http.get.chunk.count", nil)
+	getChunkFail    = metrics.NewRegisteredCounter("api.http.get.chunk.fail", nil)
 )
 
 // ServerConfig is the basic configuration needed for the HTTP server and also
@@ -79,6 +92,46 @@ var (
 type ServerConfig struct {
 	Addr       string
 	CorsString string
+	Transforms map[string]Transform
+	// AccessLog is the file the access log is written to, the access log
+	// is disabled if empty
+	AccessLog string
+	// AnonymizeIPs removes the host part of client addresses from the
+	// access log
+	AnonymizeIPs bool
+	// TLSCert and TLSKey are the files of the certificate and its private
+	// key the server is served with over TLS and HTTP/2, plain HTTP is
+	// served if empty. The certificate is reloaded when the files change.
+	TLSCert string
+	TLSKey  string
+	// Socket is the path of a unix socket the server is also served on in
+	// plain HTTP, the server is only served on the socket if Addr is empty
+	Socket string
+	// ReadOnly, Allow and Deny are the gateway policy of the server, see
+	// GatewayPolicy. DenyListURL is the URL of a deny list signed by the
+	// key with the address DenyListSigner, none is loaded if empty.
+	ReadOnly       bool
+	Allow          []string
+	Deny           []string
+	DenyListURL    string
+	DenyListSigner common.Address
+	// Blocklist is the list of roots taken down by the operator, the
+	// takedown webhook is served with the bearer token TakedownToken if
+	// it is not empty
+	Blocklist     *api.Blocklist
+	TakedownToken string
+	// GatewayDomains are the domains whose subdomains serve the roots of
+	// their first label, see SetGatewayDomains. The subdomains are served
+	// over TLS with the wildcard certificate in TLSWildcardCert and
+	// TLSWildcardKey, with the certificate of the server if empty.
+	GatewayDomains  []string
+	TLSWildcardCert string
+	TLSWildcardKey  string
+	// SigningKeys are the keys the write requests can be signed with, see
+	// SetSigningKeys. Unsigned write requests are refused if
+	// RequireSignedWrites is set.
+	SigningKeys         *api.SigningKeys
+	RequireSignedWrites bool
 }
 
 // browser API for registering bzz url scheme handlers:
@@ -86,29 +139,112 @@ type ServerConfig struct {
 // electron (chromium) api for registering bzz url scheme handlers:
 // https://github.com/atom/electron/blob/master/docs/api/protocol.md
 
-// starts up http server
-func StartHTTPServer(api *api.API, config *ServerConfig) {
+// starts up http server, the returned server is shut down to stop accepting
+// requests and wait for the ones being served
+func StartHTTPServer(api *api.API, config *ServerConfig) (*http.Server, error) {
 	var allowedOrigins []string
 	for _, domain := range strings.Split(config.CorsString, ",") {
 		allowedOrigins = append(allowedOrigins, strings.TrimSpace(domain))
 	}
 	c := cors.New(cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{"POST", "GET", "DELETE", "PATCH", "PUT"},
+		AllowedMethods: []string{"POST", "GET", "DELETE", "MOVE", "PATCH", "PUT"},
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
-	hdlr := c.Handler(NewServer(api))
-
-	go http.ListenAndServe(config.Addr, hdlr)
+	server := NewServer(api)
+	for name, t := range config.Transforms {
+		server.RegisterTransform(name, t)
+	}
+	if config.AccessLog != "" {
+		h, err := l.FileHandler(config.AccessLog, l.JSONFormat())
+		if err != nil {
+			log.Error("cannot open access log", "path", config.AccessLog, "err", err)
+		} else {
+			server.EnableAccessLog(h, config.AnonymizeIPs)
+		}
+	}
+	if config.ReadOnly || len(config.Allow) > 0 || len(config.Deny) > 0 || config.DenyListURL != "" {
+		policy := NewGatewayPolicy(config.ReadOnly, config.Allow, config.Deny)
+		if config.DenyListURL != "" {
+			if err := policy.SetDenyListURL(config.DenyListURL, config.DenyListSigner); err != nil {
+				return nil, fmt.Errorf("cannot load deny list: %v", err)
+			}
+		}
+		server.SetPolicy(policy)
+	}
+	if config.Blocklist != nil {
+		server.SetBlocklist(config.Blocklist, config.TakedownToken)
+	}
+	if config.SigningKeys != nil {
+		server.SetSigningKeys(config.SigningKeys, config.RequireSignedWrites)
+	}
+	server.SetGatewayDomains(config.GatewayDomains)
+	srv := &http.Server{
+		Addr:    config.Addr,
+		Handler: c.Handler(server),
+	}
+	if config.TLSCert != "" || config.TLSKey != "" {
+		tlsConf, err := tlsConfig(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load TLS certificate: %v", err)
+		}
+		if config.TLSWildcardCert != "" || config.TLSWildcardKey != "" {
+			if err := wildcardTLSConfig(tlsConf, server.domains, config.TLSWildcardCert, config.TLSWildcardKey); err != nil {
+				return nil, fmt.Errorf("cannot load wildcard TLS certificate: %v", err)
+			}
+		}
+		checkWildcardCert(tlsConf, server.domains)
+		srv.TLSConfig = tlsConf
+	}
+	if config.Socket != "" {
+		ln, err := listenSocket(config.Socket)
+		if err != nil {
+			return nil, fmt.Errorf("cannot listen on unix socket: %v", err)
+		}
+		go func() {
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Error("http server stopped", "socket", config.Socket, "err", err)
+			}
+		}()
+		log.Info("HTTP API served on unix socket", "path", config.Socket)
+		if config.Addr == "" {
+			return srv, nil
+		}
+	}
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("http server stopped", "addr", config.Addr, "err", err)
+		}
+	}()
+	return srv, nil
 }
 
 func NewServer(api *api.API) *Server {
-	return &Server{api}
+	return &Server{
+		api:        api,
+		transforms: newTransforms(),
+	}
 }
 
 type Server struct {
-	api *api.API
+	api        *api.API
+	transforms *transforms
+	accessLog  *accessLog
+	policy     *GatewayPolicy
+
+	blocklist     *api.Blocklist
+	takedownToken string
+
+	domains []string // gateway domains serving roots at their subdomains
+
+	verifier *requestVerifier // verifies the signatures of write requests
 }
 
 // Request wraps http.Request and also includes the parsed bzz URI
@@ -120,7 +256,8 @@ type Request struct {
 }
 
 // HandlePostRaw handles a POST request to a raw bzz-raw:/ URI, stores the request
-// body in swarm and returns the resulting storage address as a text/plain response
+// body in swarm and returns the resulting storage address as a text/plain response,
+// or the receipt of the upload if the request accepts application/json
 func (s *Server) HandlePostRaw(ctx context.Context, w http.ResponseWriter, r *Request) {
 	log.Debug("handle.post.raw", "ruid", r.ruid)
 
@@ -148,25 +285,41 @@ func (s *Server) HandlePostRaw(ctx context.Context, w http.ResponseWriter, r *Re
 		Respond(w, r, "missing Content-Length header in request", http.StatusBadRequest)
 		return
 	}
-	addr, _, err := s.api.Store(ctx, r.Body, r.ContentLength, toEncrypt)
+	hash, err := uploadHash(r)
 	if err != nil {
 		postRawFail.Inc(1)
-		Respond(w, r, err.Error(), http.StatusInternalServerError)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
+	sparse, err := uploadSparse(r)
+	if err != nil {
+		postRawFail.Inc(1)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx = storage.WithSparse(ctx, sparse)
+	ctx, tag := s.newTag(ctx, w, "upload", nil)
+	addr, _, err := s.api.StoreWithHash(ctx, r.Body, r.ContentLength, toEncrypt, hash)
+	if err != nil {
+		tag.Done(err)
+		postRawFail.Inc(1)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
+		return
+	}
+	tag.SetAddress(addr)
+	tag.Done(nil)
 
 	log.Debug("stored content", "ruid", r.ruid, "key", addr)
 
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, addr)
+	respondUpload(w, r, api.NewUploadReceipt(addr, tag, r.ContentLength, false))
 }
 
 // HandlePostFiles handles a POST request to
 // bzz:/<hash>/<path> which contains either a single file or multiple files
 // (either a tar archive or multipart form), adds those files either to an
 // existing manifest or to a new manifest under <path> and returns the
-// resulting manifest hash as a text/plain response
+// resulting manifest hash as a text/plain response, or the receipt of the
+// upload if the request accepts application/json
 func (s *Server) HandlePostFiles(ctx context.Context, w http.ResponseWriter, r *Request) {
 	log.Debug("handle.post.files", "ruid", r.ruid)
 
@@ -183,6 +336,14 @@ func (s *Server) HandlePostFiles(ctx context.Context, w http.ResponseWriter, r *
 		toEncrypt = true
 	}
 
+	errorDocs, err := uploadErrorDocuments(r)
+	if err != nil {
+		postFilesFail.Inc(1)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fallback := r.Header.Get(api.SwarmFallbackHeader)
+
 	var addr storage.Address
 	if r.uri.Addr != "" && r.uri.Addr != "encrypt" {
 		addr, err = s.api.Resolve(ctx, r.uri)
@@ -193,7 +354,22 @@ func (s *Server) HandlePostFiles(ctx context.Context, w http.ResponseWriter, r *
 		}
 		log.Debug("resolved key", "ruid", r.ruid, "key", addr)
 	} else {
-		addr, err = s.api.NewManifest(ctx, toEncrypt)
+		// the chunk hash function of a new manifest and whether its content
+		// is sparse can be selected, content added to an existing manifest
+		// is stored like the content of the manifest
+		hash, err := uploadHash(r)
+		if err != nil {
+			postFilesFail.Inc(1)
+			Respond(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sparse, err := uploadSparse(r)
+		if err != nil {
+			postFilesFail.Inc(1)
+			Respond(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		addr, err = s.api.NewManifestWithHash(storage.WithSparse(ctx, sparse), toEncrypt, hash)
 		if err != nil {
 			postFilesFail.Inc(1)
 			Respond(w, r, err.Error(), http.StatusInternalServerError)
@@ -202,7 +378,16 @@ func (s *Server) HandlePostFiles(ctx context.Context, w http.ResponseWriter, r *
 		log.Debug("new manifest", "ruid", r.ruid, "key", addr)
 	}
 
+	ctx, tag := s.newTag(ctx, w, "upload", nil)
+	body := &countingReader{ReadCloser: r.Body}
+	r.Body = body
 	newAddr, err := s.updateManifest(ctx, addr, func(mw *api.ManifestWriter) error {
+		for _, doc := range errorDocs {
+			mw.SetErrorDocument(doc)
+		}
+		if fallback != "" {
+			mw.SetFallback(fallback)
+		}
 		switch contentType {
 
 		case "application/x-tar":
@@ -216,16 +401,67 @@ func (s *Server) HandlePostFiles(ctx context.Context, w http.ResponseWriter, r *
 		}
 	})
 	if err != nil {
+		tag.Done(err)
 		postFilesFail.Inc(1)
-		Respond(w, r, fmt.Sprintf("cannot create manifest: %s", err), http.StatusInternalServerError)
+		RespondError(w, r, fmt.Sprintf("cannot create manifest: %s", err), err, http.StatusInternalServerError)
 		return
 	}
+	tag.SetAddress(newAddr)
+	tag.Done(nil)
 
 	log.Debug("stored content", "ruid", r.ruid, "key", newAddr)
 
+	respondUpload(w, r, api.NewUploadReceipt(newAddr, tag, body.count, true))
+}
+
+// respondUpload responds with the address of the uploaded content as
+// text/plain, or with the receipt of the upload if the request accepts
+// application/json
+func respondUpload(w http.ResponseWriter, r *Request, receipt *api.UploadReceipt) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(receipt)
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, newAddr)
+	fmt.Fprint(w, receipt.Address)
+}
+
+// countingReader counts the bytes read from the body of a request
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// uploadHash returns the chunk hash function selected with the X-Swarm-Hash
+// header, the default hash function if the header is not set
+func uploadHash(r *Request) (string, error) {
+	hash := r.Header.Get(api.SwarmHashHeader)
+	if hash == "" {
+		return "", nil
+	}
+	if _, err := storage.SupportedHashFunc(hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// uploadSparse returns true if the X-Swarm-Sparse header selects to store
+// the upload sparse
+func uploadSparse(r *Request) (bool, error) {
+	sparse := r.Header.Get(api.SwarmSparseHeader)
+	if sparse == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(sparse)
 }
 
 func (s *Server) handleTarUpload(ctx context.Context, req *Request, mw *api.ManifestWriter) error {
@@ -239,22 +475,36 @@ func (s *Server) handleTarUpload(ctx context.Context, req *Request, mw *api.Mani
 			return fmt.Errorf("error reading tar stream: %s", err)
 		}
 
-		// only store regular files
-		if !hdr.FileInfo().Mode().IsRegular() {
+		// only store regular files and symbolic links
+		isLink := hdr.Typeflag == tar.TypeSymlink
+		if !isLink && !hdr.FileInfo().Mode().IsRegular() {
 			continue
 		}
 
 		// add the entry under the path from the request
 		path := path.Join(req.uri.Path, hdr.Name)
+		disposition := hdr.Xattrs["user.swarm.content-disposition"]
+		if err := validateContentDisposition(disposition); err != nil {
+			return err
+		}
 		entry := &api.ManifestEntry{
-			Path:        path,
-			ContentType: hdr.Xattrs["user.swarm.content-type"],
-			Mode:        hdr.Mode,
-			Size:        hdr.Size,
-			ModTime:     hdr.ModTime,
+			Path:               path,
+			ContentType:        hdr.Xattrs["user.swarm.content-type"],
+			ContentDisposition: disposition,
+			Mode:               hdr.Mode,
+			Size:               hdr.Size,
+			ModTime:            hdr.ModTime,
+		}
+		entry.Preload = preloadPaths(req.uri.Path, hdr.Xattrs["user.swarm.preload"])
+		var content io.Reader = tr
+		if isLink {
+			// the content of a link is its target
+			entry.LinkTarget = hdr.Linkname
+			entry.Size = int64(len(hdr.Linkname))
+			content = strings.NewReader(hdr.Linkname)
 		}
 		log.Debug("adding path to new manifest", "ruid", req.ruid, "bytes", entry.Size, "path", entry.Path)
-		contentKey, err := mw.AddEntry(ctx, tr, entry)
+		contentKey, err := mw.AddEntryInline(ctx, content, entry)
 		if err != nil {
 			return fmt.Errorf("error adding manifest entry from tar stream: %s", err)
 		}
@@ -312,7 +562,7 @@ func (s *Server) handleMultipartUpload(ctx context.Context, req *Request, bounda
 			ModTime:     time.Now(),
 		}
 		log.Debug("adding path to new manifest", "ruid", req.ruid, "bytes", entry.Size, "path", entry.Path)
-		contentKey, err := mw.AddEntry(ctx, reader, entry)
+		contentKey, err := mw.AddEntryInline(ctx, reader, entry)
 		if err != nil {
 			return fmt.Errorf("error adding manifest entry from multipart form: %s", err)
 		}
@@ -322,12 +572,17 @@ func (s *Server) handleMultipartUpload(ctx context.Context, req *Request, bounda
 
 func (s *Server) handleDirectUpload(ctx context.Context, req *Request, mw *api.ManifestWriter) error {
 	log.Debug("handle.direct.upload", "ruid", req.ruid)
-	key, err := mw.AddEntry(ctx, req.Body, &api.ManifestEntry{
-		Path:        req.uri.Path,
-		ContentType: req.Header.Get("Content-Type"),
-		Mode:        0644,
-		Size:        req.ContentLength,
-		ModTime:     time.Now(),
+	disposition := req.Header.Get("Content-Disposition")
+	if err := validateContentDisposition(disposition); err != nil {
+		return err
+	}
+	key, err := mw.AddEntryInline(ctx, req.Body, &api.ManifestEntry{
+		Path:               req.uri.Path,
+		ContentType:        req.Header.Get("Content-Type"),
+		ContentDisposition: disposition,
+		Mode:               0644,
+		Size:               req.ContentLength,
+		ModTime:            time.Now(),
 	})
 	if err != nil {
 		return err
@@ -352,11 +607,66 @@ func (s *Server) HandleDelete(ctx context.Context, w http.ResponseWriter, r *Req
 
 	newKey, err := s.updateManifest(ctx, key, func(mw *api.ManifestWriter) error {
 		log.Debug(fmt.Sprintf("removing %s from manifest %s", r.uri.Path, key.Log()), "ruid", r.ruid)
-		return mw.RemoveEntry(r.uri.Path)
+		return mw.RemoveEntries(r.uri.Path)
 	})
+	if err == api.ErrEntryNotFound {
+		deleteFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("%s not found in manifest %s", r.uri.Path, key), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		deleteFail.Inc(1)
-		Respond(w, r, fmt.Sprintf("cannot update manifest: %s", err), http.StatusInternalServerError)
+		RespondError(w, r, fmt.Sprintf("cannot update manifest: %s", err), err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, newKey)
+}
+
+// HandleMove handles a MOVE request to bzz:/<manifest>/<path> with a
+// Destination header of bzz:/<manifest>/<new path>, moves <path> to
+// <new path> in <manifest> and returns the resulting manifest hash as a
+// text/plain response
+//
+// Directories are moved with all the entries under them. Existing entries
+// at the destination are replaced unless the Overwrite header is "F".
+func (s *Server) HandleMove(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.move", "ruid", r.ruid)
+
+	moveCount.Inc(1)
+	dest, err := moveDestination(r)
+	if err != nil {
+		moveFail.Inc(1)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	overwrite := r.Header.Get("Overwrite") != "F"
+
+	key, err := s.api.Resolve(ctx, r.uri)
+	if err != nil {
+		moveFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("cannot resolve %s: %s", r.uri.Addr, err), http.StatusInternalServerError)
+		return
+	}
+
+	newKey, err := s.updateManifest(ctx, key, func(mw *api.ManifestWriter) error {
+		log.Debug(fmt.Sprintf("moving %s to %s in manifest %s", r.uri.Path, dest, key.Log()), "ruid", r.ruid)
+		return mw.MoveEntry(r.uri.Path, dest, overwrite)
+	})
+	switch {
+	case err == api.ErrEntryNotFound:
+		moveFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("%s not found in manifest %s", r.uri.Path, key), http.StatusNotFound)
+		return
+	case err == api.ErrEntryExists:
+		moveFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("%s already exists in manifest %s", dest, key), http.StatusPreconditionFailed)
+		return
+	case err != nil:
+		moveFail.Inc(1)
+		RespondError(w, r, fmt.Sprintf("cannot update manifest: %s", err), err, http.StatusInternalServerError)
 		return
 	}
 
@@ -365,6 +675,30 @@ func (s *Server) HandleDelete(ctx context.Context, w http.ResponseWriter, r *Req
 	fmt.Fprint(w, newKey)
 }
 
+// moveDestination returns the new path of a MOVE request from its
+// Destination header, a URL or path of the same manifest as the request
+func moveDestination(r *Request) (string, error) {
+	header := r.Header.Get("Destination")
+	if header == "" {
+		return "", errors.New("missing Destination header")
+	}
+	u, err := url.Parse(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid Destination header %q: %s", header, err)
+	}
+	dest, err := api.Parse(strings.TrimLeft(u.Path, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Destination header %q: %s", header, err)
+	}
+	if dest.Scheme != r.uri.Scheme || dest.Addr != r.uri.Addr {
+		return "", fmt.Errorf("Destination %q is not in the manifest %s", header, r.uri.Addr)
+	}
+	if r.uri.Path == "" || dest.Path == "" {
+		return "", errors.New("cannot move the root of a manifest")
+	}
+	return dest.Path, nil
+}
+
 // Parses a resource update post url to corresponding action
 // possible combinations:
 // /			add multihash update to existing hash
@@ -400,6 +734,18 @@ func resourcePostMode(path string) (isRaw bool, frequency uint64, err error) {
 // The resource name will be verbatim what is passed as the address part of the url.
 // For example, if a POST is made to /bzz-resource:/foo.eth/raw/13 a new resource with frequency 13
 // and name "foo.eth" will be created
+//
+// A resource updatable by a set of keys is created by passing their addresses
+// as a comma separated "authors" query parameter, the "merge" query parameter
+// selects the merge rule ("last-writer" or "sequence")
+//
+// A resource created without data has no updates until it is updated.
+//
+// An update signed by the client instead of the node passes the hex encoded
+// signature as the "signature" query parameter together with the "period"
+// and "version" of the next update returned by a GET with the "meta" query
+// parameter, the signed digest is mru.UpdateDigest of the update address and
+// the data
 func (s *Server) HandlePostResource(ctx context.Context, w http.ResponseWriter, r *Request) {
 	log.Debug("handle.post.resource", "ruid", r.ruid)
 	var err error
@@ -418,7 +764,24 @@ func (s *Server) HandlePostResource(ctx context.Context, w http.ResponseWriter,
 		name = r.uri.Addr
 
 		// the key is the content addressed root chunk holding mutable resource metadata information
-		addr, err = s.api.ResourceCreate(r.Context(), name, frequency)
+		if authors := r.URL.Query().Get("authors"); authors != "" {
+			var addrs []common.Address
+			for _, a := range strings.Split(authors, ",") {
+				if !common.IsHexAddress(a) {
+					Respond(w, r, fmt.Sprintf("invalid author address: %s", a), http.StatusBadRequest)
+					return
+				}
+				addrs = append(addrs, common.HexToAddress(a))
+			}
+			merge, err := mru.ParseMergeRule(r.URL.Query().Get("merge"))
+			if err != nil {
+				Respond(w, r, err.Error(), http.StatusBadRequest)
+				return
+			}
+			addr, err = s.api.ResourceCreateMultiAuthor(r.Context(), name, frequency, addrs, merge)
+		} else {
+			addr, err = s.api.ResourceCreate(r.Context(), name, frequency)
+		}
 		if err != nil {
 			code, err2 := s.translateResourceError(w, r, "resource creation fail", err)
 
@@ -469,7 +832,7 @@ func (s *Server) HandlePostResource(ctx context.Context, w http.ResponseWriter,
 
 		log.Debug("handle.post.resource: resolved", "ruid", r.ruid, "manifestkey", manifestAddr, "rootchunkkey", addr)
 
-		name, _, err = s.api.ResourceLookup(r.Context(), addr, 0, 0, &mru.LookupParams{})
+		name, _, _, err = s.api.ResourceLookup(r.Context(), addr, 0, 0, &mru.LookupParams{})
 		if err != nil {
 			Respond(w, r, err.Error(), http.StatusNotFound)
 			return
@@ -484,24 +847,25 @@ func (s *Server) HandlePostResource(ctx context.Context, w http.ResponseWriter,
 	}
 
 	// Multihash will be passed as hex-encoded data, so we need to parse this to bytes
-	if isRaw {
-		_, _, _, err = s.api.ResourceUpdate(r.Context(), name, data)
-		if err != nil {
-			Respond(w, r, err.Error(), http.StatusBadRequest)
-			return
-		}
-	} else {
-		bytesdata, err := hexutil.Decode(string(data))
-		if err != nil {
-			Respond(w, r, err.Error(), http.StatusBadRequest)
-			return
-		}
-		_, _, _, err = s.api.ResourceUpdateMultihash(r.Context(), name, bytesdata)
-		if err != nil {
+	if !isRaw && len(data) > 0 {
+		if data, err = hexutil.Decode(string(data)); err != nil {
 			Respond(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
+	switch {
+	case frequency > 0 && len(data) == 0:
+	case r.URL.Query().Get("signature") != "":
+		err = s.resourceUpdateSigned(r, name, data, !isRaw)
+	case isRaw:
+		_, _, _, err = s.api.ResourceUpdate(r.Context(), name, data)
+	default:
+		_, _, _, err = s.api.ResourceUpdateMultihash(r.Context(), name, data)
+	}
+	if err != nil {
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// If we have data to return, write this now
 	// \TODO there should always be data to return here
@@ -514,11 +878,40 @@ func (s *Server) HandlePostResource(ctx context.Context, w http.ResponseWriter,
 	w.WriteHeader(http.StatusOK)
 }
 
+// resourceUpdateSigned adds the update of a resource signed by the client
+// with the signature, period and version given as query parameters
+func (s *Server) resourceUpdateSigned(r *Request, name string, data []byte, multihash bool) error {
+	query := r.URL.Query()
+	sigdata, err := hexutil.Decode(query.Get("signature"))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %s", err)
+	}
+	var signature mru.Signature
+	if len(sigdata) != len(signature) {
+		return fmt.Errorf("invalid signature length %d", len(sigdata))
+	}
+	copy(signature[:], sigdata)
+	period, err := strconv.ParseUint(query.Get("period"), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid period: %s", err)
+	}
+	version, err := strconv.ParseUint(query.Get("version"), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid version: %s", err)
+	}
+	_, err = s.api.ResourceUpdateSigned(r.Context(), name, uint32(period), uint32(version), data, multihash, signature)
+	return err
+}
+
 // Retrieve mutable resource updates:
 // bzz-resource://<id> - get latest update
 // bzz-resource://<id>/<n> - get latest update on period n
 // bzz-resource://<id>/<n>/<m> - get update version m of period n
 // <id> = ens name or hash
+//
+// The latest update is looked up no further back than the period given as
+// the "hint" query parameter. With the "meta" query parameter the resource
+// is described as JSON instead (see mru.Info).
 func (s *Server) HandleGetResource(ctx context.Context, w http.ResponseWriter, r *Request) {
 	s.handleGetResource(ctx, w, r)
 }
@@ -551,6 +944,28 @@ func (s *Server) handleGetResource(ctx context.Context, w http.ResponseWriter, r
 
 	log.Debug("handle.get.resource: resolved", "ruid", r.ruid, "manifestkey", manifestAddr, "rootchunk key", key)
 
+	if r.URL.Query().Get("meta") != "" {
+		info, err := s.api.ResourceInfo(r.Context(), key)
+		if err != nil {
+			code, err2 := s.translateResourceError(w, r, "mutable resource lookup fail", err)
+			Respond(w, r, err2.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+	var maxLookup *mru.LookupParams
+	if hint := r.URL.Query().Get("hint"); hint != "" {
+		period, err := strconv.ParseUint(hint, 10, 32)
+		if err != nil {
+			Respond(w, r, fmt.Sprintf("invalid hint: %s", err), http.StatusBadRequest)
+			return
+		}
+		maxLookup = &mru.LookupParams{Hint: uint32(period)}
+	}
+
 	// determine if the query specifies period and version
 	var params []string
 	if len(r.uri.Path) > 0 {
@@ -559,12 +974,12 @@ func (s *Server) handleGetResource(ctx context.Context, w http.ResponseWriter, r
 	var name string
 	var period uint64
 	var version uint64
+	var updateAddr storage.Address
 	var data []byte
-	now := time.Now()
 
 	switch len(params) {
 	case 0: // latest only
-		name, data, err = s.api.ResourceLookup(r.Context(), key, 0, 0, nil)
+		name, updateAddr, data, err = s.api.ResourceLookup(r.Context(), key, 0, 0, maxLookup)
 	case 2: // specific period and version
 		version, err = strconv.ParseUint(params[1], 10, 32)
 		if err != nil {
@@ -574,13 +989,13 @@ func (s *Server) handleGetResource(ctx context.Context, w http.ResponseWriter, r
 		if err != nil {
 			break
 		}
-		name, data, err = s.api.ResourceLookup(r.Context(), key, uint32(period), uint32(version), nil)
+		name, updateAddr, data, err = s.api.ResourceLookup(r.Context(), key, uint32(period), uint32(version), nil)
 	case 1: // last version of specific period
 		period, err = strconv.ParseUint(params[0], 10, 32)
 		if err != nil {
 			break
 		}
-		name, data, err = s.api.ResourceLookup(r.Context(), key, uint32(period), uint32(version), nil)
+		name, updateAddr, data, err = s.api.ResourceLookup(r.Context(), key, uint32(period), uint32(version), nil)
 	default: // bogus
 		err = mru.NewError(storage.ErrInvalidValue, "invalid mutable resource request")
 	}
@@ -594,8 +1009,18 @@ func (s *Server) handleGetResource(ctx context.Context, w http.ResponseWriter, r
 
 	// All ok, serve the retrieved update
 	log.Debug("Found update", "name", name, "ruid", r.ruid)
+
+	// the update is immutable, whether it is the latest one is not
+	etag := updateAddr.Hex()
+	setValidators(w, r, etag, time.Time{})
+	if len(params) == 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if checkNotModified(w, r, etag, time.Time{}) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/octet-stream")
-	http.ServeContent(w, &r.Request, "", now, bytes.NewReader(data))
+	http.ServeContent(w, &r.Request, "", time.Time{}, bytes.NewReader(data))
 }
 
 func (s *Server) translateResourceError(w http.ResponseWriter, r *Request, supErr string, err error) (int, error) {
@@ -642,8 +1067,13 @@ func (s *Server) HandleGet(ctx context.Context, w http.ResponseWriter, r *Reques
 
 	log.Debug("handle.get: resolved", "ruid", r.ruid, "key", addr)
 
+	ctx, tag := s.newTag(ctx, w, "download", addr)
+	defer tag.Done(nil)
+
 	// if path is set, interpret <key> as a manifest and return the
 	// raw entry at the given path
+	var inline *api.ManifestEntry
+	var modTime time.Time
 	if r.uri.Path != "" {
 		walker, err := s.api.NewManifestWalker(ctx, addr, nil)
 		if err != nil {
@@ -681,22 +1111,39 @@ func (s *Server) HandleGet(ctx context.Context, w http.ResponseWriter, r *Reques
 			return
 		}
 		addr = storage.Address(common.Hex2Bytes(entry.Hash))
+		modTime = entry.ModTime
+		if entry.Data != nil {
+			inline = entry
+			addr, err = api.InlineAddress(ctx, entry.Data, "")
+			if err != nil {
+				getFail.Inc(1)
+				Respond(w, r, fmt.Sprintf("cannot hash inline content: %s", err), http.StatusInternalServerError)
+				return
+			}
+		} else if len(entry.Parts) > 0 {
+			// composite content is read from its parts like inline content
+			inline = entry
+			addr = api.CompositeAddress(entry.Parts)
+		}
 	}
+	// set etag to manifest key or raw entry key.
 	etag := common.Bytes2Hex(addr)
-	noneMatchEtag := r.Header.Get("If-None-Match")
-	w.Header().Set("ETag", fmt.Sprintf("%q", etag)) // set etag to manifest key or raw entry key.
-	if noneMatchEtag != "" {
-		if bytes.Equal(storage.Address(common.Hex2Bytes(noneMatchEtag)), addr) {
-			Respond(w, r, "Not Modified", http.StatusNotModified)
-			return
-		}
+	setValidators(w, r, etag, modTime)
+	if checkNotModified(w, r, etag, modTime) {
+		return
 	}
 
 	// check the root chunk exists by retrieving the file's size
-	reader, isEncrypted := s.api.Retrieve(ctx, addr)
+	var reader storage.LazySectionReader
+	var isEncrypted bool
+	if inline != nil {
+		reader, isEncrypted = s.api.RetrieveEntry(ctx, inline)
+	} else {
+		reader, isEncrypted = s.api.Retrieve(ctx, addr)
+	}
 	if _, err := reader.Size(nil); err != nil {
 		getFail.Inc(1)
-		Respond(w, r, fmt.Sprintf("root chunk not found %s: %s", addr, err), http.StatusNotFound)
+		RespondError(w, r, fmt.Sprintf("root chunk not found %s: %s", addr, err), err, http.StatusNotFound)
 		return
 	}
 
@@ -711,7 +1158,10 @@ func (s *Server) HandleGet(ctx context.Context, w http.ResponseWriter, r *Reques
 			contentType = typ
 		}
 		w.Header().Set("Content-Type", contentType)
-		http.ServeContent(w, &r.Request, "", time.Now(), reader)
+		if filename := r.URL.Query().Get("filename"); filename != "" {
+			w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+		}
+		http.ServeContent(w, &r.Request, "", modTime, reader)
 	case r.uri.Hash():
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
@@ -742,7 +1192,7 @@ func (s *Server) HandleGetFiles(ctx context.Context, w http.ResponseWriter, r *R
 	walker, err := s.api.NewManifestWalker(ctx, addr, nil)
 	if err != nil {
 		getFilesFail.Inc(1)
-		Respond(w, r, err.Error(), http.StatusInternalServerError)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
 		return
 	}
 
@@ -757,8 +1207,22 @@ func (s *Server) HandleGetFiles(ctx context.Context, w http.ResponseWriter, r *R
 			return nil
 		}
 
+		// symbolic links have no content in the tar stream
+		if entry.LinkTarget != "" {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     entry.Path,
+				Linkname: entry.LinkTarget,
+				Mode:     entry.Mode,
+				ModTime:  entry.ModTime,
+				Xattrs: map[string]string{
+					"user.swarm.content-type": entry.ContentType,
+				},
+			})
+		}
+
 		// retrieve the entry's key and size
-		reader, isEncrypted := s.api.Retrieve(ctx, storage.Address(common.Hex2Bytes(entry.Hash)))
+		reader, isEncrypted := s.api.RetrieveEntry(ctx, entry)
 		size, err := reader.Size(nil)
 		if err != nil {
 			return err
@@ -795,6 +1259,156 @@ func (s *Server) HandleGetFiles(ctx context.Context, w http.ResponseWriter, r *R
 	}
 }
 
+// HandleGetMissing handles a GET request to bzz:/<manifest>?missing=true
+// and responds with the JSON list of the chunks of the content which are not
+// available in the local store of the node
+func (s *Server) HandleGetMissing(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.get.missing", "ruid", r.ruid, "uri", r.uri)
+	getMissingCount.Inc(1)
+	addr, err := s.api.Resolve(ctx, r.uri)
+	if err != nil {
+		getMissingFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("cannot resolve %s: %s", r.uri.Addr, err), http.StatusNotFound)
+		return
+	}
+
+	missing, err := s.api.MissingChunks(ctx, addr)
+	if err != nil {
+		getMissingFail.Inc(1)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
+		return
+	}
+	list := make([]string, 0, len(missing))
+	for _, addr := range missing {
+		list = append(list, addr.Hex())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandlePostPrefetch handles a POST request to bzz-prefetch:/<hash>, it
+// starts retrieving the content into the local store and responds with
+// 202 Accepted and the tag following the progress of the retrieval, the uid
+// of the tag is returned in the X-Swarm-Tag header
+func (s *Server) HandlePostPrefetch(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.post.prefetch", "ruid", r.ruid, "uri", r.uri)
+	prefetchCount.Inc(1)
+	addr, err := s.api.Resolve(ctx, r.uri)
+	if err != nil {
+		prefetchFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("cannot resolve %s: %s", r.uri.Addr, err), http.StatusNotFound)
+		return
+	}
+
+	tag, err := s.api.Prefetch(ctx, addr)
+	if err != nil {
+		prefetchFail.Inc(1)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Swarm-Tag", strconv.FormatUint(uint64(tag.Uid), 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// HandleGetPrefetch handles a GET request to bzz-prefetch:/<hash> and
+// responds with the tag of the last prefetch of the content
+func (s *Server) HandleGetPrefetch(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.get.prefetch", "ruid", r.ruid, "uri", r.uri)
+	addr, err := s.api.Resolve(ctx, r.uri)
+	if err != nil {
+		Respond(w, r, fmt.Sprintf("cannot resolve %s: %s", r.uri.Addr, err), http.StatusNotFound)
+		return
+	}
+
+	tag, ok := s.api.PrefetchTag(addr)
+	if !ok {
+		Respond(w, r, fmt.Sprintf("no prefetch of %s", addr), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Swarm-Tag", strconv.FormatUint(uint64(tag.Uid), 10))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// HandlePin handles a POST or DELETE request to bzz-pin:/<hash>, it pins the
+// locally stored content with the given root so that it is kept by garbage
+// collection, or removes a pin of the content
+func (s *Server) HandlePin(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.pin", "ruid", r.ruid, "method", r.Method, "uri", r.uri)
+	pinCount.Inc(1)
+	addr, err := s.api.Resolve(ctx, r.uri)
+	if err != nil {
+		pinFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("cannot resolve %s: %s", r.uri.Addr, err), http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		err = s.api.Unpin(ctx, addr)
+	} else {
+		err = s.api.Pin(ctx, addr)
+	}
+	if err != nil {
+		pinFail.Inc(1)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleGetTag handles a GET request to bzz-tag:/<uid> and responds with the
+// state of the tag with the given uid
+func (s *Server) HandleGetTag(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.get.tag", "ruid", r.ruid, "uri", r.uri)
+	uid, err := strconv.ParseUint(r.uri.Addr, 10, 32)
+	if err != nil {
+		Respond(w, r, fmt.Sprintf("invalid tag uid %q", r.uri.Addr), http.StatusBadRequest)
+		return
+	}
+	tag, ok := s.api.Tags().Get(uint32(uid))
+	if !ok {
+		Respond(w, r, fmt.Sprintf("tag %d not found", uid), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// HandleGetChunk handles a GET request to bzz-chunk:/<addr> and responds
+// with the data of the chunk with the given address, the span followed by
+// the payload, so that other nodes can retrieve chunks from the node over
+// HTTP
+func (s *Server) HandleGetChunk(ctx context.Context, w http.ResponseWriter, r *Request) {
+	log.Debug("handle.get.chunk", "ruid", r.ruid, "uri", r.uri)
+	getChunkCount.Inc(1)
+	addr := r.uri.Address()
+	if addr == nil {
+		getChunkFail.Inc(1)
+		Respond(w, r, fmt.Sprintf("invalid chunk address %q", r.uri.Addr), http.StatusBadRequest)
+		return
+	}
+	data, err := s.api.GetChunk(ctx, addr)
+	if err != nil {
+		getChunkFail.Inc(1)
+		RespondError(w, r, fmt.Sprintf("chunk %s not found: %s", addr, err), err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "max-age=2147483648, immutable")
+	w.Write(data)
+}
+
+// newTag creates the tag counting the chunks of the operation of a request,
+// the uid of the tag is returned in the X-Swarm-Tag header and the returned
+// context carries the tag to the storage layer
+func (s *Server) newTag(ctx context.Context, w http.ResponseWriter, name string, addr storage.Address) (context.Context, *storage.Tag) {
+	tag := s.api.Tags().New(name, addr)
+	w.Header().Set("X-Swarm-Tag", strconv.FormatUint(uint64(tag.Uid), 10))
+	return storage.WithTag(ctx, tag), tag
+}
+
 // HandleGetList handles a GET request to bzz-list:/<manifest>/<path> and returns
 // a list of all files contained in <manifest> under <path> grouped into
 // common prefixes using "/" as a delimiter
@@ -815,11 +1429,17 @@ func (s *Server) HandleGetList(ctx context.Context, w http.ResponseWriter, r *Re
 	}
 	log.Debug("handle.get.list: resolved", "ruid", r.ruid, "key", addr)
 
-	list, err := s.getManifestList(ctx, addr, r.uri.Path)
+	addr, ok := s.resolveAccess(ctx, w, r, addr)
+	if !ok {
+		getListFail.Inc(1)
+		return
+	}
+
+	list, err := s.api.GetManifestList(ctx, addr, r.uri.Path)
 
 	if err != nil {
 		getListFail.Inc(1)
-		Respond(w, r, err.Error(), http.StatusInternalServerError)
+		RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
 		return
 	}
 
@@ -846,60 +1466,24 @@ func (s *Server) HandleGetList(ctx context.Context, w http.ResponseWriter, r *Re
 	json.NewEncoder(w).Encode(&list)
 }
 
-func (s *Server) getManifestList(ctx context.Context, addr storage.Address, prefix string) (list api.ManifestList, err error) {
-	walker, err := s.api.NewManifestWalker(ctx, addr, nil)
+// resolveAccess returns the address of the manifest the access manifest with
+// the given address grants the request access to, using the password of the
+// basic authentication of the request or the key of the node, or the address
+// itself if it is not an access manifest. It responds with 401 and returns
+// false if access is denied.
+func (s *Server) resolveAccess(ctx context.Context, w http.ResponseWriter, r *Request, addr storage.Address) (storage.Address, bool) {
+	_, password, _ := r.BasicAuth()
+	resolved, err := s.api.ResolveAccess(ctx, addr, password)
+	if err == api.ErrAccessDenied {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", addr.Hex()))
+		Respond(w, r, fmt.Sprintf("access to %s denied", r.uri.Addr), http.StatusUnauthorized)
+		return nil, false
+	}
 	if err != nil {
-		return
+		Respond(w, r, fmt.Sprintf("cannot resolve access manifest %s: %s", r.uri.Addr, err), http.StatusInternalServerError)
+		return nil, false
 	}
-
-	err = walker.Walk(func(entry *api.ManifestEntry) error {
-		// handle non-manifest files
-		if entry.ContentType != api.ManifestType {
-			// ignore the file if it doesn't have the specified prefix
-			if !strings.HasPrefix(entry.Path, prefix) {
-				return nil
-			}
-
-			// if the path after the prefix contains a slash, add a
-			// common prefix to the list, otherwise add the entry
-			suffix := strings.TrimPrefix(entry.Path, prefix)
-			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return nil
-			}
-			if entry.Path == "" {
-				entry.Path = "/"
-			}
-			list.Entries = append(list.Entries, entry)
-			return nil
-		}
-
-		// if the manifest's path is a prefix of the specified prefix
-		// then just recurse into the manifest by returning nil and
-		// continuing the walk
-		if strings.HasPrefix(prefix, entry.Path) {
-			return nil
-		}
-
-		// if the manifest's path has the specified prefix, then if the
-		// path after the prefix contains a slash, add a common prefix
-		// to the list and skip the manifest, otherwise recurse into
-		// the manifest by returning nil and continuing the walk
-		if strings.HasPrefix(entry.Path, prefix) {
-			suffix := strings.TrimPrefix(entry.Path, prefix)
-			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return api.ErrSkipManifest
-			}
-			return nil
-		}
-
-		// the manifest neither has the prefix or needs recursing in to
-		// so just skip it
-		return api.ErrSkipManifest
-	})
-
-	return list, nil
+	return resolved, true
 }
 
 // HandleGetFile handles a GET request to bzz://<manifest>/<path> and responds
@@ -915,7 +1499,23 @@ func (s *Server) HandleGetFile(ctx context.Context, w http.ResponseWriter, r *Re
 	var err error
 	manifestAddr := r.uri.Address()
 
-	if manifestAddr == nil {
+	fallbacks, err := fallbackURIs(r)
+	if err != nil {
+		getFileFail.Inc(1)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(fallbacks) > 0 {
+		// serve the path from the first of the mirrored roots it can
+		// be retrieved from
+		manifestAddr, err = s.api.SelectRoot(ctx, append([]*api.URI{r.uri}, fallbacks...), r.uri.Path)
+		if err != nil {
+			getFileNotFound.Inc(1)
+			RespondError(w, r, fmt.Sprintf("cannot retrieve %s from any root: %s", r.uri.Path, err), err, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Swarm-Root", manifestAddr.Hex())
+	} else if manifestAddr == nil {
 		manifestAddr, err = s.api.Resolve(ctx, r.uri)
 		if err != nil {
 			getFileFail.Inc(1)
@@ -928,26 +1528,41 @@ func (s *Server) HandleGetFile(ctx context.Context, w http.ResponseWriter, r *Re
 
 	log.Debug("handle.get.file: resolved", "ruid", r.ruid, "key", manifestAddr)
 
-	reader, contentType, status, contentKey, err := s.api.Get(ctx, manifestAddr, r.uri.Path)
+	resolved, ok := s.resolveAccess(ctx, w, r, manifestAddr)
+	if !ok {
+		getFileFail.Inc(1)
+		return
+	}
+	if !bytes.Equal(resolved, manifestAddr) {
+		// the response depends on the credentials of the request
+		w.Header().Set("Cache-Control", "private")
+		manifestAddr = resolved
+	}
 
-	etag := common.Bytes2Hex(contentKey)
-	noneMatchEtag := r.Header.Get("If-None-Match")
-	w.Header().Set("ETag", fmt.Sprintf("%q", etag)) // set etag to actual content key.
-	if noneMatchEtag != "" {
-		if bytes.Equal(storage.Address(common.Hex2Bytes(noneMatchEtag)), contentKey) {
-			Respond(w, r, "Not Modified", http.StatusNotModified)
-			return
+	ctx, tag := s.newTag(ctx, w, "download", manifestAddr)
+	defer tag.Done(nil)
+
+	path := r.uri.Path
+	reader, contentType, status, contentKey, entry, err := s.api.Get(ctx, manifestAddr, path)
+	if err != nil && status == http.StatusNotFound {
+		// the unresolved paths of single-page applications are routed in
+		// the browser by the fallback entry
+		if fallback := s.fallbackPath(ctx, r, manifestAddr); fallback != "" && fallback != path {
+			path = fallback
+			reader, contentType, status, contentKey, entry, err = s.api.Get(ctx, manifestAddr, path)
 		}
 	}
-
 	if err != nil {
 		switch status {
 		case http.StatusNotFound:
 			getFileNotFound.Inc(1)
-			Respond(w, r, err.Error(), http.StatusNotFound)
+			if s.serveErrorDocument(ctx, w, r, manifestAddr, status) {
+				return
+			}
+			RespondError(w, r, err.Error(), err, http.StatusNotFound)
 		default:
 			getFileFail.Inc(1)
-			Respond(w, r, err.Error(), http.StatusInternalServerError)
+			RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
 		}
 		return
 	}
@@ -955,11 +1570,11 @@ func (s *Server) HandleGetFile(ctx context.Context, w http.ResponseWriter, r *Re
 	//the request results in ambiguous files
 	//e.g. /read with readme.md and readinglist.txt available in manifest
 	if status == http.StatusMultipleChoices {
-		list, err := s.getManifestList(ctx, manifestAddr, r.uri.Path)
+		list, err := s.api.GetManifestList(ctx, manifestAddr, path)
 
 		if err != nil {
 			getFileFail.Inc(1)
-			Respond(w, r, err.Error(), http.StatusInternalServerError)
+			RespondError(w, r, err.Error(), err, http.StatusInternalServerError)
 			return
 		}
 
@@ -969,15 +1584,59 @@ func (s *Server) HandleGetFile(ctx context.Context, w http.ResponseWriter, r *Re
 		return
 	}
 
+	chain, err := s.requestedTransforms(r.URL.Query())
+	if err != nil {
+		getFileFail.Inc(1)
+		Respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// set etag to actual content key, or to the key of the transformed
+	// content, so that content behind mutable names is revalidated
+	etag := common.Bytes2Hex(contentKey)
+	if chain != nil {
+		etag = transformKey(contentKey, r.URL.Query())[2:]
+	}
+	var modTime time.Time
+	if entry != nil {
+		modTime = entry.ModTime
+	}
+	setValidators(w, r, etag, modTime)
+	if checkNotModified(w, r, etag, modTime) {
+		return
+	}
+
 	// check the root chunk exists by retrieving the file's size
 	if _, err := reader.Size(nil); err != nil {
 		getFileNotFound.Inc(1)
-		Respond(w, r, fmt.Sprintf("file not found %s: %s", r.uri, err), http.StatusNotFound)
+		RespondError(w, r, fmt.Sprintf("file not found %s: %s", r.uri, err), err, http.StatusNotFound)
+		return
+	}
+
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	} else if entry != nil && entry.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", entry.ContentDisposition)
+	}
+
+	// the dependencies of mirrored content are linked under the root it is
+	// served from
+	if entry != nil && len(entry.Preload) > 0 {
+		root := r.uri.Addr
+		if len(fallbacks) > 0 {
+			root = manifestAddr.Hex()
+		}
+		s.preload(ctx, w, r, root, manifestAddr, entry)
+	}
+
+	if chain != nil {
+		s.serveTransformed(ctx, w, r, chain, contentKey, reader, contentType)
 		return
 	}
 
 	w.Header().Set("Content-Type", contentType)
-	http.ServeContent(w, &r.Request, "", time.Now(), newBufferedReadSeeker(reader, getFileBufferSize))
+	setMediaCacheControl(w, r, contentType)
+	http.ServeContent(w, &r.Request, "", modTime, newBufferedReadSeeker(reader, getFileBufferSize))
 }
 
 // The size of buffer used for bufio.Reader on LazyChunkReader passed to
@@ -1011,18 +1670,114 @@ func (b bufferedReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	return b.s.Seek(offset, whence)
 }
 
+// fallbackURIs parses the mirror roots given with the fallback query
+// parameter, either repeated or as a comma separated list, e.g.
+// bzz:/<root>/path?fallback=<mirror1>,<mirror2>
+func fallbackURIs(r *Request) ([]*api.URI, error) {
+	var uris []*api.URI
+	for _, param := range r.URL.Query()["fallback"] {
+		for _, addr := range strings.Split(param, ",") {
+			if addr == "" {
+				continue
+			}
+			uri, err := api.Parse("bzz:/" + addr)
+			if err != nil || uri.Path != "" {
+				return nil, fmt.Errorf("invalid fallback root %q", addr)
+			}
+			uris = append(uris, uri)
+		}
+	}
+	return uris, nil
+}
+
+// contentDisposition returns a Content-Disposition header value for the
+// given filename, encoded as per RFC 6266 if it contains non-ASCII characters
+func contentDisposition(dispositionType, filename string) string {
+	var fallback, encoded bytes.Buffer
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r < 0x20 || r > 0x7e:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			ascii = false
+			fallback.WriteByte('_')
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	if ascii {
+		return fmt.Sprintf("%s; filename=\"%s\"", dispositionType, filename)
+	}
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", dispositionType, fallback.String(), encoded.String())
+}
+
+// isAttrChar returns whether b can be used unencoded in an RFC 5987
+// extended parameter value
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// validateContentDisposition checks that a Content-Disposition value stored
+// in a manifest entry is well formed
+func validateContentDisposition(disposition string) error {
+	if disposition == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(disposition); err != nil {
+		return fmt.Errorf("invalid content disposition %q: %s", disposition, err)
+	}
+	return nil
+}
+
 func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	ctx := context.TODO()
 
+	// the roots served at the subdomains of the gateway domains are
+	// requested as bzz:/<root>/<path>
+	root, subdomain := s.subdomainRoot(r)
+	if subdomain {
+		getSubdomainCount.Inc(1)
+		r = subdomainRequest(r, root)
+	}
+
 	defer metrics.GetOrRegisterResettingTimer(fmt.Sprintf("http.request.%s.time", r.Method), nil).UpdateSince(time.Now())
 	req := &Request{Request: *r, ruid: uuid.New()[:8]}
 	metrics.GetOrRegisterCounter(fmt.Sprintf("http.request.%s", r.Method), nil).Inc(1)
 	log.Info("serving request", "ruid", req.ruid, "method", r.Method, "url", r.RequestURI)
 
+	// the request ID is returned so that it can be quoted in bug reports
+	// and the logs of the request can be found by it
+	ctx = sctx.SetRequestID(ctx, req.ruid)
+	rw.Header().Set("X-Swarm-Request-Id", req.ruid)
+
 	// wrapping the ResponseWriter, so that we get the response code set by http.ServeContent
 	w := newLoggingResponseWriter(rw)
+	if s.accessLog != nil {
+		start := time.Now()
+		defer func() {
+			s.accessLog.record(req, w, time.Since(start))
+		}()
+	}
 
-	if r.RequestURI == "/" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+	if subdomain && r.Method != "GET" {
+		Respond(w, req, fmt.Sprintf("%s method is not supported on %s", r.Method, r.Host), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.RequestURI == "/" && strings.Contains(r.Header.Get("Accept"), "text/html") && !subdomain {
 
 		err := landingPageTemplate.Execute(w, nil)
 		if err != nil {
@@ -1031,13 +1786,23 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Path == AccessStatsPath && r.Method == "GET" && s.accessLog != nil {
+		s.HandleAccessStats(w, req)
+		return
+	}
+
+	if (r.URL.Path == TakedownPath || strings.HasPrefix(r.URL.Path, TakedownPath+"/")) && s.takedownToken != "" {
+		s.HandleTakedown(ctx, w, req)
+		return
+	}
+
 	if r.URL.Path == "/robots.txt" {
 		w.Header().Set("Last-Modified", time.Now().Format(http.TimeFormat))
 		fmt.Fprintf(w, "User-agent: *\nDisallow: /")
 		return
 	}
 
-	if r.RequestURI == "/" && strings.Contains(r.Header.Get("Accept"), "application/json") {
+	if r.RequestURI == "/" && strings.Contains(r.Header.Get("Accept"), "application/json") && !subdomain {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode("Welcome to Swarm!")
@@ -1052,6 +1817,21 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	req.uri = uri
 
+	if s.policy != nil {
+		if status, msg := s.policy.check(ctx, s.api, req); status != 0 {
+			Respond(w, req, msg, status)
+			return
+		}
+	}
+
+	if s.verifier != nil && r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS" {
+		if status, msg := s.verifier.verify(req); status != 0 {
+			Respond(w, req, msg, status)
+			return
+		}
+		defer req.Body.Close()
+	}
+
 	log.Debug("parsed request path", "ruid", req.ruid, "method", req.Method, "uri.Addr", req.uri.Addr, "uri.Path", req.uri.Path, "uri.Scheme", req.uri.Scheme)
 
 	switch r.Method {
@@ -1062,8 +1842,14 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		} else if uri.Resource() {
 			log.Debug("handlePostResource")
 			s.HandlePostResource(ctx, w, req)
-		} else if uri.Immutable() || uri.List() || uri.Hash() {
-			log.Debug("POST not allowed on immutable, list or hash")
+		} else if uri.Prefetch() {
+			log.Debug("handlePostPrefetch")
+			s.HandlePostPrefetch(ctx, w, req)
+		} else if uri.Pin() {
+			log.Debug("handlePin")
+			s.HandlePin(ctx, w, req)
+		} else if uri.Immutable() || uri.List() || uri.Hash() || uri.Tag() || uri.Chunk() {
+			log.Debug("POST not allowed on immutable, list, hash, tag or chunk")
 			Respond(w, req, fmt.Sprintf("POST method on scheme %s not allowed", uri.Scheme), http.StatusMethodNotAllowed)
 		} else {
 			log.Debug("handlePostFiles")
@@ -1075,19 +1861,55 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 
 	case "DELETE":
-		if uri.Raw() {
+		if uri.Pin() {
+			s.HandlePin(ctx, w, req)
+			return
+		}
+		if uri.Raw() || uri.Prefetch() || uri.Tag() || uri.Chunk() {
 			Respond(w, req, fmt.Sprintf("DELETE method to %s not allowed", uri), http.StatusBadRequest)
 			return
 		}
 		s.HandleDelete(ctx, w, req)
 
+	case "MOVE":
+		if uri.Raw() || uri.Prefetch() || uri.Pin() || uri.Tag() || uri.Chunk() {
+			Respond(w, req, fmt.Sprintf("MOVE method to %s not allowed", uri), http.StatusBadRequest)
+			return
+		}
+		s.HandleMove(ctx, w, req)
+
 	case "GET":
 
+		if r.URL.Query().Get("missing") == "true" && !uri.Resource() {
+			s.HandleGetMissing(ctx, w, req)
+			return
+		}
+
 		if uri.Resource() {
 			s.HandleGetResource(ctx, w, req)
 			return
 		}
 
+		if uri.Prefetch() {
+			s.HandleGetPrefetch(ctx, w, req)
+			return
+		}
+
+		if uri.Pin() {
+			Respond(w, req, fmt.Sprintf("GET method on scheme %s not allowed", uri.Scheme), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if uri.Tag() {
+			s.HandleGetTag(ctx, w, req)
+			return
+		}
+
+		if uri.Chunk() {
+			s.HandleGetChunk(ctx, w, req)
+			return
+		}
+
 		if uri.Raw() || uri.Hash() {
 			s.HandleGet(ctx, w, req)
 			return
@@ -1133,13 +1955,29 @@ func (s *Server) updateManifest(ctx context.Context, addr storage.Address, updat
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64 // size of the response body
+	cacheHit   bool  // response served from a cache, see markCacheHit
 }
 
 func newLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytes += int64(n)
+	return n, err
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+// Push pushes the target to the client if the connection supports it
+func (lrw *loggingResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := lrw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -27,8 +28,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -40,10 +44,12 @@ import (
 	"github.com/ethereum/go-ethereum/swarm/testutil"
 )
 
-func init() {
-	loglevel := flag.Int("loglevel", 2, "loglevel")
+var loglevel = flag.Int("loglevel", 2, "loglevel")
+
+func TestMain(m *testing.M) {
 	flag.Parse()
 	log.Root().SetHandler(log.CallerFileHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(os.Stderr, log.TerminalFormat(true)))))
+	os.Exit(m.Run())
 }
 
 func TestResourcePostMode(t *testing.T) {
@@ -684,3 +690,870 @@ func TestMethodsNotAllowed(t *testing.T) {
 	}
 
 }
+
+func TestContentDisposition(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{`a"b.txt`, `attachment; filename="a_b.txt"; filename*=UTF-8''a%22b.txt`},
+		{"€ rates.txt", `attachment; filename="_ rates.txt"; filename*=UTF-8''%E2%82%AC%20rates.txt`},
+	} {
+		if got := contentDisposition("attachment", test.filename); got != test.expected {
+			t.Fatalf("expected %s, got %s", test.expected, got)
+		}
+	}
+}
+
+func TestBzzGetContentDisposition(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/bzz:/", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Content-Disposition", `attachment; filename="data.txt"`)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+
+	getDisposition := func(url string) string {
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %s", res.Status)
+		}
+		return res.Header.Get("Content-Disposition")
+	}
+
+	// the disposition is stored in the manifest
+	if d := getDisposition(srv.URL + "/bzz:/" + string(hash) + "/"); d != `attachment; filename="data.txt"` {
+		t.Fatalf("unexpected content disposition %q", d)
+	}
+	// and can be overridden with the filename query parameter
+	if d := getDisposition(srv.URL + "/bzz:/" + string(hash) + "/?filename=%C3%A9t%C3%A9.txt"); d != `attachment; filename="_t_.txt"; filename*=UTF-8''%C3%A9t%C3%A9.txt` {
+		t.Fatalf("unexpected content disposition %q", d)
+	}
+}
+
+func TestBzzGetFallback(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/bzz:/", "text/plain", strings.NewReader("mirrored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := make([]byte, 32)
+	if _, err := rand.Read(missing); err != nil {
+		t.Fatal(err)
+	}
+	missingHex := hexutil.Encode(missing)[2:]
+
+	// the path is served from the first retrievable root
+	res, err = http.Get(fmt.Sprintf("%s/bzz:/%s/?fallback=%s", srv.URL, missingHex, mirror))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+	if string(data) != "mirrored" {
+		t.Fatalf("expected %q, got %q", "mirrored", data)
+	}
+	if root := res.Header.Get("X-Swarm-Root"); root != string(mirror) {
+		t.Fatalf("expected root %s, got %s", mirror, root)
+	}
+
+	// the first root is used if it is retrievable
+	res, err = http.Get(fmt.Sprintf("%s/bzz:/%s/?fallback=%s", srv.URL, mirror, missingHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+
+	// not found if none of the roots is retrievable
+	res, err = http.Get(fmt.Sprintf("%s/bzz:/%s/?fallback=%s", srv.URL, missingHex, missingHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+}
+
+// TestBzzGetFallbackPartial tests that a root is fallen back from if the
+// content it serves is only partially retrievable
+func TestBzzGetFallbackPartial(t *testing.T) {
+	var a *api.API
+	srv := testutil.NewTestSwarmServer(t, func(x *api.API) testutil.TestServer {
+		a = x
+		return NewServer(x)
+	})
+	defer srv.Close()
+
+	// the root chunk of the content and its first child are stored, its
+	// last child is missing
+	children := storage.GenerateRandomChunks(storage.DefaultChunkSize, 2)
+	parent := storage.NewChunk(nil, nil)
+	parent.SData = make([]byte, 8, 8+2*storage.KeyLength)
+	binary.LittleEndian.PutUint64(parent.SData, uint64(2*storage.DefaultChunkSize))
+	for _, child := range children {
+		parent.SData = append(parent.SData, child.Addr...)
+	}
+	hasher := storage.MakeHashFunc(storage.DefaultHash)()
+	hasher.ResetWithLength(parent.SData[:8])
+	hasher.Write(parent.SData[8:])
+	parent.Addr = hasher.Sum(nil)
+	for _, chunk := range []*storage.Chunk{children[0], parent} {
+		srv.FileStore.ChunkStore.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.TODO()
+	empty, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw, err := a.NewManifestWriter(ctx, empty, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.AddEntryHash(&api.ManifestEntry{Hash: parent.Addr.Hex(), ContentType: "text/plain"}); err != nil {
+		t.Fatal(err)
+	}
+	partial, err := mw.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Post(srv.URL+"/bzz:/", "text/plain", strings.NewReader("mirrored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.Get(fmt.Sprintf("%s/bzz:/%s/?fallback=%s", srv.URL, partial.Hex(), mirror))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+	if root := res.Header.Get("X-Swarm-Root"); root != string(mirror) {
+		t.Fatalf("expected root %s, got %s", mirror, root)
+	}
+}
+
+// TestBzzUploadHash tests uploads with the chunk hash function selected by
+// the X-Swarm-Hash header
+func TestBzzUploadHash(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	data := []byte("hashed with blake2b")
+	client := swarm.NewClient(srv.URL)
+	defaultAddr, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Hash = storage.BLAKE2BHash
+	addr, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr == defaultAddr {
+		t.Fatal("expected a different address with BLAKE2b")
+	}
+	reader, _, err := client.DownloadRaw(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+
+	// new manifests record the hash function
+	hash, err := client.Upload(&swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "file.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+		},
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, _, err := client.DownloadManifest(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.HashFunc != storage.BLAKE2BHash {
+		t.Fatalf("expected manifest hash function %s, got %q", storage.BLAKE2BHash, manifest.HashFunc)
+	}
+	file, err := client.Download(hash, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+
+	client.Hash = "MD5"
+	if _, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)), false); err == nil {
+		t.Fatal("expected error uploading with an unsupported hash function")
+	}
+}
+
+// TestBzzPrefetch tests that a prefetch of a manifest retrieves the chunks of
+// the manifest and its entries and that its progress can be polled
+func TestBzzPrefetch(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	hash, err := client.UploadManifest(&api.Manifest{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"a.txt", "b.txt"} {
+		data := make([]byte, 3*4096+17)
+		rand.Read(data)
+		hash, err = client.Upload(&swarm.File{
+			ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+			ManifestEntry: api.ManifestEntry{
+				Path:        path,
+				ContentType: "text/plain",
+				Size:        int64(len(data)),
+			},
+		}, hash, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := client.PrefetchTag(hash); err == nil {
+		t.Fatal("expected error getting the tag of a content which was not prefetched")
+	}
+	tag, err := client.Prefetch(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Address != hash || tag.Uid == 0 {
+		t.Fatalf("expected a tag of %s, got %+v", hash, tag)
+	}
+	for i := 0; !tag.Done; i++ {
+		if i == 100 {
+			t.Fatalf("prefetch not done: %+v", tag)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if tag, err = client.PrefetchTag(hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the manifest chunk and 5 chunks of each file
+	if tag.Error != "" || tag.Failed != 0 || tag.Stored != 11 || tag.Total != 11 {
+		t.Fatalf("expected 11 chunks stored, got %+v", tag)
+	}
+
+	res, err := http.Post(srv.URL+"/bzz-prefetch:/"+hash, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %s", http.StatusAccepted, res.Status)
+	}
+	if uid := res.Header.Get("X-Swarm-Tag"); uid == "" || uid == strconv.FormatUint(uint64(tag.Uid), 10) {
+		t.Fatalf("expected the tag of a new prefetch, got %q", uid)
+	}
+}
+
+// TestBzzTag tests that uploads and downloads return the uid of their tag
+// and that the tag state can be polled
+func TestBzzTag(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	data := make([]byte, 5*4096)
+	rand.Read(data)
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := strconv.ParseUint(res.Header.Get("X-Swarm-Tag"), 10, 32)
+	if err != nil {
+		t.Fatalf("expected a tag uid, got %q", res.Header.Get("X-Swarm-Tag"))
+	}
+
+	client := swarm.NewClient(srv.URL)
+	// the root chunk and 5 data chunks
+	var tag *swarm.Tag
+	for i := 0; tag == nil || tag.Stored < 6; i++ {
+		if i == 100 {
+			t.Fatalf("upload not stored: %+v", tag)
+		}
+		if tag, err = client.Tag(uint32(uid)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tag.Name != "upload" || tag.Address != string(hash) || !tag.Done || tag.Total != 6 || tag.Failed != 0 {
+		t.Fatalf("unexpected upload tag %+v", tag)
+	}
+
+	res, err = http.Get(srv.URL + "/bzz-raw:/" + string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	uid, err = strconv.ParseUint(res.Header.Get("X-Swarm-Tag"), 10, 32)
+	if err != nil {
+		t.Fatalf("expected a tag uid, got %q", res.Header.Get("X-Swarm-Tag"))
+	}
+	if tag, err = client.Tag(uint32(uid)); err != nil {
+		t.Fatal(err)
+	}
+	if tag.Name != "download" || tag.Address != string(hash) || tag.Stored != 6 || tag.Total != 6 {
+		t.Fatalf("unexpected download tag %+v", tag)
+	}
+
+	if _, err := client.Tag(uint32(uid) + 100); err == nil {
+		t.Fatal("expected error getting an unknown tag")
+	}
+}
+
+// TestBzzUploadReceipt tests that uploads accepting application/json respond
+// with the receipt of the upload
+func TestBzzUploadReceipt(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	post := func(url, contentType string, data []byte) *api.UploadReceipt {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected HTTP status 200, got %s", res.Status)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("expected Content-Type application/json, got %q", ct)
+		}
+		receipt := &api.UploadReceipt{}
+		if err := json.NewDecoder(res.Body).Decode(receipt); err != nil {
+			t.Fatal(err)
+		}
+		if uid := res.Header.Get("X-Swarm-Tag"); uid != strconv.FormatUint(uint64(receipt.Tag), 10) {
+			t.Fatalf("expected tag %s, got %d", uid, receipt.Tag)
+		}
+		return receipt
+	}
+
+	data := make([]byte, 5*4096)
+	rand.Read(data)
+	receipt := post(srv.URL+"/bzz-raw:/", "application/octet-stream", data)
+	// the root chunk and 5 data chunks
+	if len(receipt.Address) != 64 || receipt.RootHash != receipt.Address || receipt.Encrypted || receipt.Manifest || receipt.Chunks != 6 || receipt.Bytes != int64(len(data)) {
+		t.Fatalf("unexpected raw upload receipt %+v", receipt)
+	}
+
+	receipt = post(srv.URL+"/bzz-raw:/encrypt", "application/octet-stream", data)
+	if len(receipt.Address) != 128 || receipt.RootHash != receipt.Address[:64] || !receipt.Encrypted || receipt.Chunks != 6 {
+		t.Fatalf("unexpected encrypted upload receipt %+v", receipt)
+	}
+
+	receipt = post(srv.URL+"/bzz:/", "text/plain", data[:100])
+	if len(receipt.Address) != 64 || !receipt.Manifest || receipt.Bytes != 100 || receipt.Chunks == 0 {
+		t.Fatalf("unexpected manifest upload receipt %+v", receipt)
+	}
+
+	// the address is still returned as text/plain by default
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/plain" || len(hash) != 64 {
+		t.Fatalf("expected a text/plain hash, got %q (%s)", hash, ct)
+	}
+}
+
+// TestRequestID tests that every response carries the ID of its request and
+// that error responses include it in their body
+func TestRequestID(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", srv.URL+"/bzz-raw:/nonhash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var params struct {
+			RequestID string
+		}
+		err = json.NewDecoder(res.Body).Decode(&params)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ruid := res.Header.Get("X-Swarm-Request-Id")
+		if ruid == "" {
+			t.Fatal("expected a request id")
+		}
+		if seen[ruid] {
+			t.Fatalf("expected a new request id, got %q again", ruid)
+		}
+		seen[ruid] = true
+		if params.RequestID != ruid {
+			t.Fatalf("expected request id %q in the error response, got %q", ruid, params.RequestID)
+		}
+	}
+}
+
+// TestBzzInlineUpload tests that files up to the inline threshold are stored
+// inline in their manifest entries and can be retrieved like stored files
+func TestBzzInlineUpload(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		a.SetInlineThreshold(1024)
+		return serverFunc(a)
+	})
+	defer srv.Close()
+
+	files := map[string][]byte{
+		"small.txt": []byte("stored inline in the manifest"),
+		"large.txt": bytes.Repeat([]byte("stored in a chunk tree"), 100),
+	}
+	client := swarm.NewClient(srv.URL)
+	var hash string
+	for _, path := range []string{"small.txt", "large.txt"} {
+		var err error
+		hash, err = client.Upload(&swarm.File{
+			ReadCloser: ioutil.NopCloser(bytes.NewReader(files[path])),
+			ManifestEntry: api.ManifestEntry{
+				Path:        path,
+				ContentType: "text/plain",
+				Size:        int64(len(files[path])),
+			},
+		}, hash, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, _, err := client.DownloadManifest(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest.Entries {
+		switch entry.Path {
+		case "small.txt":
+			if entry.Hash != "" || !bytes.Equal(entry.Data, files["small.txt"]) {
+				t.Fatalf("expected small.txt to be inline, got hash %q and data %q", entry.Hash, entry.Data)
+			}
+		case "large.txt":
+			if entry.Hash == "" || entry.Data != nil {
+				t.Fatalf("expected large.txt to be stored, got hash %q and data %q", entry.Hash, entry.Data)
+			}
+		}
+	}
+
+	for path, data := range files {
+		file, err := client.Download(hash, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: expected %q, got %q", path, data, got)
+		}
+	}
+
+	// raw entries and tar downloads handle both forms
+	res, err := http.Get(fmt.Sprintf("%s/bzz-raw:/%s/small.txt", srv.URL, hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || !bytes.Equal(got, files["small.txt"]) {
+		t.Fatalf("expected raw inline content, got %s: %q", res.Status, got)
+	}
+
+	dir, err := ioutil.TempDir("", "swarm-inline-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := client.DownloadDirectory(hash, "", dir); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: expected %q, got %q", path, data, got)
+		}
+	}
+}
+
+// TestBzzMoveDelete tests moving and removing files and directories with the
+// MOVE and DELETE methods
+func TestBzzMoveDelete(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	var hash string
+	for _, path := range []string{"a.txt", "dir/b.txt", "dir/c.txt"} {
+		var err error
+		hash, err = client.Upload(&swarm.File{
+			ReadCloser: ioutil.NopCloser(strings.NewReader(path)),
+			ManifestEntry: api.ManifestEntry{
+				Path:        path,
+				ContentType: "text/plain",
+				Size:        int64(len(path)),
+			},
+		}, hash, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkFiles := func(hash string, files map[string]string) {
+		list, err := client.List(hash, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		for _, prefix := range append([]string{""}, list.CommonPrefixes...) {
+			list, err := client.List(hash, prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range list.Entries {
+				count++
+				file, err := client.Download(hash, entry.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := ioutil.ReadAll(file)
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expect, ok := files[entry.Path]; !ok || string(data) != expect {
+					t.Fatalf("unexpected content of %s: %q", entry.Path, data)
+				}
+			}
+		}
+		if count != len(files) {
+			t.Fatalf("expected %d files, got %d", len(files), count)
+		}
+	}
+
+	moved, err := client.Move(hash, "dir", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(moved, map[string]string{"a.txt": "a.txt", "new/b.txt": "dir/b.txt", "new/c.txt": "dir/c.txt"})
+
+	moved, err = client.Move(moved, "a.txt", "new/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(moved, map[string]string{"new/a.txt": "a.txt", "new/b.txt": "dir/b.txt", "new/c.txt": "dir/c.txt"})
+
+	deleted, err := client.Delete(moved, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(deleted, map[string]string{})
+
+	do := func(method, path string, header map[string]string) int {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	for _, x := range []struct {
+		method string
+		path   string
+		header map[string]string
+		expect int
+	}{
+		{"DELETE", "/bzz:/" + hash + "/missing.txt", nil, http.StatusNotFound},
+		{"MOVE", "/bzz:/" + hash + "/missing.txt", map[string]string{"Destination": "/bzz:/" + hash + "/new.txt"}, http.StatusNotFound},
+		{"MOVE", "/bzz:/" + hash + "/a.txt", nil, http.StatusBadRequest},
+		{"MOVE", "/bzz:/" + hash + "/a.txt", map[string]string{"Destination": "/bzz:/" + moved + "/new.txt"}, http.StatusBadRequest},
+		{"MOVE", "/bzz:/" + hash + "/a.txt", map[string]string{"Destination": "/bzz:/" + hash + "/dir/b.txt", "Overwrite": "F"}, http.StatusPreconditionFailed},
+		{"MOVE", "/bzz:/" + hash + "/a.txt", map[string]string{"Destination": srv.URL + "/bzz:/" + hash + "/dir/b.txt"}, http.StatusOK},
+		{"MOVE", "/bzz-raw:/" + hash + "/a.txt", map[string]string{"Destination": "/bzz-raw:/" + hash + "/new.txt"}, http.StatusBadRequest},
+	} {
+		if code := do(x.method, x.path, x.header); code != x.expect {
+			t.Fatalf("%s %s %v: expected status %d, got %d", x.method, x.path, x.header, x.expect, code)
+		}
+	}
+}
+
+// TestBzzGetChunk tests that the data of single chunks is served on the
+// bzz-chunk endpoint
+func TestBzzGetChunk(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	data := make([]byte, 100)
+	rand.Read(data)
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.Get(srv.URL + "/bzz-chunk:/" + string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if len(chunk) != 8+len(data) || binary.LittleEndian.Uint64(chunk[:8]) != uint64(len(data)) || !bytes.Equal(chunk[8:], data) {
+		t.Fatalf("unexpected chunk data %x", chunk)
+	}
+
+	for path, expect := range map[string]int{
+		"/bzz-chunk:/nonhash":                     http.StatusBadRequest,
+		"/bzz-chunk:/" + strings.Repeat("00", 32): http.StatusNotFound,
+	} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != expect {
+			t.Fatalf("%s: expected status %d, got %d", path, expect, res.StatusCode)
+		}
+	}
+}
+
+// TestBzzPreload tests that the dependencies declared by a manifest entry
+// are announced with Link preload headers when the entry is served
+func TestBzzPreload(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	hash, err := client.UploadManifest(&api.Manifest{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []api.ManifestEntry{
+		{Path: "index.html", ContentType: "text/html", Preload: []string{"js/app.js", "style.css", "missing.js"}},
+		{Path: "js/app.js", ContentType: "application/javascript"},
+		{Path: "style.css", ContentType: "text/css; charset=utf-8"},
+	} {
+		data := make([]byte, 5000)
+		rand.Read(data)
+		entry.Size = int64(len(data))
+		hash, err = client.Upload(&swarm.File{
+			ReadCloser:    ioutil.NopCloser(bytes.NewReader(data)),
+			ManifestEntry: entry,
+		}, hash, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := http.Get(srv.URL + "/bzz:/" + hash + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+	expected := []string{
+		"</bzz:/" + hash + "/js/app.js>; rel=preload; as=script",
+		"</bzz:/" + hash + "/style.css>; rel=preload; as=style",
+	}
+	if links := res.Header["Link"]; strings.Join(links, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected links %q, got %q", expected, links)
+	}
+
+	res, err = http.Get(srv.URL + "/bzz:/" + hash + "/style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if links := res.Header["Link"]; len(links) != 0 {
+		t.Fatalf("expected no links, got %q", links)
+	}
+}
+
+// TestBzzAccessPassword tests that the content of a password access manifest
+// is only served to requests with the password
+func TestBzzAccessPassword(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, serverFunc)
+	defer srv.Close()
+
+	client := swarm.NewClient(srv.URL)
+	data := []byte("secret content")
+	hash, err := client.Upload(&swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "a.txt",
+			ContentType: "text/plain",
+			Size:        int64(len(data)),
+		},
+	}, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ae, key, err := api.NewAccessEntryPassword("secret", &api.KdfParams{N: 16, P: 1, R: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := api.NewAccessManifest(storage.Address(common.Hex2Bytes(hash)), key, ae)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := client.UploadManifest(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(password string) *http.Response {
+		req, err := http.NewRequest("GET", srv.URL+"/bzz:/"+access+"/a.txt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	for _, password := range []string{"", "wrong"} {
+		res := get(password)
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d with password %q, got %s", http.StatusUnauthorized, password, res.Status)
+		}
+		if res.Header.Get("WWW-Authenticate") == "" {
+			t.Fatal("expected a WWW-Authenticate header")
+		}
+	}
+	res := get("secret")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %s", http.StatusOK, res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, data) {
+		t.Fatalf("expected content %q, got %q", data, body)
+	}
+}
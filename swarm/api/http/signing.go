@@ -0,0 +1,210 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/api"
+)
+
+var (
+	signedRequestCount  = metrics.NewRegisteredCounter("api.http.signed.count", nil)
+	signedRequestRefuse = metrics.NewRegisteredCounter("api.http.signed.refuse", nil)
+)
+
+// SignatureMaxAge is the maximum difference between the timestamp of a
+// signed request and the time of the server, the signatures of the requests
+// are remembered for as long to refuse them if they are replayed
+var SignatureMaxAge = 5 * time.Minute
+
+// errContentHash is returned by the bodies of signed requests which do not
+// match their signed hash
+var errContentHash = errors.New("request body does not match its signed SHA-256 hash")
+
+// requestVerifier verifies the signatures of the write requests of the
+// server and refuses replayed requests
+type requestVerifier struct {
+	keys     *api.SigningKeys
+	required bool
+
+	mu       sync.Mutex
+	seen     map[[sha256.Size]byte]bool // hashes of the signed data of the requests verified within SignatureMaxAge
+	expiries expiryQueue                // the seen hashes by the time they expire
+}
+
+// SetSigningKeys verifies the signatures of the write requests signed with
+// the keys, and refuses the unsigned write requests if required is set
+func (s *Server) SetSigningKeys(keys *api.SigningKeys, required bool) {
+	s.verifier = &requestVerifier{
+		keys:     keys,
+		required: required,
+		seen:     make(map[[sha256.Size]byte]bool),
+	}
+}
+
+// verify returns the status and message the write request is refused with,
+// or zero if it is signed with a known key or unsigned signatures are not
+// required
+//
+// The body of a signed request is spooled to a temporary file and checked
+// against its signed hash before the request is handled, the file is removed
+// when the body is closed.
+func (v *requestVerifier) verify(r *Request) (int, string) {
+	sig := r.Header.Get(api.SwarmSignatureHeader)
+	if sig == "" {
+		if v.required {
+			signedRequestRefuse.Inc(1)
+			return http.StatusUnauthorized, fmt.Sprintf("%s requests have to be signed", r.Method)
+		}
+		return 0, ""
+	}
+	status, msg := v.check(r, sig)
+	if status != 0 {
+		signedRequestRefuse.Inc(1)
+		return status, msg
+	}
+	signedRequestCount.Inc(1)
+	return 0, ""
+}
+
+func (v *requestVerifier) check(r *Request, sig string) (int, string) {
+	id := r.Header.Get(api.SwarmKeyHeader)
+	key, ok := v.keys.Get(id)
+	if !ok {
+		return http.StatusUnauthorized, fmt.Sprintf("unknown signing key %q", id)
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(api.SwarmTimestampHeader), 10, 64)
+	if err != nil {
+		return http.StatusUnauthorized, "invalid signature timestamp"
+	}
+	now := time.Now()
+	signed := time.Unix(timestamp, 0)
+	if signed.Before(now.Add(-SignatureMaxAge)) || signed.After(now.Add(SignatureMaxAge)) {
+		return http.StatusUnauthorized, "signature timestamp is too far from the time of the server"
+	}
+	contentHash, err := hex.DecodeString(r.Header.Get(api.SwarmContentHashHeader))
+	if err != nil || len(contentHash) != sha256.Size {
+		return http.StatusUnauthorized, "invalid signed content hash"
+	}
+	data := api.SignedRequestData(r.Method, r.RequestURI, id, timestamp, contentHash)
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil || !key.Verify(data, sigBytes) {
+		return http.StatusUnauthorized, "invalid signature"
+	}
+	// the signed data rather than the signature identifies the request, as
+	// a request can be replayed with another encoding of its signature
+	if !v.remember(sha256.Sum256(data), signed.Add(SignatureMaxAge), now) {
+		return http.StatusUnauthorized, "replayed request"
+	}
+	body, err := spoolBody(r.Body, contentHash)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	r.Body = body
+	return 0, ""
+}
+
+// remember records the hash of the signed data of a request until it
+// expires, it returns false if the hash was already recorded
+func (v *requestVerifier) remember(hash [sha256.Size]byte, expires, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for len(v.expiries) > 0 && v.expiries[0].expires.Before(now) {
+		delete(v.seen, heap.Pop(&v.expiries).(seenRequest).hash)
+	}
+	if v.seen[hash] {
+		return false
+	}
+	v.seen[hash] = true
+	heap.Push(&v.expiries, seenRequest{hash, expires})
+	return true
+}
+
+// seenRequest is the hash of the signed data of a verified request and the
+// time it expires
+type seenRequest struct {
+	hash    [sha256.Size]byte
+	expires time.Time
+}
+
+// expiryQueue is a heap of the seen requests ordered by the time they expire,
+// the timestamps of the requests are not ordered as they are signed by
+// different clients
+type expiryQueue []seenRequest
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(seenRequest)) }
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	r := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return r
+}
+
+// spoolBody copies the body to a temporary file and returns it if it matches
+// its signed hash
+func spoolBody(body io.Reader, contentHash []byte) (io.ReadCloser, error) {
+	f, err := ioutil.TempFile("", "swarm-signed-request")
+	if err != nil {
+		return nil, err
+	}
+	spool := &spooledBody{f}
+	h := sha256.New()
+	if body != nil {
+		if _, err := io.Copy(io.MultiWriter(f, h), body); err != nil {
+			spool.Close()
+			return nil, fmt.Errorf("cannot read request body: %v", err)
+		}
+	}
+	if !bytes.Equal(h.Sum(nil), contentHash) {
+		spool.Close()
+		return nil, errContentHash
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+// spooledBody is the body of a signed request spooled to a temporary file,
+// which is removed when it is closed
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	b.File.Close()
+	return os.Remove(b.Name())
+}
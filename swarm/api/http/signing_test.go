@@ -0,0 +1,163 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestSignedWrites tests that the write requests have to be signed with a
+// known signing key, and that signed requests cannot be replayed or have
+// their body replaced
+func TestSignedWrites(t *testing.T) {
+	keys, err := api.NewSigningKeys(state.NewInmemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server := NewServer(a)
+		server.SetSigningKeys(keys, true)
+		return server
+	})
+	defer srv.Close()
+
+	hmacKey, err := keys.AddHMAC("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.AddECDSA("deploy", crypto.PubkeyToAddress(privKey.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "<h1>signed</h1>"
+	upload := func(client *swarm.Client) (string, error) {
+		return client.Upload(&swarm.File{
+			ReadCloser: ioutil.NopCloser(strings.NewReader(content)),
+			ManifestEntry: api.ManifestEntry{
+				Path:        "index.html",
+				ContentType: "text/html",
+				Size:        int64(len(content)),
+			},
+		}, "", false)
+	}
+
+	client := swarm.NewClient(srv.URL)
+	if _, err := upload(client); err == nil {
+		t.Fatal("expected the unsigned upload to be refused")
+	}
+	client.Signer = swarm.NewHMACSigner("ci", hmacKey.Secret)
+	hash, err := upload(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Signer = swarm.NewECDSASigner("deploy", privKey)
+	if _, err := client.UploadRaw(strings.NewReader(content), int64(len(content)), false); err != nil {
+		t.Fatal(err)
+	}
+	client.Signer = swarm.NewHMACSigner("ci", []byte("wrong secret"))
+	if _, err := upload(client); err == nil {
+		t.Fatal("expected the upload signed with a wrong secret to be refused")
+	}
+
+	// reads do not have to be signed
+	res, err := http.Get(srv.URL + "/bzz:/" + hash + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+
+	type signedRequest struct {
+		key       string
+		timestamp int64
+		body      string
+		signed    string
+	}
+	encodeSignature := hex.EncodeToString
+	send := func(r signedRequest) int {
+		contentHash := sha256.Sum256([]byte(r.signed))
+		data := api.SignedRequestData("POST", "/bzz-raw:/", r.key, r.timestamp, contentHash[:])
+		req, err := http.NewRequest("POST", srv.URL+"/bzz-raw:/", bytes.NewReader([]byte(r.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(api.SwarmKeyHeader, r.key)
+		req.Header.Set(api.SwarmTimestampHeader, strconv.FormatInt(r.timestamp, 10))
+		req.Header.Set(api.SwarmContentHashHeader, hex.EncodeToString(contentHash[:]))
+		req.Header.Set(api.SwarmSignatureHeader, encodeSignature(api.SignRequestHMAC(hmacKey.Secret, data)))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	now := time.Now().Unix()
+	for _, test := range []struct {
+		name    string
+		request signedRequest
+		status  int
+	}{
+		{"signed", signedRequest{"ci", now, "data", "data"}, http.StatusOK},
+		{"replayed", signedRequest{"ci", now, "data", "data"}, http.StatusUnauthorized},
+		{"replaced body", signedRequest{"ci", now + 1, "other data", "data"}, http.StatusBadRequest},
+		{"expired", signedRequest{"ci", now - int64(2*SignatureMaxAge/time.Second), "data", "data"}, http.StatusUnauthorized},
+		{"unknown key", signedRequest{"unknown", now, "data", "data"}, http.StatusUnauthorized},
+	} {
+		if status := send(test.request); status != test.status {
+			t.Fatalf("%s: expected status %d, got %d", test.name, test.status, status)
+		}
+	}
+
+	// a request is not taken for a new one when it is replayed with another
+	// encoding of its signature
+	encodeSignature = func(sig []byte) string {
+		return strings.ToUpper(hex.EncodeToString(sig))
+	}
+	if status := send(signedRequest{"ci", now, "data", "data"}); status != http.StatusUnauthorized {
+		t.Fatalf("expected the replayed request to be refused with status %d, got %d", http.StatusUnauthorized, status)
+	}
+	encodeSignature = hex.EncodeToString
+
+	// the requests signed with a removed key are refused
+	if err := keys.Remove("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if status := send(signedRequest{"ci", now + 2, "data", "data"}); status != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, status)
+	}
+}
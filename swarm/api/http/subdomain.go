@@ -0,0 +1,105 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var getSubdomainCount = metrics.NewRegisteredCounter("api.http.get.subdomain.count", nil)
+
+// labelEncoding encodes root hashes in host labels, which are limited to 63
+// characters, as 52 lowercase base32 characters
+var labelEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// SetGatewayDomains serves the roots at the subdomains of the domains, so
+// that each root has its own browser origin isolating its cookies and
+// storage from the other roots: http(s)://<root>.<domain>/<path> is served
+// as bzz:/<root>/<path>
+//
+// The root is the first label of the host, either a root hash encoded with
+// subdomainLabel or a name. The dots of a name can be encoded as hyphens,
+// and its hyphens doubled, so that every root is covered by a wildcard
+// certificate of the domain: mysite-eth.<domain> serves mysite.eth.
+func (s *Server) SetGatewayDomains(domains []string) {
+	s.domains = nil
+	for _, domain := range domains {
+		s.domains = append(s.domains, strings.ToLower(strings.Trim(domain, ".")))
+	}
+}
+
+// subdomainRoot returns the root served at the host of the request, false
+// if the host is not a subdomain of the gateway domains
+func (s *Server) subdomainRoot(r *http.Request) (string, bool) {
+	label, ok := subdomainOf(requestHost(r), s.domains)
+	if !ok {
+		return "", false
+	}
+	return labelRoot(label), true
+}
+
+// subdomainOf returns the labels of the host before the domain it is a
+// subdomain of, false if it is not a subdomain of any of the domains
+func subdomainOf(host string, domains []string) (string, bool) {
+	for _, domain := range domains {
+		if label := strings.TrimSuffix(host, "."+domain); label != host && label != "" {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// subdomainRequest returns the request for the root served at a subdomain,
+// the request URI is kept so that the links of responses stay relative to
+// the subdomain
+func subdomainRequest(r *http.Request, root string) *http.Request {
+	out := new(http.Request)
+	*out = *r
+	u := *r.URL
+	u.Path, u.RawPath = "/bzz:/"+root+r.URL.Path, ""
+	out.URL = &u
+	return out
+}
+
+// subdomainLabel returns the host label of the root hash or name
+func subdomainLabel(root string) string {
+	if hash, err := hex.DecodeString(root); err == nil && len(hash) == 32 {
+		return labelEncoding.EncodeToString(hash)
+	}
+	return strings.Replace(strings.Replace(root, "-", "--", -1), ".", "-", -1)
+}
+
+// labelRoot returns the root hash or name of the labels of a subdomain
+func labelRoot(label string) string {
+	if strings.Contains(label, ".") {
+		// the name is not encoded in a single label
+		return label
+	}
+	if hash, err := labelEncoding.DecodeString(label); err == nil && len(hash) == 32 {
+		return hex.EncodeToString(hash)
+	}
+	var name []string
+	for _, part := range strings.Split(label, "--") {
+		name = append(name, strings.Replace(part, "-", ".", -1))
+	}
+	return strings.Join(name, "-")
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	swarm "github.com/ethereum/go-ethereum/swarm/api/client"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+func TestSubdomainLabel(t *testing.T) {
+	for root, label := range map[string]string{
+		"8b634aea26eec353ac0ecbec20c94f44d6f8d11f38d4578a4c207a84c74ef731": "rnruv2rg53bvhlaozpwcbskpitlprui7hdkfpcsmeb5ijr2o64yq",
+		"mysite.eth":          "mysite-eth",
+		"my-site.eth":         "my--site-eth",
+		"docs.my--site.eth":   "docs-my----site-eth",
+		"theswarm":            "theswarm",
+		"app.theswarm.eth":    "app-theswarm-eth",
+		"a-b-c.d":             "a--b--c-d",
+		"trailing-.hyphen.io": "trailing---hyphen-io",
+	} {
+		if l := subdomainLabel(root); l != label {
+			t.Fatalf("%s: expected label %s, got %s", root, label, l)
+		}
+		if len(label) > 63 {
+			t.Fatalf("%s: label %s exceeds 63 characters", root, label)
+		}
+		if r := labelRoot(label); r != root {
+			t.Fatalf("%s: expected root %s, got %s", label, root, r)
+		}
+	}
+	if r := labelRoot("app.theswarm.eth"); r != "app.theswarm.eth" {
+		t.Fatalf("expected the labels of a name to be kept, got %s", r)
+	}
+}
+
+// TestSubdomainGateway tests that the roots are served at the subdomains of
+// the gateway domains
+func TestSubdomainGateway(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server := NewServer(a)
+		server.SetGatewayDomains([]string{"Gateway.Test."})
+		return server
+	})
+	defer srv.Close()
+
+	content := "<h1>site</h1>"
+	client := swarm.NewClient(srv.URL)
+	root, err := client.Upload(&swarm.File{
+		ReadCloser: ioutil.NopCloser(strings.NewReader(content)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "index.html",
+			ContentType: "text/html",
+			Size:        int64(len(content)),
+		},
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, host, path string) (*http.Response, string) {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = host
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body)
+	}
+
+	host := subdomainLabel(root) + ".gateway.test:8500"
+	res, body := request("GET", host, "/index.html")
+	if res.StatusCode != http.StatusOK || body != content {
+		t.Fatalf("expected the content of the root, got %d %q", res.StatusCode, body)
+	}
+	if res, _ := request("GET", host, "/missing.html"); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+	if res, _ := request("GET", host, "/bzz:/"+root+"/index.html"); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the paths of subdomains to be paths of the root, got status %d", res.StatusCode)
+	}
+	if res, _ := request("POST", host, "/index.html"); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, res.StatusCode)
+	}
+
+	// the domain itself and other hosts are served as before
+	for _, host := range []string{"gateway.test", "example.com"} {
+		res, body := request("GET", host, "/bzz:/"+root+"/index.html")
+		if res.StatusCode != http.StatusOK || body != content {
+			t.Fatalf("%s: expected the content of the root, got %d %q", host, res.StatusCode, body)
+		}
+	}
+}
+
+// TestWildcardTLSConfig tests that the subdomains of the gateway domains are
+// served with the wildcard certificate
+func TestWildcardTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-wildcard-tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	wildcardCert, wildcardKey := filepath.Join(dir, "wildcard.pem"), filepath.Join(dir, "wildcard-key.pem")
+	writeTestCert(t, certFile, keyFile, 1)
+	writeTestCert(t, wildcardCert, wildcardKey, 2)
+
+	conf, err := tlsConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wildcardTLSConfig(conf, []string{"gateway.test"}, wildcardCert, wildcardKey); err != nil {
+		t.Fatal(err)
+	}
+	for host, serial := range map[string]int64{
+		"gateway.test":            1,
+		"localhost":               1,
+		"mysite-eth.gateway.test": 2,
+		"Root.Gateway.Test":       2,
+	} {
+		cert, err := conf.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf.SerialNumber.Int64() != serial {
+			t.Fatalf("%s: expected certificate %d, got %d", host, serial, leaf.SerialNumber.Int64())
+		}
+	}
+}
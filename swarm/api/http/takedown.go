@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// TakedownPath is the path of the webhook gateway operators block and
+// unblock roots with
+const TakedownPath = "/takedown"
+
+// takedownRequest is the body of a request blocking a root
+type takedownRequest struct {
+	Root   string `json:"root"`   // root hash or name
+	Reason string `json:"reason"` // reason of the takedown, such as the reference of an abuse report
+	Source string `json:"source"` // who reported the root, "webhook" if empty
+}
+
+// SetBlocklist refuses the requests of the roots blocked in b and serves the
+// takedown webhook authenticated with the bearer token, the webhook is not
+// served if token is empty
+func (s *Server) SetBlocklist(b *api.Blocklist, token string) {
+	if s.policy == nil {
+		s.policy = NewGatewayPolicy(false, nil, nil)
+	}
+	s.policy.SetBlocklist(b)
+	s.blocklist, s.takedownToken = b, token
+}
+
+// HandleTakedown serves the takedown webhook:
+//
+//   - POST /takedown blocks the root of the JSON encoded takedownRequest
+//   - DELETE /takedown/<root> unblocks the root
+//   - GET /takedown exports the block list
+//   - PUT /takedown imports the exported block list of another operator
+func (s *Server) HandleTakedown(ctx context.Context, w http.ResponseWriter, r *Request) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(s.takedownToken)) != 1 {
+		Respond(w, r, "invalid takedown token", http.StatusUnauthorized)
+		return
+	}
+	root := strings.Trim(strings.TrimPrefix(r.URL.Path, TakedownPath), "/")
+	switch {
+	case r.Method == "POST" && root == "":
+		var req takedownRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Respond(w, r, fmt.Sprintf("invalid takedown request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Root == "" {
+			Respond(w, r, "missing root", http.StatusBadRequest)
+			return
+		}
+		if req.Source == "" {
+			req.Source = "webhook"
+		}
+		record, err := s.blocklist.Block(ctx, req.Root, req.Reason, req.Source)
+		if err != nil {
+			Respond(w, r, fmt.Sprintf("cannot block %s: %v", req.Root, err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(record)
+
+	case r.Method == "DELETE" && root != "":
+		err := s.blocklist.Unblock(ctx, root)
+		if err == api.ErrNotBlocked {
+			Respond(w, r, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			Respond(w, r, fmt.Sprintf("cannot unblock %s: %v", root, err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == "GET" && root == "":
+		w.Header().Set("Content-Type", "application/json")
+		if err := s.blocklist.Export(w); err != nil {
+			log.Error("cannot export block list", "ruid", r.ruid, "err", err)
+		}
+
+	case r.Method == "PUT" && root == "":
+		count, err := s.blocklist.Import(ctx, r.Body)
+		if err != nil {
+			Respond(w, r, fmt.Sprintf("cannot import block list: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"imported": count})
+
+	default:
+		Respond(w, r, fmt.Sprintf("%s method not allowed on %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+// TestTakedown tests that the roots blocked with the takedown webhook are
+// refused and can be unblocked
+func TestTakedown(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		blocklist, err := api.NewBlocklist(a, state.NewInmemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := NewServer(a)
+		server.SetBlocklist(blocklist, "secret")
+		return server
+	})
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", strings.NewReader("abusive content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := string(body)
+
+	request := func(method, path, token string, body io.Reader) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	expect := func(res *http.Response, status int) {
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: expected status %d, got %d", res.Request.Method, res.Request.URL.Path, status, res.StatusCode)
+		}
+	}
+
+	takedown := `{"root":"` + root + `","reason":"abuse report 1"}`
+	expect(request("POST", TakedownPath, "", strings.NewReader(takedown)), http.StatusUnauthorized)
+	expect(request("POST", TakedownPath, "other", strings.NewReader(takedown)), http.StatusUnauthorized)
+	expect(request("GET", "/bzz-raw:/"+root, "", nil), http.StatusOK)
+
+	expect(request("POST", TakedownPath, "secret", strings.NewReader(takedown)), http.StatusOK)
+	expect(request("GET", "/bzz-raw:/"+root, "", nil), http.StatusUnavailableForLegalReasons)
+
+	res = request("GET", TakedownPath, "secret", nil)
+	var list api.BlockListExport
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(list.Records) != 1 || list.Records[0].Root != root || list.Records[0].Source != "webhook" {
+		t.Fatalf("unexpected block list %+v", list.Records)
+	}
+
+	expect(request("DELETE", TakedownPath+"/"+root, "secret", nil), http.StatusOK)
+	expect(request("DELETE", TakedownPath+"/"+root, "secret", nil), http.StatusNotFound)
+	expect(request("GET", "/bzz-raw:/"+root, "", nil), http.StatusOK)
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// certCheckInterval is the minimum time between two checks of the
+// certificate files for changes
+var certCheckInterval = time.Minute
+
+// certReloader loads the TLS certificate of the server from its files and
+// reloads it when they change, so that a certificate renewed by an external
+// ACME client is used without restarting the node
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader loads the certificate from the given files
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load loads the certificate if its files changed since it was last loaded,
+// must be called with the lock held
+func (r *certReloader) load() error {
+	r.checkedAt = time.Now()
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil {
+		log.Info("reloaded TLS certificate", "cert", r.certFile)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// lastModified returns the latest modification time of the files
+func (r *certReloader) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// GetCertificate returns the certificate for the TLS handshakes of the
+// server, the current certificate is kept if the changed files cannot be
+// loaded
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) >= certCheckInterval {
+		if err := r.load(); err != nil {
+			log.Error("cannot reload TLS certificate", "cert", r.certFile, "err", err)
+		}
+	}
+	return r.cert, nil
+}
+
+// tlsConfig returns the TLS configuration of a server with the certificate
+// in the given files, HTTP/2 is negotiated with the clients supporting it
+func tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// wildcardTLSConfig serves the subdomains of the gateway domains with the
+// wildcard certificate in the given files, and the other hosts with the
+// certificate of conf
+func wildcardTLSConfig(conf *tls.Config, domains []string, certFile, keyFile string) error {
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	getCertificate := conf.GetCertificate
+	conf.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if _, ok := subdomainOf(strings.ToLower(hello.ServerName), domains); ok {
+			return r.GetCertificate(hello)
+		}
+		return getCertificate(hello)
+	}
+	return nil
+}
+
+// checkWildcardCert warns about the gateway domains whose subdomains are not
+// covered by the certificate they are served with, browsers refuse to
+// connect to them
+func checkWildcardCert(conf *tls.Config, domains []string) {
+	for _, domain := range domains {
+		host := "root." + domain
+		cert, err := conf.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+		if err != nil || cert == nil || len(cert.Certificate) == 0 {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			continue
+		}
+		if err := leaf.VerifyHostname(host); err != nil {
+			log.Warn("TLS certificate does not cover the subdomains of the gateway domain", "domain", domain, "err", err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+var (
+	transformCount     = metrics.NewRegisteredCounter("api.http.transform.count", nil)
+	transformFail      = metrics.NewRegisteredCounter("api.http.transform.fail", nil)
+	transformCacheHits = metrics.NewRegisteredCounter("api.http.transform.cache.hits", nil)
+)
+
+const (
+	// transformParam is the query parameter selecting the comma separated
+	// list of transforms applied to a file, e.g. ?transform=resize&width=100
+	transformParam = "transform"
+
+	transformCacheSize  = 64 << 20 // total size of the cached transform results
+	transformCacheMax   = 4 << 20  // results larger than this are not cached
+	transformOutputMax  = 64 << 20 // transforms producing more than this fail
+	transformCacheItems = 4096     // upper bound of the number of cached results
+)
+
+// Transform transforms the content of a file before it is served, for
+// example to resize images or render markdown. All query parameters of the
+// request are passed as params.
+type Transform interface {
+	Transform(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error)
+}
+
+// TransformFunc is an adapter to allow the use of ordinary functions as
+// transforms
+type TransformFunc func(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error)
+
+// Transform calls f(ctx, content, contentType, params)
+func (f TransformFunc) Transform(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error) {
+	return f(ctx, content, contentType, params)
+}
+
+// transforms holds the registered transforms and caches their results by a
+// key derived from the content hash, the transforms and their parameters.
+// The cache is bounded by the total size of the results rather than by their
+// number.
+type transforms struct {
+	mu       sync.RWMutex
+	registry map[string]Transform
+
+	cacheMu   sync.Mutex
+	cache     *lru.Cache
+	cached    int   // total size of the cached results
+	cacheSize int   // cached results are evicted above this total size
+	outputMax int64 // maximum size of a transform result
+}
+
+type transformResult struct {
+	data        []byte
+	contentType string
+}
+
+func newTransforms() *transforms {
+	t := &transforms{
+		registry:  make(map[string]Transform),
+		cacheSize: transformCacheSize,
+		outputMax: transformOutputMax,
+	}
+	// evictions only happen in add, which holds cacheMu
+	t.cache, _ = lru.NewWithEvict(transformCacheItems, func(key interface{}, value interface{}) {
+		t.cached -= len(value.(*transformResult).data)
+	})
+	return t
+}
+
+// add caches the transform result under the given key and evicts the least
+// recently used results until the cache fits in its size
+func (t *transforms) add(key string, result *transformResult) {
+	if len(result.data) > transformCacheMax {
+		return
+	}
+	t.cacheMu.Lock()
+	defer t.cacheMu.Unlock()
+	if t.cache.Contains(key) {
+		return
+	}
+	t.cache.Add(key, result)
+	t.cached += len(result.data)
+	for t.cached > t.cacheSize && t.cache.Len() > 0 {
+		t.cache.RemoveOldest()
+	}
+}
+
+// RegisterTransform registers a transform under the given name, it is applied
+// to files requested with ?transform=<name>
+func (s *Server) RegisterTransform(name string, t Transform) {
+	s.transforms.mu.Lock()
+	defer s.transforms.mu.Unlock()
+	s.transforms.registry[name] = t
+}
+
+// requestedTransforms returns the transforms selected by the query or nil if
+// none is requested
+func (s *Server) requestedTransforms(query url.Values) ([]Transform, error) {
+	param := query.Get(transformParam)
+	if param == "" {
+		return nil, nil
+	}
+	s.transforms.mu.RLock()
+	defer s.transforms.mu.RUnlock()
+	names := strings.Split(param, ",")
+	chain := make([]Transform, len(names))
+	for i, name := range names {
+		t, ok := s.transforms.registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		chain[i] = t
+	}
+	return chain, nil
+}
+
+// transformKey derives the cache key of a transform result from the content
+// hash and the query parameters, which include the transform names
+func transformKey(contentKey storage.Address, query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.Write(contentKey)
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			fmt.Fprintf(&buf, "\x00%s=%s", k, v)
+		}
+	}
+	return crypto.Keccak256Hash(buf.Bytes()).Hex()
+}
+
+// serveTransformed applies the transform chain to the content and serves the
+// result, using the cached result if there is one
+func (s *Server) serveTransformed(ctx context.Context, w http.ResponseWriter, r *Request, chain []Transform, contentKey storage.Address, content io.Reader, contentType string) {
+	transformCount.Inc(1)
+	query := r.URL.Query()
+	key := transformKey(contentKey, query)
+	w.Header().Set("ETag", fmt.Sprintf("%q", key[2:]))
+
+	var result *transformResult
+	if v, ok := s.transforms.cache.Get(key); ok {
+		transformCacheHits.Inc(1)
+		markCacheHit(w)
+		result = v.(*transformResult)
+	} else {
+		var err error
+		for _, t := range chain {
+			content, contentType, err = t.Transform(ctx, content, contentType, query)
+			if err != nil {
+				transformFail.Inc(1)
+				Respond(w, r, fmt.Sprintf("transform failed: %s", err), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(content, s.transforms.outputMax+1))
+		if err != nil {
+			transformFail.Inc(1)
+			Respond(w, r, fmt.Sprintf("transform failed: %s", err), http.StatusInternalServerError)
+			return
+		}
+		if int64(len(data)) > s.transforms.outputMax {
+			transformFail.Inc(1)
+			Respond(w, r, fmt.Sprintf("transform failed: result larger than %d bytes", s.transforms.outputMax), http.StatusUnprocessableEntity)
+			return
+		}
+		result = &transformResult{data: data, contentType: contentType}
+		s.transforms.add(key, result)
+		log.Debug("transformed content", "ruid", r.ruid, "key", contentKey, "transform", query.Get(transformParam), "size", len(data))
+	}
+
+	w.Header().Set("Content-Type", result.contentType)
+	// the validators are set by the caller
+	http.ServeContent(w, &r.Request, "", time.Time{}, bytes.NewReader(result.data))
+}
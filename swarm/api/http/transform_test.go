@@ -0,0 +1,160 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/testutil"
+)
+
+func TestTransforms(t *testing.T) {
+	var calls int
+	upper := TransformFunc(func(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error) {
+		calls++
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(bytes.ToUpper(data)), "text/plain", nil
+	})
+	repeat := TransformFunc(func(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error) {
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return nil, "", err
+		}
+		return strings.NewReader(strings.Repeat(string(data), len(params.Get("times")))), contentType, nil
+	})
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server := NewServer(a)
+		server.RegisterTransform("upper", upper)
+		server.RegisterTransform("repeat", repeat)
+		return server
+	})
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/bzz:/", "text/plain", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) (int, string) {
+		res, err := http.Get(srv.URL + "/bzz:/" + string(hash) + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(data)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code, data := get("transform=upper"); code != http.StatusOK || data != "FOO" {
+			t.Fatalf("unexpected response %d %q", code, data)
+		}
+	}
+	// the second request is served from the cache
+	if calls != 1 {
+		t.Fatalf("expected transform to be called once, got %d", calls)
+	}
+
+	// transforms are applied in order
+	if code, data := get("transform=upper,repeat&times=xx"); code != http.StatusOK || data != "FOOFOO" {
+		t.Fatalf("unexpected response %d %q", code, data)
+	}
+
+	if code, _ := get("transform=unknown"); code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for unknown transform, got %d", http.StatusBadRequest, code)
+	}
+}
+
+// TestTransformLimits tests that the transform results are limited in size
+// and that the cache is bounded by the total size of the cached results
+func TestTransformLimits(t *testing.T) {
+	var calls int
+	repeat := TransformFunc(func(ctx context.Context, content io.Reader, contentType string, params url.Values) (io.Reader, string, error) {
+		calls++
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return nil, "", err
+		}
+		return strings.NewReader(strings.Repeat(string(data), len(params.Get("times")))), contentType, nil
+	})
+	var server *Server
+	srv := testutil.NewTestSwarmServer(t, func(a *api.API) testutil.TestServer {
+		server = NewServer(a)
+		server.RegisterTransform("repeat", repeat)
+		return server
+	})
+	defer srv.Close()
+	server.transforms.outputMax = 8
+	server.transforms.cacheSize = 8
+
+	res, err := http.Post(srv.URL+"/bzz:/", "text/plain", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(times string) int {
+		res, err := http.Get(srv.URL + "/bzz:/" + string(hash) + "/?transform=repeat&times=" + times)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := get("xxx"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d for a result over the limit, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	// both results fit in the cache separately but not together, so the
+	// first one is evicted by the second
+	calls = 0
+	for _, times := range []string{"x", "xx", "xx", "x"} {
+		if code := get(times); code != http.StatusOK {
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected transform to be called 3 times, got %d", calls)
+	}
+	if server.transforms.cached > server.transforms.cacheSize {
+		t.Fatalf("expected at most %d cached bytes, got %d", server.transforms.cacheSize, server.transforms.cached)
+	}
+}
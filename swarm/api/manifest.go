@@ -19,11 +19,11 @@ package api
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -42,17 +42,53 @@ const (
 // Manifest represents a swarm manifest
 type Manifest struct {
 	Entries []ManifestEntry `json:"entries,omitempty"`
+	// HashFunc is the chunk hash function of the manifest and of the content
+	// added to it, the default hash function if empty
+	HashFunc string `json:"hashFunc,omitempty"`
+	// ErrorDocuments are the documents served instead of the errors of the
+	// requests of the paths of a root manifest, see SelectErrorDocument
+	ErrorDocuments []ErrorDocument `json:"errorDocuments,omitempty"`
+	// Fallback is the path of the entry served instead of the unresolved
+	// paths of a root manifest, such as the index.html of a single-page
+	// application routing its paths in the browser
+	Fallback string `json:"fallback,omitempty"`
+	// Sparse tells that the content added to the manifest is stored sparse,
+	// with its chunks of zeros represented by the zero reference, see
+	// storage.WithSparse
+	Sparse bool `json:"sparse,omitempty"`
 }
 
 // ManifestEntry represents an entry in a swarm manifest
 type ManifestEntry struct {
-	Hash        string    `json:"hash,omitempty"`
-	Path        string    `json:"path,omitempty"`
-	ContentType string    `json:"contentType,omitempty"`
-	Mode        int64     `json:"mode,omitempty"`
-	Size        int64     `json:"size,omitempty"`
-	ModTime     time.Time `json:"mod_time,omitempty"`
-	Status      int       `json:"status,omitempty"`
+	Hash               string    `json:"hash,omitempty"`
+	Path               string    `json:"path,omitempty"`
+	ContentType        string    `json:"contentType,omitempty"`
+	ContentDisposition string    `json:"contentDisposition,omitempty"`
+	Mode               int64     `json:"mode,omitempty"`
+	Size               int64     `json:"size,omitempty"`
+	ModTime            time.Time `json:"mod_time,omitempty"`
+	Status             int       `json:"status,omitempty"`
+	// Data is the content of small files stored inline in the entry
+	// instead of in a chunk tree referenced by Hash
+	Data []byte `json:"data,omitempty"`
+	// Entries are the entries of a submanifest embedded in the entry instead
+	// of stored separately and referenced by Hash
+	Entries []ManifestEntry `json:"entries,omitempty"`
+	// Preload are the paths of the entries of the manifest the content of
+	// the entry depends on, they are prefetched and pushed to the client
+	// when the entry is served
+	Preload []string `json:"preload,omitempty"`
+	// Access tells how the encrypted hash of the entry of an access
+	// manifest is decrypted, see NewAccessManifest
+	Access *AccessEntry `json:"access,omitempty"`
+	// LinkTarget is the target of the symbolic link the entry was uploaded
+	// from, the content of the entry is the target so that clients which do
+	// not restore links get it as a regular file
+	LinkTarget string `json:"linkTarget,omitempty"`
+	// Parts are the references of the contents the content of a composite
+	// entry is the concatenation of, instead of a content referenced by
+	// Hash, see NewCompositeManifest
+	Parts []string `json:"parts,omitempty"`
 }
 
 // ManifestList represents the result of listing files in a manifest
@@ -63,14 +99,24 @@ type ManifestList struct {
 
 // NewManifest creates and stores a new, empty manifest
 func (a *API) NewManifest(ctx context.Context, toEncrypt bool) (storage.Address, error) {
-	var manifest Manifest
-	data, err := json.Marshal(&manifest)
+	return a.NewManifestWithHash(ctx, toEncrypt, "")
+}
+
+// NewManifestWithHash creates and stores a new, empty manifest whose chunks
+// and content are addressed with the given hash function, the content is
+// stored sparse if the context selects it
+func (a *API) NewManifestWithHash(ctx context.Context, toEncrypt bool, hash string) (storage.Address, error) {
+	manifest := Manifest{HashFunc: hash, Sparse: storage.SparseFromContext(ctx)}
+	data, err := EncodeManifest(&manifest, a.manifestVersion)
+	if err != nil {
+		return nil, err
+	}
+	key, wait, err := a.StoreWithHash(ctx, bytes.NewReader(data), int64(len(data)), toEncrypt, hash)
 	if err != nil {
 		return nil, err
 	}
-	key, wait, err := a.Store(ctx, bytes.NewReader(data), int64(len(data)), toEncrypt)
 	wait(ctx)
-	return key, err
+	return key, nil
 }
 
 // Manifest hack for supporting Mutable Resource Updates from the bzz: scheme
@@ -82,7 +128,7 @@ func (a *API) NewResourceManifest(ctx context.Context, resourceAddr string) (sto
 		ContentType: ResourceContentType,
 	}
 	manifest.Entries = append(manifest.Entries, entry)
-	data, err := json.Marshal(&manifest)
+	data, err := EncodeManifest(&manifest, a.manifestVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -100,14 +146,15 @@ type ManifestWriter struct {
 func (a *API) NewManifestWriter(ctx context.Context, addr storage.Address, quitC chan bool) (*ManifestWriter, error) {
 	trie, err := loadManifest(ctx, a.fileStore, addr, quitC)
 	if err != nil {
-		return nil, fmt.Errorf("error loading manifest %s: %s", addr, err)
+		return nil, err
 	}
+	trie.fanout = a.manifestFanout
 	return &ManifestWriter{a, trie, quitC}, nil
 }
 
 // AddEntry stores the given data and adds the resulting key to the manifest
 func (m *ManifestWriter) AddEntry(ctx context.Context, data io.Reader, e *ManifestEntry) (storage.Address, error) {
-	key, _, err := m.api.Store(ctx, data, e.Size, m.trie.encrypted)
+	key, _, err := m.api.StoreWithHash(storage.WithSparse(ctx, m.trie.sparse), data, e.Size, m.trie.encrypted, m.trie.hashFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -117,12 +164,107 @@ func (m *ManifestWriter) AddEntry(ctx context.Context, data io.Reader, e *Manife
 	return key, nil
 }
 
+// AddEntryHash adds the given entry of content which is already stored,
+// referenced by the hash of the entry, to the manifest
+func (m *ManifestWriter) AddEntryHash(e *ManifestEntry) error {
+	m.trie.addEntry(newManifestTrieEntry(e, nil), m.quitC)
+	return nil
+}
+
+// AddEntryInline adds the given data to the manifest inline in the entry if
+// its size is known and within the inline threshold of the API, otherwise it
+// stores the data and adds the resulting key like AddEntry
+//
+// The returned key is nil if the data was inlined.
+func (m *ManifestWriter) AddEntryInline(ctx context.Context, data io.Reader, e *ManifestEntry) (storage.Address, error) {
+	if e.Size <= 0 || e.Size > m.api.inlineThreshold || e.ContentType == ManifestType {
+		return m.AddEntry(ctx, data, e)
+	}
+	content := make([]byte, e.Size)
+	if _, err := io.ReadFull(data, content); err != nil {
+		return nil, err
+	}
+	entry := newManifestTrieEntry(e, nil)
+	entry.Hash = ""
+	entry.Data = content
+	m.trie.addEntry(entry, m.quitC)
+	return nil, nil
+}
+
 // RemoveEntry removes the given path from the manifest
 func (m *ManifestWriter) RemoveEntry(path string) error {
 	m.trie.deleteEntry(path, m.quitC)
 	return nil
 }
 
+// RemoveEntries removes the entry at the given path from the manifest or,
+// if the path is a directory, all the entries under it
+func (m *ManifestWriter) RemoveEntries(path string) error {
+	entries, err := m.trie.entriesAt(path, m.quitC)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return ErrEntryNotFound
+	}
+	for suffix := range entries {
+		m.trie.deleteEntry(path+suffix, m.quitC)
+	}
+	return nil
+}
+
+// MoveEntry moves the entry at the given path to the new path or, if the
+// path is a directory, all the entries under it to the new directory
+//
+// ErrEntryExists is returned if overwrite is false and one of the new paths
+// already exists.
+func (m *ManifestWriter) MoveEntry(from, to string, overwrite bool) error {
+	if from == to {
+		return nil
+	}
+	if dir := strings.TrimSuffix(from, "/") + "/"; strings.HasPrefix(to, dir) {
+		return fmt.Errorf("cannot move %s into itself", from)
+	}
+	entries, err := m.trie.entriesAt(from, m.quitC)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return ErrEntryNotFound
+	}
+
+	suffixes := make([]string, 0, len(entries))
+	moved := make(map[string]string, len(entries))
+	for suffix := range entries {
+		suffixes = append(suffixes, suffix)
+		switch {
+		case suffix == "":
+			moved[suffix] = to
+		case strings.HasSuffix(from, "/"):
+			moved[suffix] = strings.TrimSuffix(to, "/") + "/" + suffix
+		default:
+			moved[suffix] = strings.TrimSuffix(to, "/") + suffix
+		}
+		if !overwrite {
+			existing, err := m.trie.entriesAt(moved[suffix], m.quitC)
+			if err != nil {
+				return err
+			}
+			if _, ok := existing[""]; ok {
+				return ErrEntryExists
+			}
+		}
+	}
+	sort.Strings(suffixes)
+	for _, suffix := range suffixes {
+		entry := entries[suffix]
+		entry.Path = moved[suffix]
+		m.trie.deleteEntry(from+suffix, m.quitC)
+		m.trie.addEntry(newManifestTrieEntry(&entry, nil), m.quitC)
+	}
+	return nil
+}
+
 // Store stores the manifest, returning the resulting storage key
 func (m *ManifestWriter) Store() (storage.Address, error) {
 	return m.trie.ref, m.trie.recalcAndStore()
@@ -139,7 +281,7 @@ type ManifestWalker struct {
 func (a *API) NewManifestWalker(ctx context.Context, addr storage.Address, quitC chan bool) (*ManifestWalker, error) {
 	trie, err := loadManifest(ctx, a.fileStore, addr, quitC)
 	if err != nil {
-		return nil, fmt.Errorf("error loading manifest %s: %s", addr, err)
+		return nil, err
 	}
 	return &ManifestWalker{a, trie, quitC}, nil
 }
@@ -148,6 +290,50 @@ func (a *API) NewManifestWalker(ctx context.Context, addr storage.Address, quitC
 // manifest should be skipped
 var ErrSkipManifest = errors.New("skip this manifest")
 
+var (
+	ErrEntryNotFound    = errors.New("manifest entry not found")
+	ErrEntryExists      = errors.New("manifest entry already exists")
+	ErrManifestNotFound = errors.New("manifest not found")
+	ErrInvalidManifest  = errors.New("invalid manifest")
+)
+
+// ManifestError is an error of the manifest with the given address, Err is
+// one of the errors above and Reason describes it further if not empty
+type ManifestError struct {
+	Addr   storage.Address
+	Path   string // path looked up in the manifest, if any
+	Err    error
+	Reason string
+}
+
+func (e *ManifestError) Error() string {
+	s := fmt.Sprintf("manifest %s", e.Addr)
+	if e.Path != "" {
+		s += fmt.Sprintf(" path %q", e.Path)
+	}
+	s += ": " + e.Err.Error()
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
+// Cause returns the error the manifest error is caused by
+func (e *ManifestError) Cause() error {
+	return e.Err
+}
+
+// HashFunc returns the chunk hash function of the walked manifest, the
+// default hash function if empty
+func (m *ManifestWalker) HashFunc() string {
+	return m.trie.hashFunc
+}
+
+// Sparse returns true if the content of the walked manifest is stored sparse
+func (m *ManifestWalker) Sparse() bool {
+	return m.trie.sparse
+}
+
 // WalkFn is the type of function called for each entry visited by a recursive
 // manifest walk
 type WalkFn func(entry *ManifestEntry) error
@@ -189,6 +375,13 @@ type manifestTrie struct {
 	entries   [257]*manifestTrieEntry // indexed by first character of basePath, entries[256] is the empty basePath entry
 	ref       storage.Address         // if ref != nil, it is stored
 	encrypted bool
+	hashFunc  string // chunk hash function, the default if empty
+	sparse    bool   // the content is stored sparse
+	version   int    // encoding version, see EncodeManifest
+	fanout    int    // maximum number of entries including embedded submanifests, see pack
+
+	errorDocs []ErrorDocument // error documents of a root manifest
+	fallback  string          // fallback path of a root manifest
 }
 
 func newManifestTrieEntry(entry *ManifestEntry, subtrie *manifestTrie) *manifestTrieEntry {
@@ -219,31 +412,30 @@ func readManifest(manifestReader storage.LazySectionReader, hash storage.Address
 	if err != nil { // size == 0
 		// can't determine size means we don't have the root chunk
 		log.Trace("manifest not found", "key", hash)
-		err = fmt.Errorf("Manifest not Found")
+		err = &ManifestError{Addr: hash, Err: ErrManifestNotFound, Reason: err.Error()}
 		return
 	}
 	if size > manifestSizeLimit {
 		log.Warn("manifest exceeds size limit", "key", hash, "size", size, "limit", manifestSizeLimit)
-		err = fmt.Errorf("Manifest size of %v bytes exceeds the %v byte limit", size, manifestSizeLimit)
+		err = &ManifestError{Addr: hash, Err: ErrInvalidManifest, Reason: fmt.Sprintf("size of %v bytes exceeds the %v byte limit", size, manifestSizeLimit)}
 		return
 	}
 	manifestData := make([]byte, size)
 	read, err := manifestReader.Read(manifestData)
 	if int64(read) < size {
 		log.Trace("manifest not found", "key", hash)
-		if err == nil {
-			err = fmt.Errorf("Manifest retrieval cut short: read %v, expect %v", read, size)
+		reason := fmt.Sprintf("retrieval cut short: read %v, expect %v", read, size)
+		if err != nil {
+			reason = err.Error()
 		}
+		err = &ManifestError{Addr: hash, Err: ErrManifestNotFound, Reason: reason}
 		return
 	}
 
 	log.Debug("manifest retrieved", "key", hash)
-	var man struct {
-		Entries []*manifestTrieEntry `json:"entries"`
-	}
-	err = json.Unmarshal(manifestData, &man)
+	man, version, err := DecodeManifest(manifestData)
 	if err != nil {
-		err = fmt.Errorf("Manifest %v is malformed: %v", hash.Log(), err)
+		err = &ManifestError{Addr: hash, Err: ErrInvalidManifest, Reason: err.Error()}
 		log.Trace("malformed manifest", "key", hash)
 		return
 	}
@@ -253,13 +445,36 @@ func readManifest(manifestReader storage.LazySectionReader, hash storage.Address
 	trie = &manifestTrie{
 		fileStore: fileStore,
 		encrypted: isEncrypted,
+		hashFunc:  man.HashFunc,
+		sparse:    man.Sparse,
+		version:   version,
+		errorDocs: man.ErrorDocuments,
+		fallback:  man.Fallback,
 	}
-	for _, entry := range man.Entries {
-		trie.addEntry(entry, quitC)
-	}
+	trie.addEntries(man.Entries, quitC)
 	return
 }
 
+// addEntries adds the entries of a stored manifest to the trie, the
+// embedded submanifests are added as loaded subtries
+func (mt *manifestTrie) addEntries(entries []ManifestEntry, quitC chan bool) {
+	for i := range entries {
+		entry := newManifestTrieEntry(&entries[i], nil)
+		if entry.ContentType == ManifestType && entry.Entries != nil {
+			entry.subtrie = &manifestTrie{
+				fileStore: mt.fileStore,
+				encrypted: mt.encrypted,
+				hashFunc:  mt.hashFunc,
+				sparse:    mt.sparse,
+				version:   mt.version,
+			}
+			entry.subtrie.addEntries(entry.Entries, quitC)
+			entry.Entries = nil
+		}
+		mt.addEntry(entry, quitC)
+	}
+}
+
 func (mt *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) {
 	mt.ref = nil // trie modified, hash needs to be re-calculated on demand
 
@@ -295,6 +510,9 @@ func (mt *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) {
 	subtrie := &manifestTrie{
 		fileStore: mt.fileStore,
 		encrypted: mt.encrypted,
+		hashFunc:  mt.hashFunc,
+		sparse:    mt.sparse,
+		version:   mt.version,
 	}
 	entry.Path = entry.Path[cpl:]
 	oldentry.Path = oldentry.Path[cpl:]
@@ -357,33 +575,77 @@ func (mt *manifestTrie) recalcAndStore() error {
 	if mt.ref != nil {
 		return nil
 	}
+	list, _, err := mt.pack(mt.fanout)
+	if err != nil {
+		return err
+	}
+	return mt.store(list)
+}
 
-	var buffer bytes.Buffer
-	buffer.WriteString(`{"entries":[`)
-
-	list := &Manifest{}
+// pack returns the manifest of the trie and its number of entries including
+// the entries of embedded submanifests
+//
+// Modified submanifests are embedded in the entries of the manifest as long
+// as the number of entries does not exceed the fanout, otherwise the largest
+// ones are stored separately and loaded on demand. Paths are resolved
+// without retrieving the submanifests a manifest embeds, while manifests stay
+// small regardless of the size of the directory. A fanout of 0 stores every
+// submanifest separately.
+func (mt *manifestTrie) pack(fanout int) (*Manifest, int, error) {
+	type packed struct {
+		index    int
+		entry    *manifestTrieEntry
+		manifest *Manifest
+		count    int
+	}
+	var subtries []*packed
+
+	list := &Manifest{HashFunc: mt.hashFunc, Sparse: mt.sparse, ErrorDocuments: mt.errorDocs, Fallback: mt.fallback}
+	count := 0
 	for _, entry := range mt.entries {
-		if entry != nil {
-			if entry.Hash == "" { // TODO: paralellize
-				err := entry.subtrie.recalcAndStore()
-				if err != nil {
-					return err
-				}
-				entry.Hash = entry.subtrie.ref.Hex()
+		if entry == nil {
+			continue
+		}
+		if entry.Hash == "" && entry.subtrie != nil { // TODO: paralellize
+			manifest, n, err := entry.subtrie.pack(fanout)
+			if err != nil {
+				return nil, 0, err
 			}
-			list.Entries = append(list.Entries, entry.ManifestEntry)
+			subtries = append(subtries, &packed{len(list.Entries), entry, manifest, n})
+			count += n
 		}
+		list.Entries = append(list.Entries, entry.ManifestEntry)
+		count++
+	}
 
+	sort.SliceStable(subtries, func(i, j int) bool {
+		return subtries[i].count > subtries[j].count
+	})
+	for _, sub := range subtries {
+		if count <= fanout && len(sub.manifest.Entries) > 0 {
+			list.Entries[sub.index].Entries = sub.manifest.Entries
+			continue
+		}
+		if err := sub.entry.subtrie.store(sub.manifest); err != nil {
+			return nil, 0, err
+		}
+		sub.entry.Hash = sub.entry.subtrie.ref.Hex()
+		list.Entries[sub.index].Hash = sub.entry.Hash
+		count -= sub.count
 	}
+	return list, count, nil
+}
 
-	manifest, err := json.Marshal(list)
+// store encodes and stores the packed manifest of the trie
+func (mt *manifestTrie) store(list *Manifest) error {
+	manifest, err := EncodeManifest(list, mt.version)
 	if err != nil {
 		return err
 	}
 
 	sr := bytes.NewReader(manifest)
 	ctx := context.TODO()
-	key, wait, err2 := mt.fileStore.Store(ctx, sr, int64(len(manifest)), mt.encrypted)
+	key, wait, err2 := mt.fileStore.StoreWithHash(ctx, sr, int64(len(manifest)), mt.encrypted, mt.hashFunc)
 	if err2 != nil {
 		return err2
 	}
@@ -450,6 +712,25 @@ func (mt *manifestTrie) listWithPrefix(prefix string, quitC chan bool, cb func(e
 	return mt.listWithPrefixInt(prefix, "", quitC, cb)
 }
 
+// entriesAt returns the entry at the given path or, if there is none and the
+// path is a directory, the entries under the directory, keyed by the rest of
+// their paths after the given path
+func (mt *manifestTrie) entriesAt(path string, quitC chan bool) (map[string]ManifestEntry, error) {
+	entries := make(map[string]ManifestEntry)
+	err := mt.listWithPrefix(path, quitC, func(entry *manifestTrieEntry, suffix string) {
+		if suffix == "" || strings.HasSuffix(path, "/") || strings.HasPrefix(suffix, "/") {
+			entries[suffix] = entry.ManifestEntry
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := entries[""]; ok {
+		return map[string]ManifestEntry{"": entry}, nil
+	}
+	return entries, nil
+}
+
 func (mt *manifestTrie) findPrefixOf(path string, quitC chan bool) (entry *manifestTrieEntry, pos int) {
 	log.Trace(fmt.Sprintf("findPrefixOf(%s)", path))
 
@@ -535,3 +816,34 @@ func (mt *manifestTrie) getEntry(spath string) (entry *manifestTrieEntry, fullpa
 	entry, pos = mt.findPrefixOf(path, quitC)
 	return entry, path[:pos]
 }
+
+// inlineReader is a LazySectionReader of content stored inline in a manifest
+// entry
+type inlineReader struct {
+	*io.SectionReader
+}
+
+func (r *inlineReader) Size(chan bool) (int64, error) {
+	return r.SectionReader.Size(), nil
+}
+
+// RetrieveEntry returns a reader of the content of the manifest entry, which
+// is either stored inline in the entry, the concatenation of its parts or
+// retrieved by its hash
+func (a *API) RetrieveEntry(ctx context.Context, entry *ManifestEntry) (reader storage.LazySectionReader, isEncrypted bool) {
+	if entry.Data != nil {
+		return &inlineReader{io.NewSectionReader(bytes.NewReader(entry.Data), 0, int64(len(entry.Data)))}, false
+	}
+	if len(entry.Parts) > 0 {
+		return a.retrieveComposite(ctx, entry.Parts)
+	}
+	return a.Retrieve(ctx, storage.Address(common.Hex2Bytes(entry.Hash)))
+}
+
+// InlineAddress returns the address the inline content of an entry would
+// have if it was stored with the given hash function, without storing it
+func InlineAddress(ctx context.Context, data []byte, hash string) (storage.Address, error) {
+	fileStore := storage.NewFileStore(storage.NewMapChunkStore(), storage.NewFileStoreParams())
+	addr, _, err := fileStore.StoreWithHash(ctx, bytes.NewReader(data), int64(len(data)), false, hash)
+	return addr, err
+}
@@ -18,13 +18,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
@@ -118,6 +121,79 @@ func TestDeleteEntry(t *testing.T) {
 
 }
 
+// TestMoveEntry tests moving and removing files and directories in a
+// manifest
+func TestMoveEntry(t *testing.T) {
+	paths := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dirx.txt"}
+	newWriter := func() *ManifestWriter {
+		var entries []ManifestEntry
+		for _, path := range paths {
+			entries = append(entries, ManifestEntry{Path: path, Hash: path})
+		}
+		manifest, _ := json.Marshal(&Manifest{Entries: entries})
+		reader := &storage.LazyTestSectionReader{
+			SectionReader: io.NewSectionReader(bytes.NewReader(manifest), 0, int64(len(manifest))),
+		}
+		fileStore := storage.NewFileStore(nil, storage.NewFileStoreParams())
+		trie, err := readManifest(reader, make([]byte, fileStore.HashSize()), fileStore, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &ManifestWriter{trie: trie}
+	}
+	// contents returns the hashes of the entries of the manifest by path
+	contents := func(mw *ManifestWriter) map[string]string {
+		res := make(map[string]string)
+		mw.trie.listWithPrefix("", nil, func(entry *manifestTrieEntry, suffix string) {
+			res[suffix] = entry.Hash
+		})
+		return res
+	}
+
+	for _, x := range []struct {
+		from, to  string
+		overwrite bool
+		err       error
+		expect    map[string]string
+	}{
+		{"a.txt", "b.txt", false, nil, map[string]string{"b.txt": "a.txt", "dir/b.txt": "dir/b.txt", "dir/sub/c.txt": "dir/sub/c.txt", "dirx.txt": "dirx.txt"}},
+		{"dir", "new", false, nil, map[string]string{"a.txt": "a.txt", "new/b.txt": "dir/b.txt", "new/sub/c.txt": "dir/sub/c.txt", "dirx.txt": "dirx.txt"}},
+		{"dir/", "new/", false, nil, map[string]string{"a.txt": "a.txt", "new/b.txt": "dir/b.txt", "new/sub/c.txt": "dir/sub/c.txt", "dirx.txt": "dirx.txt"}},
+		{"dir/sub", "sub", false, nil, map[string]string{"a.txt": "a.txt", "dir/b.txt": "dir/b.txt", "sub/c.txt": "dir/sub/c.txt", "dirx.txt": "dirx.txt"}},
+		{"a.txt", "dirx.txt", true, nil, map[string]string{"dir/b.txt": "dir/b.txt", "dir/sub/c.txt": "dir/sub/c.txt", "dirx.txt": "a.txt"}},
+		{"a.txt", "dirx.txt", false, ErrEntryExists, nil},
+		{"missing", "new", false, ErrEntryNotFound, nil},
+		{"di", "new", false, ErrEntryNotFound, nil},
+	} {
+		mw := newWriter()
+		err := mw.MoveEntry(x.from, x.to, x.overwrite)
+		if err != x.err {
+			t.Fatalf("move %s to %s: expected error %v, got %v", x.from, x.to, x.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got := contents(mw); !reflect.DeepEqual(got, x.expect) {
+			t.Fatalf("move %s to %s: expected %v, got %v", x.from, x.to, x.expect, got)
+		}
+	}
+
+	if err := newWriter().MoveEntry("dir", "dir/sub/dir", false); err == nil {
+		t.Fatal("expected error moving a directory into itself")
+	}
+
+	mw := newWriter()
+	if err := mw.RemoveEntries("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := contents(mw), map[string]string{"a.txt": "a.txt", "dirx.txt": "dirx.txt"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %v after removing dir, got %v", expect, got)
+	}
+	if err := mw.RemoveEntries("dir"); err != ErrEntryNotFound {
+		t.Fatalf("expected ErrEntryNotFound removing dir again, got %v", err)
+	}
+}
+
 // TestAddFileWithManifestPath tests that adding an entry at a path which
 // already exists as a manifest just adds the entry to the manifest rather
 // than replacing the manifest with the entry
@@ -152,8 +228,8 @@ func TestAddFileWithManifestPath(t *testing.T) {
 }
 
 // TestReadManifestOverSizeLimit creates a manifest reader with data longer then
-// manifestSizeLimit and checks if readManifest function will return the exact error
-// message.
+// manifestSizeLimit and checks if readManifest function will return an invalid
+// manifest error with the exact reason.
 // The manifest data is not in json-encoded format, preventing possbile
 // successful parsing attempts if limit check fails.
 func TestReadManifestOverSizeLimit(t *testing.T) {
@@ -165,11 +241,117 @@ func TestReadManifestOverSizeLimit(t *testing.T) {
 	if err == nil {
 		t.Fatal("got no error from readManifest")
 	}
+	merr, ok := err.(*ManifestError)
+	if !ok || merr.Err != ErrInvalidManifest {
+		t.Fatalf("expected invalid manifest error, got %v", err)
+	}
 	// Error message is part of the http response body
 	// which justifies exact string validation.
-	got := err.Error()
-	want := fmt.Sprintf("Manifest size of %v bytes exceeds the %v byte limit", len(manifest), manifestSizeLimit)
+	got := merr.Reason
+	want := fmt.Sprintf("size of %v bytes exceeds the %v byte limit", len(manifest), manifestSizeLimit)
 	if got != want {
-		t.Fatalf("got error mesage %q, expected %q", got, want)
+		t.Fatalf("got error reason %q, expected %q", got, want)
+	}
+}
+
+// TestManifestFanout tests that submanifests are embedded in their parent
+// manifests up to the fanout and that paths are resolved in manifests with
+// embedded and separately stored submanifests
+func TestManifestFanout(t *testing.T) {
+	var paths []string
+	for i := 0; i < 1000; i++ {
+		paths = append(paths, fmt.Sprintf("dir%d/file%03d.txt", i%3, i))
+	}
+
+	// storedManifests returns the number of separately stored manifests and
+	// the maximum number of entries in one of them
+	fileStore := storage.NewFileStore(storage.NewMapChunkStore(), storage.NewFileStoreParams())
+	var storedManifests func(addr storage.Address) (int, int)
+	storedManifests = func(addr storage.Address) (int, int) {
+		reader, _ := fileStore.Retrieve(context.TODO(), addr)
+		size, err := reader.Size(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, size)
+		if _, err := reader.ReadAt(data, 0); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		man, _, err := DecodeManifest(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, max := 1, 0
+		var walk func(entries []ManifestEntry)
+		walk = func(entries []ManifestEntry) {
+			for _, e := range entries {
+				max++
+				if e.Entries != nil {
+					walk(e.Entries)
+				} else if e.ContentType == ManifestType {
+					n, m := storedManifests(storage.Address(common.Hex2Bytes(e.Hash)))
+					count += n
+					if m > max {
+						max = m
+					}
+				}
+			}
+		}
+		walk(man.Entries)
+		return count, max
+	}
+
+	a := NewAPI(fileStore, nil, nil)
+	var counts []int
+	for _, fanout := range []int{0, 64, 10000} {
+		a.SetManifestFanout(fanout)
+		addr, err := a.NewManifest(context.TODO(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw, err := a.NewManifestWriter(context.TODO(), addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			if _, err := mw.AddEntry(context.TODO(), strings.NewReader(path), &ManifestEntry{Path: path, Size: int64(len(path))}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		addr, err = mw.Store()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, max := storedManifests(addr)
+		if fanout > 0 && max > fanout+256 {
+			t.Fatalf("fanout %d: expected at most %d entries in a manifest, got %d", fanout, fanout+256, max)
+		}
+		counts = append(counts, count)
+
+		// delete an entry to check that loaded manifests are modified
+		addr, err = a.Modify(context.TODO(), addr, paths[0], "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		trie, err := loadManifest(context.TODO(), fileStore, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, path := range paths {
+			entry, _ := trie.getEntry(path)
+			if i == 0 {
+				if entry != nil {
+					t.Fatalf("fanout %d: expected %s to be deleted", fanout, path)
+				}
+				continue
+			}
+			if entry == nil || entry.Path != path[len(path)-len(entry.Path):] {
+				t.Fatalf("fanout %d: expected entry for %s", fanout, path)
+			}
+		}
+	}
+	if !(counts[0] > counts[1] && counts[1] > counts[2] && counts[2] == 1) {
+		t.Fatalf("expected fewer stored manifests with higher fanouts, got %v", counts)
 	}
 }
@@ -0,0 +1,324 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Manifest encoding versions
+const (
+	// ManifestVersion1 is the JSON encoding of manifests
+	ManifestVersion1 = 1
+	// ManifestVersion2 is the binary encoding of manifests
+	ManifestVersion2 = 2
+
+	DefaultManifestVersion = ManifestVersion1
+)
+
+// manifestMagic prefixes binary encoded manifests and is followed by the
+// version byte, JSON manifests cannot start with a zero byte
+var manifestMagic = []byte{0x00, 'b', 'z', 'z'}
+
+// rlpManifest is the binary encoding of a manifest, the RLP list of the
+// entries and the hash function, followed by the error documents
+//
+// Fields added by later versions are appended, readers ignore the fields
+// they do not know about in the tails of the lists.
+type rlpManifest struct {
+	Entries  []rlpManifestEntry
+	HashFunc string
+	Rest     []rlp.RawValue `rlp:"tail"`
+}
+
+type rlpErrorDocument struct {
+	Status uint64
+	Lang   string
+	Path   string
+}
+
+type rlpManifestEntry struct {
+	Hash               []byte
+	Path               string
+	ContentType        string
+	ContentDisposition string
+	Mode               uint64
+	Size               uint64
+	ModTime            uint64 // unix time in nanoseconds, 0 if not set
+	Status             uint64
+	Data               []byte
+	Entries            []rlpManifestEntry
+	Rest               []rlp.RawValue `rlp:"tail"`
+}
+
+// EncodeManifest encodes the manifest with the given encoding version
+func EncodeManifest(m *Manifest, version int) ([]byte, error) {
+	switch version {
+	case 0, ManifestVersion1:
+		return json.Marshal(m)
+	case ManifestVersion2:
+	default:
+		return nil, fmt.Errorf("unsupported manifest version %d", version)
+	}
+
+	entries, err := encodeManifestEntries(m.Entries)
+	if err != nil {
+		return nil, err
+	}
+	rm := rlpManifest{
+		Entries:  entries,
+		HashFunc: m.HashFunc,
+	}
+	// the fields added after the first version are in the tail
+	if rm.Rest, err = encodeManifestTail(m); err != nil {
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(&rm)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(append([]byte{}, manifestMagic...), ManifestVersion2), data...), nil
+}
+
+// DecodeManifest decodes a manifest in any of the supported encodings and
+// returns it with the version of its encoding
+func DecodeManifest(data []byte) (*Manifest, int, error) {
+	if !bytes.HasPrefix(data, manifestMagic) {
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, 0, err
+		}
+		return &m, ManifestVersion1, nil
+	}
+	data = data[len(manifestMagic):]
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("missing manifest version")
+	}
+	if version := int(data[0]); version != ManifestVersion2 {
+		return nil, 0, fmt.Errorf("unsupported manifest version %d", version)
+	}
+
+	var rm rlpManifest
+	if err := rlp.DecodeBytes(data[1:], &rm); err != nil {
+		return nil, 0, err
+	}
+	m := &Manifest{
+		Entries:  decodeManifestEntries(rm.Entries),
+		HashFunc: rm.HashFunc,
+	}
+	if len(rm.Rest) > 0 {
+		var docs []rlpErrorDocument
+		if rlp.DecodeBytes(rm.Rest[0], &docs) != nil {
+			docs = nil
+		}
+		for _, doc := range docs {
+			m.ErrorDocuments = append(m.ErrorDocuments, ErrorDocument{int(doc.Status), doc.Lang, doc.Path})
+		}
+	}
+	if len(rm.Rest) > 1 {
+		rlp.DecodeBytes(rm.Rest[1], &m.Fallback)
+	}
+	if len(rm.Rest) > 2 {
+		rlp.DecodeBytes(rm.Rest[2], &m.Sparse)
+	}
+	return m, ManifestVersion2, nil
+}
+
+// encodeManifestTail encodes the fields of the manifest added after the
+// first version like encodeManifestEntryTail
+func encodeManifestTail(m *Manifest) ([]rlp.RawValue, error) {
+	docs := make([]rlpErrorDocument, len(m.ErrorDocuments))
+	for i, doc := range m.ErrorDocuments {
+		if doc.Status < 0 {
+			return nil, fmt.Errorf("error document %q: negative status", doc.Path)
+		}
+		docs[i] = rlpErrorDocument{uint64(doc.Status), doc.Lang, doc.Path}
+	}
+	fields := []interface{}{docs, m.Fallback, m.Sparse}
+	n := 0
+	for i, set := range []bool{len(docs) > 0, m.Fallback != "", m.Sparse} {
+		if set {
+			n = i + 1
+		}
+	}
+	var rest []rlp.RawValue
+	for _, field := range fields[:n] {
+		data, err := rlp.EncodeToBytes(field)
+		if err != nil {
+			return nil, err
+		}
+		rest = append(rest, data)
+	}
+	return rest, nil
+}
+
+func encodeManifestEntries(entries []ManifestEntry) ([]rlpManifestEntry, error) {
+	if entries == nil {
+		return nil, nil
+	}
+	res := make([]rlpManifestEntry, len(entries))
+	for i, e := range entries {
+		hash, err := hex.DecodeString(e.Hash)
+		if err != nil || hex.EncodeToString(hash) != e.Hash {
+			return nil, fmt.Errorf("manifest entry %q: hash %q is not lowercase hex", e.Path, e.Hash)
+		}
+		if e.Mode < 0 || e.Size < 0 || e.Status < 0 {
+			return nil, fmt.Errorf("manifest entry %q: negative mode, size or status", e.Path)
+		}
+		var modTime uint64
+		if !e.ModTime.IsZero() {
+			if e.ModTime.UnixNano() <= 0 {
+				return nil, fmt.Errorf("manifest entry %q: modification time %v before 1970", e.Path, e.ModTime)
+			}
+			modTime = uint64(e.ModTime.UnixNano())
+		}
+		sub, err := encodeManifestEntries(e.Entries)
+		if err != nil {
+			return nil, err
+		}
+		// the fields added after the first version are in the tail
+		rest, err := encodeManifestEntryTail(&e)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = rlpManifestEntry{
+			Hash:               hash,
+			Path:               e.Path,
+			ContentType:        e.ContentType,
+			ContentDisposition: e.ContentDisposition,
+			Mode:               uint64(e.Mode),
+			Size:               uint64(e.Size),
+			ModTime:            modTime,
+			Status:             uint64(e.Status),
+			Data:               e.Data,
+			Entries:            sub,
+			Rest:               rest,
+		}
+	}
+	return res, nil
+}
+
+// encodeManifestEntryTail encodes the fields of the entry added after the
+// first version, in the order they were added. The tail ends with the last
+// field which is set, the fields before it are encoded empty if not set.
+func encodeManifestEntryTail(e *ManifestEntry) ([]rlp.RawValue, error) {
+	var parts [][]byte
+	for _, part := range e.Parts {
+		hash, err := hex.DecodeString(part)
+		if err != nil || hex.EncodeToString(hash) != part {
+			return nil, fmt.Errorf("manifest entry %q: part %q is not lowercase hex", e.Path, part)
+		}
+		parts = append(parts, hash)
+	}
+	// the access entry is JSON encoded as it holds signed integers
+	var access []byte
+	if e.Access != nil {
+		var err error
+		if access, err = json.Marshal(e.Access); err != nil {
+			return nil, err
+		}
+	}
+	fields := []interface{}{e.Preload, access, e.LinkTarget, parts}
+	n := 0
+	for i, set := range []bool{len(e.Preload) > 0, e.Access != nil, e.LinkTarget != "", len(parts) > 0} {
+		if set {
+			n = i + 1
+		}
+	}
+	var rest []rlp.RawValue
+	for _, field := range fields[:n] {
+		data, err := rlp.EncodeToBytes(field)
+		if err != nil {
+			return nil, err
+		}
+		rest = append(rest, data)
+	}
+	return rest, nil
+}
+
+func decodeManifestEntries(entries []rlpManifestEntry) []ManifestEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	res := make([]ManifestEntry, len(entries))
+	for i, e := range entries {
+		var modTime time.Time
+		if e.ModTime > 0 {
+			modTime = time.Unix(0, int64(e.ModTime))
+		}
+		var hash string
+		if len(e.Hash) > 0 {
+			hash = hex.EncodeToString(e.Hash)
+		}
+		var inline []byte
+		if len(e.Data) > 0 {
+			inline = e.Data
+		}
+		var preload []string
+		if len(e.Rest) > 0 {
+			rlp.DecodeBytes(e.Rest[0], &preload)
+			if len(preload) == 0 {
+				preload = nil
+			}
+		}
+		var access *AccessEntry
+		if len(e.Rest) > 1 {
+			var data []byte
+			if rlp.DecodeBytes(e.Rest[1], &data) == nil && len(data) > 0 {
+				access = &AccessEntry{}
+				if json.Unmarshal(data, access) != nil {
+					access = nil
+				}
+			}
+		}
+		var linkTarget string
+		if len(e.Rest) > 2 {
+			rlp.DecodeBytes(e.Rest[2], &linkTarget)
+		}
+		var parts []string
+		if len(e.Rest) > 3 {
+			var hashes [][]byte
+			rlp.DecodeBytes(e.Rest[3], &hashes)
+			for _, hash := range hashes {
+				parts = append(parts, hex.EncodeToString(hash))
+			}
+		}
+		res[i] = ManifestEntry{
+			Hash:               hash,
+			Path:               e.Path,
+			ContentType:        e.ContentType,
+			ContentDisposition: e.ContentDisposition,
+			Mode:               int64(e.Mode),
+			Size:               int64(e.Size),
+			ModTime:            modTime,
+			Status:             int(e.Status),
+			Data:               inline,
+			Entries:            decodeManifestEntries(e.Entries),
+			Preload:            preload,
+			Access:             access,
+			LinkTarget:         linkTarget,
+			Parts:              parts,
+		}
+	}
+	return res
+}
@@ -0,0 +1,295 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+func testManifest() *Manifest {
+	return &Manifest{
+		HashFunc: storage.SHA3Hash,
+		ErrorDocuments: []ErrorDocument{
+			{Status: 404, Path: "404.html"},
+			{Status: 404, Lang: "de", Path: "de/404.html"},
+			{Path: "error.html"},
+		},
+		Fallback: "index.html",
+		Entries: []ManifestEntry{
+			{
+				Hash:        "8b634aea26eec353ac0ecbec20c94f44d6f8d11f38d4578a4c207a84c74ef731",
+				Path:        "index.html",
+				ContentType: "text/html",
+				Mode:        0644,
+				Size:        1234,
+				ModTime:     time.Unix(1530000000, 123),
+				Preload:     []string{"style.css", "dir/app.js"},
+			},
+			{
+				Path:               "small.txt",
+				ContentType:        "text/plain",
+				ContentDisposition: "attachment",
+				Size:               5,
+				Data:               []byte("small"),
+			},
+			{
+				Hash:   "3ca8b9d7ea6c8b1e36cd98fd5b82d6cfc74bd5e1ffdf71c0a0e3e9ab4b1ea64f",
+				Path:   "dir/",
+				Status: 300,
+			},
+			{
+				Path:       "latest.html",
+				Mode:       0777,
+				Size:       10,
+				Data:       []byte("index.html"),
+				LinkTarget: "index.html",
+			},
+			{
+				Path:        "log.txt",
+				ContentType: "text/plain",
+				Size:        8192,
+				Parts: []string{
+					"8b634aea26eec353ac0ecbec20c94f44d6f8d11f38d4578a4c207a84c74ef731",
+					"3ca8b9d7ea6c8b1e36cd98fd5b82d6cfc74bd5e1ffdf71c0a0e3e9ab4b1ea64f",
+				},
+			},
+			{
+				Path:        "embedded/",
+				ContentType: ManifestType,
+				Entries: []ManifestEntry{
+					{Path: "a.txt", Size: 1, Data: []byte("a")},
+					{Path: "b.txt", Size: 1, Data: []byte("b")},
+				},
+			},
+		},
+	}
+}
+
+func TestManifestEncoding(t *testing.T) {
+	m := testManifest()
+	for _, version := range []int{ManifestVersion1, ManifestVersion2} {
+		data, err := EncodeManifest(m, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, decodedVersion, err := DecodeManifest(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedVersion != version {
+			t.Fatalf("expected version %d, got %d", version, decodedVersion)
+		}
+		if decoded.HashFunc != m.HashFunc || len(decoded.Entries) != len(m.Entries) {
+			t.Fatalf("version %d: manifest does not match: %+v", version, decoded)
+		}
+		if decoded.Fallback != m.Fallback {
+			t.Fatalf("version %d: expected fallback %q, got %q", version, m.Fallback, decoded.Fallback)
+		}
+		if !reflect.DeepEqual(decoded.ErrorDocuments, m.ErrorDocuments) {
+			t.Fatalf("version %d: expected error documents %+v, got %+v", version, m.ErrorDocuments, decoded.ErrorDocuments)
+		}
+		for i, e := range decoded.Entries {
+			if !e.ModTime.Equal(m.Entries[i].ModTime) {
+				t.Fatalf("version %d: expected mod time %v, got %v", version, m.Entries[i].ModTime, e.ModTime)
+			}
+			e.ModTime = m.Entries[i].ModTime
+			if !reflect.DeepEqual(e, m.Entries[i]) {
+				t.Fatalf("version %d: expected entry %+v, got %+v", version, m.Entries[i], e)
+			}
+		}
+	}
+
+	if _, _, err := DecodeManifest(append(manifestMagic, 3)); err == nil {
+		t.Fatal("expected error decoding unknown manifest version")
+	}
+	if _, err := EncodeManifest(&Manifest{Entries: []ManifestEntry{{Hash: "0x1234"}}}, ManifestVersion2); err == nil {
+		t.Fatal("expected error encoding a hash which is not hex")
+	}
+}
+
+// TestManifestEncodingSize checks that binary manifests of large
+// directories are smaller than JSON manifests
+func TestManifestEncodingSize(t *testing.T) {
+	m := &Manifest{}
+	for i := 0; i < 1000; i++ {
+		m.Entries = append(m.Entries, ManifestEntry{
+			Hash:        "8b634aea26eec353ac0ecbec20c94f44d6f8d11f38d4578a4c207a84c74ef731",
+			Path:        fmt.Sprintf("file%d.txt", i),
+			ContentType: "text/plain",
+			Mode:        0644,
+			Size:        int64(i),
+			ModTime:     time.Unix(1530000000, 0),
+		})
+	}
+	v1, err := EncodeManifest(m, ManifestVersion1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := EncodeManifest(m, ManifestVersion2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 2*len(v2) > len(v1) {
+		t.Fatalf("expected binary manifest to be less than half the JSON size, got %d and %d bytes", len(v2), len(v1))
+	}
+}
+
+// TestManifestForwardCompatibility checks that fields added to binary
+// manifests by later versions are ignored
+func TestManifestForwardCompatibility(t *testing.T) {
+	type futureEntry struct {
+		Hash               []byte
+		Path               string
+		ContentType        string
+		ContentDisposition string
+		Mode               uint64
+		Size               uint64
+		ModTime            uint64
+		Status             uint64
+		Data               []byte
+		Entries            []futureEntry
+		Preload            []string
+		Checksum           []byte
+	}
+	type futureManifest struct {
+		Entries  []futureEntry
+		HashFunc string
+		Index    []string
+	}
+	data, err := rlp.EncodeToBytes(&futureManifest{
+		Entries: []futureEntry{{
+			Path:     "future.txt",
+			Size:     42,
+			Preload:  []string{"index.html"},
+			Checksum: []byte{1, 2, 3},
+		}},
+		Index: []string{"future.txt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := DecodeManifest(append(append(manifestMagic, ManifestVersion2), data...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 1 || m.Entries[0].Path != "future.txt" || m.Entries[0].Size != 42 || len(m.Entries[0].Preload) != 1 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+}
+
+// TestManifestVersion tests that manifests are written in the configured
+// version, that their subtries and modifications keep it and that both
+// versions are read
+func TestManifestVersion(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "bzz-manifest-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, err := storage.NewLocalFileStore(datadir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(fileStore, nil, nil)
+	if err := a.SetManifestVersion(3); err == nil {
+		t.Fatal("expected error setting unknown manifest version")
+	}
+
+	ctx := context.TODO()
+	for _, version := range []int{ManifestVersion1, ManifestVersion2} {
+		if err := a.SetManifestVersion(version); err != nil {
+			t.Fatal(err)
+		}
+		addr, err := a.NewManifest(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw, err := a.NewManifestWriter(ctx, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// common prefixes create a submanifest
+		for _, path := range []string{"dir/a.txt", "dir/b.txt"} {
+			if _, err := mw.AddEntry(ctx, bytes.NewReader([]byte(path)), &ManifestEntry{Path: path, Size: int64(len(path))}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		addr, err = mw.Store()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// new manifests do not change the version of existing ones
+		a.SetManifestVersion(ManifestVersion1)
+		mw, err = a.NewManifestWriter(ctx, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mw.AddEntry(ctx, bytes.NewReader([]byte("c")), &ManifestEntry{Path: "dir/c.txt", Size: 1}); err != nil {
+			t.Fatal(err)
+		}
+		addr, err = mw.Store()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		walker, err := a.NewManifestWalker(ctx, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifests, files int
+		err = walker.Walk(func(entry *ManifestEntry) error {
+			if entry.ContentType != ManifestType {
+				files++
+				return nil
+			}
+			manifests++
+			trie, err := loadManifest(ctx, fileStore, storage.Address(common.Hex2Bytes(entry.Hash)), nil)
+			if err != nil {
+				return err
+			}
+			if trie.version != version {
+				return fmt.Errorf("expected submanifest version %d, got %d", version, trie.version)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if manifests == 0 || files != 3 {
+			t.Fatalf("expected 3 files in submanifests, got %d files in %d submanifests", files, manifests)
+		}
+		trie, err := loadManifest(ctx, fileStore, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trie.version != version {
+			t.Fatalf("expected manifest version %d, got %d", version, trie.version)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"path"
+	"strings"
+)
+
+// content types of HLS and DASH playlists
+const (
+	HLSPlaylistType  = "application/vnd.apple.mpegurl"
+	DASHManifestType = "application/dash+xml"
+)
+
+// mediaTypes maps the extensions of adaptive streaming files to their content
+// types, which are missing from the mime databases of most systems
+var mediaTypes = map[string]string{
+	".m3u8": HLSPlaylistType,
+	".m3u":  HLSPlaylistType,
+	".mpd":  DASHManifestType,
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".vtt":  "text/vtt",
+}
+
+// MediaContentType returns the content type of an adaptive streaming file
+// based on its extension, or an empty string if it is not a known one
+func MediaContentType(file string) string {
+	return mediaTypes[strings.ToLower(path.Ext(file))]
+}
+
+// IsPlaylistType returns whether the content type is that of a HLS playlist
+// or a DASH manifest
+func IsPlaylistType(contentType string) bool {
+	switch contentType {
+	case HLSPlaylistType, DASHManifestType, "application/x-mpegurl", "audio/x-mpegurl", "audio/mpegurl":
+		return true
+	}
+	return false
+}
+
+// IsMediaType returns whether the content type is that of a playlist or a
+// segment of adaptive streaming content
+func IsMediaType(contentType string) bool {
+	if IsPlaylistType(contentType) {
+		return true
+	}
+	for _, typ := range mediaTypes {
+		if contentType == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultContentType is the content type of files whose type is not detected
+const DefaultContentType = "application/octet-stream"
+
+// sniffLen is the number of bytes content types are sniffed from
+const sniffLen = 512
+
+// webTypes are the content types of the extensions of web assets, which
+// browsers refuse to load with other types and the mime databases of some
+// systems get wrong or miss
+var webTypes = map[string]string{
+	".css":   "text/css",
+	".js":    "application/javascript",
+	".mjs":   "application/javascript",
+	".json":  "application/json",
+	".wasm":  "application/wasm",
+	".svg":   "image/svg+xml",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// MimeDetector detects the content types of files, in order of precedence:
+//
+//   - by the extension of the name in Types
+//   - by the extension of the name in the built-in types of web assets and
+//     adaptive streaming files, and in the mime database of the system
+//   - by sniffing the magic bytes of the content if Sniff is set
+//   - Default if none of the above applies
+//
+// The same detector is applied to the files uploaded to manifests by the
+// node and to the content served from manifest entries without a type.
+type MimeDetector struct {
+	Types   map[string]string // extension, with the dot, to content type overrides
+	Sniff   bool              // detect the types of unknown extensions from the content
+	Default string            // type of the content whose type is not detected
+}
+
+// NewMimeDetector returns a detector sniffing unknown types without
+// overrides
+func NewMimeDetector() *MimeDetector {
+	return &MimeDetector{
+		Sniff:   true,
+		Default: DefaultContentType,
+	}
+}
+
+// ParseMimeTypes parses extension to content type overrides given as
+// ext=type, the dot of the extension is optional
+func ParseMimeTypes(overrides []string) (map[string]string, error) {
+	types := make(map[string]string, len(overrides))
+	for _, override := range overrides {
+		i := strings.Index(override, "=")
+		if i < 1 || i == len(override)-1 {
+			return nil, fmt.Errorf("invalid content type override %q, expected ext=type", override)
+		}
+		ext := strings.ToLower(strings.TrimSpace(override[:i]))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		typ := strings.TrimSpace(override[i+1:])
+		if _, _, err := mime.ParseMediaType(typ); err != nil {
+			return nil, fmt.Errorf("invalid content type override %q: %v", override, err)
+		}
+		types[ext] = typ
+	}
+	return types, nil
+}
+
+// ByName returns the content type of the file with the given name detected
+// by its extension, or an empty string if the extension is not known
+func (d *MimeDetector) ByName(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if typ, ok := d.Types[ext]; ok {
+		return typ
+	}
+	if typ, ok := webTypes[ext]; ok {
+		return typ
+	}
+	if typ, ok := mediaTypes[ext]; ok {
+		return typ
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// Detect returns the content type of the file with the given name and
+// content starting with head
+func (d *MimeDetector) Detect(name string, head []byte) string {
+	if typ := d.ByName(name); typ != "" {
+		return typ
+	}
+	if d.Sniff && len(head) > 0 {
+		if len(head) > sniffLen {
+			head = head[:sniffLen]
+		}
+		// content which is not recognised is sniffed as the generic type
+		if typ := http.DetectContentType(head); typ != DefaultContentType {
+			return typ
+		}
+	}
+	return d.Default
+}
+
+// DetectReaderAt returns the content type of the file with the given name and
+// content read from r
+func (d *MimeDetector) DetectReaderAt(name string, r io.ReaderAt) string {
+	if typ := d.ByName(name); typ != "" || !d.Sniff {
+		return d.Detect(name, nil)
+	}
+	head := make([]byte, sniffLen)
+	n, _ := r.ReadAt(head, 0)
+	return d.Detect(name, head[:n])
+}
+
+// SetMimeDetector sets the detector of the content types of uploaded and
+// served files
+func (a *API) SetMimeDetector(d *MimeDetector) {
+	a.mime = d
+}
+
+// MimeDetector returns the detector of the content types of uploaded and
+// served files
+func (a *API) MimeDetector() *MimeDetector {
+	return a.mime
+}
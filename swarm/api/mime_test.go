@@ -0,0 +1,110 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestMimeDetector tests the precedence of the rules of the detector
+func TestMimeDetector(t *testing.T) {
+	types, err := ParseMimeTypes([]string{"md=text/markdown; charset=utf-8", ".JS=text/javascript"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, override := range []string{"md", "=text/plain", "md=", "md=text/"} {
+		if _, err := ParseMimeTypes([]string{override}); err == nil {
+			t.Fatalf("expected an error parsing %q", override)
+		}
+	}
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	d := &MimeDetector{Types: types, Sniff: true, Default: "application/x-unknown"}
+	for _, test := range []struct {
+		name string
+		head []byte
+		typ  string
+	}{
+		{"README.md", nil, "text/markdown; charset=utf-8"},
+		{"app.js", nil, "text/javascript"},
+		{"style.css", nil, "text/css"},
+		{"index.m3u8", nil, HLSPlaylistType},
+		{"logo", png, "image/png"},
+		{"data.unknown-ext", png, "image/png"},
+		{"data", []byte{0, 1, 2, 3}, "application/x-unknown"},
+		{"data", nil, "application/x-unknown"},
+	} {
+		if typ := d.Detect(test.name, test.head); typ != test.typ {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.typ, typ)
+		}
+	}
+
+	d.Sniff = false
+	if typ := d.Detect("logo", png); typ != "application/x-unknown" {
+		t.Fatalf("expected the default type without sniffing, got %q", typ)
+	}
+}
+
+// TestGetDetectsContentType tests that the type of the content of entries
+// without a content type is detected when it is served
+func TestGetDetectsContentType(t *testing.T) {
+	testAPI(t, func(a *API, toEncrypt bool) {
+		ctx := context.Background()
+		a.SetMimeDetector(&MimeDetector{Sniff: true, Default: "application/x-unknown"})
+		addr, err := a.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw, err := a.NewManifestWriter(ctx, addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"page":      "<!DOCTYPE html><html></html>",
+			"data":      "\x00\x01\x02",
+			"typed.bin": "\x00\x01\x02",
+		}
+		for path, content := range files {
+			entry := &ManifestEntry{Path: path, Size: int64(len(content))}
+			if path == "typed.bin" {
+				entry.ContentType = "application/x-typed"
+			}
+			if _, err := mw.AddEntry(ctx, strings.NewReader(content), entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if addr, err = mw.Store(); err != nil {
+			t.Fatal(err)
+		}
+
+		for path, typ := range map[string]string{
+			"page":      "text/html; charset=utf-8",
+			"data":      "application/x-unknown",
+			"typed.bin": "application/x-typed",
+		} {
+			_, contentType, _, _, _, err := a.Get(ctx, addr, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != typ {
+				t.Fatalf("%s: expected %q, got %q", path, typ, contentType)
+			}
+		}
+	})
+}
@@ -0,0 +1,399 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+var (
+	// ErrNameNotFound is returned when a name is not in the name registry
+	ErrNameNotFound = errors.New("name not registered")
+	// ErrNameTaken is returned when a name registered by another owner of
+	// the name registry is changed or removed
+	ErrNameTaken = errors.New("name registered by another owner")
+	// ErrNotRegistryOwner is returned when the key of the node is not one of
+	// the owners of the name registry
+	ErrNotRegistryOwner = errors.New("not an owner of the name registry")
+)
+
+// NameRecord is the entry of a name in a name registry
+type NameRecord struct {
+	Name    string         `json:"name"`
+	Hash    string         `json:"hash"`
+	Owner   common.Address `json:"owner"`
+	Updated time.Time      `json:"updated"`
+}
+
+// nameTable is the document the updates of a name registry point to
+type nameTable struct {
+	Names map[string]*NameRecord `json:"names"`
+
+	resource string           // name of the registry resource
+	owners   []common.Address // owners of the registry
+}
+
+// NameRegistry maps names to content hashes with a Mutable Resource, so that
+// names can be resolved in a private swarm without ENS or a connection to a
+// blockchain.
+//
+// The registry is a multi-author resource whose authors are the owners of
+// the registry. Every update points to the name table, and registering or
+// removing a name publishes the current table with the change. Conflicts are
+// settled by these rules:
+//
+//   - only the updates of the owners of the registry are looked up
+//   - the current table is the one of the update chosen by the merge rule of
+//     the resource, with the sequence rule the change of an owner is lost only
+//     if another owner publishes a table at the same time
+//   - a name is held by the owner which registered it, the other owners can
+//     not change or remove it
+type NameRegistry struct {
+	addr     storage.Address // address of the metadata chunk of the resource
+	resource *mru.Handler
+	api      *API
+	mu       sync.Mutex // serialises the updates of the node
+}
+
+// NormalizeName returns the form of the name it is registered with
+func NormalizeName(name string) (string, error) {
+	safe, err := mru.ToSafeName(strings.ToLower(strings.TrimSuffix(name, "/")))
+	if err != nil {
+		return "", fmt.Errorf("invalid name %q: %v", name, err)
+	}
+	if safe == "" {
+		return "", errors.New("empty name")
+	}
+	return safe, nil
+}
+
+// NameRegistryCreate creates a name registry owned by the given owners and
+// returns the address of its metadata chunk. The key of the node has to be
+// one of the owners, as it publishes the empty name table.
+func (a *API) NameRegistryCreate(ctx context.Context, name string, frequency uint64, owners []common.Address, merge mru.MergeRule) (storage.Address, error) {
+	self, err := a.nameOwner()
+	if err != nil {
+		return nil, err
+	}
+	if !containsAddress(owners, self) {
+		return nil, ErrNotRegistryOwner
+	}
+	addr, err := a.ResourceCreateMultiAuthor(ctx, name, frequency, owners, merge)
+	if err != nil {
+		return nil, err
+	}
+	r := &NameRegistry{addr: addr, resource: a.resource, api: a}
+	table := &nameTable{Names: make(map[string]*NameRecord), resource: name}
+	if err := r.publish(ctx, table); err != nil {
+		return nil, err
+	}
+	log.Info("created name registry", "addr", addr, "name", name, "owners", len(owners), "merge", merge)
+	return addr, nil
+}
+
+// SetNameRegistry sets the name registry with the given metadata chunk
+// address, the names in it are resolved ahead of ENS
+func (a *API) SetNameRegistry(addr storage.Address) {
+	if addr == nil {
+		a.names = nil
+		return
+	}
+	a.names = &NameRegistry{addr: addr, resource: a.resource, api: a}
+}
+
+// NameRegistry returns the name registry of the node, nil if none is set
+func (a *API) NameRegistry() *NameRegistry {
+	return a.names
+}
+
+// nameOwner returns the address names are registered by, the one of the key
+// of the node
+func (a *API) nameOwner() (common.Address, error) {
+	if a.accessKey == nil {
+		return common.Address{}, errors.New("no key to update the name registry with")
+	}
+	return crypto.PubkeyToAddress(a.accessKey.PublicKey), nil
+}
+
+// Address returns the address of the metadata chunk of the registry
+func (r *NameRegistry) Address() storage.Address {
+	return r.addr
+}
+
+// Resolve resolves the name to the hash it is registered with, it
+// implements Resolver
+func (r *NameRegistry) Resolve(name string) (common.Hash, error) {
+	record, err := r.Lookup(context.TODO(), name)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(record.Hash), nil
+}
+
+// Lookup returns the record of the name in the current name table
+func (r *NameRegistry) Lookup(ctx context.Context, name string) (*NameRecord, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	table, err := r.table(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := table.Names[name]
+	if !ok {
+		return nil, ErrNameNotFound
+	}
+	return record, nil
+}
+
+// Names returns the records of the current name table sorted by name
+func (r *NameRegistry) Names(ctx context.Context) ([]*NameRecord, error) {
+	table, err := r.table(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*NameRecord, 0, len(table.Names))
+	for _, record := range table.Names {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+// Register registers the name with the hash, or changes the hash of a name
+// registered by the node
+func (r *NameRegistry) Register(ctx context.Context, name string, hash storage.Address) (*NameRecord, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != common.HashLength {
+		return nil, fmt.Errorf("invalid hash %s", hash)
+	}
+	var record *NameRecord
+	err = r.update(ctx, func(table *nameTable, owner common.Address) error {
+		if old, ok := table.Names[name]; ok && old.Owner != owner {
+			return ErrNameTaken
+		}
+		record = &NameRecord{
+			Name:    name,
+			Hash:    hash.Hex(),
+			Owner:   owner,
+			Updated: time.Now().UTC(),
+		}
+		table.Names[name] = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Remove removes a name registered by the node
+func (r *NameRegistry) Remove(ctx context.Context, name string) error {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	return r.update(ctx, func(table *nameTable, owner common.Address) error {
+		old, ok := table.Names[name]
+		if !ok {
+			return ErrNameNotFound
+		}
+		if old.Owner != owner {
+			return ErrNameTaken
+		}
+		delete(table.Names, name)
+		return nil
+	})
+}
+
+// update applies the change to the current name table and publishes it
+func (r *NameRegistry) update(ctx context.Context, change func(*nameTable, common.Address) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner, err := r.api.nameOwner()
+	if err != nil {
+		return err
+	}
+	table, err := r.table(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsAddress(table.owners, owner) {
+		return ErrNotRegistryOwner
+	}
+	if err := change(table, owner); err != nil {
+		return err
+	}
+	return r.publish(ctx, table)
+}
+
+// table looks up the current name table
+func (r *NameRegistry) table(ctx context.Context) (*nameTable, error) {
+	rsrc, err := r.resource.Load(r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load name registry %s: %v", r.addr, err)
+	}
+	if len(rsrc.Authors()) == 0 {
+		return nil, fmt.Errorf("not a name registry: %s", r.addr)
+	}
+	update, err := r.api.resourceLatest(ctx, rsrc.NameHash())
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up name registry %s: %v", r.addr, err)
+	}
+	if len(update.Data) != common.HashLength {
+		return nil, fmt.Errorf("invalid name registry update %s", update.Key)
+	}
+	reader, _ := r.api.Retrieve(ctx, storage.Address(update.Data))
+	size, err := reader.Size(nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve name table: %v", err)
+	}
+	data, err := ioutil.ReadAll(io.NewSectionReader(reader, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve name table: %v", err)
+	}
+	table := &nameTable{resource: rsrc.Name(), owners: rsrc.Authors()}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("invalid name table: %v", err)
+	}
+	if table.Names == nil {
+		table.Names = make(map[string]*NameRecord)
+	}
+	return table, nil
+}
+
+// publish stores the name table and updates the registry resource with its
+// address
+func (r *NameRegistry) publish(ctx context.Context, table *nameTable) error {
+	data, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	addr, wait, err := r.api.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		return err
+	}
+	if err := wait(ctx); err != nil {
+		return err
+	}
+	if _, err := r.resource.Update(ctx, table.resource, addr); err != nil {
+		return err
+	}
+	log.Debug("published name table", "registry", r.addr, "table", addr, "names", len(table.Names))
+	return nil
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// NameAPI manages the name registry of the node in the bzz namespace
+type NameAPI struct {
+	api *API
+}
+
+// NewNameAPI creates a new NameAPI
+func NewNameAPI(api *API) *NameAPI {
+	return &NameAPI{api: api}
+}
+
+// CreateNameRegistry creates a name registry owned by the given owners with
+// the merge rule ("last-writer" or "sequence") and returns its address, the
+// node has to be started with it to resolve its names
+func (n *NameAPI) CreateNameRegistry(ctx context.Context, name string, frequency uint64, owners []common.Address, merge string) (string, error) {
+	rule, err := mru.ParseMergeRule(merge)
+	if err != nil {
+		return "", err
+	}
+	addr, err := n.api.NameRegistryCreate(ctx, name, frequency, owners, rule)
+	if err != nil {
+		return "", err
+	}
+	return addr.Hex(), nil
+}
+
+// ResolveName returns the record of the name in the name registry
+func (n *NameAPI) ResolveName(ctx context.Context, name string) (*NameRecord, error) {
+	r, err := n.registry()
+	if err != nil {
+		return nil, err
+	}
+	return r.Lookup(ctx, name)
+}
+
+// RegisterName registers the name with the hash in the name registry
+func (n *NameAPI) RegisterName(ctx context.Context, name string, hash string) (*NameRecord, error) {
+	r, err := n.registry()
+	if err != nil {
+		return nil, err
+	}
+	return r.Register(ctx, name, storage.Address(common.FromHex(hash)))
+}
+
+// RemoveName removes the name from the name registry
+func (n *NameAPI) RemoveName(ctx context.Context, name string) error {
+	r, err := n.registry()
+	if err != nil {
+		return err
+	}
+	return r.Remove(ctx, name)
+}
+
+// Names returns the records of the names in the name registry
+func (n *NameAPI) Names(ctx context.Context) ([]*NameRecord, error) {
+	r, err := n.registry()
+	if err != nil {
+		return nil, err
+	}
+	return r.Names(ctx)
+}
+
+func (n *NameAPI) registry() (*NameRegistry, error) {
+	r := n.api.NameRegistry()
+	if r == nil {
+		return nil, errors.New("no name registry set")
+	}
+	return r, nil
+}
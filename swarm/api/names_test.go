@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+// TestNameRegistry tests that names registered by the owners of a name
+// registry are resolved by the other nodes, and that a name can only be
+// changed by the owner which registered it
+func TestNameRegistry(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "bzz-names-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	params := storage.NewDefaultLocalStoreParams()
+	params.Init(filepath.Join(datadir, "chunks"))
+	localStore, err := storage.NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentValidator, err := storage.NewMultiHashValidator(storage.SupportedHashes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localStore.Validators = append(localStore.Validators, contentValidator)
+	netStore := storage.NewNetStore(localStore, nil)
+	fileStore := storage.NewFileStore(netStore, storage.NewFileStoreParams())
+	headers := &fakeHeaderGetter{blocknumber: 42}
+
+	// the nodes share the store, as if the chunks were synced between them
+	newNode := func() (*API, *ecdsa.PrivateKey) {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rh, err := mru.NewHandler(&mru.HandlerParams{
+			QueryMaxPeriods: &mru.LookupParams{},
+			Signer:          &mru.GenericSigner{PrivKey: key},
+			HeaderGetter:    headers,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rh.SetStore(netStore)
+		localStore.Validators = append(localStore.Validators, rh)
+		a := NewAPI(fileStore, nil, rh)
+		a.SetAccessKey(key)
+		return a, key
+	}
+	a1, key1 := newNode()
+	a2, key2 := newNode()
+	a3, _ := newNode()
+	owners := []common.Address{crypto.PubkeyToAddress(key1.PublicKey), crypto.PubkeyToAddress(key2.PublicKey)}
+
+	ctx := context.Background()
+	if _, err := a3.NameRegistryCreate(ctx, "names.test", 1, owners, mru.MergeSequence); err != ErrNotRegistryOwner {
+		t.Fatalf("expected %v creating a registry not owned by the node, got %v", ErrNotRegistryOwner, err)
+	}
+	addr, err := a1.NameRegistryCreate(ctx, "names.test", 1, owners, mru.MergeSequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []*API{a1, a2, a3} {
+		a.SetNameRegistry(addr)
+	}
+
+	hash1 := storage.Address(crypto.Keccak256([]byte("site")))
+	hash2 := storage.Address(crypto.Keccak256([]byte("other")))
+	if _, err := a1.NameRegistry().Register(ctx, "Site.Test", hash1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a2.NameRegistry().Register(ctx, "site.test", hash2); err != ErrNameTaken {
+		t.Fatalf("expected %v registering a name of another owner, got %v", ErrNameTaken, err)
+	}
+	if _, err := a2.NameRegistry().Register(ctx, "other.test", hash2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a3.NameRegistry().Register(ctx, "third.test", hash2); err != ErrNotRegistryOwner {
+		t.Fatalf("expected %v registering with a node which is not an owner, got %v", ErrNotRegistryOwner, err)
+	}
+
+	// the names of both owners are resolved by every node
+	for _, test := range []struct {
+		name string
+		hash storage.Address
+	}{
+		{"site.test", hash1},
+		{"other.test", hash2},
+	} {
+		resolved, err := a3.Resolve(ctx, &URI{Scheme: "bzz", Addr: test.name})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(resolved, test.hash) {
+			t.Fatalf("expected %s to resolve to %s, got %s", test.name, test.hash, resolved)
+		}
+	}
+	records, err := a3.NameRegistry().Names(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Name != "other.test" || records[0].Owner != owners[1] {
+		t.Fatalf("unexpected name records %v", records)
+	}
+
+	if err := a2.NameRegistry().Remove(ctx, "site.test"); err != ErrNameTaken {
+		t.Fatalf("expected %v removing a name of another owner, got %v", ErrNameTaken, err)
+	}
+	if err := a1.NameRegistry().Remove(ctx, "site.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a3.NameRegistry().Lookup(ctx, "site.test"); err != ErrNameNotFound {
+		t.Fatalf("expected %v looking up a removed name, got %v", ErrNameNotFound, err)
+	}
+	if _, err := a3.Resolve(ctx, &URI{Scheme: "bzz", Addr: "site.test"}); err == nil {
+		t.Fatal("expected a removed name not to be resolved")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/sctx"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// Pin protects the content with the given root from garbage collection of
+// the local store, the content must be available locally. If the root is a
+// manifest, the content of its entries and submanifests and the parts of its
+// composite entries are pinned as well, the updates of resources are not.
+// Content pinned more than once is kept until it is unpinned as many times.
+func (a *API) Pin(ctx context.Context, root storage.Address) error {
+	contents, err := a.pinContents(ctx, root)
+	if err != nil {
+		return err
+	}
+	log.Debug("api.pin", "ruid", sctx.GetRequestID(ctx), "root", root, "contents", len(contents))
+	for i, addr := range contents {
+		if err := a.fileStore.Pin(ctx, addr); err != nil {
+			for _, pinned := range contents[:i] {
+				a.fileStore.Unpin(ctx, pinned)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Unpin removes the pins of the content with the given root made by Pin
+func (a *API) Unpin(ctx context.Context, root storage.Address) error {
+	contents, err := a.pinContents(ctx, root)
+	if err != nil {
+		return err
+	}
+	log.Debug("api.unpin", "ruid", sctx.GetRequestID(ctx), "root", root, "contents", len(contents))
+	for _, addr := range contents {
+		if err := a.fileStore.Unpin(ctx, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinContents returns the addresses of the contents pinned with the root,
+// each is listed once
+func (a *API) pinContents(ctx context.Context, root storage.Address) ([]storage.Address, error) {
+	contents := []storage.Address{root}
+	trie, err := loadManifest(ctx, a.fileStore.Local(), root, nil)
+	if err != nil {
+		// not a manifest
+		return contents, nil
+	}
+
+	seen := map[string]bool{root.Hex(): true}
+	add := func(hash string) {
+		if hash == "" || seen[hash] {
+			return
+		}
+		seen[hash] = true
+		contents = append(contents, storage.Address(common.Hex2Bytes(hash)))
+	}
+	walker := &ManifestWalker{a, trie, nil}
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		if entry.ContentType == ResourceContentType {
+			return nil
+		}
+		// the hashes of inline and composite entries are not stored
+		switch {
+		case entry.Data != nil:
+		case len(entry.Parts) > 0:
+			for _, part := range entry.Parts {
+				add(part)
+			}
+		default:
+			add(entry.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
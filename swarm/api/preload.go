@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// MaxPreload is the maximum number of dependencies of a manifest entry
+// which are preloaded when the entry is retrieved
+const MaxPreload = 16
+
+var (
+	apiPreloadCount = metrics.NewRegisteredCounter("api.preload.count", nil)
+	apiPreloadFail  = metrics.NewRegisteredCounter("api.preload.fail", nil)
+)
+
+// Preload looks up the entries the given entry of the manifest declares as
+// its dependencies and starts retrieving their content into the local
+// store, it returns the entries found. The dependencies are the paths of
+// other entries of the same manifest, the ones which are not found are
+// skipped.
+func (a *API) Preload(ctx context.Context, manifestAddr storage.Address, entry *ManifestEntry) ([]*ManifestEntry, error) {
+	if len(entry.Preload) == 0 {
+		return nil, nil
+	}
+	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil)
+	if err != nil {
+		return nil, err
+	}
+	var deps []*ManifestEntry
+	for _, path := range entry.Preload {
+		if len(deps) == MaxPreload {
+			break
+		}
+		dep, full := trie.getEntry(path)
+		if dep == nil || full != RegularSlashes(path) || dep.ContentType == ManifestType {
+			log.Debug("preloaded entry not found", "key", manifestAddr, "path", path)
+			apiPreloadFail.Inc(1)
+			continue
+		}
+		e := dep.ManifestEntry
+		deps = append(deps, &e)
+		// the content of inline entries is in the manifest
+		if e.Hash == "" || len(e.Data) > 0 {
+			continue
+		}
+		apiPreloadCount.Inc(1)
+		if _, err := a.Prefetch(ctx, storage.Address(common.Hex2Bytes(e.Hash))); err != nil {
+			apiPreloadFail.Inc(1)
+			log.Warn("preload failed", "key", manifestAddr, "path", path, "err", err)
+		}
+	}
+	return deps, nil
+}
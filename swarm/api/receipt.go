@@ -0,0 +1,78 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// UploadReceipt describes the content stored by an upload
+type UploadReceipt struct {
+	Address   string        `json:"address"`   // reference of the content, with the decryption key if encrypted
+	RootHash  string        `json:"rootHash"`  // address of the root chunk of the content
+	Encrypted bool          `json:"encrypted"` // the address is an encrypted reference
+	Manifest  bool          `json:"manifest"`  // the address is the address of a manifest
+	Tag       uint32        `json:"tag"`       // uid of the tag counting the chunks of the upload
+	Chunks    int64         `json:"chunks"`    // number of chunks of the upload
+	Bytes     int64         `json:"bytes"`     // number of bytes uploaded
+	Elapsed   time.Duration `json:"elapsed"`   // duration of the upload in nanoseconds
+}
+
+// NewUploadReceipt returns the receipt of the upload of size bytes stored at
+// the address, the chunks and the duration of the upload are taken from its
+// tag
+func NewUploadReceipt(addr storage.Address, tag *storage.Tag, size int64, isManifest bool) *UploadReceipt {
+	receipt := &UploadReceipt{
+		Address:   addr.Hex(),
+		RootHash:  addr.Hex(),
+		Encrypted: len(addr) > storage.KeyLength,
+		Manifest:  isManifest,
+		Bytes:     size,
+	}
+	if receipt.Encrypted {
+		receipt.RootHash = addr[:storage.KeyLength].Hex()
+	}
+	if tag != nil {
+		receipt.Tag = tag.Uid
+		receipt.Chunks = tag.Get(storage.StateTotal)
+		receipt.Elapsed = time.Since(tag.StartedAt)
+	}
+	return receipt
+}
+
+// StoreWithReceipt stores the data like StoreWithHash, waits until its chunks
+// are stored and returns the receipt of the upload, the chunks are counted by
+// a new tag named "upload"
+func (a *API) StoreWithReceipt(ctx context.Context, data io.Reader, size int64, toEncrypt bool, hash string) (*UploadReceipt, error) {
+	tag := a.tags.New("upload", nil)
+	ctx = storage.WithTag(ctx, tag)
+	addr, wait, err := a.StoreWithHash(ctx, data, size, toEncrypt, hash)
+	if err == nil {
+		err = wait(ctx)
+	}
+	if err != nil {
+		tag.Done(err)
+		return nil, err
+	}
+	tag.SetAddress(addr)
+	tag.Done(nil)
+	return NewUploadReceipt(addr, tag, size, false), nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+// MaxRPCContentSize is the largest content which can be uploaded or
+// downloaded in a single RPC call, larger content has to go through the
+// HTTP API
+const MaxRPCContentSize = 16 * 1024 * 1024
+
+// ContentAPI exposes content upload, name resolution, manifest listing and
+// Mutable Resource updates in the bzz namespace, so that they can be used
+// with the rpc client over the IPC, WS and HTTP-RPC endpoints of the node
+type ContentAPI struct {
+	api *API
+}
+
+// NewContentAPI creates a new ContentAPI
+func NewContentAPI(api *API) *ContentAPI {
+	return &ContentAPI{api: api}
+}
+
+// UploadBytes stores the data and returns its address once all its chunks
+// are stored
+func (c *ContentAPI) UploadBytes(ctx context.Context, data hexutil.Bytes, toEncrypt bool) (string, error) {
+	if len(data) > MaxRPCContentSize {
+		return "", fmt.Errorf("content too large: %d bytes, the limit is %d", len(data), MaxRPCContentSize)
+	}
+	addr, wait, err := c.api.Store(ctx, bytes.NewReader(data), int64(len(data)), toEncrypt)
+	if err != nil {
+		return "", err
+	}
+	if err := wait(ctx); err != nil {
+		return "", err
+	}
+	return addr.Hex(), nil
+}
+
+// UploadFile stores the data with a manifest giving its content type and
+// returns the address of the manifest once all its chunks are stored
+func (c *ContentAPI) UploadFile(ctx context.Context, data hexutil.Bytes, contentType string, toEncrypt bool) (string, error) {
+	if len(data) > MaxRPCContentSize {
+		return "", fmt.Errorf("content too large: %d bytes, the limit is %d", len(data), MaxRPCContentSize)
+	}
+	addr, wait, err := c.api.Put(ctx, string(data), contentType, toEncrypt)
+	if err != nil {
+		return "", err
+	}
+	if err := wait(ctx); err != nil {
+		return "", err
+	}
+	return addr.Hex(), nil
+}
+
+// DownloadBytes retrieves the data with the given address
+func (c *ContentAPI) DownloadBytes(ctx context.Context, addr string) (hexutil.Bytes, error) {
+	key, err := c.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	reader, _ := c.api.Retrieve(ctx, key)
+	size, err := reader.Size(nil)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxRPCContentSize {
+		return nil, fmt.Errorf("content too large: %d bytes, the limit is %d", size, MaxRPCContentSize)
+	}
+	return ioutil.ReadAll(io.NewSectionReader(reader, 0, size))
+}
+
+// Resolve resolves an ENS name or a content hash to an address
+func (c *ContentAPI) Resolve(ctx context.Context, name string) (string, error) {
+	key, err := c.resolve(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return key.Hex(), nil
+}
+
+// List returns the entries of the manifest with the given address or ENS
+// name which start with the prefix, the paths continuing with a slash after
+// the prefix are listed as common prefixes
+func (c *ContentAPI) List(ctx context.Context, addr string, prefix string) (*ManifestList, error) {
+	key, err := c.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.api.GetManifestList(ctx, key, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// UpdateFeed publishes the data as a new update of the Mutable Resource of
+// the resource manifest with the given address or ENS name, the data is
+// marked as a multihash if multihash is true
+func (c *ContentAPI) UpdateFeed(ctx context.Context, manifest string, data hexutil.Bytes, multihash bool) (*FeedUpdate, error) {
+	manifestAddr, err := c.resolve(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := c.api.ResolveResourceManifest(ctx, manifestAddr)
+	if err != nil {
+		return nil, err
+	}
+	// the lookup brings the resource in sync with its latest update, which
+	// is required to publish the next one
+	name, _, _, err := c.api.ResourceLookup(ctx, addr, 0, 0, &mru.LookupParams{})
+	if err != nil {
+		return nil, err
+	}
+	var key storage.Address
+	var period, version uint32
+	if multihash {
+		key, period, version, err = c.api.ResourceUpdateMultihash(ctx, name, data)
+	} else {
+		key, period, version, err = c.api.ResourceUpdate(ctx, name, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FeedUpdate{
+		Name:    name,
+		Key:     hexutil.Bytes(key),
+		Period:  period,
+		Version: version,
+		Data:    data,
+	}, nil
+}
+
+// resolve resolves an ENS name or a content hash to an address
+func (c *ContentAPI) resolve(ctx context.Context, name string) (storage.Address, error) {
+	uri, err := Parse("bzz:/" + name)
+	if err != nil {
+		return nil, err
+	}
+	return c.api.Resolve(ctx, uri)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/storage/mru"
+)
+
+// TestContentAPI tests uploading, resolving, listing and feed updates
+// through the bzz RPC namespace
+func TestContentAPI(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "bzz-rpc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, err := storage.NewLocalFileStore(datadir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rh, err := mru.NewTestHandler(datadir, &mru.HandlerParams{
+		QueryMaxPeriods: &mru.LookupParams{},
+		HeaderGetter:    &fakeHeaderGetter{blocknumber: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rh.Close()
+	a := NewAPI(fileStore, nil, rh)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("bzz", NewContentAPI(a)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// bytes round trip
+	data := []byte("the quick brown fox")
+	var addr string
+	if err := client.Call(&addr, "bzz_uploadBytes", hexutil.Bytes(data), false); err != nil {
+		t.Fatal(err)
+	}
+	var got hexutil.Bytes
+	if err := client.Call(&got, "bzz_downloadBytes", addr); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+	var resolved string
+	if err := client.Call(&resolved, "bzz_resolve", addr); err != nil {
+		t.Fatal(err)
+	}
+	if resolved != addr {
+		t.Fatalf("expected %s to resolve to itself, got %s", addr, resolved)
+	}
+
+	// a file is listed in its manifest with its content type
+	var manifestAddr string
+	if err := client.Call(&manifestAddr, "bzz_uploadFile", hexutil.Bytes(data), "text/plain", false); err != nil {
+		t.Fatal(err)
+	}
+	var list ManifestList
+	if err := client.Call(&list, "bzz_list", manifestAddr, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].Hash != addr || list.Entries[0].ContentType != "text/plain" {
+		t.Fatalf("unexpected manifest list %+v", list)
+	}
+
+	// feed update through the resource manifest
+	ctx := context.Background()
+	rsrcAddr, err := a.ResourceCreate(ctx, "rpc.eth", 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.ResourceUpdate(ctx, "rpc.eth", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := a.NewResourceManifest(ctx, rsrcAddr.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var update FeedUpdate
+	if err := client.Call(&update, "bzz_updateFeed", m.Hex(), hexutil.Bytes("update"), false); err != nil {
+		t.Fatal(err)
+	}
+	if update.Name != "rpc.eth" || string(update.Data) != "update" || update.Period == 0 || len(update.Key) == 0 {
+		t.Fatalf("unexpected feed update %+v", update)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+// The headers of the write requests of the HTTP API signed with a signing
+// key: the signature of SignedRequestData is sent with the id of the key,
+// the unix time the request was signed at and the hex encoded SHA-256 hash
+// of the body
+const (
+	SwarmKeyHeader         = "X-Swarm-Key"
+	SwarmTimestampHeader   = "X-Swarm-Timestamp"
+	SwarmContentHashHeader = "X-Swarm-Content-Sha256"
+	SwarmSignatureHeader   = "X-Swarm-Signature"
+)
+
+var (
+	// ErrUnknownSigningKey is returned when a key which is not known is
+	// removed
+	ErrUnknownSigningKey = errors.New("unknown signing key")
+	// ErrSigningKeyExists is returned when a key is added with the id of
+	// a known key
+	ErrSigningKeyExists = errors.New("signing key already exists")
+)
+
+// signingKeysKey is the state store key under which the signing keys are
+// persisted
+const signingKeysKey = "signing_keys"
+
+// SigningKey is a key the write requests of the HTTP API are signed with,
+// either a secret shared with the client signing HMAC-SHA256 signatures, or
+// the address of the key of the client signing ECDSA signatures which never
+// leaves the client
+type SigningKey struct {
+	ID      string          `json:"id"`
+	Secret  hexutil.Bytes   `json:"secret,omitempty"`  // HMAC secret, empty for ECDSA keys
+	Address *common.Address `json:"address,omitempty"` // address of the ECDSA key, nil for HMAC keys
+	Added   time.Time       `json:"added"`
+}
+
+// Verify reports whether sig is the signature of data with the key
+func (k *SigningKey) Verify(data, sig []byte) bool {
+	if k.Address != nil {
+		pub, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+		return err == nil && crypto.PubkeyToAddress(*pub) == *k.Address
+	}
+	return hmac.Equal(SignRequestHMAC(k.Secret, data), sig)
+}
+
+// SignedRequestData returns the data the signature of a write request signs
+func SignedRequestData(method, requestURI, keyID string, timestamp int64, contentHash []byte) []byte {
+	return []byte(strings.Join([]string{
+		method,
+		requestURI,
+		keyID,
+		strconv.FormatInt(timestamp, 10),
+		hex.EncodeToString(contentHash),
+	}, "\n"))
+}
+
+// SignRequestHMAC returns the HMAC-SHA256 signature of the data of a request
+func SignRequestHMAC(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// SignRequestECDSA returns the ECDSA signature of the data of a request
+func SignRequestECDSA(key *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	return crypto.Sign(crypto.Keccak256(data), key)
+}
+
+// SigningKeys are the keys the write requests of the HTTP API can be signed
+// with, persisted in the state store
+type SigningKeys struct {
+	store state.Store
+	mu    sync.RWMutex
+	keys  map[string]*SigningKey
+}
+
+// NewSigningKeys loads the signing keys from store
+func NewSigningKeys(store state.Store) (*SigningKeys, error) {
+	k := &SigningKeys{
+		store: store,
+		keys:  make(map[string]*SigningKey),
+	}
+	err := store.Get(signingKeysKey, &k.keys)
+	if err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Get returns the key with the given id
+func (k *SigningKeys) Get(id string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Keys returns the keys sorted by id, without their secrets
+func (k *SigningKeys) Keys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		key := *key
+		key.Secret = nil
+		keys = append(keys, &key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// AddHMAC adds an HMAC key with a random secret, which is only returned
+// when the key is added
+func (k *SigningKeys) AddHMAC(id string) (*SigningKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return k.add(&SigningKey{ID: id, Secret: secret})
+}
+
+// AddECDSA adds the ECDSA key with the given address
+func (k *SigningKeys) AddECDSA(id string, addr common.Address) (*SigningKey, error) {
+	return k.add(&SigningKey{ID: id, Address: &addr})
+}
+
+func (k *SigningKeys) add(key *SigningKey) (*SigningKey, error) {
+	if key.ID == "" || strings.ContainsAny(key.ID, "\n\r") {
+		return nil, fmt.Errorf("invalid signing key id %q", key.ID)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[key.ID]; ok {
+		return nil, ErrSigningKeyExists
+	}
+	key.Added = time.Now().UTC()
+	k.keys[key.ID] = key
+	if err := k.store.Put(signingKeysKey, k.keys); err != nil {
+		delete(k.keys, key.ID)
+		return nil, err
+	}
+	log.Info("added signing key", "id", key.ID)
+	return key, nil
+}
+
+// Remove removes the key with the given id, the requests signed with it
+// are refused
+func (k *SigningKeys) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return ErrUnknownSigningKey
+	}
+	delete(k.keys, id)
+	if err := k.store.Put(signingKeysKey, k.keys); err != nil {
+		k.keys[id] = key
+		return err
+	}
+	log.Info("removed signing key", "id", id)
+	return nil
+}
+
+// SigningKeysAPI manages the keys the write requests of the HTTP API are
+// signed with in the bzz namespace
+type SigningKeysAPI struct {
+	keys *SigningKeys
+}
+
+// NewSigningKeysAPI creates a new SigningKeysAPI
+func NewSigningKeysAPI(keys *SigningKeys) *SigningKeysAPI {
+	return &SigningKeysAPI{keys: keys}
+}
+
+// AddHMACKey adds an HMAC key, its secret is only returned by this call
+func (s *SigningKeysAPI) AddHMACKey(id string) (*SigningKey, error) {
+	return s.keys.AddHMAC(id)
+}
+
+// AddECDSAKey adds the ECDSA key with the given address
+func (s *SigningKeysAPI) AddECDSAKey(id string, addr common.Address) (*SigningKey, error) {
+	return s.keys.AddECDSA(id, addr)
+}
+
+// RemoveSigningKey removes the key with the given id
+func (s *SigningKeysAPI) RemoveSigningKey(id string) error {
+	return s.keys.Remove(id)
+}
+
+// SigningKeys returns the signing keys without their secrets
+func (s *SigningKeysAPI) SigningKeys() []*SigningKey {
+	return s.keys.Keys()
+}
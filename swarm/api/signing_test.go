@@ -0,0 +1,104 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+// TestSigningKeys tests that the signing keys verify the signatures of
+// their clients and are persisted in the state store
+func TestSigningKeys(t *testing.T) {
+	store := state.NewInmemoryStore()
+	keys, err := NewSigningKeys(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey, err := keys.AddHMAC("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hmacKey.Secret) != 32 {
+		t.Fatalf("expected a 32 byte secret, got %d bytes", len(hmacKey.Secret))
+	}
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.AddECDSA("deploy", crypto.PubkeyToAddress(privKey.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.AddHMAC("ci"); err != ErrSigningKeyExists {
+		t.Fatalf("expected error %v, got %v", ErrSigningKeyExists, err)
+	}
+	if _, err := keys.AddHMAC("a\nb"); err == nil {
+		t.Fatal("expected an error adding a key with an invalid id")
+	}
+
+	contentHash := sha256.Sum256([]byte("content"))
+	data := SignedRequestData("POST", "/bzz:/", "ci", 1500000000, contentHash[:])
+	other := SignedRequestData("POST", "/bzz:/", "ci", 1500000001, contentHash[:])
+
+	key, _ := keys.Get("ci")
+	if !key.Verify(data, SignRequestHMAC(hmacKey.Secret, data)) {
+		t.Fatal("expected the HMAC signature to be valid")
+	}
+	if key.Verify(other, SignRequestHMAC(hmacKey.Secret, data)) {
+		t.Fatal("expected the HMAC signature of other data to be invalid")
+	}
+	sig, err := SignRequestECDSA(privKey, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ = keys.Get("deploy")
+	if !key.Verify(data, sig) {
+		t.Fatal("expected the ECDSA signature to be valid")
+	}
+	if key.Verify(other, sig) {
+		t.Fatal("expected the ECDSA signature of other data to be invalid")
+	}
+
+	// the keys are loaded from the state store without exposing secrets
+	loaded, err := NewSigningKeys(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := loaded.Keys()
+	if len(list) != 2 || list[0].ID != "ci" || list[1].ID != "deploy" {
+		t.Fatalf("unexpected keys %v", list)
+	}
+	if list[0].Secret != nil {
+		t.Fatal("expected the listed keys to have no secrets")
+	}
+	if key, ok := loaded.Get("ci"); !ok || !key.Verify(data, SignRequestHMAC(hmacKey.Secret, data)) {
+		t.Fatal("expected the loaded HMAC key to verify its signatures")
+	}
+
+	if err := loaded.Remove("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Remove("ci"); err != ErrUnknownSigningKey {
+		t.Fatalf("expected error %v, got %v", ErrUnknownSigningKey, err)
+	}
+	if _, ok := loaded.Get("ci"); ok {
+		t.Fatal("expected the removed key to be unknown")
+	}
+}
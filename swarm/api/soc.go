@@ -0,0 +1,53 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// PutSOC signs the payload with the given key and stores it as the
+// single-owner chunk with the given identifier
+func (a *API) PutSOC(ctx context.Context, id common.Hash, payload []byte, key *ecdsa.PrivateKey) (storage.Address, error) {
+	soc, err := storage.NewSingleOwnerChunk(id, payload, key)
+	if err != nil {
+		return nil, err
+	}
+	chunk := soc.Chunk()
+	log.Debug("api.putsoc", "owner", soc.Owner, "id", id, "addr", chunk.Addr)
+	a.fileStore.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		return nil, err
+	}
+	return chunk.Addr, nil
+}
+
+// GetSOC retrieves the single-owner chunk of the given owner with the given
+// identifier and verifies its signature
+func (a *API) GetSOC(ctx context.Context, owner common.Address, id common.Hash) (*storage.SingleOwnerChunk, error) {
+	addr := storage.SOCAddress(owner, id)
+	chunk, err := a.fileStore.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	return storage.ParseSingleOwnerChunk(addr, chunk.SData)
+}
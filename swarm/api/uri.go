@@ -39,6 +39,12 @@ type URI struct {
 	// * bzz-immutable - immutable URI of an entry in a swarm manifest
 	//                   (address is not resolved)
 	// * bzz-list      -  list of all files contained in a swarm manifest
+	// * bzz-prefetch  - retrieval of swarm content into the local store
+	// * bzz-pin       - protection of locally stored swarm content from
+	//                   garbage collection
+	// * bzz-tag       - progress of an operation of the node by the uid of
+	//                   its tag (address is the uid)
+	// * bzz-chunk     - data of a single chunk (address is not resolved)
 	//
 	Scheme string
 
@@ -63,7 +69,8 @@ type URI struct {
 // * <scheme>://<addr>
 // * <scheme>://<addr>/<path>
 //
-// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list or bzz-hash
+// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list, bzz-hash,
+// bzz-resource, bzz-prefetch, bzz-pin, bzz-tag or bzz-chunk
 func Parse(rawuri string) (*URI, error) {
 	u, err := url.Parse(rawuri)
 	if err != nil {
@@ -73,7 +80,7 @@ func Parse(rawuri string) (*URI, error) {
 
 	// check the scheme is valid
 	switch uri.Scheme {
-	case "bzz", "bzz-raw", "bzz-immutable", "bzz-list", "bzz-hash", "bzz-resource":
+	case "bzz", "bzz-raw", "bzz-immutable", "bzz-list", "bzz-hash", "bzz-resource", "bzz-prefetch", "bzz-pin", "bzz-tag", "bzz-chunk":
 	default:
 		return nil, fmt.Errorf("unknown scheme %q", u.Scheme)
 	}
@@ -115,6 +122,22 @@ func (u *URI) Hash() bool {
 	return u.Scheme == "bzz-hash"
 }
 
+func (u *URI) Prefetch() bool {
+	return u.Scheme == "bzz-prefetch"
+}
+
+func (u *URI) Pin() bool {
+	return u.Scheme == "bzz-pin"
+}
+
+func (u *URI) Tag() bool {
+	return u.Scheme == "bzz-tag"
+}
+
+func (u *URI) Chunk() bool {
+	return u.Scheme == "bzz-chunk"
+}
+
 func (u *URI) String() string {
 	return u.Scheme + ":/" + u.Addr + "/" + u.Path
 }
@@ -0,0 +1,270 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+/*
+Package capture records the messages of the swarm protocols (bzz, hive,
+stream, pss) exchanged with peers to rotating capture files, for debugging
+interoperability issues between versions.
+
+A capture file starts with a magic header followed by a sequence of RLP
+encoded records. Payloads are truncated to a configurable size.
+*/
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+const (
+	DefaultMaxSize     = 64 * 1024 * 1024 // size a capture file is rotated at
+	DefaultMaxFiles    = 4                // number of rotated capture files kept
+	DefaultPayloadSize = 256              // number of payload bytes recorded
+
+	// queueSize is the number of records buffered for writing, records are
+	// dropped rather than slowing down the protocols when it is full
+	queueSize = 1024
+)
+
+var (
+	magic = []byte("swarmcap\x01")
+
+	errInvalidHeader = errors.New("not a swarm capture file")
+
+	droppedCount = metrics.NewRegisteredCounter("capture.dropped.count", nil)
+)
+
+// Record is a protocol message sent to or received from a peer
+type Record struct {
+	Time     uint64 // unix time in nanoseconds
+	Protocol string
+	Version  uint64
+	Peer     discover.NodeID
+	Outbound bool
+	Code     uint64
+	Type     string
+	Size     uint64 // size of the RLP encoded payload
+	Payload  []byte // payload truncated to the configured size
+}
+
+// String returns a one line summary of the record
+func (r *Record) String() string {
+	dir := "<-"
+	if r.Outbound {
+		dir = "->"
+	}
+	return fmt.Sprintf("%s %s/%d %s %s code=%d size=%d payload=%x", dir, r.Protocol, r.Version, r.Peer.TerminalString(), r.Type, r.Code, r.Size, r.Payload)
+}
+
+// Config is the configuration of a capture Writer
+type Config struct {
+	Path        string // path of the current capture file, rotated files get a numeric suffix
+	MaxSize     int64  // size in bytes a capture file is rotated at
+	MaxFiles    int    // number of rotated files kept besides the current one
+	PayloadSize int    // number of payload bytes recorded
+}
+
+// NewConfig returns a capture config with default values writing to path
+func NewConfig(path string) *Config {
+	return &Config{
+		Path:        path,
+		MaxSize:     DefaultMaxSize,
+		MaxFiles:    DefaultMaxFiles,
+		PayloadSize: DefaultPayloadSize,
+	}
+}
+
+// Writer is a protocols.Tracer recording messages to rotating capture files
+type Writer struct {
+	config  *Config
+	records chan *Record
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	file    *os.File
+	buf     *bufio.Writer
+	written int64
+}
+
+// NewWriter creates a Writer, the capture file is truncated if it exists
+func NewWriter(config *Config) (*Writer, error) {
+	w := &Writer{
+		config:  config,
+		records: make(chan *Record, queueSize),
+		quit:    make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+// Trace implements protocols.Tracer
+func (w *Writer) Trace(ev *protocols.TraceEvent) {
+	payload := ev.Payload
+	if len(payload) > w.config.PayloadSize {
+		payload = payload[:w.config.PayloadSize]
+	}
+	r := &Record{
+		Time:     uint64(ev.Time.UnixNano()),
+		Protocol: ev.Protocol,
+		Version:  uint64(ev.Version),
+		Peer:     ev.Peer,
+		Outbound: ev.Outbound,
+		Code:     ev.Code,
+		Type:     ev.Type,
+		Size:     uint64(ev.Size),
+		Payload:  append([]byte(nil), payload...),
+	}
+	select {
+	case w.records <- r:
+	default:
+		droppedCount.Inc(1)
+	}
+}
+
+// Close writes the buffered records and closes the capture file
+func (w *Writer) Close() error {
+	close(w.quit)
+	w.wg.Wait()
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *Writer) loop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case r := <-w.records:
+			if err := w.write(r); err != nil {
+				log.Error("Failed to write capture record", "err", err)
+			}
+			// flush once the queue is drained
+			if len(w.records) == 0 {
+				if err := w.buf.Flush(); err != nil {
+					log.Error("Failed to flush capture file", "err", err)
+				}
+			}
+		case <-w.quit:
+			for {
+				select {
+				case r := <-w.records:
+					if err := w.write(r); err != nil {
+						log.Error("Failed to write capture record", "err", err)
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *Writer) write(r *Record) error {
+	data, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return err
+	}
+	if w.written+int64(len(data)) > w.config.MaxSize && w.written > int64(len(magic)) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.buf.Write(data)
+	w.written += int64(n)
+	return err
+}
+
+// open creates the current capture file and writes the header
+func (w *Writer) open() error {
+	f, err := os.Create(w.config.Path)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	n, err := w.buf.Write(magic)
+	w.written = int64(n)
+	return err
+}
+
+// rotate closes the current capture file, shifts the suffixes of the
+// rotated ones dropping the oldest and opens a new capture file
+func (w *Writer) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	for i := w.config.MaxFiles - 1; i > 0; i-- {
+		from := rotatedPath(w.config.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, rotatedPath(w.config.Path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if w.config.MaxFiles > 0 {
+		if err := os.Rename(w.config.Path, rotatedPath(w.config.Path, 1)); err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
+
+func rotatedPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// Reader decodes the records of a capture file
+type Reader struct {
+	stream *rlp.Stream
+}
+
+// NewReader checks the capture file header and returns a Reader
+func NewReader(r io.Reader) (*Reader, error) {
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header, magic) {
+		return nil, errInvalidHeader
+	}
+	return &Reader{stream: rlp.NewStream(r, 0)}, nil
+}
+
+// Next returns the next record, io.EOF at the end of the capture file
+func (r *Reader) Next() (*Record, error) {
+	rec := &Record{}
+	if err := r.stream.Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
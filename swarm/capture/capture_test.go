@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package capture
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+)
+
+func readCapture(t *testing.T, path string) []*Record {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := NewConfig(filepath.Join(dir, "bzz.cap"))
+	config.PayloadSize = 8
+	config.MaxSize = 400
+	config.MaxFiles = 2
+	w, err := NewWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := discover.NodeID{1, 2, 3}
+	n := 20
+	for i := 0; i < n; i++ {
+		w.Trace(&protocols.TraceEvent{
+			Time:     time.Now(),
+			Protocol: "stream",
+			Version:  5,
+			Peer:     peer,
+			Outbound: i%2 == 0,
+			Code:     uint64(i),
+			Type:     "OfferedHashesMsg",
+			Size:     32,
+			Payload:  bytes.Repeat([]byte{byte(i)}, 32),
+		})
+		// give the writer a chance so that no record is dropped
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(rotatedPath(config.Path, 3)); !os.IsNotExist(err) {
+		t.Fatalf("expected at most %d rotated files", config.MaxFiles)
+	}
+	// records are in order across the rotated files
+	var records []*Record
+	for i := config.MaxFiles; i > 0; i-- {
+		records = append(records, readCapture(t, rotatedPath(config.Path, i))...)
+	}
+	records = append(records, readCapture(t, config.Path)...)
+	if len(records) == 0 || len(records) >= n {
+		t.Fatalf("expected the oldest records to be rotated out, got %d records", len(records))
+	}
+	last := records[len(records)-1]
+	if last.Code != uint64(n-1) {
+		t.Fatalf("expected last record %d, got %d", n-1, last.Code)
+	}
+	for i, rec := range records {
+		if i > 0 && rec.Code != records[i-1].Code+1 {
+			t.Fatalf("records out of order: %d after %d", rec.Code, records[i-1].Code)
+		}
+		if rec.Peer != peer || rec.Protocol != "stream" || rec.Size != 32 || rec.Outbound != (rec.Code%2 == 0) {
+			t.Fatalf("unexpected record %v", rec)
+		}
+		if !bytes.Equal(rec.Payload, bytes.Repeat([]byte{byte(rec.Code)}, 8)) {
+			t.Fatalf("expected truncated payload, got %x", rec.Payload)
+		}
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("not a capture file"))); err != errInvalidHeader {
+		t.Fatalf("expected %v, got %v", errInvalidHeader, err)
+	}
+}
@@ -44,10 +44,11 @@ var (
 	longrunning = flag.Bool("longrunning", false, "do run long-running tests")
 )
 
-func init() {
+func TestMain(m *testing.M) {
 	flag.Parse()
 	log.PrintOrigins(true)
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(colorable.NewColorableStderr(), log.TerminalFormat(!*rawlog))))
+	os.Exit(m.Run())
 }
 
 type fileInfo struct {
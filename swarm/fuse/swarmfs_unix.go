@@ -121,6 +121,21 @@ func (swarmfs *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	log.Trace("swarmfs mount: traversing manifest map")
 	for suffix, entry := range manifestEntryMap {
 		addr := common.Hex2Bytes(entry.Hash)
+		if entry.Data != nil || len(entry.Parts) > 0 {
+			// content inlined in the manifest and composite content are
+			// stored, so that the file can be read and modified like any
+			// other
+			reader, _ := swarmfs.swarmApi.RetrieveEntry(context.TODO(), &entry.ManifestEntry)
+			var wait func(context.Context) error
+			addr, wait, err = swarmfs.swarmApi.Store(context.TODO(), reader, entry.Size, false)
+			if err == nil {
+				err = wait(context.TODO())
+			}
+			if err != nil {
+				log.Error("swarmfs error storing inline content", "path", suffix, "err", err)
+				return nil, err
+			}
+		}
 		fullpath := "/" + suffix
 		basepath := filepath.Dir(fullpath)
 		parentDir := rootDir
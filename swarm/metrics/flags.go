@@ -92,3 +92,21 @@ func Setup(ctx *cli.Context) {
 		}
 	}
 }
+
+// Report posts the collected metrics to InfluxDB once if the export is
+// enabled, for short-lived commands exiting before the periodic export
+func Report(ctx *cli.Context, namespace string) error {
+	if !gethmetrics.Enabled || !ctx.GlobalBool(metricsEnableInfluxDBExportFlag.Name) {
+		return nil
+	}
+	var (
+		endpoint = ctx.GlobalString(metricsInfluxDBEndpointFlag.Name)
+		database = ctx.GlobalString(metricsInfluxDBDatabaseFlag.Name)
+		username = ctx.GlobalString(metricsInfluxDBUsernameFlag.Name)
+		password = ctx.GlobalString(metricsInfluxDBPasswordFlag.Name)
+		hosttag  = ctx.GlobalString(metricsInfluxDBHostTagFlag.Name)
+	)
+	return influxdb.InfluxDBWithTagsOnce(gethmetrics.DefaultRegistry, endpoint, database, username, password, namespace, map[string]string{
+		"host": hosttag,
+	})
+}
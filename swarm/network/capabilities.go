@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability is a feature of a node advertised in the bzz handshake
+type Capability uint
+
+const (
+	CapabilityStorer     Capability = iota // stores and serves chunks of its neighbourhood
+	CapabilityLight                        // does not store chunks for others
+	CapabilityPushSync                     // accepts chunks pushed to their neighbourhood
+	CapabilityPss                          // forwards pss messages
+	CapabilityEncryption                   // supports encrypted content
+)
+
+var capabilityNames = []string{"storer", "light", "pushsync", "pss", "encryption"}
+
+// String returns the name of the capability
+func (c Capability) String() string {
+	if int(c) < len(capabilityNames) {
+		return capabilityNames[c]
+	}
+	return "unknown"
+}
+
+// Capabilities is a bit vector of node capabilities
+type Capabilities uint64
+
+// NewCapabilities returns the bit vector with the given capabilities set
+func NewCapabilities(caps ...Capability) Capabilities {
+	var c Capabilities
+	for _, cap := range caps {
+		c |= 1 << cap
+	}
+	return c
+}
+
+// Has returns true if all the given capabilities are set
+func (c Capabilities) Has(caps ...Capability) bool {
+	return c.Contains(NewCapabilities(caps...))
+}
+
+// Contains returns true if all capabilities set in other are set
+func (c Capabilities) Contains(other Capabilities) bool {
+	return c&other == other
+}
+
+// Names returns the names of the capabilities
+func (c Capabilities) Names() []string {
+	var names []string
+	for i := range capabilityNames {
+		if c.Has(Capability(i)) {
+			names = append(names, Capability(i).String())
+		}
+	}
+	return names
+}
+
+// String returns a comma separated list of capability names
+func (c Capabilities) String() string {
+	return strings.Join(c.Names(), ",")
+}
+
+// ParseCapabilities parses a comma separated list of capability names
+func ParseCapabilities(s string) (Capabilities, error) {
+	var c Capabilities
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found bool
+		for i, n := range capabilityNames {
+			if n == name {
+				c |= NewCapabilities(Capability(i))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown capability %q, expected one of %s", name, strings.Join(capabilityNames, ","))
+		}
+	}
+	return c, nil
+}
+
+// MarshalText encodes the capabilities as a list of names in the config
+func (c Capabilities) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes the capabilities from a list of names
+func (c *Capabilities) UnmarshalText(text []byte) error {
+	caps, err := ParseCapabilities(string(text))
+	if err != nil {
+		return err
+	}
+	*c = caps
+	return nil
+}
+
+// CapablePeer is implemented by connected peers that advertised
+// their capabilities in the handshake
+type CapablePeer interface {
+	Capabilities() Capabilities
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/swarm/pot"
+)
+
+func TestCapabilities(t *testing.T) {
+	c := NewCapabilities(CapabilityStorer, CapabilityPss)
+	if !c.Has(CapabilityStorer) || !c.Has(CapabilityStorer, CapabilityPss) {
+		t.Fatalf("expected %v to have storer and pss", c)
+	}
+	if c.Has(CapabilityLight) || c.Has(CapabilityPss, CapabilityEncryption) {
+		t.Fatalf("unexpected capabilities in %v", c)
+	}
+	if s := c.String(); s != "storer,pss" {
+		t.Fatalf("expected storer,pss, got %s", s)
+	}
+	if !c.Contains(0) {
+		t.Fatal("expected to contain no capabilities")
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	c, err := ParseCapabilities("storer, pss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != NewCapabilities(CapabilityStorer, CapabilityPss) {
+		t.Fatalf("unexpected capabilities %v", c)
+	}
+	if c, err := ParseCapabilities(""); err != nil || c != 0 {
+		t.Fatalf("expected no capabilities, got %v %v", c, err)
+	}
+	if _, err := ParseCapabilities("storer,swap"); err == nil {
+		t.Fatal("expected an error parsing an unknown capability")
+	}
+
+	// the capabilities are written to the config as names
+	text, err := c.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Capabilities
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != c {
+		t.Fatalf("expected %v, got %v", c, decoded)
+	}
+}
+
+// TestKademliaIncapable tests that the known peers lacking the required
+// capabilities are not suggested for connection
+func TestKademliaIncapable(t *testing.T) {
+	k := NewKademlia(pot.NewAddressFromString("00000000"), NewKadParams())
+	incapable := testKadPeerAddr("10000000")
+	if err := k.Register([]OverlayAddr{incapable}); err != nil {
+		t.Fatal(err)
+	}
+	k.Incapable(incapable.Address())
+	if a, _, _ := k.SuggestPeer(); a != nil {
+		t.Fatalf("expected no peer to be suggested, got %v", a)
+	}
+
+	capable := testKadPeerAddr("01000000")
+	if err := k.Register([]OverlayAddr{capable}); err != nil {
+		t.Fatal(err)
+	}
+	a, _, _ := k.SuggestPeer()
+	if a == nil || !bytes.Equal(a.Address(), capable.Address()) {
+		t.Fatalf("expected %v to be suggested, got %v", capable, a)
+	}
+}
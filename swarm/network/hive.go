@@ -18,6 +18,7 @@ package network
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/state"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
 )
 
 /*
@@ -63,6 +65,8 @@ type HiveParams struct {
 	PeersBroadcastSetSize uint8 // how many peers to use when relaying
 	MaxPeersPerRequest    uint8 // max size for peer address batches
 	KeepAliveInterval     time.Duration
+	RequiredCapabilities  Capabilities  // capabilities peers must advertise to connect
+	PersistInterval       time.Duration // interval the known peers are saved at, 0 saves them only on stop
 }
 
 // NewHiveParams returns hive config with only the
@@ -72,6 +76,7 @@ func NewHiveParams() *HiveParams {
 		PeersBroadcastSetSize: 3,
 		MaxPeersPerRequest:    5,
 		KeepAliveInterval:     500 * time.Millisecond,
+		PersistInterval:       time.Minute,
 	}
 }
 
@@ -84,6 +89,8 @@ type Hive struct {
 	// bookkeeping
 	lock   sync.Mutex
 	ticker *time.Ticker
+	quit   chan struct{}
+	wg     sync.WaitGroup
 }
 
 // NewHive constructs a new hive
@@ -104,19 +111,35 @@ func NewHive(params *HiveParams, overlay Overlay, store state.Store) *Hive {
 func (h *Hive) Start(server *p2p.Server) error {
 	log.Info(fmt.Sprintf("%08x hive starting", h.BaseAddr()[:4]))
 	// if state store is specified, load peers to prepopulate the overlay address book
+	var conns []*BzzAddr
 	if h.Store != nil {
 		log.Info("detected an existing store. trying to load peers")
 		if err := h.loadPeers(); err != nil {
 			log.Error(fmt.Sprintf("%08x hive encoutered an error trying to load peers", h.BaseAddr()[:4]))
 			return err
 		}
+		var err error
+		if conns, err = h.loadConns(); err != nil {
+			log.Error(fmt.Sprintf("%08x hive encoutered an error trying to load connections", h.BaseAddr()[:4]))
+			return err
+		}
 	}
 	// assigns the p2p.Server#AddPeer function to connect to peers
 	h.addPeer = server.AddPeer
+	// reconnect to the peers of the last session before suggesting new ones
+	h.reconnect(conns)
+	h.quit = make(chan struct{})
+	if h.Store != nil && h.PersistInterval > 0 {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			supervisor.Run("hive.persist", h.quit, h.persist)
+		}()
+	}
 	// ticker to keep the hive alive
 	h.ticker = time.NewTicker(h.KeepAliveInterval)
 	// this loop is doing bootstrapping and maintains a healthy table
-	go h.connect()
+	supervisor.Go("hive.connect", h.quit, h.connect)
 	return nil
 }
 
@@ -124,6 +147,8 @@ func (h *Hive) Start(server *p2p.Server) error {
 func (h *Hive) Stop() error {
 	log.Info(fmt.Sprintf("%08x hive stopping, saving peers", h.BaseAddr()[:4]))
 	h.ticker.Stop()
+	close(h.quit)
+	h.wg.Wait()
 	if h.Store != nil {
 		if err := h.savePeers(); err != nil {
 			return fmt.Errorf("could not save peers to persistence store: %v", err)
@@ -143,6 +168,46 @@ func (h *Hive) Stop() error {
 	return nil
 }
 
+// persist saves the known peers periodically so that they survive a crash
+func (h *Hive) persist() {
+	ticker := time.NewTicker(h.PersistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.savePeers(); err != nil {
+				log.Warn(fmt.Sprintf("%08x hive could not persist peers: %v", h.BaseAddr()[:4], err))
+			}
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// reconnect dials the peers connected in the last session, shallow bins
+// first, so that kademlia saturation recovers without waiting for
+// the hive to suggest them one by one
+func (h *Hive) reconnect(conns []*BzzAddr) {
+	if len(conns) == 0 {
+		return
+	}
+	base := h.BaseAddr()
+	sort.SliceStable(conns, func(i, j int) bool {
+		poi, _ := pof(conns[i], base, 0)
+		poj, _ := pof(conns[j], base, 0)
+		return poi < poj
+	})
+	log.Info(fmt.Sprintf("%08x hive reconnecting to %d peers", base[:4], len(conns)))
+	for _, a := range conns {
+		under, err := discover.ParseNode(string(a.Under()))
+		if err != nil {
+			log.Warn(fmt.Sprintf("%08x unable to reconnect to bee %08x: invalid node URL: %v", base[:4], a.Address()[:4], err))
+			continue
+		}
+		h.addPeer(under)
+	}
+}
+
 // connect is a forever loop
 // at each iteration, ask the overlay driver to suggest the most preferred peer to connect to
 // as well as advertises saturation depth if needed
@@ -241,6 +306,16 @@ func toOverlayAddrs(as ...*BzzAddr) (oas []OverlayAddr) {
 	return
 }
 
+// loadConns returns the peers connected at the end of the last session
+func (h *Hive) loadConns() ([]*BzzAddr, error) {
+	var as []*BzzAddr
+	err := h.Store.Get("conns", &as)
+	if err == state.ErrNotFound {
+		return nil, nil
+	}
+	return as, err
+}
+
 // savePeers, savePeer implement persistence callback/
 func (h *Hive) savePeers() error {
 	var peers []*BzzAddr
@@ -257,5 +332,13 @@ func (h *Hive) savePeers() error {
 	if err := h.Store.Put("peers", peers); err != nil {
 		return fmt.Errorf("could not save peers: %v", err)
 	}
+	var conns []*BzzAddr
+	h.Overlay.EachConn(nil, 256, func(p OverlayConn, _ int, _ bool) bool {
+		conns = append(conns, ToAddr(p))
+		return true
+	})
+	if err := h.Store.Put("conns", conns); err != nil {
+		return fmt.Errorf("could not save connections: %v", err)
+	}
 	return nil
 }
@@ -17,11 +17,14 @@
 package network
 
 import (
+	"bytes"
 	"io/ioutil"
 	"log"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
 	"github.com/ethereum/go-ethereum/swarm/state"
 )
@@ -106,3 +109,61 @@ func TestHiveStatePersistance(t *testing.T) {
 		t.Fatalf("invalid peers loaded")
 	}
 }
+
+func TestHiveReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hive_test_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := state.NewDBStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := NewHiveParams()
+	s, pp := newHiveTester(t, params, 1, store)
+	raddr := NewAddrFromNodeID(s.IDs[0])
+	pp.Register([]OverlayAddr{OverlayAddr(raddr)})
+
+	if err := pp.Start(s.Server); err != nil {
+		t.Fatal(err)
+	}
+	// wait for the hive to connect to the peer
+	connected := false
+	for i := 0; i < 100 && !connected; i++ {
+		time.Sleep(50 * time.Millisecond)
+		pp.EachConn(nil, 256, func(OverlayConn, int, bool) bool {
+			connected = true
+			return false
+		})
+	}
+	if !connected {
+		t.Fatal("expected peer to connect")
+	}
+	pp.Stop()
+
+	persistedStore, err := state.NewDBStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer persistedStore.Close()
+	_, pp = newHiveTester(t, params, 1, persistedStore)
+	conns, err := pp.loadConns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 1 || !bytes.Equal(conns[0].OAddr, raddr.OAddr) {
+		t.Fatalf("expected persisted connection to %v, got %v", raddr, conns)
+	}
+
+	// the peers of the last session are dialed right away
+	var dialed []discover.NodeID
+	pp.addPeer = func(n *discover.Node) {
+		dialed = append(dialed, n.ID)
+	}
+	pp.reconnect(conns)
+	if len(dialed) != 1 || dialed[0] != s.IDs[0] {
+		t.Fatalf("expected to dial %v, got %v", s.IDs[0], dialed)
+	}
+}
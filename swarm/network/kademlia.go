@@ -18,8 +18,10 @@ package network
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -51,13 +53,17 @@ node from the other.
 
 var pof = pot.DefaultPof(256)
 
+var errPruned = errors.New("pruned from kademlia table")
+
 // KadParams holds the config params for Kademlia
 type KadParams struct {
 	// adjustable parameters
 	MaxProxDisplay int   // number of rows the table shows
 	MinProxBinSize int   // nearest neighbour core minimum cardinality
 	MinBinSize     int   // minimum number of peers in a row
-	MaxBinSize     int   // maximum number of peers in a row before pruning
+	MaxBinSize     int   // maximum number of peers in a row before pruning, 0 for no limit
+	MaxPeers       int   // maximum number of peers before pruning, 0 for no limit
+	Prune          bool  // drop the least useful peers when over MaxBinSize or MaxPeers
 	RetryInterval  int64 // initial interval before a peer is first redialed
 	RetryExponent  int   // exponent to multiply retry intervals with
 	MaxRetries     int   // maximum number of redial attempts
@@ -128,8 +134,10 @@ type OverlayAddr interface {
 // entry represents a Kademlia table entry (an extension of OverlayPeer)
 type entry struct {
 	OverlayPeer
-	seenAt  time.Time
-	retries int
+	seenAt    time.Time
+	retries   int
+	pruned    bool // the connection is dropped to prune the table
+	incapable bool // the peer lacks the capabilities required of the peers
 }
 
 // newEntry creates a kademlia peer from an OverlayPeer interface
@@ -217,6 +225,10 @@ func (k *Kademlia) SuggestPeer() (a OverlayAddr, o int, want bool) {
 	defer k.lock.Unlock()
 	minsize := k.MinBinSize
 	depth := k.neighbourhoodDepth()
+	// do not dial new peers once the table is full
+	if k.Prune && k.MaxPeers > 0 && k.conns.Size()-k.prunedCount() >= k.MaxPeers {
+		return nil, 0, false
+	}
 	// if there is a callable neighbour within the current proxBin, connect
 	// this makes sure nearest neighbour set is fully connected
 	var ppo int
@@ -281,10 +293,30 @@ func (k *Kademlia) SuggestPeer() (a OverlayAddr, o int, want bool) {
 	return a, nxt, changed
 }
 
+// Incapable marks the known peer with the given address as lacking the
+// capabilities required of the peers, it is not suggested to connect to
+func (k *Kademlia) Incapable(addr []byte) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.addrs, _, _, _ = pot.Swap(k.addrs, addr, pof, func(v pot.Val) pot.Val {
+		if v != nil {
+			v.(*entry).incapable = true
+		}
+		return v
+	})
+}
+
 // On inserts the peer as a kademlia peer into the live peers
 func (k *Kademlia) On(p OverlayConn) (uint8, bool) {
 	k.lock.Lock()
-	defer k.lock.Unlock()
+	var pruned []OverlayConn
+	defer func() {
+		k.lock.Unlock()
+		// dropping calls back into the overlay, so it is done unlocked
+		for _, c := range pruned {
+			c.Drop(errPruned)
+		}
+	}()
 	e := newEntry(p)
 	var ins bool
 	k.conns, _, _, _ = pot.Swap(k.conns, p, pof, func(v pot.Val) pot.Val {
@@ -306,6 +338,9 @@ func (k *Kademlia) On(p OverlayConn) (uint8, bool) {
 		if k.addrCountC != nil {
 			k.addrCountC <- k.addrs.Size()
 		}
+		if k.Prune {
+			pruned = k.prune()
+		}
 	}
 	log.Trace(k.string())
 	// calculate if depth of saturation changed
@@ -319,6 +354,89 @@ func (k *Kademlia) On(p OverlayConn) (uint8, bool) {
 	return k.depth, changed
 }
 
+// prune marks the least useful connections of the bins over MaxBinSize and
+// of the whole table over MaxPeers and returns them to be dropped, peers in
+// the nearest neighbourhood are never pruned
+// caller must hold the lock
+func (k *Kademlia) prune() (pruned []OverlayConn) {
+	depth := k.neighbourhoodDepth()
+	var total int
+	bins := make(map[int][]*entry)
+	k.conns.EachBin(k.base, pof, 0, func(po, _ int, f func(func(val pot.Val, i int) bool) bool) bool {
+		return f(func(val pot.Val, _ int) bool {
+			e := val.(*entry)
+			if e.pruned {
+				return true
+			}
+			total++
+			if po < depth {
+				bins[po] = append(bins[po], e)
+			}
+			return true
+		})
+	})
+	// least useful first: light nodes, then the most recently connected
+	for _, es := range bins {
+		sort.SliceStable(es, func(i, j int) bool {
+			li, lj := isLight(es[i]), isLight(es[j])
+			if li != lj {
+				return li
+			}
+			return es[i].seenAt.After(es[j].seenAt)
+		})
+	}
+	evict := func(po int) {
+		e := bins[po][0]
+		bins[po] = bins[po][1:]
+		e.pruned = true
+		total--
+		pruned = append(pruned, e.conn())
+		log.Debug(fmt.Sprintf("%08x pruning peer %08x in bin %d", k.BaseAddr()[:4], e.Address()[:4], po))
+	}
+	if k.MaxBinSize > 0 {
+		for po, es := range bins {
+			for len(es) > k.MaxBinSize {
+				evict(po)
+				es = bins[po]
+			}
+		}
+	}
+	if k.MaxPeers > 0 {
+		for total > k.MaxPeers {
+			// prune from the most populated bin below depth
+			max := -1
+			for po, es := range bins {
+				if len(es) > 0 && (max < 0 || len(es) > len(bins[max]) || len(es) == len(bins[max]) && po < max) {
+					max = po
+				}
+			}
+			if max < 0 {
+				break
+			}
+			evict(max)
+		}
+	}
+	return pruned
+}
+
+// prunedCount returns the number of connections being dropped by pruning
+// caller must hold the lock
+func (k *Kademlia) prunedCount() (n int) {
+	k.conns.EachNeighbour(k.base, pof, func(val pot.Val, _ int) bool {
+		if val.(*entry).pruned {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// isLight reports whether the peer of the entry advertised to be a light node
+func isLight(e *entry) bool {
+	p, ok := e.OverlayPeer.(CapablePeer)
+	return ok && p.Capabilities().Has(CapabilityLight)
+}
+
 // NeighbourhoodDepthC returns the channel that sends a new kademlia
 // neighbourhood depth on each change.
 // Not receiving from the returned channel will block On function
@@ -475,8 +593,9 @@ func (k *Kademlia) neighbourhoodDepth() (depth int) {
 // callable when called with val,
 func (k *Kademlia) callable(val pot.Val) OverlayAddr {
 	e := val.(*entry)
-	// not callable if peer is live or exceeded maxRetries
-	if e.conn() != nil || e.retries > k.MaxRetries {
+	// not callable if peer is live, lacks the required capabilities or
+	// exceeded maxRetries
+	if e.conn() != nil || e.incapable || e.retries > k.MaxRetries {
 		return nil
 	}
 	// calculate the allowed number of retries based on time lapsed since last seen
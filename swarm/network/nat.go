@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+var (
+	// natDialTimeout is the timeout of the self-dial reachability test
+	natDialTimeout = 5 * time.Second
+	// natMapTimeout is the lifetime of the port mapping refreshed by the
+	// diagnostics, the same as the one kept alive by the p2p server
+	natMapTimeout = 20 * time.Minute
+
+	errNotStarted = errors.New("p2p server not started")
+)
+
+// NATStatus reports the reachability of the node's underlay address
+type NATStatus struct {
+	ListenAddr string   `json:"listenAddr"`
+	Underlay   []string `json:"underlay"`   // externally reachable underlay addresses
+	NAT        string   `json:"nat"`        // NAT port mapper in use, empty if none
+	ExternalIP string   `json:"externalIP"` // external IP reported by the NAT port mapper
+	Mapped     bool     `json:"mapped"`     // the listening port is mapped by the NAT port mapper
+	Public     bool     `json:"public"`     // the underlay IP is reachable from the internet
+	Reachable  bool     `json:"reachable"`  // the self-dial of the underlay address succeeded
+	Errors     []string `json:"errors"`
+}
+
+// NATAPI provides NAT traversal diagnostics in the bzz namespace
+type NATAPI struct {
+	bzz *Bzz
+}
+
+// NatStatus returns the externally reachable underlay addresses, the port
+// mapping status and the result of dialing the node's own underlay address
+func (api *NATAPI) NatStatus() (*NATStatus, error) {
+	api.bzz.mtx.Lock()
+	srv := api.bzz.server
+	api.bzz.mtx.Unlock()
+	if srv == nil {
+		return nil, errNotStarted
+	}
+	return natStatus(srv), nil
+}
+
+// natStatus runs the NAT diagnostics of a running p2p server
+func natStatus(srv *p2p.Server) *NATStatus {
+	s := &NATStatus{
+		ListenAddr: srv.ListenAddr,
+		Errors:     []string{},
+	}
+	fail := func(format string, args ...interface{}) {
+		s.Errors = append(s.Errors, fmt.Sprintf(format, args...))
+	}
+
+	self := srv.Self()
+	ip := self.IP
+	port := int(self.TCP)
+	if srv.NAT != nil {
+		s.NAT = srv.NAT.String()
+		if ext, err := srv.NAT.ExternalIP(); err != nil {
+			fail("external IP: %v", err)
+		} else {
+			s.ExternalIP = ext.String()
+			ip = ext
+		}
+		if port != 0 {
+			if err := srv.NAT.AddMapping("tcp", port, port, "ethereum p2p", natMapTimeout); err != nil {
+				fail("port mapping: %v", err)
+			} else {
+				s.Mapped = true
+			}
+		}
+	}
+	if port == 0 {
+		fail("inbound connections disabled")
+		return s
+	}
+	if ip == nil || ip.IsUnspecified() {
+		fail("no external IP, configure a NAT port mapper with --nat")
+		return s
+	}
+	self.IP = ip
+	s.Underlay = append(s.Underlay, self.String())
+	s.Public = !ip.IsLoopback() && !netutil.IsLAN(ip) && !netutil.IsSpecialNetwork(ip)
+	if !s.Public {
+		fail("underlay IP %v is not reachable from the internet", ip)
+	}
+
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, natDialTimeout)
+	if err != nil {
+		fail("self-dial %s: %v", addr, err)
+		return s
+	}
+	conn.Close()
+	s.Reachable = true
+	return s
+}
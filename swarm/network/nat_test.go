@@ -0,0 +1,68 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+)
+
+func TestNATStatus(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &p2p.Server{
+		Config: p2p.Config{
+			PrivateKey:  key,
+			MaxPeers:    1,
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			NAT:         nat.ExtIP(net.ParseIP("127.0.0.1")),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s := natStatus(srv)
+	if !s.Mapped {
+		t.Fatalf("expected port to be mapped, errors: %v", s.Errors)
+	}
+	if s.ExternalIP != "127.0.0.1" {
+		t.Fatalf("expected external IP 127.0.0.1, got %q", s.ExternalIP)
+	}
+	if len(s.Underlay) != 1 {
+		t.Fatalf("expected 1 underlay address, got %v", s.Underlay)
+	}
+	if s.Public {
+		t.Fatal("expected loopback underlay not to be public")
+	}
+	if !s.Reachable {
+		t.Fatalf("expected self-dial to succeed, errors: %v", s.Errors)
+	}
+
+	// once stopped the underlay can not be dialed
+	srv.Stop()
+	s = natStatus(srv)
+	if s.Reachable {
+		t.Fatal("expected stopped server not to be reachable")
+	}
+}
@@ -19,7 +19,6 @@ package network
 import (
 	"bytes"
 	"context"
-	"flag"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -48,7 +47,6 @@ const (
 )
 
 func init() {
-	flag.Parse()
 	rand.Seed(time.Now().Unix())
 }
 
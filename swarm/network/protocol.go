@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -28,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/protocols"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/state"
@@ -44,13 +46,28 @@ const (
 // BzzSpec is the spec of the generic swarm handshake
 var BzzSpec = &protocols.Spec{
 	Name:       "bzz",
-	Version:    4,
+	Version:    5,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		HandshakeMsg{},
 	},
 }
 
+const (
+	// MinBzzVersion is the oldest version of the bzz handshake run with
+	// peers, the newest version shared with a peer is negotiated
+	MinBzzVersion = 4
+	// capabilitiesVersion is the version of the bzz handshake introducing
+	// the capabilities, the peers of older versions are assumed to have
+	// the legacyCapabilities
+	capabilitiesVersion = 5
+)
+
+// legacyCapabilities are the capabilities of the nodes of the versions before
+// capabilitiesVersion, which all store the chunks of their neighbourhood and
+// support encrypted content
+var legacyCapabilities = NewCapabilities(CapabilityStorer, CapabilityEncryption)
+
 // DiscoverySpec is the spec for the bzz discovery subprotocols
 var DiscoverySpec = &protocols.Spec{
 	Name:       "hive",
@@ -94,17 +111,21 @@ type BzzConfig struct {
 	UnderlayAddr []byte // node's underlay address
 	HiveParams   *HiveParams
 	NetworkID    uint64
+	Capabilities Capabilities // capabilities advertised in the handshake
 }
 
 // Bzz is the swarm protocol bundle
 type Bzz struct {
 	*Hive
 	NetworkID    uint64
+	Capabilities Capabilities
 	localAddr    *BzzAddr
 	mtx          sync.Mutex
 	handshakes   map[discover.NodeID]*HandshakeMsg
-	streamerSpec *protocols.Spec
+	protocols    map[discover.NodeID]map[string]*ProtocolInfo // subprotocols run with the connected peers
+	streamerSpec *VersionedSpec
 	streamerRun  func(*BzzPeer) error
+	server       *p2p.Server
 }
 
 // NewBzz is the swarm protocol constructor
@@ -112,12 +133,14 @@ type Bzz struct {
 // * bzz config
 // * overlay driver
 // * peer store
-func NewBzz(config *BzzConfig, kad Overlay, store state.Store, streamerSpec *protocols.Spec, streamerRun func(*BzzPeer) error) *Bzz {
+func NewBzz(config *BzzConfig, kad Overlay, store state.Store, streamerSpec *VersionedSpec, streamerRun func(*BzzPeer) error) *Bzz {
 	return &Bzz{
 		Hive:         NewHive(config.HiveParams, kad, store),
 		NetworkID:    config.NetworkID,
+		Capabilities: config.Capabilities,
 		localAddr:    &BzzAddr{config.OverlayAddr, config.UnderlayAddr},
 		handshakes:   make(map[discover.NodeID]*HandshakeMsg),
+		protocols:    make(map[discover.NodeID]map[string]*ProtocolInfo),
 		streamerRun:  streamerRun,
 		streamerSpec: streamerSpec,
 	}
@@ -141,31 +164,38 @@ func (b *Bzz) NodeInfo() interface{} {
 // Bzz implements the node.Service interface
 // * handshake/hive
 // * discovery
+// The protocols run over the RLPx transport of the devp2p server on TCP,
+// which is the only underlay transport of the bzz peers.
 func (b *Bzz) Protocols() []p2p.Protocol {
-	protocol := []p2p.Protocol{
-		{
+	var protocol []p2p.Protocol
+	// the newest version of the handshake shared with the peer is run
+	for version := BzzSpec.Version; version >= MinBzzVersion; version-- {
+		protocol = append(protocol, p2p.Protocol{
 			Name:     BzzSpec.Name,
-			Version:  BzzSpec.Version,
+			Version:  version,
 			Length:   BzzSpec.Length(),
-			Run:      b.runBzz,
+			Run:      b.runBzzVersion(version),
 			NodeInfo: b.NodeInfo,
-		},
-		{
-			Name:     DiscoverySpec.Name,
-			Version:  DiscoverySpec.Version,
-			Length:   DiscoverySpec.Length(),
-			Run:      b.RunProtocol(DiscoverySpec, b.Hive.Run),
-			NodeInfo: b.Hive.NodeInfo,
-			PeerInfo: b.Hive.PeerInfo,
-		},
+		})
 	}
+	protocol = append(protocol, p2p.Protocol{
+		Name:     DiscoverySpec.Name,
+		Version:  DiscoverySpec.Version,
+		Length:   DiscoverySpec.Length(),
+		Run:      b.RunProtocol(DiscoverySpec, b.Hive.Run),
+		NodeInfo: b.Hive.NodeInfo,
+		PeerInfo: b.Hive.PeerInfo,
+	})
 	if b.streamerSpec != nil && b.streamerRun != nil {
-		protocol = append(protocol, p2p.Protocol{
-			Name:    b.streamerSpec.Name,
-			Version: b.streamerSpec.Version,
-			Length:  b.streamerSpec.Length(),
-			Run:     b.RunProtocol(b.streamerSpec, b.streamerRun),
-		})
+		// the newest version shared with the peer is run
+		for _, spec := range b.streamerSpec.Versions() {
+			protocol = append(protocol, p2p.Protocol{
+				Name:    spec.Name,
+				Version: spec.Version,
+				Length:  spec.Length(),
+				Run:     b.runProtocolVersion(b.streamerSpec, spec, b.streamerRun),
+			})
+		}
 	}
 	return protocol
 }
@@ -178,9 +208,25 @@ func (b *Bzz) APIs() []rpc.API {
 		Namespace: "hive",
 		Version:   "3.0",
 		Service:   b.Hive,
+	}, {
+		Namespace: "bzz",
+		Version:   "3.0",
+		Service:   &NATAPI{b},
+	}, {
+		Namespace: "bzz",
+		Version:   "3.0",
+		Service:   &FeaturesAPI{b},
 	}}
 }
 
+// Start starts the hive and keeps the p2p server for the NAT diagnostics
+func (b *Bzz) Start(server *p2p.Server) error {
+	b.mtx.Lock()
+	b.server = server
+	b.mtx.Unlock()
+	return b.Hive.Start(server)
+}
+
 // RunProtocol is a wrapper for swarm subprotocols
 // returns a p2p protocol run function that can be assigned to p2p.Protocol#Run field
 // arguments:
@@ -191,6 +237,13 @@ func (b *Bzz) APIs() []rpc.API {
 // the protocol waits for the bzz handshake is negotiated
 // the overlay address on the BzzPeer is set from the remote handshake
 func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*p2p.Peer, p2p.MsgReadWriter) error {
+	return b.runProtocolVersion(&VersionedSpec{Spec: spec}, spec, run)
+}
+
+// runProtocolVersion is RunProtocol for one of the versions of a versioned
+// subprotocol, the version and the features available are recorded on the
+// BzzPeer and for the FeaturesAPI
+func (b *Bzz) runProtocolVersion(versioned *VersionedSpec, spec *protocols.Spec, run func(*BzzPeer) error) func(*p2p.Peer, p2p.MsgReadWriter) error {
 	return func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 		// wait for the bzz protocol to perform the handshake
 		handshake, _ := b.GetHandshake(p.ID())
@@ -204,12 +257,20 @@ func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*
 			return fmt.Errorf("%08x: %s protocol closed: %v", b.BaseAddr()[:4], spec.Name, handshake.err)
 		}
 		// the handshake has succeeded so construct the BzzPeer and run the protocol
+		info := &ProtocolInfo{
+			Version:  spec.Version,
+			Features: versioned.FeaturesAt(spec.Version),
+		}
 		peer := &BzzPeer{
-			Peer:       protocols.NewPeer(p, rw, spec),
-			localAddr:  b.localAddr,
-			BzzAddr:    handshake.peerAddr,
-			lastActive: time.Now(),
+			Peer:         protocols.NewPeer(p, rw, spec),
+			localAddr:    b.localAddr,
+			BzzAddr:      handshake.peerAddr,
+			lastActive:   time.Now(),
+			capabilities: handshake.peerCapabilities,
+			protocol:     info,
 		}
+		b.setProtocol(p.ID(), spec.Name, info)
+		defer b.removeProtocol(p.ID(), spec.Name)
 		return run(peer)
 	}
 }
@@ -228,41 +289,58 @@ func (b *Bzz) performHandshake(p *protocols.Peer, handshake *HandshakeMsg) error
 		return err
 	}
 	handshake.peerAddr = rsh.(*HandshakeMsg).Addr
+	handshake.peerCapabilities = rsh.(*HandshakeMsg).Capabilities
 	return nil
 }
 
 // runBzz is the p2p protocol run function for the bzz base protocol
 // that negotiates the bzz handshake
 func (b *Bzz) runBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-	handshake, _ := b.GetHandshake(p.ID())
-	if !<-handshake.init {
-		return fmt.Errorf("%08x: bzz already started on peer %08x", b.localAddr.Over()[:4], ToOverlayAddr(p.ID().Bytes())[:4])
-	}
-	close(handshake.init)
-	defer b.removeHandshake(p.ID())
-	peer := protocols.NewPeer(p, rw, BzzSpec)
-	err := b.performHandshake(peer, handshake)
-	if err != nil {
-		log.Warn(fmt.Sprintf("%08x: handshake failed with remote peer %08x: %v", b.localAddr.Over()[:4], ToOverlayAddr(p.ID().Bytes())[:4], err))
+	return b.runBzzVersion(BzzSpec.Version)(p, rw)
+}
 
-		return err
-	}
-	// fail if we get another handshake
-	msg, err := rw.ReadMsg()
-	if err != nil {
-		return err
+// runBzzVersion is runBzz for the given version of the bzz handshake, the
+// version and the capabilities of the peer are recorded for the FeaturesAPI
+func (b *Bzz) runBzzVersion(version uint) func(*p2p.Peer, p2p.MsgReadWriter) error {
+	return func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		handshake, _ := b.GetHandshake(p.ID())
+		if !<-handshake.init {
+			return fmt.Errorf("%08x: bzz already started on peer %08x", b.localAddr.Over()[:4], ToOverlayAddr(p.ID().Bytes())[:4])
+		}
+		close(handshake.init)
+		defer b.removeHandshake(p.ID())
+		handshake.Version = uint64(version)
+		peer := protocols.NewPeer(p, rw, BzzSpec)
+		err := b.performHandshake(peer, handshake)
+		if err != nil {
+			log.Warn(fmt.Sprintf("%08x: handshake failed with remote peer %08x: %v", b.localAddr.Over()[:4], ToOverlayAddr(p.ID().Bytes())[:4], err))
+
+			return err
+		}
+		b.setProtocol(p.ID(), BzzSpec.Name, &ProtocolInfo{
+			Version:  version,
+			Features: handshake.peerCapabilities.Names(),
+		})
+		defer b.removeProtocol(p.ID(), BzzSpec.Name)
+		// fail if we get another handshake
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		msg.Discard()
+		return errors.New("received multiple handshakes")
 	}
-	msg.Discard()
-	return errors.New("received multiple handshakes")
 }
 
 // BzzPeer is the bzz protocol view of a protocols.Peer (itself an extension of p2p.Peer)
 // implements the Peer interface and all interfaces Peer implements: Addr, OverlayPeer
 type BzzPeer struct {
-	*protocols.Peer           // represents the connection for online peers
-	localAddr       *BzzAddr  // local Peers address
-	*BzzAddr                  // remote address -> implements Addr interface = protocols.Peer
-	lastActive      time.Time // time is updated whenever mutexes are releasing
+	*protocols.Peer               // represents the connection for online peers
+	localAddr       *BzzAddr      // local Peers address
+	*BzzAddr                      // remote address -> implements Addr interface = protocols.Peer
+	lastActive      time.Time     // time is updated whenever mutexes are releasing
+	capabilities    Capabilities  // capabilities advertised by the remote peer
+	protocol        *ProtocolInfo // version of the protocol negotiated with the peer
 }
 
 func NewBzzTestPeer(p *protocols.Peer, addr *BzzAddr) *BzzPeer {
@@ -278,6 +356,11 @@ func (p *BzzPeer) Off() OverlayAddr {
 	return p.BzzAddr
 }
 
+// Capabilities returns the capabilities the peer advertised in the handshake
+func (p *BzzPeer) Capabilities() Capabilities {
+	return p.capabilities
+}
+
 // LastActive returns the time the peer was last active
 func (p *BzzPeer) LastActive() time.Time {
 	return p.lastActive
@@ -289,14 +372,18 @@ func (p *BzzPeer) LastActive() time.Time {
 * Version: 8 byte integer version of the protocol
 * NetworkID: 8 byte integer network identifier
 * Addr: the address advertised by the node including underlay and overlay connecctions
+* Capabilities: bit vector of the capabilities of the node, from version 5
 */
 type HandshakeMsg struct {
-	Version   uint64
-	NetworkID uint64
-	Addr      *BzzAddr
+	Version      uint64
+	NetworkID    uint64
+	Addr         *BzzAddr
+	Capabilities Capabilities
 
 	// peerAddr is the address received in the peer handshake
 	peerAddr *BzzAddr
+	// peerCapabilities are the capabilities received in the peer handshake
+	peerCapabilities Capabilities
 
 	init chan bool
 	done chan struct{}
@@ -305,7 +392,41 @@ type HandshakeMsg struct {
 
 // String pretty prints the handshake
 func (bh *HandshakeMsg) String() string {
-	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, Addr: %v", bh.Version, bh.NetworkID, bh.Addr)
+	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, Addr: %v, Capabilities: %v", bh.Version, bh.NetworkID, bh.Addr, bh.Capabilities)
+}
+
+// EncodeRLP encodes the handshake, without the capabilities in the versions
+// before capabilitiesVersion
+func (bh *HandshakeMsg) EncodeRLP(w io.Writer) error {
+	if bh.Version < capabilitiesVersion {
+		return rlp.Encode(w, []interface{}{bh.Version, bh.NetworkID, bh.Addr})
+	}
+	return rlp.Encode(w, []interface{}{bh.Version, bh.NetworkID, bh.Addr, bh.Capabilities})
+}
+
+// DecodeRLP decodes the handshake, the capabilities of the versions before
+// capabilitiesVersion are the legacyCapabilities
+func (bh *HandshakeMsg) DecodeRLP(s *rlp.Stream) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	var err error
+	if bh.Version, err = s.Uint(); err != nil {
+		return err
+	}
+	if bh.NetworkID, err = s.Uint(); err != nil {
+		return err
+	}
+	if err := s.Decode(&bh.Addr); err != nil {
+		return err
+	}
+	bh.Capabilities = legacyCapabilities
+	if bh.Version >= capabilitiesVersion {
+		if err := s.Decode(&bh.Capabilities); err != nil {
+			return err
+		}
+	}
+	return s.ListEnd()
 }
 
 // Perform initiates the handshake and validates the remote handshake message
@@ -314,8 +435,15 @@ func (b *Bzz) checkHandshake(hs interface{}) error {
 	if rhs.NetworkID != b.NetworkID {
 		return fmt.Errorf("network id mismatch %d (!= %d)", rhs.NetworkID, b.NetworkID)
 	}
-	if rhs.Version != uint64(BzzSpec.Version) {
-		return fmt.Errorf("version mismatch %d (!= %d)", rhs.Version, BzzSpec.Version)
+	if rhs.Version < MinBzzVersion || rhs.Version > uint64(BzzSpec.Version) {
+		return fmt.Errorf("version mismatch %d (not in %d-%d)", rhs.Version, MinBzzVersion, BzzSpec.Version)
+	}
+	if !rhs.Capabilities.Contains(b.RequiredCapabilities) {
+		// the peer is not dialled again
+		if k, ok := b.Overlay.(*Kademlia); ok && rhs.Addr != nil {
+			k.Incapable(rhs.Addr.Over())
+		}
+		return fmt.Errorf("missing capabilities %v (have %v)", b.RequiredCapabilities&^rhs.Capabilities, rhs.Capabilities)
 	}
 	return nil
 }
@@ -335,11 +463,12 @@ func (b *Bzz) GetHandshake(peerID discover.NodeID) (*HandshakeMsg, bool) {
 	handshake, found := b.handshakes[peerID]
 	if !found {
 		handshake = &HandshakeMsg{
-			Version:   uint64(BzzSpec.Version),
-			NetworkID: b.NetworkID,
-			Addr:      b.localAddr,
-			init:      make(chan bool, 1),
-			done:      make(chan struct{}),
+			Version:      uint64(BzzSpec.Version),
+			NetworkID:    b.NetworkID,
+			Addr:         b.localAddr,
+			Capabilities: b.Capabilities,
+			init:         make(chan bool, 1),
+			done:         make(chan struct{}),
 		}
 		// when handhsake is first created for a remote peer
 		// it is initialised with the init
@@ -17,6 +17,7 @@
 package network
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"os"
@@ -28,15 +29,17 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/protocols"
 	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
 	loglevel = flag.Int("loglevel", 2, "verbosity of logs")
 )
 
-func init() {
+func TestMain(m *testing.M) {
 	flag.Parse()
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(os.Stderr, log.TerminalFormat(true))))
+	os.Exit(m.Run())
 }
 
 type testStore struct {
@@ -130,16 +133,24 @@ type bzzTester struct {
 }
 
 func newBzzHandshakeTester(t *testing.T, n int, addr *BzzAddr) *bzzTester {
+	return newBzzHandshakeTesterWithParams(t, n, addr, NewHiveParams())
+}
+
+func newBzzHandshakeTesterWithParams(t *testing.T, n int, addr *BzzAddr, params *HiveParams) *bzzTester {
+	return newBzzHandshakeTesterVersion(t, n, addr, params, BzzSpec.Version)
+}
+
+func newBzzHandshakeTesterVersion(t *testing.T, n int, addr *BzzAddr, params *HiveParams, version uint) *bzzTester {
 	config := &BzzConfig{
 		OverlayAddr:  addr.Over(),
 		UnderlayAddr: addr.Under(),
-		HiveParams:   NewHiveParams(),
+		HiveParams:   params,
 		NetworkID:    DefaultNetworkID,
 	}
 	kad := NewKademlia(addr.OAddr, NewKadParams())
 	bzz := NewBzz(config, kad, nil, nil, nil)
 
-	s := p2ptest.NewProtocolTester(t, NewNodeIDFromAddr(addr), 1, bzz.runBzz)
+	s := p2ptest.NewProtocolTester(t, NewNodeIDFromAddr(addr), 1, bzz.runBzzVersion(version))
 
 	return &bzzTester{
 		addr:           addr,
@@ -186,7 +197,7 @@ func (s *bzzTester) testHandshake(lhs, rhs *HandshakeMsg, disconnects ...*p2ptes
 
 func correctBzzHandshake(addr *BzzAddr) *HandshakeMsg {
 	return &HandshakeMsg{
-		Version:   4,
+		Version:   5,
 		NetworkID: DefaultNetworkID,
 		Addr:      addr,
 	}
@@ -199,7 +210,7 @@ func TestBzzHandshakeNetworkIDMismatch(t *testing.T) {
 
 	err := s.testHandshake(
 		correctBzzHandshake(addr),
-		&HandshakeMsg{Version: 4, NetworkID: 321, Addr: NewAddrFromNodeID(id)},
+		&HandshakeMsg{Version: 5, NetworkID: 321, Addr: NewAddrFromNodeID(id)},
 		&p2ptest.Disconnect{Peer: id, Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): network id mismatch 321 (!= 3)")},
 	)
 
@@ -216,7 +227,7 @@ func TestBzzHandshakeVersionMismatch(t *testing.T) {
 	err := s.testHandshake(
 		correctBzzHandshake(addr),
 		&HandshakeMsg{Version: 0, NetworkID: 3, Addr: NewAddrFromNodeID(id)},
-		&p2ptest.Disconnect{Peer: id, Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): version mismatch 0 (!= 4)")},
+		&p2ptest.Disconnect{Peer: id, Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): version mismatch 0 (not in 4-5)")},
 	)
 
 	if err != nil {
@@ -231,7 +242,86 @@ func TestBzzHandshakeSuccess(t *testing.T) {
 
 	err := s.testHandshake(
 		correctBzzHandshake(addr),
-		&HandshakeMsg{Version: 4, NetworkID: 3, Addr: NewAddrFromNodeID(id)},
+		&HandshakeMsg{Version: 5, NetworkID: 3, Addr: NewAddrFromNodeID(id)},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBzzHandshakeLegacyVersion tests that the handshake is performed with
+// the peers of the versions without capabilities
+func TestBzzHandshakeLegacyVersion(t *testing.T) {
+	addr := RandomAddr()
+	s := newBzzHandshakeTesterVersion(t, 1, addr, NewHiveParams(), MinBzzVersion)
+	id := s.IDs[0]
+
+	err := s.testHandshake(
+		&HandshakeMsg{Version: MinBzzVersion, NetworkID: DefaultNetworkID, Addr: addr},
+		&HandshakeMsg{Version: MinBzzVersion, NetworkID: 3, Addr: NewAddrFromNodeID(id)},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandshakeMsgLegacyEncoding tests that the handshake of the versions
+// before capabilitiesVersion is encoded without the capabilities and is
+// decoded with the legacyCapabilities
+func TestHandshakeMsgLegacyEncoding(t *testing.T) {
+	addr := RandomAddr()
+	legacy := struct {
+		Version   uint64
+		NetworkID uint64
+		Addr      *BzzAddr
+	}{MinBzzVersion, DefaultNetworkID, addr}
+	expected, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rlp.EncodeToBytes(&HandshakeMsg{Version: MinBzzVersion, NetworkID: DefaultNetworkID, Addr: addr, Capabilities: NewCapabilities(CapabilityLight)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("expected legacy encoding %x, got %x", expected, data)
+	}
+
+	var hs HandshakeMsg
+	if err := rlp.DecodeBytes(expected, &hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.Version != MinBzzVersion || !bytes.Equal(hs.Addr.Over(), addr.Over()) || hs.Capabilities != legacyCapabilities {
+		t.Fatalf("unexpected decoded handshake %v", &hs)
+	}
+
+	// the capabilities of the current version are kept
+	data, err = rlp.EncodeToBytes(&HandshakeMsg{Version: uint64(BzzSpec.Version), NetworkID: DefaultNetworkID, Addr: addr, Capabilities: NewCapabilities(CapabilityLight)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs = HandshakeMsg{}
+	if err := rlp.DecodeBytes(data, &hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.Capabilities != NewCapabilities(CapabilityLight) {
+		t.Fatalf("expected capabilities light, got %v", hs.Capabilities)
+	}
+}
+
+func TestBzzHandshakeMissingCapabilities(t *testing.T) {
+	addr := RandomAddr()
+	params := NewHiveParams()
+	params.RequiredCapabilities = NewCapabilities(CapabilityStorer)
+	s := newBzzHandshakeTesterWithParams(t, 1, addr, params)
+	id := s.IDs[0]
+
+	err := s.testHandshake(
+		correctBzzHandshake(addr),
+		&HandshakeMsg{Version: 5, NetworkID: 3, Addr: NewAddrFromNodeID(id), Capabilities: NewCapabilities(CapabilityLight)},
+		&p2ptest.Disconnect{Peer: id, Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): missing capabilities storer (have light)")},
 	)
 
 	if err != nil {
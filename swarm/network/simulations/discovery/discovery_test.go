@@ -94,13 +94,16 @@ var (
 )
 
 func init() {
-	flag.Parse()
 	// register the discovery service which will run as a devp2p
 	// protocol when using the exec adapter
 	adapters.RegisterServices(services)
+}
 
+func TestMain(m *testing.M) {
+	flag.Parse()
 	log.PrintOrigins(true)
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(colorable.NewColorableStderr(), log.TerminalFormat(!*rawlog))))
+	os.Exit(m.Run())
 }
 
 // Benchmarks to test the average time it takes for an N-node ring
@@ -44,8 +44,7 @@ var (
 	httpSimPort = 8888
 )
 
-func init() {
-	flag.Parse()
+func setupLogging() {
 	//initialize the logger
 	//this is a demonstration on how to use Vmodule for filtering logs
 	//provide -vmodule as param, and comma-separated values, e.g.:
@@ -127,6 +126,8 @@ func newOverlaySim(sim *simulations.Network) *simulations.Server {
 
 // var server
 func main() {
+	flag.Parse()
+	setupLogging()
 	//cpu optimization
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	//run the sim
@@ -0,0 +1,175 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// MinBatchSize is the smallest number of hashes offered in a batch
+	// when batch sizes are adapted to slow or failing peers
+	MinBatchSize = 8
+	// DefaultOfferWindow is the number of offered hashes batches per stream
+	// the downstream peer may have in flight
+	DefaultOfferWindow = 1
+	// DefaultBinConcurrency is the number of streams per proximity bin
+	// that are served from the database concurrently
+	DefaultBinConcurrency = 4
+)
+
+var (
+	// batchTargetRTT is the round trip time between offering a batch and
+	// receiving the wanted hashes above which batch size is decreased
+	batchTargetRTT = 2 * time.Second
+	// batchTimeout is the round trip time counted as a failed batch
+	batchTimeout = 30 * time.Second
+	// batchMaxErrorRate is the error rate above which batch size is halved
+	batchMaxErrorRate = 0.1
+
+	batchSizeGrowCount   = metrics.NewRegisteredCounter("network.stream.batch.grow.count", nil)
+	batchSizeShrinkCount = metrics.NewRegisteredCounter("network.stream.batch.shrink.count", nil)
+)
+
+// batchSizer adapts the number of hashes offered in a batch to the
+// responsiveness of the downstream peer: batch size grows additively while
+// round trips are fast and error free and shrinks multiplicatively on slow
+// round trips and errors. This keeps nodes from flooding their peers with
+// offers after a restart when all bins are synced at once.
+type batchSizer struct {
+	mu        sync.Mutex
+	size      int
+	min, max  int
+	rtt       time.Duration // smoothed round trip time
+	errorRate float64       // smoothed rate of failed round trips
+}
+
+// newBatchSizer creates a batchSizer starting with and never exceeding max
+func newBatchSizer(max int) *batchSizer {
+	min := MinBatchSize
+	if min > max {
+		min = max
+	}
+	return &batchSizer{
+		size: max,
+		min:  min,
+		max:  max,
+	}
+}
+
+// Size returns the current batch size
+func (b *batchSizer) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Update records the round trip time of a batch and adapts the batch size
+func (b *batchSizer) Update(rtt time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := rtt > batchTimeout
+	if b.rtt == 0 {
+		b.rtt = rtt
+	} else {
+		b.rtt = (7*b.rtt + rtt) / 8
+	}
+	var e float64
+	if failed {
+		e = 1
+	}
+	b.errorRate = (7*b.errorRate + e) / 8
+
+	size := b.size
+	switch {
+	case failed || b.errorRate > batchMaxErrorRate:
+		size /= 2
+	case b.rtt > batchTargetRTT:
+		size = size * 3 / 4
+	default:
+		inc := size / 8
+		if inc == 0 {
+			inc = 1
+		}
+		size += inc
+	}
+	if size < b.min {
+		size = b.min
+	}
+	if size > b.max {
+		size = b.max
+	}
+	if size > b.size {
+		batchSizeGrowCount.Inc(1)
+	} else if size < b.size {
+		batchSizeShrinkCount.Inc(1)
+	}
+	b.size = size
+}
+
+// binLimiter limits the number of streams per proximity bin that
+// iterate the database at the same time
+type binLimiter struct {
+	mu   sync.Mutex
+	n    int
+	bins map[uint8]chan struct{}
+}
+
+// newBinLimiter creates a binLimiter allowing n concurrent streams per bin,
+// n <= 0 disables limiting
+func newBinLimiter(n int) *binLimiter {
+	return &binLimiter{
+		n:    n,
+		bins: make(map[uint8]chan struct{}),
+	}
+}
+
+// acquire blocks until a slot for the bin is available or quit is closed,
+// in the latter case it returns false
+func (l *binLimiter) acquire(po uint8, quit chan struct{}) bool {
+	if l == nil || l.n <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	sem, ok := l.bins[po]
+	if !ok {
+		sem = make(chan struct{}, l.n)
+		l.bins[po] = sem
+	}
+	l.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// release frees a slot acquired for the bin
+func (l *binLimiter) release(po uint8) {
+	if l == nil || l.n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	sem := l.bins[po]
+	l.mu.Unlock()
+	<-sem
+}
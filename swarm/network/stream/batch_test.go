@@ -0,0 +1,89 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchSizer(t *testing.T) {
+	b := newBatchSizer(BatchSize)
+	if size := b.Size(); size != BatchSize {
+		t.Fatalf("expected initial size %d, got %d", BatchSize, size)
+	}
+
+	// slow round trips shrink the batch size
+	for i := 0; i < 20; i++ {
+		b.Update(2 * batchTargetRTT)
+	}
+	slow := b.Size()
+	if slow >= BatchSize {
+		t.Fatalf("expected batch size to shrink, got %d", slow)
+	}
+
+	// failed round trips shrink it down to the minimum
+	for i := 0; i < 10; i++ {
+		b.Update(2 * batchTimeout)
+	}
+	if size := b.Size(); size != MinBatchSize {
+		t.Fatalf("expected size %d, got %d", MinBatchSize, size)
+	}
+
+	// fast round trips grow it back, but not above the maximum
+	for i := 0; i < 200; i++ {
+		b.Update(time.Millisecond)
+	}
+	if size := b.Size(); size != BatchSize {
+		t.Fatalf("expected size %d, got %d", BatchSize, size)
+	}
+}
+
+func TestBinLimiter(t *testing.T) {
+	l := newBinLimiter(1)
+	quit := make(chan struct{})
+	if !l.acquire(1, quit) {
+		t.Fatal("expected to acquire bin 1")
+	}
+	// other bins are not affected
+	if !l.acquire(2, quit) {
+		t.Fatal("expected to acquire bin 2")
+	}
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- l.acquire(1, quit)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected acquire to block while the bin is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.release(1)
+	if !<-acquired {
+		t.Fatal("expected to acquire bin 1 after release")
+	}
+
+	// closing quit unblocks waiting streams
+	go func() {
+		acquired <- l.acquire(1, quit)
+	}()
+	close(quit)
+	if <-acquired {
+		t.Fatal("expected acquire to fail after quit")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var (
+	capacityMsgCount     = metrics.NewRegisteredCounter("network.stream.capacity_msg", nil)
+	capacityPeersCounter = metrics.NewRegisteredCounter("network.stream.capacity.exhausted", nil)
+	deferredOffersCount  = metrics.NewRegisteredCounter("network.stream.capacity.deferred_offers", nil)
+	pausedOffersCount    = metrics.NewRegisteredCounter("network.stream.capacity.paused_offers", nil)
+)
+
+// CapacityMsg is the protocol msg signalling that the storage capacity of
+// the sender is exhausted and it does not accept synced chunks until it sends
+// a CapacityMsg with Exhausted set to false
+//
+// Retrieve requests are served regardless of the storage capacity.
+type CapacityMsg struct {
+	Exhausted bool
+}
+
+// String pretty prints CapacityMsg
+func (m CapacityMsg) String() string {
+	return fmt.Sprintf("Exhausted: %v", m.Exhausted)
+}
+
+// capacity tracks if the storage capacity of a node, the local one or a
+// peer, is exhausted
+type capacity struct {
+	mu      sync.Mutex
+	resumeC chan struct{} // closed when the capacity is available again, nil if it is not exhausted
+}
+
+// set sets the state of the capacity and returns true if it changed
+func (c *capacity) set(exhausted bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case exhausted && c.resumeC == nil:
+		c.resumeC = make(chan struct{})
+	case !exhausted && c.resumeC != nil:
+		close(c.resumeC)
+		c.resumeC = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// wait returns a channel which is closed when the capacity is available
+// again or nil if it is not exhausted
+func (c *capacity) wait() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumeC
+}
+
+// watchCapacity signals the changes of the local storage capacity to all
+// connected peers until the registry is closed
+func (r *Registry) watchCapacity(db *storage.DBAPI) {
+	exhaustedC := make(chan bool, 1)
+	r.capacitySub = db.SubscribeCapacity(exhaustedC)
+	r.capacity.set(db.CapacityExhausted())
+	go func() {
+		for {
+			select {
+			case exhausted := <-exhaustedC:
+				if !r.capacity.set(exhausted) {
+					continue
+				}
+				log.Info("signalling storage capacity to peers", "exhausted", exhausted)
+				r.peersMu.RLock()
+				for _, p := range r.peers {
+					go p.sendCapacity(exhausted)
+				}
+				r.peersMu.RUnlock()
+			case <-r.capacitySub.Err():
+				return
+			}
+		}
+	}()
+}
+
+// sendCapacity sends a CapacityMsg with the top priority
+func (p *Peer) sendCapacity(exhausted bool) {
+	if err := p.SendPriority(&CapacityMsg{Exhausted: exhausted}, Top); err != nil {
+		log.Warn("unable to signal storage capacity", "peer", p.ID(), "err", err)
+	}
+}
+
+// handleCapacityMsg records the storage capacity of the peer, offering
+// hashes to the peer is paused while its capacity is exhausted
+func (p *Peer) handleCapacityMsg(req *CapacityMsg) error {
+	capacityMsgCount.Inc(1)
+	if !p.capacity.set(req.Exhausted) {
+		return nil
+	}
+	log.Debug("peer storage capacity", "peer", p.ID(), "exhausted", req.Exhausted)
+	if req.Exhausted {
+		capacityPeersCounter.Inc(1)
+	}
+	return nil
+}
+
+// waitPeerCapacity blocks while the storage capacity of the peer is
+// exhausted and returns false if the peer disconnects meanwhile
+func (p *Peer) waitPeerCapacity() bool {
+	resumeC := p.capacity.wait()
+	if resumeC == nil {
+		return true
+	}
+	pausedOffersCount.Inc(1)
+	select {
+	case <-resumeC:
+		return true
+	case <-p.quit:
+		return false
+	}
+}
+
+// deferOfferedHashes handles the offered hashes once the local storage
+// capacity is available again, so that no chunks are wanted while it is
+// exhausted and the offered range is not recorded as synced
+func (p *Peer) deferOfferedHashes(req *OfferedHashesMsg) bool {
+	resumeC := p.streamer.capacity.wait()
+	if resumeC == nil {
+		return false
+	}
+	deferredOffersCount.Inc(1)
+	go func() {
+		select {
+		case <-resumeC:
+		case <-p.quit:
+			return
+		}
+		if err := p.handleOfferedHashesMsg(req); err != nil {
+			log.Warn("deferred offered hashes, dropping peer", "peer", p.ID(), "err", err)
+			p.Drop(err)
+		}
+	}()
+	return true
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
+)
+
+// TestStreamerCapacityDefersOffers tests that offered hashes are not
+// wanted while the local storage capacity is exhausted
+func TestStreamerCapacityDefersOffers(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var client *testClient
+	streamer.RegisterClientFunc("foo", func(p *Peer, t string, live bool) (Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		client = newTestClient(t)
+		return client, nil
+	})
+
+	peerID := tester.IDs[0]
+	stream := NewStream("foo", "", true)
+	if err := streamer.Subscribe(peerID, stream, NewRange(5, 8), Top); err != nil {
+		t.Fatal(err)
+	}
+	streamer.capacity.set(true)
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Subscribe message",
+			Expects: []p2ptest.Expect{
+				{
+					Code: 4,
+					Msg: &SubscribeMsg{
+						Stream:   stream,
+						History:  NewRange(5, 8),
+						Priority: Top,
+					},
+					Peer: peerID,
+				},
+			},
+		},
+		p2ptest.Exchange{
+			Label: "OfferedHashes message",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &OfferedHashesMsg{
+						HandoverProof: &HandoverProof{
+							Handover: &Handover{},
+						},
+						Hashes: hashes,
+						From:   5,
+						To:     8,
+						Stream: stream,
+					},
+					Peer: peerID,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if client != nil && len(client.receivedHashes) > 0 {
+		t.Fatal("expected no hashes to be checked while the capacity is exhausted")
+	}
+	mu.Unlock()
+
+	streamer.capacity.set(false)
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "WantedHashes message",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 2,
+				Msg: &WantedHashesMsg{
+					Stream: stream,
+					Want:   []byte{5},
+					From:   9,
+					To:     0,
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStreamerPeerCapacity tests that offering hashes to a peer is paused
+// while the peer signals its storage capacity is exhausted
+func TestStreamerPeerCapacity(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerID := tester.IDs[0]
+	peer := streamer.getPeer(peerID)
+	if peer == nil {
+		t.Fatal("peer not found")
+	}
+
+	capacityMsg := func(exhausted bool) {
+		err := tester.TestExchanges(p2ptest.Exchange{
+			Label: "Capacity message",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 10,
+					Msg:  &CapacityMsg{Exhausted: exhausted},
+					Peer: peerID,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	capacityMsg(true)
+	for i := 0; peer.capacity.wait() == nil; i++ {
+		if i == 100 {
+			t.Fatal("expected peer capacity to be exhausted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resumed := make(chan bool)
+	go func() {
+		resumed <- peer.waitPeerCapacity()
+	}()
+	select {
+	case <-resumed:
+		t.Fatal("expected offering to be paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	capacityMsg(false)
+	select {
+	case ok := <-resumed:
+		if !ok {
+			t.Fatal("expected offering to be resumed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected offering to be resumed")
+	}
+}
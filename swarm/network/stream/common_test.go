@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -75,13 +76,16 @@ var services = adapters.Services{
 }
 
 func init() {
-	flag.Parse()
 	// register the Delivery service which will run as a devp2p
 	// protocol when using the exec adapter
 	adapters.RegisterServices(services)
+}
 
+func TestMain(m *testing.M) {
+	flag.Parse()
 	log.PrintOrigins(true)
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(colorable.NewColorableStderr(), log.TerminalFormat(true))))
+	os.Exit(m.Run())
 }
 
 func createGlobalStore() {
@@ -150,6 +154,20 @@ func localStoreCleanup() {
 }
 
 func newStreamerTester(t *testing.T) (*p2ptest.ProtocolTester, *Registry, *storage.LocalStore, func(), error) {
+	return newStreamerTesterWithOptions(t, 1, &RegistryOptions{
+		SkipCheck: defaultSkipCheck,
+	})
+}
+
+// newStreamerTesterWithOptions creates a protocol tester with the given
+// number of peers and a registry with the given options
+func newStreamerTesterWithOptions(t *testing.T, peers int, options *RegistryOptions) (*p2ptest.ProtocolTester, *Registry, *storage.LocalStore, func(), error) {
+	return newStreamerTesterVersion(t, peers, Spec.Version, options)
+}
+
+// newStreamerTesterVersion is newStreamerTesterWithOptions with the peers
+// running the given version of the protocol
+func newStreamerTesterVersion(t *testing.T, peers int, version uint, options *RegistryOptions) (*p2ptest.ProtocolTester, *Registry, *storage.LocalStore, func(), error) {
 	// setup
 	addr := network.RandomAddr() // tested peers peer address
 	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
@@ -174,16 +192,29 @@ func newStreamerTester(t *testing.T) (*p2ptest.ProtocolTester, *Registry, *stora
 
 	db := storage.NewDBAPI(localStore)
 	delivery := NewDelivery(to, db)
-	streamer := NewRegistry(addr, delivery, db, state.NewInmemoryStore(), &RegistryOptions{
-		SkipCheck: defaultSkipCheck,
-	})
+	streamer := NewRegistry(addr, delivery, db, state.NewInmemoryStore(), options)
 	teardown := func() {
 		streamer.Close()
 		removeDataDir()
 	}
-	protocolTester := p2ptest.NewProtocolTester(t, network.NewNodeIDFromAddr(addr), 1, streamer.runProtocol)
+	run := streamer.runProtocol
+	if version != Spec.Version {
+		var spec *protocols.Spec
+		for _, spec = range Versions.Versions() {
+			if spec.Version == version {
+				break
+			}
+		}
+		run = func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			bzzPeer := network.NewBzzTestPeerVersion(protocols.NewPeer(p, rw, spec), addr, Versions, version)
+			to.On(bzzPeer)
+			defer to.Off(bzzPeer)
+			return streamer.Run(bzzPeer)
+		}
+	}
+	protocolTester := p2ptest.NewProtocolTester(t, network.NewNodeIDFromAddr(addr), peers, run)
 
-	err = waitForPeers(streamer, 1*time.Second, 1)
+	err = waitForPeers(streamer, 1*time.Second, peers)
 	if err != nil {
 		return nil, nil, nil, nil, errors.New("timeout: peer is not created")
 	}
@@ -0,0 +1,85 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/golang/snappy"
+)
+
+// FeatureCompression is the feature of the stream protocol delivering chunks
+// compressed with snappy, see CompressedChunkDeliveryMsg
+const FeatureCompression = "compression"
+
+var (
+	compressedDeliveryCount = metrics.NewRegisteredCounter("network.stream.compression.deliveries", nil)
+	compressionRawBytes     = metrics.NewRegisteredCounter("network.stream.compression.raw_bytes", nil)
+	compressionSentBytes    = metrics.NewRegisteredCounter("network.stream.compression.sent_bytes", nil)
+	compressionSavedBytes   = metrics.NewRegisteredCounter("network.stream.compression.saved_bytes", nil)
+	decompressedCount       = metrics.NewRegisteredCounter("network.stream.compression.received", nil)
+
+	errCompressedSize = errors.New("compressed chunk data exceeds the chunk size")
+)
+
+// CompressedChunkDeliveryMsg is a ChunkDeliveryMsg with the chunk data
+// compressed with snappy. It is only sent to peers running a version of the
+// protocol with FeatureCompression, and only if the compression makes the
+// chunk data smaller.
+type CompressedChunkDeliveryMsg ChunkDeliveryMsg
+
+// compress returns the delivery message with the chunk data compressed
+func compress(msg *ChunkDeliveryMsg) interface{} {
+	compressed := snappy.Encode(nil, msg.SData)
+	if len(compressed) >= len(msg.SData) {
+		return msg
+	}
+	compressedDeliveryCount.Inc(1)
+	compressionRawBytes.Inc(int64(len(msg.SData)))
+	compressionSentBytes.Inc(int64(len(compressed)))
+	compressionSavedBytes.Inc(int64(len(msg.SData) - len(compressed)))
+	return &CompressedChunkDeliveryMsg{
+		Addr:    msg.Addr,
+		SData:   compressed,
+		Receipt: msg.Receipt,
+	}
+}
+
+// decompress returns the delivery message with the chunk data restored, the
+// decoded length is checked up front so that no more than a chunk is
+// allocated
+func (m *CompressedChunkDeliveryMsg) decompress() (*ChunkDeliveryMsg, error) {
+	n, err := snappy.DecodedLen(m.SData)
+	if err != nil {
+		return nil, err
+	}
+	if n > int(storage.DefaultChunkSize)+8 {
+		return nil, errCompressedSize
+	}
+	data, err := snappy.Decode(nil, m.SData)
+	if err != nil {
+		return nil, err
+	}
+	decompressedCount.Inc(1)
+	return &ChunkDeliveryMsg{
+		Addr:    m.Addr,
+		SData:   data,
+		Receipt: m.Receipt,
+	}, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/golang/snappy"
+)
+
+// compressibleChunk returns a chunk of highly compressible data
+func compressibleChunk(b byte) *storage.Chunk {
+	data := make([]byte, 8+storage.DefaultChunkSize)
+	binary.LittleEndian.PutUint64(data, uint64(storage.DefaultChunkSize))
+	copy(data[8:], bytes.Repeat([]byte{b}, int(storage.DefaultChunkSize)))
+	hasher := storage.MakeHashFunc(storage.DefaultHash)()
+	hasher.ResetWithLength(data[:8])
+	hasher.Write(data[8:])
+	chunk := storage.NewChunk(hasher.Sum(nil), nil)
+	chunk.SData = data
+	return chunk
+}
+
+// TestStreamerDeliveryCompression tests that chunks are delivered compressed
+// to peers running a version of the protocol with FeatureCompression, and
+// that compressed deliveries are stored decompressed
+func TestStreamerDeliveryCompression(t *testing.T) {
+	tester, streamer, localStore, teardown, err := newStreamerTesterWithOptions(t, 1, &RegistryOptions{
+		SkipCheck:   defaultSkipCheck,
+		Compression: true,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerID := tester.IDs[0]
+	streamer.getPeer(peerID).handleSubscribeMsg(&SubscribeMsg{
+		Stream:   NewStream(swarmChunkServerStreamName, "", false),
+		Priority: Top,
+	})
+
+	chunk := compressibleChunk(1)
+	localStore.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "compressed delivery",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 5,
+				Msg: &RetrieveRequestMsg{
+					Addr:      chunk.Addr,
+					SkipCheck: true,
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 11,
+				Msg: &CompressedChunkDeliveryMsg{
+					Addr:  chunk.Addr,
+					SData: snappy.Encode(nil, chunk.SData),
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := compressibleChunk(2)
+	request, _ := localStore.GetOrCreateRequest(received.Addr)
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "CompressedChunkDeliveryMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 11,
+				Msg: &CompressedChunkDeliveryMsg{
+					Addr:  received.Addr,
+					SData: snappy.Encode(nil, received.SData),
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-request.ReqC:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for chunk %v", received.Addr)
+	}
+	stored, err := localStore.Get(received.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.SData, received.SData) {
+		t.Fatal("expected the chunk to be stored decompressed")
+	}
+}
+
+// TestStreamerDeliveryCompressionFallback tests that chunks are delivered
+// uncompressed to peers running a version of the protocol preceding
+// FeatureCompression
+func TestStreamerDeliveryCompressionFallback(t *testing.T) {
+	tester, streamer, localStore, teardown, err := newStreamerTesterVersion(t, 1, 6, &RegistryOptions{
+		SkipCheck:   defaultSkipCheck,
+		Compression: true,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerID := tester.IDs[0]
+	streamer.getPeer(peerID).handleSubscribeMsg(&SubscribeMsg{
+		Stream:   NewStream(swarmChunkServerStreamName, "", false),
+		Priority: Top,
+	})
+
+	chunk := compressibleChunk(1)
+	localStore.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "uncompressed delivery",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 5,
+				Msg: &RetrieveRequestMsg{
+					Addr:      chunk.Addr,
+					SkipCheck: true,
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 6,
+				Msg: &ChunkDeliveryMsg{
+					Addr:  chunk.Addr,
+					SData: chunk.SData,
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+// serverCursor is the position of a history stream offered to a peer, it is
+// persisted in the intervals store so that a reconnecting peer is offered the
+// history from where it left off instead of from the beginning
+type serverCursor struct {
+	Offered uint64 `json:"offered"` // index following the last offered batch
+	Acked   uint64 `json:"acked"`   // index following the last batch the peer stored
+}
+
+func serverCursorKey(p *Peer, s Stream) string {
+	return "server-cursor:" + p.ID().String() + s.String()
+}
+
+// loadCursor loads the persisted cursor of a history stream server, live
+// streams are not resumed and have no cursor
+func (p *Peer) loadCursor(s *server) {
+	store := p.streamer.intervalsStore
+	if s.stream.Live || store == nil {
+		return
+	}
+	key := serverCursorKey(p, s.stream)
+	cursor := &serverCursor{}
+	if err := store.Get(key, cursor); err != nil && err != state.ErrNotFound {
+		log.Warn("load server cursor", "peer", p.ID(), "stream", s.stream, "err", err)
+	}
+	s.cursorKey = key
+	s.cursorStore = store
+	s.cursor = cursor
+}
+
+// resumeFrom returns the start of the requested history range advanced to
+// the index following the last batch the peer stored, unless that is beyond
+// the end of the range
+func (s *server) resumeFrom(from, to uint64) uint64 {
+	if s.cursor == nil {
+		return from
+	}
+	s.cursorMu.Lock()
+	defer s.cursorMu.Unlock()
+	acked := s.cursor.Acked
+	if acked <= from || (to > 0 && acked > to) {
+		return from
+	}
+	return acked
+}
+
+// offered moves the cursor past the batch offered to the peer, the batch
+// offered before it becomes the one to be acknowledged by the next wanted
+// hashes message
+func (s *server) offered(to uint64) {
+	if s.cursor == nil {
+		return
+	}
+	s.cursorMu.Lock()
+	defer s.cursorMu.Unlock()
+	s.pending = s.cursor.Offered
+	s.cursor.Offered = to + 1
+	s.saveCursor()
+}
+
+// acked is called when a wanted hashes message arrives, which the peer only
+// sends after all the chunks it wanted from the previous batch are stored
+func (s *server) acked() {
+	if s.cursor == nil {
+		return
+	}
+	s.cursorMu.Lock()
+	defer s.cursorMu.Unlock()
+	if s.pending <= s.cursor.Acked {
+		return
+	}
+	s.cursor.Acked = s.pending
+	s.saveCursor()
+}
+
+func (s *server) saveCursor() {
+	if err := s.cursorStore.Put(s.cursorKey, s.cursor); err != nil {
+		log.Warn("save server cursor", "stream", s.stream, "err", err)
+	}
+}
@@ -17,7 +17,9 @@
 package stream
 
 import (
-	"errors"
+	"bytes"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -26,11 +28,19 @@ import (
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/network"
 	"github.com/ethereum/go-ethereum/swarm/storage"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const (
 	swarmChunkServerStreamName = "RETRIEVE_REQUEST"
 	deliveryCap                = 32
+	retrievalsCap              = 10000 // number of chunk retrievals receipts are kept for
+	deliveredCap               = 10000 // number of recently delivered chunks duplicate deliveries are dropped for
+
+	// DefaultRetrieveFanout is the number of closest peers a chunk is
+	// requested from in parallel
+	DefaultRetrieveFanout = 1
 )
 
 var (
@@ -39,23 +49,60 @@ var (
 
 	requestFromPeersCount     = metrics.NewRegisteredCounter("network.stream.request_from_peers.count", nil)
 	requestFromPeersEachCount = metrics.NewRegisteredCounter("network.stream.request_from_peers_each.count", nil)
+	// the requests and deliveries in excess of one per chunk when a chunk is
+	// requested from more than one peer
+	requestFromPeersExtraCount = metrics.NewRegisteredCounter("network.stream.request_from_peers_extra.count", nil)
+	redundantDeliveryCount     = metrics.NewRegisteredCounter("network.stream.redundant_delivery.count", nil)
+	dedupDeliveryCount         = metrics.NewRegisteredCounter("network.stream.dedup_delivery.count", nil)
+	validateChunkTimer         = metrics.NewRegisteredResettingTimer("network.stream.validate_chunk", nil)
+
+	cancelRequestCount       = metrics.NewRegisteredCounter("network.stream.cancel_request.count", nil)
+	handleCancelRequestCount = metrics.NewRegisteredCounter("network.stream.handle_cancel_request.count", nil)
+
+	receiptsCount        = metrics.NewRegisteredCounter("network.stream.receipts.count", nil)
+	invalidReceiptsCount = metrics.NewRegisteredCounter("network.stream.receipts.invalid.count", nil)
 )
 
 type Delivery struct {
-	db       *storage.DBAPI
-	overlay  network.Overlay
-	receiveC chan *ChunkDeliveryMsg
-	getPeer  func(discover.NodeID) *Peer
+	db        *storage.DBAPI
+	overlay   network.Overlay
+	receiveC  chan *ChunkDeliveryMsg
+	validateC chan *ChunkDeliveryMsg // received chunks to be validated by the workers
+	getPeer   func(discover.NodeID) *Peer
+	delivered *lru.Cache // addresses of the recently delivered chunks
+	fanout    int        // number of closest peers a chunk is requested from in parallel
+
+	retrievalsMu sync.Mutex // protects the retrievals and their receipts
+	retrievals   *lru.Cache // latest retrieval requests by chunk address
+}
+
+// retrieval is a retrieve request of a chunk sent to one or more peers, the
+// receipts of the delivery are collected per retrieval so that a receipt is
+// only accepted from a peer the request was sent to, once per request
+type retrieval struct {
+	peers    map[discover.NodeID]bool // peers the request was sent to, false once they sent a receipt
+	receipts []*DeliveryReceipt
 }
 
 func NewDelivery(overlay network.Overlay, db *storage.DBAPI) *Delivery {
+	retrievals, _ := lru.New(retrievalsCap)
+	delivered, _ := lru.New(deliveredCap)
 	d := &Delivery{
-		db:       db,
-		overlay:  overlay,
-		receiveC: make(chan *ChunkDeliveryMsg, deliveryCap),
+		db:         db,
+		overlay:    overlay,
+		receiveC:   make(chan *ChunkDeliveryMsg, deliveryCap),
+		validateC:  make(chan *ChunkDeliveryMsg, deliveryCap),
+		retrievals: retrievals,
+		delivered:  delivered,
+		fanout:     DefaultRetrieveFanout,
 	}
 
-	go d.processReceivedChunks()
+	// the chunks are validated in parallel, one worker per core, and stored
+	// in the order they were received
+	for i := 0; i < runtime.NumCPU(); i++ {
+		supervisor.Go("stream.delivery.validatechunks", nil, d.validateReceivedChunks)
+	}
+	supervisor.Go("stream.delivery.receivedchunks", nil, d.processReceivedChunks)
 	return d
 }
 
@@ -76,7 +123,7 @@ func NewSwarmChunkServer(db *storage.DBAPI) *SwarmChunkServer {
 		db:        db,
 		quit:      make(chan struct{}),
 	}
-	go s.processDeliveries()
+	supervisor.Go("stream.chunkserver.deliveries", s.quit, s.processDeliveries)
 	return s
 }
 
@@ -146,13 +193,16 @@ func (d *Delivery) handleRetrieveRequestMsg(sp *Peer, req *RetrieveRequestMsg) e
 	chunk, created := d.db.GetOrCreateRequest(req.Addr)
 	if chunk.ReqC != nil {
 		if created {
+			chunk.Forwarded = true
 			if err := d.RequestFromPeers(chunk.Addr[:], true, sp.ID()); err != nil {
 				log.Warn("unable to forward chunk request", "peer", sp.ID(), "key", chunk.Addr, "err", err)
 				chunk.SetErrored(storage.ErrChunkForward)
 				return nil
 			}
 		}
+		cancelC := sp.waitRequest(req.Addr)
 		go func() {
+			defer sp.doneRequest(req.Addr, cancelC)
 			t := time.NewTimer(10 * time.Minute)
 			defer t.Stop()
 
@@ -161,6 +211,10 @@ func (d *Delivery) handleRetrieveRequestMsg(sp *Peer, req *RetrieveRequestMsg) e
 			select {
 			case <-chunk.ReqC:
 				log.Debug("retrieve request ReqC closed", "peer", sp.ID(), "hash", req.Addr, "time", time.Since(start))
+			case <-cancelC:
+				// the chunk is still retrieved for the other requests
+				log.Debug("retrieve request cancelled", "peer", sp.ID(), "hash", req.Addr)
+				return
 			case <-t.C:
 				log.Debug("retrieve request timeout", "peer", sp.ID(), "hash", req.Addr)
 				chunk.SetErrored(storage.ErrChunkTimeout)
@@ -191,26 +245,80 @@ func (d *Delivery) handleRetrieveRequestMsg(sp *Peer, req *RetrieveRequestMsg) e
 	return nil
 }
 
+// FeatureCancelRequest is the feature of the stream protocol cancelling the
+// retrieve requests of a chunk sent to the peers which have not delivered it
+// when another peer does, see CancelRetrieveRequestMsg
+const FeatureCancelRequest = "cancelrequest"
+
+// CancelRetrieveRequestMsg cancels a retrieve request of the chunk with the
+// given address, the chunk is not delivered if it is not yet available. It is
+// only sent to peers running a version of the protocol with
+// FeatureCancelRequest.
+type CancelRetrieveRequestMsg struct {
+	Addr storage.Address
+}
+
+func (d *Delivery) handleCancelRetrieveRequestMsg(sp *Peer, req *CancelRetrieveRequestMsg) error {
+	handleCancelRequestCount.Inc(1)
+	if sp.cancelRequest(req.Addr) {
+		log.Trace("retrieve request cancelled", "peer", sp.ID(), "hash", req.Addr)
+	}
+	return nil
+}
+
 type ChunkDeliveryMsg struct {
-	Addr  storage.Address
-	SData []byte // the stored chunk Data (incl size)
-	peer  *Peer  // set in handleChunkDeliveryMsg
+	Addr    storage.Address
+	SData   []byte           // the stored chunk Data (incl size)
+	Receipt *DeliveryReceipt `rlp:"nil"` // optional receipt signed by the delivering node
+	peer    *Peer            // set in handleChunkDeliveryMsg
+	validC  chan bool        // receives the result of the validation of the chunk
 }
 
+// handleChunkDeliveryMsg hands the chunk over both to the validation workers
+// and to the goroutine storing the chunks, which waits for the result of
+// the validation of each chunk in turn
 func (d *Delivery) handleChunkDeliveryMsg(sp *Peer, req *ChunkDeliveryMsg) error {
 	req.peer = sp
+	req.validC = make(chan bool, 1)
+	d.validateC <- req
 	d.receiveC <- req
 	return nil
 }
 
+// validateReceivedChunks validates the received chunks off the goroutine
+// storing them, so that the hashing of the chunks uses all cores
+func (d *Delivery) validateReceivedChunks() {
+	for req := range d.validateC {
+		// recently delivered chunks are dropped when they are processed
+		if d.delivered.Contains(string(req.Addr)) {
+			req.validC <- true
+			continue
+		}
+		chunk := storage.NewChunk(req.Addr, nil)
+		chunk.SData = req.SData
+		start := time.Now()
+		req.validC <- d.db.Validate(chunk)
+		validateChunkTimer.UpdateSince(start)
+	}
+}
+
 func (d *Delivery) processReceivedChunks() {
 R:
 	for req := range d.receiveC {
 		processReceivedChunksCount.Inc(1)
 
+		// the chunk was recently delivered by another peer, it is neither
+		// validated nor stored again
+		if d.delivered.Contains(string(req.Addr)) {
+			dedupDeliveryCount.Inc(1)
+			continue R
+		}
 		// this should be has locally
 		chunk, err := d.db.Get(req.Addr)
 		if err == nil {
+			// the chunk was delivered by another peer it was requested from
+			redundantDeliveryCount.Inc(1)
+			d.delivered.Add(string(req.Addr), struct{}{})
 			continue R
 		}
 		if err != storage.ErrFetching {
@@ -223,23 +331,115 @@ R:
 			continue R
 		default:
 		}
+		if !<-req.validC {
+			req.peer.Drop(&storage.ChunkError{Op: "deliver", Addr: req.Addr, Peer: req.peer.ID().String(), Err: storage.ErrChunkInvalid})
+			continue R
+		}
 		chunk.SData = req.SData
-		d.db.Put(chunk)
+		d.db.PutValidated(chunk)
 
 		go func(req *ChunkDeliveryMsg) {
-			err := chunk.WaitToStore()
-			if err == storage.ErrChunkInvalid {
-				req.peer.Drop(err)
+			d.cancelRetrieval(req.Addr, req.peer.ID())
+			chunk.WaitToStore()
+			d.delivered.Add(string(req.Addr), struct{}{})
+			if req.Receipt != nil {
+				d.addReceipt(req.peer.ID(), req.Addr, req.Receipt)
 			}
 		}(req)
 	}
 }
 
-// RequestFromPeers sends a chunk retrieve request to
+// newRetrieval records a new retrieval of the chunk with the given address,
+// it replaces the previous retrieval of the chunk and its receipts
+func (d *Delivery) newRetrieval(addr storage.Address) *retrieval {
+	r := &retrieval{peers: make(map[discover.NodeID]bool)}
+	d.retrievalsMu.Lock()
+	defer d.retrievalsMu.Unlock()
+	d.retrievals.Add(string(addr), r)
+	return r
+}
+
+// requested marks the retrieval as sent to the peer with the given id
+func (d *Delivery) requested(r *retrieval, id discover.NodeID) {
+	d.retrievalsMu.Lock()
+	defer d.retrievalsMu.Unlock()
+	r.peers[id] = true
+}
+
+// addReceipt verifies the receipt of a chunk delivered by the peer with the
+// given id and records it with the retrieval it answers
+func (d *Delivery) addReceipt(id discover.NodeID, addr storage.Address, receipt *DeliveryReceipt) {
+	if !bytes.Equal(receipt.Addr, addr) || receipt.Verify() != nil {
+		invalidReceiptsCount.Inc(1)
+		log.Debug("invalid delivery receipt", "addr", addr, "signer", receipt.Signer)
+		return
+	}
+	d.retrievalsMu.Lock()
+	defer d.retrievalsMu.Unlock()
+	v, ok := d.retrievals.Get(string(addr))
+	if !ok || !v.(*retrieval).peers[id] {
+		invalidReceiptsCount.Inc(1)
+		log.Debug("unrequested delivery receipt", "addr", addr, "peer", id, "signer", receipt.Signer)
+		return
+	}
+	r := v.(*retrieval)
+	r.peers[id] = false
+	r.receipts = append(r.receipts, receipt)
+	receiptsCount.Inc(1)
+}
+
+// cancelRetrieval cancels the retrieve requests of the chunk sent to the peers
+// other than the one which delivered it, so that they do not send the chunk
+// once they retrieve it. Peers running a version of the protocol without
+// FeatureCancelRequest still deliver it and the delivery is discarded.
+func (d *Delivery) cancelRetrieval(addr storage.Address, deliverer discover.NodeID) {
+	var ids []discover.NodeID
+	d.retrievalsMu.Lock()
+	if v, ok := d.retrievals.Peek(string(addr)); ok {
+		for id, waiting := range v.(*retrieval).peers {
+			if waiting && id != deliverer {
+				ids = append(ids, id)
+			}
+		}
+	}
+	d.retrievalsMu.Unlock()
+
+	for _, id := range ids {
+		sp := d.getPeer(id)
+		if sp == nil || !sp.cancelRequests {
+			continue
+		}
+		if err := sp.SendPriority(&CancelRetrieveRequestMsg{Addr: addr}, Top); err != nil {
+			log.Debug("unable to cancel retrieve request", "peer", id, "hash", addr, "err", err)
+			continue
+		}
+		cancelRequestCount.Inc(1)
+	}
+}
+
+// Receipts returns the delivery receipts collected for the latest retrieval
+// of the chunk with the given address
+func (d *Delivery) Receipts(addr storage.Address) []*DeliveryReceipt {
+	d.retrievalsMu.Lock()
+	defer d.retrievalsMu.Unlock()
+	if v, ok := d.retrievals.Get(string(addr)); ok {
+		return append([]*DeliveryReceipt(nil), v.(*retrieval).receipts...)
+	}
+	return nil
+}
+
+// RequestFromPeers sends a chunk retrieve request to the closest peers, as
+// many as the fanout of the delivery, the chunk is stored when the first of
+// them delivers it, the requests sent to the others are cancelled and their
+// later deliveries are discarded
 func (d *Delivery) RequestFromPeers(hash []byte, skipCheck bool, peersToSkip ...discover.NodeID) error {
-	var success bool
+	var sent int
 	var err error
 	requestFromPeersCount.Inc(1)
+	// the chunk is requested again, it must not be taken for a duplicate
+	// when it is delivered
+	d.delivered.Remove(string(hash))
+	r := d.newRetrieval(hash)
 	d.overlay.EachConn(hash, 255, func(p network.OverlayConn, po int, nn bool) bool {
 		spId := p.(network.Peer).ID()
 		for _, p := range peersToSkip {
@@ -253,7 +453,14 @@ func (d *Delivery) RequestFromPeers(hash []byte, skipCheck bool, peersToSkip ...
 			log.Warn("Delivery.RequestFromPeers: peer not found", "id", spId)
 			return true
 		}
-		// TODO: skip light nodes that do not accept retrieve requests
+		// skip light nodes that do not accept retrieve requests
+		if cp, ok := p.(network.CapablePeer); ok && cp.Capabilities().Has(network.CapabilityLight) {
+			log.Trace("Delivery.RequestFromPeers: skip light peer", "peer", spId)
+			return true
+		}
+		// the peer is recorded before sending so that its delivery cannot
+		// arrive before it
+		d.requested(r, spId)
 		err = sp.SendPriority(&RetrieveRequestMsg{
 			Addr:      hash,
 			SkipCheck: skipCheck,
@@ -262,11 +469,14 @@ func (d *Delivery) RequestFromPeers(hash []byte, skipCheck bool, peersToSkip ...
 			return true
 		}
 		requestFromPeersEachCount.Inc(1)
-		success = true
-		return false
+		sent++
+		if sent > 1 {
+			requestFromPeersExtraCount.Inc(1)
+		}
+		return sent < d.fanout
 	})
-	if success {
+	if sent > 0 {
 		return nil
 	}
-	return errors.New("no peer found")
+	return &storage.ChunkError{Op: "request", Addr: hash, Err: storage.ErrChunkUnavailable}
 }
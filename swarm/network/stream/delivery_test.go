@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	crand "crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -67,6 +68,187 @@ func TestStreamerRetrieveRequest(t *testing.T) {
 	}
 }
 
+// TestStreamerRetrieveRequestFanout tests that a chunk is requested from as
+// many closest peers as the retrieve fanout
+func TestStreamerRetrieveRequestFanout(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTesterWithOptions(t, 3, &RegistryOptions{
+		SkipCheck:      defaultSkipCheck,
+		RetrieveFanout: 2,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expects []p2ptest.Expect
+	streamer.delivery.overlay.EachConn(hash0[:], 255, func(p network.OverlayConn, po int, nn bool) bool {
+		expects = append(expects, p2ptest.Expect{
+			Code: 5,
+			Msg: &RetrieveRequestMsg{
+				Addr:      hash0[:],
+				SkipCheck: true,
+			},
+			Peer: p.(network.Peer).ID(),
+		})
+		return len(expects) < 2
+	})
+	if len(expects) != 2 {
+		t.Fatalf("expected 2 connected peers, got %d", len(expects))
+	}
+
+	if err := streamer.delivery.RequestFromPeers(hash0[:], true); err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label:   "RetrieveRequestMsg",
+		Expects: expects,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestStreamerRetrieveRequestCancel tests that the requests of a chunk sent to
+// the peers which have not delivered it are cancelled when a peer delivers it
+func TestStreamerRetrieveRequestCancel(t *testing.T) {
+	tester, streamer, localStore, teardown, err := newStreamerTesterWithOptions(t, 2, &RegistryOptions{
+		SkipCheck:      defaultSkipCheck,
+		RetrieveFanout: 2,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peers []discover.NodeID
+	streamer.delivery.overlay.EachConn(hash0[:], 255, func(p network.OverlayConn, po int, nn bool) bool {
+		peers = append(peers, p.(network.Peer).ID())
+		return true
+	})
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 connected peers, got %d", len(peers))
+	}
+	if _, created := localStore.GetOrCreateRequest(hash0[:]); !created {
+		t.Fatal("chunk already exists")
+	}
+	if err := streamer.delivery.RequestFromPeers(hash0[:], true); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []p2ptest.Expect
+	for _, id := range peers {
+		requests = append(requests, p2ptest.Expect{
+			Code: 5,
+			Msg: &RetrieveRequestMsg{
+				Addr:      hash0[:],
+				SkipCheck: true,
+			},
+			Peer: id,
+		})
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label:   "RetrieveRequestMsg",
+		Expects: requests,
+	}, p2ptest.Exchange{
+		Label: "CancelRetrieveRequestMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 6,
+				Msg: &ChunkDeliveryMsg{
+					Addr:  hash0[:],
+					SData: hash1[:],
+				},
+				Peer: peers[0],
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 12,
+				Msg: &CancelRetrieveRequestMsg{
+					Addr: hash0[:],
+				},
+				Peer: peers[1],
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestStreamerCancelRetrieveRequestMsg tests that a peer cancelling its
+// request of a chunk stops waiting for the delivery of the chunk
+func TestStreamerCancelRetrieveRequestMsg(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTesterWithOptions(t, 2, &RegistryOptions{
+		SkipCheck: defaultSkipCheck,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerID, otherID := tester.IDs[0], tester.IDs[1]
+	peer := streamer.getPeer(peerID)
+	peer.handleSubscribeMsg(&SubscribeMsg{
+		Stream:   NewStream(swarmChunkServerStreamName, "", false),
+		Priority: Top,
+	})
+
+	// the request of the chunk the node does not have is forwarded
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "RetrieveRequestMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 5,
+				Msg: &RetrieveRequestMsg{
+					Addr:      hash0[:],
+					SkipCheck: true,
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 5,
+				Msg: &RetrieveRequestMsg{
+					Addr:      hash0[:],
+					SkipCheck: true,
+				},
+				Peer: otherID,
+			},
+		},
+	}, p2ptest.Exchange{
+		Label: "CancelRetrieveRequestMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 12,
+				Msg: &CancelRetrieveRequestMsg{
+					Addr: hash0[:],
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		peer.waitingMu.Lock()
+		waiting := len(peer.waiting)
+		peer.waitingMu.Unlock()
+		if waiting == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the request to be cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestStreamerUpstreamRetrieveRequestMsgExchangeWithoutStore(t *testing.T) {
 	tester, streamer, _, teardown, err := newStreamerTester(t)
 	defer teardown()
@@ -211,6 +393,72 @@ func TestStreamerUpstreamRetrieveRequestMsgExchange(t *testing.T) {
 	}
 }
 
+// TestStreamerDeliveryCountsTag tests that the delivery of a chunk put with
+// a tag is counted by the tag
+func TestStreamerDeliveryCountsTag(t *testing.T) {
+	tester, streamer, localStore, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerID := tester.IDs[0]
+	peer := streamer.getPeer(peerID)
+	peer.handleSubscribeMsg(&SubscribeMsg{
+		Stream:   NewStream(swarmChunkServerStreamName, "", false),
+		History:  nil,
+		Priority: Top,
+	})
+
+	hash := storage.Address(hash1[:])
+	chunk := storage.NewChunk(hash, nil)
+	chunk.SData = hash1[:]
+	chunk.Tag = storage.NewTag(1, "upload", hash)
+	localStore.Put(chunk)
+	chunk.WaitToStore()
+
+	delivery := p2ptest.Exchange{
+		Label: "RetrieveRequestMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 5,
+				Msg: &RetrieveRequestMsg{
+					Addr:      hash,
+					SkipCheck: true,
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 6,
+				Msg: &ChunkDeliveryMsg{
+					Addr:  hash,
+					SData: hash,
+				},
+				Peer: peerID,
+			},
+		},
+	}
+	// a chunk is counted once however many times it is delivered
+	for i := 0; i < 2; i++ {
+		if err := tester.TestExchanges(delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var synced int64
+	if storage.Proximity(network.ToOverlayAddr(peerID.Bytes()), hash) > storage.Proximity(streamer.addr.Over(), hash) {
+		synced = 1
+	}
+	if sent := chunk.Tag.Get(storage.StateSent); sent != 1 {
+		t.Fatalf("expected 1 chunk sent, got %d", sent)
+	}
+	if got := chunk.Tag.Get(storage.StateSynced); got != synced {
+		t.Fatalf("expected %d chunks synced, got %d", synced, got)
+	}
+}
+
 func TestStreamerDownstreamChunkDeliveryMsgExchange(t *testing.T) {
 	tester, streamer, localStore, teardown, err := newStreamerTester(t)
 	defer teardown()
@@ -296,6 +544,135 @@ func TestStreamerDownstreamChunkDeliveryMsgExchange(t *testing.T) {
 
 }
 
+// TestStreamerDeliveryDedup tests that a delivered chunk is remembered until
+// it is requested again
+func TestStreamerDeliveryDedup(t *testing.T) {
+	tester, streamer, localStore, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunkKey := storage.Address(hash0[:])
+	chunk, created := localStore.GetOrCreateRequest(chunkKey)
+	if !created {
+		t.Fatal("chunk already exists")
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "ChunkDeliveryRequest message",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 6,
+				Msg: &ChunkDeliveryMsg{
+					Addr:  chunkKey,
+					SData: hash1[:],
+				},
+				Peer: tester.IDs[0],
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	timeout := time.NewTimer(1 * time.Second)
+	defer timeout.Stop()
+	for !streamer.delivery.delivered.Contains(string(chunkKey)) {
+		select {
+		case <-timeout.C:
+			t.Fatal("timeout waiting for the chunk to be delivered")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a request for the chunk clears it from the recent deliveries
+	streamer.delivery.RequestFromPeers(chunkKey, true)
+	if streamer.delivery.delivered.Contains(string(chunkKey)) {
+		t.Fatal("expected the requested chunk not to be taken for a duplicate")
+	}
+}
+
+// TestStreamerDeliveryValidation tests that the validated chunks of a batch
+// of deliveries are all stored and that the peer delivering an invalid
+// chunk is dropped without the chunk being stored
+func TestStreamerDeliveryValidation(t *testing.T) {
+	tester, _, localStore, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	localStore.Validators = []storage.ChunkValidator{storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash))}
+
+	var triggers []p2ptest.Trigger
+	var requests []*storage.Chunk
+	for _, chunk := range storage.GenerateRandomChunks(storage.DefaultChunkSize, 50) {
+		request, _ := localStore.GetOrCreateRequest(chunk.Addr)
+		requests = append(requests, request)
+		triggers = append(triggers, p2ptest.Trigger{
+			Code: 6,
+			Msg: &ChunkDeliveryMsg{
+				Addr:  chunk.Addr,
+				SData: chunk.SData,
+			},
+			Peer: tester.IDs[0],
+		})
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label:    "ChunkDeliveryRequest messages",
+		Triggers: triggers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for _, request := range requests {
+		select {
+		case <-request.ReqC:
+		case <-timeout:
+			t.Fatalf("timeout waiting for chunk %v", request.Addr)
+		}
+		if _, err := localStore.Get(request.Addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invalid := storage.GenerateRandomChunk(storage.DefaultChunkSize)
+	request, _ := localStore.GetOrCreateRequest(invalid.Addr)
+	invalid.SData[8]++
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "invalid ChunkDeliveryRequest message",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 6,
+				Msg: &ChunkDeliveryMsg{
+					Addr:  invalid.Addr,
+					SData: invalid.SData,
+				},
+				Peer: tester.IDs[0],
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.TestDisconnected(&p2ptest.Disconnect{
+		Peer:  tester.IDs[0],
+		Error: errors.New("subprotocol error"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-request.ReqC:
+		t.Fatal("expected the invalid chunk not to be stored")
+	default:
+	}
+}
+
 func TestDeliveryFromNodes(t *testing.T) {
 	testDeliveryFromNodes(t, 2, 1, dataChunkCount, true)
 	testDeliveryFromNodes(t, 2, 1, dataChunkCount, false)
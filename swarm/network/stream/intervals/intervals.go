@@ -149,6 +149,17 @@ func (i *Intervals) Last() (end uint64) {
 	return i.ranges[l-1][1]
 }
 
+// Ranges returns a copy of the range intervals, ordered and with no
+// overlapping or adjacent ranges.
+func (i *Intervals) Ranges() [][2]uint64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	ranges := make([][2]uint64, len(i.ranges))
+	copy(ranges, i.ranges)
+	return ranges
+}
+
 // String returns a descriptive representation of range intervals
 // in [] notation, as a list of two element vectors.
 func (i *Intervals) String() string {
@@ -169,7 +180,12 @@ func (i *Intervals) MarshalBinary() (data []byte, err error) {
 }
 
 // UnmarshalBinary decodes data according to the Intervals.MarshalBinary format.
+// Decoded ranges are added one by one, so that unordered, overlapping or
+// adjacent ranges are merged and the intervals are compacted when loaded.
 func (i *Intervals) UnmarshalBinary(data []byte) (err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	d := bytes.Split(data, []byte(";"))
 	l := len(d)
 	if l == 0 {
@@ -199,7 +215,10 @@ func (i *Intervals) UnmarshalBinary(data []byte) (err error) {
 		if err != nil {
 			return fmt.Errorf("parsing the second element in range %d: %v", j, err)
 		}
-		i.ranges = append(i.ranges, [2]uint64{start, end})
+		if start > end {
+			return fmt.Errorf("range %d start %d is greater then end %d", j, start, end)
+		}
+		i.add(start, end)
 	}
 
 	return nil
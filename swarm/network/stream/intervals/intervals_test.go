@@ -16,7 +16,10 @@
 
 package intervals
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 // Test tests Interval methods Add, Next and Last for various
 // initial state.
@@ -393,3 +396,44 @@ func TestMerge(t *testing.T) {
 		}
 	}
 }
+
+// TestUnmarshalCompaction tests that unordered, overlapping and adjacent
+// ranges are merged when intervals are decoded.
+func TestUnmarshalCompaction(t *testing.T) {
+	for i, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{
+			data:     "0;a,k",
+			expected: "[[10 20]]",
+		},
+		{
+			data:     "0;u,z;a,k",
+			expected: "[[10 20] [30 35]]",
+		},
+		{
+			data:     "0;a,k;f,u;v,z",
+			expected: "[[10 35]]",
+		},
+		{
+			data:     "f;0,a;c,k",
+			expected: "[[15 20]]",
+		},
+	} {
+		intervals := &Intervals{}
+		if err := intervals.UnmarshalBinary([]byte(tc.data)); err != nil {
+			t.Fatalf("interval #%d: %v", i, err)
+		}
+		if got := intervals.String(); got != tc.expected {
+			t.Errorf("interval #%d: expected %s, got %s", i, tc.expected, got)
+		}
+		if got := fmt.Sprint(intervals.Ranges()); got != tc.expected {
+			t.Errorf("interval #%d: expected ranges %s, got %s", i, tc.expected, got)
+		}
+	}
+
+	if err := (&Intervals{}).UnmarshalBinary([]byte("0;k,a")); err == nil {
+		t.Error("expected error decoding a range with start greater then end")
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/network/stream/intervals"
 	streamTesting "github.com/ethereum/go-ethereum/swarm/network/stream/testing"
 	"github.com/ethereum/go-ethereum/swarm/state"
 	"github.com/ethereum/go-ethereum/swarm/storage"
@@ -315,3 +316,40 @@ func testIntervals(t *testing.T, live bool, history *Range, skipCheck bool) {
 	}
 	streamTesting.CheckResult(t, result, startedAt, finishedAt)
 }
+
+// TestIntervalsAPI tests that the intervals of a stream received from a peer
+// are returned by the API
+func TestIntervalsAPI(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerID := tester.IDs[0]
+	stream := NewStream("foo", "", false)
+	api := NewAPI(streamer)
+	if _, err := api.Intervals(peerID, stream); err != state.ErrNotFound {
+		t.Fatalf("expected error %v, got %v", state.ErrNotFound, err)
+	}
+
+	i := intervals.NewIntervals(5)
+	i.Add(5, 10)
+	i.Add(20, 30)
+	if err := streamer.intervalsStore.Put(streamIntervalsKey(peerID, stream), i); err != nil {
+		t.Fatal(err)
+	}
+	got, err := api.Intervals(peerID, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Peer != peerID || got.Stream != stream {
+		t.Fatalf("expected intervals of peer %s stream %s, got %s %s", peerID, stream, got.Peer, got.Stream)
+	}
+	if fmt.Sprint(got.Ranges) != "[[5 10] [20 30]]" {
+		t.Fatalf("expected ranges [[5 10] [20 30]], got %v", got.Ranges)
+	}
+	if got.Next != [2]uint64{11, 19} {
+		t.Fatalf("expected next gap [11 19], got %v", got.Next)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Messages are sent to a peer in two lanes of the outgoing priority queue.
+// Control messages, such as retrieve requests and offered and wanted hashes,
+// are small and take the control lane, which is always served before the data
+// lane of the chunk deliveries, so that they are not held up behind the
+// deliveries of a heavy sync. Within a lane, messages are sent in the order
+// of the priority of their stream.
+const (
+	dataLane    = 0                  // offset of the data lane in the queue
+	controlLane = int(PriorityQueue) // offset of the control lane in the queue
+	laneCount   = 2
+)
+
+var (
+	controlLaneWaitTimer = metrics.NewRegisteredResettingTimer("network.stream.lane.control.wait", nil)
+	dataLaneWaitTimer    = metrics.NewRegisteredResettingTimer("network.stream.lane.data.wait", nil)
+)
+
+// queuedMsg is a message in the outgoing priority queue
+type queuedMsg struct {
+	msg    interface{}
+	queued time.Time
+	lane   int
+}
+
+// laneOf returns the lane a message is sent in
+func laneOf(msg interface{}) int {
+	switch msg.(type) {
+	case *ChunkDeliveryMsg, *CompressedChunkDeliveryMsg:
+		return dataLane
+	default:
+		return controlLane
+	}
+}
+
+// sendQueued sends a message popped from the outgoing priority queue
+func (p *Peer) sendQueued(i interface{}) {
+	m := i.(*queuedMsg)
+	if m.lane == controlLane {
+		controlLaneWaitTimer.UpdateSince(m.queued)
+	} else {
+		dataLaneWaitTimer.UpdateSince(m.queued)
+	}
+	p.Send(m.msg)
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pq "github.com/ethereum/go-ethereum/swarm/network/priorityqueue"
+)
+
+// TestPeerSendLanes tests that queued control messages are sent before the
+// queued chunk deliveries regardless of the priority of their streams
+func TestPeerSendLanes(t *testing.T) {
+	p := &Peer{
+		pq: pq.New(laneCount*int(PriorityQueue), PriorityQueueCap),
+	}
+	for i := 0; i < 10; i++ {
+		if err := p.SendPriority(&ChunkDeliveryMsg{}, Top); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.SendPriority(&WantedHashesMsg{}, Low); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SendPriority(&RetrieveRequestMsg{}, Top); err != nil {
+		t.Fatal(err)
+	}
+
+	sentC := make(chan interface{}, 12)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.pq.Run(ctx, func(i interface{}) { sentC <- i.(*queuedMsg).msg })
+
+	var sent []interface{}
+	for len(sent) < 12 {
+		select {
+		case msg := <-sentC:
+			sent = append(sent, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the messages, got %d", len(sent))
+		}
+	}
+	if _, ok := sent[0].(*RetrieveRequestMsg); !ok {
+		t.Fatalf("expected the retrieve request to be sent first, got %T", sent[0])
+	}
+	if _, ok := sent[1].(*WantedHashesMsg); !ok {
+		t.Fatalf("expected the wanted hashes to be sent second, got %T", sent[1])
+	}
+	for _, msg := range sent[2:] {
+		if laneOf(msg) != dataLane {
+			t.Fatalf("expected chunk deliveries after the control messages, got %T", msg)
+		}
+	}
+}
@@ -108,7 +108,7 @@ func (p *Peer) handleSubscribeMsg(req *SubscribeMsg) (err error) {
 	var from uint64
 	var to uint64
 	if !req.Stream.Live && req.History != nil {
-		from = req.History.From
+		from = os.resumeFrom(req.History.From, req.History.To)
 		to = req.History.To
 	}
 
@@ -125,13 +125,18 @@ func (p *Peer) handleSubscribeMsg(req *SubscribeMsg) (err error) {
 		if err != nil {
 			return err
 		}
+		// the live stream takes over from where history ends
+		if hs, ok := s.(historyServer); ok {
+			hs.BoundHistory()
+		}
 
 		os, err := p.setServer(getHistoryStream(req.Stream), s, getHistoryPriority(req.Priority))
 		if err != nil {
 			return err
 		}
+		from := os.resumeFrom(req.History.From, req.History.To)
 		go func() {
-			if err := p.SendOfferedHashes(os, req.History.From, req.History.To); err != nil {
+			if err := p.SendOfferedHashes(os, from, req.History.To); err != nil {
 				log.Warn("SendOfferedHashes dropping peer", "err", err)
 				p.Drop(err)
 			}
@@ -184,6 +189,10 @@ func (m OfferedHashesMsg) String() string {
 func (p *Peer) handleOfferedHashesMsg(req *OfferedHashesMsg) error {
 	metrics.GetOrRegisterCounter("peer.handleofferedhashes", nil).Inc(1)
 
+	if p.deferOfferedHashes(req) {
+		return nil
+	}
+
 	c, _, err := p.getOrSetClient(req.Stream, req.From, req.To)
 	if err != nil {
 		return err
@@ -193,11 +202,14 @@ func (p *Peer) handleOfferedHashesMsg(req *OfferedHashesMsg) error {
 	if err != nil {
 		return fmt.Errorf("error initiaising bitvector of length %v: %v", len(hashes)/HashSize, err)
 	}
+	p.streamer.progress.offered(p.ID(), req.Stream, req.From)
 	wg := sync.WaitGroup{}
+	var wanted int
 	for i := 0; i < len(hashes); i += HashSize {
 		hash := hashes[i : i+HashSize]
 
 		if wait := c.NeedData(hash); wait != nil {
+			wanted++
 			want.Set(i/HashSize, true)
 			wg.Add(1)
 			// create request and wait until the chunk data arrives and is stored
@@ -222,8 +234,12 @@ func (p *Peer) handleOfferedHashesMsg(req *OfferedHashesMsg) error {
 	// }()
 	go func() {
 		wg.Wait()
+		err := c.batchDone(p, req, hashes)
+		if err == nil {
+			p.streamer.progress.batchSynced(p.ID(), req.Stream, req.To, wanted)
+		}
 		select {
-		case c.next <- c.batchDone(p, req, hashes):
+		case c.next <- err:
 		case <-c.quit:
 		}
 	}()
@@ -294,8 +310,12 @@ func (p *Peer) handleWantedHashesMsg(req *WantedHashesMsg) error {
 		return err
 	}
 	hashes := s.currentBatch
+	s.acked()
 	// launch in go routine since GetBatch blocks until new hashes arrive
 	go func() {
+		if !p.waitPeerCapacity() {
+			return
+		}
 		if err := p.SendOfferedHashes(s, req.From, req.To); err != nil {
 			log.Warn("SendOfferedHashes dropping peer", "err", err)
 			p.Drop(err)
@@ -314,12 +334,16 @@ func (p *Peer) handleWantedHashesMsg(req *WantedHashesMsg) error {
 			metrics.GetOrRegisterCounter("peer.handlewantedhashesmsg.actualget", nil).Inc(1)
 
 			hash := hashes[i*HashSize : (i+1)*HashSize]
-			data, err := s.GetData(hash)
+			var chunk *storage.Chunk
+			if cs, ok := s.Server.(chunkServer); ok {
+				chunk, err = cs.GetChunk(hash)
+			} else {
+				chunk = storage.NewChunk(hash, nil)
+				chunk.SData, err = s.GetData(hash)
+			}
 			if err != nil {
 				return fmt.Errorf("handleWantedHashesMsg get data %x: %v", hash, err)
 			}
-			chunk := storage.NewChunk(hash, nil)
-			chunk.SData = data
 			if length := len(chunk.SData); length < 9 {
 				log.Error("Chunk.SData to sync is too short", "len(chunk.SData)", length, "address", chunk.Addr)
 			}
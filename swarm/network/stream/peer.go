@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/protocols"
 	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/network"
 	pq "github.com/ethereum/go-ethereum/swarm/network/priorityqueue"
 	"github.com/ethereum/go-ethereum/swarm/network/stream/intervals"
 	"github.com/ethereum/go-ethereum/swarm/state"
@@ -59,22 +60,29 @@ type Peer struct {
 	// that are set on Registry.Subscribe and used
 	// on creating a new client in offered hashes handler.
 	clientParams map[Stream]*clientParams
-	quit         chan struct{}
+	capacity     capacity // storage capacity of the peer
+	compression  bool     // chunks are delivered compressed to the peer
+	// retrieve requests sent to the peer can be cancelled
+	cancelRequests bool
+	waitingMu      sync.Mutex
+	waiting        map[string]chan struct{} // retrieve requests of the peer waiting for a delivery by chunk address
+	quit           chan struct{}
 }
 
 // NewPeer is the constructor for Peer
 func NewPeer(peer *protocols.Peer, streamer *Registry) *Peer {
 	p := &Peer{
 		Peer:         peer,
-		pq:           pq.New(int(PriorityQueue), PriorityQueueCap),
+		pq:           pq.New(laneCount*int(PriorityQueue), PriorityQueueCap),
 		streamer:     streamer,
 		servers:      make(map[Stream]*server),
 		clients:      make(map[Stream]*client),
 		clientParams: make(map[Stream]*clientParams),
+		waiting:      make(map[string]chan struct{}),
 		quit:         make(chan struct{}),
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	go p.pq.Run(ctx, func(i interface{}) { p.Send(i) })
+	go p.pq.Run(ctx, p.sendQueued)
 	go func() {
 		<-p.quit
 		cancel()
@@ -88,16 +96,38 @@ func (p *Peer) Deliver(chunk *storage.Chunk, priority uint8) error {
 		Addr:  chunk.Addr,
 		SData: chunk.SData,
 	}
-	return p.SendPriority(msg, priority)
+	if key := p.streamer.receiptKey; key != nil {
+		receipt, err := NewDeliveryReceipt(chunk.Addr, key)
+		if err != nil {
+			return err
+		}
+		msg.Receipt = receipt
+	}
+	var send interface{} = msg
+	if p.compression {
+		send = compress(msg)
+	}
+	if err := p.SendPriority(send, priority); err != nil {
+		return err
+	}
+	if chunk.Tag != nil {
+		chunk.IncTag(storage.StateSent)
+		if storage.Proximity(network.ToOverlayAddr(p.ID().Bytes()), chunk.Addr) > storage.Proximity(p.streamer.addr.Over(), chunk.Addr) {
+			chunk.IncTag(storage.StateSynced)
+		}
+	}
+	return nil
 }
 
-// SendPriority sends message to the peer using the outgoing priority queue
+// SendPriority sends message to the peer using the outgoing priority queue,
+// in the lane of the message with the given priority within the lane
 func (p *Peer) SendPriority(msg interface{}, priority uint8) error {
 	defer metrics.GetOrRegisterResettingTimer(fmt.Sprintf("peer.sendpriority_t.%d", priority), nil).UpdateSince(time.Now())
 	metrics.GetOrRegisterCounter(fmt.Sprintf("peer.sendpriority.%d", priority), nil).Inc(1)
 	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
 	defer cancel()
-	return p.pq.Push(ctx, msg, int(priority))
+	lane := laneOf(msg)
+	return p.pq.Push(ctx, &queuedMsg{msg: msg, queued: time.Now(), lane: lane}, lane+int(priority))
 }
 
 // SendOfferedHashes sends OfferedHashesMsg protocol msg
@@ -116,6 +146,7 @@ func (p *Peer) SendOfferedHashes(s *server, f, t uint64) error {
 		}
 	}
 	s.currentBatch = hashes
+	s.offered(to)
 	msg := &OfferedHashesMsg{
 		HandoverProof: proof,
 		Hashes:        hashes,
@@ -150,6 +181,7 @@ func (p *Peer) setServer(s Stream, o Server, priority uint8) (*server, error) {
 		stream:   s,
 		priority: priority,
 	}
+	p.loadCursor(os)
 	p.servers[s] = os
 	return os, nil
 }
@@ -261,7 +293,7 @@ func (p *Peer) getOrSetClient(s Stream, from, to uint64) (c *client, created boo
 		return nil, false, err
 	}
 
-	next := make(chan error, 1)
+	next := make(chan error, p.streamer.offerWindow)
 	c = &client{
 		Client:         is,
 		stream:         s,
@@ -274,7 +306,10 @@ func (p *Peer) getOrSetClient(s Stream, from, to uint64) (c *client, created boo
 	}
 	p.clients[s] = c
 	cp.clientCreated() // unblock all possible getClient calls that are waiting
-	next <- nil        // this is to allow wantedKeysMsg before first batch arrives
+	// this is to allow wantedKeysMsg before first batches arrive
+	for i := 0; i < p.streamer.offerWindow; i++ {
+		next <- nil
+	}
 	return c, true, nil
 }
 
@@ -326,3 +361,36 @@ func (p *Peer) close() {
 		s.Close()
 	}
 }
+
+// waitRequest records a retrieve request of the peer waiting for the
+// delivery of the chunk, the returned channel is closed if the peer cancels
+// the request
+func (p *Peer) waitRequest(addr storage.Address) chan struct{} {
+	c := make(chan struct{})
+	p.waitingMu.Lock()
+	defer p.waitingMu.Unlock()
+	p.waiting[string(addr)] = c
+	return c
+}
+
+// doneRequest removes the retrieve request waiting on the given channel
+func (p *Peer) doneRequest(addr storage.Address, c chan struct{}) {
+	p.waitingMu.Lock()
+	defer p.waitingMu.Unlock()
+	if p.waiting[string(addr)] == c {
+		delete(p.waiting, string(addr))
+	}
+}
+
+// cancelRequest cancels the retrieve request of the peer waiting for the
+// delivery of the chunk, it returns false if no request is waiting
+func (p *Peer) cancelRequest(addr storage.Address) bool {
+	p.waitingMu.Lock()
+	defer p.waitingMu.Unlock()
+	c, ok := p.waiting[string(addr)]
+	if ok {
+		close(c)
+		delete(p.waiting, string(addr))
+	}
+	return ok
+}
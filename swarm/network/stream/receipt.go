@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+var errInvalidReceipt = errors.New("invalid delivery receipt")
+
+// DeliveryReceipt is a statement signed by a node that it delivered a chunk
+// at a given time. Receipts can be collected by applications to build proof
+// of service and reward schemes.
+type DeliveryReceipt struct {
+	Addr      storage.Address `json:"addr"`
+	Timestamp uint64          `json:"timestamp"` // unix time in seconds
+	Signer    hexutil.Bytes   `json:"signer"`    // overlay address of the delivering node
+	Signature hexutil.Bytes   `json:"signature"`
+}
+
+// NewDeliveryReceipt creates a receipt for the delivery of the chunk with
+// the given address signed with the bzz key of the delivering node
+func NewDeliveryReceipt(addr storage.Address, key *ecdsa.PrivateKey) (*DeliveryReceipt, error) {
+	r := &DeliveryReceipt{
+		Addr:      addr,
+		Timestamp: uint64(time.Now().Unix()),
+		Signer:    crypto.Keccak256(crypto.FromECDSAPub(&key.PublicKey)),
+	}
+	sig, err := crypto.Sign(r.digest(), key)
+	if err != nil {
+		return nil, err
+	}
+	r.Signature = sig
+	return r, nil
+}
+
+// digest returns the hash of the signed fields of the receipt
+func (r *DeliveryReceipt) digest() []byte {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, r.Timestamp)
+	return crypto.Keccak256(r.Addr, ts, r.Signer)
+}
+
+// Verify checks that the receipt was signed by the node with the overlay
+// address in the Signer field
+func (r *DeliveryReceipt) Verify() error {
+	pub, err := crypto.SigToPub(r.digest(), r.Signature)
+	if err != nil {
+		return errInvalidReceipt
+	}
+	if !bytes.Equal(crypto.Keccak256(crypto.FromECDSAPub(pub)), r.Signer) {
+		return errInvalidReceipt
+	}
+	return nil
+}
+
+// ReceiptAPI provides the collected delivery receipts in the bzz namespace
+type ReceiptAPI struct {
+	delivery *Delivery
+}
+
+// NewReceiptAPI creates the delivery receipt API of the registry
+func NewReceiptAPI(r *Registry) *ReceiptAPI {
+	return &ReceiptAPI{delivery: r.delivery}
+}
+
+// DeliveryReceipts returns the receipts signed by the peers which delivered
+// the chunk with the given address in its latest retrieval
+func (api *ReceiptAPI) DeliveryReceipts(addr storage.Address) []*DeliveryReceipt {
+	return api.delivery.Receipts(addr)
+}
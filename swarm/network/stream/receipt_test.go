@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+func TestDeliveryReceipt(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := storage.Address(crypto.Keccak256([]byte("chunk")))
+	receipt, err := NewDeliveryReceipt(addr, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the receipt survives encoding as part of a delivery message
+	data, err := rlp.EncodeToBytes(&ChunkDeliveryMsg{Addr: addr, SData: []byte("data"), Receipt: receipt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg ChunkDeliveryMsg
+	if err := rlp.DecodeBytes(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Receipt == nil {
+		t.Fatal("expected receipt in decoded message")
+	}
+	if err := msg.Receipt.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// messages without receipts are still valid
+	data, err = rlp.EncodeToBytes(&ChunkDeliveryMsg{Addr: addr, SData: []byte("data")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = ChunkDeliveryMsg{}
+	if err := rlp.DecodeBytes(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Receipt != nil {
+		t.Fatal("expected no receipt in decoded message")
+	}
+
+	// a receipt for a different timestamp does not verify
+	receipt.Timestamp++
+	if err := receipt.Verify(); err == nil {
+		t.Fatal("expected tampered receipt to fail verification")
+	}
+
+	// receipts are collected per retrieval from the peers it was requested
+	// from, once per peer
+	d := NewDelivery(nil, nil)
+	receipt.Timestamp--
+	peer := discover.NodeID{1}
+	d.requested(d.newRetrieval(addr), peer)
+	d.addReceipt(peer, addr, receipt)
+	d.addReceipt(peer, addr, receipt)
+	d.addReceipt(discover.NodeID{2}, addr, receipt)
+	d.addReceipt(peer, storage.Address(crypto.Keccak256([]byte("other"))), receipt)
+	if n := len(d.Receipts(addr)); n != 1 {
+		t.Fatalf("expected 1 receipt, got %d", n)
+	}
+
+	// a new retrieval of the chunk does not inherit the receipts
+	r := d.newRetrieval(addr)
+	if n := len(d.Receipts(addr)); n != 0 {
+		t.Fatalf("expected no receipts, got %d", n)
+	}
+
+	// the concurrent receipts of a retrieval are all recorded
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		peer := discover.NodeID{byte(i)}
+		d.requested(r, peer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.addReceipt(peer, addr, receipt)
+		}()
+	}
+	wg.Wait()
+	if n := len(d.Receipts(addr)); n != 16 {
+		t.Fatalf("expected 16 receipts, got %d", n)
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+var (
+	// rateWindow is the period over which the sync ingest rate is measured
+	rateWindow = 10 * time.Second
+	// syncedNotifyDelay is the time the synced event waits for
+	// the subscription to be established
+	syncedNotifyDelay = 1 * time.Second
+)
+
+// SyncCursor is the position of the history sync of a bin from a peer
+type SyncCursor struct {
+	Peer    string `json:"peer"`
+	History uint64 `json:"history"` // upstream index of the last synced history batch
+	LiveAt  uint64 `json:"liveAt"`  // upstream index the live stream started at
+	Behind  uint64 `json:"behind"`  // estimated number of chunks not yet synced
+}
+
+// BinSyncStatus is the sync status of a proximity bin
+type BinSyncStatus struct {
+	Bin     uint8         `json:"bin"`
+	Behind  uint64        `json:"behind"`
+	Cursors []*SyncCursor `json:"cursors"`
+}
+
+// SyncStatus is the sync status of the node as reported by bzz_syncStatus
+type SyncStatus struct {
+	Bins     []*BinSyncStatus `json:"bins"`
+	Behind   uint64           `json:"behind"`   // estimated number of chunks not yet synced
+	Ingested uint64           `json:"ingested"` // number of chunks received by syncing
+	Rate     float64          `json:"rate"`     // chunks received per second
+	ETA      float64          `json:"eta"`      // estimated seconds until synced, -1 if unknown
+	Synced   bool             `json:"synced"`
+}
+
+type syncCursor struct {
+	history    uint64
+	liveAt     uint64
+	hasHistory bool
+	hasLive    bool
+}
+
+// behind returns the estimated number of chunks history sync is behind,
+// the estimate is an upper bound as indexes are shared by all bins
+func (c *syncCursor) behind() uint64 {
+	if !c.hasLive || c.liveAt == 0 {
+		return 0
+	}
+	if !c.hasHistory {
+		return c.liveAt + 1
+	}
+	if c.history >= c.liveAt {
+		return 0
+	}
+	return c.liveAt - c.history
+}
+
+// syncProgress tracks the history sync positions of SYNC streams for all
+// upstream peers and bins and the rate chunks are ingested by syncing
+type syncProgress struct {
+	mu      sync.Mutex
+	cursors map[discover.NodeID]map[uint8]*syncCursor
+
+	ingested    uint64
+	windowStart time.Time
+	windowCount uint64
+	rate        float64
+
+	synced     chan struct{}
+	syncedOnce sync.Once
+}
+
+func newSyncProgress() *syncProgress {
+	return &syncProgress{
+		cursors:     make(map[discover.NodeID]map[uint8]*syncCursor),
+		windowStart: time.Now(),
+		synced:      make(chan struct{}),
+	}
+}
+
+func (p *syncProgress) cursor(id discover.NodeID, bin uint8) *syncCursor {
+	bins, ok := p.cursors[id]
+	if !ok {
+		bins = make(map[uint8]*syncCursor)
+		p.cursors[id] = bins
+	}
+	c, ok := bins[bin]
+	if !ok {
+		c = &syncCursor{}
+		bins[bin] = c
+	}
+	return c
+}
+
+// live records the index the live stream of the bin started at
+func (p *syncProgress) live(id discover.NodeID, bin uint8, from uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.cursor(id, bin)
+	if !c.hasLive {
+		c.liveAt = from
+		c.hasLive = true
+	}
+	p.checkSynced()
+}
+
+// history records the end of a synced history batch of the bin
+func (p *syncProgress) history(id discover.NodeID, bin uint8, to uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.cursor(id, bin)
+	if !c.hasHistory || to > c.history {
+		c.history = to
+		c.hasHistory = true
+	}
+	p.checkSynced()
+}
+
+// ingest counts chunks received by syncing
+func (p *syncProgress) ingest(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ingested += uint64(n)
+	p.updateRate(time.Now())
+}
+
+// offered records the start of the live SYNC stream of a peer
+func (p *syncProgress) offered(id discover.NodeID, s Stream, from uint64) {
+	if s.Name != "SYNC" || !s.Live {
+		return
+	}
+	bin, err := ParseSyncBinKey(s.Key)
+	if err != nil {
+		return
+	}
+	p.live(id, bin, from)
+}
+
+// batchSynced records a batch of a SYNC stream of a peer that is stored
+// with n chunks received
+func (p *syncProgress) batchSynced(id discover.NodeID, s Stream, to uint64, n int) {
+	if s.Name != "SYNC" {
+		return
+	}
+	p.ingest(n)
+	if s.Live {
+		return
+	}
+	bin, err := ParseSyncBinKey(s.Key)
+	if err != nil {
+		return
+	}
+	p.history(id, bin, to)
+}
+
+// removePeer forgets the cursors of a disconnected peer
+func (p *syncProgress) removePeer(id discover.NodeID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cursors, id)
+}
+
+// updateRate closes the measurement window if it is over, the rate
+// is smoothed over the last windows
+func (p *syncProgress) updateRate(now time.Time) {
+	elapsed := now.Sub(p.windowStart)
+	if elapsed < rateWindow {
+		return
+	}
+	rate := float64(p.ingested-p.windowCount) / elapsed.Seconds()
+	if p.rate == 0 {
+		p.rate = rate
+	} else {
+		p.rate = (p.rate + rate) / 2
+	}
+	p.windowStart = now
+	p.windowCount = p.ingested
+}
+
+// checkSynced closes the synced channel once history sync caught up with
+// the live streams of all upstream peers
+func (p *syncProgress) checkSynced() {
+	var n int
+	for _, bins := range p.cursors {
+		for _, c := range bins {
+			if !c.hasLive {
+				continue
+			}
+			if c.behind() > 0 {
+				return
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	p.syncedOnce.Do(func() {
+		log.Info("Swarm history synced")
+		close(p.synced)
+	})
+}
+
+// status returns the current sync status
+func (p *syncProgress) status() *SyncStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.updateRate(time.Now())
+	bins := make(map[uint8]*BinSyncStatus)
+	s := &SyncStatus{
+		Ingested: p.ingested,
+		Rate:     p.rate,
+		ETA:      -1,
+	}
+	for id, cursors := range p.cursors {
+		for bin, c := range cursors {
+			b, ok := bins[bin]
+			if !ok {
+				b = &BinSyncStatus{Bin: bin}
+				bins[bin] = b
+				s.Bins = append(s.Bins, b)
+			}
+			behind := c.behind()
+			b.Cursors = append(b.Cursors, &SyncCursor{
+				Peer:    id.String(),
+				History: c.history,
+				LiveAt:  c.liveAt,
+				Behind:  behind,
+			})
+			b.Behind += behind
+			s.Behind += behind
+		}
+	}
+	sort.Slice(s.Bins, func(i, j int) bool {
+		return s.Bins[i].Bin < s.Bins[j].Bin
+	})
+	for _, b := range s.Bins {
+		sort.Slice(b.Cursors, func(i, j int) bool {
+			return b.Cursors[i].Peer < b.Cursors[j].Peer
+		})
+	}
+	select {
+	case <-p.synced:
+		s.Synced = true
+	default:
+	}
+	switch {
+	case s.Behind == 0:
+		s.ETA = 0
+	case p.rate > 0:
+		s.ETA = float64(s.Behind) / p.rate
+	}
+	return s
+}
+
+// SyncAPI provides the sync progress of the node in the bzz namespace
+type SyncAPI struct {
+	streamer *Registry
+}
+
+// NewSyncAPI creates the sync progress API of the registry
+func NewSyncAPI(r *Registry) *SyncAPI {
+	return &SyncAPI{streamer: r}
+}
+
+// SyncStatus returns the per bin history sync positions, the number of
+// chunks behind, the ingest rate and the estimated time until synced
+func (api *SyncAPI) SyncStatus() *SyncStatus {
+	return api.streamer.progress.status()
+}
+
+// Synced creates a subscription which gets a single notification once
+// history syncing caught up with live syncing
+func (api *SyncAPI) Synced(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		// notifications are dropped until the subscription id is sent
+		// to the client, so do not notify right away if already synced
+		select {
+		case <-time.After(syncedNotifyDelay):
+		case <-notifier.Closed():
+			return
+		}
+		select {
+		case <-api.streamer.progress.synced:
+			if err := notifier.Notify(sub.ID, true); err != nil {
+				log.Warn("rpc sub notifier notify synced", "err", err)
+			}
+		case <-sub.Err():
+		case <-notifier.Closed():
+		}
+	}()
+	return sub, nil
+}
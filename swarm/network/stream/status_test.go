@@ -0,0 +1,77 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+func TestSyncProgress(t *testing.T) {
+	p := newSyncProgress()
+	id := discover.NodeID{1}
+	live := NewStream("SYNC", FormatSyncBinKey(3), true)
+	history := getHistoryStream(live)
+
+	p.offered(id, live, 100)
+	s := p.status()
+	if s.Synced {
+		t.Fatal("expected not synced before history batches")
+	}
+	if len(s.Bins) != 1 || s.Bins[0].Bin != 3 || s.Behind != 101 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if s.ETA != -1 {
+		t.Fatalf("expected unknown ETA, got %v", s.ETA)
+	}
+
+	p.batchSynced(id, history, 40, 20)
+	s = p.status()
+	if s.Behind != 60 || s.Ingested != 20 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if c := s.Bins[0].Cursors[0]; c.History != 40 || c.LiveAt != 100 || c.Behind != 60 {
+		t.Fatalf("unexpected cursor %+v", c)
+	}
+
+	// the rate is measured once a window is over
+	p.mu.Lock()
+	p.windowStart = time.Now().Add(-rateWindow)
+	p.mu.Unlock()
+	s = p.status()
+	if s.Rate <= 0 || s.ETA <= 0 {
+		t.Fatalf("expected rate and ETA, got %+v", s)
+	}
+
+	p.batchSynced(id, history, 100, 30)
+	select {
+	case <-p.synced:
+	default:
+		t.Fatal("expected synced event")
+	}
+	s = p.status()
+	if !s.Synced || s.Behind != 0 || s.ETA != 0 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+
+	p.removePeer(id)
+	if s = p.status(); len(s.Bins) != 0 {
+		t.Fatalf("expected no bins after peer removal, got %+v", s.Bins)
+	}
+}
@@ -18,11 +18,13 @@ package stream
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"math"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -60,6 +62,14 @@ type Registry struct {
 	delivery       *Delivery
 	intervalsStore state.Store
 	doRetrieve     bool
+	receiptKey     *ecdsa.PrivateKey
+	compression    bool
+	syncBatchSize  int
+	offerWindow    int
+	syncBins       *binLimiter
+	progress       *syncProgress
+	capacity       capacity // local storage capacity
+	capacitySub    event.Subscription
 }
 
 // RegistryOptions holds optional values for NewRegistry constructor.
@@ -68,6 +78,12 @@ type RegistryOptions struct {
 	DoSync          bool
 	DoRetrieve      bool
 	SyncUpdateDelay time.Duration
+	ReceiptKey      *ecdsa.PrivateKey // if set, delivered chunks are accompanied by a receipt signed with this key
+	SyncBatchSize   int               // maximum number of hashes offered in a sync batch
+	OfferWindow     int               // number of offered hashes batches per stream in flight
+	BinConcurrency  int               // number of sync streams per bin served concurrently
+	RetrieveFanout  int               // number of closest peers a chunk is requested from in parallel
+	Compression     bool              // if set, chunks are delivered compressed to the peers with FeatureCompression
 }
 
 // NewRegistry is Streamer constructor
@@ -78,6 +94,15 @@ func NewRegistry(addr *network.BzzAddr, delivery *Delivery, db *storage.DBAPI, i
 	if options.SyncUpdateDelay <= 0 {
 		options.SyncUpdateDelay = 15 * time.Second
 	}
+	if options.SyncBatchSize <= 0 {
+		options.SyncBatchSize = BatchSize
+	}
+	if options.OfferWindow <= 0 {
+		options.OfferWindow = DefaultOfferWindow
+	}
+	if options.BinConcurrency <= 0 {
+		options.BinConcurrency = DefaultBinConcurrency
+	}
 	streamer := &Registry{
 		addr:           addr,
 		skipCheck:      options.SkipCheck,
@@ -87,9 +112,18 @@ func NewRegistry(addr *network.BzzAddr, delivery *Delivery, db *storage.DBAPI, i
 		delivery:       delivery,
 		intervalsStore: intervalsStore,
 		doRetrieve:     options.DoRetrieve,
+		receiptKey:     options.ReceiptKey,
+		compression:    options.Compression,
+		syncBatchSize:  options.SyncBatchSize,
+		offerWindow:    options.OfferWindow,
+		syncBins:       newBinLimiter(options.BinConcurrency),
+		progress:       newSyncProgress(),
 	}
 	streamer.api = NewAPI(streamer)
 	delivery.getPeer = streamer.getPeer
+	if options.RetrieveFanout > 0 {
+		delivery.fanout = options.RetrieveFanout
+	}
 	streamer.RegisterServerFunc(swarmChunkServerStreamName, func(_ *Peer, _ string, _ bool) (Server, error) {
 		return NewSwarmChunkServer(delivery.db), nil
 	})
@@ -98,6 +132,7 @@ func NewRegistry(addr *network.BzzAddr, delivery *Delivery, db *storage.DBAPI, i
 	})
 	RegisterSwarmSyncerServer(streamer, db)
 	RegisterSwarmSyncerClient(streamer, db)
+	streamer.watchCapacity(db)
 
 	if options.DoSync {
 		// latestIntC function ensures that
@@ -336,6 +371,7 @@ func (r *Registry) PeerInfo(id discover.NodeID) interface{} {
 }
 
 func (r *Registry) Close() error {
+	r.capacitySub.Unsubscribe()
 	return r.intervalsStore.Close()
 }
 
@@ -358,6 +394,7 @@ func (r *Registry) deletePeer(peer *Peer) {
 	delete(r.peers, peer.ID())
 	metrics.GetOrRegisterGauge("registry.peers", nil).Update(int64(len(r.peers)))
 	r.peersMu.Unlock()
+	r.progress.removePeer(peer.ID())
 }
 
 func (r *Registry) peersCount() (c int) {
@@ -370,11 +407,18 @@ func (r *Registry) peersCount() (c int) {
 // Run protocol run function
 func (r *Registry) Run(p *network.BzzPeer) error {
 	sp := NewPeer(p.Peer, r)
+	// the features are set before the peer is shared
+	sp.compression = r.compression && p.HasFeature(FeatureCompression)
+	sp.cancelRequests = p.HasFeature(FeatureCancelRequest)
 	r.setPeer(sp)
 	defer r.deletePeer(sp)
 	defer close(sp.quit)
 	defer sp.close()
 
+	if r.capacity.wait() != nil {
+		go sp.sendCapacity(true)
+	}
+
 	if r.doRetrieve {
 		err := r.Subscribe(p.ID(), NewStream(swarmChunkServerStreamName, "", false), nil, Top)
 		if err != nil {
@@ -451,9 +495,44 @@ func (r *Registry) updateSyncing() {
 	}
 }
 
+// Resync subscribes to the SYNC streams of the given bins with the peers in
+// these bins which the node does not sync them from, so that the chunks lost
+// from the local store are retrieved again
+//
+// Streams which are already synced are left alone, their history is offered
+// from the start on every connection.
+func (r *Registry) Resync(bins []uint8) {
+	resync := make(map[int]bool)
+	for _, bin := range bins {
+		resync[int(bin)] = true
+	}
+	kad := r.delivery.overlay.(*network.Kademlia)
+	kad.EachBin(r.addr.Over(), pot.DefaultPof(256), 0, func(conn network.OverlayConn, bin int) bool {
+		if !resync[bin] {
+			return true
+		}
+		p := r.getPeer(conn.(network.Peer).ID())
+		if p == nil {
+			return true
+		}
+		stream := NewStream("SYNC", FormatSyncBinKey(uint8(bin)), true)
+		p.clientMu.RLock()
+		_, synced := p.clients[stream]
+		p.clientMu.RUnlock()
+		if synced {
+			return true
+		}
+		log.Info("resyncing lost bin", "peer", p.ID(), "bin", bin)
+		if err := r.Subscribe(p.ID(), stream, NewRange(0, 0), High); err != nil {
+			log.Warn("resync subscription", "peer", p.ID(), "bin", bin, "err", err)
+		}
+		return true
+	})
+}
+
 func (r *Registry) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	peer := protocols.NewPeer(p, rw, Spec)
-	bzzPeer := network.NewBzzTestPeer(peer, r.addr)
+	bzzPeer := network.NewBzzTestPeerVersion(peer, r.addr, Versions, Spec.Version)
 	r.delivery.overlay.On(bzzPeer)
 	defer r.delivery.overlay.Off(bzzPeer)
 	return r.Run(bzzPeer)
@@ -487,12 +566,25 @@ func (p *Peer) HandleMsg(msg interface{}) error {
 	case *RetrieveRequestMsg:
 		return p.streamer.delivery.handleRetrieveRequestMsg(p, msg)
 
+	case *CancelRetrieveRequestMsg:
+		return p.streamer.delivery.handleCancelRetrieveRequestMsg(p, msg)
+
 	case *RequestSubscriptionMsg:
 		return p.handleRequestSubscription(msg)
 
 	case *QuitMsg:
 		return p.handleQuitMsg(msg)
 
+	case *CapacityMsg:
+		return p.handleCapacityMsg(msg)
+
+	case *CompressedChunkDeliveryMsg:
+		req, err := msg.decompress()
+		if err != nil {
+			return fmt.Errorf("compressed chunk %s: %v", msg.Addr, err)
+		}
+		return p.streamer.delivery.handleChunkDeliveryMsg(p, req)
+
 	default:
 		return fmt.Errorf("unknown message type: %T", msg)
 	}
@@ -503,6 +595,12 @@ type server struct {
 	stream       Stream
 	priority     uint8
 	currentBatch []byte
+
+	cursorMu    sync.Mutex
+	cursor      *serverCursor
+	cursorKey   string
+	cursorStore state.Store
+	pending     uint64 // index following the batch offered before the current one
 }
 
 // Server interface for outgoing peer Streamer
@@ -512,6 +610,19 @@ type Server interface {
 	Close()
 }
 
+// chunkServer is implemented by servers that can return the stored chunk of
+// a wanted hash, so that the tag the chunk was put with counts its delivery
+type chunkServer interface {
+	GetChunk([]byte) (*storage.Chunk, error)
+}
+
+// historyServer is implemented by servers that can bound their history
+// stream to the start of the live stream it is paired with, so that
+// catching up on history does not overlap with syncing new chunks
+type historyServer interface {
+	BoundHistory()
+}
+
 type client struct {
 	Client
 	stream    Stream
@@ -526,7 +637,11 @@ type client struct {
 }
 
 func peerStreamIntervalsKey(p *Peer, s Stream) string {
-	return p.ID().String() + s.String()
+	return streamIntervalsKey(p.ID(), s)
+}
+
+func streamIntervalsKey(id discover.NodeID, s Stream) string {
+	return id.String() + s.String()
 }
 
 func (c client) AddInterval(start, end uint64) (err error) {
@@ -645,7 +760,7 @@ func (c *clientParams) clientCreated() {
 // Spec is the spec of the streamer protocol
 var Spec = &protocols.Spec{
 	Name:       "stream",
-	Version:    4,
+	Version:    8,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		UnsubscribeMsg{},
@@ -658,6 +773,20 @@ var Spec = &protocols.Spec{
 		SubscribeErrorMsg{},
 		RequestSubscriptionMsg{},
 		QuitMsg{},
+		CapacityMsg{},
+		CompressedChunkDeliveryMsg{},
+		CancelRetrieveRequestMsg{},
+	},
+}
+
+// Versions are the versions of the streamer protocol run with the peers, the
+// newest version shared with a peer is negotiated on connection
+var Versions = &network.VersionedSpec{
+	Spec:       Spec,
+	MinVersion: 6,
+	Features: []network.Feature{
+		{Name: FeatureCompression, Version: 7, Messages: []interface{}{CompressedChunkDeliveryMsg{}}},
+		{Name: FeatureCancelRequest, Version: 8, Messages: []interface{}{CancelRetrieveRequestMsg{}}},
 	},
 }
 
@@ -690,7 +819,14 @@ func (r *Registry) Start(server *p2p.Server) error {
 	return nil
 }
 
+// Stop drops the stream peers so that no chunks are offered, requested or
+// delivered while the node is shutting down
 func (r *Registry) Stop() error {
+	r.peersMu.RLock()
+	defer r.peersMu.RUnlock()
+	for _, p := range r.peers {
+		p.Drop(nil)
+	}
 	return nil
 }
 
@@ -737,3 +873,28 @@ func (api *API) SubscribeStream(peerId discover.NodeID, s Stream, history *Range
 func (api *API) UnsubscribeStream(peerId discover.NodeID, s Stream) error {
 	return api.streamer.Unsubscribe(peerId, s)
 }
+
+// StreamIntervals are the ranges of a stream fully received from a peer and
+// the first gap in them, which is requested from the peer next
+type StreamIntervals struct {
+	Peer   discover.NodeID `json:"peer"`
+	Stream Stream          `json:"stream"`
+	Ranges [][2]uint64     `json:"ranges"`
+	Next   [2]uint64       `json:"next"` // end is 0 if the gap is unbounded
+}
+
+// Intervals returns the persisted intervals of the stream received from the
+// peer, it is meant for debugging
+func (api *API) Intervals(peerId discover.NodeID, s Stream) (*StreamIntervals, error) {
+	i := &intervals.Intervals{}
+	if err := api.streamer.intervalsStore.Get(streamIntervalsKey(peerId, s), i); err != nil {
+		return nil, err
+	}
+	start, end := i.Next()
+	return &StreamIntervals{
+		Peer:   peerId,
+		Stream: s,
+		Ranges: i.Ranges(),
+		Next:   [2]uint64{start, end},
+	}, nil
+}
@@ -281,6 +281,106 @@ func TestStreamerUpstreamSubscribeUnsubscribeMsgExchange(t *testing.T) {
 	}
 }
 
+// TestStreamerUpstreamResumeHistory tests that a history stream is offered
+// from the index following the last batch the peer stored when the peer
+// subscribes again
+func TestStreamerUpstreamResumeHistory(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("foo", "", false)
+
+	streamer.RegisterServerFunc("foo", func(p *Peer, t string, live bool) (Server, error) {
+		return newTestServer(t), nil
+	})
+
+	peerID := tester.IDs[0]
+	offered := func(from, to uint64) p2ptest.Expect {
+		return p2ptest.Expect{
+			Code: 1,
+			Msg: &OfferedHashesMsg{
+				Stream: stream,
+				HandoverProof: &HandoverProof{
+					Handover: &Handover{},
+				},
+				Hashes: make([]byte, HashSize),
+				From:   from,
+				To:     to,
+			},
+			Peer: peerID,
+		}
+	}
+	subscribe := func(from, to uint64) p2ptest.Trigger {
+		return p2ptest.Trigger{
+			Code: 4,
+			Msg: &SubscribeMsg{
+				Stream:   stream,
+				History:  NewRange(from, to),
+				Priority: Top,
+			},
+			Peer: peerID,
+		}
+	}
+	wanted := func(from, to uint64) p2ptest.Trigger {
+		return p2ptest.Trigger{
+			Code: 2,
+			Msg: &WantedHashesMsg{
+				Stream: stream,
+				Want:   []byte{0},
+				From:   from,
+				To:     to,
+			},
+			Peer: peerID,
+		}
+	}
+
+	// the second wanted hashes message acknowledges the first batch
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label:    "Subscribe message",
+			Triggers: []p2ptest.Trigger{subscribe(5, 8)},
+			Expects:  []p2ptest.Expect{offered(6, 9)},
+		},
+		p2ptest.Exchange{
+			Label:    "first wanted hashes message",
+			Triggers: []p2ptest.Trigger{wanted(10, 13)},
+			Expects:  []p2ptest.Expect{offered(11, 14)},
+		},
+		p2ptest.Exchange{
+			Label:    "second wanted hashes message",
+			Triggers: []p2ptest.Trigger{wanted(15, 18)},
+			Expects:  []p2ptest.Expect{offered(16, 19)},
+		},
+		p2ptest.Exchange{
+			Label: "unsubscribe message",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 0,
+					Msg: &UnsubscribeMsg{
+						Stream: stream,
+					},
+					Peer: peerID,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label:    "resubscribe message",
+		Triggers: []p2ptest.Trigger{subscribe(5, 30)},
+		Expects:  []p2ptest.Expect{offered(11, 31)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestStreamerUpstreamSubscribeUnsubscribeMsgExchangeLive(t *testing.T) {
 	tester, streamer, _, teardown, err := newStreamerTester(t)
 	defer teardown()
@@ -28,6 +28,7 @@ import (
 
 const (
 	// BatchSize = 2
+	// BatchSize is the default maximum number of hashes offered in a batch
 	BatchSize = 128
 )
 
@@ -36,11 +37,16 @@ const (
 // * live request delivery with or without checkback
 // * (live/non-live historical) chunk syncing per proximity bin
 type SwarmSyncerServer struct {
+	live      bool
+	bounded   bool // history ends where the paired live stream starts
 	po        uint8
 	db        *storage.DBAPI
 	sessionAt uint64
 	start     uint64
 	quit      chan struct{}
+	sizer     *batchSizer // adapts the number of offered hashes
+	offeredAt time.Time   // time the last batch was offered
+	bins      *binLimiter // limits concurrent iterations of the bin
 }
 
 // NewSwarmSyncerServer is contructor for SwarmSyncerServer
@@ -51,11 +57,13 @@ func NewSwarmSyncerServer(live bool, po uint8, db *storage.DBAPI) (*SwarmSyncerS
 		start = sessionAt
 	}
 	return &SwarmSyncerServer{
+		live:      live,
 		po:        po,
 		db:        db,
 		sessionAt: sessionAt,
 		start:     start,
 		quit:      make(chan struct{}),
+		sizer:     newBatchSizer(BatchSize),
 	}, nil
 }
 
@@ -65,13 +73,27 @@ func RegisterSwarmSyncerServer(streamer *Registry, db *storage.DBAPI) {
 		if err != nil {
 			return nil, err
 		}
-		return NewSwarmSyncerServer(live, po, db)
+		s, err := NewSwarmSyncerServer(live, po, db)
+		if err != nil {
+			return nil, err
+		}
+		s.sizer = newBatchSizer(streamer.syncBatchSize)
+		s.bins = streamer.syncBins
+		return s, nil
 	})
 	// streamer.RegisterServerFunc(stream, func(p *Peer) (Server, error) {
 	// 	return NewOutgoingProvableSwarmSyncer(po, db)
 	// })
 }
 
+// BoundHistory limits a history stream to the chunks stored before the
+// server was created, the rest is synced by the paired live stream
+func (s *SwarmSyncerServer) BoundHistory() {
+	if !s.live {
+		s.bounded = true
+	}
+}
+
 // Close needs to be called on a stream server
 func (s *SwarmSyncerServer) Close() {
 	close(s.quit)
@@ -79,13 +101,22 @@ func (s *SwarmSyncerServer) Close() {
 
 // GetSection retrieves the actual chunk from localstore
 func (s *SwarmSyncerServer) GetData(key []byte) ([]byte, error) {
+	chunk, err := s.GetChunk(key)
+	if err != nil {
+		return nil, err
+	}
+	return chunk.SData, nil
+}
+
+// GetChunk retrieves the chunk from the localstore
+func (s *SwarmSyncerServer) GetChunk(key []byte) (*storage.Chunk, error) {
 	chunk, err := s.db.Get(storage.Address(key))
 	if err == storage.ErrFetching {
 		<-chunk.ReqC
 	} else if err != nil {
 		return nil, err
 	}
-	return chunk.SData, nil
+	return chunk, nil
 }
 
 // GetBatch retrieves the next batch of hashes from the dbstore
@@ -98,6 +129,21 @@ func (s *SwarmSyncerServer) SetNextBatch(from, to uint64) ([]byte, uint64, uint6
 	if to <= from || from >= s.sessionAt {
 		to = math.MaxUint64
 	}
+	if s.bounded {
+		if from > s.sessionAt {
+			// history is synced, no more batches to offer
+			return nil, 0, 0, nil, nil
+		}
+		if to > s.sessionAt {
+			to = s.sessionAt
+		}
+	}
+	// the next batch is requested once the downstream peer
+	// received the previous one and sent the wanted hashes
+	if !s.offeredAt.IsZero() {
+		s.sizer.Update(time.Since(s.offeredAt))
+	}
+	batchSize := s.sizer.Size()
 	var ticker *time.Ticker
 	defer func() {
 		if ticker != nil {
@@ -118,22 +164,35 @@ func (s *SwarmSyncerServer) SetNextBatch(from, to uint64) ([]byte, uint64, uint6
 		}
 
 		metrics.GetOrRegisterCounter("syncer.setnextbatch.iterator", nil).Inc(1)
+		// only history streams compete for the bin, live streams are
+		// served right away so that new chunks propagate quickly
+		if !s.live && !s.bins.acquire(s.po, s.quit) {
+			return nil, 0, 0, nil, nil
+		}
 		err := s.db.Iterator(from, to, s.po, func(addr storage.Address, idx uint64) bool {
 			batch = append(batch, addr[:]...)
 			i++
 			to = idx
-			return i < BatchSize
+			return i < batchSize
 		})
+		if !s.live {
+			s.bins.release(s.po)
+		}
 		if err != nil {
 			return nil, 0, 0, nil, err
 		}
 		if len(batch) > 0 {
 			break
 		}
+		if s.bounded {
+			// nothing left in the bounded range
+			return nil, 0, 0, nil, nil
+		}
 		wait = true
 	}
 
 	log.Trace("Swarm syncer offer batch", "po", s.po, "len", i, "from", from, "to", to, "current store count", s.db.CurrentBucketStorageIndex(s.po))
+	s.offeredAt = time.Now()
 	return batch, from, to, nil, nil
 }
 
@@ -23,6 +23,7 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -265,3 +266,65 @@ func testSyncBetweenNodes(t *testing.T, nodes, conns, chunkCount int, skipCheck
 	}
 	streamTesting.CheckResult(t, result, startedAt, finishedAt)
 }
+
+// TestSwarmSyncerServerBoundedHistory checks that a history stream paired
+// with a live stream only offers chunks stored before it was created
+func TestSwarmSyncerServerBoundedHistory(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "syncer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	params := storage.NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	params.BaseKey = make([]byte, 32)
+	localStore, err := storage.NewTestLocalStoreForAddr(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+	db := storage.NewDBAPI(localStore)
+
+	// all chunks with a first bit set fall into bin 0
+	put := func(n int) {
+		for stored := 0; stored < n; {
+			chunk := storage.GenerateRandomChunks(storage.DefaultChunkSize, 1)[0]
+			if storage.Proximity(params.BaseKey, chunk.Addr) != 0 {
+				continue
+			}
+			localStore.Put(chunk)
+			if err := chunk.WaitToStore(); err != nil {
+				t.Fatal(err)
+			}
+			stored++
+		}
+	}
+	put(10)
+
+	s, err := NewSwarmSyncerServer(false, 0, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.BoundHistory()
+
+	put(10)
+
+	var offered int
+	var from uint64
+	for {
+		hashes, _, to, _, err := s.SetNextBatch(from, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hashes) == 0 {
+			break
+		}
+		offered += len(hashes) / HashSize
+		from = to + 1
+	}
+	if offered != 10 {
+		t.Fatalf("expected 10 offered hashes, got %d", offered)
+	}
+}
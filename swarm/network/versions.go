@@ -0,0 +1,192 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+)
+
+// Feature is an optional feature of a bzz subprotocol, such as a new message
+// type or a change of the wire format, introduced in a version of the
+// protocol. Peers running older versions of the protocol lack the feature.
+type Feature struct {
+	Name     string
+	Version  uint          // version of the protocol introducing the feature
+	Messages []interface{} // message types introduced with the feature
+}
+
+// VersionedSpec is the spec of a bzz subprotocol together with the features
+// introduced by its versions. Nodes run all the versions from the version of
+// the spec down to MinVersion, so that nodes are able to connect during the
+// rollout of a new version. The newest version shared by two peers is
+// negotiated when they connect and determines the features available.
+//
+// Messages introduced with a feature must be listed in the spec after the
+// messages of the older versions, so that their codes are kept.
+type VersionedSpec struct {
+	*protocols.Spec
+	MinVersion uint // oldest version run, only the version of the spec if 0
+	Features   []Feature
+}
+
+// Versions returns the specs of the versions of the protocol from the newest
+// to the oldest, without the messages introduced by later versions
+func (s *VersionedSpec) Versions() []*protocols.Spec {
+	specs := []*protocols.Spec{s.Spec}
+	if s.MinVersion == 0 {
+		return specs
+	}
+	for version := s.Version - 1; version >= s.MinVersion; version-- {
+		spec := &protocols.Spec{
+			Name:       s.Name,
+			Version:    version,
+			MaxMsgSize: s.MaxMsgSize,
+		}
+		for _, msg := range s.Messages {
+			if !s.introducedAfter(msg, version) {
+				spec.Messages = append(spec.Messages, msg)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// introducedAfter returns true if the message type is introduced by a feature
+// of a version later than the given one
+func (s *VersionedSpec) introducedAfter(msg interface{}, version uint) bool {
+	typ := reflect.TypeOf(msg)
+	for _, f := range s.Features {
+		if f.Version <= version {
+			continue
+		}
+		for _, m := range f.Messages {
+			if reflect.TypeOf(m) == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FeaturesAt returns the names of the features available at the version
+func (s *VersionedSpec) FeaturesAt(version uint) []string {
+	var features []string
+	for _, f := range s.Features {
+		if f.Version <= version {
+			features = append(features, f.Name)
+		}
+	}
+	return features
+}
+
+// ProtocolInfo is the version of a subprotocol negotiated with a peer and the
+// features it makes available
+type ProtocolInfo struct {
+	Version  uint     `json:"version"`
+	Features []string `json:"features"`
+}
+
+// NewBzzTestPeerVersion is NewBzzTestPeer for a peer running the given
+// version of the protocol
+func NewBzzTestPeerVersion(p *protocols.Peer, addr *BzzAddr, spec *VersionedSpec, version uint) *BzzPeer {
+	peer := NewBzzTestPeer(p, addr)
+	peer.protocol = &ProtocolInfo{Version: version, Features: spec.FeaturesAt(version)}
+	return peer
+}
+
+// ProtocolVersion returns the version of the protocol negotiated with the peer
+func (p *BzzPeer) ProtocolVersion() uint {
+	if p.protocol == nil {
+		return 0
+	}
+	return p.protocol.Version
+}
+
+// HasFeature returns true if the feature is available with the peer
+func (p *BzzPeer) HasFeature(name string) bool {
+	if p.protocol == nil {
+		return false
+	}
+	for _, f := range p.protocol.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setProtocol records the version of a subprotocol negotiated with the peer
+func (b *Bzz) setProtocol(id discover.NodeID, name string, info *ProtocolInfo) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	running, ok := b.protocols[id]
+	if !ok {
+		running = make(map[string]*ProtocolInfo)
+		b.protocols[id] = running
+	}
+	running[name] = info
+}
+
+// removeProtocol removes the record of a subprotocol run with the peer
+func (b *Bzz) removeProtocol(id discover.NodeID, name string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	delete(b.protocols[id], name)
+	if len(b.protocols[id]) == 0 {
+		delete(b.protocols, id)
+	}
+}
+
+// PeerProtocols are the subprotocols run with a connected peer
+type PeerProtocols struct {
+	ID        discover.NodeID          `json:"id"`
+	Protocols map[string]*ProtocolInfo `json:"protocols"`
+}
+
+// FeaturesAPI exposes the protocol versions and features negotiated with the
+// connected peers in the bzz namespace, the features of the bzz handshake are
+// the capabilities of the peer
+type FeaturesAPI struct {
+	bzz *Bzz
+}
+
+// PeerFeatures returns the versions and features of the subprotocols
+// negotiated with each connected peer
+func (api *FeaturesAPI) PeerFeatures() []*PeerProtocols {
+	api.bzz.mtx.Lock()
+	defer api.bzz.mtx.Unlock()
+	peers := make([]*PeerProtocols, 0, len(api.bzz.protocols))
+	for id, running := range api.bzz.protocols {
+		p := &PeerProtocols{
+			ID:        id,
+			Protocols: make(map[string]*ProtocolInfo, len(running)),
+		}
+		for name, info := range running {
+			p.Protocols[name] = info
+		}
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID.String() < peers[j].ID.String()
+	})
+	return peers
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+)
+
+func newTestVersionedSpec() *VersionedSpec {
+	return &VersionedSpec{
+		Spec: &protocols.Spec{
+			Name:     "test",
+			Version:  3,
+			Messages: []interface{}{testV1Msg{}, testV2Msg{}, testV3Msg{}},
+		},
+		MinVersion: 1,
+		Features: []Feature{
+			{Name: "v2", Version: 2, Messages: []interface{}{testV2Msg{}}},
+			{Name: "v3", Version: 3, Messages: []interface{}{testV3Msg{}}},
+			{Name: "v3-format", Version: 3},
+		},
+	}
+}
+
+type (
+	testV1Msg struct{}
+	testV2Msg struct{}
+	testV3Msg struct{}
+)
+
+// TestVersionedSpec tests that the specs of the older versions of a protocol
+// leave out the messages of the features introduced later
+func TestVersionedSpec(t *testing.T) {
+	spec := newTestVersionedSpec()
+	versions := spec.Versions()
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(versions))
+	}
+	for i, expected := range []struct {
+		version  uint
+		length   uint64
+		features []string
+	}{
+		{3, 3, []string{"v2", "v3", "v3-format"}},
+		{2, 2, []string{"v2"}},
+		{1, 1, nil},
+	} {
+		v := versions[i]
+		if v.Version != expected.version || v.Length() != expected.length {
+			t.Fatalf("expected version %d with %d messages, got version %d with %d", expected.version, expected.length, v.Version, v.Length())
+		}
+		if code, ok := v.GetCode(testV1Msg{}); !ok || code != 0 {
+			t.Fatalf("version %d: expected code 0 for the first message, got %d (%v)", v.Version, code, ok)
+		}
+		if features := spec.FeaturesAt(v.Version); !reflect.DeepEqual(features, expected.features) {
+			t.Fatalf("version %d: expected features %v, got %v", v.Version, expected.features, features)
+		}
+	}
+
+	single := &VersionedSpec{Spec: spec.Spec}
+	if versions := single.Versions(); len(versions) != 1 || versions[0] != spec.Spec {
+		t.Fatalf("expected only the version of the spec, got %d versions", len(versions))
+	}
+}
+
+// TestFeaturesAPI tests that all the versions of the streamer protocol are
+// offered and that the versions run with the peers are exposed
+func TestFeaturesAPI(t *testing.T) {
+	addr := RandomAddr()
+	config := &BzzConfig{
+		OverlayAddr:  addr.Over(),
+		UnderlayAddr: addr.Under(),
+		HiveParams:   NewHiveParams(),
+		NetworkID:    DefaultNetworkID,
+	}
+	spec := newTestVersionedSpec()
+	bzz := NewBzz(config, NewKademlia(addr.OAddr, NewKadParams()), nil, spec, func(*BzzPeer) error { return nil })
+
+	var versions []uint
+	for _, p := range bzz.Protocols() {
+		if p.Name == spec.Name {
+			versions = append(versions, p.Version)
+		}
+	}
+	if !reflect.DeepEqual(versions, []uint{3, 2, 1}) {
+		t.Fatalf("expected versions 3, 2 and 1 to be offered, got %v", versions)
+	}
+
+	api := &FeaturesAPI{bzz}
+	id := NewNodeIDFromAddr(RandomAddr())
+	bzz.setProtocol(id, spec.Name, &ProtocolInfo{Version: 2, Features: spec.FeaturesAt(2)})
+	peers := api.PeerFeatures()
+	if len(peers) != 1 || peers[0].ID != id {
+		t.Fatalf("expected the features of peer %v, got %v", id, peers)
+	}
+	if info := peers[0].Protocols[spec.Name]; info == nil || info.Version != 2 || !reflect.DeepEqual(info.Features, []string{"v2"}) {
+		t.Fatalf("expected version 2 with feature v2, got %v", info)
+	}
+	bzz.removeProtocol(id, spec.Name)
+	if peers := api.PeerFeatures(); len(peers) != 0 {
+		t.Fatalf("expected no peers, got %d", len(peers))
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package swarm
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// DefaultNodeName is the name of the p2p node of an embedded swarm node, the
+// swarm data is stored in the directory of that name in the data directory
+const DefaultNodeName = "swarm"
+
+// NodeConfig is the configuration of a swarm node embedded in an application
+type NodeConfig struct {
+	// PrivateKey is the key of the swarm account of the node
+	PrivateKey *ecdsa.PrivateKey
+
+	// Swarm is the configuration of the swarm service, api.NewConfig() if
+	// nil. Its Path is replaced with the data directory of the node.
+	Swarm *api.Config
+
+	// Node is the configuration of the p2p node running the swarm service,
+	// DefaultNodeConfig() if nil
+	Node *node.Config
+}
+
+// DefaultNodeConfig returns the p2p node configuration of an embedded swarm
+// node, which stores its data in the default data directory and does not
+// open RPC endpoints
+func DefaultNodeConfig() *node.Config {
+	cfg := node.DefaultConfig
+	cfg.Name = DefaultNodeName
+	cfg.P2P.ListenAddr = ":30399"
+	return &cfg
+}
+
+// Node is a swarm node embedded in an application, it runs the swarm service
+// in its own p2p node without parsing command line flags
+type Node struct {
+	stack *node.Node
+	swarm *Swarm
+}
+
+// NewNode creates a swarm node with the given configuration, the node is
+// not connected to the network until it is started
+func NewNode(config *NodeConfig) (*Node, error) {
+	if config.PrivateKey == nil {
+		return nil, errors.New("missing swarm account key")
+	}
+	bzzconfig := config.Swarm
+	if bzzconfig == nil {
+		bzzconfig = api.NewConfig()
+	}
+	cfg := DefaultNodeConfig()
+	if config.Node != nil {
+		*cfg = *config.Node
+	}
+	// the p2p node falls back to the name of the executable otherwise
+	if cfg.Name == "" {
+		cfg.Name = DefaultNodeName
+	}
+	if cfg.DataDir == "" {
+		return nil, errors.New("missing data directory")
+	}
+	stack, err := node.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bzzconfig.Path = stack.InstanceDir()
+	bzzconfig.Init(config.PrivateKey)
+
+	self, err := NewSwarm(bzzconfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := stack.Register(func(*node.ServiceContext) (node.Service, error) {
+		return self, nil
+	}); err != nil {
+		return nil, err
+	}
+	return &Node{stack: stack, swarm: self}, nil
+}
+
+// Start starts the p2p node and the swarm service, a stopped node cannot be
+// started again
+func (n *Node) Start() error {
+	return n.stack.Start()
+}
+
+// Stop stops the swarm service and the p2p node
+func (n *Node) Stop() error {
+	return n.stack.Stop()
+}
+
+// Stack returns the p2p node running the swarm service
+func (n *Node) Stack() *node.Node {
+	return n.stack
+}
+
+// Swarm returns the swarm service of the node
+func (n *Node) Swarm() *Swarm {
+	return n.swarm
+}
+
+// API returns the high level API of the node to store and retrieve content
+// and manifests
+func (n *Node) API() *api.API {
+	return n.swarm.api
+}
+
+// FileStore returns the document level storage of the node
+func (n *Node) FileStore() *storage.FileStore {
+	return n.swarm.fileStore
+}
+
+// LocalStore returns the local chunk store of the node
+func (n *Node) LocalStore() *storage.LocalStore {
+	return n.swarm.lstore
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// DefaultAckTimeout is the time the API waits for the acknowledgement of a
+// message sent with an ack request
+const DefaultAckTimeout = 30 * time.Second
+
+// ackTopic is the topic acknowledgements are sent on
+var ackTopic = BytesToTopic([]byte("pss_ack"))
+
+var (
+	ackSentCounter     = metrics.NewRegisteredCounter("pss.ack.sent", nil)
+	ackReceivedCounter = metrics.NewRegisteredCounter("pss.ack.received", nil)
+	ackTimeoutCounter  = metrics.NewRegisteredCounter("pss.ack.timeout", nil)
+
+	// ErrAckTimeout is returned when no acknowledgement of a message is
+	// received before the timeout
+	ErrAckTimeout = errors.New("ack timeout")
+)
+
+// ackRequest wraps the payload of a message requesting an acknowledgement,
+// the ack is sent to the From address encrypted with the key the message
+// was signed with
+type ackRequest struct {
+	ID   []byte
+	From []byte
+	Data []byte
+}
+
+// ack is the payload of an acknowledgement, it is signed and encrypted by
+// the pss layer of the recipient like any other message
+type ack struct {
+	ID []byte
+}
+
+// pendingAck is a sent message waiting for its acknowledgement
+type pendingAck struct {
+	recipient string // public key the ack must be signed with, empty for any
+	c         chan error
+	timer     *time.Timer
+}
+
+// SendSymWithAck sends a message using symmetric encryption like SendSym
+// and requests an acknowledgement from its recipient. The returned channel
+// receives nil when the first acknowledgement is received, or ErrAckTimeout
+// if none is received within the timeout.
+func (p *Pss) SendSymWithAck(symkeyid string, topic Topic, msg []byte, timeout time.Duration) (<-chan error, error) {
+	id, payload, c, err := p.requestAck(msg, "", timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.sendSym(symkeyid, topic, payload, true); err != nil {
+		p.resolveAck(id, err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// SendAsymWithAck sends a message using asymmetric encryption like SendAsym
+// and requests an acknowledgement from its recipient, which must be signed
+// with the public key of the recipient. The returned channel receives nil
+// when the acknowledgement is received, or ErrAckTimeout if none is
+// received within the timeout.
+func (p *Pss) SendAsymWithAck(pubkeyid string, topic Topic, msg []byte, timeout time.Duration) (<-chan error, error) {
+	id, payload, c, err := p.requestAck(msg, common.ToHex(common.FromHex(pubkeyid)), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.sendAsym(pubkeyid, topic, payload, true); err != nil {
+		p.resolveAck(id, err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// requestAck registers a pending acknowledgement resolved with ErrAckTimeout
+// after the timeout and wraps the message in an ack request
+func (p *Pss) requestAck(msg []byte, recipient string, timeout time.Duration) (string, []byte, <-chan error, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, nil, err
+	}
+	payload, err := rlp.EncodeToBytes(&ackRequest{
+		ID:   id,
+		From: p.BaseAddr(),
+		Data: msg,
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	key := common.ToHex(id)
+	pa := &pendingAck{
+		recipient: recipient,
+		c:         make(chan error, 1),
+	}
+	p.acksMu.Lock()
+	p.acks[key] = pa
+	pa.timer = time.AfterFunc(timeout, func() {
+		if p.resolveAck(key, ErrAckTimeout) {
+			ackTimeoutCounter.Inc(1)
+		}
+	})
+	p.acksMu.Unlock()
+	return key, payload, pa.c, nil
+}
+
+// resolveAck removes the pending acknowledgement and sends the result on
+// its channel, it returns false if the ack is not pending
+func (p *Pss) resolveAck(id string, err error) bool {
+	p.acksMu.Lock()
+	pa, ok := p.acks[id]
+	delete(p.acks, id)
+	p.acksMu.Unlock()
+	if !ok {
+		return false
+	}
+	pa.timer.Stop()
+	pa.c <- err
+	return true
+}
+
+// sendAck acknowledges the message with the request to its sender
+func (p *Pss) sendAck(req *ackRequest, src *ecdsa.PublicKey) {
+	if src == nil {
+		log.Debug("pss ack requested by unsigned message")
+		return
+	}
+	data, err := rlp.EncodeToBytes(&ack{ID: req.ID})
+	if err != nil {
+		log.Error("pss ack encode", "err", err)
+		return
+	}
+	go func() {
+		if err := p.send(req.From, ackTopic, data, true, crypto.FromECDSAPub(src), false); err != nil {
+			log.Warn("pss ack send", "err", err)
+			return
+		}
+		ackSentCounter.Inc(1)
+	}()
+}
+
+// handleAck resolves the pending acknowledgement of an incoming ack
+func (p *Pss) handleAck(msg []byte, _ *p2p.Peer, asymmetric bool, keyid string) error {
+	if !asymmetric {
+		return nil
+	}
+	var a ack
+	if err := rlp.DecodeBytes(msg, &a); err != nil {
+		return err
+	}
+	id := common.ToHex(a.ID)
+	p.acksMu.Lock()
+	pa, ok := p.acks[id]
+	p.acksMu.Unlock()
+	if !ok {
+		return nil
+	}
+	if pa.recipient != "" && pa.recipient != keyid {
+		log.Debug("pss ack signed by another key", "id", id, "key", keyid)
+		return nil
+	}
+	if p.resolveAck(id, nil) {
+		ackReceivedCounter.Inc(1)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// TestAck tests that the recipient of a message requesting an ack receives
+// the message payload and that its acknowledgement resolves the send
+func TestAck(t *testing.T) {
+	sender := newUnstartedPss(t, NewPssParams())
+	recipient := newUnstartedPss(t, NewPssParams())
+	topic := BytesToTopic([]byte("ack"))
+
+	received := make(chan string, 10)
+	recipient.Register(&topic, func(msg []byte, _ *p2p.Peer, _ bool, _ string) error {
+		received <- string(msg)
+		return nil
+	})
+
+	// take a message from the outbox of a pss, skipping the messages on
+	// other topics
+	next := func(ps *Pss, topic Topic) *PssMsg {
+		for {
+			select {
+			case msg := <-ps.outbox:
+				if Topic(msg.Payload.Topic) == topic {
+					return msg
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for a message on topic %x", topic)
+			}
+		}
+	}
+	deliver := func(c <-chan error, data string) {
+		if err := recipient.handlePssMsg(next(sender, topic)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-received:
+			if msg != data {
+				t.Fatalf("expected message %q, got %q", data, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the message to be handled")
+		}
+		if err := sender.handlePssMsg(next(recipient, ackTopic)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-c:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the ack")
+		}
+	}
+
+	// asymmetric
+	pubkeyid := common.ToHex(crypto.FromECDSAPub(recipient.PublicKey()))
+	if err := sender.SetPeerPublicKey(recipient.PublicKey(), topic, &PssAddress{}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := sender.SendAsymWithAck(pubkeyid, topic, []byte("asymmetric"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliver(c, "asymmetric")
+
+	// symmetric
+	symkey := make([]byte, 32)
+	symkey[0] = 42
+	symkeyid, err := sender.SetSymmetricKey(symkey, topic, &PssAddress{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recipient.SetSymmetricKey(symkey, topic, &PssAddress{}, true); err != nil {
+		t.Fatal(err)
+	}
+	c, err = sender.SendSymWithAck(symkeyid, topic, []byte("symmetric"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliver(c, "symmetric")
+
+	// timeout
+	c, err = sender.SendSymWithAck(symkeyid, topic, []byte("lost"), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-c:
+		if err != ErrAckTimeout {
+			t.Fatalf("expected ack timeout, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the ack timeout")
+	}
+	if len(sender.acks) != 0 {
+		t.Fatalf("expected no pending acks, got %d", len(sender.acks))
+	}
+}
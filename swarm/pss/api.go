@@ -17,9 +17,11 @@
 package pss
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -129,6 +131,70 @@ func (pssapi *API) SetPeerPublicKey(pubkey hexutil.Bytes, topic Topic, addr PssA
 	return nil
 }
 
+// KeyInfo describes the association of a key with a topic and the address
+// hint of the peer it is shared with
+type KeyInfo struct {
+	Key     string     `json:"key"` // symmetric key id or public key in hex
+	Topic   Topic      `json:"topic"`
+	Address PssAddress `json:"address"`
+}
+
+// Generate a new symmetric key for a topic and address hint, the key is
+// used for decryption attempts if addToCache is true
+func (pssapi *API) GenerateSymmetricKey(topic Topic, addr PssAddress, addToCache bool) (string, error) {
+	return pssapi.Pss.GenerateSymmetricKey(topic, &addr, addToCache)
+}
+
+// Set a symmetric key shared with a peer for a topic and address hint, the
+// key is used for decryption attempts if addToCache is true
+func (pssapi *API) SetSymmetricKey(symkey hexutil.Bytes, topic Topic, addr PssAddress, addToCache bool) (string, error) {
+	return pssapi.Pss.SetSymmetricKey(symkey, topic, &addr, addToCache)
+}
+
+// Remove a symmetric key for all its topics
+func (pssapi *API) RemoveSymmetricKey(symkeyid string) error {
+	return pssapi.Pss.RemoveSymmetricKey(symkeyid)
+}
+
+// Remove the association of a peer public key with a topic
+func (pssapi *API) RemovePeerPublicKey(pubkeyhex string, topic Topic) error {
+	return pssapi.Pss.RemovePeerPublicKey(pubkeyhex, topic)
+}
+
+// List the symmetric key ids with their topics and address hints
+func (pssapi *API) GetSymmetricKeys() []KeyInfo {
+	pssapi.Pss.symKeyPoolMu.RLock()
+	defer pssapi.Pss.symKeyPoolMu.RUnlock()
+	return keyInfos(pssapi.Pss.symKeyPool)
+}
+
+// List the peer public keys with their topics and address hints
+func (pssapi *API) GetPeerPublicKeys() []KeyInfo {
+	pssapi.Pss.pubKeyPoolMu.RLock()
+	defer pssapi.Pss.pubKeyPoolMu.RUnlock()
+	return keyInfos(pssapi.Pss.pubKeyPool)
+}
+
+func keyInfos(pool map[string]map[Topic]*pssPeer) []KeyInfo {
+	infos := []KeyInfo{}
+	for key, topics := range pool {
+		for topic, peer := range topics {
+			info := KeyInfo{Key: key, Topic: topic}
+			if peer.address != nil {
+				info.Address = *peer.address
+			}
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Key != infos[j].Key {
+			return infos[i].Key < infos[j].Key
+		}
+		return bytes.Compare(infos[i].Topic[:], infos[j].Topic[:]) < 0
+	})
+	return infos
+}
+
 func (pssapi *API) GetSymmetricKey(symkeyid string) (hexutil.Bytes, error) {
 	symkey, err := pssapi.Pss.GetSymmetricKey(symkeyid)
 	return hexutil.Bytes(symkey), err
@@ -158,6 +224,35 @@ func (pssapi *API) SendSym(symkeyhex string, topic Topic, msg hexutil.Bytes) err
 	return pssapi.Pss.SendSym(symkeyhex, topic, msg[:])
 }
 
+// Send a message using asymmetric encryption and wait for the
+// acknowledgement of its recipient, for at most DefaultAckTimeout
+func (pssapi *API) SendAsymWithAck(ctx context.Context, pubkeyhex string, topic Topic, msg hexutil.Bytes) error {
+	c, err := pssapi.Pss.SendAsymWithAck(pubkeyhex, topic, msg[:], DefaultAckTimeout)
+	if err != nil {
+		return err
+	}
+	return waitAck(ctx, c)
+}
+
+// Send a message using symmetric encryption and wait for the
+// acknowledgement of a recipient, for at most DefaultAckTimeout
+func (pssapi *API) SendSymWithAck(ctx context.Context, symkeyhex string, topic Topic, msg hexutil.Bytes) error {
+	c, err := pssapi.Pss.SendSymWithAck(symkeyhex, topic, msg[:], DefaultAckTimeout)
+	if err != nil {
+		return err
+	}
+	return waitAck(ctx, c)
+}
+
+func waitAck(ctx context.Context, c <-chan error) error {
+	select {
+	case err := <-c:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (pssapi *API) GetPeerTopics(pubkeyhex string) ([]Topic, error) {
 	topics, _, err := pssapi.Pss.GetPublickeyPeers(pubkeyhex)
 	return topics, err
@@ -167,3 +262,12 @@ func (pssapi *API) GetPeerTopics(pubkeyhex string) ([]Topic, error) {
 func (pssapi *API) GetPeerAddress(pubkeyhex string, topic Topic) (PssAddress, error) {
 	return pssapi.Pss.getPeerAddress(pubkeyhex, topic)
 }
+
+// RelayQueue returns the messages waiting in the relay queue of the node
+// for a peer to be forwarded to
+func (pssapi *API) RelayQueue() ([]*RelayInfo, error) {
+	if pssapi.Pss.relayQueue == nil {
+		return nil, errors.New("relay queue disabled")
+	}
+	return pssapi.Pss.relayQueue.list(), nil
+}
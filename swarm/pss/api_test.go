@@ -0,0 +1,109 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TestKeyManagementAPI tests generating, importing, listing and removing
+// keys over RPC
+func TestKeyManagementAPI(t *testing.T) {
+	ps := newUnstartedPss(t, NewPssParams())
+	server := rpc.NewServer()
+	if err := server.RegisterName("pss", NewAPI(ps)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	topic := BytesToTopic([]byte("keys"))
+	addr := PssAddress{0x01, 0x02}
+
+	var generated string
+	if err := client.Call(&generated, "pss_generateSymmetricKey", topic, addr, true); err != nil {
+		t.Fatal(err)
+	}
+	symkey := make([]byte, 32)
+	symkey[0] = 42
+	var imported string
+	if err := client.Call(&imported, "pss_setSymmetricKey", hexutil.Bytes(symkey), topic, addr, false); err != nil {
+		t.Fatal(err)
+	}
+	var got hexutil.Bytes
+	if err := client.Call(&got, "pss_getSymmetricKey", imported); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, symkey) {
+		t.Fatalf("expected imported key %x, got %x", symkey, got)
+	}
+
+	var keys []KeyInfo
+	if err := client.Call(&keys, "pss_getSymmetricKeys"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 symmetric keys, got %d", len(keys))
+	}
+	for _, key := range keys {
+		if key.Key != generated && key.Key != imported || key.Topic != topic || !bytes.Equal(key.Address, addr) {
+			t.Fatalf("unexpected symmetric key %+v", key)
+		}
+	}
+	if err := client.Call(nil, "pss_removeSymmetricKey", generated); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&keys, "pss_getSymmetricKeys"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != imported {
+		t.Fatalf("expected only the imported key to be left, got %+v", keys)
+	}
+	if err := client.Call(nil, "pss_removeSymmetricKey", generated); err == nil {
+		t.Fatal("expected an error removing a removed key")
+	}
+
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey := crypto.FromECDSAPub(&privkey.PublicKey)
+	if err := client.Call(nil, "pss_setPeerPublicKey", hexutil.Bytes(pubkey), topic, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&keys, "pss_getPeerPublicKeys"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != common.ToHex(pubkey) || keys[0].Topic != topic {
+		t.Fatalf("unexpected peer public keys %+v", keys)
+	}
+	if err := client.Call(nil, "pss_removePeerPublicKey", common.ToHex(pubkey), topic); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&keys, "pss_getPeerPublicKeys"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no peer public keys, got %+v", keys)
+	}
+}
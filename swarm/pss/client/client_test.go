@@ -61,11 +61,13 @@ var (
 var services = newServices()
 
 func init() {
+	adapters.RegisterServices(services)
+}
+
+func TestMain(m *testing.M) {
 	flag.Parse()
 	rand.Seed(time.Now().Unix())
 
-	adapters.RegisterServices(services)
-
 	loglevel := log.LvlInfo
 	if *debugflag {
 		loglevel = log.LvlDebug
@@ -83,6 +85,7 @@ func init() {
 	wapi = whisper.NewPublicWhisperAPI(w)
 
 	pssprotocols = make(map[string]*protoCtrl)
+	os.Exit(m.Run())
 }
 
 // ping pong exchange across one expired symkey
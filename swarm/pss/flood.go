@@ -0,0 +1,136 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	floodPoWCounter  = metrics.NewRegisteredCounter("pss.flood.pow", nil)
+	floodRateCounter = metrics.NewRegisteredCounter("pss.flood.rate", nil)
+
+	errLowPoW      = errors.New("insufficient proof of work")
+	errRateLimited = errors.New("topic rate limit exceeded")
+)
+
+// topicLimiter is a token bucket limiting the rate of the messages on a
+// topic, a limiter with a rate of 0 does not limit
+type topicLimiter struct {
+	rate   float64 // tokens added per second
+	burst  float64 // capacity of the bucket
+	tokens float64
+	last   time.Time
+	pinned bool // set explicitly, not removed when idle
+}
+
+func newTopicLimiter(rate float64, burst int, now time.Time) *topicLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &topicLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// allow takes a token from the bucket and returns true if there was one
+func (l *topicLimiter) allow(now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.refill(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *topicLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// SetTopicLimit sets the rate in messages per second and the burst of the
+// incoming messages on the topic, overriding the defaults of the pss
+// parameters. A rate of 0 removes the limit of the topic.
+func (p *Pss) SetTopicLimit(topic Topic, rate float64, burst int) {
+	p.topicLimitsMu.Lock()
+	defer p.topicLimitsMu.Unlock()
+	l := newTopicLimiter(rate, burst, time.Now())
+	l.pinned = true
+	p.topicLimits[topic] = l
+}
+
+// admit checks that an incoming message for the node carries enough proof
+// of work and is within the rate limit of its topic, before it is decrypted
+// and dispatched to the handlers of its topic
+//
+// Raw messages are not sealed with a proof of work, so only their rate is
+// limited.
+func (p *Pss) admit(msg *PssMsg) error {
+	if p.minPoW > 0 && !msg.isRaw() && msg.Payload.PoW() < p.minPoW {
+		floodPoWCounter.Inc(1)
+		return errLowPoW
+	}
+
+	topic := Topic(msg.Payload.Topic)
+	now := time.Now()
+	p.topicLimitsMu.Lock()
+	defer p.topicLimitsMu.Unlock()
+	l, ok := p.topicLimits[topic]
+	if !ok {
+		if p.topicRate <= 0 {
+			return nil
+		}
+		l = newTopicLimiter(p.topicRate, p.topicBurst, now)
+		p.topicLimits[topic] = l
+	}
+	if !l.allow(now) {
+		floodRateCounter.Inc(1)
+		return fmt.Errorf("%v: %x", errRateLimited, topic)
+	}
+	return nil
+}
+
+// cleanTopicLimits removes the limiters of the topics which have not been
+// limited since their buckets filled up, so that messages on many topics do
+// not accumulate limiters
+func (p *Pss) cleanTopicLimits() {
+	now := time.Now()
+	p.topicLimitsMu.Lock()
+	defer p.topicLimitsMu.Unlock()
+	for topic, l := range p.topicLimits {
+		if l.pinned {
+			continue
+		}
+		l.refill(now)
+		if l.tokens >= l.burst {
+			delete(p.topicLimits, topic)
+		}
+	}
+}
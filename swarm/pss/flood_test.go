@@ -0,0 +1,99 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"testing"
+	"time"
+
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+)
+
+// TestTopicRateLimit tests that the incoming messages on a topic are
+// limited to the burst and refilled at the rate of the topic
+func TestTopicRateLimit(t *testing.T) {
+	params := NewPssParams()
+	params.TopicRate = 10
+	params.TopicBurst = 2
+	ps := newUnstartedPss(t, params)
+
+	newMsg := func(topic string) *PssMsg {
+		msg := newPssMsg(&msgParams{raw: true})
+		msg.Payload = &whisper.Envelope{Topic: whisper.BytesToTopic([]byte(topic))}
+		return msg
+	}
+	for i := 0; i < 2; i++ {
+		if err := ps.admit(newMsg("foo")); err != nil {
+			t.Fatalf("expected message %d to be admitted, got %v", i, err)
+		}
+	}
+	if err := ps.admit(newMsg("foo")); err == nil {
+		t.Fatal("expected the message above the burst to be limited")
+	}
+	if err := ps.admit(newMsg("bar")); err != nil {
+		t.Fatalf("expected the limit to be per topic, got %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := ps.admit(newMsg("foo")); err != nil {
+		t.Fatalf("expected the bucket to be refilled, got %v", err)
+	}
+
+	// the limit of a topic can be removed
+	ps.SetTopicLimit(Topic(newMsg("foo").Payload.Topic), 0, 0)
+	for i := 0; i < 10; i++ {
+		if err := ps.admit(newMsg("foo")); err != nil {
+			t.Fatalf("expected the topic to be unlimited, got %v", err)
+		}
+	}
+
+	// idle limiters are removed, explicitly set ones are kept
+	ps.cleanTopicLimits()
+	time.Sleep(250 * time.Millisecond)
+	ps.cleanTopicLimits()
+	if len(ps.topicLimits) != 1 {
+		t.Fatalf("expected only the explicit limit to be kept, got %d limiters", len(ps.topicLimits))
+	}
+}
+
+// TestMinPoW tests that incoming messages are only admitted with the proof
+// of work required by the node
+func TestMinPoW(t *testing.T) {
+	params := NewPssParams()
+	params.MinPoW = 0.01
+	recipient := newUnstartedPss(t, params)
+
+	topic := BytesToTopic([]byte("pow"))
+	send := func(sender *Pss) *PssMsg {
+		symkeyid, err := sender.GenerateSymmetricKey(topic, &PssAddress{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sender.SendSym(symkeyid, topic, []byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+		return <-sender.outbox
+	}
+
+	params = NewPssParams()
+	params.MinPoW = 0.01
+	if err := recipient.admit(send(newUnstartedPss(t, params))); err != nil {
+		t.Fatalf("expected a message with enough proof of work to be admitted, got %v", err)
+	}
+	if err := recipient.admit(send(newUnstartedPss(t, NewPssParams()))); err != errLowPoW {
+		t.Fatalf("expected a message without proof of work to be refused, got %v", err)
+	}
+}
@@ -261,7 +261,10 @@ func (ctl *HandshakeController) cleanHandshake(pubkeyid string, topic *Topic, in
 	}
 	for _, keyid := range deletes {
 		delete(ctl.symKeyIndex, keyid)
-		ctl.pss.symKeyPool[keyid][*topic].protected = false
+		// the key may have been removed through the API
+		if psp := ctl.pss.symKeyPool[keyid][*topic]; psp != nil {
+			psp.protected = false
+		}
 	}
 	return len(deletes)
 }
@@ -29,7 +29,7 @@ var (
 	wapi     *whisper.PublicWhisperAPI
 )
 
-func init() {
+func TestMain(m *testing.M) {
 	flag.Parse()
 	hs := log.StreamHandler(os.Stderr, log.TerminalFormat(true))
 	hf := log.LvlFilterHandler(log.Lvl(*loglevel), hs)
@@ -39,6 +39,7 @@ func init() {
 	w = whisper.New(&whisper.DefaultConfig)
 	wapi = whisper.NewPublicWhisperAPI(w)
 	psses = make(map[string]*pss.Pss)
+	os.Exit(m.Run())
 }
 
 // Creates a client node and notifier node
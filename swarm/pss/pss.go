@@ -31,10 +31,12 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/protocols"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/network"
 	"github.com/ethereum/go-ethereum/swarm/pot"
+	"github.com/ethereum/go-ethereum/swarm/state"
 	"github.com/ethereum/go-ethereum/swarm/storage"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
 )
@@ -89,6 +91,21 @@ type PssParams struct {
 	privateKey          *ecdsa.PrivateKey
 	SymKeyCacheCapacity int
 	AllowRaw            bool // If true, enables sending and receiving messages without builtin pss encryption
+
+	// RelayTTL enables the relay queue if it is set, messages which cannot
+	// be forwarded to any peer are kept in the queue and retried with a
+	// backoff for at most RelayTTL
+	RelayTTL      time.Duration
+	RelayCapacity int // maximum number of messages in the relay queue
+	stateStore    state.Store
+
+	// MinPoW is the proof of work required of incoming encrypted messages
+	// and the target of sent messages, 0 disables the requirement
+	MinPoW float64
+	// TopicRate limits the incoming messages on each topic to this number
+	// of messages per second with bursts of TopicBurst, 0 disables the limit
+	TopicRate  float64
+	TopicBurst int
 }
 
 // Sane defaults for Pss
@@ -105,6 +122,12 @@ func (params *PssParams) WithPrivateKey(privatekey *ecdsa.PrivateKey) *PssParams
 	return params
 }
 
+// WithStateStore sets the store the relay queue is persisted in
+func (params *PssParams) WithStateStore(store state.Store) *PssParams {
+	params.stateStore = store
+	return params
+}
+
 // Toplevel pss object, takes care of message sending, receiving, decryption and encryption, message handler dispatchers and message forwarding.
 //
 // Implements node.Service
@@ -124,6 +147,7 @@ type Pss struct {
 	paddingByteSize int
 	capstring       string
 	outbox          chan *PssMsg
+	relayQueue      *relayQueue // messages to unreachable recipients, nil if disabled
 
 	// keys and peers
 	pubKeyPool                 map[string]map[Topic]*pssPeer // mapping of hex public keys to peer address by topic.
@@ -140,6 +164,17 @@ type Pss struct {
 	allowRaw   bool
 	hashPool   sync.Pool
 
+	// flood control
+	minPoW        float64
+	topicRate     float64
+	topicBurst    int
+	topicLimits   map[Topic]*topicLimiter
+	topicLimitsMu sync.Mutex
+
+	// acknowledgements
+	acks   map[string]*pendingAck // sent messages waiting for an ack by request id
+	acksMu sync.Mutex
+
 	// process
 	quitC chan struct{}
 }
@@ -186,6 +221,17 @@ func NewPss(k network.Overlay, params *PssParams) (*Pss, error) {
 				return storage.MakeHashFunc(storage.DefaultHash)()
 			},
 		},
+
+		minPoW:      params.MinPoW,
+		topicRate:   params.TopicRate,
+		topicBurst:  params.TopicBurst,
+		topicLimits: make(map[Topic]*topicLimiter),
+		acks:        make(map[string]*pendingAck),
+	}
+	ps.Register(&ackTopic, ps.handleAck)
+
+	if params.RelayTTL > 0 {
+		ps.relayQueue = newRelayQueue(params.RelayTTL, params.RelayCapacity, params.stateStore)
 	}
 
 	for i := 0; i < hasherCount; i++ {
@@ -210,6 +256,7 @@ func (p *Pss) Start(srv *p2p.Server) error {
 			select {
 			case <-cacheTicker.C:
 				p.cleanFwdCache()
+				p.cleanTopicLimits()
 			case <-ticker.C:
 				p.cleanKeys()
 			case <-p.quitC:
@@ -231,6 +278,20 @@ func (p *Pss) Start(srv *p2p.Server) error {
 			}
 		}
 	}()
+	if p.relayQueue != nil {
+		go func() {
+			ticker := time.NewTicker(relayRetryInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.retryRelay()
+				case <-p.quitC:
+					return
+				}
+			}
+		}()
+	}
 	log.Debug("Started pss", "public key", common.ToHex(crypto.FromECDSAPub(p.PublicKey())))
 	return nil
 }
@@ -277,6 +338,12 @@ func (p *Pss) APIs() []rpc.API {
 			Service:   NewAPI(p),
 			Public:    true,
 		},
+		{
+			Namespace: "shh",
+			Version:   "1.0",
+			Service:   NewShhAPI(p),
+			Public:    true,
+		},
 	}
 	apis = append(apis, p.auxAPIs...)
 	return apis
@@ -395,6 +462,14 @@ func (p *Pss) process(pssmsg *PssMsg) error {
 
 	envelope := pssmsg.Payload
 	psstopic := Topic(envelope.Topic)
+	if err := p.admit(pssmsg); err != nil {
+		log.Trace("pss message not admitted", "topic", psstopic, "err", err)
+		// messages to a partial address are forwarded regardless
+		if len(pssmsg.To) < addressLength {
+			return p.enqueue(pssmsg)
+		}
+		return nil
+	}
 	if pssmsg.isRaw() {
 		if !p.allowRaw {
 			return errors.New("raw message support disabled")
@@ -415,12 +490,26 @@ func (p *Pss) process(pssmsg *PssMsg) error {
 		payload = recvmsg.Payload
 	}
 
+	// the payload of a message requesting an acknowledgement is wrapped
+	// with the id of the request and the address of the sender
+	var ackReq *ackRequest
+	if pssmsg.wantsAck() && recvmsg != nil {
+		ackReq = &ackRequest{}
+		if err := rlp.DecodeBytes(payload, ackReq); err != nil {
+			return fmt.Errorf("invalid ack request: %v", err)
+		}
+		payload = ackReq.Data
+	}
+
 	if len(pssmsg.To) < addressLength {
 		if err := p.enqueue(pssmsg); err != nil {
 			return err
 		}
 	}
 	p.executeHandlers(psstopic, payload, from, asymmetric, keyid)
+	if ackReq != nil {
+		p.sendAck(ackReq, recvmsg.Src)
+	}
 
 	return nil
 
@@ -536,6 +625,34 @@ func (p *Pss) addSymmetricKeyToPool(keyid string, topic Topic, address *PssAddre
 	log.Trace("added symkey", "symkeyid", keyid, "symkey", common.ToHex(key), "topic", topic, "address", fmt.Sprintf("%p", address), "cache", addtocache)
 }
 
+// Removes a symmetric key from the key pool and the whisper backend
+//
+// Messages encrypted with the key can no longer be sent or decrypted
+func (p *Pss) RemoveSymmetricKey(symkeyid string) error {
+	p.symKeyPoolMu.Lock()
+	_, ok := p.symKeyPool[symkeyid]
+	delete(p.symKeyPool, symkeyid)
+	p.symKeyPoolMu.Unlock()
+	if !p.w.DeleteSymKey(symkeyid) && !ok {
+		return fmt.Errorf("symkey %s not found", symkeyid)
+	}
+	return nil
+}
+
+// Removes the association of a peer public key with a topic
+func (p *Pss) RemovePeerPublicKey(pubkeyid string, topic Topic) error {
+	p.pubKeyPoolMu.Lock()
+	defer p.pubKeyPoolMu.Unlock()
+	if _, ok := p.pubKeyPool[pubkeyid][topic]; !ok {
+		return fmt.Errorf("pubkey/topic pair %s/%x not found", pubkeyid, topic)
+	}
+	delete(p.pubKeyPool[pubkeyid], topic)
+	if len(p.pubKeyPool[pubkeyid]) == 0 {
+		delete(p.pubKeyPool, pubkeyid)
+	}
+	return nil
+}
+
 // Returns a symmetric key byte seqyence stored in the whisper backend
 // by its unique id
 //
@@ -705,6 +822,10 @@ func (p *Pss) SendRaw(address PssAddress, topic Topic, msg []byte) error {
 //
 // Fails if the key id does not match any of the stored symmetric keys
 func (p *Pss) SendSym(symkeyid string, topic Topic, msg []byte) error {
+	return p.sendSym(symkeyid, topic, msg, false)
+}
+
+func (p *Pss) sendSym(symkeyid string, topic Topic, msg []byte, ack bool) error {
 	symkey, err := p.GetSymmetricKey(symkeyid)
 	if err != nil {
 		return fmt.Errorf("missing valid send symkey %s: %v", symkeyid, err)
@@ -717,7 +838,7 @@ func (p *Pss) SendSym(symkeyid string, topic Topic, msg []byte) error {
 	} else if psp.address == nil {
 		return fmt.Errorf("no address hint for topic '%s' symkey '%s'", topic.String(), symkeyid)
 	}
-	err = p.send(*psp.address, topic, msg, false, symkey)
+	err = p.send(*psp.address, topic, msg, false, symkey, ack)
 	return err
 }
 
@@ -725,6 +846,10 @@ func (p *Pss) SendSym(symkeyid string, topic Topic, msg []byte) error {
 //
 // Fails if the key id does not match any in of the stored public keys
 func (p *Pss) SendAsym(pubkeyid string, topic Topic, msg []byte) error {
+	return p.sendAsym(pubkeyid, topic, msg, false)
+}
+
+func (p *Pss) sendAsym(pubkeyid string, topic Topic, msg []byte, ack bool) error {
 	if _, err := crypto.UnmarshalPubkey(common.FromHex(pubkeyid)); err != nil {
 		return fmt.Errorf("Cannot unmarshal pubkey: %x", pubkeyid)
 	}
@@ -737,7 +862,7 @@ func (p *Pss) SendAsym(pubkeyid string, topic Topic, msg []byte) error {
 		return fmt.Errorf("no address hint for topic '%s' pubkey '%s'", topic.String(), pubkeyid)
 	}
 	go func() {
-		p.send(*psp.address, topic, msg, true, common.FromHex(pubkeyid))
+		p.send(*psp.address, topic, msg, true, common.FromHex(pubkeyid), ack)
 	}()
 	return nil
 }
@@ -746,7 +871,7 @@ func (p *Pss) SendAsym(pubkeyid string, topic Topic, msg []byte) error {
 // It generates an whisper envelope for the specified recipient and topic,
 // and wraps the message payload in it.
 // TODO: Implement proper message padding
-func (p *Pss) send(to []byte, topic Topic, msg []byte, asymmetric bool, key []byte) error {
+func (p *Pss) send(to []byte, topic Topic, msg []byte, asymmetric bool, key []byte, ack bool) error {
 	metrics.GetOrRegisterCounter("pss.send", nil).Inc(1)
 
 	if key == nil || bytes.Equal(key, []byte{}) {
@@ -777,6 +902,9 @@ func (p *Pss) send(to []byte, topic Topic, msg []byte, asymmetric bool, key []by
 	} else {
 		wparams.KeySym = key
 	}
+	if p.minPoW > 0 {
+		wparams.PoW = p.minPoW
+	}
 	// set up outgoing message container, which does encryption and envelope wrapping
 	woutmsg, err := whisper.NewSentMessage(wparams)
 	if err != nil {
@@ -794,6 +922,7 @@ func (p *Pss) send(to []byte, topic Topic, msg []byte, asymmetric bool, key []by
 	// prepare for devp2p transport
 	pssMsgParams := &msgParams{
 		sym: !asymmetric,
+		ack: ack,
 	}
 	pssMsg := newPssMsg(pssMsgParams)
 	pssMsg.To = to
@@ -808,6 +937,26 @@ func (p *Pss) send(to []byte, topic Topic, msg []byte, asymmetric bool, key []by
 func (p *Pss) forward(msg *PssMsg) error {
 	metrics.GetOrRegisterCounter("pss.forward", nil).Inc(1)
 
+	if sent := p.forwardToPeers(msg); sent == 0 {
+		log.Debug("unable to forward to any peers")
+		if p.relayQueue != nil {
+			return p.relay(msg)
+		}
+		if err := p.enqueue(msg); err != nil {
+			metrics.GetOrRegisterCounter("pss.forward.enqueue.error", nil).Inc(1)
+			log.Error(err.Error())
+			return err
+		}
+	}
+
+	// cache the message
+	p.addFwdCache(msg)
+	return nil
+}
+
+// forwardToPeers sends the message to the peers closest to its recipient
+// and returns the number of peers it was sent to
+func (p *Pss) forwardToPeers(msg *PssMsg) int {
 	to := make([]byte, addressLength)
 	copy(to[:len(msg.To)], msg.To)
 
@@ -870,19 +1019,7 @@ func (p *Pss) forward(msg *PssMsg) error {
 		// - partial addresses don't fully match
 		return false
 	})
-
-	if sent == 0 {
-		log.Debug("unable to forward to any peers")
-		if err := p.enqueue(msg); err != nil {
-			metrics.GetOrRegisterCounter("pss.forward.enqueue.error", nil).Inc(1)
-			log.Error(err.Error())
-			return err
-		}
-	}
-
-	// cache the message
-	p.addFwdCache(msg)
-	return nil
+	return sent
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -65,11 +65,14 @@ var (
 )
 
 func init() {
+	adapters.RegisterServices(newServices(false))
+}
+
+func TestMain(m *testing.M) {
 	flag.Parse()
 	rand.Seed(time.Now().Unix())
-
-	adapters.RegisterServices(newServices(false))
 	initTest()
+	os.Exit(m.Run())
 }
 
 func initTest() {
@@ -1663,6 +1666,23 @@ func newTestPss(privkey *ecdsa.PrivateKey, overlay network.Overlay, ppextra *Pss
 	return ps
 }
 
+// newUnstartedPss creates a pss which is not started, so that the messages
+// it sends stay in its outbox
+func newUnstartedPss(t *testing.T, params *PssParams) *Pss {
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nid discover.NodeID
+	copy(nid[:], crypto.FromECDSAPub(&privkey.PublicKey))
+	addr := network.NewAddrFromNodeID(nid)
+	ps, err := NewPss(network.NewKademlia(addr.Over(), network.NewKadParams()), params.WithPrivateKey(privkey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
 // API calls for test/development use
 type APITest struct {
 	*Pss
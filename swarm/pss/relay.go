@@ -0,0 +1,275 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/state"
+)
+
+const (
+	defaultRelayCapacity  = 1000
+	maxRelayRetryInterval = 5 * time.Minute
+	relayIndexKey         = "pss_relay_index"
+	relayKeyPrefix        = "pss_relay_"
+)
+
+// relayRetryInterval is the interval at which the relay queue is checked
+// for messages due for a retry, and the backoff of their first retry
+var relayRetryInterval = time.Second
+
+var (
+	relayQueuedCounter  = metrics.NewRegisteredCounter("pss.relay.queued", nil)
+	relaySentCounter    = metrics.NewRegisteredCounter("pss.relay.sent", nil)
+	relayExpiredCounter = metrics.NewRegisteredCounter("pss.relay.expired", nil)
+	relayFullCounter    = metrics.NewRegisteredCounter("pss.relay.full", nil)
+)
+
+var errRelayFull = errors.New("relay queue full")
+
+// relayEntry is a message which could not be forwarded to any peer, it is
+// retried with an exponential backoff until it expires
+type relayEntry struct {
+	Msg      *PssMsg
+	Queued   uint64 // unix time
+	Expire   uint64 // unix time
+	Attempts uint64
+	Next     uint64 // unix nanoseconds
+}
+
+// MarshalBinary encodes the entry with rlp, the wire encoding of messages
+func (e *relayEntry) MarshalBinary() ([]byte, error) {
+	return rlp.EncodeToBytes(e)
+}
+
+// UnmarshalBinary decodes an entry encoded by MarshalBinary
+func (e *relayEntry) UnmarshalBinary(data []byte) error {
+	return rlp.DecodeBytes(data, e)
+}
+
+// RelayInfo describes a message in the relay queue
+type RelayInfo struct {
+	ID          string        `json:"id"`
+	To          hexutil.Bytes `json:"to"`
+	Topic       Topic         `json:"topic"`
+	Queued      time.Time     `json:"queued"`
+	Expire      time.Time     `json:"expire"`
+	Attempts    uint64        `json:"attempts"`
+	NextAttempt time.Time     `json:"nextAttempt"`
+}
+
+// relayQueue holds the messages to recipients which are not reachable
+// through any peer, the queue is persisted in the state store if there is
+// one so that the messages survive a restart
+type relayQueue struct {
+	ttl      time.Duration
+	capacity int
+	store    state.Store
+
+	mu      sync.Mutex
+	entries map[string]*relayEntry
+}
+
+// newRelayQueue creates a relay queue keeping messages for at most ttl and
+// loads the messages persisted in the store
+func newRelayQueue(ttl time.Duration, capacity int, store state.Store) *relayQueue {
+	if capacity <= 0 {
+		capacity = defaultRelayCapacity
+	}
+	q := &relayQueue{
+		ttl:      ttl,
+		capacity: capacity,
+		store:    store,
+		entries:  make(map[string]*relayEntry),
+	}
+	if store == nil {
+		return q
+	}
+	var ids []string
+	if err := store.Get(relayIndexKey, &ids); err != nil && err != state.ErrNotFound {
+		log.Error("pss relay queue load", "err", err)
+	}
+	now := time.Now()
+	for _, id := range ids {
+		e := &relayEntry{}
+		if err := store.Get(relayKeyPrefix+id, e); err != nil {
+			log.Error("pss relay queue load", "id", id, "err", err)
+			continue
+		}
+		if int64(e.Expire) < now.Unix() {
+			store.Delete(relayKeyPrefix + id)
+			continue
+		}
+		e.Next = uint64(now.UnixNano())
+		q.entries[id] = e
+	}
+	q.persistIndex()
+	return q
+}
+
+// add queues the message with the given id, the message is kept until the
+// ttl of the queue or its own expiry, whichever is earlier
+func (q *relayQueue) add(id string, msg *PssMsg) error {
+	now := time.Now()
+	expire := uint64(now.Add(q.ttl).Unix())
+	if uint64(msg.Expire) < expire {
+		expire = uint64(msg.Expire)
+	}
+	if int64(expire) < now.Unix() {
+		relayExpiredCounter.Inc(1)
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.entries[id]; ok {
+		return nil
+	}
+	if len(q.entries) >= q.capacity {
+		relayFullCounter.Inc(1)
+		return errRelayFull
+	}
+	e := &relayEntry{
+		Msg:    msg,
+		Queued: uint64(now.Unix()),
+		Expire: expire,
+		Next:   uint64(now.Add(relayRetryInterval).UnixNano()),
+	}
+	q.entries[id] = e
+	relayQueuedCounter.Inc(1)
+	if q.store != nil {
+		if err := q.store.Put(relayKeyPrefix+id, e); err != nil {
+			log.Error("pss relay queue persist", "id", id, "err", err)
+		}
+		q.persistIndex()
+	}
+	return nil
+}
+
+// retry tries to forward the messages due for a retry with the given send
+// function, which returns true if the message was sent to a peer. Messages
+// which are sent or expired are removed, the others are scheduled for the
+// next retry with a doubled backoff.
+func (q *relayQueue) retry(send func(*PssMsg) bool) {
+	now := time.Now()
+	q.mu.Lock()
+	due := make(map[string]*relayEntry)
+	for id, e := range q.entries {
+		if e.Next <= uint64(now.UnixNano()) {
+			due[id] = e
+		}
+	}
+	q.mu.Unlock()
+
+	for id, e := range due {
+		if int64(e.Expire) < now.Unix() {
+			relayExpiredCounter.Inc(1)
+			q.remove(id)
+			continue
+		}
+		if send(e.Msg) {
+			relaySentCounter.Inc(1)
+			q.remove(id)
+			continue
+		}
+		q.mu.Lock()
+		e.Attempts++
+		backoff := maxRelayRetryInterval
+		if e.Attempts < 32 && relayRetryInterval<<e.Attempts < maxRelayRetryInterval {
+			backoff = relayRetryInterval << e.Attempts
+		}
+		e.Next = uint64(now.Add(backoff).UnixNano())
+		q.mu.Unlock()
+	}
+}
+
+func (q *relayQueue) remove(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.entries, id)
+	if q.store != nil {
+		q.store.Delete(relayKeyPrefix + id)
+		q.persistIndex()
+	}
+}
+
+// persistIndex stores the ids of the queued messages, it must be called
+// with the lock held
+func (q *relayQueue) persistIndex() {
+	if q.store == nil {
+		return
+	}
+	ids := make([]string, 0, len(q.entries))
+	for id := range q.entries {
+		ids = append(ids, id)
+	}
+	if err := q.store.Put(relayIndexKey, ids); err != nil {
+		log.Error("pss relay queue persist", "err", err)
+	}
+}
+
+// list returns the messages in the queue by the time they were queued
+func (q *relayQueue) list() []*RelayInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	infos := make([]*RelayInfo, 0, len(q.entries))
+	for id, e := range q.entries {
+		infos = append(infos, &RelayInfo{
+			ID:          id,
+			To:          hexutil.Bytes(e.Msg.To),
+			Topic:       Topic(e.Msg.Payload.Topic),
+			Queued:      time.Unix(int64(e.Queued), 0),
+			Expire:      time.Unix(int64(e.Expire), 0),
+			Attempts:    e.Attempts,
+			NextAttempt: time.Unix(0, int64(e.Next)),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Queued.Equal(infos[j].Queued) {
+			return infos[i].Queued.Before(infos[j].Queued)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// relay queues a message which could not be forwarded to any peer
+func (p *Pss) relay(msg *PssMsg) error {
+	digest := p.digest(msg)
+	if err := p.relayQueue.add(fmt.Sprintf("%x", digest), msg); err != nil {
+		return err
+	}
+	log.Trace("pss relay queued", "to", fmt.Sprintf("%x", msg.To))
+	return nil
+}
+
+// retryRelay tries to forward the messages of the relay queue which are
+// due for a retry
+func (p *Pss) retryRelay() {
+	p.relayQueue.retry(func(msg *PssMsg) bool {
+		return p.forwardToPeers(msg) > 0
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/state"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+)
+
+// TestRelayQueue tests that messages which cannot be forwarded to any peer
+// are kept in the relay queue across restarts and retried with a backoff
+// until they are sent or expire
+func TestRelayQueue(t *testing.T) {
+	defer func(interval time.Duration) { relayRetryInterval = interval }(relayRetryInterval)
+	relayRetryInterval = time.Millisecond
+
+	store := state.NewInmemoryStore()
+	params := NewPssParams().WithStateStore(store)
+	params.RelayTTL = time.Minute
+	ps := newUnstartedPss(t, params)
+
+	newMsg := func(data string, expire time.Time) *PssMsg {
+		msg := newPssMsg(&msgParams{raw: true})
+		msg.To = network.RandomAddr().Over()
+		msg.Expire = uint32(expire.Unix())
+		msg.Payload = &whisper.Envelope{
+			Data:  []byte(data),
+			Topic: whisper.BytesToTopic([]byte("relay")),
+		}
+		return msg
+	}
+	msg := newMsg("queued", time.Now().Add(time.Hour))
+	if err := ps.forward(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.forward(newMsg("expiring", time.Now().Add(2*time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if len(ps.outbox) != 0 {
+		t.Fatalf("expected the messages to be queued instead of the outbox, got %d in the outbox", len(ps.outbox))
+	}
+	infos, err := NewAPI(ps).RelayQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 queued messages, got %d", len(infos))
+	}
+	for _, info := range infos {
+		if info.Expire.After(time.Now().Add(params.RelayTTL)) {
+			t.Fatalf("expected the message to expire within the relay ttl, got %v", info.Expire)
+		}
+	}
+
+	// the queue is loaded from the store
+	q := newRelayQueue(params.RelayTTL, 0, store)
+	if len(q.list()) != 2 {
+		t.Fatalf("expected 2 persisted messages, got %d", len(q.list()))
+	}
+
+	// failed retries back off
+	var sent []string
+	send := func(ok bool) func(*PssMsg) bool {
+		return func(msg *PssMsg) bool {
+			sent = append(sent, string(msg.Payload.Data))
+			return ok
+		}
+	}
+	q.retry(send(false))
+	q.retry(send(false))
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 attempts before the backoff elapsed, got %d", len(sent))
+	}
+	for _, info := range q.list() {
+		if info.Attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", info.Attempts)
+		}
+	}
+
+	// expired messages are dropped, sent messages are removed
+	time.Sleep(3 * time.Second)
+	sent = nil
+	q.retry(send(true))
+	if len(sent) != 1 || sent[0] != "queued" {
+		t.Fatalf("expected only the unexpired message to be sent, got %v", sent)
+	}
+	if len(q.list()) != 0 {
+		t.Fatalf("expected the queue to be empty, got %d messages", len(q.list()))
+	}
+	if len(newRelayQueue(params.RelayTTL, 0, store).list()) != 0 {
+		t.Fatal("expected the persisted queue to be empty")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	whisperv6 "github.com/ethereum/go-ethereum/whisper/whisperv6"
+)
+
+// maxShhFilterMessages is the number of messages a filter of the shh shim
+// holds until they are retrieved, the oldest messages are dropped first
+const maxShhFilterMessages = 1024
+
+// ShhAPI maps the message posting, key and filter methods of the whisper
+// shh RPC namespace onto pss, so that whisper dapps can use pss with
+// minimal changes
+//
+// Pss decrypts asymmetric messages with the key of the node, so the key
+// pair of the shim is always the key of the node. Messages are sent with
+// an empty address hint, the PoW parameters of whisper are ignored.
+type ShhAPI struct {
+	pss *Pss
+
+	mu      sync.Mutex
+	filters map[string]*shhFilter
+}
+
+// shhFilter holds the messages matching the criteria of a filter until
+// they are retrieved
+type shhFilter struct {
+	mu       sync.Mutex
+	messages []*whisperv6.Message
+	deregs   []func()
+}
+
+// NewShhAPI creates a new ShhAPI
+func NewShhAPI(ps *Pss) *ShhAPI {
+	return &ShhAPI{
+		pss:     ps,
+		filters: make(map[string]*shhFilter),
+	}
+}
+
+// Version returns the whisper protocol version the shim is compatible with
+func (s *ShhAPI) Version(ctx context.Context) string {
+	return whisperv6.ProtocolVersionStr
+}
+
+// NewKeyPair returns the id of the key pair of the node
+func (s *ShhAPI) NewKeyPair(ctx context.Context) (string, error) {
+	return s.keyPairID(), nil
+}
+
+// HasKeyPair returns true if the id is the id of the key pair of the node
+func (s *ShhAPI) HasKeyPair(ctx context.Context, id string) bool {
+	return id == s.keyPairID()
+}
+
+// GetPublicKey returns the public key of the key pair with the given id
+func (s *ShhAPI) GetPublicKey(ctx context.Context, id string) (hexutil.Bytes, error) {
+	if id != s.keyPairID() {
+		return nil, fmt.Errorf("key pair %s not found", id)
+	}
+	return crypto.FromECDSAPub(s.pss.PublicKey()), nil
+}
+
+// NewSymKey generates a random symmetric key and returns its id
+func (s *ShhAPI) NewSymKey(ctx context.Context) (string, error) {
+	return s.pss.w.GenerateSymKey()
+}
+
+// AddSymKey stores the symmetric key and returns its id
+func (s *ShhAPI) AddSymKey(ctx context.Context, key hexutil.Bytes) (string, error) {
+	return s.pss.w.AddSymKeyDirect(key)
+}
+
+// HasSymKey returns true if a symmetric key with the given id is stored
+func (s *ShhAPI) HasSymKey(ctx context.Context, id string) bool {
+	return s.pss.w.HasSymKey(id)
+}
+
+// GetSymKey returns the symmetric key with the given id
+func (s *ShhAPI) GetSymKey(ctx context.Context, id string) (hexutil.Bytes, error) {
+	return s.pss.GetSymmetricKey(id)
+}
+
+// Post sends the payload of the message on its topic, encrypted with the
+// symmetric key or the public key of the message
+//
+// Pss messages have no envelope hash, so no hash is returned
+func (s *ShhAPI) Post(ctx context.Context, req whisperv6.NewMessage) (hexutil.Bytes, error) {
+	if (req.SymKeyID == "") == (len(req.PublicKey) == 0) {
+		return nil, errors.New("either a symmetric key id or a public key is required")
+	}
+	topic := Topic(req.Topic)
+	if req.SymKeyID != "" {
+		if !s.pss.w.HasSymKey(req.SymKeyID) {
+			return nil, fmt.Errorf("symmetric key %s not found", req.SymKeyID)
+		}
+		s.addSymKey(req.SymKeyID, topic, false)
+		return nil, s.pss.SendSym(req.SymKeyID, topic, req.Payload)
+	}
+
+	pubkeyid := common.ToHex(req.PublicKey)
+	s.pss.pubKeyPoolMu.RLock()
+	_, ok := s.pss.pubKeyPool[pubkeyid][topic]
+	s.pss.pubKeyPoolMu.RUnlock()
+	if !ok {
+		pubkey, err := crypto.UnmarshalPubkey(req.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+		if err := s.pss.SetPeerPublicKey(pubkey, topic, &PssAddress{}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, s.pss.SendAsym(pubkeyid, topic, req.Payload)
+}
+
+// NewMessageFilter creates a filter holding the messages received on the
+// topics of the criteria which are encrypted with its symmetric key or
+// with the key pair of the node, and returns its id
+func (s *ShhAPI) NewMessageFilter(req whisperv6.Criteria) (string, error) {
+	if (req.SymKeyID == "") == (req.PrivateKeyID == "") {
+		return "", errors.New("either a symmetric key id or a key pair id is required")
+	}
+	if len(req.Topics) == 0 {
+		return "", errors.New("at least one topic is required")
+	}
+	asymmetric := req.PrivateKeyID != ""
+	if asymmetric && req.PrivateKeyID != s.keyPairID() {
+		return "", fmt.Errorf("key pair %s not found", req.PrivateKeyID)
+	}
+	if !asymmetric && !s.pss.w.HasSymKey(req.SymKeyID) {
+		return "", fmt.Errorf("symmetric key %s not found", req.SymKeyID)
+	}
+	var sig string
+	if len(req.Sig) > 0 {
+		sig = common.ToHex(req.Sig)
+	}
+
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	f := &shhFilter{}
+	for _, t := range req.Topics {
+		topic := Topic(t)
+		if !asymmetric {
+			s.addSymKey(req.SymKeyID, topic, true)
+		}
+		handler := func(msg []byte, p *p2p.Peer, asym bool, keyid string) error {
+			if asym != asymmetric || !asym && keyid != req.SymKeyID || sig != "" && keyid != sig {
+				return nil
+			}
+			m := &whisperv6.Message{
+				TTL:       uint32(s.pss.msgTTL / time.Second),
+				Timestamp: uint32(time.Now().Unix()),
+				Topic:     whisperv6.TopicType(topic),
+				Payload:   msg,
+			}
+			if asym {
+				m.Sig = common.FromHex(keyid)
+				m.Dst = crypto.FromECDSAPub(s.pss.PublicKey())
+			}
+			f.add(m)
+			return nil
+		}
+		f.deregs = append(f.deregs, s.pss.Register(&topic, handler))
+	}
+
+	filterID := common.Bytes2Hex(id)
+	s.mu.Lock()
+	s.filters[filterID] = f
+	s.mu.Unlock()
+	return filterID, nil
+}
+
+// GetFilterMessages returns the messages received by the filter since the
+// last call
+func (s *ShhAPI) GetFilterMessages(id string) ([]*whisperv6.Message, error) {
+	s.mu.Lock()
+	f, ok := s.filters[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("filter %s not found", id)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := f.messages
+	f.messages = nil
+	if messages == nil {
+		messages = []*whisperv6.Message{}
+	}
+	return messages, nil
+}
+
+// DeleteMessageFilter removes the filter with the given id
+func (s *ShhAPI) DeleteMessageFilter(id string) (bool, error) {
+	s.mu.Lock()
+	f, ok := s.filters[id]
+	delete(s.filters, id)
+	s.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("filter %s not found", id)
+	}
+	for _, dereg := range f.deregs {
+		dereg()
+	}
+	return true, nil
+}
+
+// keyPairID returns the id of the key pair of the node, which is the hex
+// encoding of its public key as in the pss key pool
+func (s *ShhAPI) keyPairID() string {
+	return common.ToHex(crypto.FromECDSAPub(s.pss.PublicKey()))
+}
+
+// addSymKey links the symmetric key to the topic with an empty address
+// hint unless it is linked already, it is added to the keys used to decrypt
+// incoming messages if decrypt is true
+func (s *ShhAPI) addSymKey(id string, topic Topic, decrypt bool) {
+	s.pss.symKeyPoolMu.RLock()
+	_, ok := s.pss.symKeyPool[id][topic]
+	s.pss.symKeyPoolMu.RUnlock()
+	if !ok || decrypt {
+		s.pss.addSymmetricKeyToPool(id, topic, &PssAddress{}, decrypt, true)
+	}
+}
+
+func (f *shhFilter) add(m *whisperv6.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.messages) == maxShhFilterMessages {
+		f.messages = f.messages[1:]
+	}
+	f.messages = append(f.messages, m)
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	whisperv6 "github.com/ethereum/go-ethereum/whisper/whisperv6"
+)
+
+// TestShhAPI tests that messages posted through the shh shim of a node are
+// received by the filters of the shim of their recipient
+func TestShhAPI(t *testing.T) {
+	// the messages of the sender are not forwarded, they are taken from
+	// its outbox and handed to the recipient
+	sender := newUnstartedPss(t, NewPssParams())
+	recipientKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := newTestPss(recipientKey, nil, nil)
+	defer recipient.Stop()
+
+	dial := func(ps *Pss) *rpc.Client {
+		server := rpc.NewServer()
+		if err := server.RegisterName("shh", NewShhAPI(ps)); err != nil {
+			t.Fatal(err)
+		}
+		return rpc.DialInProc(server)
+	}
+	senderClient := dial(sender)
+	defer senderClient.Close()
+	recipientClient := dial(recipient)
+	defer recipientClient.Close()
+
+	topic := whisperv6.BytesToTopic([]byte("shim"))
+	deliver := func() {
+		select {
+		case msg := <-sender.outbox:
+			if err := recipient.handlePssMsg(msg); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the message to be sent")
+		}
+	}
+	receive := func(filterID string, payload []byte) *whisperv6.Message {
+		var messages []*whisperv6.Message
+		if err := recipientClient.Call(&messages, "shh_getFilterMessages", filterID); err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 1 || !bytes.Equal(messages[0].Payload, payload) || messages[0].Topic != topic {
+			t.Fatalf("expected message %q on topic %x, got %v", payload, topic, messages)
+		}
+		return messages[0]
+	}
+
+	// symmetric
+	var symKeyID string
+	if err := recipientClient.Call(&symKeyID, "shh_newSymKey"); err != nil {
+		t.Fatal(err)
+	}
+	var symKey hexutil.Bytes
+	if err := recipientClient.Call(&symKey, "shh_getSymKey", symKeyID); err != nil {
+		t.Fatal(err)
+	}
+	var filterID string
+	if err := recipientClient.Call(&filterID, "shh_newMessageFilter", map[string]interface{}{
+		"symKeyID": symKeyID,
+		"topics":   []whisperv6.TopicType{topic},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var senderSymKeyID string
+	if err := senderClient.Call(&senderSymKeyID, "shh_addSymKey", symKey); err != nil {
+		t.Fatal(err)
+	}
+	var hash hexutil.Bytes
+	if err := senderClient.Call(&hash, "shh_post", map[string]interface{}{
+		"symKeyID": senderSymKeyID,
+		"topic":    topic,
+		"payload":  hexutil.Bytes("symmetric"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deliver()
+	receive(filterID, []byte("symmetric"))
+
+	// asymmetric
+	var keyPairID string
+	if err := recipientClient.Call(&keyPairID, "shh_newKeyPair"); err != nil {
+		t.Fatal(err)
+	}
+	var pubkey hexutil.Bytes
+	if err := recipientClient.Call(&pubkey, "shh_getPublicKey", keyPairID); err != nil {
+		t.Fatal(err)
+	}
+	if err := recipientClient.Call(&filterID, "shh_newMessageFilter", map[string]interface{}{
+		"privateKeyID": keyPairID,
+		"topics":       []whisperv6.TopicType{topic},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := senderClient.Call(&hash, "shh_post", map[string]interface{}{
+		"pubKey":  pubkey,
+		"topic":   topic,
+		"payload": hexutil.Bytes("asymmetric"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deliver()
+	msg := receive(filterID, []byte("asymmetric"))
+	if !bytes.Equal(msg.Sig, crypto.FromECDSAPub(sender.PublicKey())) {
+		t.Fatalf("expected the message to be signed by the sender, got %x", msg.Sig)
+	}
+
+	var deleted bool
+	if err := recipientClient.Call(&deleted, "shh_deleteMessageFilter", filterID); err != nil || !deleted {
+		t.Fatalf("expected the filter to be deleted, got %v, %v", deleted, err)
+	}
+}
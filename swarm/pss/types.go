@@ -36,6 +36,7 @@ const (
 const (
 	pssControlSym = 1
 	pssControlRaw = 1 << 1
+	pssControlAck = 1 << 2
 )
 
 var (
@@ -93,6 +94,7 @@ type pssDigest [digestLength]byte
 type msgParams struct {
 	raw bool
 	sym bool
+	ack bool
 }
 
 func newMsgParamsFromBytes(paramBytes []byte) *msgParams {
@@ -102,6 +104,7 @@ func newMsgParamsFromBytes(paramBytes []byte) *msgParams {
 	return &msgParams{
 		raw: paramBytes[0]&pssControlRaw > 0,
 		sym: paramBytes[0]&pssControlSym > 0,
+		ack: paramBytes[0]&pssControlAck > 0,
 	}
 }
 
@@ -113,6 +116,9 @@ func (m *msgParams) Bytes() (paramBytes []byte) {
 	if m.sym {
 		b |= pssControlSym
 	}
+	if m.ack {
+		b |= pssControlAck
+	}
 	paramBytes = append(paramBytes, b)
 	return paramBytes
 }
@@ -141,6 +147,11 @@ func (msg *PssMsg) isSym() bool {
 	return msg.Control[0]&pssControlSym > 0
 }
 
+// message requests an acknowledgement from its recipient
+func (msg *PssMsg) wantsAck() bool {
+	return msg.Control[0]&pssControlAck > 0
+}
+
 // serializes the message for use in cache
 func (msg *PssMsg) serialize() []byte {
 	rlpdata, _ := rlp.EncodeToBytes(struct {
@@ -0,0 +1,39 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package sctx holds the values swarm carries in the contexts of requests
+package sctx
+
+import "context"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+)
+
+// SetRequestID returns a context carrying the ID of the HTTP API request it
+// is used for, the ID is logged as ruid by the layers serving the request
+func SetRequestID(ctx context.Context, ruid string) context.Context {
+	return context.WithValue(ctx, requestIDKey, ruid)
+}
+
+// GetRequestID returns the ID of the request the context is used for, or
+// an empty string if it is not used for an HTTP API request
+func GetRequestID(ctx context.Context) string {
+	ruid, _ := ctx.Value(requestIDKey).(string)
+	return ruid
+}
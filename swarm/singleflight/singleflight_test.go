@@ -0,0 +1,120 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package singleflight
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("expected bar, <nil>, false, got %v, %v, %v", v, err, shared)
+	}
+
+	errFailed := errors.New("failed")
+	if _, err, _ := g.Do("key", func() (interface{}, error) {
+		return nil, errFailed
+	}); err != errFailed {
+		t.Fatalf("expected %v, got %v", errFailed, err)
+	}
+}
+
+func TestDoDuplicates(t *testing.T) {
+	var g Group
+	var calls, shares int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "bar", nil
+	}
+
+	n := 10
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.Do("key", fn)
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, shared := g.Do("key", fn)
+			if v != "bar" {
+				t.Errorf("expected bar, got %v", v)
+			}
+			if shared {
+				atomic.AddInt32(&shares, 1)
+			}
+		}()
+	}
+	// wait for the duplicate calls to join the call in flight
+	for {
+		g.mu.Lock()
+		dups := g.calls["key"].dups
+		g.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+	if shares != int32(n-1) {
+		t.Fatalf("expected %d shared results, got %d", n-1, shares)
+	}
+}
+
+// TestDoChan tests that a caller can stop waiting for a call without the
+// other callers of the call losing its results
+func TestDoChan(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	// the first caller gives up waiting, the call goes on for the second one
+	g.DoChan("key", fn)
+	second := g.DoChan("key", fn)
+	close(release)
+
+	res := <-second
+	if res.Val != "bar" || res.Err != nil || !res.Shared {
+		t.Fatalf("expected bar, <nil>, true, got %v, %v, %v", res.Val, res.Err, res.Shared)
+	}
+	if v, _, shared := g.Do("key", func() (interface{}, error) { return "baz", nil }); v != "baz" || shared {
+		t.Fatalf("expected a new call after the results were returned, got %v, %v", v, shared)
+	}
+}
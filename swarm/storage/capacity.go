@@ -0,0 +1,157 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+)
+
+const (
+	// cachedChunkSize is the approximate size of the memory taken by a chunk
+	// in the in-memory cache, including the overhead of the cache
+	cachedChunkSize = DefaultChunkSize + 8 + 256
+
+	capacityCheckInterval = time.Minute
+)
+
+var (
+	dbByteCapacityGauge = metrics.NewRegisteredGauge("localstore.capacity.bytes", nil)
+	cacheCapacityGauge  = metrics.NewRegisteredGauge("localstore.capacity.cache", nil)
+)
+
+// capacityMonitor periodically sets the byte capacity of the chunk database
+// and the capacity of the in-memory cache from the configured ratios of the
+// disk space and the memory available to them, so that the same
+// configuration fits machines of any size
+type capacityMonitor struct {
+	path       string
+	dbRatio    float64
+	cacheRatio float64
+	dbStore    *LDBStore
+	memStore   *MemStore
+	usage      func(path string) (used, total uint64, err error)
+	memory     func() (uint64, error)
+	quit       chan struct{}
+}
+
+func newCapacityMonitor(params *LocalStoreParams, dbStore *LDBStore, memStore *MemStore) *capacityMonitor {
+	return &capacityMonitor{
+		path:       params.ChunkDbPath,
+		dbRatio:    params.DbByteCapacityRatio,
+		cacheRatio: params.CacheCapacityRatio,
+		dbStore:    dbStore,
+		memStore:   memStore,
+		usage:      diskUsage,
+		memory:     totalMemory,
+		quit:       make(chan struct{}),
+	}
+}
+
+// start sets the capacities once and then periodically until stop is called
+func (m *capacityMonitor) start(interval time.Duration) {
+	if err := m.check(); err != nil {
+		log.Warn("capacities of the chunk stores are not adapted", "path", m.path, "err", err)
+		return
+	}
+	supervisor.Go("storage.capacitymonitor", m.quit, func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.check(); err != nil {
+					log.Error("capacity check failed", "path", m.path, "err", err)
+				}
+			case <-m.quit:
+				return
+			}
+		}
+	})
+}
+
+func (m *capacityMonitor) stop() {
+	close(m.quit)
+}
+
+// check sets the capacities from the current disk space and memory
+func (m *capacityMonitor) check() error {
+	if m.dbRatio > 0 {
+		used, total, err := m.usage(m.path)
+		if err != nil {
+			return err
+		}
+		size, err := m.dbStore.size()
+		if err != nil {
+			return err
+		}
+		c := dbByteCapacity(m.dbRatio, size, used, total)
+		dbByteCapacityGauge.Update(int64(c))
+		log.Trace("chunk store byte capacity", "path", m.path, "size", size, "capacity", c)
+		m.dbStore.setByteCapacity(c)
+	}
+	if m.cacheRatio > 0 {
+		memory, err := m.memory()
+		if err != nil {
+			return err
+		}
+		c := cacheCapacity(m.cacheRatio, memory)
+		cacheCapacityGauge.Update(int64(c))
+		m.memStore.setCapacity(int(c))
+	}
+	return nil
+}
+
+// dbByteCapacity returns the given ratio of the disk space available to the
+// chunk database, the space it takes and the free space of its filesystem
+func dbByteCapacity(ratio float64, size, used, total uint64) uint64 {
+	var free uint64
+	if total > used {
+		free = total - used
+	}
+	return uint64(ratio * float64(size+free))
+}
+
+// cacheCapacity returns the number of chunks the given ratio of the memory
+// holds in the in-memory cache, at least one
+func cacheCapacity(ratio float64, memory uint64) uint {
+	c := uint(ratio * float64(memory) / float64(cachedChunkSize))
+	if c == 0 {
+		c = 1
+	}
+	return c
+}
+
+// sizeCache returns the store parameters with the capacity of the in-memory
+// cache set from the ratio of the memory if one is configured, it is kept if
+// the size of the memory is unknown
+func sizeCache(params *LocalStoreParams) *StoreParams {
+	if params.CacheCapacityRatio <= 0 {
+		return params.StoreParams
+	}
+	memory, err := totalMemory()
+	if err != nil {
+		log.Warn("in-memory chunk cache is not sized to the memory", "capacity", params.CacheCapacity, "err", err)
+		return params.StoreParams
+	}
+	storeParams := *params.StoreParams
+	storeParams.CacheCapacity = cacheCapacity(params.CacheCapacityRatio, memory)
+	return &storeParams
+}
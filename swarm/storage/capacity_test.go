@@ -0,0 +1,97 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestCapacityMonitor tests that the capacities of the chunk database and the
+// in-memory cache follow the disk space and the memory, and that the most
+// recently used chunks are kept when the cache shrinks
+func TestCapacityMonitor(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "storage-testcapacitymonitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	params := NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	store, err := NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	params.DbByteCapacityRatio = 0.5
+	params.CacheCapacityRatio = 0.1
+	m := newCapacityMonitor(params, store.DbStore, store.memStore)
+	var used, total, memory uint64
+	m.usage = func(string) (uint64, uint64, error) {
+		return used, total, nil
+	}
+	m.memory = func() (uint64, error) {
+		return memory, nil
+	}
+
+	var chunks []*Chunk
+	for i := 0; i < 10; i++ {
+		chunk := GenerateRandomChunk(DefaultChunkSize)
+		store.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	size, err := store.DbStore.size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		used, total, memory uint64
+		cache               int
+	}{
+		{size, 1 << 30, uint64(100 * cachedChunkSize), 10},
+		{size + 1<<29, 1 << 30, uint64(30 * cachedChunkSize), 3},
+		{1 << 30, 1 << 30, 0, 1},
+	} {
+		used, total, memory = test.used, test.total, test.memory
+		if err := m.check(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := store.DbStore.Usage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := uint64(0.5 * float64(size+total-used)); u.ByteCapacity != expected {
+			t.Fatalf("used %d of %d: expected byte capacity %d, got %d", used, total, expected, u.ByteCapacity)
+		}
+		if n := store.memStore.cache.Len(); n != test.cache {
+			t.Fatalf("memory %d: expected %d cached chunks, got %d", memory, test.cache, n)
+		}
+		// the most recently stored chunks are kept
+		for _, chunk := range chunks[len(chunks)-test.cache:] {
+			if _, err := store.memStore.Get(chunk.Addr); err != nil {
+				t.Fatalf("memory %d: chunk %v not cached", memory, chunk.Addr)
+			}
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 	"time"
 
@@ -64,6 +65,8 @@ The hashing itself does use extra copies and allocation though, since it does ne
 var (
 	errAppendOppNotSuported = errors.New("Append operation not supported")
 	errOperationTimedOut    = errors.New("operation timed out")
+	errDataTooLarge         = errors.New("data too large")
+	errInvalidDataSize      = errors.New("invalid data size")
 )
 
 const (
@@ -230,17 +233,13 @@ func (tc *TreeChunker) Split(ctx context.Context) (k Address, wait func(context.
 		panic("chunker must be initialised")
 	}
 
-	tc.runWorker()
-
-	depth := 0
-	treeSize := tc.chunkSize
-
-	// takes lowest depth such that chunksize*HashCount^(depth+1) > size
-	// power series, will find the order of magnitude of the data size in base hashCount or numbers of levels of branching in the resulting tree.
-	for ; treeSize < tc.dataSize; treeSize *= tc.branches {
-		depth++
+	treeSize, depth, err := treeSizeOf(tc.chunkSize, tc.branches, tc.dataSize)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	tc.runWorker()
+
 	key := make([]byte, tc.hashSize)
 	// this waitgroup member is released after the root hash is calculated
 	tc.wg.Add(1)
@@ -268,6 +267,26 @@ func (tc *TreeChunker) Split(ctx context.Context) (k Address, wait func(context.
 	return key, tc.putter.Wait, nil
 }
 
+// treeSizeOf returns the lowest depth such that chunkSize*branches^depth is
+// not less than size, together with that tree size
+//
+// This is the order of magnitude of the data size in base branches, or the
+// number of levels of branching in the resulting tree. Sizes are encoded in
+// the 8 byte little endian span of the chunks, data sizes for which the tree
+// size would overflow an int64 cannot be chunked.
+func treeSizeOf(chunkSize, branches, size int64) (treeSize int64, depth int, err error) {
+	if size < 0 {
+		return 0, 0, errInvalidDataSize
+	}
+	for treeSize = chunkSize; treeSize < size; treeSize *= branches {
+		if treeSize > math.MaxInt64/branches {
+			return 0, 0, errDataTooLarge
+		}
+		depth++
+	}
+	return treeSize, depth, nil
+}
+
 func (tc *TreeChunker) split(depth int, treeSize int64, addr Address, size int64, parentWg *sync.WaitGroup) {
 
 	//
@@ -407,7 +426,7 @@ func (r *LazyChunkReader) Size(quitC chan bool) (n int64, err error) {
 			case <-quitC:
 				return 0, errors.New("aborted")
 			default:
-				return 0, fmt.Errorf("root chunk not found for %v", r.key.Hex())
+				return 0, &ChunkError{Op: "get", Addr: r.key, Err: ErrChunkNotFound}
 			}
 		}
 		r.chunkData = chunkData
@@ -432,16 +451,17 @@ func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 		return 0, err
 	}
 
+	treeSize, depth, err := treeSizeOf(r.chunkSize, r.branches, size)
+	if err != nil {
+		log.Error("lazychunkreader.readat.size", "size", size, "err", err)
+		return 0, err
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+
 	errC := make(chan error)
 
-	// }
-	var treeSize int64
-	var depth int
-	// calculate depth and max treeSize
-	treeSize = r.chunkSize
-	for ; treeSize < size; treeSize *= r.branches {
-		depth++
-	}
 	wg := sync.WaitGroup{}
 	length := int64(len(b))
 	for d := 0; d < r.depth; d++ {
@@ -516,16 +536,20 @@ func (r *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, treeS
 			childKey := chunkData[8+j*r.hashSize : 8+(j+1)*r.hashSize]
 			chunkData, err := r.getter.Get(Reference(childKey))
 			if err != nil {
-				log.Error("lazychunkreader.join", "key", fmt.Sprintf("%x", childKey), "err", err)
+				log.Error("lazychunkreader.join", "key", fmt.Sprintf("%x", childKey), "offset", off, "err", err)
+				if _, ok := err.(*ChunkError); !ok {
+					err = &ChunkError{Op: "get", Addr: Address(childKey), Err: err}
+				}
 				select {
-				case errC <- fmt.Errorf("chunk %v-%v not found; key: %s", off, off+treeSize, fmt.Sprintf("%x", childKey)):
+				case errC <- err:
 				case <-quitC:
 				}
 				return
 			}
 			if l := len(chunkData); l < 9 {
+				log.Error("lazychunkreader.join: chunk incomplete", "key", fmt.Sprintf("%x", childKey), "offset", off, "length", l)
 				select {
-				case errC <- fmt.Errorf("chunk %v-%v incomplete; key: %s, data length %v", off, off+treeSize, fmt.Sprintf("%x", childKey), l):
+				case errC <- &ChunkError{Op: "get", Addr: Address(childKey), Err: ErrChunkInvalid}:
 				case <-quitC:
 				}
 				return
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto/sha3"
@@ -280,6 +281,199 @@ func TestRandomBrokenData(t *testing.T) {
 	}
 }
 
+// sparseTree builds chunk trees the way the TreeChunker splits data whose
+// content chunks all hold the same pattern, so that identical subtrees are
+// stored only once and trees of many GB need only a few chunks
+type sparseTree struct {
+	putter   Putter
+	branches int64
+	leaf     []byte
+	refs     map[[2]int64]Reference
+}
+
+func newSparseTree(putter Putter) *sparseTree {
+	leaf := make([]byte, DefaultChunkSize)
+	for i := range leaf {
+		leaf[i] = byte(i % 251)
+	}
+	return &sparseTree{
+		putter:   putter,
+		branches: DefaultChunkSize / putter.RefSize(),
+		leaf:     leaf,
+		refs:     make(map[[2]int64]Reference),
+	}
+}
+
+// at returns the byte at the given offset of the data
+func (s *sparseTree) at(off int64) byte {
+	return s.leaf[off%DefaultChunkSize]
+}
+
+func (s *sparseTree) root(size int64) (Reference, error) {
+	treeSize, depth, err := treeSizeOf(DefaultChunkSize, s.branches, size)
+	if err != nil {
+		return nil, err
+	}
+	return s.put(depth, treeSize/s.branches, size)
+}
+
+func (s *sparseTree) put(depth int, treeSize int64, size int64) (Reference, error) {
+	for depth > 0 && size < treeSize {
+		treeSize /= s.branches
+		depth--
+	}
+	if ref, ok := s.refs[[2]int64{int64(depth), size}]; ok {
+		return ref, nil
+	}
+	chunk := make([]byte, 8)
+	binary.LittleEndian.PutUint64(chunk, uint64(size))
+	if depth == 0 {
+		chunk = append(chunk, s.leaf[:size]...)
+	} else {
+		for pos := int64(0); pos < size; pos += treeSize {
+			secSize := treeSize
+			if size-pos < treeSize {
+				secSize = size - pos
+			}
+			ref, err := s.put(depth-1, treeSize/s.branches, secSize)
+			if err != nil {
+				return nil, err
+			}
+			chunk = append(chunk, ref...)
+		}
+	}
+	ref, err := s.putter.Put(chunk)
+	if err != nil {
+		return nil, err
+	}
+	s.refs[[2]int64{int64(depth), size}] = ref
+	return ref, nil
+}
+
+// Read implements io.Reader on the data of the tree from offset 0
+type sparseReader struct {
+	tree *sparseTree
+	off  int64
+}
+
+func (r *sparseReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = r.tree.at(r.off + int64(i))
+	}
+	r.off += int64(len(b))
+	return len(b), nil
+}
+
+// TestSparseTreeSplit checks that sparse trees are built exactly as the
+// TreeChunker splits the same data
+func TestSparseTreeSplit(t *testing.T) {
+	for _, size := range []int64{4095, 4096, 524288, 524288 + 4096, 2*524288 + 4097, 524288*128 + 1} {
+		putGetter := newTestHasherStore(NewMapChunkStore(), BMTHash)
+		tree := newSparseTree(putGetter)
+		expected, err := tree.root(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := io.LimitReader(&sparseReader{tree: tree}, size)
+		addr, wait, err := TreeSplit(context.TODO(), data, size, putGetter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(addr, expected) {
+			t.Fatalf("size %v: expected root %x, got %x", size, expected, addr)
+		}
+	}
+}
+
+// TestLargeDataJoin reads files well beyond 4GB, across the 32 bit offset
+// boundaries and up to their end
+func TestLargeDataJoin(t *testing.T) {
+	for _, size := range []int64{1<<32 + 4097, 5<<30 + 12345, 1<<40 + 4097} {
+		putGetter := newTestHasherStore(NewMapChunkStore(), BMTHash)
+		tree := newSparseTree(putGetter)
+		addr, err := tree.root(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		putGetter.Close()
+		if err := putGetter.Wait(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+
+		reader := TreeJoin(context.TODO(), Address(addr), putGetter, 0)
+		n, err := reader.Size(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size {
+			t.Fatalf("expected size %v, got %v", size, n)
+		}
+		for _, off := range []int64{0, 1<<31 - 10, 1<<32 - 10, size - 100} {
+			b := make([]byte, 20)
+			read, err := reader.ReadAt(b, off)
+			if err != nil {
+				t.Fatalf("size %v: read at %v: %v", size, off, err)
+			}
+			if read != len(b) {
+				t.Fatalf("size %v: expected to read %v bytes at %v, got %v", size, len(b), off, read)
+			}
+			for i := range b {
+				if b[i] != tree.at(off+int64(i)) {
+					t.Fatalf("size %v: wrong data at offset %v", size, off+int64(i))
+				}
+			}
+		}
+
+		// reads at the end are cut short
+		b := make([]byte, 20)
+		read, err := reader.ReadAt(b, size-5)
+		if err != io.EOF || read != 5 {
+			t.Fatalf("size %v: expected 5 bytes and EOF at the end, got %v and %v", size, read, err)
+		}
+		for i := 0; i < read; i++ {
+			if b[i] != tree.at(size-5+int64(i)) {
+				t.Fatalf("size %v: wrong data at offset %v", size, size-5+int64(i))
+			}
+		}
+		if read, err := reader.ReadAt(b, size); err != io.EOF || read != 0 {
+			t.Fatalf("size %v: expected EOF past the end, got %v and %v", size, read, err)
+		}
+	}
+}
+
+func TestTreeSizeOverflow(t *testing.T) {
+	branches := DefaultChunkSize / 32
+	treeSize, depth, err := treeSizeOf(DefaultChunkSize, branches, 5<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 3 || treeSize != DefaultChunkSize*branches*branches*branches {
+		t.Fatalf("expected depth 3, got depth %v with tree size %v", depth, treeSize)
+	}
+	if _, _, err := treeSizeOf(DefaultChunkSize, branches, math.MaxInt64); err != errDataTooLarge {
+		t.Fatalf("expected %v, got %v", errDataTooLarge, err)
+	}
+	if _, _, err := treeSizeOf(DefaultChunkSize, branches, -1); err != errInvalidDataSize {
+		t.Fatalf("expected %v, got %v", errInvalidDataSize, err)
+	}
+
+	// a span with the highest bit set does not hang or panic the reader
+	putGetter := newTestHasherStore(NewMapChunkStore(), BMTHash)
+	chunk := make([]byte, 8+DefaultChunkSize)
+	binary.LittleEndian.PutUint64(chunk, math.MaxUint64)
+	addr, err := putGetter.Put(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := TreeJoin(context.TODO(), Address(addr), putGetter, 0)
+	if _, err := reader.ReadAt(make([]byte, 10), 0); err != errInvalidDataSize {
+		t.Fatalf("expected %v, got %v", errInvalidDataSize, err)
+	}
+}
+
 func benchReadAll(reader LazySectionReader) {
 	size, _ := reader.Size(nil)
 	output := make([]byte, 1000)
@@ -0,0 +1,83 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// The data of the chunks put into an LDBStore can be compressed and
+// encrypted at rest. The codecs the data of a chunk is encoded with are
+// recorded in its index entry in the order they are applied, so that entries
+// stored with and without encoding can be read by any store.
+const (
+	codecNone uint = iota
+	codecSnappy
+	codecAESGCM
+)
+
+// encodeChunkData returns the data entry of the chunk, its address followed
+// by its data encoded with the codecs of the store, and records the codecs
+// in the index entry
+func (s *LDBStore) encodeChunkData(chunk *Chunk, index *dpaDBIndex) []byte {
+	sdata := chunk.SData
+	index.Codec = nil
+	if s.compress {
+		var codec uint
+		if sdata, codec = compressData(sdata); codec != codecNone {
+			index.Codec = append(index.Codec, codec)
+		}
+	}
+	if keys := s.keys(); keys != nil {
+		sdata = encryptData(keys.current(), chunk.Addr, sdata)
+		index.Codec = append(index.Codec, codecAESGCM)
+	}
+	return append(append(make([]byte, 0, len(chunk.Addr)+len(sdata)), chunk.Addr[:]...), sdata...)
+}
+
+// decodeChunkData returns the data entry of a chunk, the address of the
+// chunk followed by its data, with the codecs of its index entry undone
+func (s *LDBStore) decodeChunkData(data []byte, index *dpaDBIndex) ([]byte, error) {
+	if len(index.Codec) == 0 {
+		return data, nil
+	}
+	if len(data) < 32 {
+		return nil, ErrChunkInvalid
+	}
+	addr, sdata := Address(data[:32]), data[32:]
+	var err error
+	for i := len(index.Codec) - 1; i >= 0; i-- {
+		switch index.Codec[i] {
+		case codecSnappy:
+			sdata, err = snappy.Decode(nil, sdata)
+		case codecAESGCM:
+			keys := s.keys()
+			if keys == nil {
+				return nil, ErrStoreEncrypted
+			}
+			sdata, _, err = keys.open(addr, sdata)
+		default:
+			err = fmt.Errorf("unknown chunk data codec %d", index.Codec[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(append(make([]byte, 0, 32+len(sdata)), addr...), sdata...), nil
+}
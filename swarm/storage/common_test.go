@@ -22,6 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -34,10 +35,11 @@ var (
 	loglevel = flag.Int("loglevel", 3, "verbosity of logs")
 )
 
-func init() {
+func TestMain(m *testing.M) {
 	flag.Parse()
 	log.PrintOrigins(true)
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(colorable.NewColorableStderr(), log.TerminalFormat(true))))
+	os.Exit(m.Run())
 }
 
 type brokenLimitedReader struct {
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/golang/snappy"
+)
+
+// compressSampleSize is the size of the prefix of the chunk data compressed
+// first to skip incompressible data, such as already compressed content,
+// without compressing all of it
+const compressSampleSize = 512
+
+// compressMinSaving is the inverse of the smallest fraction of the size of
+// the chunk data compression has to save for the data to be stored
+// compressed
+const compressMinSaving = 8
+
+var (
+	compressInCounter   = metrics.NewRegisteredCounter("ldbstore.compress.in", nil)
+	compressOutCounter  = metrics.NewRegisteredCounter("ldbstore.compress.out", nil)
+	compressSkipCounter = metrics.NewRegisteredCounter("ldbstore.compress.skip", nil)
+	compressRatioGauge  = metrics.NewRegisteredGaugeFloat64("ldbstore.compress.ratio", nil)
+)
+
+// compressData returns the compressed chunk data and its codec, or the data
+// and codecNone if it is not compressible. Compression is skipped for data
+// whose prefix is incompressible, such as already compressed content.
+func compressData(data []byte) ([]byte, uint) {
+	compressInCounter.Inc(int64(len(data)))
+	out, codec := data, codecNone
+	if len(data) <= 2*compressSampleSize || compressible(data[:compressSampleSize]) != nil {
+		if enc := compressible(data); enc != nil {
+			out, codec = enc, codecSnappy
+		}
+	}
+	if codec == codecNone {
+		compressSkipCounter.Inc(1)
+	}
+	compressOutCounter.Inc(int64(len(out)))
+	compressRatioGauge.Update(float64(compressOutCounter.Count()) / float64(compressInCounter.Count()))
+	return out, codec
+}
+
+// compressible returns the compressed data if compression saves enough of
+// its size, nil otherwise
+func compressible(data []byte) []byte {
+	enc := snappy.Encode(nil, data)
+	if len(enc) > len(data)-len(data)/compressMinSaving {
+		return nil
+	}
+	return enc
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestLDBStoreCompress tests that compressible chunk data is stored
+// compressed, incompressible data is stored as is and that both can be
+// retrieved by stores with and without compression
+func TestLDBStoreCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	params.Compress = true
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a chunk of text and a chunk of random data
+	text := NewChunk(nil, nil)
+	text.SData = make([]byte, 8, 8+DefaultChunkSize)
+	binary.LittleEndian.PutUint64(text.SData, uint64(DefaultChunkSize))
+	text.SData = append(text.SData, bytes.Repeat([]byte("swarm chunk "), int(DefaultChunkSize)/12+1)[:DefaultChunkSize]...)
+	hasher := MakeHashFunc(DefaultHash)()
+	hasher.ResetWithLength(text.SData[:8])
+	hasher.Write(text.SData[8:])
+	text.Addr = hasher.Sum(nil)
+	random := GenerateRandomChunk(DefaultChunkSize)
+
+	for _, chunk := range []*Chunk{text, random} {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, x := range []struct {
+		chunk *Chunk
+		codec uint
+	}{
+		{text, codecSnappy},
+		{random, codecNone},
+	} {
+		idata, err := ldb.db.Get(getIndexKey(x.chunk.Addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var index dpaDBIndex
+		if err := decodeIndex(idata, &index); err != nil {
+			t.Fatal(err)
+		}
+		if (x.codec == codecNone && len(index.Codec) != 0) || (x.codec != codecNone && (len(index.Codec) != 1 || index.Codec[0] != x.codec)) {
+			t.Fatalf("chunk %v: expected codec %d, got %v", x.chunk.Addr, x.codec, index.Codec)
+		}
+		data, err := ldb.db.Get(getDataKey(index.Idx, ldb.po(x.chunk.Addr)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.codec == codecSnappy && len(data) >= len(x.chunk.SData) {
+			t.Fatalf("expected compressed data smaller than %d bytes, got %d", len(x.chunk.SData), len(data))
+		}
+	}
+	ldb.Close()
+
+	// the chunks are retrieved with and without compression enabled
+	for _, compress := range []bool{true, false} {
+		params.Compress = compress
+		ldb, err := NewLDBStore(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range []*Chunk{text, random} {
+			got, err := ldb.Get(chunk.Addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.SData, chunk.SData) {
+				t.Fatalf("compress %v: unexpected data of chunk %v", compress, chunk.Addr)
+			}
+		}
+		info, err := ldb.Inspect(text.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int(DefaultChunkSize) {
+			t.Fatalf("expected inspected size %d, got %d", DefaultChunkSize, info.Size)
+		}
+		ldb.Close()
+	}
+}
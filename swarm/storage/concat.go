@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ConcatReader reads the concatenation of the contents of several readers,
+// such as the readers of the parts of a composite content, as one content.
+// The sizes of the parts are retrieved on the first access.
+type ConcatReader struct {
+	parts []LazySectionReader
+	off   int64
+
+	mu    sync.Mutex
+	sizes []int64 // sizes of the parts, nil until retrieved
+	size  int64
+}
+
+// NewConcatReader creates a reader of the concatenation of the parts
+func NewConcatReader(parts ...LazySectionReader) *ConcatReader {
+	return &ConcatReader{parts: parts}
+}
+
+// Size returns the sum of the sizes of the parts
+func (r *ConcatReader) Size(quitC chan bool) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sizes != nil {
+		return r.size, nil
+	}
+	sizes := make([]int64, len(r.parts))
+	var size int64
+	for i, part := range r.parts {
+		s, err := part.Size(quitC)
+		if err != nil {
+			return 0, err
+		}
+		sizes[i] = s
+		size += s
+	}
+	r.sizes, r.size = sizes, size
+	return size, nil
+}
+
+// ReadAt reads from the parts overlapped by the range, reads across the end
+// of a part continue at the start of the next one
+func (r *ConcatReader) ReadAt(b []byte, off int64) (read int, err error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	size, err := r.Size(nil)
+	if err != nil {
+		return 0, err
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	var start int64
+	for i, part := range r.parts {
+		end := start + r.sizes[i]
+		if pos := off + int64(read); pos < end && read < len(b) {
+			want := len(b) - read
+			if int64(want) > end-pos {
+				want = int(end - pos)
+			}
+			n, err := part.ReadAt(b[read:read+want], pos-start)
+			read += n
+			if err != nil && err != io.EOF {
+				return read, err
+			}
+			if n != want {
+				return read, fmt.Errorf("part %d: expected %d bytes but read %d", i, want, n)
+			}
+		}
+		start = end
+	}
+	if off+int64(len(b)) >= size {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+// Read reads from the current offset like ReadAt
+func (r *ConcatReader) Read(b []byte) (read int, err error) {
+	read, err = r.ReadAt(b, r.off)
+	r.off += int64(read)
+	return read, err
+}
+
+// Seek sets the offset of the next Read, see LazyChunkReader.Seek
+func (r *ConcatReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	default:
+		return 0, errWhence
+	case 0:
+	case 1:
+		offset += r.off
+	case 2:
+		size, err := r.Size(nil)
+		if err != nil {
+			return 0, fmt.Errorf("can't get size: %v", err)
+		}
+		offset += size
+	}
+	if offset < 0 {
+		return 0, errOffset
+	}
+	r.off = offset
+	return offset, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// TestConcatReader tests that the concatenation of stored contents is read
+// across the boundaries of the parts
+func TestConcatReader(t *testing.T) {
+	fileStore := NewFileStore(NewMapChunkStore(), NewFileStoreParams())
+	ctx := context.TODO()
+
+	var parts []LazySectionReader
+	var expected []byte
+	for _, size := range []int{4096 + 1, 0, 10, 3 * 4096} {
+		_, data := generateRandomData(size)
+		if size == 0 {
+			parts = append(parts, &LazyTestSectionReader{io.NewSectionReader(bytes.NewReader(nil), 0, 0)})
+			continue
+		}
+		addr, wait, err := fileStore.Store(ctx, bytes.NewReader(data), int64(size), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		reader, _ := fileStore.Retrieve(ctx, addr)
+		parts = append(parts, reader)
+		expected = append(expected, data...)
+	}
+
+	reader := NewConcatReader(parts...)
+	size, err := reader.Size(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(expected)) {
+		t.Fatalf("expected size %d, got %d", len(expected), size)
+	}
+
+	result, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, expected) {
+		t.Fatal("concatenated content differs")
+	}
+
+	// a read across the three parts
+	b := make([]byte, 20)
+	off := int64(4096 + 1 - 5)
+	if n, err := reader.ReadAt(b, off); n != len(b) || err != nil {
+		t.Fatalf("expected %d bytes, got %d (%v)", len(b), n, err)
+	}
+	if !bytes.Equal(b, expected[off:off+int64(len(b))]) {
+		t.Fatal("content read across the parts differs")
+	}
+
+	// a read past the end
+	if _, err := reader.Seek(-3, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reader.Read(b); n != 3 || err != io.EOF {
+		t.Fatalf("expected 3 bytes and EOF, got %d (%v)", n, err)
+	}
+	if !bytes.Equal(b[:3], expected[len(expected)-3:]) {
+		t.Fatal("content read at the end differs")
+	}
+}
@@ -20,10 +20,10 @@ package storage
 // no need for queueing/caching
 
 import (
-	"fmt"
-
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 )
@@ -31,28 +31,59 @@ import (
 const openFileLimit = 128
 
 type LDBDatabase struct {
-	db *leveldb.DB
+	db       *leveldb.DB
+	path     string
+	recovery *RecoveryReport
 }
 
+// NewLDBDatabase opens the database, a corrupted database is recovered
+// with the loss of its unreadable tables and blocks
 func NewLDBDatabase(file string) (*LDBDatabase, error) {
 	// Open the db
-	db, err := leveldb.OpenFile(file, &opt.Options{OpenFilesCacheCapacity: openFileLimit})
+	o := &opt.Options{OpenFilesCacheCapacity: openFileLimit}
+	db, err := leveldb.OpenFile(file, o)
+	if errors.IsCorrupted(err) {
+		log.Error("database corrupted, recovering", "path", file, "err", err)
+		var report *RecoveryReport
+		db, report, err = recoverLDBDatabase(file, o)
+		if err != nil {
+			return nil, err
+		}
+		return &LDBDatabase{db: db, path: file, recovery: report}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	database := &LDBDatabase{db: db}
+	database := &LDBDatabase{db: db, path: file}
 
 	return database, nil
 }
 
-func (db *LDBDatabase) Put(key []byte, value []byte) {
-	metrics.GetOrRegisterCounter("ldbdatabase.put", nil).Inc(1)
-
-	err := db.db.Put(key, value, nil)
+// NewLDBDatabaseReadOnly opens an existing database without writing to it,
+// a corrupted database is not recovered
+func NewLDBDatabaseReadOnly(file string) (*LDBDatabase, error) {
+	db, err := leveldb.OpenFile(file, &opt.Options{
+		OpenFilesCacheCapacity: openFileLimit,
+		ReadOnly:               true,
+		ErrorIfMissing:         true,
+	})
 	if err != nil {
-		fmt.Println("Error put", err)
+		return nil, err
 	}
+	return &LDBDatabase{db: db, path: file}, nil
+}
+
+// Recovery returns the report of the recovery of the database when it was
+// opened, nil if it was not corrupted
+func (db *LDBDatabase) Recovery() *RecoveryReport {
+	return db.recovery
+}
+
+func (db *LDBDatabase) Put(key []byte, value []byte) error {
+	metrics.GetOrRegisterCounter("ldbdatabase.put", nil).Inc(1)
+
+	return db.db.Put(key, value, nil)
 }
 
 func (db *LDBDatabase) Get(key []byte) ([]byte, error) {
@@ -16,6 +16,8 @@
 
 package storage
 
+import "github.com/ethereum/go-ethereum/event"
+
 // wrapper of db-s to provide mockable custom local chunk store access to syncer
 type DBAPI struct {
 	db  *LDBStore
@@ -50,3 +52,23 @@ func (d *DBAPI) GetOrCreateRequest(addr Address) (*Chunk, bool) {
 func (d *DBAPI) Put(chunk *Chunk) {
 	d.loc.Put(chunk)
 }
+
+// to validate the chunk data apart from storing it, safe to call concurrently
+func (d *DBAPI) Validate(chunk *Chunk) bool {
+	return d.loc.Validate(chunk)
+}
+
+// to store a chunk validated with Validate without validating it again
+func (d *DBAPI) PutValidated(chunk *Chunk) {
+	d.loc.PutValidated(chunk)
+}
+
+// true if the local store rejects new chunks under disk pressure
+func (d *DBAPI) CapacityExhausted() bool {
+	return d.loc.CapacityExhausted()
+}
+
+// to be notified of the changes of the storage capacity
+func (d *DBAPI) SubscribeCapacity(ch chan<- bool) event.Subscription {
+	return d.loc.SubscribeCapacity(ch)
+}
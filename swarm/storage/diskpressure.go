@@ -0,0 +1,154 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+)
+
+const (
+	// DefaultDiskHighWater is the default ratio of the filesystem of the
+	// chunk database used above which new chunks are rejected
+	DefaultDiskHighWater = 0.95
+
+	// diskPressureHysteresis is how far the disk usage has to drop below the
+	// high-water mark for new chunks to be accepted again
+	diskPressureHysteresis = 0.02
+
+	diskCheckInterval = 10 * time.Second
+)
+
+var (
+	diskUsageGauge        = metrics.NewRegisteredGaugeFloat64("localstore.diskusage", nil)
+	diskExhaustedGauge    = metrics.NewRegisteredGauge("localstore.diskpressure", nil)
+	diskExhaustedCounter  = metrics.NewRegisteredCounter("localstore.diskpressure.exhausted", nil)
+	diskRejectedPutsCount = metrics.NewRegisteredCounter("localstore.diskpressure.rejected", nil)
+)
+
+// diskMonitor periodically checks the usage of the filesystem of the chunk
+// database and flags the storage capacity as exhausted while it is above
+// the high-water mark
+type diskMonitor struct {
+	path      string
+	highWater float64
+	usage     func(path string) (used, total uint64, err error)
+
+	mu        sync.RWMutex
+	exhausted bool
+	feed      event.Feed
+	quit      chan struct{}
+}
+
+func newDiskMonitor(path string, highWater float64) *diskMonitor {
+	return &diskMonitor{
+		path:      path,
+		highWater: highWater,
+		usage:     diskUsage,
+		quit:      make(chan struct{}),
+	}
+}
+
+// start checks the disk usage once and then periodically until stop is called
+func (m *diskMonitor) start(interval time.Duration) {
+	if err := m.check(); err != nil {
+		log.Warn("disk usage of the chunk database is not monitored", "path", m.path, "err", err)
+		return
+	}
+	supervisor.Go("storage.diskmonitor", m.quit, func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.check(); err != nil {
+					log.Error("disk usage check failed", "path", m.path, "err", err)
+				}
+			case <-m.quit:
+				return
+			}
+		}
+	})
+}
+
+func (m *diskMonitor) stop() {
+	close(m.quit)
+}
+
+// check updates the exhausted flag from the current disk usage and notifies
+// the subscribers if it changed
+func (m *diskMonitor) check() error {
+	used, total, err := m.usage(m.path)
+	if err != nil {
+		return err
+	}
+	if total == 0 {
+		return nil
+	}
+	ratio := float64(used) / float64(total)
+	diskUsageGauge.Update(ratio)
+
+	m.mu.Lock()
+	var changed bool
+	switch {
+	case !m.exhausted && ratio >= m.highWater:
+		m.exhausted, changed = true, true
+		diskExhaustedCounter.Inc(1)
+		diskExhaustedGauge.Update(1)
+		log.Error("disk usage above high-water mark, rejecting new chunks", "path", m.path, "usage", ratio, "highwater", m.highWater)
+	case m.exhausted && ratio < m.highWater-diskPressureHysteresis:
+		m.exhausted, changed = false, true
+		diskExhaustedGauge.Update(0)
+		log.Warn("disk usage below high-water mark, accepting new chunks", "path", m.path, "usage", ratio, "highwater", m.highWater)
+	}
+	m.mu.Unlock()
+
+	if changed {
+		m.feed.Send(ratio >= m.highWater)
+	}
+	return nil
+}
+
+func (m *diskMonitor) isExhausted() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.exhausted
+}
+
+// CapacityExhausted returns true if the disk usage of the chunk database is
+// above the configured high-water mark and new chunks are rejected
+func (ls *LocalStore) CapacityExhausted() bool {
+	return ls.disk != nil && ls.disk.isExhausted()
+}
+
+// SubscribeCapacity subscribes to the changes of the storage capacity, true
+// is sent on the channel when it is exhausted and false when new chunks are
+// accepted again
+func (ls *LocalStore) SubscribeCapacity(ch chan<- bool) event.Subscription {
+	if ls.disk == nil {
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			<-quit
+			return nil
+		})
+	}
+	return ls.disk.feed.Subscribe(ch)
+}
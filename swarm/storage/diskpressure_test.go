@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestDiskPressure tests that the local store rejects new chunks while the
+// disk usage is above the high-water mark, but still stores requested
+// chunks in memory and serves the stored ones
+func TestDiskPressure(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "storage-testdiskpressure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	params := NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	store, err := NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var used uint64 = 50
+	store.disk = newDiskMonitor(params.ChunkDbPath, 0.9)
+	store.disk.usage = func(string) (uint64, uint64, error) {
+		return used, 100, nil
+	}
+	capacityC := make(chan bool, 1)
+	sub := store.SubscribeCapacity(capacityC)
+	defer sub.Unsubscribe()
+
+	check := func(usage uint64, exhausted bool) {
+		used = usage
+		if err := store.disk.check(); err != nil {
+			t.Fatal(err)
+		}
+		if store.CapacityExhausted() != exhausted {
+			t.Fatalf("usage %d: expected exhausted %v", usage, exhausted)
+		}
+	}
+	expectNotification := func(exhausted bool) {
+		select {
+		case e := <-capacityC:
+			if e != exhausted {
+				t.Fatalf("expected exhausted %v notification, got %v", exhausted, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected exhausted %v notification", exhausted)
+		}
+	}
+
+	check(50, false)
+	stored := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(stored)
+	if err := stored.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	check(95, true)
+	expectNotification(true)
+
+	rejected := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(rejected)
+	if err := rejected.WaitToStore(); err != ErrCapacityExhausted {
+		t.Fatalf("expected %v, got %v", ErrCapacityExhausted, err)
+	}
+	if _, err := store.DbStore.Get(rejected.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected rejected chunk not to be persisted, got %v", err)
+	}
+
+	// stored chunks are still served
+	chunk, err := store.Get(stored.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chunk.SData, stored.SData) {
+		t.Fatal("stored chunk data mismatch")
+	}
+
+	// requested chunks are delivered and cached, but not persisted
+	requested := GenerateRandomChunk(DefaultChunkSize)
+	request, created := store.GetOrCreateRequest(requested.Addr)
+	if !created {
+		t.Fatal("expected a new request")
+	}
+	request.SData = requested.SData
+	store.Put(request)
+	select {
+	case <-request.ReqC:
+	case <-time.After(time.Second):
+		t.Fatal("expected request to be delivered")
+	}
+	if err := request.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	if chunk, err := store.Get(requested.Addr); err != nil || !bytes.Equal(chunk.SData, requested.SData) {
+		t.Fatalf("expected requested chunk to be served, got %v", err)
+	}
+	if _, err := store.DbStore.Get(requested.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected requested chunk not to be persisted, got %v", err)
+	}
+
+	// within the hysteresis the capacity stays exhausted
+	check(89, true)
+	check(80, false)
+	expectNotification(false)
+
+	accepted := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(accepted)
+	if err := accepted.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DbStore.Get(accepted.Addr); err != nil {
+		t.Fatalf("expected accepted chunk to be persisted, got %v", err)
+	}
+}
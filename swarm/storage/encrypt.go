@@ -0,0 +1,145 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var errDataDecrypt = errors.New("chunk data cannot be decrypted")
+
+// storeKeyDomain separates the key the chunk data is encrypted with at rest
+// from other keys derived from the node key
+var storeKeyDomain = []byte("swarm chunk store")
+
+// The chunk data of a store encrypted at rest is encrypted with data keys
+// which are persisted wrapped with the store key, so that the data keys can
+// be rotated without changing the store key. Stores which never rotated
+// their key use the store key as their data key.
+
+// DeriveStoreKey derives the key the chunk data of a node is encrypted with
+// at rest from the private key of the node
+func DeriveStoreKey(prvKey *ecdsa.PrivateKey) []byte {
+	return crypto.Keccak256(storeKeyDomain, crypto.FromECDSA(prvKey))
+}
+
+// newDataCipher returns the AES-GCM cipher the chunk data is encrypted with
+// at rest, the key must be 16, 24 or 32 bytes long
+func newDataCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptData encrypts the chunk data with a random nonce, which is
+// prepended to the ciphertext. The address of the chunk is authenticated
+// with the data so that the data of a chunk cannot be moved to another one.
+func encryptData(aead cipher.AEAD, addr Address, data []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, data, addr)
+}
+
+// decryptData decrypts the chunk data encrypted by encryptData
+func decryptData(aead cipher.AEAD, addr Address, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrChunkInvalid
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], addr)
+}
+
+// wrapKey encrypts a data key with the store key
+func wrapKey(storeKey cipher.AEAD, key []byte) []byte {
+	return encryptData(storeKey, Address(storeKeyDomain), key)
+}
+
+// unwrapKey decrypts a data key wrapped with the store key
+func unwrapKey(storeKey cipher.AEAD, wrapped []byte) ([]byte, error) {
+	return decryptData(storeKey, Address(storeKeyDomain), wrapped)
+}
+
+// dataKeys are the ciphers of the data keys of a store, new chunk data is
+// encrypted with the current key and the data of a chunk is decrypted with
+// the key it was encrypted with
+type dataKeys struct {
+	all []cipher.AEAD // the current key first
+}
+
+func (k *dataKeys) current() cipher.AEAD {
+	return k.all[0]
+}
+
+// open decrypts the chunk data and returns the position of the key it was
+// encrypted with
+func (k *dataKeys) open(addr Address, data []byte) ([]byte, int, error) {
+	for i, aead := range k.all {
+		if plain, err := decryptData(aead, addr, data); err == nil {
+			return plain, i, nil
+		}
+	}
+	return nil, -1, errDataDecrypt
+}
+
+// keys returns the data keys of the store, nil if it is not encrypted
+func (s *LDBStore) keys() *dataKeys {
+	k, _ := s.dataKeys.Load().(*dataKeys)
+	return k
+}
+
+// initDataKeys unwraps the persisted data keys of the store with the store
+// key, the store key is the data key of stores which never rotated their key
+func (s *LDBStore) initDataKeys(key []byte) error {
+	storeKey, err := newDataCipher(key)
+	if err != nil {
+		return err
+	}
+	state := &keyState{Keys: [][]byte{wrapKey(storeKey, key)}}
+	if data, err := s.db.Get(keyDataKeys); err == nil {
+		state = new(keyState)
+		if err := rlp.DecodeBytes(data, state); err != nil {
+			return err
+		}
+	}
+	keys := new(dataKeys)
+	for _, wrapped := range state.Keys {
+		raw, err := unwrapKey(storeKey, wrapped)
+		if err != nil {
+			return fmt.Errorf("cannot unwrap data key, wrong store key: %v", err)
+		}
+		aead, err := newDataCipher(raw)
+		if err != nil {
+			return err
+		}
+		keys.all = append(keys.all, aead)
+	}
+	s.storeKey = storeKey
+	s.keyState = state
+	s.dataKeys.Store(keys)
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestLDBStoreEncrypt tests that the chunk data is encrypted at rest and
+// can only be retrieved with the key it is encrypted with
+func TestLDBStoreEncrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	params.Compress = true
+	params.EncryptionKey = DeriveStoreKey(prvKey)
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	ldb.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	idata, err := ldb.db.Get(getIndexKey(chunk.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index dpaDBIndex
+	if err := decodeIndex(idata, &index); err != nil {
+		t.Fatal(err)
+	}
+	// random data is not compressed
+	if len(index.Codec) != 1 || index.Codec[0] != codecAESGCM {
+		t.Fatalf("expected codecs [%d], got %v", codecAESGCM, index.Codec)
+	}
+	data, err := ldb.db.Get(getDataKey(index.Idx, ldb.po(chunk.Addr)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, chunk.SData[8:64]) {
+		t.Fatal("expected the chunk data to be encrypted")
+	}
+	got, err := ldb.Get(chunk.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.SData, chunk.SData) {
+		t.Fatal("unexpected chunk data")
+	}
+	ldb.Close()
+
+	// the chunk cannot be retrieved without the key or with another key
+	// and is kept in the store
+	otherKey, _ := crypto.GenerateKey()
+	for _, key := range [][]byte{nil, DeriveStoreKey(otherKey)} {
+		params.EncryptionKey = key
+		ldb, err := NewLDBStore(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ldb.Get(chunk.Addr); err == nil {
+			t.Fatal("expected error retrieving encrypted chunk")
+		} else if key == nil && err != ErrStoreEncrypted {
+			t.Fatalf("expected %v, got %v", ErrStoreEncrypted, err)
+		}
+		ldb.Close()
+	}
+
+	params.EncryptionKey = DeriveStoreKey(prvKey)
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	if _, err := ldb.Get(chunk.Addr); err != nil {
+		t.Fatal(err)
+	}
+}
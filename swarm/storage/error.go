@@ -18,6 +18,7 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 )
 
 const (
@@ -36,10 +37,54 @@ const (
 )
 
 var (
-	ErrChunkNotFound    = errors.New("chunk not found")
-	ErrFetching         = errors.New("chunk still fetching")
-	ErrChunkInvalid     = errors.New("invalid chunk")
-	ErrChunkForward     = errors.New("cannot forward")
-	ErrChunkUnavailable = errors.New("chunk unavailable")
-	ErrChunkTimeout     = errors.New("timeout")
+	ErrChunkNotFound     = errors.New("chunk not found")
+	ErrFetching          = errors.New("chunk still fetching")
+	ErrChunkInvalid      = errors.New("invalid chunk")
+	ErrChunkForward      = errors.New("cannot forward")
+	ErrChunkUnavailable  = errors.New("chunk unavailable")
+	ErrChunkTimeout      = errors.New("timeout")
+	ErrCapacityExhausted = errors.New("capacity exhausted")
+	ErrReadOnly          = errors.New("read-only store")
+	ErrShuttingDown      = errors.New("shutting down")
+	ErrStoreClosed       = errors.New("store closed")
+	ErrStoreEncrypted    = errors.New("chunk data encrypted at rest")
+	ErrChunkFault        = errors.New("injected fault")
 )
+
+// ChunkError is an error of an operation on the chunk with the given
+// address, Err is one of the errors above or the error of the chunk store
+type ChunkError struct {
+	Op   string  // operation on the chunk, such as get, store or deliver
+	Addr Address // address of the chunk
+	Peer string  // peer the chunk was requested from or delivered by, if any
+	Err  error
+}
+
+func (e *ChunkError) Error() string {
+	if e.Peer != "" {
+		return fmt.Sprintf("%s chunk %s from peer %s: %v", e.Op, e.Addr, e.Peer, e.Err)
+	}
+	return fmt.Sprintf("%s chunk %s: %v", e.Op, e.Addr, e.Err)
+}
+
+// Cause returns the error the chunk error is caused by
+func (e *ChunkError) Cause() error {
+	return e.Err
+}
+
+// Cause returns the underlying cause of the error, such as ErrChunkNotFound
+// for the ChunkError of a chunk which cannot be retrieved. Errors which have
+// a cause implement the Cause method.
+func Cause(err error) error {
+	type causer interface {
+		Cause() error
+	}
+	for err != nil {
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+)
+
+// StoreEventType is the type of an event of the chunk store
+type StoreEventType string
+
+const (
+	// ChunkStoredEvent is published when a new chunk is written to the store
+	ChunkStoredEvent StoreEventType = "stored"
+	// ChunkEvictedEvent is published when a chunk is removed from the store
+	ChunkEvictedEvent StoreEventType = "evicted"
+	// GCEvent is published after each garbage collection round, Count is the
+	// number of chunks evicted by the round
+	GCEvent StoreEventType = "gc"
+	// SyncBatchEvent is published when the chunks of a bin are iterated for
+	// syncing, Count is the number of chunks of the batch
+	SyncBatchEvent StoreEventType = "syncbatch"
+)
+
+// storeEventQueue is the number of events queued for the subscribers, the
+// events published while the queue is full are dropped
+const storeEventQueue = 1024
+
+var storeEventsDroppedCounter = metrics.NewRegisteredCounter("ldbstore.events.dropped", nil)
+
+// StoreEvent is an event of the lifecycle of the chunks of a store
+type StoreEvent struct {
+	Type  StoreEventType `json:"type"`
+	Addr  Address        `json:"addr,omitempty"` // address of the chunk, if the event is about one
+	Po    uint8          `json:"po"`             // proximity order bin of the chunk or the sync batch
+	Count int            `json:"count,omitempty"`
+	Time  time.Time      `json:"time"`
+}
+
+// storeEvents publishes the events of a store to its subscribers without
+// ever blocking the store, the events are delivered in order by a separate
+// goroutine and dropped if the subscribers fall behind
+type storeEvents struct {
+	feed  event.Feed
+	queue chan *StoreEvent
+}
+
+func newStoreEvents(quit chan struct{}) *storeEvents {
+	e := &storeEvents{
+		queue: make(chan *StoreEvent, storeEventQueue),
+	}
+	supervisor.Go("ldbstore.events", quit, func() {
+		for {
+			select {
+			case ev := <-e.queue:
+				e.feed.Send(ev)
+			case <-quit:
+				return
+			}
+		}
+	})
+	return e
+}
+
+func (e *storeEvents) publish(ev *StoreEvent) {
+	ev.Time = time.Now()
+	select {
+	case e.queue <- ev:
+	default:
+		storeEventsDroppedCounter.Inc(1)
+	}
+}
+
+// SubscribeEvents subscribes to the events of the store, the subscribers
+// have to receive them promptly for the events not to be dropped
+func (s *LDBStore) SubscribeEvents(ch chan<- *StoreEvent) event.Subscription {
+	return s.events.feed.Subscribe(ch)
+}
+
+// EventsAPI streams the events of the chunk store to subscribers in the bzz
+// namespace
+type EventsAPI struct {
+	store *LDBStore
+}
+
+// NewEventsAPI creates the events API of the store
+func NewEventsAPI(store *LDBStore) *EventsAPI {
+	return &EventsAPI{store: store}
+}
+
+// StoreEvents creates a subscription which gets a notification for every
+// event of the chunk store of the given types, of all types if none are
+// given
+func (api *EventsAPI) StoreEvents(ctx context.Context, types []StoreEventType) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	wanted := make(map[StoreEventType]bool)
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	sub := notifier.CreateSubscription()
+	go func() {
+		events := make(chan *StoreEvent, storeEventQueue)
+		eventsSub := api.store.SubscribeEvents(events)
+		defer eventsSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				if len(wanted) > 0 && !wanted[ev.Type] {
+					continue
+				}
+				if err := notifier.Notify(sub.ID, ev); err != nil {
+					log.Warn("rpc sub notifier notify store event", "err", err)
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestLDBStoreEvents tests that the stored, evicted, garbage collection and
+// sync batch events are published to the subscribers
+func TestLDBStoreEvents(t *testing.T) {
+	ldb, cleanup := newLDBStore(t)
+	defer cleanup()
+
+	events := make(chan *StoreEvent, 10)
+	sub := ldb.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+	expect := func(typ StoreEventType, addr Address, count int) {
+		select {
+		case ev := <-events:
+			if ev.Type != typ || !bytes.Equal(ev.Addr, addr) || ev.Count != count {
+				t.Fatalf("expected %s event of %v with count %d, got %s event of %v with count %d", typ, addr, count, ev.Type, ev.Addr, ev.Count)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s event", typ)
+		}
+	}
+
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	ldb.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	expect(ChunkStoredEvent, chunk.Addr, 0)
+
+	po := ldb.po(chunk.Addr)
+	if err := ldb.SyncIterator(0, ldb.CurrentBucketStorageIndex(po), po, func(Address, uint64) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	expect(SyncBatchEvent, nil, 1)
+
+	ldb.lock.Lock()
+	ldb.collectGarbage(1)
+	ldb.lock.Unlock()
+	expect(ChunkEvictedEvent, chunk.Addr, 0)
+	expect(GCEvent, nil, 1)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errExcludeUnsupported = errors.New("chunk store does not support cache exclusion")
+	errPinned             = errors.New("chunk is pinned")
+)
+
+// CacheExcluder is a chunk store which can keep chunks out of its
+// forwarding cache, so that the content of blocked roots is not cached
+// when it is retrieved on behalf of peers
+type CacheExcluder interface {
+	ExcludeFromCache(addr Address) error
+	IncludeInCache(addr Address)
+}
+
+// Evict removes the chunk from the store, pinned chunks are kept
+func (s *LDBStore) Evict(addr Address) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// the chunk may still be pending in the batch
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if s.pinned(addr) {
+		return errPinned
+	}
+	idxKey := getIndexKey(addr)
+	data, err := s.db.Get(idxKey)
+	if err != nil {
+		return ErrChunkNotFound
+	}
+	var index dpaDBIndex
+	if err := decodeIndex(data, &index); err != nil {
+		return err
+	}
+	s.delete(index.Idx, idxKey, s.po(addr))
+	return nil
+}
+
+// ExcludeFromCache removes the chunk from the forwarding cache and keeps it
+// out of it when it is forwarded again, the synced chunks are not affected
+func (ls *LocalStore) ExcludeFromCache(addr Address) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.excluded == nil {
+		ls.excluded = make(map[string]bool)
+	}
+	ls.excluded[string(addr)] = true
+	if ls.cache == nil {
+		return nil
+	}
+	if err := ls.cache.Evict(addr); err != nil && err != ErrChunkNotFound {
+		return err
+	}
+	return nil
+}
+
+// IncludeInCache lets the chunk excluded with ExcludeFromCache be cached
+// again
+func (ls *LocalStore) IncludeInCache(addr Address) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	delete(ls.excluded, string(addr))
+}
+
+// ExcludeFromCache keeps the chunks of the data with the given reference
+// out of the forwarding cache of the local store and returns their
+// addresses. Only the chunks available locally can be found, the root chunk
+// is excluded whether it is available or not.
+func (f *FileStore) ExcludeFromCache(ctx context.Context, ref Address) ([]Address, error) {
+	var addrs []Address
+	err := f.walkLocal(ctx, ref, func(addr Address, err error) error {
+		if err == nil || len(addrs) == 0 {
+			addrs = append(addrs, addr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addrs, f.ExcludeChunksFromCache(addrs)
+}
+
+// ExcludeChunksFromCache keeps the chunks with the given addresses out of
+// the forwarding cache of the local store
+func (f *FileStore) ExcludeChunksFromCache(addrs []Address) error {
+	excluder, ok := f.Local().ChunkStore.(CacheExcluder)
+	if !ok {
+		return errExcludeUnsupported
+	}
+	for _, addr := range addrs {
+		if err := excluder.ExcludeFromCache(addr); err != nil {
+			return &ChunkError{Op: "exclude", Addr: addr, Err: err}
+		}
+	}
+	return nil
+}
+
+// IncludeChunksInCache lets the chunks with the given addresses be cached
+// again in the forwarding cache of the local store
+func (f *FileStore) IncludeChunksInCache(addrs []Address) error {
+	excluder, ok := f.Local().ChunkStore.(CacheExcluder)
+	if !ok {
+		return errExcludeUnsupported
+	}
+	for _, addr := range addrs {
+		excluder.IncludeInCache(addr)
+	}
+	return nil
+}
@@ -19,6 +19,10 @@ package storage
 import (
 	"context"
 	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/singleflight"
 )
 
 /*
@@ -39,9 +43,19 @@ const (
 	defaultChunkRequestsCacheCapacity = 5000000 // capacity for container holding outgoing requests for chunks. should be set to LevelDB capacity
 )
 
+var (
+	fileStoreGetSharedCounter = metrics.NewRegisteredCounter("filestore.get.shared", nil)
+	fileStoreRejectedCounter  = metrics.NewRegisteredCounter("filestore.store.rejected", nil)
+)
+
 type FileStore struct {
 	ChunkStore
 	hashFunc SwarmHasher
+	flights  singleflight.Group // retrievals of chunks in flight
+
+	mu       sync.RWMutex
+	draining bool
+	inflight sync.WaitGroup // store operations whose chunks are not stored yet
 }
 
 type FileStoreParams struct {
@@ -62,7 +76,11 @@ func NewLocalFileStore(datadir string, basekey []byte) (*FileStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	localStore.Validators = append(localStore.Validators, NewContentAddressValidator(MakeHashFunc(DefaultHash)))
+	validator, err := NewMultiHashValidator(SupportedHashes...)
+	if err != nil {
+		return nil, err
+	}
+	localStore.Validators = append(localStore.Validators, validator, NewSOCValidator())
 	return NewFileStore(localStore, NewFileStoreParams()), nil
 }
 
@@ -81,16 +99,159 @@ func NewFileStore(store ChunkStore, params *FileStoreParams) *FileStore {
 // It returns a reader with the chunk data and whether the content was encrypted
 func (f *FileStore) Retrieve(ctx context.Context, addr Address) (reader *LazyChunkReader, isEncrypted bool) {
 	isEncrypted = len(addr) > f.hashFunc().Size()
-	getter := NewHasherStore(f.ChunkStore, f.hashFunc, isEncrypted)
+	getter := NewHasherStore(f, f.hashFunc, isEncrypted)
+	getter.tag = TagFromContext(ctx)
+	// the chunks of the default hash function are validated with it first
+	// anyway
+	if hash := HashFromContext(ctx); hash != DefaultHash {
+		getter.hash = hash
+	}
 	reader = TreeJoin(ctx, addr, getter, 0)
 	return
 }
 
+// hashExpecter is a chunk store which can be told the hash function the
+// chunks it is asked for are addressed with
+type hashExpecter interface {
+	ExpectHash(addr Address, hash string)
+}
+
+// ExpectHash records the hash function the chunk with the given address is
+// addressed with in the local store under the NetStore, so that the chunk
+// is validated with it when it is delivered
+func (f *FileStore) ExpectHash(addr Address, hash string) {
+	if ns := f.netStore(); ns != nil {
+		ns.localStore.ExpectHash(addr, hash)
+	}
+}
+
 // Public API. Main entry point for document storage directly. Used by the
 // FS-aware API and httpaccess
+//
+// The content is stored sparse if the context selects it, see WithSparse.
 func (f *FileStore) Store(ctx context.Context, data io.Reader, size int64, toEncrypt bool) (addr Address, wait func(context.Context) error, err error) {
 	putter := NewHasherStore(f.ChunkStore, f.hashFunc, toEncrypt)
-	return PyramidSplit(ctx, data, putter, putter)
+	putter.tag = TagFromContext(ctx)
+	putter.sparse = SparseFromContext(ctx)
+	return f.split(ctx, data, putter, nil)
+}
+
+// StoreWithHash stores the data like Store, addressing the chunks with the
+// given hash function instead of the one of the FileStore
+//
+// The hash function must be one of SupportedHashes, the content is retrieved
+// the same way regardless of the hash function it was stored with
+func (f *FileStore) StoreWithHash(ctx context.Context, data io.Reader, size int64, toEncrypt bool, hash string) (addr Address, wait func(context.Context) error, err error) {
+	if hash == "" {
+		return f.Store(ctx, data, size, toEncrypt)
+	}
+	hashFunc, err := SupportedHashFunc(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	putter := NewHasherStore(f.ChunkStore, hashFunc, toEncrypt)
+	putter.tag = TagFromContext(ctx)
+	putter.sparse = SparseFromContext(ctx)
+	return f.split(ctx, data, putter, nil)
+}
+
+// Append stores the data as the continuation of the content stored at addr
+// and returns the address of the extended content. The chunks of the
+// existing content are reused, only the last data chunk and the branch of
+// the tree leading to it are stored again. The content is encrypted if the
+// existing content is.
+func (f *FileStore) Append(ctx context.Context, addr Address, data io.Reader) (newAddr Address, wait func(context.Context) error, err error) {
+	putter := NewHasherStore(f.ChunkStore, f.hashFunc, len(addr) > f.hashFunc().Size())
+	putter.tag = TagFromContext(ctx)
+	putter.sparse = SparseFromContext(ctx)
+	return f.split(ctx, data, putter, addr)
+}
+
+// Patch writes the data at the offset of the content stored at addr, like
+// WriteAt, and returns the address of the patched content. Only the chunks
+// overlapped by the data and the branches of the tree leading to them are
+// stored again. The patch must be within the content, the content is
+// encrypted if the existing content is.
+func (f *FileStore) Patch(ctx context.Context, addr Address, data []byte, off int64) (newAddr Address, wait func(context.Context) error, err error) {
+	isEncrypted := len(addr) > f.hashFunc().Size()
+	putter := NewHasherStore(f.ChunkStore, f.hashFunc, isEncrypted)
+	putter.tag = TagFromContext(ctx)
+	putter.sparse = SparseFromContext(ctx)
+	// the chunks read are not counted by the tag of the patch
+	getter := NewHasherStore(f, f.hashFunc, isEncrypted)
+	return f.track(func() (Address, func(context.Context) error, error) {
+		return TreePatch(ctx, addr, data, off, putter, getter)
+	})
+}
+
+// split stores the data with the putter, appending it to the content stored
+// at root if not nil
+func (f *FileStore) split(ctx context.Context, data io.Reader, putter *hasherStore, root Address) (addr Address, wait func(context.Context) error, err error) {
+	return f.track(func() (Address, func(context.Context) error, error) {
+		if root != nil {
+			return PyramidAppend(ctx, root, data, putter, putter)
+		}
+		return PyramidSplit(ctx, data, putter, putter)
+	})
+}
+
+// track runs the store operation, the operation is in flight until all of
+// its chunks are stored
+func (f *FileStore) track(store func() (Address, func(context.Context) error, error)) (addr Address, wait func(context.Context) error, err error) {
+	f.mu.RLock()
+	if f.draining {
+		f.mu.RUnlock()
+		fileStoreRejectedCounter.Inc(1)
+		return nil, nil, ErrShuttingDown
+	}
+	f.inflight.Add(1)
+	f.mu.RUnlock()
+
+	addr, wait, err = store()
+	if err != nil {
+		f.inflight.Done()
+		return nil, nil, err
+	}
+	go func() {
+		wait(context.Background())
+		f.inflight.Done()
+	}()
+	return addr, wait, nil
+}
+
+// Drain stops the FileStore from storing new content and waits until the
+// chunks of the content being stored are stored or the context is done, it
+// is called on shutdown before the chunk store is closed
+func (f *FileStore) Drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.draining = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get retrieves the chunk from the chunk store of the FileStore, concurrent
+// retrievals of the same chunk, such as the ones of the readers of content
+// requested by many clients at the same time, are done once
+func (f *FileStore) Get(addr Address) (*Chunk, error) {
+	v, err, shared := f.flights.Do(string(addr), func() (interface{}, error) {
+		return f.ChunkStore.Get(addr)
+	})
+	if shared {
+		fileStoreGetSharedCounter.Inc(1)
+	}
+	chunk, _ := v.(*Chunk)
+	return chunk, err
 }
 
 func (f *FileStore) HashSize() int {
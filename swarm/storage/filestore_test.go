@@ -19,10 +19,15 @@ package storage
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"io"
 	"io/ioutil"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const testDataSize = 0x1000000
@@ -171,3 +176,241 @@ func testFileStoreCapacity(toEncrypt bool, t *testing.T) {
 		t.Errorf("Comparison error after clearing memStore.")
 	}
 }
+
+// TestFileStoreWithHash tests that content stored with any of the supported
+// hash functions passes the validators of the local store and is retrieved
+// the same way
+func TestFileStoreWithHash(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "filestore-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, err := NewLocalFileStore(datadir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := 3*int(DefaultChunkSize) + 42
+	_, slice := generateRandomData(size)
+	addrs := make(map[string]string)
+	for _, hash := range SupportedHashes {
+		ctx := context.TODO()
+		addr, wait, err := fileStore.StoreWithHash(ctx, bytes.NewReader(slice), int64(size), false, hash)
+		if err != nil {
+			t.Fatalf("%s: store error: %v", hash, err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatalf("%s: store wait error: %v", hash, err)
+		}
+		if other, ok := addrs[addr.Hex()]; ok {
+			t.Fatalf("%s: same address as %s", hash, other)
+		}
+		addrs[addr.Hex()] = hash
+
+		reader, _ := fileStore.Retrieve(ctx, addr)
+		result := make([]byte, size)
+		if _, err := reader.ReadAt(result, 0); err != io.EOF {
+			t.Fatalf("%s: retrieve error: %v", hash, err)
+		}
+		if !bytes.Equal(slice, result) {
+			t.Fatalf("%s: retrieved content differs", hash)
+		}
+	}
+
+	if _, _, err := fileStore.StoreWithHash(context.TODO(), bytes.NewReader(slice), int64(size), false, "MD5"); err == nil {
+		t.Fatal("expected error storing with an unsupported hash function")
+	}
+}
+
+// countingChunkStore is a ChunkStore which counts the chunks put into it
+type countingChunkStore struct {
+	*MapChunkStore
+	puts int64
+}
+
+func (s *countingChunkStore) Put(chunk *Chunk) {
+	atomic.AddInt64(&s.puts, 1)
+	s.MapChunkStore.Put(chunk)
+}
+
+// TestFileStoreAppend tests that appended content is retrieved as the
+// continuation of the existing content and that only the last data chunk
+// and the root chunk are stored again
+func TestFileStoreAppend(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		store := &countingChunkStore{MapChunkStore: NewMapChunkStore()}
+		fileStore := NewFileStore(store, NewFileStoreParams())
+
+		size := 3*int(DefaultChunkSize) + 42
+		_, slice := generateRandomData(size)
+		ctx := context.TODO()
+		addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), toEncrypt)
+		if err != nil {
+			t.Fatalf("encrypt %v: store error: %v", toEncrypt, err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatalf("encrypt %v: store wait error: %v", toEncrypt, err)
+		}
+
+		atomic.StoreInt64(&store.puts, 0)
+		_, appended := generateRandomData(100)
+		newAddr, wait, err := fileStore.Append(ctx, addr, bytes.NewReader(appended))
+		if err != nil {
+			t.Fatalf("encrypt %v: append error: %v", toEncrypt, err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatalf("encrypt %v: append wait error: %v", toEncrypt, err)
+		}
+		if len(newAddr) != len(addr) || bytes.Equal(newAddr, addr) {
+			t.Fatalf("encrypt %v: expected a new address of %d bytes, got %v", toEncrypt, len(addr), newAddr)
+		}
+		if puts := atomic.LoadInt64(&store.puts); puts != 2 {
+			t.Fatalf("encrypt %v: expected 2 chunks stored by the append, got %d", toEncrypt, puts)
+		}
+
+		expected := append(slice, appended...)
+		reader, isEncrypted := fileStore.Retrieve(ctx, newAddr)
+		if isEncrypted != toEncrypt {
+			t.Fatalf("encrypt %v: retrieved content encrypted %v", toEncrypt, isEncrypted)
+		}
+		result := make([]byte, len(expected))
+		if _, err := reader.ReadAt(result, 0); err != io.EOF {
+			t.Fatalf("encrypt %v: retrieve error: %v", toEncrypt, err)
+		}
+		if !bytes.Equal(expected, result) {
+			t.Fatalf("encrypt %v: retrieved content differs", toEncrypt)
+		}
+	}
+}
+
+// heldChunkStore is a ChunkStore which stores the chunks put into it when
+// it is released
+type heldChunkStore struct {
+	*MapChunkStore
+	release chan struct{}
+}
+
+func (s *heldChunkStore) Put(chunk *Chunk) {
+	go func() {
+		<-s.release
+		s.MapChunkStore.Put(chunk)
+	}()
+}
+
+// TestFileStoreDrain tests that a draining FileStore waits for the chunks of
+// the content being stored and rejects new content
+func TestFileStoreDrain(t *testing.T) {
+	store := &heldChunkStore{
+		MapChunkStore: NewMapChunkStore(),
+		release:       make(chan struct{}),
+	}
+	fileStore := NewFileStore(store, NewFileStoreParams())
+
+	size := 3*int(DefaultChunkSize) + 42
+	_, slice := generateRandomData(size)
+	if _, _, err := fileStore.Store(context.TODO(), bytes.NewReader(slice), int64(size), false); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := fileStore.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected drain to time out with chunks not stored, got %v", err)
+	}
+	if _, _, err := fileStore.Store(context.TODO(), bytes.NewReader(slice), int64(size), false); err != ErrShuttingDown {
+		t.Fatalf("expected %v storing while draining, got %v", ErrShuttingDown, err)
+	}
+
+	close(store.release)
+	if err := fileStore.Drain(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	// the root chunk and 4 data chunks
+	if n := len(store.chunks); n != 5 {
+		t.Fatalf("expected 5 chunks stored, got %d", n)
+	}
+}
+
+// TestChunkErrorCause tests that the content of a missing chunk cannot be
+// retrieved with an error caused by ErrChunkNotFound which carries the
+// address of the chunk
+func TestChunkErrorCause(t *testing.T) {
+	fileStore := NewFileStore(NewMapChunkStore(), NewFileStoreParams())
+	addr := Address(make([]byte, 32))
+	addr[0] = 1
+	reader, _ := fileStore.Retrieve(context.TODO(), addr)
+	_, err := reader.Size(nil)
+	if Cause(err) != ErrChunkNotFound {
+		t.Fatalf("expected %v, got %v", ErrChunkNotFound, err)
+	}
+	if cerr, ok := err.(*ChunkError); !ok || !bytes.Equal(cerr.Addr, addr) {
+		t.Fatalf("expected chunk error for %v, got %v", addr, err)
+	}
+}
+
+func TestMultiHashValidator(t *testing.T) {
+	data := make([]byte, 8+42)
+	binary.LittleEndian.PutUint64(data, 42)
+	hasher := MakeHashFunc(BLAKE2BHash)()
+	hasher.ResetWithLength(data[:8])
+	hasher.Write(data[8:])
+	addr := Address(hasher.Sum(nil))
+
+	if NewContentAddressValidator(MakeHashFunc(DefaultHash)).Validate(addr, data) {
+		t.Fatal("expected default hash validator to reject BLAKE2b address")
+	}
+	validator, err := NewMultiHashValidator(SupportedHashes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validator.Validate(addr, data) {
+		t.Fatal("expected multi-hash validator to accept BLAKE2b address")
+	}
+	addr[0] ^= 1
+	if validator.Validate(addr, data) {
+		t.Fatal("expected multi-hash validator to reject invalid address")
+	}
+	if _, err := NewMultiHashValidator("MD5"); err == nil {
+		t.Fatal("expected error for unknown hash function")
+	}
+}
+
+// TestExpectedHashValidation tests that a chunk expected to be addressed with
+// a hash function is validated with it without trying the others first
+func TestExpectedHashValidation(t *testing.T) {
+	data := make([]byte, 8+42)
+	binary.LittleEndian.PutUint64(data, 42)
+	hasher := MakeHashFunc(SHA3Hash)()
+	hasher.ResetWithLength(data[:8])
+	hasher.Write(data[8:])
+	chunk := NewChunk(Address(hasher.Sum(nil)), nil)
+	chunk.SData = data
+
+	var hashed int32
+	counting := func(hash string) SwarmHasher {
+		return func() SwarmHash {
+			atomic.AddInt32(&hashed, 1)
+			return MakeHashFunc(hash)()
+		}
+	}
+	hashes, _ := lru.New(expectedHashesCap)
+	ls := &LocalStore{
+		Validators: []ChunkValidator{NewContentAddressValidator(counting(BMTHash), counting(SHA3Hash))},
+		hashes:     hashes,
+	}
+	if !ls.Validate(chunk) || hashed != 2 {
+		t.Fatalf("expected the chunk to be valid after 2 hashes, got %d", hashed)
+	}
+	atomic.StoreInt32(&hashed, 0)
+	ls.ExpectHash(chunk.Addr, SHA3Hash)
+	if !ls.Validate(chunk) || hashed != 0 {
+		t.Fatalf("expected the chunk to be valid with the expected hash only, got %d other hashes", hashed)
+	}
+
+	// a wrong expectation falls back to the other hash functions
+	ls.ExpectHash(chunk.Addr, SHA256Hash)
+	if !ls.Validate(chunk) {
+		t.Fatal("expected the chunk to be valid with another hash function than the expected one")
+	}
+}
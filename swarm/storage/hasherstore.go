@@ -18,6 +18,7 @@ package storage
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"sync"
 
@@ -38,6 +39,13 @@ type hasherStore struct {
 	refSize         int64 // reference size (content hash + possibly encryption key)
 	wg              *sync.WaitGroup
 	closed          chan struct{}
+	errMu           sync.Mutex
+	err             error  // first error of storing a chunk
+	tag             *Tag   // counts the chunks put and retrieved, nil if untagged
+	hash            string // hash function the retrieved chunks are expected to be addressed with, empty if not known
+	sparse          bool   // content chunks of zeros are represented by the zero reference, see isZeroChunk
+	taggedMu        sync.Mutex
+	tagged          map[string]uint32 // states the retrieved chunks are counted in by the tag
 }
 
 func newChunkEncryption(chunkSize, refSize int64) *chunkEncryption {
@@ -74,7 +82,13 @@ func NewHasherStore(chunkStore ChunkStore, hashFunc SwarmHasher, toEncrypt bool)
 // Put stores the chunkData into the ChunkStore of the hasherStore and returns the reference.
 // If hasherStore has a chunkEncryption object, the data will be encrypted.
 // Asynchronous function, the data will not necessarily be stored when it returns.
+//
+// Unencrypted content chunks of zeros of sparse content are not stored, the
+// reserved zero reference is returned for them instead, see isZeroChunk.
 func (h *hasherStore) Put(chunkData ChunkData) (Reference, error) {
+	if h.sparse && h.chunkEncryption == nil && isZeroChunk(chunkData) {
+		return make(Reference, h.refSize), nil
+	}
 	c := chunkData
 	size := chunkData.Size()
 	var encryptionKey encryption.Key
@@ -86,6 +100,7 @@ func (h *hasherStore) Put(chunkData ChunkData) (Reference, error) {
 		}
 	}
 	chunk := h.createChunk(c, size)
+	chunk.IncTag(StateTotal)
 
 	h.storeChunk(chunk)
 
@@ -96,16 +111,27 @@ func (h *hasherStore) Put(chunkData ChunkData) (Reference, error) {
 // If the data is encrypted and the reference contains an encryption key, it will be decrypted before
 // return.
 func (h *hasherStore) Get(ref Reference) (ChunkData, error) {
+	if isZeroReference(ref) {
+		return newZeroChunkData(), nil
+	}
 	key, encryptionKey, err := parseReference(ref, h.hashSize)
 	if err != nil {
 		return nil, err
 	}
 	toDecrypt := (encryptionKey != nil)
 
+	h.countTag(key, StateTotal)
+	if h.hash != "" {
+		if e, ok := h.store.(hashExpecter); ok {
+			e.ExpectHash(key, h.hash)
+		}
+	}
 	chunk, err := h.store.Get(key)
 	if err != nil {
-		return nil, err
+		h.countTag(key, StateFailed)
+		return nil, &ChunkError{Op: "get", Addr: key, Err: err}
 	}
+	h.countTag(key, StateStored)
 
 	chunkData := chunk.SData
 	if toDecrypt {
@@ -125,12 +151,34 @@ func (h *hasherStore) Close() {
 }
 
 // Wait returns when
-//    1) the Close() function has been called and
-//    2) all the chunks which has been Put has been stored
+//  1. the Close() function has been called and
+//  2. all the chunks which has been Put has been stored
+//
+// It returns the first error of storing the chunks.
 func (h *hasherStore) Wait(ctx context.Context) error {
 	<-h.closed
 	h.wg.Wait()
-	return nil
+	h.errMu.Lock()
+	defer h.errMu.Unlock()
+	return h.err
+}
+
+// countTag counts the retrieved chunk with the given address in the state
+// of the tag, chunks retrieved more than once are counted once
+func (h *hasherStore) countTag(addr Address, state State) {
+	if h.tag == nil {
+		return
+	}
+	h.taggedMu.Lock()
+	defer h.taggedMu.Unlock()
+	if h.tagged == nil {
+		h.tagged = make(map[string]uint32)
+	}
+	bit := uint32(1) << uint(state)
+	if h.tagged[string(addr)]&bit == 0 {
+		h.tagged[string(addr)] |= bit
+		h.tag.Inc(state)
+	}
 }
 
 func (h *hasherStore) createHash(chunkData ChunkData) Address {
@@ -145,6 +193,7 @@ func (h *hasherStore) createChunk(chunkData ChunkData, chunkSize int64) *Chunk {
 	chunk := NewChunk(hash, nil)
 	chunk.SData = chunkData
 	chunk.Size = chunkSize
+	chunk.Tag = h.tag
 
 	return chunk
 }
@@ -210,7 +259,16 @@ func (h *hasherStore) RefSize() int64 {
 func (h *hasherStore) storeChunk(chunk *Chunk) {
 	h.wg.Add(1)
 	go func() {
-		<-chunk.dbStoredC
+		if err := chunk.WaitToStore(); err != nil {
+			chunk.IncTag(StateFailed)
+			h.errMu.Lock()
+			if h.err == nil {
+				h.err = &ChunkError{Op: "store", Addr: chunk.Addr, Err: err}
+			}
+			h.errMu.Unlock()
+		} else {
+			chunk.IncTag(StateStored)
+		}
 		h.wg.Done()
 	}()
 	h.store.Put(chunk)
@@ -229,3 +287,58 @@ func parseReference(ref Reference, hashSize int) (Address, encryption.Key, error
 	}
 
 }
+
+type sparseKey struct{}
+
+// WithSparse returns a context selecting whether the content stored with it
+// is sparse, see isZeroChunk
+func WithSparse(ctx context.Context, sparse bool) context.Context {
+	return context.WithValue(ctx, sparseKey{}, sparse)
+}
+
+// SparseFromContext returns true if the context selects sparse content
+func SparseFromContext(ctx context.Context) bool {
+	sparse, _ := ctx.Value(sparseKey{}).(bool)
+	return sparse
+}
+
+// isZeroChunk returns true if the chunk data is a full content chunk of zeros
+//
+// Long runs of zeros in sparse files and disk images are represented by the
+// zero reference (a reference of zero bytes) in the chunk tree of sparse
+// content, so that the zeros are neither stored nor retrieved from the
+// network. The root hash of sparse content differs from the one of the same
+// data stored in full, and nodes which do not know the zero reference cannot
+// retrieve it, so content is only stored sparse on request.
+func isZeroChunk(chunkData ChunkData) bool {
+	if int64(len(chunkData)) != DefaultChunkSize+8 || chunkData.Size() != DefaultChunkSize {
+		return false
+	}
+	for _, b := range chunkData[8:] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeroReference returns true if the reference is the reserved reference
+// of a content chunk of zeros
+func isZeroReference(ref Reference) bool {
+	if len(ref) == 0 {
+		return false
+	}
+	for _, b := range ref {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// newZeroChunkData returns the data of a full content chunk of zeros
+func newZeroChunkData() ChunkData {
+	c := make(ChunkData, DefaultChunkSize+8)
+	binary.LittleEndian.PutUint64(c[:8], uint64(DefaultChunkSize))
+	return c
+}
@@ -19,6 +19,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"io"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/swarm/storage/encryption"
@@ -120,3 +121,76 @@ func TestHasherStore(t *testing.T) {
 		}
 	}
 }
+
+// TestHasherStoreZeroChunks tests that content chunks of zeros of sparse
+// content are represented by the zero reference and are not stored
+func TestHasherStoreZeroChunks(t *testing.T) {
+	chunkStore := NewMapChunkStore()
+	hasherStore := NewHasherStore(chunkStore, MakeHashFunc(DefaultHash), false)
+	hasherStore.sparse = true
+
+	// 1MB of zeros between two short runs of data, like a sparse file
+	size := 2*DefaultChunkSize + 1<<20
+	data := make([]byte, size)
+	copy(data, []byte("header"))
+	copy(data[size-DefaultChunkSize:], []byte("trailer"))
+
+	ctx := context.TODO()
+	addr, wait, err := PyramidSplit(ctx, bytes.NewReader(data), hasherStore, hasherStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// two data chunks, three intermediate chunks of 128, 128 and 8 references
+	// and the root chunk, none of the 262 chunks of zeros
+	if len(chunkStore.chunks) != 6 {
+		t.Fatalf("expected 6 chunks to be stored, got %v", len(chunkStore.chunks))
+	}
+
+	reader := TreeJoin(ctx, addr, hasherStore, 0)
+	output := make([]byte, size)
+	if n, err := reader.ReadAt(output, 0); n != int(size) || err != io.EOF {
+		t.Fatalf("expected to read %v bytes and EOF, got %v and %v", size, n, err)
+	}
+	if !bytes.Equal(output, data) {
+		t.Fatal("joined data does not match")
+	}
+
+	// the tree chunker represents zeros the same way
+	treeStore := NewHasherStore(chunkStore, MakeHashFunc(DefaultHash), false)
+	treeStore.sparse = true
+	treeAddr, _, err := TreeSplit(ctx, bytes.NewReader(data), size, treeStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(treeAddr, addr) {
+		t.Fatalf("tree chunker and pyramid chunker key mismatch, TC: %v PC: %v", treeAddr, addr)
+	}
+
+	// content is only stored sparse on request, its root hash differs
+	fullStore := NewMapChunkStore()
+	fullAddr, _, err := PyramidSplit(ctx, bytes.NewReader(data), NewHasherStore(fullStore, MakeHashFunc(DefaultHash), false), NewHasherStore(fullStore, MakeHashFunc(DefaultHash), false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(fullAddr, addr) {
+		t.Fatal("expected the root hash of the content stored in full to differ")
+	}
+	if len(fullStore.chunks) <= len(chunkStore.chunks) {
+		t.Fatalf("expected the chunks of zeros to be stored, got %v chunks", len(fullStore.chunks))
+	}
+
+	// encrypted zeros are stored, the zero reference would leak them
+	encryptedStore := NewHasherStore(NewMapChunkStore(), MakeHashFunc(DefaultHash), true)
+	encryptedStore.sparse = true
+	ref, err := encryptedStore.Put(newZeroChunkData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isZeroReference(ref) {
+		t.Fatal("expected encrypted zero chunk to be stored")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// ChunkInfo is the metadata of a chunk in the chunk store
+type ChunkInfo struct {
+	Addr     Address
+	Po       uint8     // proximity bin of the chunk
+	Idx      uint64    // storage index of the chunk
+	Access   uint64    // access count of the last access to the chunk
+	StoredAt time.Time // zero for chunks stored before the sync index
+	Span     int64     // size of the data subtree of the chunk
+	Size     int       // size of the chunk payload
+	Children []Address // references of an intermediate chunk
+}
+
+// Inspect returns the metadata of a chunk without recording the access,
+// the references of an intermediate chunk are decoded as unencrypted
+// references
+func (s *LDBStore) Inspect(addr Address) (*ChunkInfo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	idata, err := s.db.Get(getIndexKey(addr))
+	if err != nil {
+		return nil, ErrChunkNotFound
+	}
+	var index dpaDBIndex
+	if err := decodeIndex(idata, &index); err != nil {
+		return nil, err
+	}
+	info := &ChunkInfo{
+		Addr:   addr,
+		Po:     s.po(addr),
+		Idx:    index.Idx,
+		Access: index.Access,
+	}
+	if val, err := s.db.Get(getSyncIdxKey(info.Idx, info.Po)); err == nil {
+		_, info.StoredAt = decodeSyncIdx(val)
+	}
+
+	var data []byte
+	if s.getDataFunc != nil {
+		data, err = s.getDataFunc(addr)
+	} else {
+		data, err = s.getData(addr, getDataKey(info.Idx, info.Po))
+		if err == nil {
+			data, err = s.decodeChunkData(data, &index)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 40 {
+		return nil, ErrChunkInvalid
+	}
+	payload := data[40:]
+	info.Span = int64(binary.LittleEndian.Uint64(data[32:40]))
+	info.Size = len(payload)
+	if info.Span > int64(len(payload)) && len(payload)%KeyLength == 0 {
+		for i := 0; i < len(payload); i += KeyLength {
+			info.Children = append(info.Children, Address(append([]byte{}, payload[i:i+KeyLength]...)))
+		}
+	}
+	return info, nil
+}
+
+// Parents returns the addresses of the intermediate chunks in the store
+// which reference the chunk, it scans all the chunks of the store
+func (s *LDBStore) Parents(addr Address) ([]Address, error) {
+	var parents []Address
+	for _, db := range s.shards {
+		found, err := s.parents(db, addr)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, found...)
+	}
+	return parents, nil
+}
+
+// parents returns the parents of the chunk among the chunks with their data
+// in the given database
+func (s *LDBStore) parents(db *LDBDatabase, addr Address) ([]Address, error) {
+	var parents []Address
+	it := db.NewIterator()
+	defer it.Release()
+	for ok := it.Seek([]byte{keyData}); ok; ok = it.Next() {
+		if it.Key()[0] != keyData {
+			break
+		}
+		data := it.Value()
+		if len(data) < 40 {
+			continue
+		}
+		// the data of compressed chunks is decoded with the codec of their
+		// index entry
+		var index dpaDBIndex
+		if idata, err := s.db.Get(getIndexKey(Address(data[:32]))); err != nil || decodeIndex(idata, &index) != nil {
+			continue
+		}
+		data, err := s.decodeChunkData(data, &index)
+		if err != nil || len(data) < 40 {
+			continue
+		}
+		payload := data[40:]
+		if int64(binary.LittleEndian.Uint64(data[32:40])) <= int64(len(payload)) || len(payload)%KeyLength != 0 {
+			continue
+		}
+		for i := 0; i < len(payload); i += KeyLength {
+			if bytes.Equal(payload[i:i+KeyLength], addr) {
+				parents = append(parents, Address(append([]byte{}, data[:32]...)))
+				break
+			}
+		}
+	}
+	return parents, it.Error()
+}
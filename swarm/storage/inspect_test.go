@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestReadOnlyInspect tests that a store opened read-only shows the
+// metadata and references of its chunks without being changed
+func TestReadOnlyInspect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := GenerateRandomChunks(DefaultChunkSize, 3)
+	span := int64(len(children)) * DefaultChunkSize
+	parent := NewChunk(nil, nil)
+	parent.SData = make([]byte, 8, 8+len(children)*KeyLength)
+	binary.LittleEndian.PutUint64(parent.SData, uint64(span))
+	for _, child := range children {
+		parent.SData = append(parent.SData, child.Addr...)
+	}
+	hasher := MakeHashFunc(DefaultHash)()
+	hasher.ResetWithLength(parent.SData[:8])
+	hasher.Write(parent.SData[8:])
+	parent.Addr = hasher.Sum(nil)
+
+	for _, chunk := range append(children, parent) {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ldb.Close()
+
+	params.ReadOnly = true
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	info, err := ldb.Inspect(parent.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Span != span || info.Size != len(children)*KeyLength {
+		t.Fatalf("expected span %d and size %d, got %d and %d", span, len(children)*KeyLength, info.Span, info.Size)
+	}
+	if info.StoredAt.IsZero() {
+		t.Fatal("expected store time")
+	}
+	if len(info.Children) != len(children) {
+		t.Fatalf("expected %d children, got %d", len(children), len(info.Children))
+	}
+	for i, child := range children {
+		if !bytes.Equal(info.Children[i], child.Addr) {
+			t.Fatalf("child %d: expected %v, got %v", i, child.Addr, info.Children[i])
+		}
+	}
+
+	info, err = ldb.Inspect(children[1].Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Children) != 0 {
+		t.Fatalf("expected no children of a data chunk, got %d", len(info.Children))
+	}
+	parents, err := ldb.Parents(children[1].Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parents) != 1 || !bytes.Equal(parents[0], parent.Addr) {
+		t.Fatalf("expected parent %v, got %v", parent.Addr, parents)
+	}
+
+	// accesses are not recorded and chunks are rejected
+	if _, err := ldb.Get(children[1].Addr); err != nil {
+		t.Fatal(err)
+	}
+	after, err := ldb.Inspect(children[1].Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Access != info.Access {
+		t.Fatalf("expected access %d to be unchanged, got %d", info.Access, after.Access)
+	}
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	ldb.Put(chunk)
+	if err := chunk.WaitToStore(); err != ErrReadOnly {
+		t.Fatalf("expected %v, got %v", ErrReadOnly, err)
+	}
+	if _, err := ldb.Verify(true); err != ErrReadOnly {
+		t.Fatalf("expected %v, got %v", ErrReadOnly, err)
+	}
+}
@@ -25,6 +25,7 @@ package storage
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/cipher"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -32,11 +33,14 @@ import (
 	"io/ioutil"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/storage/mock"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 )
@@ -52,8 +56,14 @@ var (
 	keyAccessCnt   = []byte{2}
 	keyEntryCnt    = []byte{3}
 	keyDataIdx     = []byte{4}
+	keyGCPos       = []byte{5}
 	keyData        = byte(6)
 	keyDistanceCnt = byte(7)
+	keySyncIdx     = byte(8)
+	keySchema      = []byte{9}
+	keyDataKeys    = []byte{10}
+	keyShards      = []byte{11}
+	keyPin         = byte(12)
 )
 
 type gcItem struct {
@@ -67,6 +77,15 @@ type LDBStoreParams struct {
 	*StoreParams
 	Path string
 	Po   func(Address) uint8
+	// ReadOnly opens the store without ever writing to it, chunks are not
+	// accepted, accesses are not recorded and no garbage is collected
+	ReadOnly bool
+	// ShardPaths are the directories of the databases the chunk data is
+	// sharded to in addition to the one at Path
+	ShardPaths []string
+	// KeepShards opens the store with the directories of the shards it was
+	// last opened with instead of ShardPaths
+	KeepShards bool
 }
 
 // NewLDBStoreParams constructs LDBStoreParams with the specified values.
@@ -87,10 +106,25 @@ type LDBStore struct {
 	dataIdx   uint64 // similar to entryCnt, but we only increment it
 	capacity  uint64
 	bucketCnt []uint64
+	gcPos     []byte // index key the next garbage collection round starts from
+	readOnly  bool
+	compress  bool         // store the chunk data compressed
+	storeKey  cipher.AEAD  // cipher the data keys are wrapped with, nil to store the chunk data in plain
+	dataKeys  atomic.Value // *dataKeys the chunk data is encrypted with at rest
+	keyState  *keyState    // persisted state of the data keys and their rotation
+
+	shards       []*LDBDatabase   // databases of the chunk data by address prefix, the first one is db
+	shardBatches []*leveldb.Batch // pending data entries of the shards, the first one is unused
+
+	byteCapacity uint64    // size of the databases on disk garbage is collected above, 0 if unlimited
+	lowWater     float64   // ratio of the byte capacity garbage is collected down to
+	sizedAt      time.Time // time the size of the databases was last measured
+	compacting   int32     // 1 while the databases are compacted after a garbage collection
 
 	hashfunc SwarmHasher
 	po       func(Address) uint8
 
+	events   *storeEvents
 	batchC   chan bool
 	batchesC chan struct{}
 	batch    *leveldb.Batch
@@ -114,21 +148,42 @@ func NewLDBStore(params *LDBStoreParams) (s *LDBStore, err error) {
 	s = new(LDBStore)
 	s.hashfunc = params.Hash
 	s.quit = make(chan struct{})
+	s.events = newStoreEvents(s.quit)
 
 	s.batchC = make(chan bool)
 	s.batchesC = make(chan struct{}, 1)
-	go s.writeBatches()
+	supervisor.Go("ldbstore.writebatches", s.quit, s.writeBatches)
 	s.batch = new(leveldb.Batch)
 	// associate encodeData with default functionality
 	s.encodeDataFunc = encodeData
 
-	s.db, err = NewLDBDatabase(params.Path)
+	if params.ReadOnly {
+		s.readOnly = true
+		s.db, err = NewLDBDatabaseReadOnly(params.Path)
+	} else {
+		s.db, err = NewLDBDatabase(params.Path)
+	}
 	if err != nil {
 		return nil, err
 	}
-
 	s.po = params.Po
+	s.compress = params.Compress
+	if err = s.openShards(params.ShardPaths, params.KeepShards); err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	if len(params.EncryptionKey) > 0 {
+		if err = s.initDataKeys(params.EncryptionKey); err != nil {
+			s.db.Close()
+			return nil, err
+		}
+	}
 	s.setCapacity(params.DbCapacity)
+	s.byteCapacity = params.DbByteCapacity
+	s.lowWater = params.DbLowWater
+	if s.lowWater <= 0 || s.lowWater >= 1 {
+		s.lowWater = DefaultDbLowWater
+	}
 
 	s.bucketCnt = make([]uint64, 0x100)
 	for i := 0; i < 0x100; i++ {
@@ -139,15 +194,37 @@ func NewLDBStore(params *LDBStoreParams) (s *LDBStore, err error) {
 		s.bucketCnt[i] = BytesToU64(cnt)
 		s.bucketCnt[i]++
 	}
+	// the entry count is persisted with the entries, the access counter and
+	// the data index only need to be larger than any persisted one
 	data, _ := s.db.Get(keyEntryCnt)
 	s.entryCnt = BytesToU64(data)
-	s.entryCnt++
 	data, _ = s.db.Get(keyAccessCnt)
 	s.accessCnt = BytesToU64(data)
 	s.accessCnt++
 	data, _ = s.db.Get(keyDataIdx)
 	s.dataIdx = BytesToU64(data)
 	s.dataIdx++
+	s.gcPos, _ = s.db.Get(keyGCPos)
+
+	if s.readOnly {
+		return s, nil
+	}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+
+	if r := s.db.Recovery(); r != nil {
+		r.LostBins = lostBins(r.Lost, params.BaseKey)
+		report, err := s.Verify(true)
+		if err != nil {
+			return nil, err
+		}
+		log.Warn("chunk store recovered", "path", params.Path, "lostbins", r.LostBins, "entries", report.Counted.Entries, "orphaned", report.Orphaned)
+	}
+	// a key rotation interrupted by a restart is resumed
+	if s.keyState != nil && len(s.keyState.Pos) > 0 {
+		supervisor.Go("ldbstore.rotatekey", s.quit, s.rotateKey)
+	}
 
 	return s, nil
 }
@@ -172,6 +249,9 @@ func NewMockDbStore(params *LDBStoreParams, mockStore *mock.NodeStore) (s *LDBSt
 type dpaDBIndex struct {
 	Idx    uint64
 	Access uint64
+	// Codec holds the codec of the chunk data if it is stored compressed,
+	// the entries of uncompressed chunks keep their original encoding
+	Codec []uint `rlp:"tail"`
 }
 
 func BytesToU64(data []byte) uint64 {
@@ -253,10 +333,24 @@ func (s *LDBStore) collectGarbage(ratio float32) {
 	garbage := []*gcItem{}
 	gcnt := 0
 
-	for ok := it.Seek([]byte{keyIndex}); ok && (gcnt < maxGCitems) && (uint64(gcnt) < s.entryCnt); ok = it.Next() {
-		itkey := it.Key()
-
-		if (itkey == nil) || (itkey[0] != keyIndex) {
+	// the round starts where the previous one stopped and wraps around the
+	// index, so that all chunks are considered in turn
+	start := s.gcPos
+	if len(start) == 0 {
+		start = []byte{keyIndex}
+	}
+	wrapped := false
+	ok := it.Seek(start)
+	for (gcnt < maxGCitems) && (uint64(gcnt) < s.entryCnt) {
+		if !ok || it.Key()[0] != keyIndex {
+			if wrapped || len(s.gcPos) == 0 {
+				break
+			}
+			wrapped = true
+			ok = it.Seek([]byte{keyIndex})
+			continue
+		}
+		if wrapped && bytes.Compare(it.Key(), start) >= 0 {
 			break
 		}
 
@@ -281,21 +375,45 @@ func (s *LDBStore) collectGarbage(ratio float32) {
 
 		garbage = append(garbage, gci)
 		gcnt++
+		ok = it.Next()
+	}
+
+	// the position is persisted with the first deletion
+	if ok && it.Key()[0] == keyIndex && gcnt == maxGCitems {
+		s.gcPos = append([]byte{}, it.Key()...)
+	} else {
+		s.gcPos = nil
 	}
+	s.batch.Put(keyGCPos, s.gcPos)
 
 	sort.Slice(garbage[:gcnt], func(i, j int) bool { return garbage[i].value < garbage[j].value })
 
+	// pinned chunks are skipped, the next least accessed ones are deleted
+	// in their place
 	cutoff := int(float32(gcnt) * ratio)
-	metrics.GetOrRegisterCounter("ldbstore.collectgarbage.delete", nil).Inc(int64(cutoff))
-
-	for i := 0; i < cutoff; i++ {
+	deleted := 0
+	for i := 0; i < gcnt && deleted < cutoff; i++ {
+		if s.pinned(garbage[i].idxKey[1:]) {
+			continue
+		}
 		s.delete(garbage[i].idx, garbage[i].idxKey, garbage[i].po)
+		deleted++
 	}
+	metrics.GetOrRegisterCounter("ldbstore.collectgarbage.delete", nil).Inc(int64(deleted))
+	s.events.publish(&StoreEvent{Type: GCEvent, Count: deleted})
 }
 
 // Export writes all chunks from the store to a tar archive, returning the
 // number of chunks written.
 func (s *LDBStore) Export(out io.Writer) (int64, error) {
+	return s.ExportSince(out, time.Time{})
+}
+
+// ExportSince writes the chunks stored at or after the given time to a tar
+// archive, returning the number of chunks written. The modification time of
+// an archive entry is the store time of its chunk, chunks with an unknown
+// store time are only written if since is zero.
+func (s *LDBStore) ExportSince(out io.Writer, since time.Time) (int64, error) {
 	tw := tar.NewWriter(out)
 	defer tw.Close()
 
@@ -313,9 +431,19 @@ func (s *LDBStore) Export(out io.Writer) (int64, error) {
 		hash := key[1:]
 		decodeIndex(it.Value(), &index)
 		po := s.po(hash)
+		var storedAt time.Time
+		if val, err := s.db.Get(getSyncIdxKey(index.Idx, po)); err == nil {
+			_, storedAt = decodeSyncIdx(val)
+		}
+		if !since.IsZero() && storedAt.Before(since) {
+			continue
+		}
 		datakey := getDataKey(index.Idx, po)
 		log.Trace("store.export", "dkey", fmt.Sprintf("%x", datakey), "dataidx", index.Idx, "po", po)
-		data, err := s.db.Get(datakey)
+		data, err := s.getData(hash, datakey)
+		if err == nil {
+			data, err = s.decodeChunkData(data, &index)
+		}
 		if err != nil {
 			log.Warn(fmt.Sprintf("Chunk %x found but could not be accessed: %v", key[:], err))
 			continue
@@ -326,6 +454,9 @@ func (s *LDBStore) Export(out io.Writer) (int64, error) {
 			Mode: 0644,
 			Size: int64(len(data)),
 		}
+		if !storedAt.IsZero() {
+			hdr.ModTime = storedAt
+		}
 		if err := tw.WriteHeader(hdr); err != nil {
 			return count, err
 		}
@@ -338,18 +469,30 @@ func (s *LDBStore) Export(out io.Writer) (int64, error) {
 	return count, nil
 }
 
+// Import reads chunks into the store from a tar archive, returning the number
 // of chunks read.
 func (s *LDBStore) Import(in io.Reader) (int64, error) {
+	count, _, err := s.importChunks(in, false)
+	return count, err
+}
+
+// ResumeImport continues an interrupted import of a tar archive, the chunks
+// already in the store are skipped without reading their data. It returns
+// the number of chunks imported and skipped.
+func (s *LDBStore) ResumeImport(in io.Reader) (int64, int64, error) {
+	return s.importChunks(in, true)
+}
+
+func (s *LDBStore) importChunks(in io.Reader, resume bool) (count int64, skipped int64, err error) {
 	tr := tar.NewReader(in)
 
-	var count int64
 	var wg sync.WaitGroup
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return count, err
+			return count, skipped, err
 		}
 
 		if len(hdr.Name) != 64 {
@@ -362,12 +505,18 @@ func (s *LDBStore) Import(in io.Reader) (int64, error) {
 			log.Warn("ignoring invalid chunk file", "name", hdr.Name, "err", err)
 			continue
 		}
+		key := Address(keybytes)
+		if resume {
+			if _, err := s.db.Get(getIndexKey(key)); err == nil {
+				skipped++
+				continue
+			}
+		}
 
 		data, err := ioutil.ReadAll(tr)
 		if err != nil {
-			return count, err
+			return count, skipped, err
 		}
-		key := Address(keybytes)
 		chunk := NewChunk(key, nil)
 		chunk.SData = data[32:]
 		s.Put(chunk)
@@ -379,7 +528,7 @@ func (s *LDBStore) Import(in io.Reader) (int64, error) {
 		count++
 	}
 	wg.Wait()
-	return count, nil
+	return count, skipped, nil
 }
 
 func (s *LDBStore) Cleanup() {
@@ -401,7 +550,15 @@ func (s *LDBStore) Cleanup() {
 			it.Next()
 			continue
 		}
-		data, err := s.db.Get(getDataKey(index.Idx, s.po(Address(key[1:]))))
+		data, err := s.getData(Address(key[1:]), getDataKey(index.Idx, s.po(Address(key[1:]))))
+		if err == nil {
+			data, err = s.decodeChunkData(data, &index)
+		}
+		if err == ErrStoreEncrypted {
+			// the chunk cannot be checked without the key
+			it.Next()
+			continue
+		}
 		if err != nil {
 			log.Warn(fmt.Sprintf("Chunk %x found but could not be accessed: %v", key[:], err))
 			s.delete(index.Idx, getIndexKey(key[1:]), s.po(Address(key[1:])))
@@ -464,20 +621,201 @@ func (s *LDBStore) ReIndex() {
 	log.Warn(fmt.Sprintf("Found %v errors out of %v entries", errorsFound, total))
 }
 
+// StoreCounters are the counters of a chunk store
+type StoreCounters struct {
+	Entries   uint64   // number of chunks
+	AccessCnt uint64   // next access count
+	DataIdx   uint64   // next storage index
+	Bins      []uint64 // storage index of the latest chunk of each proximity bin
+}
+
+// CountersReport compares the persisted counters of a chunk store with the
+// ones recomputed from its entries
+type CountersReport struct {
+	Stored    StoreCounters
+	Counted   StoreCounters
+	Orphaned  int  // data entries without an index and sync index entries without data
+	Unindexed int  // data entries missing from the sync index
+	Repaired  bool // the persisted counters were replaced with the recomputed ones
+}
+
+// Consistent returns true if the persisted counters agree with the entries
+// of the store
+func (r *CountersReport) Consistent() bool {
+	if r.Orphaned > 0 || r.Unindexed > 0 || r.Stored.Entries != r.Counted.Entries {
+		return false
+	}
+	if r.Stored.AccessCnt < r.Counted.AccessCnt || r.Stored.DataIdx < r.Counted.DataIdx {
+		return false
+	}
+	for po, idx := range r.Counted.Bins {
+		if r.Stored.Bins[po] < idx {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify recomputes the counters of the store from its entries and compares
+// them with the persisted ones, if repair is true inconsistent counters are
+// replaced, the data entries left without an index are removed and the
+// missing sync index entries are added
+func (s *LDBStore) Verify(repair bool) (*CountersReport, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if repair && s.readOnly {
+		return nil, ErrReadOnly
+	}
+	// the pending entries are persisted first
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+
+	r := &CountersReport{
+		Stored: StoreCounters{
+			Bins: make([]uint64, 0x100),
+		},
+		Counted: StoreCounters{
+			Bins: make([]uint64, 0x100),
+		},
+	}
+	data, _ := s.db.Get(keyEntryCnt)
+	r.Stored.Entries = BytesToU64(data)
+	data, _ = s.db.Get(keyAccessCnt)
+	r.Stored.AccessCnt = BytesToU64(data)
+	data, _ = s.db.Get(keyDataIdx)
+	r.Stored.DataIdx = BytesToU64(data)
+	for po := range r.Stored.Bins {
+		data, _ = s.db.Get([]byte{keyDistanceCnt, uint8(po)})
+		r.Stored.Bins[po] = BytesToU64(data)
+	}
+
+	// the orphaned data entries keep their address so that they are deleted
+	// from their shard
+	type orphan struct {
+		key  []byte
+		addr Address
+	}
+	var orphans []orphan
+	unindexed := new(leveldb.Batch)
+	countData := func(key, val []byte) {
+		if len(key) != 10 || len(val) < 32 {
+			return
+		}
+		idx := binary.BigEndian.Uint64(key[2:])
+		if _, err := s.db.Get(getIndexKey(Address(val[:32]))); err != nil {
+			orphans = append(orphans, orphan{append([]byte{}, key...), Address(append([]byte{}, val[:32]...))})
+			return
+		}
+		if _, err := s.db.Get(getSyncIdxKey(idx, key[1])); err != nil {
+			unindexed.Put(getSyncIdxKey(idx, key[1]), encodeSyncIdx(Address(val[:32]), time.Time{}))
+		}
+		if idx >= r.Counted.DataIdx {
+			r.Counted.DataIdx = idx + 1
+		}
+		if idx > r.Counted.Bins[key[1]] {
+			r.Counted.Bins[key[1]] = idx
+		}
+	}
+	it := s.db.NewIterator()
+	for ok := it.Seek([]byte{keyIndex}); ok; ok = it.Next() {
+		key := it.Key()
+		switch key[0] {
+		case keyIndex:
+			var index dpaDBIndex
+			if err := decodeIndex(it.Value(), &index); err != nil {
+				continue
+			}
+			r.Counted.Entries++
+			if index.Access >= r.Counted.AccessCnt {
+				r.Counted.AccessCnt = index.Access + 1
+			}
+		case keyData:
+			countData(key, it.Value())
+		case keySyncIdx:
+			if len(key) != 10 || len(it.Value()) < 32 {
+				continue
+			}
+			if _, err := s.getData(Address(it.Value()[:32]), getDataKey(binary.BigEndian.Uint64(key[2:]), key[1])); err != nil {
+				orphans = append(orphans, orphan{key: append([]byte{}, key...)})
+			}
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	for _, db := range s.shards[1:] {
+		it := db.NewIterator()
+		for ok := it.Seek([]byte{keyData}); ok && it.Key()[0] == keyData; ok = it.Next() {
+			countData(it.Key(), it.Value())
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+	}
+	r.Orphaned = len(orphans)
+	r.Unindexed = unindexed.Len()
+
+	if !repair || r.Consistent() {
+		return r, nil
+	}
+
+	// the access counter and the storage indexes never decrease
+	batch := unindexed
+	for _, o := range orphans {
+		if o.key[0] != keyData {
+			batch.Delete(o.key)
+			continue
+		}
+		s.deleteData(batch, o.addr, o.key)
+		batch.Delete(getSyncIdxKey(binary.BigEndian.Uint64(o.key[2:]), o.key[1]))
+	}
+	s.entryCnt = r.Counted.Entries
+	if s.accessCnt < r.Counted.AccessCnt {
+		s.accessCnt = r.Counted.AccessCnt
+	}
+	if s.dataIdx < r.Counted.DataIdx {
+		s.dataIdx = r.Counted.DataIdx
+	}
+	for po, idx := range r.Counted.Bins {
+		if s.bucketCnt[po] < idx {
+			s.bucketCnt[po] = idx
+		}
+		batch.Put([]byte{keyDistanceCnt, uint8(po)}, U64ToBytes(s.bucketCnt[po]))
+	}
+	if err := s.writeBatch(batch, s.entryCnt, s.dataIdx, s.accessCnt); err != nil {
+		return nil, err
+	}
+	r.Repaired = true
+	log.Warn("repaired chunk store counters", "entries", r.Stored.Entries, "counted", r.Counted.Entries, "orphaned", r.Orphaned)
+	return r, nil
+}
+
+// Recovery returns the report of the recovery of the database when the
+// store was opened, nil if it was not corrupted
+func (s *LDBStore) Recovery() *RecoveryReport {
+	return s.db.Recovery()
+}
+
+// delete removes the entry of a chunk, it is written together with the
+// pending entries so that the persisted entry count stays consistent
 func (s *LDBStore) delete(idx uint64, idxKey []byte, po uint8) {
 	metrics.GetOrRegisterCounter("ldbstore.delete", nil).Inc(1)
+	if s.readOnly {
+		return
+	}
 
-	batch := new(leveldb.Batch)
-	batch.Delete(idxKey)
-	batch.Delete(getDataKey(idx, po))
+	s.batch.Delete(idxKey)
+	s.deleteData(s.batch, Address(idxKey[1:]), getDataKey(idx, po))
+	s.batch.Delete(getSyncIdxKey(idx, po))
 	s.entryCnt--
-	s.bucketCnt[po]--
-	cntKey := make([]byte, 2)
-	cntKey[0] = keyDistanceCnt
-	cntKey[1] = po
-	batch.Put(keyEntryCnt, U64ToBytes(s.entryCnt))
-	batch.Put(cntKey, U64ToBytes(s.bucketCnt[po]))
-	s.db.Write(batch)
+	if err := s.flush(); err != nil {
+		log.Error(fmt.Sprintf("delete: %v", err))
+	}
+	s.events.publish(&StoreEvent{Type: ChunkEvictedEvent, Addr: Address(idxKey[1:]), Po: po})
 }
 
 func (s *LDBStore) CurrentBucketStorageIndex(po uint8) uint64 {
@@ -506,6 +844,17 @@ func (s *LDBStore) Put(chunk *Chunk) {
 	ikey := getIndexKey(chunk.Addr)
 	var index dpaDBIndex
 
+	if s.readOnly {
+		chunk.SetErrored(ErrReadOnly)
+		chunk.markAsStored()
+		return
+	}
+	if s.closed() {
+		chunk.SetErrored(ErrStoreClosed)
+		chunk.markAsStored()
+		return
+	}
+
 	po := s.po(chunk.Addr)
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -518,6 +867,7 @@ func (s *LDBStore) Put(chunk *Chunk) {
 		go func() {
 			<-batchC
 			chunk.markAsStored()
+			s.events.publish(&StoreEvent{Type: ChunkStoredEvent, Addr: chunk.Addr, Po: po})
 		}()
 	} else {
 		log.Trace("ldbstore.put: chunk already exists, only update access", "key", chunk.Addr)
@@ -536,9 +886,15 @@ func (s *LDBStore) Put(chunk *Chunk) {
 
 // force putting into db, does not check access index
 func (s *LDBStore) doPut(chunk *Chunk, index *dpaDBIndex, po uint8) {
-	data := s.encodeDataFunc(chunk)
+	var data []byte
+	if (s.compress || s.keys() != nil) && s.getDataFunc == nil {
+		data = s.encodeChunkData(chunk, index)
+	} else {
+		data = s.encodeDataFunc(chunk)
+	}
 	dkey := getDataKey(s.dataIdx, po)
-	s.batch.Put(dkey, data)
+	s.putData(s.batch, chunk.Addr, dkey, data)
+	s.batch.Put(getSyncIdxKey(s.dataIdx, po), encodeSyncIdx(chunk.Addr, time.Now()))
 	index.Idx = s.dataIdx
 	s.bucketCnt[po] = s.dataIdx
 	s.entryCnt++
@@ -557,48 +913,81 @@ mainLoop:
 		case <-s.quit:
 			break mainLoop
 		case <-s.batchesC:
-			s.lock.Lock()
-			b := s.batch
-			e := s.entryCnt
-			d := s.dataIdx
-			a := s.accessCnt
-			c := s.batchC
-			s.batchC = make(chan bool)
-			s.batch = new(leveldb.Batch)
-			err := s.writeBatch(b, e, d, a)
-			// TODO: set this error on the batch, then tell the chunk
-			if err != nil {
-				log.Error(fmt.Sprintf("spawn batch write (%d entries): %v", b.Len(), err))
+			if !s.writeBatchAndCollect() {
+				break mainLoop
 			}
-			close(c)
-			for e > s.capacity {
-				// Collect garbage in a separate goroutine
-				// to be able to interrupt this loop by s.quit.
-				done := make(chan struct{})
-				go func() {
-					s.collectGarbage(gcArrayFreeRatio)
-					close(done)
-				}()
-
-				e = s.entryCnt
-				select {
-				case <-s.quit:
-					s.lock.Unlock()
-					break mainLoop
-				case <-done:
-				}
-			}
-			s.lock.Unlock()
 		}
 	}
 	log.Trace(fmt.Sprintf("DbStore: quit batch write loop"))
 }
 
+// writeBatchAndCollect writes the pending batch and collects garbage if the
+// store is over capacity, it returns false if the store is closed meanwhile.
+// The lock is released by a deferred call so that the batch write loop can
+// be restarted after a panic.
+func (s *LDBStore) writeBatchAndCollect() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// TODO: set this error on the batch, then tell the chunk
+	if err := s.flush(); err != nil {
+		log.Error(fmt.Sprintf("spawn batch write: %v", err))
+	}
+	if !s.collectTo(s.capacity) {
+		return false
+	}
+	return s.collectBytes()
+}
+
+// collectTo collects garbage until the store has at most the given number of
+// entries, it returns false if the store is closed meanwhile. Must be called
+// with the lock held.
+func (s *LDBStore) collectTo(entries uint64) bool {
+	e := s.entryCnt
+	for e > entries {
+		// Collect garbage in a separate goroutine
+		// to be able to interrupt this loop by s.quit.
+		done := make(chan struct{})
+		go func() {
+			s.collectGarbage(gcArrayFreeRatio)
+			close(done)
+		}()
+
+		select {
+		case <-s.quit:
+			return false
+		case <-done:
+		}
+		// too few entries are left for a round to delete any
+		if s.entryCnt == e {
+			break
+		}
+		e = s.entryCnt
+	}
+	return true
+}
+
+// flush writes the pending batch with the current counters and signals the
+// chunks in it as stored, must be called with the lock held
+func (s *LDBStore) flush() error {
+	if s.readOnly {
+		return nil
+	}
+	b := s.batch
+	c := s.batchC
+	s.batchC = make(chan bool)
+	s.batch = new(leveldb.Batch)
+	defer close(c)
+	return s.writeBatch(b, s.entryCnt, s.dataIdx, s.accessCnt)
+}
+
 // must be called non concurrently
 func (s *LDBStore) writeBatch(b *leveldb.Batch, entryCnt, dataIdx, accessCnt uint64) error {
 	b.Put(keyEntryCnt, U64ToBytes(entryCnt))
 	b.Put(keyDataIdx, U64ToBytes(dataIdx))
 	b.Put(keyAccessCnt, U64ToBytes(accessCnt))
+	if err := s.writeShards(); err != nil {
+		return fmt.Errorf("unable to write batch: %v", err)
+	}
 	l := b.Len()
 	if err := s.db.Write(b); err != nil {
 		return fmt.Errorf("unable to write batch: %v", err)
@@ -627,6 +1016,9 @@ func (s *LDBStore) tryAccessIdx(ikey []byte, index *dpaDBIndex) bool {
 		return false
 	}
 	decodeIndex(idata, index)
+	if s.readOnly {
+		return true
+	}
 	s.batch.Put(keyAccessCnt, U64ToBytes(s.accessCnt))
 	s.accessCnt++
 	index.Access = s.accessCnt
@@ -643,11 +1035,24 @@ func (s *LDBStore) Get(addr Address) (chunk *Chunk, err error) {
 	metrics.GetOrRegisterCounter("ldbstore.get", nil).Inc(1)
 	log.Trace("ldbstore.get", "key", addr)
 
+	if s.closed() {
+		return nil, ErrStoreClosed
+	}
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	return s.get(addr)
 }
 
+// closed returns true if the store is closed
+func (s *LDBStore) closed() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *LDBStore) get(addr Address) (chunk *Chunk, err error) {
 	var indx dpaDBIndex
 
@@ -664,13 +1069,22 @@ func (s *LDBStore) get(addr Address) (chunk *Chunk, err error) {
 			// default DbStore functionality to retrieve chunk data
 			proximity := s.po(addr)
 			datakey := getDataKey(indx.Idx, proximity)
-			data, err = s.db.Get(datakey)
+			data, err = s.getData(addr, datakey)
 			log.Trace("ldbstore.get retrieve", "key", addr, "indexkey", indx.Idx, "datakey", fmt.Sprintf("%x", datakey), "proximity", proximity)
 			if err != nil {
 				log.Trace("ldbstore.get chunk found but could not be accessed", "key", addr, "err", err)
+				if s.readOnly {
+					return
+				}
 				s.delete(indx.Idx, getIndexKey(addr), s.po(addr))
 				return
 			}
+			// the entry is kept if it cannot be decoded, it may be
+			// encrypted with a key the store is not opened with
+			if data, err = s.decodeChunkData(data, &indx); err != nil {
+				log.Trace("ldbstore.get chunk could not be decoded", "key", addr, "err", err)
+				return
+			}
 		}
 
 		chunk = NewChunk(addr, nil)
@@ -713,7 +1127,7 @@ func (s *LDBStore) setCapacity(c uint64) {
 
 	s.capacity = c
 
-	if s.entryCnt > c {
+	if s.entryCnt > c && !s.readOnly {
 		ratio := float32(1.01) - float32(c)/float32(s.entryCnt)
 		if ratio < gcArrayFreeRatio {
 			ratio = gcArrayFreeRatio
@@ -722,13 +1136,28 @@ func (s *LDBStore) setCapacity(c uint64) {
 			ratio = 1
 		}
 		for s.entryCnt > c {
+			e := s.entryCnt
 			s.collectGarbage(ratio)
+			// the remaining chunks are pinned
+			if s.entryCnt == e {
+				break
+			}
 		}
 	}
 }
 
 func (s *LDBStore) Close() {
 	close(s.quit)
+	s.lock.Lock()
+	if s.batch.Len() > 0 {
+		if err := s.flush(); err != nil {
+			log.Error(fmt.Sprintf("close: %v", err))
+		}
+	}
+	s.lock.Unlock()
+	for _, db := range s.shards[1:] {
+		db.Close()
+	}
 	s.db.Close()
 }
 
@@ -736,25 +1165,26 @@ func (s *LDBStore) Close() {
 func (s *LDBStore) SyncIterator(since uint64, until uint64, po uint8, f func(Address, uint64) bool) error {
 	metrics.GetOrRegisterCounter("ldbstore.synciterator", nil).Inc(1)
 
-	sincekey := getDataKey(since, po)
-	untilkey := getDataKey(until, po)
+	sincekey := getSyncIdxKey(since, po)
+	untilkey := getSyncIdxKey(until, po)
 	it := s.db.NewIterator()
 	defer it.Release()
 
+	var count int
 	for ok := it.Seek(sincekey); ok; ok = it.Next() {
 		metrics.GetOrRegisterCounter("ldbstore.synciterator.seek", nil).Inc(1)
 
 		dbkey := it.Key()
-		if dbkey[0] != keyData || dbkey[1] != po || bytes.Compare(untilkey, dbkey) < 0 {
+		if dbkey[0] != keySyncIdx || dbkey[1] != po || bytes.Compare(untilkey, dbkey) < 0 {
 			break
 		}
-		key := make([]byte, 32)
-		val := it.Value()
-		copy(key, val[:32])
-		if !f(Address(key), binary.BigEndian.Uint64(dbkey[2:])) {
+		key, _ := decodeSyncIdx(it.Value())
+		count++
+		if !f(key, binary.BigEndian.Uint64(dbkey[2:])) {
 			break
 		}
 	}
+	s.events.publish(&StoreEvent{Type: SyncBatchEvent, Po: po, Count: count})
 	return it.Error()
 }
 
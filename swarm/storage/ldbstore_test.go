@@ -163,6 +163,31 @@ func testDbStoreNotFound(t *testing.T, mock bool) {
 	}
 }
 
+// TestLDBStoreClosed tests that a closed store returns ErrStoreClosed
+func TestLDBStoreClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	params.Po = testPoFunc
+	db, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := db.Get(ZeroAddr); err != ErrStoreClosed {
+		t.Fatalf("expected %v, got %v", ErrStoreClosed, err)
+	}
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	db.Put(chunk)
+	if err := chunk.WaitToStore(); err != ErrStoreClosed {
+		t.Fatalf("expected %v, got %v", ErrStoreClosed, err)
+	}
+}
+
 func TestDbStoreNotFound(t *testing.T) {
 	testDbStoreNotFound(t, false)
 }
@@ -322,8 +347,8 @@ func TestLDBStoreWithoutCollectGarbage(t *testing.T) {
 		log.Info("got back chunk", "chunk", ret)
 	}
 
-	if ldb.entryCnt != uint64(n+1) {
-		t.Fatalf("expected entryCnt to be equal to %v, but got %v", n+1, ldb.entryCnt)
+	if ldb.entryCnt != uint64(n) {
+		t.Fatalf("expected entryCnt to be equal to %v, but got %v", n, ldb.entryCnt)
 	}
 
 	if ldb.accessCnt != uint64(2*n+1) {
@@ -458,7 +483,7 @@ func TestLDBStoreRemoveThenCollectGarbage(t *testing.T) {
 	n := 7
 
 	chunks := []*Chunk{}
-	for i := 0; i < capacity; i++ {
+	for i := 0; i <= capacity; i++ {
 		c := GenerateRandomChunk(DefaultChunkSize)
 		chunks = append(chunks, c)
 		log.Trace("generate random chunk", "idx", i, "chunk", c)
@@ -491,7 +516,7 @@ func TestLDBStoreRemoveThenCollectGarbage(t *testing.T) {
 	ldb, cleanup = newLDBStore(t)
 	ldb.setCapacity(uint64(capacity))
 
-	n = 10
+	n = capacity + 1
 
 	for i := 0; i < n; i++ {
 		ldb.Put(chunks[i])
@@ -520,3 +545,187 @@ func TestLDBStoreRemoveThenCollectGarbage(t *testing.T) {
 		t.Fatal("expected to get the same data back, but got smth else")
 	}
 }
+
+// TestLDBStoreCountersPersisted tests that the counters of the store are the
+// same after it is reopened and that inconsistent counters are repaired
+func TestLDBStoreCountersPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 20
+	chunks := GenerateRandomChunks(DefaultChunkSize, n)
+	for _, chunk := range chunks {
+		ldb.Put(chunk)
+	}
+	for _, chunk := range chunks {
+		<-chunk.dbStoredC
+	}
+	key := chunks[0].Addr
+	var indx dpaDBIndex
+	ldb.tryAccessIdx(getIndexKey(key), &indx)
+	ldb.delete(indx.Idx, getIndexKey(key), ldb.po(key))
+	ldb.Close()
+
+	for i := 0; i < 2; i++ {
+		ldb, err = NewLDBStore(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ldb.entryCnt != uint64(n-1) {
+			t.Fatalf("expected %d entries after reopening the store, got %d", n-1, ldb.entryCnt)
+		}
+		report, err := ldb.Verify(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Consistent() {
+			t.Fatalf("expected consistent counters, got %+v", report)
+		}
+		ldb.Close()
+	}
+
+	// a stale entry count and a data entry without index, as left by a
+	// crash between writes
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	orphan := GenerateRandomChunk(DefaultChunkSize)
+	ldb.db.Put(keyEntryCnt, U64ToBytes(uint64(n+5)))
+	ldb.entryCnt = uint64(n + 5)
+	ldb.db.Put(getDataKey(ldb.dataIdx+10, ldb.po(orphan.Addr)), encodeData(orphan))
+
+	report, err := ldb.Verify(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stored.Entries != uint64(n+5) || report.Counted.Entries != uint64(n-1) {
+		t.Fatalf("expected %d stored and %d counted entries, got %d and %d", n+5, n-1, report.Stored.Entries, report.Counted.Entries)
+	}
+	if report.Orphaned != 1 || !report.Repaired {
+		t.Fatalf("expected 1 orphaned entry to be repaired, got %+v", report)
+	}
+	if ldb.entryCnt != uint64(n-1) {
+		t.Fatalf("expected %d entries, got %d", n-1, ldb.entryCnt)
+	}
+	if report, err = ldb.Verify(false); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("expected consistent counters after repair, got %+v", report)
+	}
+}
+
+// TestLDBStoreCollectGarbagePosition tests that garbage collection rounds
+// continue from the persisted position of the previous one
+func TestLDBStoreCollectGarbagePosition(t *testing.T) {
+	ldb, cleanup := newLDBStore(t)
+	defer cleanup()
+
+	n := maxGCitems + 10
+	for i := 0; i < n; i++ {
+		ldb.Put(GenerateRandomChunk(DefaultChunkSize))
+	}
+	ldb.Put(GenerateRandomChunk(DefaultChunkSize))
+	last := GenerateRandomChunk(DefaultChunkSize)
+	ldb.Put(last)
+	<-last.dbStoredC
+
+	ldb.lock.Lock()
+	ldb.collectGarbage(0)
+	pos := ldb.gcPos
+	ldb.lock.Unlock()
+	if len(pos) == 0 {
+		t.Fatal("expected a garbage collection position")
+	}
+	ldb.Put(GenerateRandomChunk(DefaultChunkSize))
+	ldb.lock.Lock()
+	if err := ldb.flush(); err != nil {
+		t.Fatal(err)
+	}
+	ldb.lock.Unlock()
+	stored, err := ldb.db.Get(keyGCPos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, pos) {
+		t.Fatalf("expected persisted position %x, got %x", pos, stored)
+	}
+
+	// the next round wraps around the index
+	ldb.lock.Lock()
+	ldb.collectGarbage(0)
+	next := ldb.gcPos
+	ldb.lock.Unlock()
+	if len(next) == 0 || bytes.Compare(next, pos) >= 0 {
+		t.Fatalf("expected the position to wrap around from %x, got %x", pos, next)
+	}
+}
+
+// TestLDBStoreExportSinceResumeImport tests that an incremental export only
+// holds the chunks stored since the given time and that a resumed import
+// skips the chunks already in the store
+func TestLDBStoreExportSinceResumeImport(t *testing.T) {
+	src, cleanup, err := newTestDbStore(false, true)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, cleanup2, err := newTestDbStore(false, true)
+	defer cleanup2()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(store *testDbStore, chunks []*Chunk) {
+		for _, chunk := range chunks {
+			store.Put(chunk)
+		}
+		for _, chunk := range chunks {
+			<-chunk.dbStoredC
+		}
+	}
+	put(src, GenerateRandomChunks(DefaultChunkSize, 5))
+	since := time.Now()
+	chunks := GenerateRandomChunks(DefaultChunkSize, 5)
+	put(src, chunks)
+
+	var buf bytes.Buffer
+	count, err := src.ExportSince(&buf, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 chunks exported, got %d", count)
+	}
+
+	// the chunks imported before the interruption
+	var done []*Chunk
+	for _, chunk := range chunks[:2] {
+		c := NewChunk(chunk.Addr, nil)
+		c.SData = chunk.SData
+		done = append(done, c)
+	}
+	put(dst, done)
+	imported, skipped, err := dst.ResumeImport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 3 || skipped != 2 {
+		t.Fatalf("expected 3 chunks imported and 2 skipped, got %d and %d", imported, skipped)
+	}
+	for _, chunk := range chunks {
+		if _, err := dst.Get(chunk.Addr); err != nil {
+			t.Fatalf("expected chunk %v to be imported, got %v", chunk.Addr, err)
+		}
+	}
+}
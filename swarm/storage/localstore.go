@@ -21,16 +21,35 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/log"
 	"github.com/ethereum/go-ethereum/swarm/storage/mock"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+var (
+	forwardCachePutCount = metrics.NewRegisteredCounter("localstore.forwardcache.put", nil)
+	forwardCacheHitCount = metrics.NewRegisteredCounter("localstore.forwardcache.hit", nil)
+	// forwarded chunks not cached because they are excluded
+	forwardCacheExcludedCount = metrics.NewRegisteredCounter("localstore.forwardcache.excluded", nil)
+
+	// the time from the acceptance of a put to the store confirmation of the
+	// chunk per backend, uploads wait for the confirmations
+	dbStoreStoredTimer      = metrics.NewRegisteredResettingTimer("localstore.stored.ldbstore", nil)
+	forwardCacheStoredTimer = metrics.NewRegisteredResettingTimer("localstore.stored.forwardcache", nil)
 )
 
 type LocalStoreParams struct {
 	*StoreParams
-	ChunkDbPath string
-	Validators  []ChunkValidator `toml:"-"`
+	ChunkDbPath          string
+	ChunkDbShards        []string         // directories of the chunk DBs the chunk data is sharded to in addition to ChunkDbPath
+	DiskHighWater        float64          // ratio of the filesystem used above which new chunks are rejected, 0 disables the check
+	ForwardCacheCapacity uint64           // number of chunks retrieved for peers cached apart from the synced chunks, 0 stores them with the synced chunks
+	DbByteCapacityRatio  float64          // ratio of the disk space available to the chunk DB its byte capacity is periodically set to, 0 to keep DbByteCapacity
+	CacheCapacityRatio   float64          // ratio of the memory of the system the in-memory cache is periodically sized to, 0 to keep CacheCapacity
+	Validators           []ChunkValidator `toml:"-"`
 }
 
 func NewDefaultLocalStoreParams() *LocalStoreParams {
@@ -53,21 +72,59 @@ type LocalStore struct {
 	Validators []ChunkValidator
 	memStore   *MemStore
 	DbStore    *LDBStore
+	cache      *LDBStore // forwarding cache, nil if disabled
+	disk       *diskMonitor
+	capacity   *capacityMonitor
+	excluded   map[string]bool // chunks kept out of the forwarding cache
+	hashes     *lru.Cache      // hash functions of the requested chunks by address, nil if not known
 	mu         sync.Mutex
 }
 
+// expectedHashesCap is the number of requested chunks the hash function they
+// are addressed with is kept for until they are delivered
+const expectedHashesCap = 10000
+
 // This constructor uses MemStore and DbStore as components
 func NewLocalStore(params *LocalStoreParams, mockStore *mock.NodeStore) (*LocalStore, error) {
 	ldbparams := NewLDBStoreParams(params.StoreParams, params.ChunkDbPath)
+	ldbparams.ShardPaths = params.ChunkDbShards
 	dbStore, err := NewMockDbStore(ldbparams, mockStore)
 	if err != nil {
 		return nil, err
 	}
-	return &LocalStore{
-		memStore:   NewMemStore(params.StoreParams, dbStore),
+	hashes, _ := lru.New(expectedHashesCap)
+	ls := &LocalStore{
+		memStore:   NewMemStore(sizeCache(params), dbStore),
 		DbStore:    dbStore,
 		Validators: params.Validators,
-	}, nil
+		hashes:     hashes,
+	}
+	if params.ForwardCacheCapacity > 0 {
+		if ls.cache, err = newForwardCache(params); err != nil {
+			dbStore.Close()
+			return nil, err
+		}
+	}
+	if params.DiskHighWater > 0 {
+		ls.disk = newDiskMonitor(params.ChunkDbPath, params.DiskHighWater)
+		ls.disk.start(diskCheckInterval)
+	}
+	if params.DbByteCapacityRatio > 0 || params.CacheCapacityRatio > 0 {
+		ls.capacity = newCapacityMonitor(params, dbStore, ls.memStore)
+		ls.capacity.start(capacityCheckInterval)
+	}
+	return ls, nil
+}
+
+// newForwardCache opens the store of the forwarding cache next to the chunk
+// DB, it has its own capacity and garbage collection so that the chunks
+// cached along retrieval paths never displace the synced chunks
+func newForwardCache(params *LocalStoreParams) (*LDBStore, error) {
+	storeParams := *params.StoreParams
+	storeParams.DbCapacity = params.ForwardCacheCapacity
+	storeParams.DbByteCapacity = 0
+	path := filepath.Join(filepath.Dir(params.ChunkDbPath), "forwarded")
+	return NewLDBStore(NewLDBStoreParams(&storeParams, path))
 }
 
 func NewTestLocalStoreForAddr(params *LocalStoreParams) (*LocalStore, error) {
@@ -97,25 +154,56 @@ func NewTestLocalStoreForAddr(params *LocalStoreParams) (*LocalStore, error) {
 // After the LDBStore.Put, it is ensured that the MemStore
 // contains the chunk with the same data, but nil ReqC channel.
 func (ls *LocalStore) Put(chunk *Chunk) {
-	if l := len(chunk.SData); l < 9 {
-		log.Debug("incomplete chunk data", "addr", chunk.Addr, "length", l)
+	acceptedAt := time.Now()
+	if !ls.Validate(chunk) {
 		chunk.SetErrored(ErrChunkInvalid)
 		chunk.markAsStored()
 		return
 	}
-	valid := true
-	for _, v := range ls.Validators {
-		if valid = v.Validate(chunk.Addr, chunk.SData); valid {
-			break
-		}
+	ls.put(chunk, acceptedAt)
+}
+
+// PutValidated stores the chunk like Put without validating it, the chunk
+// must have been validated with Validate before
+func (ls *LocalStore) PutValidated(chunk *Chunk) {
+	ls.put(chunk, time.Now())
+}
+
+// ExpectHash records the name of the hash function the chunk with the given
+// address is expected to be addressed with, so that the chunk is validated
+// with it first when it is delivered
+func (ls *LocalStore) ExpectHash(addr Address, hash string) {
+	if ls.hashes != nil && hash != "" {
+		ls.hashes.Add(string(addr), hash)
+	}
+}
+
+// expectedHash returns the hash function recorded for the chunk by
+// ExpectHash or the empty string
+func (ls *LocalStore) expectedHash(addr Address) string {
+	if ls.hashes == nil {
+		return ""
+	}
+	hash, _ := ls.hashes.Get(string(addr))
+	s, _ := hash.(string)
+	return s
+}
+
+// Validate returns true if the chunk data is complete and any of the
+// validators accepts it, it is safe to call concurrently
+func (ls *LocalStore) Validate(chunk *Chunk) bool {
+	if l := len(chunk.SData); l < 9 {
+		log.Debug("incomplete chunk data", "addr", chunk.Addr, "length", l)
+		return false
 	}
-	if !valid {
+	if !validChunk(ls.Validators, chunk, ls.expectedHash(chunk.Addr)) {
 		log.Trace("invalid content address", "addr", chunk.Addr)
-		chunk.SetErrored(ErrChunkInvalid)
-		chunk.markAsStored()
-		return
+		return false
 	}
+	return true
+}
 
+func (ls *LocalStore) put(chunk *Chunk, acceptedAt time.Time) {
 	log.Trace("localstore.put", "addr", chunk.Addr)
 
 	ls.mu.Lock()
@@ -136,13 +224,36 @@ func (ls *LocalStore) Put(chunk *Chunk) {
 		return
 	}
 
-	ls.DbStore.Put(chunk)
+	if ls.CapacityExhausted() {
+		if memChunk == nil {
+			diskRejectedPutsCount.Inc(1)
+			log.Trace("localstore.put rejected, capacity exhausted", "addr", chunk.Addr)
+			chunk.SetErrored(ErrCapacityExhausted)
+			chunk.markAsStored()
+			return
+		}
+		// requested chunks are still delivered to the retrievers and cached
+		// in memory, but not persisted
+		chunk.markAsStored()
+	} else if chunk.Forwarded && ls.excluded[string(chunk.Addr)] {
+		// excluded chunks are delivered to the retrievers, but not cached
+		forwardCacheExcludedCount.Inc(1)
+		chunk.markAsStored()
+	} else if chunk.Forwarded && ls.cache != nil {
+		forwardCachePutCount.Inc(1)
+		chunk.timeStore(forwardCacheStoredTimer, acceptedAt)
+		ls.cache.Put(chunk)
+	} else {
+		chunk.timeStore(dbStoreStoredTimer, acceptedAt)
+		ls.DbStore.Put(chunk)
+	}
 
 	// chunk is no longer a request, but a chunk with data, so replace it in memStore
 	newc := NewChunk(chunk.Addr, nil)
 	newc.SData = chunk.SData
 	newc.Size = chunk.Size
 	newc.dbStoredC = chunk.dbStoredC
+	newc.Tag = chunk.Tag
 
 	ls.memStore.Put(newc)
 
@@ -178,6 +289,11 @@ func (ls *LocalStore) get(addr Address) (chunk *Chunk, err error) {
 	}
 	metrics.GetOrRegisterCounter("localstore.get.cachemiss", nil).Inc(1)
 	chunk, err = ls.DbStore.Get(addr)
+	if err == ErrChunkNotFound && ls.cache != nil {
+		if chunk, err = ls.cache.Get(addr); err == nil {
+			forwardCacheHitCount.Inc(1)
+		}
+	}
 	if err != nil {
 		metrics.GetOrRegisterCounter("localstore.get.error", nil).Inc(1)
 		return
@@ -221,5 +337,14 @@ func (ls *LocalStore) RequestsCacheLen() int {
 
 // Close the local store
 func (ls *LocalStore) Close() {
+	if ls.disk != nil {
+		ls.disk.stop()
+	}
+	if ls.capacity != nil {
+		ls.capacity.stop()
+	}
+	if ls.cache != nil {
+		ls.cache.Close()
+	}
 	ls.DbStore.Close()
 }
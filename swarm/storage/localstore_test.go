@@ -20,6 +20,8 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 var (
@@ -116,3 +118,150 @@ type boolTestValidator bool
 func (self boolTestValidator) Validate(addr Address, data []byte) bool {
 	return bool(self)
 }
+
+// TestForwardCache tests that the chunks retrieved on behalf of peers are
+// stored in the forwarding cache and not with the synced chunks
+func TestForwardCache(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "storage-testforwardcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	params := NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	params.ForwardCacheCapacity = 100
+	store, err := NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := GenerateRandomChunks(DefaultChunkSize, 2)
+	synced, forwarded := chunks[0], chunks[1]
+	store.Put(synced)
+	if err := synced.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	request, created := store.GetOrCreateRequest(forwarded.Addr)
+	if !created {
+		t.Fatal("expected a new request")
+	}
+	request.Forwarded = true
+	request.SData = forwarded.SData
+	store.Put(request)
+	if err := request.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.DbStore.Get(synced.Addr); err != nil {
+		t.Fatalf("expected synced chunk in the chunk store, got %v", err)
+	}
+	if _, err := store.cache.Get(synced.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected synced chunk not to be in the forwarding cache, got %v", err)
+	}
+	if _, err := store.DbStore.Get(forwarded.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected forwarded chunk not to be in the chunk store, got %v", err)
+	}
+	if _, err := store.cache.Get(forwarded.Addr); err != nil {
+		t.Fatalf("expected forwarded chunk in the forwarding cache, got %v", err)
+	}
+}
+
+// TestExcludeFromCache tests that excluded chunks are evicted from the
+// forwarding cache and not cached when they are forwarded again
+func TestExcludeFromCache(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "storage-testexcludefromcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	params := NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	params.ForwardCacheCapacity = 100
+	store, err := NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	forward := func(chunk *Chunk) {
+		request, created := store.GetOrCreateRequest(chunk.Addr)
+		if !created {
+			t.Fatal("expected a new request")
+		}
+		request.Forwarded = true
+		request.SData = chunk.SData
+		store.Put(request)
+		if err := request.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks := GenerateRandomChunks(DefaultChunkSize, 2)
+	cached, excluded := chunks[0], chunks[1]
+	forward(cached)
+	if err := store.ExcludeFromCache(cached.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.cache.Get(cached.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected excluded chunk to be evicted from the forwarding cache, got %v", err)
+	}
+
+	if err := store.ExcludeFromCache(excluded.Addr); err != nil {
+		t.Fatal(err)
+	}
+	forward(excluded)
+	if _, err := store.cache.Get(excluded.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected excluded chunk not to be in the forwarding cache, got %v", err)
+	}
+	if _, err := store.DbStore.Get(excluded.Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected excluded chunk not to be in the chunk store, got %v", err)
+	}
+}
+
+// TestStoredTimer tests that the time to the store confirmation of the chunks
+// put in the local store is recorded once per chunk
+func TestStoredTimer(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "storage-teststoredtimer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	timer := &metrics.StandardResettingTimer{}
+	defer func(t metrics.ResettingTimer) { dbStoreStoredTimer = t }(dbStoreStoredTimer)
+	dbStoreStoredTimer = timer
+
+	params := NewDefaultLocalStoreParams()
+	params.Init(datadir)
+	store, err := NewLocalStore(params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := GenerateRandomChunks(DefaultChunkSize, 3)
+	for _, chunk := range chunks {
+		store.Put(chunk)
+	}
+	for _, chunk := range chunks {
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// putting a stored chunk again does not record another confirmation
+	store.Put(chunks[0])
+
+	values := timer.Values()
+	if len(values) != len(chunks) {
+		t.Fatalf("expected %d store confirmation times, got %d", len(chunks), len(values))
+	}
+	for _, v := range values {
+		if v <= 0 {
+			t.Fatalf("expected a positive store confirmation time, got %d", v)
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 type MemStore struct {
 	cache    *lru.Cache
+	capacity int // capacity of the cache of chunks
 	requests *lru.Cache
 	mu       sync.RWMutex
 	disabled bool
@@ -44,11 +45,8 @@ func NewMemStore(params *StoreParams, _ *LDBStore) (m *MemStore) {
 		}
 	}
 
-	onEvicted := func(key interface{}, value interface{}) {
-		v := value.(*Chunk)
-		<-v.dbStoredC
-	}
-	c, err := lru.NewWithEvict(int(params.CacheCapacity), onEvicted)
+	m = &MemStore{capacity: int(params.CacheCapacity)}
+	c, err := lru.NewWithEvict(m.capacity, m.onEvicted)
 	if err != nil {
 		panic(err)
 	}
@@ -62,10 +60,14 @@ func NewMemStore(params *StoreParams, _ *LDBStore) (m *MemStore) {
 		panic(err)
 	}
 
-	return &MemStore{
-		cache:    c,
-		requests: r,
-	}
+	m.cache, m.requests = c, r
+	return m
+}
+
+// onEvicted waits for an evicted chunk to be stored, so that it is not lost
+func (m *MemStore) onEvicted(key interface{}, value interface{}) {
+	v := value.(*Chunk)
+	<-v.dbStoredC
 }
 
 func (m *MemStore) Get(addr Address) (*Chunk, error) {
@@ -119,29 +121,35 @@ func (m *MemStore) Put(c *Chunk) {
 	m.requests.Remove(string(c.Addr))
 }
 
+// setCapacity resizes the cache of chunks keeping the most recently used
+// ones, a capacity of 0 disables the store. A disabled store is not enabled
+// again.
 func (m *MemStore) setCapacity(n int) {
 	if n <= 0 {
 		m.disabled = true
-	} else {
-		onEvicted := func(key interface{}, value interface{}) {
-			v := value.(*Chunk)
-			<-v.dbStoredC
-		}
-		c, err := lru.NewWithEvict(n, onEvicted)
-		if err != nil {
-			panic(err)
-		}
-
-		r, err := lru.New(defaultChunkRequestsCacheCapacity)
-		if err != nil {
-			panic(err)
-		}
-
-		m = &MemStore{
-			cache:    c,
-			requests: r,
+		return
+	}
+	if m.disabled {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n == m.capacity {
+		return
+	}
+	c, err := lru.NewWithEvict(n, m.onEvicted)
+	if err != nil {
+		panic(err)
+	}
+	// the keys are listed from the least recently used, so that the most
+	// recently used chunks are kept if the cache shrinks
+	for _, key := range m.cache.Keys() {
+		if v, ok := m.cache.Peek(key); ok {
+			c.Add(key, v)
 		}
 	}
+	m.cache = c
+	m.capacity = n
 }
 
 func (s *MemStore) Close() {}
@@ -0,0 +1,284 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// Middleware decorates a ChunkStore with a concern common to the stores,
+// such as metrics or validation, see Wrap
+type Middleware func(ChunkStore) ChunkStore
+
+// Wrap decorates the store with the middlewares, the first middleware is the
+// outermost one, it sees the calls first and their results last
+func Wrap(store ChunkStore, middlewares ...Middleware) ChunkStore {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		store = middlewares[i](store)
+	}
+	return store
+}
+
+// wrapper is a store decorated by a middleware, Unwrap returns the store it
+// decorates
+type wrapper interface {
+	Unwrap() ChunkStore
+}
+
+// wrappedStore is the base of the stores of the middlewares, calls not
+// decorated by the middleware go to the decorated store
+type wrappedStore struct {
+	ChunkStore
+}
+
+func (s *wrappedStore) Unwrap() ChunkStore {
+	return s.ChunkStore
+}
+
+// WithMetrics counts the puts, the gets and the failed gets of the store and
+// times the gets, the metrics are named after the prefix
+func WithMetrics(prefix string) Middleware {
+	putCounter := metrics.GetOrRegisterCounter(prefix+".put", nil)
+	getCounter := metrics.GetOrRegisterCounter(prefix+".get", nil)
+	getErrorCounter := metrics.GetOrRegisterCounter(prefix+".get.error", nil)
+	getTimer := metrics.GetOrRegisterResettingTimer(prefix+".get.time", nil)
+	return func(store ChunkStore) ChunkStore {
+		return &metricsStore{
+			wrappedStore: wrappedStore{store},
+			put:          putCounter,
+			get:          getCounter,
+			getError:     getErrorCounter,
+			getTime:      getTimer,
+		}
+	}
+}
+
+type metricsStore struct {
+	wrappedStore
+	put, get, getError metrics.Counter
+	getTime            metrics.ResettingTimer
+}
+
+func (s *metricsStore) Put(chunk *Chunk) {
+	s.put.Inc(1)
+	s.ChunkStore.Put(chunk)
+}
+
+func (s *metricsStore) Get(addr Address) (*Chunk, error) {
+	s.get.Inc(1)
+	defer s.getTime.UpdateSince(time.Now())
+	chunk, err := s.ChunkStore.Get(addr)
+	if err != nil {
+		s.getError.Inc(1)
+	}
+	return chunk, err
+}
+
+// WithTracing logs every put and get of the store at trace level with the
+// time it took, under the given name
+func WithTracing(name string) Middleware {
+	return func(store ChunkStore) ChunkStore {
+		return &tracingStore{wrappedStore{store}, name}
+	}
+}
+
+type tracingStore struct {
+	wrappedStore
+	name string
+}
+
+func (s *tracingStore) Put(chunk *Chunk) {
+	start := time.Now()
+	s.ChunkStore.Put(chunk)
+	log.Trace("chunk store put", "store", s.name, "addr", chunk.Addr, "elapsed", time.Since(start))
+}
+
+func (s *tracingStore) Get(addr Address) (*Chunk, error) {
+	start := time.Now()
+	chunk, err := s.ChunkStore.Get(addr)
+	log.Trace("chunk store get", "store", s.name, "addr", addr, "elapsed", time.Since(start), "err", err)
+	return chunk, err
+}
+
+// WithValidation rejects the chunks which none of the validators accepts,
+// both when they are put and when they are retrieved. No chunk is rejected
+// without validators.
+func WithValidation(validators ...ChunkValidator) Middleware {
+	return func(store ChunkStore) ChunkStore {
+		return &validatingStore{wrappedStore{store}, validators}
+	}
+}
+
+type validatingStore struct {
+	wrappedStore
+	validators []ChunkValidator
+}
+
+func (s *validatingStore) Put(chunk *Chunk) {
+	if !validChunk(s.validators, chunk, "") {
+		log.Trace("invalid content address", "addr", chunk.Addr)
+		chunk.SetErrored(ErrChunkInvalid)
+		chunk.markAsStored()
+		return
+	}
+	s.ChunkStore.Put(chunk)
+}
+
+func (s *validatingStore) Get(addr Address) (*Chunk, error) {
+	chunk, err := s.ChunkStore.Get(addr)
+	if err != nil {
+		return chunk, err
+	}
+	if !validChunk(s.validators, chunk, "") {
+		return nil, &ChunkError{Op: "get", Addr: addr, Err: ErrChunkInvalid}
+	}
+	return chunk, nil
+}
+
+// validChunk returns true if any of the validators accepts the chunk or if
+// there are none, the validators of content addresses try the given hash
+// function first if it is not empty
+func validChunk(validators []ChunkValidator, chunk *Chunk, hash string) bool {
+	valid := true
+	for _, v := range validators {
+		if hv, ok := v.(hashValidator); ok && hash != "" {
+			valid = hv.ValidateHash(chunk.Addr, chunk.SData, hash)
+		} else {
+			valid = v.Validate(chunk.Addr, chunk.SData)
+		}
+		if valid {
+			break
+		}
+	}
+	return valid
+}
+
+// WithRateLimit limits the rate of the puts and gets of the store to the
+// given number per second, calls above the rate wait for their turn. Up to
+// burst calls are made at once after the store was idle.
+func WithRateLimit(rate float64, burst int) Middleware {
+	return func(store ChunkStore) ChunkStore {
+		if burst < 1 {
+			burst = 1
+		}
+		return &rateLimitedStore{
+			wrappedStore: wrappedStore{store},
+			rate:         rate,
+			burst:        float64(burst),
+			tokens:       float64(burst),
+			last:         time.Now(),
+		}
+	}
+}
+
+// rateLimitedStore is a token bucket, the tokens of the calls waiting are
+// taken in advance so that the calls are spaced evenly
+type rateLimitedStore struct {
+	wrappedStore
+	rate   float64 // tokens added per second
+	burst  float64 // capacity of the bucket
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token from the bucket, waiting until one is added if there
+// is none
+func (s *rateLimitedStore) wait() {
+	if s.rate <= 0 {
+		return
+	}
+	s.mu.Lock()
+	now := time.Now()
+	s.tokens += now.Sub(s.last).Seconds() * s.rate
+	if s.tokens > s.burst {
+		s.tokens = s.burst
+	}
+	s.last = now
+	s.tokens--
+	tokens := s.tokens
+	s.mu.Unlock()
+	if tokens < 0 {
+		time.Sleep(time.Duration(-tokens / s.rate * float64(time.Second)))
+	}
+}
+
+func (s *rateLimitedStore) Put(chunk *Chunk) {
+	s.wait()
+	s.ChunkStore.Put(chunk)
+}
+
+func (s *rateLimitedStore) Get(addr Address) (*Chunk, error) {
+	s.wait()
+	return s.ChunkStore.Get(addr)
+}
+
+// FaultParams are the faults injected into a store by WithFaults
+type FaultParams struct {
+	PutErrorRate float64       // ratio of the puts which fail with ErrChunkFault
+	GetErrorRate float64       // ratio of the gets which fail with ErrChunkFault
+	Delay        time.Duration // time every call is delayed by
+	Seed         int64         // seed of the random faults, so that runs can be repeated
+}
+
+// WithFaults injects failures and delays into the calls of the store, to
+// test how the layers using the store cope with them
+func WithFaults(params FaultParams) Middleware {
+	return func(store ChunkStore) ChunkStore {
+		return &faultyStore{
+			wrappedStore: wrappedStore{store},
+			params:       params,
+			rand:         rand.New(rand.NewSource(params.Seed)),
+		}
+	}
+}
+
+type faultyStore struct {
+	wrappedStore
+	params FaultParams
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// fail delays the call and returns true if it fails with the given rate
+func (s *faultyStore) fail(rate float64) bool {
+	time.Sleep(s.params.Delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64() < rate
+}
+
+func (s *faultyStore) Put(chunk *Chunk) {
+	if s.fail(s.params.PutErrorRate) {
+		chunk.SetErrored(ErrChunkFault)
+		chunk.markAsStored()
+		return
+	}
+	s.ChunkStore.Put(chunk)
+}
+
+func (s *faultyStore) Get(addr Address) (*Chunk, error) {
+	if s.fail(s.params.GetErrorRate) {
+		return nil, &ChunkError{Op: "get", Addr: addr, Err: ErrChunkFault}
+	}
+	return s.ChunkStore.Get(addr)
+}
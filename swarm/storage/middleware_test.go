@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// recordingStore records the names of the middlewares the gets pass
+type recordingStore struct {
+	wrappedStore
+	name  string
+	calls *[]string
+}
+
+func (s *recordingStore) Get(addr Address) (*Chunk, error) {
+	*s.calls = append(*s.calls, s.name)
+	return s.ChunkStore.Get(addr)
+}
+
+// TestWrap tests that the first middleware is the outermost one and that
+// the local store is found under the middlewares
+func TestWrap(t *testing.T) {
+	var calls []string
+	recording := func(name string) Middleware {
+		return func(store ChunkStore) ChunkStore {
+			return &recordingStore{wrappedStore{store}, name, &calls}
+		}
+	}
+	localStore := &LocalStore{}
+	store := Wrap(NewNetStore(localStore, nil), recording("a"), recording("b"), WithMetrics("test.wrap"))
+	if local := NewFileStore(store, NewFileStoreParams()).Local(); local.ChunkStore != localStore {
+		t.Fatalf("expected the local store, got %T", local.ChunkStore)
+	}
+
+	mapStore := NewMapChunkStore()
+	store = Wrap(mapStore, recording("a"), recording("b"))
+	store.Get(Address(make([]byte, 32)))
+	if len(calls) != 2 || calls[0] != "a" || calls[1] != "b" {
+		t.Fatalf("expected calls through a and b, got %v", calls)
+	}
+}
+
+// TestWithValidation tests that invalid chunks are neither put nor retrieved
+func TestWithValidation(t *testing.T) {
+	validator, err := NewMultiHashValidator(DefaultHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapStore := NewMapChunkStore()
+	store := Wrap(mapStore, WithValidation(validator))
+
+	valid := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(valid)
+	if err := valid.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(valid.Addr); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := GenerateRandomChunk(DefaultChunkSize)
+	invalid.SData[8]++
+	store.Put(invalid)
+	if err := invalid.WaitToStore(); err != ErrChunkInvalid {
+		t.Fatalf("expected %v, got %v", ErrChunkInvalid, err)
+	}
+	mapStore.Put(invalid)
+	if _, err := store.Get(invalid.Addr); Cause(err) != ErrChunkInvalid {
+		t.Fatalf("expected %v, got %v", ErrChunkInvalid, err)
+	}
+}
+
+// TestWithFaults tests that the given ratios of the calls fail
+func TestWithFaults(t *testing.T) {
+	mapStore := NewMapChunkStore()
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	mapStore.Put(chunk)
+
+	store := Wrap(mapStore, WithFaults(FaultParams{GetErrorRate: 1}))
+	if _, err := store.Get(chunk.Addr); Cause(err) != ErrChunkFault {
+		t.Fatalf("expected %v, got %v", ErrChunkFault, err)
+	}
+	put := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(put)
+	if err := put.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = Wrap(mapStore, WithFaults(FaultParams{PutErrorRate: 1}))
+	if _, err := store.Get(chunk.Addr); err != nil {
+		t.Fatal(err)
+	}
+	put = GenerateRandomChunk(DefaultChunkSize)
+	store.Put(put)
+	if err := put.WaitToStore(); err != ErrChunkFault {
+		t.Fatalf("expected %v, got %v", ErrChunkFault, err)
+	}
+}
+
+// TestWithRateLimit tests that the calls above the burst wait for their turn
+func TestWithRateLimit(t *testing.T) {
+	mapStore := NewMapChunkStore()
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	mapStore.Put(chunk)
+
+	store := Wrap(mapStore, WithRateLimit(100, 5))
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		if _, err := store.Get(chunk.Addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected the calls above the burst to take 100ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var legacyMigrateCounter = metrics.NewRegisteredCounter("ldbstore.legacy.migrate", nil)
+
+// MigrationReport is the result of a migration of the legacy entries of a
+// chunk store
+type MigrationReport struct {
+	Legacy   int // legacy data entries found
+	Migrated int // chunks re-indexed in the current layout
+	Invalid  int // legacy data entries too short to hold a chunk
+}
+
+// Legacy returns the number of data entries of the store in the legacy
+// layout, which keys the data entries by their storage index only and does
+// not store the address of the chunk with its data
+func (s *LDBStore) Legacy() (int, error) {
+	var count int
+	it := s.db.NewIterator()
+	defer it.Release()
+	for ok := it.Seek([]byte{keyOldData}); ok && it.Key()[0] == keyOldData; ok = it.Next() {
+		count++
+	}
+	return count, it.Error()
+}
+
+// MigrateLegacy re-indexes the chunks stored in the legacy layout into the
+// current one. If dst is nil the entries are migrated in place, otherwise
+// the chunks are put into dst and the store is not changed. Invalid entries
+// are removed when migrating in place. If dryRun is true the chunks are only
+// counted. progress is called with the number of
+// legacy entries processed so far and their total if it is not nil.
+func (s *LDBStore) MigrateLegacy(dst *LDBStore, dryRun bool, progress func(done, total int)) (*MigrationReport, error) {
+	total, err := s.Legacy()
+	if err != nil {
+		return nil, err
+	}
+	r := &MigrationReport{Legacy: total}
+	if total == 0 {
+		return r, nil
+	}
+	inPlace := dst == nil && !dryRun
+	if inPlace {
+		if s.readOnly {
+			return nil, ErrReadOnly
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		// the pending entries are persisted first
+		if err := s.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := new(leveldb.Batch)
+	it := s.db.NewIterator()
+	defer it.Release()
+	done := 0
+	for ok := it.Seek([]byte{keyOldData}); ok && it.Key()[0] == keyOldData; ok = it.Next() {
+		done++
+		if progress != nil {
+			progress(done, total)
+		}
+		data := it.Value()
+		if len(data) < 8 {
+			r.Invalid++
+			if inPlace {
+				batch.Delete(it.Key())
+			}
+			continue
+		}
+		hasher := s.hashfunc()
+		hasher.ResetWithLength(data[:8])
+		hasher.Write(data[8:])
+		addr := Address(hasher.Sum(nil))
+		r.Migrated++
+		if dryRun {
+			continue
+		}
+
+		if !inPlace {
+			chunk := NewChunk(addr, nil)
+			chunk.SData = append([]byte{}, data...)
+			dst.Put(chunk)
+			if err := chunk.WaitToStore(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		s.migrateLegacyEntry(batch, it.Key(), addr, data)
+		if batch.Len() >= syncIndexBatchSize {
+			if err := s.writeBatch(batch, s.entryCnt, s.dataIdx, s.accessCnt); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if inPlace {
+		if err := s.writeBatch(batch, s.entryCnt, s.dataIdx, s.accessCnt); err != nil {
+			return nil, err
+		}
+	}
+	if !dryRun {
+		legacyMigrateCounter.Inc(int64(r.Migrated))
+		log.Info("migrated legacy chunk store entries", "migrated", r.Migrated, "invalid", r.Invalid)
+	}
+	return r, nil
+}
+
+// migrateLegacyEntry adds the writes moving a legacy data entry to a new
+// storage index in the current layout to the batch, the index entry of the
+// chunk keeps its access count if it exists
+func (s *LDBStore) migrateLegacyEntry(batch *leveldb.Batch, key []byte, addr Address, data []byte) {
+	var index dpaDBIndex
+	ikey := getIndexKey(addr)
+	if idata, err := s.db.Get(ikey); err == nil && decodeIndex(idata, &index) == nil {
+		if index.Idx != binary.BigEndian.Uint64(key[1:]) {
+			// the chunk is also stored in the current layout
+			batch.Delete(key)
+			return
+		}
+		// the access counter must stay larger than any persisted one
+		if index.Access >= s.accessCnt {
+			s.accessCnt = index.Access + 1
+		}
+	} else {
+		index.Access = s.accessCnt
+		s.entryCnt++
+	}
+	po := s.po(addr)
+	index.Idx = s.dataIdx
+	s.dataIdx++
+	s.bucketCnt[po] = index.Idx
+
+	chunk := NewChunk(addr, nil)
+	chunk.SData = data
+	batch.Delete(key)
+	batch.Put(ikey, encodeIndex(&index))
+	s.putData(batch, addr, getDataKey(index.Idx, po), encodeData(chunk))
+	batch.Put(getSyncIdxKey(index.Idx, po), encodeSyncIdx(addr, time.Time{}))
+	batch.Put([]byte{keyDistanceCnt, po}, U64ToBytes(s.bucketCnt[po]))
+}
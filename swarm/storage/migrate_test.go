@@ -0,0 +1,153 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// newLegacyTestStore creates a chunk store holding the chunks in the legacy
+// layout
+func newLegacyTestStore(t *testing.T, dir string, chunks []*Chunk) *LDBStoreParams {
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	batch := new(leveldb.Batch)
+	for i, chunk := range chunks {
+		batch.Put(getIndexKey(chunk.Addr), encodeIndex(&dpaDBIndex{Idx: uint64(i), Access: uint64(i)}))
+		batch.Put(getOldDataKey(uint64(i)), chunk.SData)
+	}
+	// an entry too short to hold a chunk
+	batch.Put(getOldDataKey(uint64(len(chunks))), []byte{1, 2, 3})
+	batch.Put(keyEntryCnt, U64ToBytes(uint64(len(chunks))))
+	if err := ldb.db.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+// TestMigrateLegacy tests that the chunks of a legacy chunk store are
+// counted by a dry run and can be retrieved after they are migrated in place
+func TestMigrateLegacy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chunks := GenerateRandomChunks(DefaultChunkSize, 10)
+	params := newLegacyTestStore(t, dir, chunks)
+
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	report, err := ldb.MigrateLegacy(nil, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Legacy != 11 || report.Migrated != 10 || report.Invalid != 1 {
+		t.Fatalf("unexpected dry run report %+v", report)
+	}
+	if n, _ := ldb.Legacy(); n != 11 {
+		t.Fatalf("expected the dry run to keep 11 legacy entries, got %d", n)
+	}
+
+	var calls int
+	report, err = ldb.MigrateLegacy(nil, false, func(done, total int) {
+		calls++
+		if done != calls || total != 11 {
+			t.Fatalf("unexpected progress %d/%d", done, total)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 10 || calls != 11 {
+		t.Fatalf("unexpected report %+v after %d progress calls", report, calls)
+	}
+	if n, _ := ldb.Legacy(); n != 0 {
+		t.Fatalf("expected no legacy entries, got %d", n)
+	}
+	r, err := ldb.Verify(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Consistent() || r.Counted.Entries != 10 {
+		t.Fatalf("expected consistent counters of 10 chunks, got %+v", r)
+	}
+	for _, chunk := range chunks {
+		got, err := ldb.Get(chunk.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.SData, chunk.SData) {
+			t.Fatalf("unexpected data of chunk %v", chunk.Addr)
+		}
+	}
+}
+
+// TestMigrateLegacyToNewStore tests that the chunks of a legacy chunk store
+// are migrated into a new store without changing the legacy one
+func TestMigrateLegacyToNewStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chunks := GenerateRandomChunks(DefaultChunkSize, 10)
+	params := newLegacyTestStore(t, filepath.Join(dir, "legacy"), chunks)
+	params.ReadOnly = true
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	dst, err := NewLDBStore(NewLDBStoreParams(NewDefaultStoreParams(), filepath.Join(dir, "new")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	report, err := ldb.MigrateLegacy(dst, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 10 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if n, _ := ldb.Legacy(); n != 11 {
+		t.Fatalf("expected the legacy store to be unchanged, got %d legacy entries", n)
+	}
+	for _, chunk := range chunks {
+		if _, err := dst.Get(chunk.Addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+)
+
+// Local returns a FileStore which only retrieves chunks from the local store,
+// chunks missing locally are not requested from the network. The NetStore
+// is found under the middlewares the chunk store is decorated with.
+func (f *FileStore) Local() *FileStore {
+	if ns := f.netStore(); ns != nil {
+		return &FileStore{
+			ChunkStore: ns.localStore,
+			hashFunc:   f.hashFunc,
+		}
+	}
+	return f
+}
+
+// netStore returns the NetStore under the middlewares the chunk store is
+// decorated with, nil if the chunk store is not a NetStore
+func (f *FileStore) netStore() *NetStore {
+	store := f.ChunkStore
+	for {
+		if ns, ok := store.(*NetStore); ok {
+			return ns
+		}
+		w, ok := store.(wrapper)
+		if !ok {
+			return nil
+		}
+		store = w.Unwrap()
+	}
+}
+
+// MissingChunks walks the chunk tree of the data with the given reference
+// resolving the chunks only locally and returns the addresses of the chunks
+// which are not available, the subtrees of missing intermediate chunks are
+// not walked and chunks referenced more than once are listed once
+func (f *FileStore) MissingChunks(ctx context.Context, ref Address) ([]Address, error) {
+	var missing []Address
+	err := f.walkLocal(ctx, ref, func(addr Address, err error) error {
+		if err != nil {
+			missing = append(missing, addr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// walkLocal walks the chunk tree of the data with the given reference
+// resolving the chunks only locally and calls visit once for every chunk
+// referenced, with the error of the retrieval if the chunk is not available.
+// The subtrees of missing chunks are not walked, neither are the chunks of
+// zeros which are not stored. The walk stops at the first error of visit.
+func (f *FileStore) walkLocal(ctx context.Context, ref Address, visit func(addr Address, err error) error) error {
+	local := f.Local()
+	hashSize := local.hashFunc().Size()
+	h := NewHasherStore(local.ChunkStore, local.hashFunc, len(ref) > hashSize)
+
+	seen := make(map[string]bool)
+	var walk func(ref Reference) error
+	walk = func(ref Reference) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if seen[string(ref)] || isZeroReference(ref) {
+			return nil
+		}
+		seen[string(ref)] = true
+		data, err := h.Get(ref)
+		if err == nil && len(data) < 8 {
+			err = ErrChunkInvalid
+		}
+		if err != nil {
+			return visit(Address(ref[:hashSize]), err)
+		}
+		if err := visit(Address(ref[:hashSize]), nil); err != nil {
+			return err
+		}
+		payload := data[8:]
+		if data.Size() <= int64(len(payload)) {
+			return nil
+		}
+		for i := int64(0); i+h.refSize <= int64(len(payload)); i += h.refSize {
+			if err := walk(Reference(payload[i : i+h.refSize])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(Reference(ref))
+}
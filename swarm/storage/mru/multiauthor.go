@@ -0,0 +1,425 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mru
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/ens"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+const (
+	// maxAuthors is the maximum number of authors of a resource
+	maxAuthors = 64
+	// stampLength is the length of the merge stamp prefixed to the data of
+	// multi-author resource updates
+	stampLength = 8
+)
+
+// MergeRule decides which of the latest updates of the authors of a
+// multi-author resource is the current one
+//
+// Every author publishes updates on its own chain of update chunks, keyed
+// by hash(period|version|namehash|author). An update carries a stamp which
+// is compared by the merge rule, the update with the highest stamp wins and
+// ties are broken by the lowest author address
+type MergeRule uint8
+
+const (
+	// MergeLastWriter stamps updates with the time of the update
+	MergeLastWriter MergeRule = iota
+	// MergeSequence stamps updates with the sequence number following the
+	// one of the current update, authors have to look up the latest update
+	// before updating
+	MergeSequence
+)
+
+// ParseMergeRule returns the merge rule with the given name
+func ParseMergeRule(s string) (MergeRule, error) {
+	switch s {
+	case "", "lww", "last-writer":
+		return MergeLastWriter, nil
+	case "seq", "sequence":
+		return MergeSequence, nil
+	}
+	return 0, NewError(ErrInvalidValue, fmt.Sprintf("Unknown merge rule '%s'", s))
+}
+
+func (m MergeRule) String() string {
+	switch m {
+	case MergeLastWriter:
+		return "last-writer"
+	case MergeSequence:
+		return "sequence"
+	}
+	return fmt.Sprintf("MergeRule(%d)", uint8(m))
+}
+
+// authorUpdate is the latest update of an author of a multi-author resource
+type authorUpdate struct {
+	author common.Address
+	chunk  *storage.Chunk
+	stamp  uint64
+	data   []byte
+	period uint32
+	vers   uint32
+}
+
+// wins reports whether the update supersedes the other one
+func (u *authorUpdate) wins(other *authorUpdate) bool {
+	if u.stamp != other.stamp {
+		return u.stamp > other.stamp
+	}
+	return bytes.Compare(u.author[:], other.author[:]) < 0
+}
+
+// Authors returns the addresses of the keys authorized to update the
+// resource, nil if the resource is updated by the owner of its name
+func (r *resource) Authors() []common.Address {
+	return r.authors
+}
+
+// LastAuthor returns the author of the current update of a multi-author
+// resource
+func (r *resource) LastAuthor() common.Address {
+	return r.lastAuthor
+}
+
+func (r *resource) isMultiAuthor() bool {
+	return len(r.authors) > 0
+}
+
+func (r *resource) isAuthor(addr common.Address) bool {
+	for _, a := range r.authors {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalAuthors encodes the author section of the metadata chunk, it
+// follows the name after a zero byte, which is not valid in a safe name:
+// 0x00|mergerule|author...
+func (r *resource) marshalAuthors() []byte {
+	if !r.isMultiAuthor() {
+		return nil
+	}
+	b := make([]byte, 2+len(r.authors)*common.AddressLength)
+	b[1] = byte(r.merge)
+	for i, a := range r.authors {
+		copy(b[2+i*common.AddressLength:], a[:])
+	}
+	return b
+}
+
+func (r *resource) unmarshalAuthors(b []byte) error {
+	if len(b) < 2+common.AddressLength || (len(b)-2)%common.AddressLength != 0 {
+		return NewError(ErrCorruptData, fmt.Sprintf("Invalid author section length %d", len(b)))
+	}
+	r.merge = MergeRule(b[1])
+	if r.merge != MergeLastWriter && r.merge != MergeSequence {
+		return NewError(ErrCorruptData, fmt.Sprintf("Unknown merge rule %d", b[1]))
+	}
+	r.authors = make([]common.Address, (len(b)-2)/common.AddressLength)
+	for i := range r.authors {
+		copy(r.authors[i][:], b[2+i*common.AddressLength:])
+	}
+	return nil
+}
+
+// NewMultiAuthor creates a new root entry for a mutable resource which can
+// be updated by any of the given authors, the current update is chosen by
+// the merge rule
+//
+// If a signer is set it has to have access to the name, as in New
+func (h *Handler) NewMultiAuthor(ctx context.Context, name string, frequency uint64, authors []common.Address, merge MergeRule) (storage.Address, *resource, error) {
+	if frequency == 0 {
+		return nil, nil, NewError(ErrInvalidValue, "Frequency cannot be 0")
+	}
+	if !isSafeName(name) {
+		return nil, nil, NewError(ErrInvalidValue, fmt.Sprintf("Invalid name: '%s'", name))
+	}
+	if len(authors) == 0 || len(authors) > maxAuthors {
+		return nil, nil, NewError(ErrInvalidValue, fmt.Sprintf("Number of authors must be between 1 and %d", maxAuthors))
+	}
+	if merge != MergeLastWriter && merge != MergeSequence {
+		return nil, nil, NewError(ErrInvalidValue, fmt.Sprintf("Unknown merge rule %d", merge))
+	}
+
+	// the authors are sorted so that the same set yields the same metadata chunk
+	sorted := make([]common.Address, len(authors))
+	copy(sorted, authors)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	for i, a := range sorted {
+		if a == (common.Address{}) {
+			return nil, nil, NewError(ErrInvalidValue, "Zero author address")
+		}
+		if i > 0 && a == sorted[i-1] {
+			return nil, nil, NewError(ErrInvalidValue, fmt.Sprintf("Duplicate author %x", a))
+		}
+	}
+
+	nameHash := ens.EnsNode(name)
+	if h.signer != nil {
+		addr, err := h.signerAddress(nameHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		ok, err := h.checkAccess(name, addr)
+		if err != nil {
+			return nil, nil, err
+		} else if !ok {
+			return nil, nil, NewError(ErrUnauthorized, fmt.Sprintf("Not owner of '%s'", name))
+		}
+	}
+
+	currentblock, err := h.getBlock(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsrc := &resource{
+		startBlock: currentblock,
+		frequency:  frequency,
+		name:       name,
+		nameHash:   nameHash,
+		authors:    sorted,
+		merge:      merge,
+		updated:    time.Now(),
+	}
+	chunk, err := h.newResourceMetaChunk(rsrc)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.chunkStore.Put(chunk)
+	log.Debug("new multi-author resource", "name", name, "key", nameHash, "startBlock", currentblock, "frequency", frequency, "authors", len(sorted), "merge", merge)
+
+	h.set(nameHash.Hex(), rsrc)
+	return chunk.Addr, rsrc, nil
+}
+
+// newResourceMetaChunk creates the metadata chunk of a resource:
+// (0x0000|startblock|frequency|identifier[|authors])
+func (h *Handler) newResourceMetaChunk(rsrc *resource) (*storage.Chunk, error) {
+	meta, err := rsrc.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 2+len(meta))
+	copy(data[2:], meta)
+	if len(data) > chunkSize {
+		return nil, NewError(ErrDataOverflow, fmt.Sprintf("Metadata overflow: %d / %d bytes", len(data), chunkSize))
+	}
+
+	hasher := h.hashPool.Get().(storage.SwarmHash)
+	hasher.Reset()
+	hasher.Write(data)
+	key := hasher.Sum(nil)
+	h.hashPool.Put(hasher)
+
+	chunk := storage.NewChunk(key, nil)
+	chunk.SData = data
+	return chunk, nil
+}
+
+// signerAddress returns the address of the key of the signer
+func (h *Handler) signerAddress(digest common.Hash) (common.Address, error) {
+	signature, err := h.signer.Sign(digest)
+	if err != nil {
+		return common.Address{}, NewError(ErrInvalidSignature, fmt.Sprintf("Sign fail: %v", err))
+	}
+	addr, err := getAddressFromDataSig(digest, signature)
+	if err != nil {
+		return common.Address{}, NewError(ErrInvalidSignature, fmt.Sprintf("Retrieve address from signature fail: %v", err))
+	}
+	return addr, nil
+}
+
+// used for the update chunk keys of multi-author resources
+func (h *Handler) authorResourceHash(period uint32, version uint32, namehash common.Hash, author common.Address) storage.Address {
+	// format is: hash(period|version|namehash|author)
+	hasher := h.hashPool.Get().(storage.SwarmHash)
+	defer h.hashPool.Put(hasher)
+	hasher.Reset()
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, period)
+	hasher.Write(b)
+	binary.LittleEndian.PutUint32(b, version)
+	hasher.Write(b)
+	hasher.Write(namehash[:])
+	hasher.Write(author[:])
+	return hasher.Sum(nil)
+}
+
+// lookupMultiAuthor looks up the latest update of every author at the
+// given period and merges them
+func (h *Handler) lookupMultiAuthor(rsrc *resource, period uint32, maxLookup *LookupParams) (*resource, error) {
+	var self common.Address
+	if h.signer != nil {
+		addr, err := h.signerAddress(rsrc.nameHash)
+		if err != nil {
+			return nil, err
+		}
+		self = addr
+	}
+
+	var best *authorUpdate
+	for _, author := range rsrc.authors {
+		author := author
+		chunk, err := h.lookupChunk(period, 0, maxLookup, func(period, version uint32) storage.Address {
+			return h.authorResourceHash(period, version, rsrc.nameHash, author)
+		})
+		if err != nil {
+			if rerr, ok := err.(*Error); ok && rerr.Code() == ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+		u, err := h.parseAuthorUpdate(rsrc, chunk)
+		if err != nil {
+			log.Warn("invalid multi-author resource update", "name", rsrc.name, "author", author, "err", err)
+			continue
+		}
+		if author == self {
+			rsrc.ownPeriod, rsrc.ownVersion = u.period, u.vers
+		}
+		if best == nil || u.wins(best) {
+			best = u
+		}
+	}
+	if best == nil {
+		return nil, NewError(ErrNotFound, "no updates found")
+	}
+	if _, err := h.updateIndex(rsrc, best.chunk); err != nil {
+		return nil, err
+	}
+	rsrc.lastAuthor = best.author
+	rsrc.lastStamp = best.stamp
+	return rsrc, nil
+}
+
+// parseAuthorUpdate parses an update chunk of a multi-author resource and
+// checks that it is signed by the author whose chain it belongs to
+func (h *Handler) parseAuthorUpdate(rsrc *resource, chunk *storage.Chunk) (*authorUpdate, error) {
+	_, period, version, name, data, _, err := h.parseUpdate(chunk.SData)
+	if err != nil {
+		return nil, err
+	}
+	if name != rsrc.name {
+		return nil, NewError(ErrNothingToReturn, fmt.Sprintf("Update belongs to '%s', but have '%s'", name, rsrc.name))
+	}
+	author, ok := h.authorOf(chunk.Addr, chunk.SData, period, version, name, data)
+	if !ok || !rsrc.isAuthor(author) {
+		return nil, NewError(ErrUnauthorized, fmt.Sprintf("Address %x is not an author of %s", author, rsrc.name))
+	}
+	if len(data) <= stampLength {
+		return nil, NewError(ErrCorruptData, "Multi-author resource update has no data")
+	}
+	return &authorUpdate{
+		author: author,
+		chunk:  chunk,
+		stamp:  binary.LittleEndian.Uint64(data[:stampLength]),
+		data:   data[stampLength:],
+		period: period,
+		vers:   version,
+	}, nil
+}
+
+// authorOf recovers the author of an update chunk of a multi-author
+// resource and reports whether the chunk is on the update chain of the
+// author
+//
+// The signature is read from the end of the chunk data since updates of
+// multi-author resources are always signed, regardless of whether the
+// handler has a signer
+func (h *Handler) authorOf(addr storage.Address, chunkdata []byte, period uint32, version uint32, name string, data []byte) (common.Address, bool) {
+	if len(chunkdata) < 12+len(name)+len(data)+signatureLength {
+		return common.Address{}, false
+	}
+	var signature Signature
+	copy(signature[:], chunkdata[len(chunkdata)-signatureLength:])
+	author, err := getAddressFromDataSig(h.keyDataHash(addr, data), signature)
+	if err != nil {
+		return common.Address{}, false
+	}
+	return author, bytes.Equal(h.authorResourceHash(period, version, ens.EnsNode(name), author), addr)
+}
+
+// updateMultiAuthor creates an update of a multi-author resource on the
+// update chain of the signer
+func (h *Handler) updateMultiAuthor(rsrc *resource, period uint32, data []byte, multihash bool) (storage.Address, error) {
+	if multihash {
+		return nil, NewError(ErrInvalidValue, "Multihash updates are not supported by multi-author resources")
+	}
+	if h.signer == nil {
+		return nil, NewError(ErrInvalidSignature, "Multi-author resource updates must be signed")
+	}
+	author, err := h.signerAddress(rsrc.nameHash)
+	if err != nil {
+		return nil, err
+	}
+	if !rsrc.isAuthor(author) {
+		return nil, NewError(ErrUnauthorized, fmt.Sprintf("Address %x is not an author of %s", author, rsrc.name))
+	}
+
+	var stamp uint64
+	switch rsrc.merge {
+	case MergeSequence:
+		stamp = rsrc.lastStamp + 1
+	default:
+		stamp = uint64(time.Now().UnixNano())
+	}
+	payload := make([]byte, stampLength+len(data))
+	binary.LittleEndian.PutUint64(payload, stamp)
+	copy(payload[stampLength:], data)
+
+	// versions count the updates of the author in the period
+	var version uint32
+	if rsrc.ownPeriod == period {
+		version = rsrc.ownVersion
+	}
+	version++
+
+	key := h.authorResourceHash(period, version, rsrc.nameHash, author)
+	signature, err := h.signer.Sign(h.keyDataHash(key, payload))
+	if err != nil {
+		return nil, NewError(ErrInvalidSignature, fmt.Sprintf("Sign fail: %v", err))
+	}
+	chunk := newUpdateChunk(key, &signature, period, version, rsrc.name, payload, len(payload))
+	h.chunkStore.Put(chunk)
+	log.Trace("multi-author resource update", "name", rsrc.name, "key", key, "author", author, "period", period, "version", version, "stamp", stamp)
+
+	rsrc.ownPeriod = period
+	rsrc.ownVersion = version
+	rsrc.lastPeriod = period
+	rsrc.version = version
+	rsrc.lastAuthor = author
+	rsrc.lastStamp = stamp
+	rsrc.data = make([]byte, len(data))
+	copy(rsrc.data, data)
+	return key, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mru
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// newAuthorHandler returns a handler signing with the given signer which
+// shares the chunk store of rh
+func newAuthorHandler(t *testing.T, rh *Handler, backend headerGetter, signer Signer, rootKey storage.Address) *Handler {
+	h, err := NewHandler(&HandlerParams{
+		Signer:       signer,
+		HeaderGetter: backend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetStore(rh.chunkStore)
+	if _, err := h.Load(rootKey); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func signerAddr(s *GenericSigner) common.Address {
+	return crypto.PubkeyToAddress(s.PrivKey.PublicKey)
+}
+
+func lookupLatest(t *testing.T, h *Handler, name string) *resource {
+	rsrc, err := h.LookupLatestByName(context.Background(), name, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsrc
+}
+
+func expectErrCode(t *testing.T, err error, code int) {
+	if err == nil {
+		t.Fatalf("expected error code %d, got no error", code)
+	}
+	if rerr, ok := err.(*Error); !ok || rerr.Code() != code {
+		t.Fatalf("expected error code %d, got %v", code, err)
+	}
+}
+
+func TestMultiAuthorLastWriter(t *testing.T) {
+	signerA, _ := newTestSigner()
+	signerB, _ := newTestSigner()
+	signerC, _ := newTestSigner()
+	backend := &fakeBackend{
+		blocknumber: int64(startBlock),
+	}
+	rhA, _, teardownTest, err := setupTest(backend, nil, signerA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+
+	ctx := context.Background()
+	authors := []common.Address{signerAddr(signerA), signerAddr(signerB)}
+	rootKey, _, err := rhA.NewMultiAuthor(ctx, safeName, resourceFrequency, authors, MergeLastWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rhA.Update(ctx, safeName, []byte("alpha")); err != nil {
+		t.Fatal(err)
+	}
+
+	rhB := newAuthorHandler(t, rhA, backend, signerB, rootKey)
+	rsrc := lookupLatest(t, rhB, safeName)
+	if !bytes.Equal(rsrc.data, []byte("alpha")) || rsrc.LastAuthor() != authors[0] {
+		t.Fatalf("expected 'alpha' by %x, got '%s' by %x", authors[0], rsrc.data, rsrc.LastAuthor())
+	}
+	if _, err := rhB.Update(ctx, safeName, []byte("beta")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the later update wins
+	rsrc = lookupLatest(t, rhA, safeName)
+	if !bytes.Equal(rsrc.data, []byte("beta")) || rsrc.LastAuthor() != authors[1] {
+		t.Fatalf("expected 'beta' by %x, got '%s' by %x", authors[1], rsrc.data, rsrc.LastAuthor())
+	}
+
+	// keys outside of the author set cannot update
+	rhC := newAuthorHandler(t, rhA, backend, signerC, rootKey)
+	lookupLatest(t, rhC, safeName)
+	_, err = rhC.Update(ctx, safeName, []byte("gamma"))
+	expectErrCode(t, err, ErrUnauthorized)
+
+	// multihash updates and previous lookups are not supported
+	_, err = rhA.UpdateMultihash(ctx, safeName, append([]byte{0x1b, 0x20}, make([]byte, 32)...))
+	expectErrCode(t, err, ErrInvalidValue)
+	_, err = rhA.LookupPreviousByName(ctx, safeName, nil)
+	expectErrCode(t, err, ErrInvalidValue)
+}
+
+func TestMultiAuthorSequence(t *testing.T) {
+	signerA, _ := newTestSigner()
+	signerB, _ := newTestSigner()
+	backend := &fakeBackend{
+		blocknumber: int64(startBlock),
+	}
+	rhA, _, teardownTest, err := setupTest(backend, nil, signerA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+
+	ctx := context.Background()
+	addrA, addrB := signerAddr(signerA), signerAddr(signerB)
+	rootKey, _, err := rhA.NewMultiAuthor(ctx, safeName, resourceFrequency, []common.Address{addrB, addrA}, MergeSequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rhA.Update(ctx, safeName, []byte("a1")); err != nil {
+		t.Fatal(err)
+	}
+	rhB := newAuthorHandler(t, rhA, backend, signerB, rootKey)
+	lookupLatest(t, rhB, safeName)
+
+	// both authors update on top of the same update, the lowest address wins
+	if _, err := rhA.Update(ctx, safeName, []byte("a2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rhB.Update(ctx, safeName, []byte("b2")); err != nil {
+		t.Fatal(err)
+	}
+	expected, author := "a2", addrA
+	if bytes.Compare(addrB[:], addrA[:]) < 0 {
+		expected, author = "b2", addrB
+	}
+	rsrc := lookupLatest(t, rhA, safeName)
+	if string(rsrc.data) != expected || rsrc.LastAuthor() != author {
+		t.Fatalf("expected '%s' by %x, got '%s' by %x", expected, author, rsrc.data, rsrc.LastAuthor())
+	}
+
+	// an update on top of the merged one wins
+	lookupLatest(t, rhB, safeName)
+	if _, err := rhB.Update(ctx, safeName, []byte("b3")); err != nil {
+		t.Fatal(err)
+	}
+	rsrc = lookupLatest(t, rhA, safeName)
+	if string(rsrc.data) != "b3" || rsrc.LastAuthor() != addrB {
+		t.Fatalf("expected 'b3' by %x, got '%s' by %x", addrB, rsrc.data, rsrc.LastAuthor())
+	}
+
+	// the author set and merge rule are read from the metadata chunk
+	rhFresh := newAuthorHandler(t, rhA, backend, nil, rootKey)
+	rsrc = rhFresh.get(rsrc.nameHash.Hex())
+	if rsrc.merge != MergeSequence || len(rsrc.Authors()) != 2 {
+		t.Fatalf("expected sequence merge of 2 authors, got %v of %v", rsrc.merge, rsrc.Authors())
+	}
+	if bytes.Compare(rsrc.Authors()[0][:], rsrc.Authors()[1][:]) >= 0 {
+		t.Fatalf("expected sorted authors, got %v", rsrc.Authors())
+	}
+	rsrc = lookupLatest(t, rhFresh, safeName)
+	if string(rsrc.data) != "b3" {
+		t.Fatalf("expected 'b3', got '%s'", rsrc.data)
+	}
+}
+
+func TestMultiAuthorInvalid(t *testing.T) {
+	backend := &fakeBackend{
+		blocknumber: int64(startBlock),
+	}
+	rh, _, teardownTest, err := setupTest(backend, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+
+	ctx := context.Background()
+	addr := common.HexToAddress("0x01")
+	for _, authors := range [][]common.Address{
+		nil,
+		{{}},
+		{addr, addr},
+	} {
+		_, _, err := rh.NewMultiAuthor(ctx, safeName, resourceFrequency, authors, MergeLastWriter)
+		expectErrCode(t, err, ErrInvalidValue)
+	}
+	if _, err := ParseMergeRule("bogus"); err == nil {
+		t.Fatal("expected error parsing unknown merge rule")
+	}
+}
@@ -107,6 +107,7 @@ type Signature [signatureLength]byte
 type LookupParams struct {
 	Limit bool
 	Max   uint32
+	Hint  uint32 // period of an update known to the caller, lookups of the latest update never walk back past it
 }
 
 // Encapsulates an specific resource update. When synced it contains the most recent
@@ -123,6 +124,12 @@ type resource struct {
 	version    uint32
 	data       []byte
 	updated    time.Time
+	authors    []common.Address
+	merge      MergeRule
+	ownPeriod  uint32
+	ownVersion uint32
+	lastAuthor common.Address
+	lastStamp  uint64
 }
 
 // TODO Expire content after a defined period (to force resync)
@@ -146,17 +153,27 @@ func (r *resource) Name() string {
 }
 
 func (r *resource) UnmarshalBinary(data []byte) error {
+	if len(data) <= 16 {
+		return NewError(ErrCorruptData, fmt.Sprintf("Invalid metadata length %d", len(data)))
+	}
 	r.startBlock = binary.LittleEndian.Uint64(data[:8])
 	r.frequency = binary.LittleEndian.Uint64(data[8:16])
+	// the author section of multi-author resources follows a zero byte
+	if i := bytes.IndexByte(data[16:], 0); i >= 0 {
+		r.name = string(data[16 : 16+i])
+		return r.unmarshalAuthors(data[16+i:])
+	}
 	r.name = string(data[16:])
 	return nil
 }
 
 func (r *resource) MarshalBinary() ([]byte, error) {
-	b := make([]byte, 16+len(r.name))
+	authors := r.marshalAuthors()
+	b := make([]byte, 16+len(r.name)+len(authors))
 	binary.LittleEndian.PutUint64(b, r.startBlock)
 	binary.LittleEndian.PutUint64(b[8:], r.frequency)
 	copy(b[16:], []byte(r.name))
+	copy(b[16+len(r.name):], authors)
 	return b, nil
 }
 
@@ -188,6 +205,11 @@ type ownerValidator interface {
 //
 // (0x0000|startblock|frequency|identifier)
 //
+// Resources updatable by a set of authors append the merge rule and the
+// author addresses after a zero byte (see NewMultiAuthor):
+//
+// (0x0000|startblock|frequency|identifier|0x00|mergerule|author...)
+//
 // (The two first zero-value bytes are used for disambiguation by the chunk validator,
 // and update chunk will always have a value > 0 there.)
 //
@@ -296,7 +318,11 @@ func (h *Handler) Validate(addr storage.Address, data []byte) bool {
 		log.Error("Invalid resource chunk")
 		return false
 	} else if signature == nil {
-		return bytes.Equal(h.resourceHash(period, version, ens.EnsNode(name)), addr)
+		if bytes.Equal(h.resourceHash(period, version, ens.EnsNode(name)), addr) {
+			return true
+		}
+		_, ok := h.authorOf(addr, data, period, version, name, parseddata)
+		return ok
 	}
 
 	digest := h.keyDataHash(addr, parseddata)
@@ -305,6 +331,11 @@ func (h *Handler) Validate(addr storage.Address, data []byte) bool {
 		log.Error("Invalid signature on resource chunk")
 		return false
 	}
+	// updates of multi-author resources are on the chain of the signer, the
+	// author set is checked on lookup
+	if bytes.Equal(h.authorResourceHash(period, version, ens.EnsNode(name), addrSig), addr) {
+		return true
+	}
 	ok, _ := h.checkAccess(name, addrSig)
 	return ok
 }
@@ -527,6 +558,12 @@ func (h *Handler) LookupLatest(ctx context.Context, nameHash common.Hash, refres
 	if err != nil {
 		return nil, err
 	}
+	if maxLookup != nil && maxLookup.Hint > 0 && maxLookup.Hint <= nextperiod {
+		maxLookup = &LookupParams{
+			Limit: true,
+			Max:   nextperiod - maxLookup.Hint,
+		}
+	}
 	return h.lookup(rsrc, nextperiod, 0, refresh, maxLookup)
 }
 
@@ -547,6 +584,8 @@ func (h *Handler) LookupPrevious(ctx context.Context, nameHash common.Hash, maxL
 	}
 	if !rsrc.isSynced() {
 		return nil, NewError(ErrNotSynced, "LookupPrevious requires synced resource.")
+	} else if rsrc.isMultiAuthor() {
+		return nil, NewError(ErrInvalidValue, "LookupPrevious is not supported by multi-author resources")
 	} else if rsrc.lastPeriod == 0 {
 		return nil, NewError(ErrNothingToReturn, " not found")
 	}
@@ -574,38 +613,54 @@ func (h *Handler) lookup(rsrc *resource, period uint32, version uint32, refresh
 		return nil, NewError(ErrInvalidValue, "period must be >0")
 	}
 
+	if maxLookup == nil {
+		maxLookup = h.queryMaxPeriods
+	}
+	log.Trace("resource lookup", "period", period, "version", version, "limit", maxLookup.Limit, "max", maxLookup.Max)
+	if rsrc.isMultiAuthor() {
+		if version > 0 {
+			return nil, NewError(ErrInvalidValue, "Multi-author resources cannot be looked up by version")
+		}
+		return h.lookupMultiAuthor(rsrc, period, maxLookup)
+	}
+	chunk, err := h.lookupChunk(period, version, maxLookup, func(period, version uint32) storage.Address {
+		return h.resourceHash(period, version, rsrc.nameHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.updateIndex(rsrc, chunk)
+}
+
+// lookupChunk retrieves the update chunk of the given version at the given
+// period, or the latest version at the latest period up to the given one
+// if version is 0, keys are calculated by keyFunc
+func (h *Handler) lookupChunk(period uint32, version uint32, maxLookup *LookupParams, keyFunc func(period, version uint32) storage.Address) (*storage.Chunk, error) {
 	// start from the last possible block period, and iterate previous ones until we find a match
 	// if we hit startBlock we're out of options
-	var specificversion bool
-	if version > 0 {
-		specificversion = true
-	} else {
+	specificversion := version > 0
+	if !specificversion {
 		version = 1
 	}
-
 	var hops uint32
-	if maxLookup == nil {
-		maxLookup = h.queryMaxPeriods
-	}
-	log.Trace("resource lookup", "period", period, "version", version, "limit", maxLookup.Limit, "max", maxLookup.Max)
 	for period > 0 {
 		if maxLookup.Limit && hops > maxLookup.Max {
 			return nil, NewError(ErrPeriodDepth, fmt.Sprintf("Lookup exceeded max period hops (%d)", maxLookup.Max))
 		}
-		key := h.resourceHash(period, version, rsrc.nameHash)
+		key := keyFunc(period, version)
 		chunk, err := h.chunkStore.GetWithTimeout(key, defaultRetrieveTimeout)
 		if err == nil {
 			if specificversion {
-				return h.updateIndex(rsrc, chunk)
+				return chunk, nil
 			}
 			// check if we have versions > 1. If a version fails, the previous version is used and returned.
 			log.Trace("rsrc update version 1 found, checking for version updates", "period", period, "key", key)
 			for {
 				newversion := version + 1
-				key := h.resourceHash(period, newversion, rsrc.nameHash)
+				key := keyFunc(period, newversion)
 				newchunk, err := h.chunkStore.GetWithTimeout(key, defaultRetrieveTimeout)
 				if err != nil {
-					return h.updateIndex(rsrc, chunk)
+					return chunk, nil
 				}
 				chunk = newchunk
 				version = newversion
@@ -637,7 +692,9 @@ func (h *Handler) Load(addr storage.Address) (*resource, error) {
 
 	// create the index entry
 	rsrc := &resource{}
-	rsrc.UnmarshalBinary(chunk.SData[2:])
+	if err := rsrc.UnmarshalBinary(chunk.SData[2:]); err != nil {
+		return nil, err
+	}
 	rsrc.nameHash = ens.EnsNode(rsrc.name)
 	h.set(rsrc.nameHash.Hex(), rsrc)
 	log.Trace("resource index load", "rootkey", addr, "name", rsrc.name, "namehash", rsrc.nameHash, "startblock", rsrc.startBlock, "frequency", rsrc.frequency)
@@ -664,6 +721,14 @@ func (h *Handler) updateIndex(rsrc *resource, chunk *storage.Chunk) (*resource,
 		}
 	}
 
+	// strip the merge stamp of multi-author resource updates
+	if rsrc.isMultiAuthor() {
+		if len(data) <= stampLength {
+			return nil, NewError(ErrCorruptData, "Multi-author resource update has no data")
+		}
+		data = data[stampLength:]
+	}
+
 	// update our rsrcs entry map
 	rsrc.lastKey = chunk.Addr
 	rsrc.lastPeriod = period
@@ -843,13 +908,17 @@ func (h *Handler) update(ctx context.Context, name string, data []byte, multihas
 		return nil, err
 	}
 
+	// multi-author resources are updated on the update chain of the signer
+	if rsrc.isMultiAuthor() {
+		if int64(len(data)+stampLength) > datalimit {
+			return nil, NewError(ErrDataOverflow, fmt.Sprintf("Data overflow: %d / %d bytes", len(data), datalimit-stampLength))
+		}
+		return h.updateMultiAuthor(rsrc, nextperiod, data, multihash)
+	}
+
 	// if we already have an update for this block then increment version
 	// resource object MUST be in sync for version to be correct, but we checked this earlier in the method already
-	var version uint32
-	if h.hasUpdate(nameHashHex, nextperiod) {
-		version = rsrc.version
-	}
-	version++
+	version := h.nextVersion(rsrc, nextperiod)
 
 	// calculate the chunk key
 	key := h.resourceHash(nextperiod, version, rsrc.nameHash)
@@ -865,20 +934,35 @@ func (h *Handler) update(ctx context.Context, name string, data []byte, multihas
 			return nil, NewError(ErrInvalidSignature, fmt.Sprintf("Sign fail: %v", err))
 		}
 		signature = &sig
+	}
+	return h.commitUpdate(rsrc, key, signature, nextperiod, version, data, multihash)
+}
+
+// nextVersion returns the version of the next update of a single-author
+// resource at the given period
+func (h *Handler) nextVersion(rsrc *resource, period uint32) uint32 {
+	var version uint32
+	if h.hasUpdate(rsrc.nameHash.Hex(), period) {
+		version = rsrc.version
+	}
+	return version + 1
+}
 
+// commitUpdate checks the access of the signer of an update of a
+// single-author resource, stores the update chunk and updates the index
+func (h *Handler) commitUpdate(rsrc *resource, key storage.Address, signature *Signature, period uint32, version uint32, data []byte, multihash bool) (storage.Address, error) {
+	if signature != nil {
 		// get the address of the signer (which also checks that it's a valid signature)
-		addr, err := getAddressFromDataSig(digest, *signature)
+		addr, err := getAddressFromDataSig(h.keyDataHash(key, data), *signature)
 		if err != nil {
 			return nil, NewError(ErrInvalidSignature, fmt.Sprintf("Invalid data/signature: %v", err))
 		}
-		if h.signer != nil {
-			// check if the signer has access to update
-			ok, err := h.checkAccess(name, addr)
-			if err != nil {
-				return nil, NewError(ErrIO, fmt.Sprintf("Access check fail: %v", err))
-			} else if !ok {
-				return nil, NewError(ErrUnauthorized, fmt.Sprintf("Address %x does not have access to update %s", addr, name))
-			}
+		// check if the signer has access to update
+		ok, err := h.checkAccess(rsrc.name, addr)
+		if err != nil {
+			return nil, NewError(ErrIO, fmt.Sprintf("Access check fail: %v", err))
+		} else if !ok {
+			return nil, NewError(ErrUnauthorized, fmt.Sprintf("Address %x does not have access to update %s", addr, rsrc.name))
 		}
 	}
 
@@ -887,14 +971,14 @@ func (h *Handler) update(ctx context.Context, name string, data []byte, multihas
 	if !multihash {
 		datalength = len(data)
 	}
-	chunk := newUpdateChunk(key, signature, nextperiod, version, name, data, datalength)
+	chunk := newUpdateChunk(key, signature, period, version, rsrc.name, data, datalength)
 
 	// send the chunk
 	h.chunkStore.Put(chunk)
-	log.Trace("resource update", "name", name, "key", key, "currentblock", currentblock, "lastperiod", nextperiod, "version", version, "data", chunk.SData, "multihash", multihash)
+	log.Trace("resource update", "name", rsrc.name, "key", key, "lastperiod", period, "version", version, "data", chunk.SData, "multihash", multihash)
 
 	// update our resources map entry and return the new key
-	rsrc.lastPeriod = nextperiod
+	rsrc.lastPeriod = period
 	rsrc.version = version
 	rsrc.data = make([]byte, len(data))
 	copy(rsrc.data, data)
@@ -58,8 +58,6 @@ var (
 
 func init() {
 	var err error
-	flag.Parse()
-	log.Root().SetHandler(log.CallerFileHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(os.Stderr, log.TerminalFormat(true)))))
 	safeName, err = ToSafeName(domainName)
 	if err != nil {
 		panic(err)
@@ -67,6 +65,12 @@ func init() {
 	nameHash = ens.EnsNode(safeName)
 }
 
+func TestMain(m *testing.M) {
+	flag.Parse()
+	log.Root().SetHandler(log.CallerFileHandler(log.LvlFilterHandler(log.Lvl(*loglevel), log.StreamHandler(os.Stderr, log.TerminalFormat(true)))))
+	os.Exit(m.Run())
+}
+
 // simulated backend does not have the blocknumber call
 // so we use this wrapper to fake returning the block count
 type fakeBackend struct {
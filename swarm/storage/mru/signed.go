@@ -0,0 +1,176 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mru
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/ens"
+	"github.com/ethereum/go-ethereum/swarm/multihash"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// Updates of single-author resources can be signed by clients holding the
+// key of the resource owner instead of the node. The client gets the period,
+// version and address of the next update from the node (see
+// Handler.NextUpdate), signs the digest of the address and the update data
+// (see UpdateDigest) and hands the signature to the node with the data (see
+// Handler.UpdateSigned).
+
+// UpdateTemplate is the period, version and address of the next update of a
+// single-author resource
+type UpdateTemplate struct {
+	Period  uint32          `json:"period"`
+	Version uint32          `json:"version"`
+	Addr    storage.Address `json:"addr"`
+}
+
+// Info describes a resource and its latest update
+type Info struct {
+	Name       string           `json:"name"`
+	StartBlock uint64           `json:"startBlock"`
+	Frequency  uint64           `json:"frequency"`
+	LastPeriod uint32           `json:"lastPeriod"` // 0 if the resource has no updates
+	Version    uint32           `json:"version"`
+	LastAddr   storage.Address  `json:"lastAddr,omitempty"`
+	Multihash  bool             `json:"multihash"`
+	Authors    []common.Address `json:"authors,omitempty"`
+	Merge      string           `json:"merge,omitempty"`
+	Next       *UpdateTemplate  `json:"next,omitempty"` // next update of a single-author resource
+}
+
+// UpdateDigest returns the digest of a resource update with the given address
+// and data which is signed by the author of the update
+func UpdateDigest(addr storage.Address, data []byte) common.Hash {
+	hasher := storage.MakeHashFunc(resourceHash)()
+	hasher.Reset()
+	hasher.Write(addr[:])
+	hasher.Write(data)
+	return common.BytesToHash(hasher.Sum(nil))
+}
+
+// Info looks up the latest update of the resource with the given metadata
+// chunk address and describes the resource
+func (h *Handler) Info(ctx context.Context, addr storage.Address) (*Info, error) {
+	rsrc, err := h.Load(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.LookupLatest(ctx, rsrc.nameHash, true, nil); err != nil {
+		// a resource without updates is described by its metadata only
+		if rsrcErr, ok := err.(*Error); !ok || rsrcErr.Code() != ErrNotFound {
+			return nil, err
+		}
+	}
+	info := &Info{
+		Name:       rsrc.name,
+		StartBlock: rsrc.startBlock,
+		Frequency:  rsrc.frequency,
+		LastPeriod: rsrc.lastPeriod,
+		Version:    rsrc.version,
+		LastAddr:   rsrc.lastKey,
+		Multihash:  rsrc.Multihash,
+	}
+	if rsrc.isMultiAuthor() {
+		info.Authors = rsrc.Authors()
+		info.Merge = rsrc.merge.String()
+		return info, nil
+	}
+	// the next update of a resource which has no updates and was not created
+	// by this node is unknown
+	if !rsrc.isSynced() {
+		return info, nil
+	}
+	if info.Next, err = h.NextUpdate(ctx, rsrc.name); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// NextUpdate returns the period, version and address of the next update of
+// the single-author resource with the given name
+func (h *Handler) NextUpdate(ctx context.Context, name string) (*UpdateTemplate, error) {
+	rsrc, period, err := h.nextPeriod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	version := h.nextVersion(rsrc, period)
+	return &UpdateTemplate{
+		Period:  period,
+		Version: version,
+		Addr:    h.resourceHash(period, version, rsrc.nameHash),
+	}, nil
+}
+
+// UpdateSigned adds an update of the single-author resource with the given
+// name which was signed by the client, the period and version must be the
+// ones of the next update returned by NextUpdate
+func (h *Handler) UpdateSigned(ctx context.Context, name string, period uint32, version uint32, data []byte, isMultihash bool, signature Signature) (storage.Address, error) {
+	if len(data) == 0 {
+		return nil, NewError(ErrInvalidValue, "data length is 0")
+	}
+	if h.signer == nil {
+		return nil, NewError(ErrInit, "resource updates are not signed")
+	}
+	if isMultihash {
+		if _, _, err := multihash.GetMultihashLength(data); err != nil {
+			return nil, NewError(ErrNothingToReturn, err.Error())
+		}
+	}
+	// an update can be only one chunk long, see update
+	datalimit := h.chunkSize() - int64(signatureLength-len(name)-12)
+	if int64(len(data)) > datalimit {
+		return nil, NewError(ErrDataOverflow, fmt.Sprintf("Data overflow: %d / %d bytes", len(data), datalimit))
+	}
+
+	rsrc, nextperiod, err := h.nextPeriod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if nextversion := h.nextVersion(rsrc, nextperiod); period != nextperiod || version != nextversion {
+		return nil, NewError(ErrInvalidValue, fmt.Sprintf("outdated update: next update is version %d of period %d", nextversion, nextperiod))
+	}
+	key := h.resourceHash(period, version, rsrc.nameHash)
+	return h.commitUpdate(rsrc, key, &signature, period, version, data, isMultihash)
+}
+
+// nextPeriod returns the synced single-author resource with the given name
+// and the period of its next update
+func (h *Handler) nextPeriod(ctx context.Context, name string) (*resource, uint32, error) {
+	if h.chunkStore == nil {
+		return nil, 0, NewError(ErrInit, "Call Handler.SetStore() before updating")
+	}
+	rsrc := h.get(ens.EnsNode(name).Hex())
+	if rsrc == nil {
+		return nil, 0, NewError(ErrNotFound, fmt.Sprintf(" object '%s' not in index", name))
+	} else if !rsrc.isSynced() {
+		return nil, 0, NewError(ErrNotSynced, " object not in sync")
+	} else if rsrc.isMultiAuthor() {
+		return nil, 0, NewError(ErrInvalidValue, "updates of multi-author resources are signed by the node of the author")
+	}
+	currentblock, err := h.getBlock(ctx, name)
+	if err != nil {
+		return nil, 0, NewError(ErrIO, fmt.Sprintf("Could not get block height: %v", err))
+	}
+	period, err := getNextPeriod(rsrc.startBlock, currentblock, rsrc.frequency)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rsrc, period, nil
+}
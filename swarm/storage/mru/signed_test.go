@@ -0,0 +1,115 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mru
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/contracts/ens"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestUpdateSigned tests that updates signed by a client with the key of the
+// owner of a resource are accepted and that others are rejected
+func TestUpdateSigned(t *testing.T) {
+	owner, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactOpts := bind.NewKeyedTransactor(owner.PrivKey)
+	domainparts := strings.Split(safeName, ".")
+	contractAddr, contractbackend, err := setupENS(crypto.PubkeyToAddress(owner.PrivKey.PublicKey), transactOpts, domainparts[0], domainparts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensClient, err := ens.NewENS(transactOpts, contractAddr, contractbackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rh, _, teardownTest, err := setupTest(contractbackend, ensClient, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rootKey, _, err := rh.New(ctx, safeName, resourceFrequency)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client signs with the key of the owner
+	next, err := rh.NextUpdate(ctx, safeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("foo")
+	signature, err := (&GenericSigner{PrivKey: owner.PrivKey}).Sign(UpdateDigest(next.Addr, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := rh.UpdateSigned(ctx, safeName, next.Period, next.Version, data, false, signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(addr, next.Addr) {
+		t.Fatalf("expected update at %v, got %v", next.Addr, addr)
+	}
+	update, err := getUpdateDirect(rh, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(update, data) {
+		t.Fatalf("expected update data %x, got %x", data, update)
+	}
+
+	// the update has been taken
+	if _, err := rh.UpdateSigned(ctx, safeName, next.Period, next.Version, data, false, signature); err == nil {
+		t.Fatal("expected outdated update to fail")
+	}
+
+	info, err := rh.Info(ctx, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LastPeriod != next.Period || info.Version != next.Version {
+		t.Fatalf("expected version %d of period %d, got %+v", next.Version, next.Period, info)
+	}
+	if info.Next == nil || info.Next.Version != next.Version+1 {
+		t.Fatalf("expected next version %d, got %+v", next.Version+1, info.Next)
+	}
+
+	// the client signs with a key which does not own the name
+	other, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next = info.Next
+	signature, err = other.Sign(UpdateDigest(next.Addr, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rh.UpdateSigned(ctx, safeName, next.Period, next.Version, data, false, signature)
+	if rsrcErr, ok := err.(*Error); !ok || rsrcErr.Code() != ErrUnauthorized {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errPatchOutOfRange = errors.New("patch out of range of the content")
+	errPatchRefSize    = errors.New("patch reference size differs from the content")
+)
+
+// treePatcher rewrites the chunks of a tree overlapped by a patch
+type treePatcher struct {
+	putter   Putter
+	getter   Getter
+	refSize  int64
+	branches int64
+}
+
+// TreePatch writes the data at the offset of the content stored at addr, like
+// WriteAt, and returns the address of the patched content. Only the data
+// chunks overlapped by the data and the intermediate chunks leading to them
+// are stored again, the other subtrees are referenced as they are. The patch
+// cannot extend the content, see PyramidAppend.
+func TreePatch(ctx context.Context, addr Address, data []byte, off int64, putter Putter, getter Getter) (Address, func(context.Context) error, error) {
+	refSize := int64(len(addr))
+	if putter.RefSize() != refSize {
+		return nil, nil, errPatchRefSize
+	}
+	root, err := getter.Get(Reference(addr))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(root) < 8 {
+		return nil, nil, &ChunkError{Op: "get", Addr: addr, Err: ErrChunkInvalid}
+	}
+	size := root.Size()
+	if off < 0 || off+int64(len(data)) > size {
+		return nil, nil, errPatchOutOfRange
+	}
+	if len(data) == 0 {
+		putter.Close()
+		return addr, putter.Wait, nil
+	}
+	treeSize, depth, err := treeSizeOf(DefaultChunkSize, DefaultChunkSize/refSize, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &treePatcher{
+		putter:   putter,
+		getter:   getter,
+		refSize:  refSize,
+		branches: DefaultChunkSize / refSize,
+	}
+	ref, err := p.patch(root, data, off, depth, treeSize/p.branches)
+	if err != nil {
+		return nil, nil, err
+	}
+	putter.Close()
+	return Address(ref), putter.Wait, nil
+}
+
+// patch writes the data at the offset of the subtree of the chunk and
+// returns the reference of the rewritten chunk, the level of the chunk is
+// found the same way the LazyChunkReader does
+func (p *treePatcher) patch(chunkData ChunkData, data []byte, off int64, depth int, treeSize int64) (Reference, error) {
+	for chunkData.Size() < treeSize && depth > 0 {
+		treeSize /= p.branches
+		depth--
+	}
+	patched := make(ChunkData, len(chunkData))
+	copy(patched, chunkData)
+
+	// leaf chunk found
+	if depth == 0 {
+		copy(patched[8+off:], data)
+		return p.putter.Put(patched)
+	}
+
+	eoff := off + int64(len(data))
+	end := (eoff + treeSize - 1) / treeSize
+	if branches := int64(len(chunkData)-8) / p.refSize; end > branches {
+		end = branches
+	}
+	for i := off / treeSize; i < end; i++ {
+		soff, seoff := i*treeSize, (i+1)*treeSize
+		if soff < off {
+			soff = off
+		}
+		if seoff > eoff {
+			seoff = eoff
+		}
+		ref := patched[8+i*p.refSize : 8+(i+1)*p.refSize]
+		child, err := p.getter.Get(Reference(ref))
+		if err != nil {
+			if _, ok := err.(*ChunkError); !ok {
+				err = &ChunkError{Op: "get", Addr: Address(ref), Err: err}
+			}
+			return nil, err
+		}
+		if len(child) < 9 {
+			return nil, &ChunkError{Op: "get", Addr: Address(ref), Err: ErrChunkInvalid}
+		}
+		childRef, err := p.patch(child, data[soff-off:seoff-off], soff-i*treeSize, depth-1, treeSize/p.branches)
+		if err != nil {
+			return nil, err
+		}
+		copy(ref, childRef)
+	}
+	return p.putter.Put(patched)
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// TestFileStorePatch tests that patched content is retrieved with the patch
+// applied and that only the chunks overlapped by the patch are stored again
+func TestFileStorePatch(t *testing.T) {
+	size := int(DefaultChunkSize)*130 + 42
+	for _, test := range []struct {
+		off, length int
+		puts        int64 // chunks stored by the unencrypted patch
+	}{
+		{0, 1, 3},
+		{size - 1, 1, 3},
+		{int(DefaultChunkSize) - 10, 20, 4},
+		{int(DefaultChunkSize) * 128, 100, 3},
+		{int(DefaultChunkSize)*128 - 1, 2, 5},
+		{0, size, 134},
+	} {
+		for _, toEncrypt := range []bool{false, true} {
+			store := &countingChunkStore{MapChunkStore: NewMapChunkStore()}
+			fileStore := NewFileStore(store, NewFileStoreParams())
+
+			_, slice := generateRandomData(size)
+			ctx := context.TODO()
+			addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), toEncrypt)
+			if err != nil {
+				t.Fatalf("store error: %v", err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatalf("store wait error: %v", err)
+			}
+
+			atomic.StoreInt64(&store.puts, 0)
+			_, data := generateRandomData(test.length)
+			newAddr, wait, err := fileStore.Patch(ctx, addr, data, int64(test.off))
+			if err != nil {
+				t.Fatalf("offset %d, encrypt %v: patch error: %v", test.off, toEncrypt, err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatalf("offset %d, encrypt %v: patch wait error: %v", test.off, toEncrypt, err)
+			}
+			// encrypted trees have half the branches, so the counts differ
+			if puts := atomic.LoadInt64(&store.puts); !toEncrypt && puts != test.puts {
+				t.Fatalf("offset %d: expected %d chunks stored by the patch, got %d", test.off, test.puts, puts)
+			}
+
+			expected := make([]byte, size)
+			copy(expected, slice)
+			copy(expected[test.off:], data)
+			reader, _ := fileStore.Retrieve(ctx, newAddr)
+			result := make([]byte, size)
+			if _, err := reader.ReadAt(result, 0); err != io.EOF {
+				t.Fatalf("offset %d, encrypt %v: retrieve error: %v", test.off, toEncrypt, err)
+			}
+			if !bytes.Equal(expected, result) {
+				t.Fatalf("offset %d, encrypt %v: retrieved content differs", test.off, toEncrypt)
+			}
+
+			if _, _, err := fileStore.Patch(ctx, addr, data, int64(size-test.length+1)); err != errPatchOutOfRange {
+				t.Fatalf("offset %d, encrypt %v: expected out of range error, got %v", test.off, toEncrypt, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errNotPinned      = errors.New("chunk is not pinned")
+	errPinUnsupported = errors.New("chunk store does not support pinning")
+)
+
+// Pinner is a chunk store whose chunks can be protected from garbage
+// collection. Pins are counted, a chunk pinned several times is collected
+// again only after it is unpinned as many times.
+type Pinner interface {
+	Pin(addr Address) error
+	Unpin(addr Address) error
+}
+
+func getPinKey(addr Address) []byte {
+	key := make([]byte, len(addr)+1)
+	key[0] = keyPin
+	copy(key[1:], addr)
+	return key
+}
+
+// Pin protects the stored chunk from garbage collection
+func (s *LDBStore) Pin(addr Address) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// the chunk may still be pending in the batch
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if _, err := s.db.Get(getIndexKey(addr)); err != nil {
+		return ErrChunkNotFound
+	}
+	return s.db.Put(getPinKey(addr), U64ToBytes(s.pinCount(addr)+1))
+}
+
+// Unpin removes a pin of the chunk, the chunk is collected again once it
+// has no pins left
+func (s *LDBStore) Unpin(addr Address) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := s.pinCount(addr)
+	switch count {
+	case 0:
+		return errNotPinned
+	case 1:
+		return s.db.Delete(getPinKey(addr))
+	}
+	return s.db.Put(getPinKey(addr), U64ToBytes(count-1))
+}
+
+// PinCount returns the number of pins of the chunk
+func (s *LDBStore) PinCount(addr Address) uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pinCount(addr)
+}
+
+func (s *LDBStore) pinCount(addr Address) uint64 {
+	data, err := s.db.Get(getPinKey(addr))
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+	return BytesToU64(data)
+}
+
+// pinned returns true if the chunk has pins, must be called with the lock
+// held
+func (s *LDBStore) pinned(addr Address) bool {
+	return s.pinCount(addr) > 0
+}
+
+// Pin protects the chunk from garbage collection, a chunk only found in the
+// forwarding cache is moved to the chunk store first
+func (ls *LocalStore) Pin(addr Address) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.cache != nil {
+		if _, err := ls.DbStore.Get(addr); err == ErrChunkNotFound {
+			cached, err := ls.cache.Get(addr)
+			if err != nil {
+				return err
+			}
+			chunk := NewChunk(addr, nil)
+			chunk.SData = cached.SData
+			chunk.Size = cached.Size
+			ls.DbStore.Put(chunk)
+			if err := chunk.WaitToStore(); err != nil {
+				return err
+			}
+		}
+	}
+	return ls.DbStore.Pin(addr)
+}
+
+// Unpin removes a pin of the chunk
+func (ls *LocalStore) Unpin(addr Address) error {
+	return ls.DbStore.Unpin(addr)
+}
+
+// Pin protects all chunks of the data with the given reference from garbage
+// collection of the local store. The chunks must be available locally, the
+// pins already made are removed if one is missing. A chunk referenced more
+// than once by the data is pinned once.
+func (f *FileStore) Pin(ctx context.Context, ref Address) error {
+	pinner, ok := f.Local().ChunkStore.(Pinner)
+	if !ok {
+		return errPinUnsupported
+	}
+	var pinned []Address
+	err := f.walkLocal(ctx, ref, func(addr Address, err error) error {
+		if err != nil {
+			return &ChunkError{Op: "pin", Addr: addr, Err: err}
+		}
+		if err := pinner.Pin(addr); err != nil {
+			return &ChunkError{Op: "pin", Addr: addr, Err: err}
+		}
+		pinned = append(pinned, addr)
+		return nil
+	})
+	if err != nil {
+		for _, addr := range pinned {
+			pinner.Unpin(addr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unpin removes the pins of the chunks of the data made by Pin
+func (f *FileStore) Unpin(ctx context.Context, ref Address) error {
+	pinner, ok := f.Local().ChunkStore.(Pinner)
+	if !ok {
+		return errPinUnsupported
+	}
+	return f.walkLocal(ctx, ref, func(addr Address, err error) error {
+		if err == nil {
+			err = pinner.Unpin(addr)
+		}
+		if err != nil {
+			return &ChunkError{Op: "unpin", Addr: addr, Err: err}
+		}
+		return nil
+	})
+}
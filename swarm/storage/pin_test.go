@@ -0,0 +1,152 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// TestLDBStorePin tests that garbage collection skips pinned chunks until
+// they are unpinned as many times as they were pinned
+func TestLDBStorePin(t *testing.T) {
+	ldb, cleanup := newLDBStore(t)
+	defer cleanup()
+
+	n := 100
+	var chunks []*Chunk
+	for i := 0; i < n; i++ {
+		chunk := GenerateRandomChunk(DefaultChunkSize)
+		ldb.Put(chunk)
+		chunks = append(chunks, chunk)
+	}
+	for _, chunk := range chunks {
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pinned := chunks[:10]
+	for _, chunk := range pinned {
+		if err := ldb.Pin(chunk.Addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ldb.Pin(pinned[0].Addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := ldb.Pin(GenerateRandomChunk(DefaultChunkSize).Addr); err != ErrChunkNotFound {
+		t.Fatalf("expected %v pinning a missing chunk, got %v", ErrChunkNotFound, err)
+	}
+
+	ldb.lock.Lock()
+	ldb.collectGarbage(1)
+	ldb.lock.Unlock()
+	if size := ldb.Size(); size != uint64(len(pinned)) {
+		t.Fatalf("expected %d chunks left, got %d", len(pinned), size)
+	}
+	for _, chunk := range pinned {
+		if _, err := ldb.Get(chunk.Addr); err != nil {
+			t.Fatalf("pinned chunk %v: %v", chunk.Addr, err)
+		}
+	}
+
+	for _, chunk := range pinned {
+		if err := ldb.Unpin(chunk.Addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count := ldb.PinCount(pinned[0].Addr); count != 1 {
+		t.Fatalf("expected 1 pin left, got %d", count)
+	}
+	if err := ldb.Unpin(pinned[1].Addr); err != errNotPinned {
+		t.Fatalf("expected %v, got %v", errNotPinned, err)
+	}
+
+	ldb.lock.Lock()
+	ldb.collectGarbage(1)
+	ldb.lock.Unlock()
+	if size := ldb.Size(); size != 1 {
+		t.Fatalf("expected 1 chunk left, got %d", size)
+	}
+	if _, err := ldb.Get(pinned[0].Addr); err != nil {
+		t.Fatalf("pinned chunk %v: %v", pinned[0].Addr, err)
+	}
+}
+
+// TestFileStorePin tests that all chunks of pinned content survive garbage
+// collection and that pinning fails without pins left if content is missing
+func TestFileStorePin(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		ldb, cleanup := newLDBStore(t)
+		fileStore := NewFileStore(ldb, NewFileStoreParams())
+		ctx := context.TODO()
+
+		store := func(size int) (Address, []byte) {
+			_, data := generateRandomData(size)
+			addr, wait, err := fileStore.Store(ctx, bytes.NewReader(data), int64(size), toEncrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+			return addr, data
+		}
+		addr, data := store(int(DefaultChunkSize)*130 + 42)
+		other, _ := store(int(DefaultChunkSize) * 10)
+
+		if err := fileStore.Pin(ctx, addr); err != nil {
+			t.Fatalf("encrypt %v: pin error: %v", toEncrypt, err)
+		}
+		ldb.lock.Lock()
+		ldb.collectGarbage(1)
+		ldb.lock.Unlock()
+
+		reader, _ := fileStore.Retrieve(ctx, addr)
+		result := make([]byte, len(data))
+		if _, err := reader.ReadAt(result, 0); err != io.EOF {
+			t.Fatalf("encrypt %v: retrieve error: %v", toEncrypt, err)
+		}
+		if !bytes.Equal(data, result) {
+			t.Fatalf("encrypt %v: retrieved content differs", toEncrypt)
+		}
+		missing, err := fileStore.MissingChunks(ctx, other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(missing) == 0 {
+			t.Fatalf("encrypt %v: expected unpinned content to be collected", toEncrypt)
+		}
+
+		size := ldb.Size()
+		if err := fileStore.Pin(ctx, other); err == nil {
+			t.Fatalf("encrypt %v: expected pinning missing content to fail", toEncrypt)
+		}
+		if err := fileStore.Unpin(ctx, addr); err != nil {
+			t.Fatalf("encrypt %v: unpin error: %v", toEncrypt, err)
+		}
+		ldb.lock.Lock()
+		ldb.collectGarbage(1)
+		ldb.lock.Unlock()
+		if left := ldb.Size(); left != 0 {
+			t.Fatalf("encrypt %v: expected all %d chunks to be collected, %d left", toEncrypt, size, left)
+		}
+		cleanup()
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// DefaultPrefetchConcurrency is the default number of chunks retrieved
+// concurrently when the chunk tree of a content is prefetched
+const DefaultPrefetchConcurrency = 16
+
+var (
+	prefetchChunkCounter = metrics.NewRegisteredCounter("filestore.prefetch.chunk", nil)
+	prefetchFailCounter  = metrics.NewRegisteredCounter("filestore.prefetch.fail", nil)
+)
+
+// Prefetch retrieves all the chunks of the chunk tree of the data with the
+// given reference so that they are available in the local store, at most
+// concurrency chunks are retrieved at the same time. The chunks are counted
+// by the tag as they are discovered and retrieved.
+//
+// The subtrees of intermediate chunks which cannot be retrieved are skipped
+// and the first retrieval error is returned after all the other chunks are
+// retrieved.
+func (f *FileStore) Prefetch(ctx context.Context, ref Address, concurrency int, tag *Tag) error {
+	if concurrency <= 0 {
+		concurrency = DefaultPrefetchConcurrency
+	}
+	h := NewHasherStore(f, f.hashFunc, len(ref) > f.hashFunc().Size())
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		seen   = make(map[string]bool)
+		errOne error
+		sem    = make(chan struct{}, concurrency)
+	)
+	fail := func(err error) {
+		prefetchFailCounter.Inc(1)
+		tag.Inc(StateFailed)
+		mu.Lock()
+		if errOne == nil {
+			errOne = err
+		}
+		mu.Unlock()
+	}
+
+	// the retrieval slot of a chunk is acquired before its goroutine is
+	// started and released when the chunk is retrieved, so the number of
+	// goroutines waiting for a slot is bounded by the depth of the tree
+	var fetch func(ref Reference)
+	schedule := func(ref Reference) {
+		// the chunks of zeros of sparse content are not stored
+		if isZeroReference(ref) {
+			return
+		}
+		mu.Lock()
+		if seen[string(ref)] {
+			mu.Unlock()
+			return
+		}
+		seen[string(ref)] = true
+		mu.Unlock()
+		tag.Inc(StateTotal)
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			return
+		}
+		wg.Add(1)
+		go fetch(ref)
+	}
+	fetch = func(ref Reference) {
+		defer wg.Done()
+		data, err := h.Get(ref)
+		<-sem
+		if err == nil && len(data) < 8 {
+			err = ErrChunkInvalid
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+		prefetchChunkCounter.Inc(1)
+		tag.Inc(StateStored)
+
+		payload := data[8:]
+		if data.Size() <= int64(len(payload)) {
+			return
+		}
+		for i := int64(0); i+h.refSize <= int64(len(payload)); i += h.refSize {
+			schedule(Reference(payload[i : i+h.refSize]))
+		}
+	}
+
+	schedule(Reference(ref))
+	wg.Wait()
+	return errOne
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+// fetchingChunkStore retrieves the chunks missing from its local store from
+// a remote store and keeps the number of chunks retrieved concurrently
+type fetchingChunkStore struct {
+	*MapChunkStore
+	remote *MapChunkStore
+
+	active, maxActive int32
+}
+
+func (f *fetchingChunkStore) Get(addr Address) (*Chunk, error) {
+	if chunk, err := f.MapChunkStore.Get(addr); err == nil {
+		return chunk, nil
+	}
+	active := atomic.AddInt32(&f.active, 1)
+	defer atomic.AddInt32(&f.active, -1)
+	for {
+		max := atomic.LoadInt32(&f.maxActive)
+		if active <= max || atomic.CompareAndSwapInt32(&f.maxActive, max, active) {
+			break
+		}
+	}
+	chunk, err := f.remote.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	f.Put(chunk)
+	return chunk, nil
+}
+
+func TestFileStorePrefetch(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		remote := NewMapChunkStore()
+		size := int64(DefaultChunkSize * 300)
+		reader, _ := generateRandomData(int(size))
+		ctx := context.TODO()
+		root, wait, err := NewFileStore(remote, NewFileStoreParams()).Store(ctx, reader, size, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		store := &fetchingChunkStore{MapChunkStore: NewMapChunkStore(), remote: remote}
+		fileStore := NewFileStore(store, NewFileStoreParams())
+		tag := NewTag(1, "prefetch", root)
+		if err := fileStore.Prefetch(ctx, root, 4, tag); err != nil {
+			t.Fatal(err)
+		}
+		n := int64(len(remote.chunks))
+		if tag.Get(StateTotal) != n || tag.Get(StateStored) != n || tag.Get(StateFailed) != 0 {
+			t.Fatalf("expected %d chunks stored, got total %d, stored %d, failed %d", n, tag.Get(StateTotal), tag.Get(StateStored), tag.Get(StateFailed))
+		}
+		if len(store.chunks) != len(remote.chunks) {
+			t.Fatalf("expected %d chunks in the local store, got %d", len(remote.chunks), len(store.chunks))
+		}
+		if max := atomic.LoadInt32(&store.maxActive); max > 4 {
+			t.Fatalf("expected at most 4 concurrent retrievals, got %d", max)
+		}
+
+		// the subtree of a missing intermediate chunk is skipped
+		missing := NewMapChunkStore()
+		fileStore = NewFileStore(&fetchingChunkStore{MapChunkStore: missing, remote: NewMapChunkStore()}, NewFileStoreParams())
+		tag = NewTag(2, "prefetch", root)
+		if err := fileStore.Prefetch(ctx, root, 4, tag); Cause(err) != ErrChunkNotFound {
+			t.Fatalf("expected %v, got %v", ErrChunkNotFound, err)
+		}
+		if tag.Get(StateTotal) != 1 || tag.Get(StateFailed) != 1 {
+			t.Fatalf("expected the root chunk to fail, got total %d, failed %d", tag.Get(StateTotal), tag.Get(StateFailed))
+		}
+	}
+}
+
+// TestFileStorePrefetchSparse tests that the chunks of zeros of sparse
+// content are not retrieved
+func TestFileStorePrefetchSparse(t *testing.T) {
+	remote := NewMapChunkStore()
+	size := int64(DefaultChunkSize * 300)
+	data := make([]byte, size)
+	copy(data, []byte("header"))
+	ctx := WithSparse(context.TODO(), true)
+	root, wait, err := NewFileStore(remote, NewFileStoreParams()).Store(ctx, bytes.NewReader(data), size, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fetchingChunkStore{MapChunkStore: NewMapChunkStore(), remote: remote}
+	fileStore := NewFileStore(store, NewFileStoreParams())
+	tag := NewTag(1, "prefetch", root)
+	if err := fileStore.Prefetch(context.TODO(), root, 4, tag); err != nil {
+		t.Fatal(err)
+	}
+	n := int64(len(remote.chunks))
+	if tag.Get(StateTotal) != n || tag.Get(StateStored) != n || tag.Get(StateFailed) != 0 {
+		t.Fatalf("expected %d chunks stored, got total %d, stored %d, failed %d", n, tag.Get(StateTotal), tag.Get(StateStored), tag.Get(StateFailed))
+	}
+	missing, err := fileStore.MissingChunks(context.TODO(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("expected no missing chunks, got %v", missing)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	lstorage "github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/table"
+)
+
+var (
+	recoveredDBCounter       = metrics.NewRegisteredCounter("ldbdatabase.recovered", nil)
+	quarantinedTablesCounter = metrics.NewRegisteredCounter("ldbdatabase.recovery.quarantined", nil)
+	lostRangesCounter        = metrics.NewRegisteredCounter("ldbdatabase.recovery.lostranges", nil)
+)
+
+// RecoveryReport describes the recovery of a corrupted database
+type RecoveryReport struct {
+	Time        time.Time
+	Quarantine  string     // directory the unrecoverable tables were moved to
+	Quarantined []string   // names of the unrecoverable tables
+	Lost        []KeyRange // key ranges of the dropped tables and blocks
+	LostBins    []uint8    // proximity bins of the chunk store with lost chunks
+}
+
+// KeyRange is a range of keys lost in the recovery of a database, nil Start
+// or Limit mean the range is unbounded
+type KeyRange struct {
+	Start []byte
+	Limit []byte
+}
+
+func (r KeyRange) String() string {
+	start, limit := "-", "-"
+	if r.Start != nil {
+		start = fmt.Sprintf("%x", r.Start)
+	}
+	if r.Limit != nil {
+		limit = fmt.Sprintf("%x", r.Limit)
+	}
+	return fmt.Sprintf("[%s, %s]", start, limit)
+}
+
+// isTableName returns the file number of a leveldb table file name
+func isTableName(name string) (int64, bool) {
+	ext := filepath.Ext(name)
+	if ext != ".ldb" && ext != ".sst" {
+		return 0, false
+	}
+	num, err := strconv.ParseInt(strings.TrimSuffix(name, ext), 10, 64)
+	return num, err == nil
+}
+
+// recoverLDBDatabase recovers the corrupted leveldb database at path
+//
+// Before the database is recovered from its tables, every table is checked.
+// Tables which cannot be read at all are moved to a quarantine directory next
+// to the database and the key ranges of the corrupted blocks of the others
+// are recorded as lost, leveldb drops these blocks when it rebuilds the
+// tables.
+func recoverLDBDatabase(path string, o *opt.Options) (*leveldb.DB, *RecoveryReport, error) {
+	report := &RecoveryReport{
+		Time:       time.Now(),
+		Quarantine: fmt.Sprintf("%s.quarantine-%d", path, time.Now().Unix()),
+	}
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, fi := range files {
+		num, ok := isTableName(fi.Name())
+		if !ok || fi.IsDir() {
+			continue
+		}
+		file := filepath.Join(path, fi.Name())
+		lost, ok := checkTable(file, num, fi.Size())
+		report.Lost = append(report.Lost, lost...)
+		if ok {
+			continue
+		}
+		if err := os.MkdirAll(report.Quarantine, 0700); err != nil {
+			return nil, nil, err
+		}
+		if err := os.Rename(file, filepath.Join(report.Quarantine, fi.Name())); err != nil {
+			return nil, nil, err
+		}
+		report.Quarantined = append(report.Quarantined, fi.Name())
+		quarantinedTablesCounter.Inc(1)
+		log.Error("quarantined unrecoverable table", "table", fi.Name(), "quarantine", report.Quarantine)
+	}
+
+	// the journal of the recovered database is replayed leniently
+	ro := *o
+	ro.Strict = opt.DefaultStrict &^ opt.StrictJournalChecksum
+	db, err := leveldb.RecoverFile(path, &ro)
+	if err != nil {
+		return nil, nil, err
+	}
+	recoveredDBCounter.Inc(1)
+	lostRangesCounter.Inc(int64(len(report.Lost)))
+	for _, r := range report.Lost {
+		log.Error("key range lost in database recovery", "path", path, "range", r)
+	}
+	log.Warn("recovered corrupted database", "path", path, "quarantined", len(report.Quarantined), "lost", len(report.Lost))
+	return db, report, nil
+}
+
+// checkTable reads all the keys of a table and returns the key ranges of its
+// corrupted blocks, if the table cannot be recovered at all, it returns false
+func checkTable(file string, num int64, size int64) ([]KeyRange, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return []KeyRange{{}}, false
+	}
+	defer f.Close()
+
+	fd := lstorage.FileDesc{Type: lstorage.TypeTable, Num: num}
+	tr, err := table.NewReader(f, size, fd, nil, nil, &opt.Options{Strict: opt.StrictBlockChecksum})
+	if err != nil {
+		log.Error("unreadable table", "table", file, "err", err)
+		return []KeyRange{{}}, false
+	}
+	iter := tr.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var (
+		lost      []KeyRange
+		last      []byte
+		corrupted bool
+		good      int
+	)
+	if setter, ok := iter.(iterator.ErrorCallbackSetter); ok {
+		setter.SetErrorCallback(func(err error) {
+			if lerrors.IsCorrupted(err) {
+				log.Error("corrupted table block", "table", file, "err", err)
+				corrupted = true
+			}
+		})
+	}
+	for iter.Next() {
+		key := userKey(iter.Key())
+		if corrupted {
+			lost = append(lost, KeyRange{Start: last, Limit: key})
+			corrupted = false
+		}
+		last = key
+		good++
+	}
+	if err := iter.Error(); err != nil && !lerrors.IsCorrupted(err) {
+		log.Error("unreadable table", "table", file, "err", err)
+		return []KeyRange{{}}, false
+	}
+	if corrupted {
+		lost = append(lost, KeyRange{Start: last})
+	}
+	if good == 0 {
+		return []KeyRange{{}}, false
+	}
+	return lost, true
+}
+
+// userKey strips the sequence number and type suffix of a leveldb internal key
+func userKey(ikey []byte) []byte {
+	if len(ikey) < 8 {
+		return nil
+	}
+	return append([]byte{}, ikey[:len(ikey)-8]...)
+}
+
+// lostBins returns the proximity bins of the chunks in the lost key ranges,
+// chunks are lost if either their index or their data is lost
+func lostBins(lost []KeyRange, baseKey []byte) []uint8 {
+	var bins [MaxPO + 1]bool
+	all := func(from int) {
+		for po := from; po <= MaxPO; po++ {
+			bins[po] = true
+		}
+	}
+	for _, r := range lost {
+		switch {
+		case len(r.Start) == 0 || len(r.Limit) == 0 || r.Start[0] != r.Limit[0]:
+			all(0)
+		case r.Start[0] == keyData && len(r.Start) > 1 && len(r.Limit) > 1:
+			for po := int(r.Start[1]); po <= int(r.Limit[1]) && po <= MaxPO; po++ {
+				bins[po] = true
+			}
+		case r.Start[0] == keyIndex && len(r.Start) == len(baseKey)+1 && len(r.Limit) == len(baseKey)+1:
+			// the addresses in the range share their first bits with each
+			// other, if these differ from the base key the bin is known
+			common := Proximity(r.Start[1:], r.Limit[1:])
+			if po := Proximity(baseKey, r.Start[1:]); po < common {
+				bins[po] = true
+			} else {
+				all(common)
+			}
+		case r.Start[0] == keyIndex || r.Start[0] == keyData:
+			all(0)
+		}
+	}
+	var res []uint8
+	for po, lost := range bins {
+		if lost {
+			res = append(res, uint8(po))
+		}
+	}
+	return res
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestLostBins(t *testing.T) {
+	baseKey := make([]byte, 32)
+	addr := func(b byte) []byte {
+		a := make([]byte, 33)
+		a[0] = keyIndex
+		a[1] = b
+		return a
+	}
+	allFrom := func(from int) []uint8 {
+		var bins []uint8
+		for po := from; po <= MaxPO; po++ {
+			bins = append(bins, uint8(po))
+		}
+		return bins
+	}
+
+	for _, tc := range []struct {
+		name string
+		lost []KeyRange
+		bins []uint8
+	}{
+		{
+			name: "nothing lost",
+		},
+		{
+			name: "data range",
+			lost: []KeyRange{{Start: getDataKey(3, 2), Limit: getDataKey(7, 4)}},
+			bins: []uint8{2, 3, 4},
+		},
+		{
+			name: "index range within a bin",
+			lost: []KeyRange{{Start: addr(0x20), Limit: addr(0x30)}},
+			bins: []uint8{2},
+		},
+		{
+			name: "index range across bins",
+			lost: []KeyRange{{Start: addr(0x01), Limit: addr(0x10)}},
+			bins: allFrom(3),
+		},
+		{
+			name: "unbounded range",
+			lost: []KeyRange{{Start: addr(0x20)}},
+			bins: allFrom(0),
+		},
+		{
+			name: "counters range",
+			lost: []KeyRange{{Start: keyAccessCnt, Limit: keyAccessCnt}},
+		},
+	} {
+		if bins := lostBins(tc.lost, baseKey); !reflect.DeepEqual(bins, tc.bins) {
+			t.Errorf("%s: expected lost bins %v, got %v", tc.name, tc.bins, bins)
+		}
+	}
+}
+
+// TestRecoverCorruptedDatabase tests that a chunk store with a corrupted
+// manifest and an unreadable table is recovered when it is opened
+func TestRecoverCorruptedDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage-testrecovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "chunks")
+
+	params := NewLDBStoreParams(NewDefaultStoreParams(), path)
+	store, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 50
+	chunks := GenerateRandomChunks(DefaultChunkSize, n)
+	for _, chunk := range chunks {
+		store.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// spread the entries over small tables
+	db, err := leveldb.OpenFile(path, &opt.Options{CompactionTableSize: 16 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	var table string
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		if _, ok := isTableName(fi.Name()); ok {
+			table = fi.Name()
+		}
+		if strings.HasPrefix(fi.Name(), "MANIFEST-") {
+			if err := ioutil.WriteFile(filepath.Join(path, fi.Name()), bytes.Repeat([]byte{0xff}, 512), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if table == "" {
+		t.Fatal("no tables found")
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, table), bytes.Repeat([]byte{0xff}, 512), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	r := store.Recovery()
+	if r == nil {
+		t.Fatal("expected the store to be recovered")
+	}
+	if !reflect.DeepEqual(r.Quarantined, []string{table}) {
+		t.Fatalf("expected table %s to be quarantined, got %v", table, r.Quarantined)
+	}
+	if _, err := os.Stat(filepath.Join(r.Quarantine, table)); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.LostBins) == 0 {
+		t.Fatal("expected lost bins")
+	}
+
+	var found int
+	for _, chunk := range chunks {
+		c, err := store.Get(chunk.Addr)
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(c.SData, chunk.SData) {
+			t.Fatalf("chunk %v data mismatch", chunk.Addr)
+		}
+		found++
+	}
+	if found == 0 || found == n {
+		t.Fatalf("expected some of the %d chunks to be lost, found %d", n, found)
+	}
+	if store.entryCnt != uint64(found) {
+		t.Fatalf("expected %d entries, got %d", found, store.entryCnt)
+	}
+
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	store.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(chunk.Addr); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+// DefaultFallbackTimeout is the default time a chunk is retrieved from the
+// network for before it is retrieved from the fallback gateways
+const DefaultFallbackTimeout = 10 * time.Second
+
+// maxChunkDataSize is the size of the data of the largest chunk, a
+// single-owner chunk with a full payload
+const maxChunkDataSize = socHeaderLength + DefaultChunkSize
+
+var (
+	fallbackGetCounter  = metrics.NewRegisteredCounter("fallbackstore.get", nil)
+	fallbackHitCounter  = metrics.NewRegisteredCounter("fallbackstore.hit", nil)
+	fallbackFailCounter = metrics.NewRegisteredCounter("fallbackstore.fail", nil)
+)
+
+// RemoteFallbackStore is a ChunkStore which retrieves the chunks it cannot
+// retrieve from its chunk store within the timeout from trusted HTTP
+// gateways and puts them into its chunk store, so that light deployments
+// can serve content which is not retrievable from their peers.
+//
+// The chunks are retrieved from the bzz-chunk endpoint of the gateways in
+// order, the chunks are validated by the chunk store when they are put.
+type RemoteFallbackStore struct {
+	ChunkStore
+	gateways []string
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// NewRemoteFallbackStore creates a RemoteFallbackStore with the given
+// gateway URLs
+func NewRemoteFallbackStore(store ChunkStore, gateways []string, timeout time.Duration) *RemoteFallbackStore {
+	if timeout <= 0 {
+		timeout = DefaultFallbackTimeout
+	}
+	urls := make([]string, len(gateways))
+	for i, g := range gateways {
+		urls[i] = strings.TrimSuffix(g, "/")
+	}
+	return &RemoteFallbackStore{
+		ChunkStore: store,
+		gateways:   urls,
+		timeout:    timeout,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// RemoteFallback decorates a store with a RemoteFallbackStore
+func RemoteFallback(gateways []string, timeout time.Duration) Middleware {
+	return func(store ChunkStore) ChunkStore {
+		return NewRemoteFallbackStore(store, gateways, timeout)
+	}
+}
+
+// Unwrap returns the chunk store the chunks are retrieved from first
+func (s *RemoteFallbackStore) Unwrap() ChunkStore {
+	return s.ChunkStore
+}
+
+// Get retrieves the chunk from the chunk store, or from the gateways if it
+// cannot be retrieved within the timeout
+func (s *RemoteFallbackStore) Get(addr Address) (*Chunk, error) {
+	type result struct {
+		chunk *Chunk
+		err   error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		chunk, err := s.ChunkStore.Get(addr)
+		resultC <- result{chunk, err}
+	}()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case r := <-resultC:
+		if r.err == nil {
+			return r.chunk, nil
+		}
+		err = r.err
+	case <-timer.C:
+		err = ErrChunkTimeout
+	}
+	log.Debug("retrieving chunk from fallback gateways", "addr", addr, "err", err)
+	fallbackGetCounter.Inc(1)
+
+	for _, gateway := range s.gateways {
+		chunk, gerr := s.fetch(gateway, addr)
+		if gerr != nil {
+			log.Debug("fallback gateway retrieval failed", "gateway", gateway, "addr", addr, "err", gerr)
+			continue
+		}
+		fallbackHitCounter.Inc(1)
+		return chunk, nil
+	}
+	fallbackFailCounter.Inc(1)
+	return nil, err
+}
+
+// fetch retrieves the chunk from the gateway and puts it into the chunk
+// store
+func (s *RemoteFallbackStore) fetch(gateway string, addr Address) (*Chunk, error) {
+	res, err := s.client.Get(gateway + "/bzz-chunk:/" + addr.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxChunkDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxChunkDataSize {
+		return nil, ErrChunkInvalid
+	}
+
+	chunk := NewChunk(addr, nil)
+	chunk.SData = data
+	s.ChunkStore.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newTestGateway starts an HTTP server serving the chunks of the store on
+// the bzz-chunk endpoint
+func newTestGateway(store ChunkStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := common.Hex2Bytes(strings.TrimPrefix(r.URL.Path, "/bzz-chunk:/"))
+		chunk, err := store.Get(Address(addr))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(chunk.SData)
+	}))
+}
+
+// blockingChunkStore is a ChunkStore whose Get blocks until it is released
+type blockingChunkStore struct {
+	ChunkStore
+	release chan struct{}
+}
+
+func (s *blockingChunkStore) Get(addr Address) (*Chunk, error) {
+	<-s.release
+	return s.ChunkStore.Get(addr)
+}
+
+// TestRemoteFallbackStore tests that the chunks which are not in the chunk
+// store are retrieved from the gateways, validated and stored locally
+func TestRemoteFallbackStore(t *testing.T) {
+	source := NewMapChunkStore()
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	source.Put(chunk)
+	gateway := newTestGateway(source)
+	defer gateway.Close()
+
+	// a gateway responding with garbage for every chunk
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := make([]byte, 100)
+		rand.Read(data)
+		w.Write(data)
+	}))
+	defer bad.Close()
+
+	datadir, err := ioutil.TempDir("", "fallbackstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, err := NewLocalFileStore(datadir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	localStore := fileStore.ChunkStore.(*LocalStore)
+	defer localStore.Close()
+
+	store := NewRemoteFallbackStore(localStore, []string{bad.URL, gateway.URL + "/"}, time.Second)
+	got, err := store.Get(chunk.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.SData, chunk.SData) {
+		t.Fatal("expected the chunk data of the gateway")
+	}
+	if _, err := localStore.Get(chunk.Addr); err != nil {
+		t.Fatalf("expected the chunk to be stored locally, got %v", err)
+	}
+
+	missing := GenerateRandomChunk(DefaultChunkSize)
+	if _, err := store.Get(missing.Addr); Cause(err) != ErrChunkNotFound {
+		t.Fatalf("expected %v, got %v", ErrChunkNotFound, err)
+	}
+}
+
+// TestRemoteFallbackStoreTimeout tests that a chunk is retrieved from the
+// gateways when it cannot be retrieved from the chunk store in time
+func TestRemoteFallbackStoreTimeout(t *testing.T) {
+	source := NewMapChunkStore()
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	source.Put(chunk)
+	gateway := newTestGateway(source)
+	defer gateway.Close()
+
+	inner := &blockingChunkStore{
+		ChunkStore: NewMapChunkStore(),
+		release:    make(chan struct{}),
+	}
+	defer close(inner.release)
+
+	store := NewRemoteFallbackStore(inner, []string{gateway.URL}, 50*time.Millisecond)
+	got, err := store.Get(chunk.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.SData, chunk.SData) {
+		t.Fatal("expected the chunk data of the gateway")
+	}
+
+	missing := GenerateRandomChunk(DefaultChunkSize)
+	if _, err := store.Get(missing.Addr); err != ErrChunkTimeout {
+		t.Fatalf("expected %v, got %v", ErrChunkTimeout, err)
+	}
+}
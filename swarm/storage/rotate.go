@@ -0,0 +1,235 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// rotateBatchSize is the number of index entries whose chunk data is
+// re-encrypted in a batch by a key rotation
+var rotateBatchSize = 1000
+
+var (
+	ErrNotEncrypted       = errors.New("chunk store not encrypted at rest")
+	ErrRotationInProgress = errors.New("key rotation in progress")
+)
+
+var rotateChunkCounter = metrics.NewRegisteredCounter("ldbstore.rotatekey.chunk", nil)
+
+// keyState is the persisted state of the data keys of a store encrypted at
+// rest and of their rotation
+type keyState struct {
+	Keys    [][]byte // data keys wrapped with the store key, the current one first
+	Pos     []byte   // index key the rotation continues from, empty if no rotation is in progress
+	Rotated uint64   // chunks re-encrypted by the last rotation
+}
+
+// RotationStatus is the status of the key rotation of a store encrypted at
+// rest
+type RotationStatus struct {
+	Rotating bool   `json:"rotating"` // a rotation is in progress
+	Keys     int    `json:"keys"`     // data keys in use, more than one while rotating
+	Rotated  uint64 `json:"rotated"`  // chunks re-encrypted by the rotation in progress or the last one
+	Entries  uint64 `json:"entries"`  // chunks in the store
+}
+
+// RotateKey starts the rotation of the data key of a store encrypted at
+// rest. New chunk data is encrypted with a new data key right away and the
+// data of the stored chunks is re-encrypted with it in the background, after
+// which the previous keys are discarded. The progress of the rotation is
+// persisted so that it is resumed when the store is opened again.
+func (s *LDBStore) RotateKey() error {
+	if s.storeKey == nil {
+		return ErrNotEncrypted
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.keyState.Pos) > 0 {
+		return ErrRotationInProgress
+	}
+	// the pending entries are persisted first so that they are rotated
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	aead, err := newDataCipher(key)
+	if err != nil {
+		return err
+	}
+	state := &keyState{
+		Keys: append([][]byte{wrapKey(s.storeKey, key)}, s.keyState.Keys...),
+		Pos:  []byte{keyIndex},
+	}
+	if err := s.putKeyState(new(leveldb.Batch), state); err != nil {
+		return err
+	}
+	s.keyState = state
+	s.dataKeys.Store(&dataKeys{all: append([]cipher.AEAD{aead}, s.keys().all...)})
+	log.Info("rotating chunk store data key", "keys", len(state.Keys))
+	supervisor.Go("ldbstore.rotatekey", s.quit, s.rotateKey)
+	return nil
+}
+
+// KeyRotation returns the status of the key rotation of the store
+func (s *LDBStore) KeyRotation() (*RotationStatus, error) {
+	if s.storeKey == nil {
+		return nil, ErrNotEncrypted
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return &RotationStatus{
+		Rotating: len(s.keyState.Pos) > 0,
+		Keys:     len(s.keyState.Keys),
+		Rotated:  s.keyState.Rotated,
+		Entries:  s.entryCnt,
+	}, nil
+}
+
+// putKeyState persists the key state together with the batch
+func (s *LDBStore) putKeyState(batch *leveldb.Batch, state *keyState) error {
+	data, err := rlp.EncodeToBytes(state)
+	if err != nil {
+		return err
+	}
+	batch.Put(keyDataKeys, data)
+	if err := s.writeShards(); err != nil {
+		return err
+	}
+	return s.db.Write(batch)
+}
+
+// rotateKey re-encrypts the chunk data of the store with the current data
+// key batch by batch until all of it is rotated or the store is closed
+func (s *LDBStore) rotateKey() {
+	for {
+		done, err := s.rotateBatch()
+		if err != nil {
+			log.Error("chunk store key rotation failed", "err", err)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// rotateBatch re-encrypts the chunk data of the next rotateBatchSize index
+// entries with the current data key, it returns true if the rotation is
+// finished or the store is closed
+func (s *LDBStore) rotateBatch() (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed() || len(s.keyState.Pos) == 0 {
+		return true, nil
+	}
+	keys := s.keys()
+	state := &keyState{
+		Keys:    s.keyState.Keys,
+		Rotated: s.keyState.Rotated,
+	}
+
+	batch := new(leveldb.Batch)
+	it := s.db.NewIterator()
+	defer it.Release()
+	n := 0
+	ok := it.Seek(s.keyState.Pos)
+	for ; ok && it.Key()[0] == keyIndex && n < rotateBatchSize; ok = it.Next() {
+		n++
+		var index dpaDBIndex
+		if decodeIndex(it.Value(), &index) != nil || len(index.Codec) == 0 || index.Codec[len(index.Codec)-1] != codecAESGCM {
+			continue
+		}
+		addr := Address(append([]byte{}, it.Key()[1:]...))
+		dkey := getDataKey(index.Idx, s.po(addr))
+		data, err := s.getData(addr, dkey)
+		if err != nil || len(data) < 32 {
+			continue
+		}
+		// the encryption is the last codec applied to the chunk data
+		plain, i, err := keys.open(addr, data[32:])
+		if err != nil {
+			log.Warn("chunk data cannot be decrypted for key rotation", "addr", addr, "err", err)
+			continue
+		}
+		if i == 0 {
+			continue
+		}
+		sdata := encryptData(keys.current(), addr, plain)
+		s.putData(batch, addr, dkey, append(append(make([]byte, 0, len(addr)+len(sdata)), addr...), sdata...))
+		state.Rotated++
+	}
+	if err := it.Error(); err != nil {
+		return false, err
+	}
+	done := !ok || it.Key()[0] != keyIndex
+	if done {
+		state.Keys = state.Keys[:1]
+	} else {
+		state.Pos = append([]byte{}, it.Key()...)
+	}
+	if err := s.putKeyState(batch, state); err != nil {
+		return false, err
+	}
+	rotateChunkCounter.Inc(int64(state.Rotated - s.keyState.Rotated))
+	s.keyState = state
+	if done {
+		s.dataKeys.Store(&dataKeys{all: keys.all[:1]})
+		log.Info("rotated chunk store data key", "chunks", state.Rotated)
+	}
+	return done, nil
+}
+
+// RotationAPI is the RPC API of the key rotation of a chunk store encrypted
+// at rest
+type RotationAPI struct {
+	store *LDBStore
+}
+
+// NewRotationAPI creates the key rotation API of the store
+func NewRotationAPI(store *LDBStore) *RotationAPI {
+	return &RotationAPI{store: store}
+}
+
+// RotateStoreKey starts the rotation of the data key of the chunk store and
+// returns its status
+func (api *RotationAPI) RotateStoreKey() (*RotationStatus, error) {
+	if err := api.store.RotateKey(); err != nil {
+		return nil, err
+	}
+	return api.store.KeyRotation()
+}
+
+// StoreKeyRotation returns the status of the key rotation of the chunk store
+func (api *RotationAPI) StoreKeyRotation() (*RotationStatus, error) {
+	return api.store.KeyRotation()
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestLDBStoreRotateKey tests that the chunk data of a store encrypted at
+// rest is re-encrypted with a new data key, that an interrupted rotation is
+// resumed when the store is opened again and that the previous key is
+// discarded when the rotation is finished
+func TestLDBStoreRotateKey(t *testing.T) {
+	defer func(n int) { rotateBatchSize = n }(rotateBatchSize)
+	rotateBatchSize = 10
+
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	params.EncryptionKey = DeriveStoreKey(prvKey)
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := GenerateRandomChunks(DefaultChunkSize, 100)
+	for _, chunk := range chunks {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldKeys := ldb.keys()
+
+	if err := ldb.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	// the rotation is interrupted and resumed when the store is opened
+	ldb.Close()
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	if err := ldb.RotateKey(); err != ErrRotationInProgress && err != nil {
+		t.Fatalf("expected %v, got %v", ErrRotationInProgress, err)
+	}
+
+	var status *RotationStatus
+	for i := 0; status == nil || status.Rotating; i++ {
+		if i == 100 {
+			t.Fatalf("rotation not finished: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if status, err = ldb.KeyRotation(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status.Keys != 1 || status.Entries != 100 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	for _, chunk := range chunks {
+		got, err := ldb.Get(chunk.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.SData, chunk.SData) {
+			t.Fatalf("unexpected data of chunk %v", chunk.Addr)
+		}
+		// the data is not encrypted with the previous key any more
+		var index dpaDBIndex
+		idata, _ := ldb.db.Get(getIndexKey(chunk.Addr))
+		decodeIndex(idata, &index)
+		data, _ := ldb.db.Get(getDataKey(index.Idx, ldb.po(chunk.Addr)))
+		if _, _, err := oldKeys.open(chunk.Addr, data[32:]); err == nil {
+			t.Fatalf("chunk %v still encrypted with the previous key", chunk.Addr)
+		}
+	}
+}
+
+// TestRotateKeyNotEncrypted tests that the key of a store which is not
+// encrypted at rest cannot be rotated
+func TestRotateKeyNotEncrypted(t *testing.T) {
+	ldb, cleanup := newLDBStore(t)
+	defer cleanup()
+	if err := ldb.RotateKey(); err != ErrNotEncrypted {
+		t.Fatalf("expected %v, got %v", ErrNotEncrypted, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// The data entries of an LDBStore can be sharded across databases in
+// several directories, so that a node can use several disks. The shard of a
+// chunk is chosen by the prefix of its address, the address space is split
+// into equal ranges, one for each database. All the other entries are kept
+// in the primary database, which is the first shard.
+//
+// The directories of the shards are persisted, when a directory is added or
+// removed the data entries are moved to their new shards when the store is
+// opened.
+
+var shardMoveCounter = metrics.NewRegisteredCounter("ldbstore.shard.move", nil)
+
+// shard returns the position of the database holding the data entry of the
+// chunk with the given address
+func (s *LDBStore) shard(addr Address) int {
+	if len(s.shards) < 2 || len(addr) == 0 {
+		return 0
+	}
+	return int(addr[0]) * len(s.shards) / 0x100
+}
+
+// getData returns the data entry with the given key of the chunk with the
+// given address
+func (s *LDBStore) getData(addr Address, key []byte) ([]byte, error) {
+	return s.shards[s.shard(addr)].Get(key)
+}
+
+// putData adds the data entry of the chunk with the given address to the
+// batch if it is kept in the primary database, otherwise to the pending
+// batch of its shard which is written before the batch
+func (s *LDBStore) putData(b *leveldb.Batch, addr Address, key, data []byte) {
+	if i := s.shard(addr); i > 0 {
+		b = s.shardBatches[i]
+	}
+	b.Put(key, data)
+}
+
+// deleteData adds the deletion of a data entry to the batch like putData
+func (s *LDBStore) deleteData(b *leveldb.Batch, addr Address, key []byte) {
+	if i := s.shard(addr); i > 0 {
+		b = s.shardBatches[i]
+	}
+	b.Delete(key)
+}
+
+// writeShards writes the pending batches of the shards, they are written
+// before the batch of the primary database so that an index entry never
+// refers to data which is not written yet
+func (s *LDBStore) writeShards() error {
+	for i := 1; i < len(s.shards); i++ {
+		if s.shardBatches[i].Len() == 0 {
+			continue
+		}
+		if err := s.shards[i].Write(s.shardBatches[i]); err != nil {
+			return err
+		}
+		s.shardBatches[i].Reset()
+	}
+	return nil
+}
+
+// openShards opens the databases of the shards in the given directories and
+// moves the data entries to their shards if the directories changed since
+// the store was last opened. If keep is true or the store is opened read-only
+// the persisted directories are used.
+func (s *LDBStore) openShards(paths []string, keep bool) error {
+	var layout []string
+	if data, err := s.db.Get(keyShards); err == nil {
+		if err := rlp.DecodeBytes(data, &layout); err != nil {
+			return err
+		}
+	}
+	if s.readOnly || keep {
+		paths = layout
+	} else {
+		clean := make([]string, len(paths))
+		for i, path := range paths {
+			clean[i] = filepath.Clean(path)
+		}
+		paths = clean
+	}
+
+	dbs := make(map[string]*LDBDatabase)
+	open := func(path string) (*LDBDatabase, error) {
+		if db, ok := dbs[path]; ok {
+			return db, nil
+		}
+		var db *LDBDatabase
+		var err error
+		if s.readOnly {
+			db, err = NewLDBDatabaseReadOnly(path)
+		} else {
+			db, err = NewLDBDatabase(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		dbs[path] = db
+		return db, nil
+	}
+	shards := []*LDBDatabase{s.db}
+	for _, path := range paths {
+		db, err := open(path)
+		if err != nil {
+			s.closeShards(dbs)
+			return err
+		}
+		shards = append(shards, db)
+	}
+	s.shards = shards
+	s.shardBatches = make([]*leveldb.Batch, len(shards))
+	for i := range s.shardBatches {
+		s.shardBatches[i] = new(leveldb.Batch)
+	}
+	if equalPaths(layout, paths) {
+		return nil
+	}
+
+	old := []*LDBDatabase{s.db}
+	for _, path := range layout {
+		db, err := open(path)
+		if err != nil {
+			s.closeShards(dbs)
+			return err
+		}
+		old = append(old, db)
+	}
+	if err := s.rebalance(old); err != nil {
+		s.closeShards(dbs)
+		return err
+	}
+	data, err := rlp.EncodeToBytes(paths)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Put(keyShards, data); err != nil {
+		return err
+	}
+
+	for _, path := range layout {
+		if !containsPath(paths, path) {
+			dbs[path].Close()
+			log.Info("chunk store shard removed, its directory can be deleted", "path", path)
+		}
+	}
+	return nil
+}
+
+// rebalance moves the data entries of the given databases which are not in
+// their shard to their shard
+func (s *LDBStore) rebalance(dbs []*LDBDatabase) error {
+	var moved int
+	for _, db := range dbs {
+		// the entries are written to their shard before they are deleted
+		del := new(leveldb.Batch)
+		write := func() error {
+			if err := s.db.Write(s.shardBatches[0]); err != nil {
+				return err
+			}
+			s.shardBatches[0].Reset()
+			if err := s.writeShards(); err != nil {
+				return err
+			}
+			if err := db.Write(del); err != nil {
+				return err
+			}
+			del.Reset()
+			return nil
+		}
+		it := db.NewIterator()
+		for ok := it.Seek([]byte{keyData}); ok && it.Key()[0] == keyData; ok = it.Next() {
+			if len(it.Value()) < 32 {
+				continue
+			}
+			addr := Address(it.Value()[:32])
+			i := s.shard(addr)
+			if s.shards[i] == db {
+				continue
+			}
+			key := append([]byte{}, it.Key()...)
+			data := append([]byte{}, it.Value()...)
+			// the unused batch of the primary database collects the
+			// entries moved to it
+			s.shardBatches[i].Put(key, data)
+			del.Delete(key)
+			moved++
+			if del.Len() >= syncIndexBatchSize {
+				if err := write(); err != nil {
+					it.Release()
+					return err
+				}
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	shardMoveCounter.Inc(int64(moved))
+	if moved > 0 {
+		log.Info("moved chunk data to their shards", "entries", moved, "shards", len(s.shards))
+	}
+	return nil
+}
+
+// closeShards closes the databases of the shards
+func (s *LDBStore) closeShards(dbs map[string]*LDBDatabase) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
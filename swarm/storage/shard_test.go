@@ -0,0 +1,126 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLDBStoreShards tests that the chunk data is moved to the shards of
+// the store when directories are added and back when they are removed
+func TestLDBStoreShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shard1 := filepath.Join(dir, "shard1")
+	shard2 := filepath.Join(dir, "shard2")
+
+	params := NewLDBStoreParams(NewDefaultStoreParams(), filepath.Join(dir, "chunks"))
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := GenerateRandomChunks(DefaultChunkSize, 100)
+	for _, chunk := range chunks {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ldb.Close()
+
+	check := func(ldb *LDBStore, shards int) {
+		t.Helper()
+		if len(ldb.shards) != shards {
+			t.Fatalf("expected %d shards, got %d", shards, len(ldb.shards))
+		}
+		report, err := ldb.Verify(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Consistent() {
+			t.Fatalf("expected a consistent store, got %+v", report)
+		}
+		counts := make([]int, shards)
+		for i, db := range ldb.shards {
+			it := db.NewIterator()
+			for ok := it.Seek([]byte{keyData}); ok && it.Key()[0] == keyData; ok = it.Next() {
+				if ldb.shard(Address(it.Value()[:32])) != i {
+					t.Fatalf("chunk %x in shard %d", it.Value()[:32], i)
+				}
+				counts[i]++
+			}
+			it.Release()
+		}
+		for i, n := range counts {
+			if n == 0 {
+				t.Fatalf("expected chunks in shard %d", i)
+			}
+		}
+		for _, chunk := range chunks {
+			got, err := ldb.Get(chunk.Addr)
+			if err != nil {
+				t.Fatalf("chunk %v: %v", chunk.Addr, err)
+			}
+			if !bytes.Equal(got.SData, chunk.SData) {
+				t.Fatalf("chunk %v: data mismatch", chunk.Addr)
+			}
+		}
+	}
+
+	params.ShardPaths = []string{shard1, shard2}
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(ldb, 3)
+	// new chunks are put to their shards
+	more := GenerateRandomChunks(DefaultChunkSize, 20)
+	for _, chunk := range more {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunks = append(chunks, more...)
+	check(ldb, 3)
+	ldb.Close()
+
+	// the persisted shards are kept
+	keep := NewLDBStoreParams(NewDefaultStoreParams(), params.Path)
+	keep.KeepShards = true
+	ldb, err = NewLDBStore(keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(ldb, 3)
+	ldb.Close()
+
+	params.ShardPaths = []string{shard2}
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	check(ldb, 2)
+}
@@ -0,0 +1,211 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/ethereum/go-ethereum/swarm/supervisor"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// DefaultDbLowWater is the default ratio of the byte capacity of the chunk
+// store garbage is collected down to when it is exceeded
+const DefaultDbLowWater = 0.9
+
+// dbSizeCheckInterval is the minimum time between two measurements of the
+// size of the chunk store on disk
+var dbSizeCheckInterval = 10 * time.Second
+
+var (
+	dbSizeGauge          = metrics.NewRegisteredGauge("ldbstore.size", nil)
+	dbSizeCollectCounter = metrics.NewRegisteredCounter("ldbstore.size.collect", nil)
+)
+
+// DBUsage is the disk usage of a LevelDB database
+type DBUsage struct {
+	Tables uint64 `json:"tables"` // size of the sorted tables
+	Log    uint64 `json:"log"`    // size of the journal, the manifest and the info log
+	Data   uint64 `json:"data"`   // approximate size of the chunk data in the tables
+	Index  uint64 `json:"index"`  // approximate size of the index entries in the tables
+}
+
+// Usage returns the disk usage of the database, the tables are counted with
+// their size on disk, the journal includes the writes not compacted yet
+func (db *LDBDatabase) Usage() (*DBUsage, error) {
+	files, err := ioutil.ReadDir(db.path)
+	if err != nil {
+		return nil, err
+	}
+	u := new(DBUsage)
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		switch filepath.Ext(f.Name()) {
+		case ".ldb", ".sst":
+			u.Tables += uint64(f.Size())
+		default:
+			u.Log += uint64(f.Size())
+		}
+	}
+	sizes, err := db.db.SizeOf([]util.Range{
+		{Start: []byte{keyData}, Limit: []byte{keyData + 1}},
+		{Start: []byte{keyIndex}, Limit: []byte{keyIndex + 1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.Data, u.Index = uint64(sizes[0]), uint64(sizes[1])
+	return u, nil
+}
+
+// Compact compacts the whole database so that the space of deleted entries
+// is reclaimed
+func (db *LDBDatabase) Compact() error {
+	return db.db.CompactRange(util.Range{})
+}
+
+// StoreUsage is the disk usage of a chunk store
+type StoreUsage struct {
+	DBUsage
+	Bytes        uint64     `json:"bytes"`        // size of all the files of the databases of the store
+	ByteCapacity uint64     `json:"byteCapacity"` // size garbage is collected above, 0 if unlimited
+	Entries      uint64     `json:"entries"`      // number of chunks
+	Capacity     uint64     `json:"capacity"`     // number of chunks garbage is collected above
+	Shards       []*DBUsage `json:"shards,omitempty"`
+}
+
+// Usage returns the disk usage of the store, the usage of the shards is
+// included in the totals
+func (s *LDBStore) Usage() (*StoreUsage, error) {
+	s.lock.RLock()
+	entries, capacity, byteCapacity := s.entryCnt, s.capacity, s.byteCapacity
+	s.lock.RUnlock()
+
+	u := &StoreUsage{
+		ByteCapacity: byteCapacity,
+		Entries:      entries,
+		Capacity:     capacity,
+	}
+	for _, db := range s.shards {
+		du, err := db.Usage()
+		if err != nil {
+			return nil, err
+		}
+		u.Tables += du.Tables
+		u.Log += du.Log
+		u.Data += du.Data
+		u.Index += du.Index
+		if len(s.shards) > 1 {
+			u.Shards = append(u.Shards, du)
+		}
+	}
+	u.Bytes = u.Tables + u.Log
+	return u, nil
+}
+
+// setByteCapacity sets the size of the databases on disk garbage is collected
+// above, the size is measured again with the next batch
+func (s *LDBStore) setByteCapacity(c uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.byteCapacity = c
+	s.sizedAt = time.Time{}
+}
+
+// size returns the size of all the files of the databases of the store
+func (s *LDBStore) size() (uint64, error) {
+	var size uint64
+	for _, db := range s.shards {
+		u, err := db.Usage()
+		if err != nil {
+			return 0, err
+		}
+		size += u.Tables + u.Log
+	}
+	return size, nil
+}
+
+// collectBytes collects garbage until the store is estimated to be below the
+// low-water mark of its byte capacity if it is above the capacity, and then
+// compacts the databases so that the space is reclaimed. The size is not
+// measured again before the compaction is finished. Must be called with the
+// lock held, it returns false if the store is closed meanwhile.
+func (s *LDBStore) collectBytes() bool {
+	if s.byteCapacity == 0 || atomic.LoadInt32(&s.compacting) == 1 || time.Since(s.sizedAt) < dbSizeCheckInterval {
+		return true
+	}
+	s.sizedAt = time.Now()
+	size, err := s.size()
+	if err != nil {
+		log.Error("chunk store size check failed", "err", err)
+		return true
+	}
+	dbSizeGauge.Update(int64(size))
+	if size <= s.byteCapacity || s.entryCnt == 0 {
+		return true
+	}
+
+	// the chunks are assumed to take the same space on average
+	target := uint64(float64(s.entryCnt) * s.lowWater * float64(s.byteCapacity) / float64(size))
+	entries := s.entryCnt
+	log.Info("chunk store above its byte capacity, collecting garbage", "size", size, "capacity", s.byteCapacity, "entries", entries, "target", target)
+	if !s.collectTo(target) {
+		return false
+	}
+	dbSizeCollectCounter.Inc(int64(entries - s.entryCnt))
+	if err := s.flush(); err != nil {
+		log.Error("chunk store garbage collection write failed", "err", err)
+	}
+
+	atomic.StoreInt32(&s.compacting, 1)
+	supervisor.Go("ldbstore.compact", s.quit, func() {
+		defer atomic.StoreInt32(&s.compacting, 0)
+		for _, db := range s.shards {
+			if err := db.Compact(); err != nil {
+				log.Error("chunk store compaction failed", "err", err)
+				return
+			}
+		}
+		// the size is measured with the next batch
+		s.lock.Lock()
+		s.sizedAt = time.Time{}
+		s.lock.Unlock()
+	})
+	return true
+}
+
+// UsageAPI is the RPC API of the disk usage of the chunk store
+type UsageAPI struct {
+	store *LDBStore
+}
+
+// NewUsageAPI creates the disk usage API of the store
+func NewUsageAPI(store *LDBStore) *UsageAPI {
+	return &UsageAPI{store: store}
+}
+
+// StoreUsage returns the disk usage of the chunk store
+func (api *UsageAPI) StoreUsage() (*StoreUsage, error) {
+	return api.store.Usage()
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestLDBStoreByteCapacity tests that garbage is collected when the chunk
+// store exceeds its byte capacity and that the space is reclaimed
+func TestLDBStoreByteCapacity(t *testing.T) {
+	defer func(d time.Duration) { dbSizeCheckInterval = d }(dbSizeCheckInterval)
+	dbSizeCheckInterval = 0
+
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+	params.DbByteCapacity = 400 * 1024
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	n := 200
+	for _, chunk := range GenerateRandomChunks(DefaultChunkSize, n) {
+		ldb.Put(chunk)
+		if err := chunk.WaitToStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; atomic.LoadInt32(&ldb.compacting) == 1; i++ {
+		if i == 500 {
+			t.Fatal("compaction not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	u, err := ldb.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Entries == 0 || u.Entries >= uint64(n) {
+		t.Fatalf("expected garbage to be collected, got %d of %d entries", u.Entries, n)
+	}
+	if u.Bytes > params.DbByteCapacity {
+		t.Fatalf("expected at most %d bytes, got %+v", params.DbByteCapacity, u)
+	}
+	if u.Bytes != u.Tables+u.Log || u.Data == 0 || u.ByteCapacity != params.DbByteCapacity {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	socIDLength        = common.HashLength
+	socSignatureLength = 65
+	socSpanLength      = 8
+	socHeaderLength    = socIDLength + socSignatureLength + socSpanLength
+)
+
+var (
+	ErrSOCTooShort     = errors.New("single-owner chunk data too short")
+	ErrSOCPayloadSize  = errors.New("single-owner chunk payload too large")
+	ErrSOCInvalidSpan  = errors.New("single-owner chunk span does not match payload")
+	ErrSOCInvalidOwner = errors.New("single-owner chunk address does not match owner")
+)
+
+// SingleOwnerChunk is a chunk whose address is derived from the address of
+// its owner and an identifier chosen by the owner instead of its content
+//
+// Only the owner can create a valid chunk for an address, the payload is
+// signed with the key of the owner. The chunk data is:
+//
+// id|signature|span|payload
+//
+// where the signature is over keccak256(id|span|payload) and the address is
+// keccak256(id|owner).
+//
+// Chunks are immutable once stored, a sequence of updates uses a new
+// identifier for every update, see SOCID
+type SingleOwnerChunk struct {
+	ID        common.Hash
+	Owner     common.Address
+	Signature []byte
+	Payload   []byte
+}
+
+// SOCAddress returns the address of the single-owner chunk with the given
+// owner and identifier
+func SOCAddress(owner common.Address, id common.Hash) Address {
+	return Address(crypto.Keccak256(id[:], owner[:]))
+}
+
+// SOCID derives the identifier of the update with the given index of a
+// topic, updating a single-owner chunk means creating the chunk with the
+// identifier of the next index
+func SOCID(topic common.Hash, index uint64) common.Hash {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, index)
+	return crypto.Keccak256Hash(topic[:], b)
+}
+
+// NewSingleOwnerChunk creates a single-owner chunk with the given identifier
+// and payload signed with the given key
+func NewSingleOwnerChunk(id common.Hash, payload []byte, key *ecdsa.PrivateKey) (*SingleOwnerChunk, error) {
+	if int64(len(payload)) > DefaultChunkSize {
+		return nil, ErrSOCPayloadSize
+	}
+	signature, err := crypto.Sign(socDigest(id, payload).Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	return &SingleOwnerChunk{
+		ID:        id,
+		Owner:     crypto.PubkeyToAddress(key.PublicKey),
+		Signature: signature,
+		Payload:   payload,
+	}, nil
+}
+
+// Address returns the address of the chunk
+func (s *SingleOwnerChunk) Address() Address {
+	return SOCAddress(s.Owner, s.ID)
+}
+
+// Chunk returns the chunk to be stored
+func (s *SingleOwnerChunk) Chunk() *Chunk {
+	chunk := NewChunk(s.Address(), nil)
+	chunk.SData = make([]byte, socHeaderLength+len(s.Payload))
+	copy(chunk.SData, s.ID[:])
+	copy(chunk.SData[socIDLength:], s.Signature)
+	binary.LittleEndian.PutUint64(chunk.SData[socIDLength+socSignatureLength:], uint64(len(s.Payload)))
+	copy(chunk.SData[socHeaderLength:], s.Payload)
+	chunk.Size = int64(len(s.Payload))
+	return chunk
+}
+
+// ParseSingleOwnerChunk parses the data of a single-owner chunk, recovers
+// its owner and checks that the chunk is valid for the given address
+func ParseSingleOwnerChunk(addr Address, data []byte) (*SingleOwnerChunk, error) {
+	if len(data) < socHeaderLength {
+		return nil, ErrSOCTooShort
+	}
+	payload := data[socHeaderLength:]
+	if int64(len(payload)) > DefaultChunkSize {
+		return nil, ErrSOCPayloadSize
+	}
+	if span := binary.LittleEndian.Uint64(data[socIDLength+socSignatureLength:]); span != uint64(len(payload)) {
+		return nil, ErrSOCInvalidSpan
+	}
+	s := &SingleOwnerChunk{
+		ID:        common.BytesToHash(data[:socIDLength]),
+		Signature: data[socIDLength : socIDLength+socSignatureLength],
+		Payload:   payload,
+	}
+	pub, err := crypto.SigToPub(socDigest(s.ID, payload).Bytes(), s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid single-owner chunk signature: %v", err)
+	}
+	s.Owner = crypto.PubkeyToAddress(*pub)
+	if !s.Address().isEqual(addr) {
+		return nil, ErrSOCInvalidOwner
+	}
+	return s, nil
+}
+
+func socDigest(id common.Hash, payload []byte) common.Hash {
+	span := make([]byte, socSpanLength)
+	binary.LittleEndian.PutUint64(span, uint64(len(payload)))
+	return crypto.Keccak256Hash(id[:], span, payload)
+}
+
+// SOCValidator validates single-owner chunks
+type SOCValidator struct{}
+
+// NewSOCValidator returns a validator for single-owner chunks
+func NewSOCValidator() *SOCValidator {
+	return &SOCValidator{}
+}
+
+// Validate checks that the data is a single-owner chunk signed by the owner
+// the address is derived from
+func (v *SOCValidator) Validate(addr Address, data []byte) bool {
+	_, err := ParseSingleOwnerChunk(addr, data)
+	return err == nil
+}
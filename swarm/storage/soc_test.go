@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSingleOwnerChunk(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	id := SOCID(common.HexToHash("0x2a"), 0)
+	payload := []byte("single owner")
+
+	soc, err := NewSingleOwnerChunk(id, payload, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if soc.Owner != owner {
+		t.Fatalf("expected owner %x, got %x", owner, soc.Owner)
+	}
+	chunk := soc.Chunk()
+	if !bytes.Equal(chunk.Addr, SOCAddress(owner, id)) {
+		t.Fatalf("expected address %v, got %v", SOCAddress(owner, id), chunk.Addr)
+	}
+
+	parsed, err := ParseSingleOwnerChunk(chunk.Addr, chunk.SData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Owner != owner || parsed.ID != id || !bytes.Equal(parsed.Payload, payload) {
+		t.Fatalf("parsed chunk does not match: %+v", parsed)
+	}
+
+	v := NewSOCValidator()
+	if !v.Validate(chunk.Addr, chunk.SData) {
+		t.Fatal("expected valid single-owner chunk")
+	}
+
+	// a tampered payload recovers a different owner
+	tampered := make([]byte, len(chunk.SData))
+	copy(tampered, chunk.SData)
+	tampered[len(tampered)-1] ^= 0xff
+	if v.Validate(chunk.Addr, tampered) {
+		t.Fatal("expected tampered payload to be invalid")
+	}
+
+	// the chunk is only valid at the address of its owner and id
+	if v.Validate(SOCAddress(owner, SOCID(common.HexToHash("0x2a"), 1)), chunk.SData) {
+		t.Fatal("expected chunk to be invalid at another address")
+	}
+	if v.Validate(chunk.Addr, chunk.SData[:socHeaderLength-1]) {
+		t.Fatal("expected short chunk to be invalid")
+	}
+
+	if _, err := NewSingleOwnerChunk(id, make([]byte, DefaultChunkSize+1), key); err != ErrSOCPayloadSize {
+		t.Fatalf("expected %v, got %v", ErrSOCPayloadSize, err)
+	}
+}
+
+func TestSingleOwnerChunkStore(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "soc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, err := NewLocalFileStore(datadir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileStore.Close()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	soc, err := NewSingleOwnerChunk(SOCID(common.Hash{}, 0), []byte("update"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := soc.Chunk()
+	fileStore.Put(chunk)
+	if err := chunk.WaitToStore(); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := fileStore.Get(chunk.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.SData, chunk.SData) {
+		t.Fatal("stored chunk data does not match")
+	}
+
+	// a chunk signed by another key is rejected at the address
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := NewSingleOwnerChunk(soc.ID, []byte("forged"), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedChunk := forged.Chunk()
+	forgedChunk.Addr = SOCAddress(soc.Owner, soc.ID)
+	fileStore.Put(forgedChunk)
+	if err := forgedChunk.WaitToStore(); err != ErrChunkInvalid {
+		t.Fatalf("expected %v, got %v", ErrChunkInvalid, err)
+	}
+}
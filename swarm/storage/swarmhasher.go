@@ -17,15 +17,24 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"hash"
 )
 
 const (
-	BMTHash     = "BMT"
-	SHA3Hash    = "SHA3" // http://golang.org/pkg/hash/#Hash
-	DefaultHash = BMTHash
+	BMTHash        = "BMT"
+	BMTBLAKE2BHash = "BMT-BLAKE2B" // BMT over BLAKE2b-256 segments
+	SHA3Hash       = "SHA3"        // http://golang.org/pkg/hash/#Hash
+	SHA256Hash     = "SHA256"
+	BLAKE2BHash    = "BLAKE2B" // BLAKE2b-256
+	DefaultHash    = BMTHash
 )
 
+// SupportedHashes are the chunk hash functions which can be selected per
+// upload, chunks addressed by any of them are valid content addressed chunks
+var SupportedHashes = []string{BMTHash, BMTBLAKE2BHash, SHA3Hash, SHA256Hash, BLAKE2BHash}
+
 type SwarmHash interface {
 	hash.Hash
 	ResetWithLength([]byte)
@@ -39,3 +48,30 @@ func (h *HashWithLength) ResetWithLength(length []byte) {
 	h.Reset()
 	h.Write(length)
 }
+
+type hashKey struct{}
+
+// WithHash returns a context carrying the name of the chunk hash function the
+// content retrieved with it is addressed with, such as the hash function of
+// the manifest the content is listed in
+func WithHash(ctx context.Context, hash string) context.Context {
+	return context.WithValue(ctx, hashKey{}, hash)
+}
+
+// HashFromContext returns the name of the chunk hash function carried by the
+// context or the empty string
+func HashFromContext(ctx context.Context) string {
+	hash, _ := ctx.Value(hashKey{}).(string)
+	return hash
+}
+
+// SupportedHashFunc returns the hash function with the given name if it is
+// one of SupportedHashes
+func SupportedHashFunc(hash string) (SwarmHasher, error) {
+	for _, h := range SupportedHashes {
+		if h == hash {
+			return MakeHashFunc(hash), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported hash function %q", hash)
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// schemaSyncIndex is the schema version of the chunk store from which on the
+// sync index is maintained
+const schemaSyncIndex = 1
+
+// syncIndexBatchSize is the number of sync index entries written in a batch
+// when the index is backfilled
+const syncIndexBatchSize = 10000
+
+var syncIndexBackfillCounter = metrics.NewRegisteredCounter("ldbstore.syncindex.backfill", nil)
+
+// The sync index maps the proximity bin and the storage index of a chunk,
+// which orders the chunks of a bin by the time they were stored, to its
+// address and store time. Offers of a bin are generated by a range scan of
+// the index without reading the chunk data.
+func getSyncIdxKey(idx uint64, po uint8) []byte {
+	key := make([]byte, 10)
+	key[0] = keySyncIdx
+	key[1] = po
+	binary.BigEndian.PutUint64(key[2:], idx)
+	return key
+}
+
+func encodeSyncIdx(addr Address, storedAt time.Time) []byte {
+	val := make([]byte, 40)
+	copy(val, addr[:32])
+	binary.BigEndian.PutUint64(val[32:], uint64(storedAt.UnixNano()))
+	return val
+}
+
+// decodeSyncIdx returns the address and the store time of a sync index
+// entry, the store time of backfilled entries is zero
+func decodeSyncIdx(val []byte) (Address, time.Time) {
+	addr := make([]byte, 32)
+	copy(addr, val[:32])
+	var storedAt time.Time
+	if ns := binary.BigEndian.Uint64(val[32:]); ns > 0 {
+		storedAt = time.Unix(0, int64(ns))
+	}
+	return Address(addr), storedAt
+}
+
+// migrate upgrades the schema of the store, stores created before the sync
+// index have it backfilled from their data entries
+func (s *LDBStore) migrate() error {
+	data, _ := s.db.Get(keySchema)
+	schema := BytesToU64(data)
+	if schema >= schemaSyncIndex {
+		return nil
+	}
+
+	var count int
+	batch := new(leveldb.Batch)
+	for _, db := range s.shards {
+		n, err := s.backfillSyncIdx(db, batch)
+		if err != nil {
+			return err
+		}
+		count += n
+	}
+	batch.Put(keySchema, U64ToBytes(schemaSyncIndex))
+	if err := s.db.Write(batch); err != nil {
+		return err
+	}
+	syncIndexBackfillCounter.Inc(int64(count))
+	if count > 0 {
+		log.Info("backfilled chunk store sync index", "entries", count)
+	}
+	return nil
+}
+
+// backfillSyncIdx adds the sync index entries of the chunks with their data
+// in the given database to the batch, full batches are written
+func (s *LDBStore) backfillSyncIdx(db *LDBDatabase, batch *leveldb.Batch) (int, error) {
+	var count int
+	it := db.NewIterator()
+	for ok := it.Seek([]byte{keyData}); ok; ok = it.Next() {
+		key := it.Key()
+		if key[0] != keyData {
+			break
+		}
+		if len(key) != 10 || len(it.Value()) < 32 {
+			continue
+		}
+		batch.Put(getSyncIdxKey(binary.BigEndian.Uint64(key[2:]), key[1]), encodeSyncIdx(Address(it.Value()[:32]), time.Time{}))
+		count++
+		if batch.Len() >= syncIndexBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				it.Release()
+				return count, err
+			}
+			batch.Reset()
+		}
+	}
+	it.Release()
+	return count, it.Error()
+}
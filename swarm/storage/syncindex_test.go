@@ -0,0 +1,96 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSyncIndexBackfill tests that the sync index of a store created before
+// it existed is backfilled when the store is opened
+func TestSyncIndexBackfill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := NewLDBStoreParams(NewDefaultStoreParams(), dir)
+
+	ldb, err := NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 50
+	start := time.Now()
+	chunks := GenerateRandomChunks(DefaultChunkSize, n)
+	for _, chunk := range chunks {
+		ldb.Put(chunk)
+	}
+	for _, chunk := range chunks {
+		<-chunk.dbStoredC
+	}
+
+	synced := func(ldb *LDBStore) map[string]uint64 {
+		addrs := make(map[string]uint64)
+		for po := 0; po <= MaxPO; po++ {
+			err := ldb.SyncIterator(0, ldb.dataIdx, uint8(po), func(addr Address, idx uint64) bool {
+				addrs[addr.Hex()] = idx
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return addrs
+	}
+	indexed := synced(ldb)
+	if len(indexed) != n {
+		t.Fatalf("expected %d chunks in the sync index, got %d", n, len(indexed))
+	}
+	it := ldb.db.NewIterator()
+	for ok := it.Seek([]byte{keySyncIdx}); ok && it.Key()[0] == keySyncIdx; ok = it.Next() {
+		if _, storedAt := decodeSyncIdx(it.Value()); storedAt.Before(start) {
+			t.Fatalf("expected store time after %v, got %v", start, storedAt)
+		}
+		ldb.db.Delete(append([]byte{}, it.Key()...))
+	}
+	it.Release()
+	ldb.db.Delete(keySchema)
+	ldb.Close()
+
+	ldb, err = NewLDBStore(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	backfilled := synced(ldb)
+	if len(backfilled) != n {
+		t.Fatalf("expected %d chunks in the backfilled sync index, got %d", n, len(backfilled))
+	}
+	for addr, idx := range indexed {
+		if backfilled[addr] != idx {
+			t.Fatalf("chunk %s: expected storage index %d, got %d", addr, idx, backfilled[addr])
+		}
+	}
+	data, err := ldb.db.Get(keySchema)
+	if err != nil || BytesToU64(data) != schemaSyncIndex {
+		t.Fatalf("expected schema %d, got %d (%v)", schemaSyncIndex, BytesToU64(data), err)
+	}
+}
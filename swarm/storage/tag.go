@@ -0,0 +1,235 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is a state of the chunks of an operation which is counted by a tag
+type State int
+
+const (
+	StateTotal  State = iota // chunks of the content known so far
+	StateStored              // chunks stored in or retrieved into the local store
+	StateSent                // chunks delivered to a peer
+	StateSynced              // chunks delivered to a peer closer to their address
+	StateFailed              // chunks which could not be retrieved or stored
+	numStates
+)
+
+// tagTTL is the time finished tags are kept in the registry
+const tagTTL = 10 * time.Minute
+
+type tagKey struct{}
+
+// WithTag returns a context carrying the tag, the chunks put and retrieved
+// by a FileStore with the context are counted by the tag
+func WithTag(ctx context.Context, tag *Tag) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag)
+}
+
+// TagFromContext returns the tag carried by the context or nil
+func TagFromContext(ctx context.Context) *Tag {
+	tag, _ := ctx.Value(tagKey{}).(*Tag)
+	return tag
+}
+
+// Tag counts the chunks of an operation on the content with the given
+// address by their state so that its progress can be followed, the address
+// of an upload is only known when it is finished
+type Tag struct {
+	Uid       uint32
+	Name      string
+	StartedAt time.Time
+
+	counters [numStates]int64
+
+	mu       sync.RWMutex
+	addr     Address
+	doneC    chan struct{}
+	doneOnce sync.Once
+	doneAt   time.Time
+	err      error
+}
+
+// NewTag creates a tag with the given uid and name
+func NewTag(uid uint32, name string, addr Address) *Tag {
+	return &Tag{
+		Uid:       uid,
+		Name:      name,
+		StartedAt: time.Now(),
+		addr:      addr,
+		doneC:     make(chan struct{}),
+	}
+}
+
+// Address returns the address of the content of the tag
+func (t *Tag) Address() Address {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.addr
+}
+
+// SetAddress sets the address of the content of the tag
+func (t *Tag) SetAddress(addr Address) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addr = addr
+}
+
+// Inc increments the count of chunks in the given state, a nil tag counts
+// nothing
+func (t *Tag) Inc(state State) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.counters[state], 1)
+}
+
+// Get returns the count of chunks in the given state
+func (t *Tag) Get(state State) int64 {
+	return atomic.LoadInt64(&t.counters[state])
+}
+
+// Done marks the operation of the tag finished with the given error, only
+// the first call has an effect
+func (t *Tag) Done(err error) {
+	t.doneOnce.Do(func() {
+		t.mu.Lock()
+		t.err = err
+		t.doneAt = time.Now()
+		t.mu.Unlock()
+		close(t.doneC)
+	})
+}
+
+// IsDone returns true if the operation of the tag is finished
+func (t *Tag) IsDone() bool {
+	select {
+	case <-t.doneC:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait returns the error of the operation of the tag when it is finished
+func (t *Tag) Wait(ctx context.Context) error {
+	select {
+	case <-t.doneC:
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MarshalJSON encodes the tag with a snapshot of its counters
+func (t *Tag) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Uid       uint32    `json:"uid"`
+		Name      string    `json:"name"`
+		Address   string    `json:"address"`
+		StartedAt time.Time `json:"startedAt"`
+		Total     int64     `json:"total"`
+		Stored    int64     `json:"stored"`
+		Sent      int64     `json:"sent"`
+		Synced    int64     `json:"synced"`
+		Failed    int64     `json:"failed"`
+		Done      bool      `json:"done"`
+		Error     string    `json:"error,omitempty"`
+	}{
+		Uid:       t.Uid,
+		Name:      t.Name,
+		Address:   t.Address().Hex(),
+		StartedAt: t.StartedAt,
+		Total:     t.Get(StateTotal),
+		Stored:    t.Get(StateStored),
+		Sent:      t.Get(StateSent),
+		Synced:    t.Get(StateSynced),
+		Failed:    t.Get(StateFailed),
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.IsDone() {
+		v.Done = true
+		if t.err != nil {
+			v.Error = t.err.Error()
+		}
+	}
+	return json.Marshal(v)
+}
+
+// Tags holds the tags of the operations of a node by their uid, finished
+// tags are removed after tagTTL
+type Tags struct {
+	tags sync.Map
+	uid  uint32
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+// NewTags creates an empty tag registry
+func NewTags() *Tags {
+	return &Tags{}
+}
+
+// New creates a tag with a new uid and adds it to the registry
+func (ts *Tags) New(name string, addr Address) *Tag {
+	ts.prune()
+	t := NewTag(atomic.AddUint32(&ts.uid, 1), name, addr)
+	ts.tags.Store(t.Uid, t)
+	return t
+}
+
+// prune removes the tags finished more than tagTTL ago, at most once a
+// minute
+func (ts *Tags) prune() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	now := time.Now()
+	if now.Sub(ts.prunedAt) < time.Minute {
+		return
+	}
+	ts.prunedAt = now
+	ts.tags.Range(func(k, v interface{}) bool {
+		t := v.(*Tag)
+		t.mu.RLock()
+		expired := t.IsDone() && now.Sub(t.doneAt) > tagTTL
+		t.mu.RUnlock()
+		if expired {
+			ts.tags.Delete(k)
+		}
+		return true
+	})
+}
+
+// Get returns the tag with the given uid
+func (ts *Tags) Get(uid uint32) (*Tag, bool) {
+	t, ok := ts.tags.Load(uid)
+	if !ok {
+		return nil, false
+	}
+	return t.(*Tag), true
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// TestTagCountsFileStore tests that the chunks stored and retrieved by a
+// FileStore are counted by the tag of the context
+func TestTagCountsFileStore(t *testing.T) {
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, NewFileStoreParams())
+	tags := NewTags()
+
+	upload := tags.New("upload", nil)
+	ctx := WithTag(context.TODO(), upload)
+	size := int64(DefaultChunkSize*130 + 10)
+	reader, _ := generateRandomData(int(size))
+	addr, wait, err := fileStore.Store(ctx, reader, size, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	upload.SetAddress(addr)
+	upload.Done(nil)
+
+	// 131 data chunks, 2 intermediate chunks and the root chunk
+	n := int64(len(store.chunks))
+	if n != 134 {
+		t.Fatalf("expected 134 chunks in the store, got %d", n)
+	}
+	if upload.Get(StateTotal) != n || upload.Get(StateStored) != n || upload.Get(StateFailed) != 0 {
+		t.Fatalf("expected %d chunks stored, got total %d, stored %d, failed %d", n, upload.Get(StateTotal), upload.Get(StateStored), upload.Get(StateFailed))
+	}
+	for _, chunk := range store.chunks {
+		if chunk.Tag != upload {
+			t.Fatalf("expected chunk %v to be tagged", chunk.Addr)
+		}
+	}
+
+	download := tags.New("download", addr)
+	reader2, _ := fileStore.Retrieve(WithTag(context.TODO(), download), addr)
+	if _, err := io.Copy(ioutil.Discard, io.NewSectionReader(reader2, 0, size)); err != nil {
+		t.Fatal(err)
+	}
+	if download.Get(StateStored) != n || download.Get(StateTotal) != n {
+		t.Fatalf("expected %d chunks retrieved, got total %d, stored %d", n, download.Get(StateTotal), download.Get(StateStored))
+	}
+
+	got, ok := tags.Get(upload.Uid)
+	if !ok || got != upload {
+		t.Fatalf("expected tag %d in the registry", upload.Uid)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		Address string
+		Stored  int64
+		Done    bool
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Address != addr.Hex() || state.Stored != n || !state.Done {
+		t.Fatalf("unexpected tag state %s", data)
+	}
+}
+
+// TestChunkIncTag tests that a chunk is counted in a state of its tag once
+func TestChunkIncTag(t *testing.T) {
+	chunk := GenerateRandomChunk(DefaultChunkSize)
+	chunk.IncTag(StateSent)
+
+	chunk.Tag = NewTag(1, "upload", chunk.Addr)
+	for i := 0; i < 3; i++ {
+		chunk.IncTag(StateSent)
+		chunk.IncTag(StateSynced)
+	}
+	if sent, synced := chunk.Tag.Get(StateSent), chunk.Tag.Get(StateSynced); sent != 1 || synced != 1 {
+		t.Fatalf("expected the chunk to be counted once, got sent %d, synced %d", sent, synced)
+	}
+}
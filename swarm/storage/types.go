@@ -26,10 +26,14 @@ import (
 	"hash"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/swarm/bmt"
+	"golang.org/x/crypto/blake2b"
 )
 
 const MaxPO = 16
@@ -105,18 +109,32 @@ func IsZeroAddr(addr Address) bool {
 
 var ZeroAddr = Address(common.Hash{}.Bytes())
 
+// newBlake2b returns an unkeyed BLAKE2b-256 hash, which cannot fail to be
+// created as only a key longer than 64 bytes is rejected
+func newBlake2b() hash.Hash {
+	h, _ := blake2b.New256(nil)
+	return h
+}
+
 func MakeHashFunc(hash string) SwarmHasher {
 	switch hash {
-	case "SHA256":
+	case SHA256Hash:
 		return func() SwarmHash { return &HashWithLength{crypto.SHA256.New()} }
-	case "SHA3":
+	case SHA3Hash:
 		return func() SwarmHash { return &HashWithLength{sha3.NewKeccak256()} }
-	case "BMT":
+	case BLAKE2BHash:
+		return func() SwarmHash { return &HashWithLength{newBlake2b()} }
+	case BMTHash:
 		return func() SwarmHash {
 			hasher := sha3.NewKeccak256
 			pool := bmt.NewTreePool(hasher, bmt.SegmentCount, bmt.PoolSize)
 			return bmt.New(pool)
 		}
+	case BMTBLAKE2BHash:
+		return func() SwarmHash {
+			pool := bmt.NewTreePool(newBlake2b, bmt.SegmentCount, bmt.PoolSize)
+			return bmt.New(pool)
+		}
 	}
 	return nil
 }
@@ -183,6 +201,37 @@ type Chunk struct {
 	dbStoredMu *sync.Mutex
 	errored    error // flag which is set when the chunk request has errored or timeouted
 	erroredMu  sync.Mutex
+	Tag        *Tag                   // tag of the operation which put the chunk, nil if untagged
+	Forwarded  bool                   // requested on behalf of a peer, stored in the forwarding cache if enabled
+	tagStates  uint32                 // bit set of the states the chunk is counted in by its tag
+	acceptedAt time.Time              // time the put of the chunk was accepted, zero if not timed
+	storeTimer metrics.ResettingTimer // records the time from acceptedAt to the store confirmation
+}
+
+// timeStore records the time from the acceptance of the put of the chunk to
+// its store confirmation in the timer of the backend storing it
+func (c *Chunk) timeStore(timer metrics.ResettingTimer, acceptedAt time.Time) {
+	c.acceptedAt = acceptedAt
+	c.storeTimer = timer
+}
+
+// IncTag counts the chunk in the given state of its tag, a chunk is counted
+// in each state at most once
+func (c *Chunk) IncTag(state State) {
+	if c.Tag == nil {
+		return
+	}
+	bit := uint32(1) << uint(state)
+	for {
+		states := atomic.LoadUint32(&c.tagStates)
+		if states&bit != 0 {
+			return
+		}
+		if atomic.CompareAndSwapUint32(&c.tagStates, states, states|bit) {
+			c.Tag.Inc(state)
+			return
+		}
+	}
 }
 
 func (c *Chunk) SetErrored(err error) {
@@ -215,6 +264,9 @@ func (c *Chunk) markAsStored() {
 	if !c.dbStored {
 		close(c.dbStoredC)
 		c.dbStored = true
+		if c.storeTimer != nil {
+			c.storeTimer.UpdateSince(c.acceptedAt)
+		}
 	}
 }
 
@@ -267,9 +319,13 @@ func (r *LazyTestSectionReader) Size(chan bool) (int64, error) {
 type StoreParams struct {
 	Hash                       SwarmHasher `toml:"-"`
 	DbCapacity                 uint64
+	DbByteCapacity             uint64  // size of the chunk DB on disk garbage is collected above, 0 if unlimited
+	DbLowWater                 float64 // ratio of DbByteCapacity garbage is collected down to
 	CacheCapacity              uint
 	ChunkRequestsCacheCapacity uint
 	BaseKey                    []byte
+	Compress                   bool   // store the chunk data compressed if it is compressible
+	EncryptionKey              []byte `toml:"-"` // key the chunk data is encrypted with at rest, empty to store it in plain
 }
 
 func NewDefaultStoreParams() *StoreParams {
@@ -286,6 +342,7 @@ func NewStoreParams(ldbCap uint64, cacheCap uint, requestsCap uint, hash SwarmHa
 	return &StoreParams{
 		Hash:                       hash,
 		DbCapacity:                 ldbCap,
+		DbLowWater:                 DefaultDbLowWater,
 		CacheCapacity:              cacheCap,
 		ChunkRequestsCacheCapacity: requestsCap,
 		BaseKey:                    basekey,
@@ -329,18 +386,69 @@ type ChunkValidator interface {
 // Holds the corresponding hasher to create the address
 type ContentAddressValidator struct {
 	Hasher SwarmHasher
+	// Alternatives are further hash functions accepted for content addresses
+	Alternatives []SwarmHasher
 }
 
 // Constructor
-func NewContentAddressValidator(hasher SwarmHasher) *ContentAddressValidator {
+func NewContentAddressValidator(hasher SwarmHasher, alternatives ...SwarmHasher) *ContentAddressValidator {
 	return &ContentAddressValidator{
-		Hasher: hasher,
+		Hasher:       hasher,
+		Alternatives: alternatives,
+	}
+}
+
+// NewMultiHashValidator returns a validator accepting content addresses of
+// any of the given hash functions. The first one is tried first and the
+// others only if it does not match, so the default hash should be first.
+func NewMultiHashValidator(hashes ...string) (*ContentAddressValidator, error) {
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("no hash functions")
+	}
+	hashers := make([]SwarmHasher, len(hashes))
+	for i, hash := range hashes {
+		if hashers[i] = MakeHashFunc(hash); hashers[i] == nil {
+			return nil, fmt.Errorf("unknown hash function %q", hash)
+		}
 	}
+	return NewContentAddressValidator(hashers[0], hashers[1:]...), nil
 }
 
 // Validate that the given key is a valid content address for the given data
 func (v *ContentAddressValidator) Validate(addr Address, data []byte) bool {
-	hasher := v.Hasher()
+	if validContentAddress(v.Hasher, addr, data) {
+		return true
+	}
+	for _, hasher := range v.Alternatives {
+		if validContentAddress(hasher, addr, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashValidator is a validator of content addresses which can be told the
+// hash function a chunk is expected to be addressed with
+type hashValidator interface {
+	ValidateHash(addr Address, data []byte, hash string) bool
+}
+
+// ValidateHash validates the content address with the given hash function,
+// and like Validate only if it does not match, so that the chunks of content
+// known to be stored with another hash function than the default one are
+// hashed once
+func (v *ContentAddressValidator) ValidateHash(addr Address, data []byte, hash string) bool {
+	if hasher := MakeHashFunc(hash); hasher != nil && validContentAddress(hasher, addr, data) {
+		return true
+	}
+	return v.Validate(addr, data)
+}
+
+func validContentAddress(hasherFunc SwarmHasher, addr Address, data []byte) bool {
+	hasher := hasherFunc()
+	if len(addr) != hasher.Size() {
+		return false
+	}
 	hasher.ResetWithLength(data[:8])
 	hasher.Write(data[8:])
 	hash := hasher.Sum(nil)
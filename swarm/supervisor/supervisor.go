@@ -0,0 +1,82 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package supervisor runs the long-running goroutines of the storage and
+// network layers so that a panic of one of them is logged and the goroutine
+// restarted instead of crashing the node
+package supervisor
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+var (
+	// minBackoff is the time a worker is restarted after its first panic
+	minBackoff = 100 * time.Millisecond
+	// maxBackoff is the maximum time a panicking worker is restarted after,
+	// the backoff is reset once the worker runs longer than maxBackoff
+	maxBackoff = 30 * time.Second
+)
+
+var panicCounter = metrics.NewRegisteredCounter("supervisor.panic", nil)
+
+// Run calls the worker until it returns without panicking or quit is closed.
+// A panic of the worker is recovered and logged with its stack trace, and
+// the worker is called again after a backoff doubling with each panic.
+func Run(name string, quit <-chan struct{}, worker func()) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		if !call(name, worker) {
+			return
+		}
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		log.Warn("restarting worker", "name", name, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-quit:
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Go runs the worker supervised by Run in a goroutine
+func Go(name string, quit <-chan struct{}, worker func()) {
+	go Run(name, quit, worker)
+}
+
+// call calls the worker and returns true if it panicked
+func call(name string, worker func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			panicCounter.Inc(1)
+			metrics.GetOrRegisterCounter("supervisor.panic."+name, nil).Inc(1)
+			log.Error("worker panicked", "name", name, "err", r, "stack", string(debug.Stack()))
+		}
+	}()
+	worker()
+	return false
+}
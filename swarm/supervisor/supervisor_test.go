@@ -0,0 +1,66 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func init() {
+	minBackoff = time.Millisecond
+}
+
+// TestRun tests that a panicking worker is restarted until it returns
+func TestRun(t *testing.T) {
+	var calls int
+	Run("test", nil, func() {
+		calls++
+		if calls < 3 {
+			panic("worker failed")
+		}
+	})
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+// TestRunQuit tests that a panicking worker is not restarted once quit is
+// closed
+func TestRunQuit(t *testing.T) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	var calls int
+	go func() {
+		defer close(done)
+		Run("test", quit, func() {
+			calls++
+			if calls == 2 {
+				close(quit)
+			}
+			panic("worker failed")
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the worker not to be restarted")
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
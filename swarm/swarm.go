@@ -20,9 +20,12 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"net"
+	"net/http"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -76,6 +79,11 @@ type Swarm struct {
 	lstore      *storage.LocalStore // local store, needs to store for releasing resources after node stopped
 	sfs         *fuse.SwarmFS       // need this to cleanup all the active mounts on node exit
 	ps          *pss.Pss
+	stateStore  state.Store
+	httpServer  *http.Server     // HTTP API server, shut down first on node exit
+	s3Server    *http.Server     // S3 compatible facade, shut down with the HTTP API server
+	blocklist   *api.Blocklist   // roots taken down by the operator
+	signingKeys *api.SigningKeys // keys the write requests of the HTTP API are signed with
 }
 
 type SwarmAPI struct {
@@ -139,12 +147,14 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		OverlayAddr:  addr.OAddr,
 		UnderlayAddr: addr.UAddr,
 		HiveParams:   config.HiveParams,
+		Capabilities: capabilities(config),
 	}
 
 	stateStore, err := state.NewDBStore(filepath.Join(config.Path, "state-store.db"))
 	if err != nil {
 		return
 	}
+	self.stateStore = stateStore
 
 	// set up high level api
 	var resolver *api.MultiResolver
@@ -163,29 +173,56 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		self.dns = resolver
 	}
 
+	if config.EncryptStore || config.StoreKeyCommand != "" {
+		if config.LocalStoreParams.EncryptionKey, err = storeKey(config.StoreKeyCommand, self.privateKey); err != nil {
+			return nil, fmt.Errorf("chunk store key: %v", err)
+		}
+	}
 	self.lstore, err = storage.NewLocalStore(config.LocalStoreParams, mockStore)
 	if err != nil {
 		return
 	}
 
 	db := storage.NewDBAPI(self.lstore)
+	kp := network.NewKadParams()
+	kp.MinBinSize = config.MinBinSize
+	kp.MaxBinSize = config.MaxBinSize
+	kp.MaxPeers = config.MaxBzzPeers
+	kp.Prune = config.MaxBinSize > 0 || config.MaxBzzPeers > 0
 	to := network.NewKademlia(
 		common.FromHex(config.BzzKey),
-		network.NewKadParams(),
+		kp,
 	)
 	delivery := stream.NewDelivery(to, db)
 
-	self.streamer = stream.NewRegistry(addr, delivery, db, stateStore, &stream.RegistryOptions{
+	registryOptions := &stream.RegistryOptions{
 		SkipCheck:       config.DeliverySkipCheck,
 		DoSync:          config.SyncEnabled,
 		DoRetrieve:      true,
 		SyncUpdateDelay: config.SyncUpdateDelay,
-	})
+		SyncBatchSize:   config.SyncBatchSize,
+		OfferWindow:     config.SyncOfferWindow,
+		BinConcurrency:  config.SyncConcurrency,
+		RetrieveFanout:  config.RetrieveFanout,
+		Compression:     config.DeliveryCompression,
+	}
+	if config.DeliveryReceipts {
+		registryOptions.ReceiptKey = self.privateKey
+	}
+	self.streamer = stream.NewRegistry(addr, delivery, db, stateStore, registryOptions)
 
 	// set up NetStore, the cloud storage local access layer
 	netStore := storage.NewNetStore(self.lstore, self.streamer.Retrieve)
+	// chunks which cannot be retrieved from the network are retrieved from
+	// the trusted gateways if any are configured
+	var middlewares []storage.Middleware
+	if len(config.FallbackGateways) > 0 {
+		middlewares = append(middlewares, storage.RemoteFallback(config.FallbackGateways, config.FallbackTimeout))
+		log.Info("retrieving chunks from fallback gateways", "gateways", config.FallbackGateways, "timeout", config.FallbackTimeout)
+	}
+	chunkStore := storage.Wrap(netStore, middlewares...)
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
-	self.fileStore = storage.NewFileStore(netStore, self.config.FileStoreParams)
+	self.fileStore = storage.NewFileStore(chunkStore, self.config.FileStoreParams)
 
 	var resourceHandler *mru.Handler
 	rhparams := &mru.HandlerParams{
@@ -213,8 +250,13 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	}
 	resourceHandler.SetStore(netStore)
 
+	// content addressed chunks may be hashed with any of the supported hash functions
+	contentValidator, err := storage.NewMultiHashValidator(storage.SupportedHashes...)
+	if err != nil {
+		return nil, err
+	}
 	var validators []storage.ChunkValidator
-	validators = append(validators, storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash)))
+	validators = append(validators, contentValidator, storage.NewSOCValidator())
 	if resourceHandler != nil {
 		validators = append(validators, resourceHandler)
 	}
@@ -223,18 +265,50 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	// setup local store
 	log.Debug(fmt.Sprintf("Set up local storage"))
 
-	self.bzz = network.NewBzz(bzzconfig, to, stateStore, stream.Spec, self.streamer.Run)
+	self.bzz = network.NewBzz(bzzconfig, to, stateStore, stream.Versions, self.streamer.Run)
 
 	// Pss = postal service over swarm (devp2p over bzz)
-	self.ps, err = pss.NewPss(to, config.Pss)
+	if config.PssEnabled {
+		self.ps, err = pss.NewPss(to, config.Pss.WithStateStore(stateStore))
+		if err != nil {
+			return nil, err
+		}
+		if pss.IsActiveHandshake {
+			pss.SetHandshakeController(self.ps, pss.NewHandshakeParams())
+		}
+	}
+
+	self.api = api.NewAPI(self.fileStore, self.dns, resourceHandler)
+	self.api.SetInlineThreshold(config.InlineThreshold)
+	self.api.SetManifestFanout(config.ManifestFanout)
+	self.api.SetPrefetchConcurrency(config.PrefetchConcurrency)
+	self.api.SetAccessKey(self.privateKey)
+	mimeTypes, err := api.ParseMimeTypes(config.MimeTypes)
+	if err != nil {
+		return nil, err
+	}
+	self.api.SetMimeDetector(&api.MimeDetector{
+		Types:   mimeTypes,
+		Sniff:   config.MimeSniff,
+		Default: config.MimeDefault,
+	})
+	if config.NameRegistry != "" {
+		self.api.SetNameRegistry(common.FromHex(config.NameRegistry))
+		log.Info("resolving names with the name registry", "addr", config.NameRegistry)
+	}
+	if config.ManifestVersion != 0 {
+		if err := self.api.SetManifestVersion(config.ManifestVersion); err != nil {
+			return nil, err
+		}
+	}
+	self.blocklist, err = api.NewBlocklist(self.api, self.stateStore)
 	if err != nil {
 		return nil, err
 	}
-	if pss.IsActiveHandshake {
-		pss.SetHandshakeController(self.ps, pss.NewHandshakeParams())
+	self.signingKeys, err = api.NewSigningKeys(self.stateStore)
+	if err != nil {
+		return nil, err
 	}
-
-	self.api = api.NewAPI(self.fileStore, self.dns, resourceHandler)
 	// Manifests for Smart Hosting
 	log.Debug(fmt.Sprintf("-> Web3 virtual server API"))
 
@@ -279,6 +353,27 @@ type ensClient struct {
 // newEnsClient creates a new ENS client for that is a consumer of
 // a ENS API on a specific endpoint. It is used as a helper function
 // for creating multiple resolvers in NewSwarm function.
+// storeKey returns the key the chunk data is encrypted with at rest, it is
+// printed by the key command, so that it can be kept in an external key
+// management service, or derived from the node key
+func storeKey(command string, prvKey *ecdsa.PrivateKey) ([]byte, error) {
+	if command == "" {
+		return storage.DeriveStoreKey(prvKey), nil
+	}
+	out, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(out)), "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+	return key, nil
+}
+
 func newEnsClient(endpoint string, addr common.Address, config *api.Config, privkey *ecdsa.PrivateKey) (*ensClient, error) {
 	log.Info("connecting to ENS API", "url", endpoint)
 	client, err := rpc.Dial(endpoint)
@@ -386,16 +481,56 @@ func (self *Swarm) Start(srv *p2p.Server) error {
 	}
 
 	// start swarm http proxy server
-	if self.config.Port != "" {
-		addr := net.JoinHostPort(self.config.ListenAddr, self.config.Port)
-		go httpapi.StartHTTPServer(self.api, &httpapi.ServerConfig{
-			Addr:       addr,
-			CorsString: self.config.Cors,
+	if self.config.Port != "" || self.config.HTTPSocket != "" {
+		var addr string
+		if self.config.Port != "" {
+			addr = net.JoinHostPort(self.config.ListenAddr, self.config.Port)
+		}
+		self.httpServer, err = httpapi.StartHTTPServer(self.api, &httpapi.ServerConfig{
+			Addr:                addr,
+			CorsString:          self.config.Cors,
+			AccessLog:           self.config.AccessLog,
+			AnonymizeIPs:        self.config.AnonymizeIPs,
+			TLSCert:             self.config.TLSCert,
+			TLSKey:              self.config.TLSKey,
+			Socket:              self.config.HTTPSocket,
+			ReadOnly:            self.config.GatewayReadOnly,
+			Allow:               self.config.GatewayAllow,
+			Deny:                self.config.GatewayDeny,
+			DenyListURL:         self.config.GatewayDenyList,
+			DenyListSigner:      common.HexToAddress(self.config.GatewayDenySigner),
+			Blocklist:           self.blocklist,
+			TakedownToken:       self.config.TakedownToken,
+			GatewayDomains:      self.config.GatewayDomains,
+			TLSWildcardCert:     self.config.TLSWildcardCert,
+			TLSWildcardKey:      self.config.TLSWildcardKey,
+			SigningKeys:         self.signingKeys,
+			RequireSignedWrites: self.config.RequireSignedWrites,
 		})
+		if err != nil {
+			return err
+		}
 	}
 
 	log.Debug(fmt.Sprintf("Swarm http proxy started on port: %v", self.config.Port))
 
+	// start the S3 compatible facade
+	if self.config.S3Port != "" {
+		addr := net.JoinHostPort(self.config.ListenAddr, self.config.S3Port)
+		self.s3Server, err = httpapi.StartS3Server(self.api, self.stateStore, addr)
+		if err != nil {
+			return fmt.Errorf("cannot start the S3 facade: %v", err)
+		}
+		log.Info(fmt.Sprintf("Swarm S3 facade started on port: %v", self.config.S3Port))
+	}
+
+	// start the restic REST backend
+	if self.config.ResticPort != "" {
+		addr := net.JoinHostPort(self.config.ListenAddr, self.config.ResticPort)
+		httpapi.StartResticServer(self.api, self.stateStore, addr)
+		log.Info(fmt.Sprintf("Swarm restic backend started on port: %v", self.config.ResticPort))
+	}
+
 	if self.config.Cors != "" {
 		log.Debug(fmt.Sprintf("Swarm http proxy started with corsdomain: %v", self.config.Cors))
 	}
@@ -404,9 +539,28 @@ func (self *Swarm) Start(srv *p2p.Server) error {
 
 	startCounter.Inc(1)
 	self.streamer.Start(srv)
+	self.resyncLostBins()
 	return nil
 }
 
+// resyncLostBins resyncs the proximity bins with chunks lost in the recovery
+// of a corrupted chunk database once the node is connected to its neighbourhood
+func (self *Swarm) resyncLostBins() {
+	r := self.lstore.DbStore.Recovery()
+	if r == nil || len(r.LostBins) == 0 {
+		return
+	}
+	if !self.config.ResyncLostBins {
+		log.Warn("chunks lost in the recovery of the chunk database are not resynced", "bins", r.LostBins)
+		return
+	}
+	go func() {
+		time.Sleep(self.config.SyncUpdateDelay)
+		log.Info("resyncing bins lost in the recovery of the chunk database", "bins", r.LostBins)
+		self.streamer.Resync(r.LostBins)
+	}()
+}
+
 func (self *Swarm) periodicallyUpdateGauges() {
 	ticker := time.NewTicker(updateGaugesPeriod)
 
@@ -424,7 +578,33 @@ func (self *Swarm) updateGauges() {
 
 // implements the node.Service interface
 // stops all component services.
+//
+// New requests and uploads are not accepted and the ones in flight are
+// waited for at most the shutdown grace period, the peers and the sync
+// intervals are saved in the state store by the hive and the streamer, and
+// the chunk store is flushed and closed last.
 func (self *Swarm) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), self.config.ShutdownGracePeriod)
+	defer cancel()
+
+	if self.httpServer != nil {
+		if err := self.httpServer.Shutdown(ctx); err != nil {
+			log.Warn("requests in flight not served on shutdown", "err", err)
+			self.httpServer.Close()
+		}
+	}
+	if self.s3Server != nil {
+		if err := self.s3Server.Shutdown(ctx); err != nil {
+			log.Warn("s3 requests in flight not served on shutdown", "err", err)
+			self.s3Server.Close()
+		}
+	}
+	self.sfs.Stop()
+	self.streamer.Stop()
+	if err := self.fileStore.Drain(ctx); err != nil {
+		log.Warn("uploads in flight not stored on shutdown", "err", err)
+	}
+
 	if self.ps != nil {
 		self.ps.Stop()
 	}
@@ -432,14 +612,13 @@ func (self *Swarm) Stop() error {
 		ch.Stop()
 		ch.Save()
 	}
+	stopCounter.Inc(1)
+	err := self.bzz.Stop()
 
 	if self.lstore != nil {
-		self.lstore.DbStore.Close()
+		self.lstore.Close()
 	}
-	self.sfs.Stop()
-	stopCounter.Inc(1)
-	self.streamer.Stop()
-	return self.bzz.Stop()
+	return err
 }
 
 // implements the node.Service interface
@@ -456,10 +635,28 @@ func (self *Swarm) RegisterPssProtocol(spec *protocols.Spec, targetprotocol *p2p
 	if !pss.IsActiveProtocol {
 		return nil, fmt.Errorf("Pss protocols not available (built with !nopssprotocol tag)")
 	}
+	if self.ps == nil {
+		return nil, fmt.Errorf("pss is disabled")
+	}
 	topic := pss.ProtocolTopic(spec)
 	return pss.RegisterProtocol(self.ps, &topic, spec, targetprotocol, options)
 }
 
+// capabilities returns the capabilities the node advertises in the bzz
+// handshake, derived from the services it runs
+func capabilities(config *api.Config) network.Capabilities {
+	caps := network.NewCapabilities(network.CapabilityEncryption)
+	if config.LightNode {
+		caps |= network.NewCapabilities(network.CapabilityLight)
+	} else {
+		caps |= network.NewCapabilities(network.CapabilityStorer)
+	}
+	if config.PssEnabled {
+		caps |= network.NewCapabilities(network.CapabilityPss)
+	}
+	return caps
+}
+
 // implements node.Service
 // APIs returns the RPC API descriptors the Swarm implementation offers
 func (self *Swarm) APIs() []rpc.API {
@@ -472,6 +669,18 @@ func (self *Swarm) APIs() []rpc.API {
 			Service:   &Info{self.config, chequebook.ContractParams},
 			Public:    true,
 		},
+		{
+			Namespace: "bzz",
+			Version:   "3.0",
+			Service:   api.NewFeedAPI(self.api),
+			Public:    true,
+		},
+		{
+			Namespace: "bzz",
+			Version:   "3.0",
+			Service:   api.NewContentAPI(self.api),
+			Public:    true,
+		},
 		// admin APIs
 		{
 			Namespace: "bzz",
@@ -479,6 +688,45 @@ func (self *Swarm) APIs() []rpc.API {
 			Service:   api.NewControl(self.api, self.bzz.Hive),
 			Public:    false,
 		},
+		// the bzzadmin namespace manages the node and hands out secrets like
+		// the signing keys, it must not be whitelisted for HTTP or WebSocket
+		// together with the content of the bzz namespace
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   api.NewNameAPI(self.api),
+			Public:    false,
+		},
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   api.NewBlocklistAPI(self.blocklist),
+			Public:    false,
+		},
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   api.NewSigningKeysAPI(self.signingKeys),
+			Public:    false,
+		},
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   storage.NewRotationAPI(self.lstore.DbStore),
+			Public:    false,
+		},
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   storage.NewUsageAPI(self.lstore.DbStore),
+			Public:    false,
+		},
+		{
+			Namespace: "bzzadmin",
+			Version:   "3.0",
+			Service:   storage.NewEventsAPI(self.lstore.DbStore),
+			Public:    false,
+		},
 		{
 			Namespace: "chequebook",
 			Version:   chequebook.Version,
@@ -509,6 +757,19 @@ func (self *Swarm) APIs() []rpc.API {
 	}
 
 	apis = append(apis, self.bzz.APIs()...)
+	// the stream namespace controls the subscriptions of the peers and is
+	// not published, only the sync progress and the receipts are
+	apis = append(apis, rpc.API{
+		Namespace: "bzz",
+		Version:   "3.0",
+		Service:   stream.NewSyncAPI(self.streamer),
+		Public:    true,
+	}, rpc.API{
+		Namespace: "bzz",
+		Version:   "3.0",
+		Service:   stream.NewReceiptAPI(self.streamer),
+		Public:    true,
+	})
 
 	if self.ps != nil {
 		apis = append(apis, self.ps.APIs()...)
@@ -521,6 +782,30 @@ func (self *Swarm) Api() *api.API {
 	return self.api
 }
 
+// Snapshot exports the chunks of the local store, it is called by the
+// simulation framework to include the chunk state in network snapshots
+func (self *Swarm) Snapshot() ([]byte, error) {
+	var buf bytes.Buffer
+	count, err := self.lstore.DbStore.Export(&buf)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("exported local store snapshot", "chunks", count)
+	return buf.Bytes(), nil
+}
+
+// LoadSnapshot imports the chunks exported by Snapshot into the local store,
+// simulation services call it with the snapshot of the service context so
+// that restored nodes start with the chunks they stored
+func (self *Swarm) LoadSnapshot(snapshot []byte) error {
+	count, err := self.lstore.DbStore.Import(bytes.NewReader(snapshot))
+	if err != nil {
+		return err
+	}
+	log.Debug("imported local store snapshot", "chunks", count)
+	return nil
+}
+
 // SetChequebook ensures that the local checquebook is set up on chain.
 func (self *Swarm) SetChequebook(ctx context.Context) error {
 	err := self.config.Swap.SetChequebook(ctx, self.backend, self.config.Path)
@@ -17,6 +17,7 @@
 package swarm
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/swarm/api"
+	"github.com/ethereum/go-ethereum/swarm/storage"
 )
 
 // TestNewSwarm validates Swarm fields in repsect to the provided configuration.
@@ -288,6 +290,37 @@ func TestParseEnsAPIAddress(t *testing.T) {
 	}
 }
 
+// TestStoreKey tests that the key the chunk data is encrypted with at rest
+// is derived from the node key or printed by the key command
+func TestStoreKey(t *testing.T) {
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := storeKey("", prvKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, storage.DeriveStoreKey(prvKey)) {
+		t.Fatal("expected the key derived from the node key")
+	}
+
+	want := make([]byte, 32)
+	rand.Read(want)
+	key, err = storeKey("echo 0x"+hex.EncodeToString(want), prvKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, want) {
+		t.Fatalf("expected key %x, got %x", want, key)
+	}
+	for _, command := range []string{"echo abcd", "echo nothex", "exit 1"} {
+		if _, err := storeKey(command, prvKey); err == nil {
+			t.Fatalf("%s: expected error", command)
+		}
+	}
+}
+
 // TestLocalStoreAndRetrieve runs multiple tests where different size files are uploaded
 // to a single Swarm instance using API Store and checked against the content returned
 // by API Retrieve function.
@@ -376,3 +409,128 @@ func testLocalStoreAndRetrieve(t *testing.T, swarm *Swarm, n int, randomData boo
 		}
 	}
 }
+
+// TestSnapshot validates that the chunks exported by Swarm.Snapshot are
+// retrievable from another Swarm instance after loading the snapshot.
+func TestSnapshot(t *testing.T) {
+	newSwarm := func() *Swarm {
+		config := api.NewConfig()
+
+		dir, err := ioutil.TempDir("", "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Path = dir
+
+		privkey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Init(privkey)
+
+		swarm, err := NewSwarm(config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return swarm
+	}
+
+	one := newSwarm()
+	defer os.RemoveAll(one.config.Path)
+	defer one.lstore.Close()
+
+	data := make([]byte, 4097)
+	rand.Read(data)
+	ctx := context.TODO()
+	k, wait, err := one.api.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != nil {
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot, err := one.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newSwarm()
+	defer os.RemoveAll(other.config.Path)
+	defer other.lstore.Close()
+
+	if err := other.LoadSnapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := other.api.Retrieve(ctx, k)
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data not matched")
+	}
+}
+
+// TestNewNode validates that an embedded node stores its data in the data
+// directory and serves content through its API while it is running.
+func TestNewNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bzzconfig := api.NewConfig()
+	bzzconfig.Port = ""
+	cfg := DefaultNodeConfig()
+	cfg.DataDir = dir
+	cfg.P2P.ListenAddr = "127.0.0.1:0"
+	cfg.P2P.NoDiscovery = true
+	cfg.P2P.NAT = nil
+
+	n, err := NewNode(&NodeConfig{PrivateKey: privkey, Swarm: bzzconfig, Node: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bzzconfig.Path, path.Join(dir, DefaultNodeName)) {
+		t.Fatalf("expected swarm data in %s, got %s", path.Join(dir, DefaultNodeName), bzzconfig.Path)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer n.Stop()
+
+	ctx := context.TODO()
+	addr, wait, err := n.API().Put(ctx, "embedded", "text/plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r, contentType, _, _, _, err := n.API().Get(ctx, addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "embedded" || contentType != "text/plain" {
+		t.Fatalf("expected embedded text/plain content, got %q %s", data, contentType)
+	}
+}
+
+func TestNewNodeMissingKey(t *testing.T) {
+	if _, err := NewNode(&NodeConfig{}); err == nil {
+		t.Fatal("expected a node without a swarm account key not to be created")
+	}
+}